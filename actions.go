@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	tele "gopkg.in/telebot.v3"
+)
+
+// --- MAINTENANCE ACTIONS (AWS LAMBDA) ---
+
+// authorizeAction checks the ?key= parameter against ADMIN_ACTION_KEY.
+// Actions are disabled entirely when no key is configured.
+func authorizeAction(request events.LambdaFunctionURLRequest) bool {
+	want := os.Getenv("ADMIN_ACTION_KEY")
+	got := request.QueryStringParameters["key"]
+	return want != "" && subtle.ConstantTimeCompare([]byte(want), []byte(got)) == 1
+}
+
+// handleAction serves ?action=<name> maintenance calls on the Function URL
+func handleAction(ctx context.Context, action string, request events.LambdaFunctionURLRequest) events.LambdaFunctionURLResponse {
+	if !authorizeAction(request) {
+		log.Printf("[ACTION] Rejected unauthorized action %q", action)
+		return events.LambdaFunctionURLResponse{StatusCode: 403, Body: "Forbidden"}
+	}
+	log.Printf("[ACTION] Running %s", action)
+
+	switch action {
+	case "selfcheck":
+		results, ok := runSelfCheck(ctx)
+		status := 200
+		if !ok {
+			status = 503
+		}
+		return events.LambdaFunctionURLResponse{StatusCode: status, Body: formatCheckResults(results)}
+	case "backup":
+		initDatabase()
+		results, err := runBackup(ctx, marketDB)
+		if err != nil {
+			log.Printf("[BACKUP ERROR] %v", err)
+			return events.LambdaFunctionURLResponse{StatusCode: 500, Body: err.Error()}
+		}
+		summary := formatBackupSummary(results)
+		b, _ := newBot(tele.Settings{Token: os.Getenv("TELEGRAM_TOKEN"), Synchronous: true, Offline: true})
+		sendAdmin(b, summary)
+		return events.LambdaFunctionURLResponse{StatusCode: 200, Body: summary}
+	case "migrate-symbols":
+		initDatabase()
+		summary, err := migrateSymbols(ctx)
+		if err != nil {
+			log.Printf("[MIGRATION ERROR] %v", err)
+			return events.LambdaFunctionURLResponse{StatusCode: 500, Body: err.Error()}
+		}
+		return events.LambdaFunctionURLResponse{StatusCode: 200, Body: summary}
+	case "health":
+		initDatabase()
+		if !databaseAvailable() {
+			return events.LambdaFunctionURLResponse{StatusCode: 503, Body: fmt.Sprintf("degraded: database down: %v", databaseErr)}
+		}
+		if w, ok := activeMaintenance(clock()); ok {
+			return events.LambdaFunctionURLResponse{StatusCode: 200, Body: "maintenance until " + w.End.Format(time.RFC3339)}
+		}
+		if status := broadcastHealth(); status.Missed {
+			return events.LambdaFunctionURLResponse{StatusCode: 503, Body: "degraded: broadcast due " +
+				status.Slot.Format(time.RFC3339) + " missed, last run " + status.Last.Format(time.RFC3339)}
+		}
+		return events.LambdaFunctionURLResponse{StatusCode: 200, Body: "ok"}
+	case "boards":
+		initDatabase()
+		b, _ := newBot(tele.Settings{Token: os.Getenv("TELEGRAM_TOKEN"), Synchronous: true, Offline: true})
+		announceMaintenance(b)
+		if _, ok := activeMaintenance(clock()); ok {
+			log.Println("[MAINTENANCE] Skipping board refresh")
+			return events.LambdaFunctionURLResponse{StatusCode: 200, Body: "maintenance"}
+		}
+		stats := refreshBoards(b, envInt("BOARD_MAX_EDITS", defaultBoardMaxEdits))
+		return events.LambdaFunctionURLResponse{StatusCode: 200, Body: fmt.Sprintf("%+v", stats)}
+	case "alerts":
+		initDatabase()
+		b, _ := newBot(tele.Settings{Token: os.Getenv("TELEGRAM_TOKEN"), Synchronous: true, Offline: true})
+		announceMaintenance(b)
+		if _, ok := activeMaintenance(clock()); ok {
+			log.Println("[MAINTENANCE] Skipping alert checks")
+			return events.LambdaFunctionURLResponse{StatusCode: 200, Body: "maintenance"}
+		}
+		checked, fired := evaluateAlerts(b)
+		return events.LambdaFunctionURLResponse{StatusCode: 200, Body: fmt.Sprintf("Checked %d alerts, %d fired", checked, fired)}
+	case "retries":
+		initDatabase()
+		b, _ := newBot(tele.Settings{Token: os.Getenv("TELEGRAM_TOKEN"), Synchronous: true, Offline: true})
+		processed := processReplyRetries(b)
+		return events.LambdaFunctionURLResponse{StatusCode: 200, Body: fmt.Sprintf("Retried %d replies", processed)}
+	case "resume":
+		initDatabase()
+		b, _ := newBot(tele.Settings{Token: os.Getenv("TELEGRAM_TOKEN"), Synchronous: true, Offline: true})
+		return events.LambdaFunctionURLResponse{StatusCode: 200, Body: resumeBroadcast(b)}
+	case "weekly":
+		initDatabase()
+		records, err := loadMetrics(metricsReportDays)
+		if err != nil {
+			log.Printf("[METRICS ERROR] %v", err)
+			return events.LambdaFunctionURLResponse{StatusCode: 500, Body: err.Error()}
+		}
+		summary := weeklyDigest(records, clock())
+		b, _ := newBot(tele.Settings{Token: os.Getenv("TELEGRAM_TOKEN"), Synchronous: true, Offline: true})
+		sendAdmin(b, summary)
+		return events.LambdaFunctionURLResponse{StatusCode: 200, Body: summary}
+	case "news":
+		initDatabase()
+		b, _ := newBot(tele.Settings{Token: os.Getenv("TELEGRAM_TOKEN"), Synchronous: true, Offline: true})
+		announceMaintenance(b)
+		if _, ok := activeMaintenance(clock()); ok {
+			log.Println("[MAINTENANCE] Skipping news check")
+			return events.LambdaFunctionURLResponse{StatusCode: 200, Body: "maintenance"}
+		}
+		// Fetching archives the feed; spikes are then measured against the archive
+		headlines, _ := fetchHeadlines(loadConfig(), newsLangVI)
+		spiked := checkKeywordSpikes(b)
+		return events.LambdaFunctionURLResponse{StatusCode: 200, Body: fmt.Sprintf("Fetched %d headlines, %d keywords spiking", len(headlines), spiked)}
+	default:
+		return events.LambdaFunctionURLResponse{StatusCode: 400, Body: "Unknown action"}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Admin roles, weakest first: viewers get read-only commands, admins operate the bot,
+// owners also manage the admin list
+const (
+	roleViewer = "viewer"
+	roleAdmin  = "admin"
+	roleOwner  = "owner"
+)
+
+// adminCacheTTL is how long the admin list is trusted before re-reading it
+const adminCacheTTL = time.Minute
+
+var roleRank = map[string]int{roleViewer: 1, roleAdmin: 2, roleOwner: 3}
+
+var (
+	adminCollection *mongo.Collection
+
+	adminMu       sync.Mutex
+	cachedAdmins  map[int64]string
+	adminLoadedAt time.Time
+)
+
+// --- ADMINS ---
+
+// loadAdmins returns chat → role, cached for adminCacheTTL. When nobody holds the owner
+// role, ADMIN_CHAT_ID is (re)bootstrapped as owner so the bot can't lock itself out.
+func loadAdmins() map[int64]string {
+	adminMu.Lock()
+	defer adminMu.Unlock()
+	if cachedAdmins != nil && clock().Sub(adminLoadedAt) < adminCacheTTL {
+		return cachedAdmins
+	}
+	admins := make(map[int64]string)
+	if env := adminChatID(); env != 0 {
+		// Without a database the env chat is the only admin, as before roles existed
+		admins[env] = roleOwner
+	}
+	if adminCollection == nil {
+		return admins
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if env := adminChatID(); env != 0 {
+		owners, err := adminCollection.CountDocuments(ctx, bson.M{"role": roleOwner})
+		if err == nil && owners == 0 {
+			adminCollection.UpdateOne(ctx, bson.M{"chat_id": env},
+				bson.M{"$set": bson.M{"chat_id": env, "role": roleOwner, "updated_at": clock()}},
+				options.Update().SetUpsert(true))
+			log.Printf("[ADMIN] Bootstrapped %d as owner from ADMIN_CHAT_ID", env)
+		}
+		delete(admins, env)
+	}
+	cursor, err := adminCollection.Find(ctx, bson.M{})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load admins: %v", err)
+		if cachedAdmins != nil {
+			return cachedAdmins
+		}
+		if env := adminChatID(); env != 0 {
+			admins[env] = roleOwner
+		}
+		return admins
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var a struct {
+			ChatID int64  `bson:"chat_id"`
+			Role   string `bson:"role"`
+		}
+		if cursor.Decode(&a) == nil && roleRank[a.Role] > 0 {
+			admins[a.ChatID] = a.Role
+		}
+	}
+	cachedAdmins = admins
+	adminLoadedAt = clock()
+	return admins
+}
+
+// invalidateAdmins forces the next loadAdmins to re-read the collection
+func invalidateAdmins() {
+	adminMu.Lock()
+	cachedAdmins = nil
+	adminMu.Unlock()
+}
+
+// hasRole reports whether chatID holds at least the given role
+func hasRole(chatID int64, role string) bool {
+	held, ok := loadAdmins()[chatID]
+	return ok && roleRank[held] >= roleRank[role]
+}
+
+// requireRole rejects chats below role as if the command didn't exist
+func requireRole(role string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(r *Request) error {
+			if !hasRole(r.ActorID(), role) {
+				return r.Reply(invalidCommandText)
+			}
+			return next(r)
+		}
+	}
+}
+
+// adminReply handles the owner-only "/admin add <id> [role]", "/admin remove <id>" and "/admin list"
+func adminReply(payload string) string {
+	usage := "ℹ️ Cú pháp: /admin list, /admin add <chat_id> [owner|admin|viewer], /admin remove <chat_id>"
+	args := strings.Fields(strings.ToLower(payload))
+	if adminCollection == nil {
+		return "⚠️ Không có kết nối cơ sở dữ liệu."
+	}
+	if len(args) == 1 && args[0] == "list" {
+		admins := loadAdmins()
+		ids := make([]int64, 0, len(admins))
+		for id := range admins {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			if roleRank[admins[ids[i]]] != roleRank[admins[ids[j]]] {
+				return roleRank[admins[ids[i]]] > roleRank[admins[ids[j]]]
+			}
+			return ids[i] < ids[j]
+		})
+		var sb strings.Builder
+		sb.WriteString("👮 Danh sách quản trị:\n")
+		for _, id := range ids {
+			fmt.Fprintf(&sb, "• %d — %s\n", id, admins[id])
+		}
+		return sb.String()
+	}
+	if len(args) < 2 {
+		return usage
+	}
+	id, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || !isValidChatID(id) {
+		return "⚠️ Chat ID không hợp lệ."
+	}
+	ctx := context.TODO()
+	defer invalidateAdmins()
+	switch args[0] {
+	case "add":
+		role := roleAdmin
+		if len(args) == 3 {
+			role = args[2]
+		}
+		if roleRank[role] == 0 || len(args) > 3 {
+			return usage
+		}
+		if role != roleOwner && loadAdmins()[id] == roleOwner && !hasOtherOwner(ctx, id) {
+			return "⚠️ Không thể hạ quyền chủ sở hữu cuối cùng."
+		}
+		_, err := adminCollection.UpdateOne(ctx, bson.M{"chat_id": id},
+			bson.M{"$set": bson.M{"chat_id": id, "role": role, "updated_at": clock()}}, options.Update().SetUpsert(true))
+		if err != nil {
+			log.Printf("[DATABASE ERROR] Failed to save admin %d: %v", id, err)
+			return "⚠️ Không thể lưu lúc này."
+		}
+		return fmt.Sprintf("✅ %d là %s.", id, role)
+	case "remove":
+		if len(args) != 2 {
+			return usage
+		}
+		if loadAdmins()[id] == roleOwner && !hasOtherOwner(ctx, id) {
+			return "⚠️ Không thể xóa chủ sở hữu cuối cùng."
+		}
+		res, err := adminCollection.DeleteOne(ctx, bson.M{"chat_id": id})
+		if err != nil {
+			log.Printf("[DATABASE ERROR] Failed to remove admin %d: %v", id, err)
+			return "⚠️ Không thể lưu lúc này."
+		}
+		if res.DeletedCount == 0 {
+			return fmt.Sprintf("ℹ️ %d không có trong danh sách quản trị.", id)
+		}
+		return fmt.Sprintf("🗑 Đã xóa quyền quản trị của %d.", id)
+	default:
+		return usage
+	}
+}
+
+// hasOtherOwner reports whether an owner other than id exists
+func hasOtherOwner(ctx context.Context, id int64) bool {
+	n, err := adminCollection.CountDocuments(ctx, bson.M{"role": roleOwner, "chat_id": bson.M{"$ne": id}})
+	return err == nil && n > 0
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	tele "gopkg.in/telebot.v3"
+)
+
+// Telegram rejects inline buttons whose callback data exceeds 64 bytes
+const maxCallbackData = 64
+
+// pendingAlertTTL is how long "Nhập giá khác" waits for the typed price
+const pendingAlertTTL = 10 * time.Minute
+
+// ladderPercents are the suggested distances from the current price, each side
+var ladderPercents = []float64{0.5, 1, 2}
+
+var priceFormatDecimals = regexp.MustCompile(`%\.(\d+)f`)
+
+// PendingAlert is a "Nhập giá khác" waiting for the chat's next plain-text message
+type PendingAlert struct {
+	Symbol string    `bson:"symbol"`
+	Until  time.Time `bson:"until"`
+}
+
+// --- ALERT LADDER ---
+
+// priceDecimals reads the decimals out of an asset's PriceFormat ("`$%.2f`" → 2)
+func priceDecimals(format string) int {
+	if m := priceFormatDecimals.FindStringSubmatch(format); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+	return 4
+}
+
+// roundTo rounds v to a multiple of step
+func roundTo(v, step float64) float64 {
+	return math.Round(v/step) * step
+}
+
+// suggestAlertLevels proposes alert levels around price: ±ladderPercents rounded to a step
+// of about 0.05% of price (never finer than the asset's decimals), plus the nearest round
+// numbers (one digit below the price's magnitude) on each side. below is nearest first
+// descending, above nearest first ascending; duplicates and the price itself are dropped.
+func suggestAlertLevels(price float64, decimals int) (below, above []float64) {
+	if price <= 0 {
+		return nil, nil
+	}
+	minStep := math.Pow(10, -float64(decimals))
+	step := math.Max(math.Pow(10, math.Floor(math.Log10(price*0.0005))), minStep)
+	round := math.Max(math.Pow(10, math.Floor(math.Log10(price))-1), minStep)
+
+	seen := map[float64]bool{}
+	add := func(level float64) {
+		level = roundTo(level, minStep)
+		if level <= 0 || level == roundTo(price, minStep) || seen[level] {
+			return
+		}
+		seen[level] = true
+		if level < price {
+			below = append(below, level)
+		} else {
+			above = append(above, level)
+		}
+	}
+	for _, pct := range ladderPercents {
+		add(roundTo(price*(1-pct/100), step))
+		add(roundTo(price*(1+pct/100), step))
+	}
+	lower := math.Floor(price/round) * round
+	if lower == price {
+		lower -= round
+	}
+	add(lower)
+	add(lower + round)
+	sort.Sort(sort.Reverse(sort.Float64Slice(below)))
+	sort.Float64s(above)
+	return below, above
+}
+
+// fitsCallback reports whether a button's unique and data stay within Telegram's limit
+// (telebot sends "\f" + unique + "|" + data joined by "|")
+func fitsCallback(unique string, data ...string) bool {
+	return len(unique)+2+len(strings.Join(data, "|")) <= maxCallbackData
+}
+
+// alertLadderReply answers "/alert SYMBOL": the current price and a keyboard of levels that
+// create a one-shot price alert on tap
+func alertLadderReply(symbol string) (string, *tele.ReplyMarkup) {
+	d := getMarketData(symbol, os.Getenv("TWELVE_DATA_API_KEY"))
+	if d.Err != nil {
+		if rejection := symbolRejection(symbol, d.Err); rejection != "" {
+			return rejection, nil
+		}
+		return fmt.Sprintf("⚠️ Không lấy được giá %s, vui lòng kiểm tra lại mã.", symbol), nil
+	}
+	markSymbolKnown(symbol)
+	asset := lookupAsset(symbol)
+	decimals := priceDecimals(asset.PriceFormat)
+	below, above := suggestAlertLevels(d.Price, decimals)
+
+	menu := &tele.ReplyMarkup{}
+	var rows []tele.Row
+	level := func(dir string, v float64) (tele.Btn, bool) {
+		raw := strconv.FormatFloat(v, 'f', -1, 64)
+		if !fitsCallback("btn_alert_set", symbol, raw) {
+			return tele.Btn{}, false
+		}
+		icon := "📈"
+		if dir == "below" {
+			icon = "📉"
+		}
+		return menu.Data(icon+" "+strconv.FormatFloat(v, 'f', decimals, 64), "btn_alert_set", symbol, raw), true
+	}
+	for i := 0; i < len(below) || i < len(above); i++ {
+		var row tele.Row
+		if i < len(below) {
+			if btn, ok := level("below", below[i]); ok {
+				row = append(row, btn)
+			}
+		}
+		if i < len(above) {
+			if btn, ok := level("above", above[i]); ok {
+				row = append(row, btn)
+			}
+		}
+		if len(row) > 0 {
+			rows = append(rows, row)
+		}
+	}
+	if fitsCallback("btn_alert_custom", symbol) {
+		rows = append(rows, menu.Row(menu.Data("✏️ Nhập giá khác", "btn_alert_custom", symbol)))
+	}
+	menu.Inline(rows...)
+	text := fmt.Sprintf("🔔 **%s** hiện ở %s\nChọn mức giá để đặt cảnh báo (📉 khi giảm xuống, 📈 khi tăng lên):",
+		symbolLabel(symbol), fmt.Sprintf(asset.PriceFormat, d.Price))
+	return text, menu
+}
+
+// createLadderAlert saves a one-shot price alert at level, its direction taken from where
+// the price is now; the returned id is empty on failure
+func createLadderAlert(chatID int64, symbol string, level float64) (string, primitive.ObjectID) {
+	d := getMarketData(symbol, os.Getenv("TWELVE_DATA_API_KEY"))
+	if d.Err != nil {
+		return fmt.Sprintf("⚠️ Không lấy được giá %s, vui lòng thử lại.", symbol), primitive.NilObjectID
+	}
+	a := Alert{ID: primitive.NewObjectID(), ChatID: chatID, Symbol: symbol, Type: alertTypePrice,
+		Direction: "above", Target: level, CreatedAt: clock()}
+	if level < d.Price {
+		a.Direction = "below"
+	}
+	msg := saveAlert(a)
+	if !strings.HasPrefix(msg, "🔔") {
+		return msg, primitive.NilObjectID
+	}
+	return msg, a.ID
+}
+
+// deleteAlertMenu is the confirmation's immediate undo
+func deleteAlertMenu(id primitive.ObjectID) *tele.ReplyMarkup {
+	menu := &tele.ReplyMarkup{}
+	menu.Inline(menu.Row(menu.Data("🗑 Xóa", "btn_alert_del", id.Hex())))
+	return menu
+}
+
+// setPendingAlert arms "Nhập giá khác" for the chat
+func setPendingAlert(chatID int64, symbol string) bool {
+	if userCollection == nil {
+		return false
+	}
+	_, err := userCollection.UpdateOne(context.TODO(), bson.M{"chat_id": chatID},
+		bson.M{"$set": bson.M{"pending_alert": PendingAlert{Symbol: symbol, Until: clock().Add(pendingAlertTTL)}}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to save pending alert for %d: %v", chatID, err)
+		return false
+	}
+	return true
+}
+
+// clearPendingAlert disarms "Nhập giá khác"
+func clearPendingAlert(chatID int64) {
+	if userCollection == nil {
+		return
+	}
+	if _, err := userCollection.UpdateOne(context.TODO(), bson.M{"chat_id": chatID},
+		bson.M{"$unset": bson.M{"pending_alert": ""}}); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to clear pending alert for %d: %v", chatID, err)
+	}
+}
+
+// plainTextHandler answers messages that aren't commands: the price typed after
+// "Nhập giá khác", otherwise the invalid-command reply
+func plainTextHandler(r *Request) error {
+	if r.User == nil || r.User.PendingAlert == nil || clock().After(r.User.PendingAlert.Until) {
+		return textHint(r)
+	}
+	p := r.User.PendingAlert
+	level, err := parseAmount(strings.TrimSpace(r.Payload), currencyUSD)
+	if err != nil {
+		return r.Reply("⚠️ Mức giá không hợp lệ, hãy gửi một số, ví dụ 2350.5.")
+	}
+	clearPendingAlert(r.ChatID())
+	msg, id := createLadderAlert(r.ChatID(), p.Symbol, level)
+	if id.IsZero() {
+		return r.Reply(msg, markdown())
+	}
+	return r.Reply(msg, &tele.SendOptions{ParseMode: tele.ModeMarkdown, ReplyMarkup: deleteAlertMenu(id)})
+}
+
+// handleAlertCallback handles the ladder's buttons; the returned text is the callback toast
+func handleAlertCallback(b *tele.Bot, cb *tele.Callback, unique, data string) string {
+	if cb.Message == nil || cb.Message.Chat == nil {
+		return "Tin nhắn đã quá cũ, vui lòng gõ lại /alert."
+	}
+	chatID := cb.Message.Chat.ID
+	switch unique {
+	case "btn_alert_set":
+		symbol, raw, _ := strings.Cut(data, "|")
+		level, err := strconv.ParseFloat(raw, 64)
+		if err != nil || level <= 0 {
+			return "⚠️ Mức giá không hợp lệ."
+		}
+		msg, id := createLadderAlert(chatID, symbol, level)
+		if id.IsZero() {
+			return msg
+		}
+		b.Send(cb.Message.Chat, msg, &tele.SendOptions{ParseMode: tele.ModeMarkdown, ReplyMarkup: deleteAlertMenu(id)})
+		return "✅ Đã tạo cảnh báo"
+	case "btn_alert_custom":
+		if !setPendingAlert(chatID, data) {
+			return "⚠️ Bạn cần đăng ký bằng /start trước."
+		}
+		b.Send(cb.Message.Chat, fmt.Sprintf("✏️ Gửi mức giá cho %s (trong %d phút).", data, int(pendingAlertTTL.Minutes())))
+		return ""
+	case "btn_alert_del":
+		id, err := primitive.ObjectIDFromHex(data)
+		if err != nil || alertCollection == nil {
+			return "⚠️ Không thể xóa cảnh báo lúc này."
+		}
+		res, err := alertCollection.DeleteOne(context.TODO(), bson.M{"_id": id, "chat_id": chatID})
+		if err != nil {
+			log.Printf("[DATABASE ERROR] Failed to delete alert for %d: %v", chatID, err)
+			return "⚠️ Không thể xóa cảnh báo lúc này."
+		}
+		if res.DeletedCount == 0 {
+			return "ℹ️ Cảnh báo đã được xóa hoặc đã kích hoạt."
+		}
+		b.Edit(cb.Message, "🗑 Đã xóa cảnh báo.")
+		return "🗑 Đã xóa"
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func approxLevels(got, want []float64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if math.Abs(got[i]-want[i]) > 1e-9*math.Max(1, want[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSuggestAlertLevels(t *testing.T) {
+	tests := []struct {
+		price        float64
+		decimals     int
+		below, above []float64
+	}{
+		{60000, 2, []float64{59700, 59400, 59000, 58800}, []float64{60300, 60600, 61200}},
+		{2345.67, 2, []float64{2334, 2322, 2300, 2299}, []float64{2357, 2369, 2393, 2400}},
+		{1.0842, 4, []float64{1.0788, 1.0734, 1.0625, 1.0}, []float64{1.0896, 1.095, 1.1, 1.1059}},
+	}
+	for _, tt := range tests {
+		below, above := suggestAlertLevels(tt.price, tt.decimals)
+		if !approxLevels(below, tt.below) || !approxLevels(above, tt.above) {
+			t.Errorf("suggestAlertLevels(%g) = %v / %v, want %v / %v", tt.price, below, above, tt.below, tt.above)
+		}
+	}
+	if below, above := suggestAlertLevels(0, 2); below != nil || above != nil {
+		t.Errorf("no price gave levels %v / %v", below, above)
+	}
+}
+
+func TestPriceDecimals(t *testing.T) {
+	for format, want := range map[string]int{"`$%.2f`": 2, "`%.4f`": 4, "%.0f đ": 0, "%v": 4} {
+		if got := priceDecimals(format); got != want {
+			t.Errorf("priceDecimals(%q) = %d, want %d", format, got, want)
+		}
+	}
+}
+
+func TestFitsCallback(t *testing.T) {
+	if !fitsCallback("btn_alert_set", "btc", "59700") {
+		t.Error("a short ladder button doesn't fit")
+	}
+	// "\f" + unique + "|" + data is exactly 64 bytes
+	if data := strings.Repeat("x", maxCallbackData-2-len("btn_x")); !fitsCallback("btn_x", data) {
+		t.Error("64 bytes rejected")
+	}
+	if data := strings.Repeat("x", maxCallbackData-1-len("btn_x")); fitsCallback("btn_x", data) {
+		t.Error("65 bytes accepted")
+	}
+}
@@ -0,0 +1,554 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	tele "gopkg.in/telebot.v3"
+)
+
+// Alert types (the "type" discriminator on alert documents)
+const (
+	alertTypePrice = "price"
+	alertTypeMove  = "move"
+	alertTypeTrail = "trail"
+)
+
+// Reference points for move alerts
+const (
+	moveBasisSession = "session"
+	moveBasisCreated = "created"
+)
+
+// maxAlertsPerChat bounds how many active alerts one chat can hold
+const maxAlertsPerChat = 20
+
+var alertCollection *mongo.Collection
+
+// Alert is one user alert. Price alerts use Direction/Target; move alerts use
+// Percent/Basis, plus BasePrice when measured from creation; trailing alerts use
+// Percent and the highest price seen so far in Peak. Recurring price alerts are never
+// marked triggered; they disarm after firing and re-arm once price moves back past the
+// level by the configured buffer.
+type Alert struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	ChatID      int64              `bson:"chat_id"`
+	Symbol      string             `bson:"symbol"`
+	Type        string             `bson:"type"`
+	Direction   string             `bson:"direction,omitempty"`
+	Target      float64            `bson:"target,omitempty"`
+	Percent     float64            `bson:"percent,omitempty"`
+	Basis       string             `bson:"basis,omitempty"`
+	BasePrice   float64            `bson:"base_price,omitempty"`
+	Peak        float64            `bson:"peak,omitempty"`
+	Triggered   bool               `bson:"triggered"`
+	Recurring   bool               `bson:"recurring,omitempty"`
+	Disarmed    bool               `bson:"disarmed"`
+	LastFiredAt time.Time          `bson:"last_fired_at,omitempty"`
+	FiresDay    string             `bson:"fires_day,omitempty"`
+	FiresToday  int                `bson:"fires_today"`
+	CreatedAt   time.Time          `bson:"created_at"`
+	TriggeredAt time.Time          `bson:"triggered_at,omitempty"`
+	// Origin marks alerts created on the user's behalf (alertOriginTarget), so they can be
+	// replaced or removed with what created them
+	Origin string `bson:"origin,omitempty"`
+}
+
+// --- ALERTS ---
+
+// parsePercent parses "3%" or "3" into a positive percentage
+func parsePercent(s string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil || v <= 0 || v > 100 {
+		return 0, fmt.Errorf("invalid percent %q", s)
+	}
+	return v, nil
+}
+
+// describeAlert renders an alert for /alerts and for trigger messages
+func describeAlert(a Alert) string {
+	asset := lookupAsset(a.Symbol)
+	switch a.Type {
+	case alertTypePortfolio:
+		verb := "vượt lên trên"
+		if a.Direction == "below" {
+			verb = "giảm xuống dưới"
+		}
+		return fmt.Sprintf("Tổng danh mục %s %s VNĐ", verb, formatVnd(a.Target))
+	case alertTypeTrail:
+		return fmt.Sprintf("%s trailing %.2f%% (đỉnh %s, kích hoạt tại %s)", symbolLabel(a.Symbol), a.Percent,
+			fmt.Sprintf(asset.PriceFormat, a.Peak), fmt.Sprintf(asset.PriceFormat, trailTrigger(a)))
+	case alertTypeMove:
+		if a.Basis == moveBasisCreated {
+			return fmt.Sprintf("%s biến động ±%.2f%% kể từ khi tạo (%s)", symbolLabel(a.Symbol), a.Percent, fmt.Sprintf(asset.PriceFormat, a.BasePrice))
+		}
+		return fmt.Sprintf("%s biến động ±%.2f%% trong phiên", symbolLabel(a.Symbol), a.Percent)
+	default:
+		verb := "vượt lên trên"
+		if a.Direction == "below" {
+			verb = "giảm xuống dưới"
+		}
+		desc := fmt.Sprintf("%s %s %s", symbolLabel(a.Symbol), verb, fmt.Sprintf(asset.PriceFormat, a.Target))
+		if a.Recurring {
+			desc += " (lặp lại)"
+			if a.Disarmed {
+				desc += " — chờ giá quay lại để kích hoạt lại"
+			}
+		}
+		return desc
+	}
+}
+
+// alertMove returns the move an alert measures and whether it is available
+func alertMove(a Alert, d MarketData) (float64, bool) {
+	if a.Basis == moveBasisCreated {
+		if a.BasePrice <= 0 || d.Price <= 0 {
+			return 0, false
+		}
+		return (d.Price/a.BasePrice - 1) * 100, true
+	}
+	return d.Percent, d.HasPercent
+}
+
+// trailTrigger is the price at which a trailing alert fires
+func trailTrigger(a Alert) float64 {
+	return a.Peak * (1 - a.Percent/100)
+}
+
+// advanceTrail raises a trailing alert's peak when the price makes a new high and persists it
+func advanceTrail(a *Alert, d MarketData) {
+	if a.Type != alertTypeTrail || d.Err != nil || d.Price <= a.Peak {
+		return
+	}
+	a.Peak = d.Price
+	_, err := alertCollection.UpdateOne(context.TODO(), bson.M{"_id": a.ID, "peak": bson.M{"$lt": d.Price}},
+		bson.M{"$set": bson.M{"peak": d.Price}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to update trailing peak for %s: %v", a.ID.Hex(), err)
+	}
+}
+
+// priceCrossed reports whether price is at or beyond a price alert's level
+func priceCrossed(a Alert, price float64) bool {
+	if a.Direction == "below" {
+		return price <= a.Target
+	}
+	return price >= a.Target
+}
+
+// rearmed reports whether price has moved back across the level by buffer percent
+func rearmed(a Alert, price, buffer float64) bool {
+	if a.Direction == "below" {
+		return price >= a.Target*(1+buffer/100)
+	}
+	return price <= a.Target*(1-buffer/100)
+}
+
+// stepRecurring advances a recurring price alert by one quote and reports whether it
+// fires. A fired alert disarms until price retreats past the level by buffer percent,
+// so a price oscillating around the level fires once, never within cooldown of the
+// stored last_fired_at, and never more than maxPerDay times per (Vietnam) day.
+func stepRecurring(a Alert, price float64, now time.Time, buffer float64, maxPerDay int, cooldown time.Duration) (Alert, bool) {
+	if a.Disarmed {
+		if rearmed(a, price, buffer) {
+			a.Disarmed = false
+		}
+		return a, false
+	}
+	if !priceCrossed(a, price) {
+		return a, false
+	}
+	if !a.LastFiredAt.IsZero() && now.Sub(a.LastFiredAt) < cooldown {
+		return a, false
+	}
+	if day := now.In(vnLocation).Format("2006-01-02"); a.FiresDay != day {
+		a.FiresDay, a.FiresToday = day, 0
+	}
+	if a.FiresToday >= maxPerDay {
+		return a, false
+	}
+	a.FiresToday++
+	a.Disarmed = true
+	a.LastFiredAt = now
+	return a, true
+}
+
+// saveRecurringState persists a recurring alert's new state only if nobody else moved it
+// since it was read; the return value doubles as the claim for sending a notification.
+// Matching on last_fired_at too means a container that read the alert before another one
+// fired it can't fire it again, whatever in-memory state either of them holds.
+func saveRecurringState(prev, next Alert) bool {
+	res, err := alertCollection.UpdateOne(context.TODO(), recurringStateFilter(prev),
+		bson.M{"$set": bson.M{
+			"disarmed":      next.Disarmed,
+			"last_fired_at": next.LastFiredAt,
+			"fires_day":     next.FiresDay,
+			"fires_today":   next.FiresToday,
+		}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to update recurring alert %s: %v", prev.ID.Hex(), err)
+		return false
+	}
+	return res.ModifiedCount == 1
+}
+
+// recurringStateFilter matches the alert document only while it still holds prev's state
+func recurringStateFilter(prev Alert) bson.M {
+	filter := bson.M{"_id": prev.ID, "disarmed": prev.Disarmed, "fires_today": prev.FiresToday, "last_fired_at": prev.LastFiredAt}
+	if prev.LastFiredAt.IsZero() {
+		// Never fired: the field is either absent or the stored zero time
+		filter["last_fired_at"] = bson.M{"$in": bson.A{nil, time.Time{}}}
+	}
+	return filter
+}
+
+// alertFires reports whether a quote satisfies an alert
+func alertFires(a Alert, d MarketData) bool {
+	if d.Err != nil || d.Price <= 0 {
+		return false
+	}
+	switch a.Type {
+	case alertTypeTrail:
+		return a.Peak > 0 && d.Price <= trailTrigger(a)
+	case alertTypeMove:
+		move, ok := alertMove(a, d)
+		return ok && math.Abs(move) >= a.Percent
+	case alertTypePrice:
+		return priceCrossed(a, d.Price)
+	default:
+		return false
+	}
+}
+
+// claimAlert atomically marks an alert triggered; only the caller that flips it may notify,
+// so overlapping cron runs never send the same alert twice
+func claimAlert(id primitive.ObjectID) bool {
+	claimed, err := store.ClaimAlert(context.TODO(), id, clock())
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to claim alert %s: %v", id.Hex(), err)
+		return false
+	}
+	return claimed
+}
+
+// triggerMessage is the notification sent when an alert fires
+func triggerMessage(a Alert, d MarketData) string {
+	asset := lookupAsset(a.Symbol)
+	msg := fmt.Sprintf("🔔 **CẢNH BÁO GIÁ:** %s\n• Giá hiện tại: %s", describeAlert(a), fmt.Sprintf(asset.PriceFormat, d.Price))
+	if a.Type == alertTypeTrail {
+		msg = fmt.Sprintf("🔔 **CẢNH BÁO TRAILING STOP:** %s\n• Đỉnh kể từ khi đặt: %s\n• Mức kích hoạt (-%.2f%%): %s\n• Giá hiện tại: %s (%s so với đỉnh)",
+			symbolLabel(a.Symbol), fmt.Sprintf(asset.PriceFormat, a.Peak), a.Percent, fmt.Sprintf(asset.PriceFormat, trailTrigger(a)),
+			fmt.Sprintf(asset.PriceFormat, d.Price), formatPercent((d.Price/a.Peak-1)*100))
+	}
+	if a.Type == alertTypeMove {
+		if move, ok := alertMove(a, d); ok {
+			msg += fmt.Sprintf("\n• Biến động: %s", formatPercent(move))
+		}
+	}
+	if a.Origin == alertOriginTarget {
+		msg = fmt.Sprintf("🎯 **ĐẠT MỤC TIÊU LỢI NHUẬN:** %s\n• Mục tiêu: %s\n• Giá hiện tại: %s",
+			symbolLabel(a.Symbol), fmt.Sprintf(asset.PriceFormat, a.Target), fmt.Sprintf(asset.PriceFormat, d.Price))
+	}
+	return msg
+}
+
+// evaluateAlerts checks every pending alert against fresh quotes and notifies the ones that fire
+func evaluateAlerts(b *tele.Bot) (checked, fired int) {
+	if alertCollection == nil {
+		return 0, 0
+	}
+	cursor, err := alertCollection.Find(context.TODO(), bson.M{"triggered": false})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load alerts: %v", err)
+		return 0, 0
+	}
+	var alerts []Alert
+	if err := cursor.All(context.TODO(), &alerts); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to decode alerts: %v", err)
+		return 0, 0
+	}
+
+	threads := loadThreadIDs()
+	var symbols []string
+	var portfolioAlerts []Alert
+	for _, a := range alerts {
+		if a.Type == alertTypePortfolio {
+			portfolioAlerts = append(portfolioAlerts, a)
+			continue
+		}
+		symbols = append(symbols, a.Symbol)
+	}
+	checked, fired = evaluatePortfolioAlerts(b, portfolioAlerts, threads)
+	quotes := fetchQuotes(symbols)
+	cfg := loadConfig()
+
+	for _, a := range alerts {
+		if a.Type == alertTypePortfolio {
+			continue
+		}
+		checked++
+		d := quotes[a.Symbol]
+		advanceTrail(&a, d)
+		if a.Recurring {
+			if d.Err != nil || d.Price <= 0 {
+				continue
+			}
+			next, fire := stepRecurring(a, d.Price, clock(), cfg.AlertRearmBuffer, cfg.AlertMaxFiresPerDay, cfg.AlertCooldown)
+			if next.Disarmed == a.Disarmed && !fire {
+				continue
+			}
+			if !saveRecurringState(a, next) || !fire {
+				continue
+			}
+			if _, err := deliver(b, a.ChatID, threads[a.ChatID], triggerMessage(next, d), &tele.SendOptions{ParseMode: tele.ModeMarkdown}); err != nil {
+				log.Printf("[ALERT ERROR] Failed to notify %d: %v", a.ChatID, err)
+				continue
+			}
+			fired++
+			continue
+		}
+		if !alertFires(a, d) || !claimAlert(a.ID) {
+			continue
+		}
+		if _, err := deliver(b, a.ChatID, threads[a.ChatID], triggerMessage(a, d), &tele.SendOptions{ParseMode: tele.ModeMarkdown}); err != nil {
+			log.Printf("[ALERT ERROR] Failed to notify %d: %v", a.ChatID, err)
+			continue
+		}
+		fired++
+	}
+	if purged, err := store.PurgeTriggeredAlerts(context.TODO(), clock().Add(-triggeredAlertRetention)); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to purge fired alerts: %v", err)
+	} else if purged > 0 {
+		log.Printf("[ALERT] Purged %d fired alerts", purged)
+	}
+	log.Printf("[ALERT] Checked %d alerts, %d fired", checked, fired)
+	return checked, fired
+}
+
+// loadChatAlerts returns a chat's pending alerts, oldest first
+func loadChatAlerts(chatID int64) []Alert {
+	if store == nil {
+		return nil
+	}
+	alerts, err := store.PendingAlerts(context.TODO(), chatID)
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load alerts for %d: %v", chatID, err)
+		return nil
+	}
+	return alerts
+}
+
+// newAlertFromArgs builds an alert from "/alert SYMBOL above|below PRICE [repeat]" or
+// "/alert SYMBOL move 3% [since]", returning a user-facing message on rejection
+func newAlertFromArgs(chatID int64, args []string) (Alert, string) {
+	usage := "ℹ️ Cú pháp: `/alert btc above 70000` (thêm `repeat` để báo mỗi lần chạm), `/alert btc below 60000` hoặc `/alert btc move 3%` (thêm `since` để tính từ lúc tạo)"
+	if len(args) < 3 {
+		return Alert{}, usage
+	}
+	a := Alert{ChatID: chatID, Symbol: resolveSymbol(args[0]), CreatedAt: clock()}
+	d := getMarketData(a.Symbol, os.Getenv("TWELVE_DATA_API_KEY"))
+	if d.Err != nil {
+		if rejection := symbolRejection(a.Symbol, d.Err); rejection != "" {
+			return Alert{}, rejection
+		}
+		return Alert{}, fmt.Sprintf("⚠️ Không lấy được giá %s, vui lòng kiểm tra lại mã.", a.Symbol)
+	}
+	markSymbolKnown(a.Symbol)
+
+	switch kind := strings.ToLower(args[1]); kind {
+	case "above", "below":
+		if len(args) == 4 && strings.ToLower(args[3]) == "repeat" {
+			a.Recurring = true
+		} else if len(args) != 3 {
+			return Alert{}, usage
+		}
+		target, err := strconv.ParseFloat(args[2], 64)
+		if err != nil || target <= 0 {
+			return Alert{}, "⚠️ Mức giá không hợp lệ."
+		}
+		a.Type, a.Direction, a.Target = alertTypePrice, kind, target
+	case "move":
+		if len(args) > 4 {
+			return Alert{}, usage
+		}
+		pct, err := parsePercent(args[2])
+		if err != nil {
+			return Alert{}, "⚠️ Phần trăm không hợp lệ, ví dụ `3%`."
+		}
+		a.Type, a.Percent, a.Basis = alertTypeMove, pct, moveBasisSession
+		if len(args) == 4 {
+			if strings.ToLower(args[3]) != "since" {
+				return Alert{}, usage
+			}
+			a.Basis, a.BasePrice = moveBasisCreated, d.Price
+		}
+		if a.Basis == moveBasisSession && !d.HasPercent {
+			return Alert{}, fmt.Sprintf("⚠️ %s không có dữ liệu %% thay đổi trong phiên. Hãy dùng `/alert %s move %s since` để tính từ giá hiện tại.", a.Symbol, a.Symbol, args[2])
+		}
+	default:
+		return Alert{}, usage
+	}
+	return a, ""
+}
+
+// alertReply handles "/alert ..."
+func alertReply(chatID int64, payload string) string {
+	a, rejection := newAlertFromArgs(chatID, strings.Fields(payload))
+	if rejection != "" {
+		return rejection
+	}
+	return saveAlert(a)
+}
+
+// saveAlert stores a new alert, enforcing the per-chat limit
+func saveAlert(a Alert) string {
+	chatID := a.ChatID
+	if store == nil {
+		return "⚠️ Không thể lưu cảnh báo lúc này."
+	}
+	if len(loadChatAlerts(chatID)) >= maxAlertsPerChat {
+		return fmt.Sprintf("⚠️ Tối đa %d cảnh báo đang hoạt động.", maxAlertsPerChat)
+	}
+	if _, err := store.InsertAlert(context.TODO(), a); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to save alert for %d: %v", chatID, err)
+		return "⚠️ Không thể lưu cảnh báo lúc này."
+	}
+	return "🔔 Đã tạo cảnh báo: " + describeAlert(a)
+}
+
+// trailReply handles "/trail SYMBOL 3%"
+func trailReply(chatID int64, payload string) string {
+	args := strings.Fields(payload)
+	if len(args) != 2 {
+		return "ℹ️ Cú pháp: `/trail btc 3%` — báo khi giá giảm 3% từ đỉnh kể từ lúc đặt."
+	}
+	pct, err := parsePercent(args[1])
+	if err != nil {
+		return "⚠️ Phần trăm không hợp lệ, ví dụ `3%`."
+	}
+	a := Alert{ChatID: chatID, Symbol: resolveSymbol(args[0]), Type: alertTypeTrail, Percent: pct, CreatedAt: clock()}
+	d := getMarketData(a.Symbol, os.Getenv("TWELVE_DATA_API_KEY"))
+	if d.Err != nil {
+		if rejection := symbolRejection(a.Symbol, d.Err); rejection != "" {
+			return rejection
+		}
+		return fmt.Sprintf("⚠️ Không lấy được giá %s, vui lòng kiểm tra lại mã.", a.Symbol)
+	}
+	markSymbolKnown(a.Symbol)
+	a.Peak = d.Price
+	return saveAlert(a)
+}
+
+// alertAllReply handles "/alertall 5%": a session move alert for every watchlist symbol
+// that doesn't already have a move alert, up to the per-chat cap
+func alertAllReply(chatID int64, payload string) string {
+	pct, err := parsePercent(payload)
+	if err != nil {
+		return "ℹ️ Cú pháp: `/alertall 5%` — tạo cảnh báo biến động cho mọi mã trong danh sách theo dõi."
+	}
+	watchlist := getWatchlist(chatID)
+	if len(watchlist) == 0 {
+		return "ℹ️ Danh sách theo dõi trống. Thêm mã bằng `/watch add btc`."
+	}
+	if store == nil {
+		return "⚠️ Không thể lưu cảnh báo lúc này."
+	}
+	existing := loadChatAlerts(chatID)
+	hasMove := make(map[string]bool)
+	for _, a := range existing {
+		if a.Type == alertTypeMove {
+			hasMove[a.Symbol] = true
+		}
+	}
+
+	quotes := fetchQuotes(watchlist)
+	var created, skipped, noData []string
+	capped := false
+	for _, symbol := range watchlist {
+		if hasMove[symbol] {
+			skipped = append(skipped, symbol)
+			continue
+		}
+		if d := quotes[symbol]; d.Err != nil || !d.HasPercent {
+			noData = append(noData, symbol)
+			continue
+		}
+		if len(existing)+len(created) >= maxAlertsPerChat {
+			capped = true
+			break
+		}
+		a := Alert{ChatID: chatID, Symbol: symbol, Type: alertTypeMove, Percent: pct, Basis: moveBasisSession, CreatedAt: clock()}
+		if _, err := store.InsertAlert(context.TODO(), a); err != nil {
+			log.Printf("[DATABASE ERROR] Failed to save alert for %d: %v", chatID, err)
+			continue
+		}
+		created = append(created, symbol)
+	}
+
+	msg := fmt.Sprintf("🔔 Đã tạo %d cảnh báo biến động ±%.2f%%", len(created), pct)
+	if len(created) > 0 {
+		msg += ": " + strings.Join(created, ", ")
+	}
+	if len(skipped) > 0 {
+		msg += "\n• Bỏ qua (đã có cảnh báo biến động): " + strings.Join(skipped, ", ")
+	}
+	if len(noData) > 0 {
+		msg += "\n• Không có dữ liệu % thay đổi: " + strings.Join(noData, ", ")
+	}
+	if capped {
+		msg += fmt.Sprintf("\n⚠️ Đã đạt giới hạn %d cảnh báo, một số mã chưa được tạo.", maxAlertsPerChat)
+	}
+	return msg
+}
+
+// clearAlertsReply handles "/clearalerts"
+func clearAlertsReply(chatID int64) string {
+	if store == nil {
+		return "⚠️ Không thể xóa cảnh báo lúc này."
+	}
+	deleted, err := store.DeleteChatAlerts(context.TODO(), chatID)
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to clear alerts for %d: %v", chatID, err)
+		return "⚠️ Không thể xóa cảnh báo lúc này."
+	}
+	return fmt.Sprintf("🗑 Đã xóa %d cảnh báo.", deleted)
+}
+
+// alertsReply handles "/alerts" and "/alerts delete N"
+func alertsReply(chatID int64, payload string) string {
+	alerts := loadChatAlerts(chatID)
+	args := strings.Fields(payload)
+	if len(args) == 2 && strings.ToLower(args[0]) == "delete" {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 || n > len(alerts) {
+			return "⚠️ Số thứ tự không hợp lệ. Xem danh sách bằng /alerts."
+		}
+		if _, err := store.DeleteAlert(context.TODO(), alerts[n-1].ID); err != nil {
+			log.Printf("[DATABASE ERROR] Failed to delete alert for %d: %v", chatID, err)
+			return "⚠️ Không thể xóa cảnh báo lúc này."
+		}
+		return "🗑 Đã xóa cảnh báo: " + describeAlert(alerts[n-1])
+	}
+	if len(args) != 0 {
+		return "ℹ️ Cú pháp: /alerts hoặc `/alerts delete 2`"
+	}
+	if len(alerts) == 0 {
+		return "ℹ️ Bạn chưa có cảnh báo nào. Tạo mới bằng `/alert btc move 3%`."
+	}
+	var sb strings.Builder
+	sb.WriteString("🔔 **Cảnh báo đang hoạt động:**\n")
+	for i, a := range alerts {
+		fmt.Fprintf(&sb, "%d. %s\n", i+1, describeAlert(a))
+	}
+	sb.WriteString("_Xóa bằng_ `/alerts delete <số>`")
+	return sb.String()
+}
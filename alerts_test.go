@@ -0,0 +1,165 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTrailingStopFires(t *testing.T) {
+	a := Alert{Symbol: "btc", Type: alertTypeTrail, Percent: 5, Peak: 100000}
+	if got := trailTrigger(a); got != 95000 {
+		t.Fatalf("trailTrigger = %v, want 95000", got)
+	}
+	tests := []struct {
+		price float64
+		err   error
+		want  bool
+	}{
+		{96000, nil, false},
+		{95000, nil, true},
+		{90000, nil, true},
+		{0, nil, false},
+		{90000, errUnknownSymbol, false},
+	}
+	for _, tt := range tests {
+		if got := alertFires(a, MarketData{Price: tt.price, Err: tt.err}); got != tt.want {
+			t.Errorf("alertFires at %v (err %v) = %v, want %v", tt.price, tt.err, got, tt.want)
+		}
+	}
+	if alertFires(Alert{Type: alertTypeTrail, Percent: 5}, MarketData{Price: 1}) {
+		t.Error("a trailing alert without a peak fired")
+	}
+}
+
+func TestTrailingStopMessageUsesLabel(t *testing.T) {
+	a := Alert{Symbol: "gold", Type: alertTypeTrail, Percent: 2, Peak: 3000}
+	got := triggerMessage(a, MarketData{Price: 2900})
+	if first := strings.SplitN(got, "\n", 2)[0]; first != "🔔 **CẢNH BÁO TRAILING STOP:** "+symbolLabel("gold") {
+		t.Errorf("trigger message starts %q, want the %q label", first, symbolLabel("gold"))
+	}
+	if !strings.Contains(got, "📉 -3.33% so với đỉnh") {
+		t.Errorf("trigger message = %q, want the drop from the peak", got)
+	}
+}
+
+func TestProfitTargetMessageUsesLabel(t *testing.T) {
+	a := Alert{Symbol: "eurusd", Type: alertTypePrice, Direction: "above", Target: 1.2, Origin: alertOriginTarget}
+	got := triggerMessage(a, MarketData{Price: 1.21})
+	if first := strings.SplitN(got, "\n", 2)[0]; first != "🎯 **ĐẠT MỤC TIÊU LỢI NHUẬN:** "+symbolLabel("eurusd") {
+		t.Errorf("target message starts %q, want the %q label", first, symbolLabel("eurusd"))
+	}
+}
+
+func TestStepRecurring(t *testing.T) {
+	a := Alert{Type: alertTypePrice, Direction: "above", Target: 100, Recurring: true}
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, vnLocation)
+	const buffer, maxPerDay = 1.0, 2
+	step := func(price float64) bool {
+		var fired bool
+		a, fired = stepRecurring(a, price, now, buffer, maxPerDay, 0)
+		now = now.Add(time.Minute)
+		return fired
+	}
+
+	// Oscillating around the level fires once; re-arming needs a 1% retreat to 99
+	for i, tt := range []struct {
+		price float64
+		fire  bool
+	}{
+		{99.5, false}, {100, true}, {99.5, false}, {100.5, false}, {99, false}, {101, true},
+		// Re-armed again, but the daily cap of 2 is reached
+		{98, false}, {102, false},
+	} {
+		if got := step(tt.price); got != tt.fire {
+			t.Fatalf("step %d at %v fired = %v, want %v (state %+v)", i, tt.price, got, tt.fire, a)
+		}
+	}
+	// The cap resets on the next Vietnam day
+	now = time.Date(2026, 3, 3, 0, 5, 0, 0, vnLocation)
+	if !step(102) || a.FiresToday != 1 || a.FiresDay != "2026-03-03" {
+		t.Errorf("first crossing of a new day didn't fire: %+v", a)
+	}
+
+	below := Alert{Type: alertTypePrice, Direction: "below", Target: 100, Disarmed: true}
+	if rearmed(below, 100.5, 1) || !rearmed(below, 101, 1) {
+		t.Error("a below alert re-arms only once price is back 1% above the level")
+	}
+}
+
+func TestStepRecurringCooldown(t *testing.T) {
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, vnLocation)
+	// Fired 10 minutes ago and already re-armed, e.g. by another container
+	a := Alert{Type: alertTypePrice, Direction: "above", Target: 100, Recurring: true,
+		LastFiredAt: now.Add(-10 * time.Minute), FiresDay: "2026-03-02", FiresToday: 1}
+	if next, fired := stepRecurring(a, 101, now, 1, 5, 30*time.Minute); fired || next != a {
+		t.Errorf("fired within the cooldown of last_fired_at: %+v", next)
+	}
+	next, fired := stepRecurring(a, 101, now.Add(20*time.Minute), 1, 5, 30*time.Minute)
+	if !fired || !next.Disarmed || next.FiresToday != 2 || !next.LastFiredAt.Equal(now.Add(20*time.Minute)) {
+		t.Errorf("didn't fire once the cooldown passed: %v, %+v", fired, next)
+	}
+	if _, fired := stepRecurring(Alert{Type: alertTypePrice, Direction: "above", Target: 100, Recurring: true},
+		101, now, 1, 5, 30*time.Minute); !fired {
+		t.Error("an alert that never fired was held by the cooldown")
+	}
+}
+
+func TestRecurringStateFilter(t *testing.T) {
+	id := primitive.NewObjectID()
+	fired := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	got := recurringStateFilter(Alert{ID: id, Disarmed: true, FiresToday: 2, LastFiredAt: fired})
+	want := bson.M{"_id": id, "disarmed": true, "fires_today": 2, "last_fired_at": fired}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filter = %v, want %v", got, want)
+	}
+	got = recurringStateFilter(Alert{ID: id})
+	if never := (bson.M{"$in": bson.A{nil, time.Time{}}}); !reflect.DeepEqual(got["last_fired_at"], never) {
+		t.Errorf("a never-fired alert matched last_fired_at %v, want absent or zero", got["last_fired_at"])
+	}
+}
+
+func TestAlertCooldownConfig(t *testing.T) {
+	t.Setenv("ALERT_COOLDOWN", "45m")
+	cfg := configFromEnv()
+	if cfg.AlertCooldown != 45*time.Minute {
+		t.Fatalf("ALERT_COOLDOWN=45m gave %v", cfg.AlertCooldown)
+	}
+	for doc, want := range map[string]time.Duration{"10m": 10 * time.Minute, "0s": 0, "soon": 45 * time.Minute, "-5m": 45 * time.Minute} {
+		if got := applyConfigDoc(cfg, configDoc{AlertCooldown: doc}).AlertCooldown; got != want {
+			t.Errorf("alert_cooldown %q gave %v, want %v", doc, got, want)
+		}
+	}
+}
+
+func TestNewAlertFromArgsRepeat(t *testing.T) {
+	withDatabase(t, false, nil)
+	f := withFakeHTTP(t)
+	f.set(twelveDataHost, jsonResponse(`{"symbol":"BTC/USD","close":"61000","percent_change":"1.5"}`))
+	tests := []struct {
+		args      string
+		recurring bool
+		ok        bool
+	}{
+		{"btc above 70000", false, true},
+		{"btc above 70000 repeat", true, true},
+		{"btc below 50000 REPEAT", true, true},
+		{"btc above 70000 often", false, false},
+		{"btc move 3% repeat", false, false},
+		{"btc above -5", false, false},
+	}
+	for _, tt := range tests {
+		a, rejection := newAlertFromArgs(1, strings.Fields(tt.args))
+		if (rejection == "") != tt.ok || a.Recurring != tt.recurring {
+			t.Errorf("%q: recurring %v, rejection %q; want recurring %v, ok %v", tt.args, a.Recurring, rejection, tt.recurring, tt.ok)
+		}
+	}
+	a := Alert{Symbol: "btc", Type: alertTypePrice, Direction: "above", Target: 70000, Recurring: true, Disarmed: true}
+	if got := describeAlert(a); !strings.HasSuffix(got, "(lặp lại) — chờ giá quay lại để kích hoạt lại") {
+		t.Errorf("describeAlert = %q", got)
+	}
+}
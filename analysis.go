@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Analysis windows, in calendar days
+const (
+	defaultAnalysisDays = 30
+	maxAnalysisDays     = 365
+)
+
+// --- ANALYSIS COMMANDS ---
+
+// parseWindowDays parses "30d" (or "30") into a day count within [2, maxAnalysisDays]
+func parseWindowDays(arg string) (int, error) {
+	arg = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(arg)), "d")
+	if arg == "" {
+		return defaultAnalysisDays, nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 2 || n > maxAnalysisDays {
+		return 0, fmt.Errorf("invalid window")
+	}
+	return n, nil
+}
+
+// corrReply builds the reply for "/corr btc eth 30d"
+func corrReply(payload string) string {
+	args := strings.Fields(payload)
+	if len(args) < 2 || len(args) > 3 {
+		return "ℹ️ Cú pháp: `/corr btc eth 30d`"
+	}
+	symA, symB := resolveSymbol(args[0]), resolveSymbol(args[1])
+	window := ""
+	if len(args) == 3 {
+		window = args[2]
+	}
+	days, err := parseWindowDays(window)
+	if err != nil {
+		return fmt.Sprintf("⚠️ Khoảng thời gian không hợp lệ. Dùng từ 2d đến %dd, ví dụ `30d`.", maxAnalysisDays)
+	}
+
+	apiKey := os.Getenv("TWELVE_DATA_API_KEY")
+	seriesA, err := getRecentSeries(symA, apiKey, days)
+	if err != nil {
+		return fmt.Sprintf("⚠️ Không lấy được dữ liệu cho %s.", symA)
+	}
+	seriesB, err := getRecentSeries(symB, apiKey, days)
+	if err != nil {
+		return fmt.Sprintf("⚠️ Không lấy được dữ liệu cho %s.", symB)
+	}
+
+	// Crypto trades every day while FX skips weekends, so only shared dates are compared
+	closesA, closesB := alignByDate(seriesA, seriesB)
+	r, err := pearson(simpleReturns(closesA), simpleReturns(closesB))
+	if err != nil {
+		return fmt.Sprintf("⚠️ Không đủ dữ liệu chung giữa %s và %s trong %d ngày.", symA, symB, days)
+	}
+
+	return fmt.Sprintf("🔗 **Tương quan %s / %s (%d ngày)**\n"+
+		"• Hệ số Pearson (lợi suất ngày): `%.2f`\n"+
+		"• Đánh giá: %s\n"+
+		"_Dựa trên %d phiên có dữ liệu chung._",
+		symA, symB, days, r, describeCorrelation(r), len(closesA))
+}
+
+// volReply builds the reply for "/vol btc 30d": realized volatility from daily log
+// returns, annualized by how often the symbol actually traded in the window
+func volReply(payload string) string {
+	args := strings.Fields(payload)
+	if len(args) < 1 || len(args) > 2 {
+		return "ℹ️ Cú pháp: `/vol btc 30d`"
+	}
+	symbol := resolveSymbol(args[0])
+	window := ""
+	if len(args) == 2 {
+		window = args[1]
+	}
+	days, err := parseWindowDays(window)
+	if err != nil {
+		return fmt.Sprintf("⚠️ Khoảng thời gian không hợp lệ. Dùng từ 2d đến %dd, ví dụ `30d`.", maxAnalysisDays)
+	}
+
+	series, err := getRecentSeries(symbol, os.Getenv("TWELVE_DATA_API_KEY"), days)
+	if err != nil {
+		return fmt.Sprintf("⚠️ Không lấy được dữ liệu cho %s.", symbol)
+	}
+	closes := make([]float64, len(series))
+	for i, p := range series {
+		closes[i] = p.Close
+	}
+	returns := logReturns(closes)
+	// Crypto trades every day and FX/equities don't; the observed frequency covers both
+	perYear := float64(len(returns)) * 365 / float64(days)
+	vol, err := annualizedVolatility(returns, perYear)
+	if err != nil {
+		return fmt.Sprintf("⚠️ Không đủ dữ liệu cho %s trong %d ngày.", symbol, days)
+	}
+	cfg := loadConfig()
+	return fmt.Sprintf("🌪 **Biến động thực tế %s (%d ngày)**\n"+
+		"• Độ biến động năm hóa: `%.1f%%`\n"+
+		"• Đánh giá: %s _(ngưỡng %.0f%%–%.0f%%)_\n"+
+		"_Độ lệch chuẩn của lợi suất log theo ngày, dựa trên %d phiên._",
+		symbol, days, vol*100, describeVolatility(vol, cfg.VolLowBand, cfg.VolHighBand),
+		cfg.VolLowBand*100, cfg.VolHighBand*100, len(returns))
+}
+
+// previousClose backs the session's reference price out of the quote's percent change
+func previousClose(d MarketData) (float64, bool) {
+	if !d.HasPercent || d.Percent <= -100 {
+		return 0, false
+	}
+	return d.Price / (1 + d.Percent/100), true
+}
+
+// openReply builds the reply for "/open btc gold": each symbol's change since today's open
+// next to its change since the previous close, telling an intraday move from an overnight gap
+func openReply(payload string) string {
+	args := strings.Fields(payload)
+	if len(args) == 0 || len(args) > maxHoldings {
+		return "ℹ️ Cú pháp: `/open btc gold`"
+	}
+	symbols := make([]string, len(args))
+	for i, a := range args {
+		symbols[i] = resolveSymbol(a)
+	}
+	quotes := fetchQuotes(symbols)
+	var sb strings.Builder
+	sb.WriteString("🕘 **Biến động trong phiên**\n")
+	for _, s := range symbols {
+		d := quotes[s]
+		label := lookupAsset(s).Label
+		if d.Err != nil {
+			fmt.Fprintf(&sb, "\n• %s: ⚠️ không có dữ liệu", label)
+			continue
+		}
+		fromOpen := "không có giá mở cửa"
+		if d.Open > 0 {
+			fromOpen = fmt.Sprintf("`%+.2f%%` (mở cửa `%.4g`)", (d.Price/d.Open-1)*100, d.Open)
+		}
+		fromClose := "không có"
+		if d.HasPercent {
+			fromClose = fmt.Sprintf("`%+.2f%%`", d.Percent)
+		}
+		fmt.Fprintf(&sb, "\n• %s `%.4g`\n   Từ giá mở cửa: %s\n   So với đóng cửa phiên trước: %s", label, d.Price, fromOpen, fromClose)
+	}
+	return sb.String()
+}
+
+// spreadReply builds the reply for "/spread eurusd gbpusd": the ratio and difference of
+// two instruments now and how the ratio moved over the session
+func spreadReply(payload string) string {
+	args := strings.Fields(payload)
+	if len(args) != 2 {
+		return "ℹ️ Cú pháp: `/spread eurusd gbpusd`"
+	}
+	symA, symB := resolveSymbol(args[0]), resolveSymbol(args[1])
+	if symA == symB {
+		return "⚠️ Hãy chọn hai mã khác nhau."
+	}
+	quotes := fetchQuotes([]string{symA, symB})
+	a, b := quotes[symA], quotes[symB]
+	for _, s := range []string{symA, symB} {
+		if quotes[s].Err != nil {
+			return fmt.Sprintf("⚠️ Không lấy được giá cho %s.", s)
+		}
+	}
+	if b.Price == 0 {
+		return fmt.Sprintf("⚠️ Giá %s bằng 0, không thể tính tỷ lệ.", symB)
+	}
+	ratio := a.Price / b.Price
+	text := fmt.Sprintf("⚖️ **Tỷ lệ %s / %s**\n"+
+		"• %s: `%.4f` | %s: `%.4f`\n"+
+		"• Tỷ lệ: `%.4f`\n"+
+		"• Chênh lệch: `%.4f`",
+		symA, symB, symA, a.Price, symB, b.Price, ratio, a.Price-b.Price)
+	prevA, okA := previousClose(a)
+	prevB, okB := previousClose(b)
+	if okA && okB && prevB != 0 && prevA != 0 {
+		prevRatio := prevA / prevB
+		text += fmt.Sprintf("\n• Tỷ lệ đầu phiên: `%.4f` (%+.2f%% hôm nay)", prevRatio, (ratio/prevRatio-1)*100)
+	} else {
+		text += "\n_Không có dữ liệu biến động trong phiên để so sánh._"
+	}
+	return text
+}
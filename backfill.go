@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// snapshotSourceBackfill marks snapshots written by /backfill rather than a broadcast
+const snapshotSourceBackfill = "backfill"
+
+// twelveDataMaxOutput is the most bars one time_series call returns on any plan
+const twelveDataMaxOutput = 5000
+
+// Defaults for BACKFILL_MAX_DAYS (the history the current plan serves) and
+// BACKFILL_SPACING (the pause between time_series calls, to stay under the per-minute quota)
+const (
+	defaultBackfillMaxDays = 365
+	defaultBackfillSpacing = 8 * time.Second
+)
+
+// --- SNAPSHOT BACKFILL ---
+
+// backfillMaxDays is the largest N /backfill accepts
+func backfillMaxDays() int {
+	n := envInt("BACKFILL_MAX_DAYS", defaultBackfillMaxDays)
+	if n <= 0 || n > twelveDataMaxOutput {
+		return twelveDataMaxOutput
+	}
+	return n
+}
+
+// parseBackfillDays validates the N of "/backfill N" against the plan's history
+func parseBackfillDays(raw string) (int, error) {
+	days, err := strconv.Atoi(raw)
+	if err != nil || days < 1 {
+		return 0, fmt.Errorf("invalid day count %q", raw)
+	}
+	if max := backfillMaxDays(); days > max {
+		return 0, fmt.Errorf("%d days exceeds the %d the data plan serves (BACKFILL_MAX_DAYS)", days, max)
+	}
+	return days, nil
+}
+
+// backfillStamp is when a daily close is stored: the last minute of its UTC date, so it
+// sorts after that day's broadcasts
+func backfillStamp(date time.Time) time.Time {
+	d := date.UTC()
+	return time.Date(d.Year(), d.Month(), d.Day(), 23, 59, 0, 0, time.UTC)
+}
+
+// runBackfill writes one snapshot per day for the last days from Twelve Data daily closes
+// of every registry asset (and USD/VND), calling progress after each symbol. Days that
+// already have a broadcast snapshot are left alone, and backfilled points are upserted by
+// day, so a rerun refreshes rather than duplicates them. Returns the days written.
+func runBackfill(days int, progress func(done, total int, symbol string)) (int, error) {
+	if snapshotCollection == nil {
+		return 0, fmt.Errorf("database unavailable")
+	}
+	apiKey := os.Getenv("TWELVE_DATA_API_KEY")
+	end := clock()
+	start := end.AddDate(0, 0, -days)
+	spacing := envDuration("BACKFILL_SPACING", defaultBackfillSpacing)
+
+	symbols := make([]string, 0, len(assetRegistry)+1)
+	for _, a := range assetRegistry {
+		symbols = append(symbols, a.Symbol)
+	}
+	symbols = append(symbols, "usdvnd")
+
+	prices := make(map[time.Time]map[string]float64)
+	changes := make(map[time.Time]map[string]float64)
+	usdVnd := make(map[time.Time]float64)
+	fetched := 0
+	for i, symbol := range symbols {
+		if i > 0 {
+			time.Sleep(spacing)
+		}
+		// One extra day so the first point has a previous close to compare against
+		points, err := getTimeSeries(symbol, apiKey, start.AddDate(0, 0, -1), end)
+		if err != nil {
+			log.Printf("[BACKFILL] Skipping %s: %v", symbol, err)
+		} else {
+			fetched++
+		}
+		for j, p := range points {
+			if backfillStamp(p.Date).Before(backfillStamp(start)) {
+				continue
+			}
+			at := backfillStamp(p.Date)
+			if symbol == "usdvnd" {
+				usdVnd[at] = p.Close
+				continue
+			}
+			if prices[at] == nil {
+				prices[at], changes[at] = make(map[string]float64), make(map[string]float64)
+			}
+			prices[at][symbol] = p.Close
+			if j > 0 && points[j-1].Close > 0 {
+				changes[at][symbol] = (p.Close - points[j-1].Close) / points[j-1].Close * 100
+			}
+		}
+		if progress != nil {
+			progress(i+1, len(symbols), symbol)
+		}
+	}
+	if fetched == 0 {
+		return 0, fmt.Errorf("no symbol returned data")
+	}
+
+	covered, err := broadcastDays(start, end)
+	if err != nil {
+		return 0, err
+	}
+	written := 0
+	for at, p := range prices {
+		if covered[at] {
+			continue
+		}
+		snap := Snapshot{At: at, Prices: p, Changes: changes[at], UsdVnd: usdVnd[at], Source: snapshotSourceBackfill}
+		_, err := snapshotCollection.ReplaceOne(context.TODO(), bson.M{"at": at, "source": snapshotSourceBackfill},
+			snap, options.Replace().SetUpsert(true))
+		if err != nil {
+			log.Printf("[DATABASE ERROR] Failed to backfill snapshot for %s: %v", at.Format("2006-01-02"), err)
+			continue
+		}
+		written++
+	}
+	log.Printf("[BACKFILL] Wrote %d daily snapshots (%d/%d symbols)", written, fetched, len(symbols))
+	return written, nil
+}
+
+// broadcastDays returns the backfill stamps of days that already have a broadcast snapshot
+func broadcastDays(start, end time.Time) (map[time.Time]bool, error) {
+	covered := make(map[time.Time]bool)
+	cursor, err := snapshotCollection.Find(context.TODO(),
+		bson.M{"at": bson.M{"$gte": start, "$lte": end}, "source": bson.M{"$ne": snapshotSourceBackfill}},
+		options.Find().SetProjection(bson.M{"at": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+	for cursor.Next(context.TODO()) {
+		var s Snapshot
+		if cursor.Decode(&s) == nil {
+			covered[backfillStamp(s.At)] = true
+		}
+	}
+	return covered, cursor.Err()
+}
+
+// backfillReply handles the admin "/backfill <days>", editing one progress message as each
+// symbol is fetched
+func backfillReply(r *Request) error {
+	usage := fmt.Sprintf("ℹ️ Cú pháp: /backfill <số ngày> (tối đa %d)", backfillMaxDays())
+	if len(r.Args) != 1 {
+		return r.Reply(usage)
+	}
+	days, err := parseBackfillDays(r.Args[0])
+	if err != nil {
+		return r.Reply(usage)
+	}
+	msg, err := r.Bot.Send(r.Message.Chat, fmt.Sprintf("⏳ Đang nạp dữ liệu %d ngày...", days))
+	if err != nil {
+		return err
+	}
+	written, err := runBackfill(days, func(done, total int, symbol string) {
+		r.Bot.Edit(msg, fmt.Sprintf("⏳ Đang nạp dữ liệu %d ngày... %d/%d (%s)", days, done, total, symbol))
+	})
+	if err != nil {
+		_, err = r.Bot.Edit(msg, "⚠️ Không thể nạp dữ liệu: "+err.Error())
+		return err
+	}
+	_, err = r.Bot.Edit(msg, fmt.Sprintf("✅ Đã nạp %d ngày dữ liệu lịch sử.", written))
+	return err
+}
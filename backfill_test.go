@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseBackfillDays(t *testing.T) {
+	t.Setenv("BACKFILL_MAX_DAYS", "90")
+	tests := []struct {
+		raw     string
+		days    int
+		wantErr bool
+	}{
+		{"30", 30, false},
+		{"1", 1, false},
+		{"90", 90, false},
+		{"91", 0, true},
+		{"0", 0, true},
+		{"-5", 0, true},
+		{"ten", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		days, err := parseBackfillDays(tt.raw)
+		if days != tt.days || (err != nil) != tt.wantErr {
+			t.Errorf("parseBackfillDays(%q) = %d, %v; want %d, error %v", tt.raw, days, err, tt.days, tt.wantErr)
+		}
+	}
+}
+
+func TestBackfillMaxDays(t *testing.T) {
+	for raw, want := range map[string]int{"": defaultBackfillMaxDays, "730": 730, "0": defaultBackfillMaxDays, "99999": twelveDataMaxOutput} {
+		t.Setenv("BACKFILL_MAX_DAYS", raw)
+		if got := backfillMaxDays(); got != want {
+			t.Errorf("BACKFILL_MAX_DAYS=%q: backfillMaxDays = %d, want %d", raw, got, want)
+		}
+	}
+}
+
+func TestBackfillStamp(t *testing.T) {
+	want := time.Date(2026, 3, 2, 23, 59, 0, 0, time.UTC)
+	for _, date := range []time.Time{
+		time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+		time.Date(2026, 3, 2, 18, 30, 0, 0, time.UTC),
+		// 06:00 ICT on the 3rd is still the 2nd in UTC
+		time.Date(2026, 3, 3, 6, 0, 0, 0, vnLocation),
+	} {
+		if got := backfillStamp(date); !got.Equal(want) {
+			t.Errorf("backfillStamp(%v) = %v, want %v", date, got, want)
+		}
+	}
+	// A broadcast that day sorts before the backfilled close
+	if broadcast := time.Date(2026, 3, 2, 17, 30, 0, 0, vnLocation); !broadcast.Before(want) {
+		t.Errorf("broadcast %v sorts after the stamp %v", broadcast, want)
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// restoreBatchSize bounds each InsertMany during a restore
+const restoreBatchSize = 500
+
+// BackupResult summarizes one collection's dump
+type BackupResult struct {
+	Collection string
+	Key        string
+	Documents  int
+	Bytes      int
+	Err        error
+}
+
+// --- BACKUP (S3) ---
+
+// runBackup dumps every collection of the bot database to BACKUP_S3_BUCKET as
+// gzip-compressed newline-delimited Extended JSON, one object per collection under a
+// date-stamped prefix (e.g. market_bot/2024-06-01/users.ndjson.gz)
+func runBackup(ctx context.Context, db *mongo.Database) ([]BackupResult, error) {
+	bucket := os.Getenv("BACKUP_S3_BUCKET")
+	if bucket == "" {
+		return nil, fmt.Errorf("BACKUP_S3_BUCKET not set")
+	}
+	if db == nil {
+		return nil, fmt.Errorf("database unavailable")
+	}
+	names, err := db.ListCollectionNames(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	prefix := fmt.Sprintf("%s/%s", db.Name(), clock().UTC().Format("2006-01-02"))
+	var results []BackupResult
+	for _, name := range names {
+		r := BackupResult{Collection: name, Key: fmt.Sprintf("%s/%s.ndjson.gz", prefix, name)}
+		var buf bytes.Buffer
+		r.Documents, r.Err = dumpCollection(ctx, db.Collection(name), &buf)
+		if r.Err == nil {
+			r.Bytes = buf.Len()
+			r.Err = putS3Object(ctx, bucket, r.Key, buf.Bytes(), "application/gzip")
+		}
+		if r.Err != nil {
+			log.Printf("[BACKUP ERROR] %s: %v", name, r.Err)
+		} else {
+			log.Printf("[BACKUP] %s: %d docs, %d bytes → s3://%s/%s", name, r.Documents, r.Bytes, bucket, r.Key)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// dumpCollection streams every document of coll through a gzip writer into w
+func dumpCollection(ctx context.Context, coll *mongo.Collection, w io.Writer) (int, error) {
+	cursor, err := coll.Find(ctx, bson.M{})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	zw := gzip.NewWriter(w)
+	count := 0
+	for cursor.Next(ctx) {
+		// Canonical Extended JSON keeps ObjectIDs, dates and int64s intact for restore
+		line, err := bson.MarshalExtJSON(cursor.Current, true, false)
+		if err != nil {
+			return count, err
+		}
+		zw.Write(line)
+		zw.Write([]byte("\n"))
+		count++
+	}
+	if err := cursor.Err(); err != nil {
+		return count, err
+	}
+	return count, zw.Close()
+}
+
+// formatBackupSummary renders the admin-chat summary of a backup run
+func formatBackupSummary(results []BackupResult) string {
+	var sb strings.Builder
+	sb.WriteString("🗄 Sao lưu cơ sở dữ liệu\n")
+	totalDocs, totalBytes := 0, 0
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(&sb, "❌ %s: %v\n", r.Collection, r.Err)
+			continue
+		}
+		fmt.Fprintf(&sb, "✅ %s: %d docs, %.1f KB\n", r.Collection, r.Documents, float64(r.Bytes)/1024)
+		totalDocs += r.Documents
+		totalBytes += r.Bytes
+	}
+	fmt.Fprintf(&sb, "Tổng: %d docs, %.1f KB", totalDocs, float64(totalBytes)/1024)
+	return sb.String()
+}
+
+// putS3Object uploads body with a SigV4-signed PUT using the Lambda role's credentials
+// (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN are injected by the runtime)
+func putS3Object(ctx context.Context, bucket, key string, body []byte, contentType string) error {
+	region := os.Getenv("AWS_REGION")
+	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
+	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if region == "" || accessKey == "" || secretKey == "" {
+		return fmt.Errorf("AWS credentials or region not available")
+	}
+
+	host := fmt.Sprintf("%s.s3.%s.amazonaws.com", bucket, region)
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	path := "/" + strings.Join(segments, "/")
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	headers := map[string]string{
+		"content-type":         contentType,
+		"host":                 host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		headers["x-amz-security-token"] = token
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{http.MethodPut, path, "", canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", day, region)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+secretKey), day)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, "https://"+host+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, value := range headers {
+		if name != "host" {
+			req.Header.Set(name, value)
+		}
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("s3 put failed: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// --- RESTORE (LOCAL CLI) ---
+
+// runRestore loads a dump produced by runBackup into the database at MONGODB_URI.
+// path is a single <collection>.ndjson.gz file or a directory of them. It refuses to
+// touch a non-empty users collection unless force is set.
+func runRestore(path string, force bool) error {
+	files, err := restoreFiles(path)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no .ndjson.gz files found in %s", path)
+	}
+
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(os.Getenv("MONGODB_URI")))
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(ctx)
+	db := client.Database("market_bot")
+
+	existing, err := db.Collection(activeProfile().collectionName("users")).EstimatedDocumentCount(ctx)
+	if err != nil {
+		return err
+	}
+	if existing > 0 && !force {
+		return fmt.Errorf("users collection already has %d documents; rerun with --force to restore anyway", existing)
+	}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".ndjson.gz")
+		n, err := restoreCollection(ctx, db.Collection(name), file)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		log.Printf("[RESTORE] %s: %d documents inserted", name, n)
+	}
+	return nil
+}
+
+// restoreFiles resolves path to the list of dump files to load
+func restoreFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	return filepath.Glob(filepath.Join(path, "*.ndjson.gz"))
+}
+
+// restoreCollection inserts every line of a gzip NDJSON dump into coll in batches
+func restoreCollection(ctx context.Context, coll *mongo.Collection, file string) (int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return 0, err
+	}
+	defer zr.Close()
+
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	inserted := 0
+	batch := make([]interface{}, 0, restoreBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		// Unordered so a duplicate _id from a partial earlier restore doesn't stop the rest
+		res, err := coll.InsertMany(ctx, batch, options.InsertMany().SetOrdered(false))
+		if res != nil {
+			inserted += len(res.InsertedIDs)
+		}
+		batch = batch[:0]
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+		return nil
+	}
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var doc bson.D
+		if err := bson.UnmarshalExtJSON(scanner.Bytes(), true, &doc); err != nil {
+			return inserted, err
+		}
+		batch = append(batch, doc)
+		if len(batch) == restoreBatchSize {
+			if err := flush(); err != nil {
+				return inserted, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return inserted, err
+	}
+	return inserted, flush()
+}
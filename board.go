@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// defaultBoardMaxEdits caps the boards one ?action=boards run will touch (BOARD_MAX_EDITS)
+const defaultBoardMaxEdits = 50
+
+// boardUser is the slice of a user document the board refresh needs
+type boardUser struct {
+	ChatID         int64     `bson:"chat_id"`
+	Watchlist      []string  `bson:"watchlist"`
+	Tier           string    `bson:"tier"`
+	PremiumUntil   time.Time `bson:"premium_until"`
+	BoardMessageID int       `bson:"board_message_id"`
+	BoardBody      string    `bson:"board_body"`
+}
+
+// BoardRunStats summarizes one refresh run
+type BoardRunStats struct {
+	Checked, Edited, Unchanged, Recreated, Failed int
+}
+
+// --- WATCHLIST PRICE BOARD ---
+
+// boardBody renders the price lines of a board; it doubles as the diff key, so it must
+// not contain the update time
+func boardBody(symbols []string, quotes map[string]MarketData) string {
+	rows := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		asset := lookupAsset(symbol)
+		rows[i] = quoteLine(asset.Label, asset.PriceFormat, quotes[symbol])
+	}
+	return strings.Join(rows, "\n")
+}
+
+// boardText wraps a board body with its header and update time
+func boardText(body string, now time.Time) string {
+	return fmt.Sprintf("📌 **BẢNG GIÁ CỦA BẠN**\n%s\n\n_Cập nhật: %s_", body, now.In(vnLocation).Format("15:04 02/01"))
+}
+
+// fetchQuotes quotes each distinct symbol once, in a single batch call
+func fetchQuotes(symbols []string) map[string]MarketData {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, symbol := range symbols {
+		if !seen[symbol] {
+			seen[symbol] = true
+			unique = append(unique, symbol)
+		}
+	}
+	return getMarketDataBatch(unique, os.Getenv("TWELVE_DATA_API_KEY"))
+}
+
+// sendBoard posts a fresh board, pins it quietly and records it on the user
+func sendBoard(b *tele.Bot, chatID int64, body string) error {
+	msg, err := deliver(b, chatID, getThreadID(chatID), boardText(body, clock()), &tele.SendOptions{ParseMode: tele.ModeMarkdown})
+	if err != nil {
+		return err
+	}
+	if err := b.Pin(msg, tele.Silent); err != nil {
+		// Groups may not let the bot pin; the board still works unpinned
+		log.Printf("[BOARD] Could not pin board in %d: %v", chatID, err)
+	}
+	_, err = userCollection.UpdateOne(context.TODO(), bson.M{"chat_id": chatID}, bson.M{"$set": bson.M{
+		"board_message_id": msg.ID, "board_body": body, "board_checked_at": clock(),
+	}})
+	return err
+}
+
+// refreshBoards edits at most maxEdits boards in place, oldest-checked first, so boards
+// beyond the cap are picked up by the next run
+func refreshBoards(b *tele.Bot, maxEdits int) BoardRunStats {
+	var stats BoardRunStats
+	if userCollection == nil {
+		return stats
+	}
+	cursor, err := userCollection.Find(context.TODO(), bson.M{"board_message_id": bson.M{"$gt": 0}},
+		options.Find().SetSort(bson.D{{Key: "board_checked_at", Value: 1}}).SetLimit(int64(maxEdits)))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load boards: %v", err)
+		return stats
+	}
+	var users []boardUser
+	if err := cursor.All(context.TODO(), &users); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to decode boards: %v", err)
+		return stats
+	}
+
+	var symbols []string
+	for i, u := range users {
+		users[i].Watchlist = capWatchlist(u.Watchlist, effectiveTier(u.Tier, u.PremiumUntil, clock()))
+		symbols = append(symbols, users[i].Watchlist...)
+	}
+	symbols, quotes := withoutQuarantined(symbols, false)
+	for symbol, d := range fetchQuotes(symbols) {
+		quotes[symbol] = d
+	}
+
+	for _, u := range users {
+		stats.Checked++
+		userCollection.UpdateOne(context.TODO(), bson.M{"chat_id": u.ChatID}, bson.M{"$set": bson.M{"board_checked_at": clock()}})
+		body := boardBody(u.Watchlist, quotes)
+		// Telegram rejects edits that change nothing, so identical prices are skipped
+		if body == u.BoardBody {
+			stats.Unchanged++
+			continue
+		}
+		msg := &tele.StoredMessage{MessageID: fmt.Sprint(u.BoardMessageID), ChatID: u.ChatID}
+		_, err := b.Edit(msg, boardText(body, clock()), &tele.SendOptions{ParseMode: tele.ModeMarkdown})
+		switch {
+		case err == nil:
+			userCollection.UpdateOne(context.TODO(), bson.M{"chat_id": u.ChatID}, bson.M{"$set": bson.M{"board_body": body}})
+			stats.Edited++
+		case errors.Is(err, tele.ErrMessageNotModified) || errors.Is(err, tele.ErrSameMessageContent):
+			stats.Unchanged++
+		case strings.Contains(err.Error(), "message to edit not found"):
+			// The user deleted the board; put a new one up
+			if err := sendBoard(b, u.ChatID, body); err != nil {
+				log.Printf("[BOARD ERROR] Failed to recreate board in %d: %v", u.ChatID, err)
+				stats.Failed++
+				continue
+			}
+			stats.Recreated++
+		default:
+			log.Printf("[BOARD ERROR] Failed to edit board in %d: %v", u.ChatID, err)
+			stats.Failed++
+		}
+	}
+	log.Printf("[BOARD] Checked %d boards: %d edited, %d unchanged, %d recreated, %d failed",
+		stats.Checked, stats.Edited, stats.Unchanged, stats.Recreated, stats.Failed)
+	return stats
+}
+
+// boardReply handles "/board on|off"
+func boardReply(b *tele.Bot, chatID int64, arg string) string {
+	switch strings.ToLower(strings.TrimSpace(arg)) {
+	case "on":
+		list := getWatchlist(chatID)
+		if len(list) == 0 {
+			return "ℹ️ Hãy thêm mã vào danh sách theo dõi trước, ví dụ `/watch add btc`."
+		}
+		if err := sendBoard(b, chatID, boardBody(list, fetchQuotes(list))); err != nil {
+			log.Printf("[BOARD ERROR] Failed to create board in %d: %v", chatID, err)
+			return "⚠️ Không thể tạo bảng giá lúc này."
+		}
+		return "📌 Đã ghim bảng giá. Bảng sẽ tự cập nhật, không gửi thêm tin nhắn."
+	case "off":
+		if userCollection == nil {
+			return "⚠️ Không thể lưu cài đặt lúc này."
+		}
+		var u boardUser
+		if err := userCollection.FindOne(context.TODO(), bson.M{"chat_id": chatID}).Decode(&u); err == nil && u.BoardMessageID > 0 {
+			b.Unpin(&tele.Chat{ID: chatID}, u.BoardMessageID)
+		}
+		userCollection.UpdateOne(context.TODO(), bson.M{"chat_id": chatID},
+			bson.M{"$unset": bson.M{"board_message_id": "", "board_body": "", "board_checked_at": ""}})
+		return "📌 Đã tắt bảng giá tự cập nhật."
+	default:
+		return "ℹ️ Cú pháp: /board on hoặc /board off"
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// assetHashtags are the channel footer tags for the report's assets; other symbols get
+// "#" plus their ID without the slash
+var assetHashtags = map[string]string{
+	"gold":   "#vàng",
+	"silver": "#bạc",
+	"btc":    "#bitcoin",
+	"eth":    "#ethereum",
+	"sol":    "#solana",
+	"usdvnd": "#tỷgiá",
+}
+
+// --- CHANNEL FORMAT ---
+
+// setChatType records the Telegram chat type (private, group, supergroup, channel) the
+// broadcast planner picks a format by; subscribers from before it was stored are filled
+// in the next time they use a command
+func setChatType(chatID int64, chatType tele.ChatType) {
+	if userCollection == nil || chatType == "" {
+		return
+	}
+	_, err := userCollection.UpdateOne(context.TODO(), bson.M{"chat_id": chatID, "chat_type": bson.M{"$ne": string(chatType)}},
+		bson.M{"$set": bson.M{"chat_type": string(chatType)}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to record chat type for %d: %v", chatID, err)
+	}
+}
+
+// loadChannels returns the subscribed chats that are channels
+func loadChannels() map[int64]bool {
+	channels := make(map[int64]bool)
+	if userCollection == nil {
+		return channels
+	}
+	cursor, err := userCollection.Find(context.TODO(), bson.M{"chat_type": string(tele.ChatChannel)},
+		options.Find().SetProjection(bson.M{"chat_id": 1}))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load channels: %v", err)
+		return channels
+	}
+	defer cursor.Close(context.TODO())
+	for cursor.Next(context.TODO()) {
+		var result struct {
+			ChatID int64 `bson:"chat_id"`
+		}
+		if cursor.Decode(&result) == nil {
+			channels[result.ChatID] = true
+		}
+	}
+	return channels
+}
+
+// channelHashtags builds the search footer for the report's symbols
+func channelHashtags(symbols []string) string {
+	tags := make([]string, 0, len(symbols))
+	for _, s := range symbols {
+		tag, ok := assetHashtags[s]
+		if !ok {
+			tag = "#" + strings.ReplaceAll(s, "/", "")
+		}
+		tags = append(tags, tag)
+	}
+	return strings.Join(tags, " ")
+}
+
+// channelText turns the report into the channel rendering: the same news and quotes, with
+// the button hint replaced by the bot's subscribe link and a hashtag footer. Channel posts
+// carry no buttons, since a press would edit the one message every reader shares.
+func channelText(report MarketReport, symbols []string, botUsername string) string {
+	text := report.Text
+	if i := strings.LastIndex(text, reportFooter); i >= 0 {
+		text = text[:i]
+	}
+	text += "━━━━━━━━━━━━━━━━━━\n"
+	if botUsername != "" {
+		text += fmt.Sprintf("🤖 **Nhận bản tin riêng miễn phí:** https://t.me/%s?start=channel\n", botUsername)
+	}
+	return text + channelHashtags(symbols)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChannelHashtags(t *testing.T) {
+	if got := channelHashtags([]string{"gold", "btc", "usdvnd", "eur/usd", "AAPL"}); got != "#vàng #bitcoin #tỷgiá #eurusd #AAPL" {
+		t.Errorf("channelHashtags = %q", got)
+	}
+}
+
+func TestChannelText(t *testing.T) {
+	report := MarketReport{Text: "📊 Bản tin\n• Vàng: $2,400\n" + reportFooter + " Bấm nút bên dưới để cập nhật."}
+	got := channelText(report, []string{"gold", "btc"}, "MarketBot")
+	want := "📊 Bản tin\n• Vàng: $2,400\n━━━━━━━━━━━━━━━━━━\n" +
+		"🤖 **Nhận bản tin riêng miễn phí:** https://t.me/MarketBot?start=channel\n#vàng #bitcoin"
+	if got != want {
+		t.Errorf("channelText =\n%q\nwant\n%q", got, want)
+	}
+	// Without a known bot name the subscribe line is left out
+	if got := channelText(report, []string{"gold"}, ""); strings.Contains(got, "t.me") || !strings.HasSuffix(got, "━━━━━━━━━━━━━━━━━━\n#vàng") {
+		t.Errorf("without a username = %q", got)
+	}
+}
+
+func TestPlanBroadcastChannels(t *testing.T) {
+	at := time.Date(2026, 3, 2, 8, 0, 0, 0, vnLocation)
+	report := MarketReport{At: at, Text: "📊 Bản tin\n" + reportFooter + " nút", Plain: "Bản tin"}
+	users := []broadcastUser{
+		{ID: -1001, Channel: true},
+		// A channel's digest and portfolio line would be one reader's data in a shared post
+		{ID: -1002, Channel: true, Portfolio: "💼 Danh mục: +1%", LastDelivered: at.AddDate(0, 0, -3)},
+		{ID: 7},
+	}
+	snapshots := []Snapshot{{At: at.AddDate(0, 0, -1), Prices: map[string]float64{"gold": 2400}}}
+	plan := planBroadcast(report, users, map[string]MarketData{}, snapshots, []string{"gold"}, "MarketBot")
+
+	channel := plan.textFor(-1001)
+	if _, ok := plan.Personal[-1002]; ok || plan.textFor(-1002) != channel {
+		t.Errorf("channels don't share one text: personal %v", plan.Personal)
+	}
+	if !strings.Contains(channel, "?start=channel") || strings.Contains(channel, "💡") {
+		t.Errorf("channel text = %q", channel)
+	}
+	if strings.Contains(plan.textFor(7), "?start=channel") {
+		t.Error("a private chat got the channel rendering")
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// defaultDeadChatAttempts is how many sends in a row may fail with "chat not found" before
+// the chat is marked dead; DEAD_CHAT_ATTEMPTS overrides it
+const defaultDeadChatAttempts = 3
+
+// --- CHAT MIGRATION ---
+
+// migrateChatID moves a group that became a supergroup onto its new ID. The user document
+// keeps every setting (its chat_id is rewritten in one update), and the chat's alerts and
+// portfolio history follow. If the new ID is already subscribed (someone ran /start in the
+// supergroup first), that document wins and the old one is dropped.
+func migrateChatID(from, to int64) bool {
+	if userCollection == nil || from == 0 || to == 0 || from == to {
+		return false
+	}
+	ctx := context.TODO()
+	res, err := userCollection.UpdateOne(ctx, bson.M{"chat_id": from}, bson.M{
+		"$set":   bson.M{"chat_id": to, "chat_type": string(tele.ChatSuperGroup), "migrated_from": from, "updated_at": clock()},
+		"$unset": bson.M{"not_found_count": "", "dead": ""},
+	})
+	switch {
+	case mongo.IsDuplicateKeyError(err):
+		log.Printf("[MIGRATION] Chat %d is already subscribed; dropping the old document of %d", to, from)
+		removeUser(from)
+	case err != nil:
+		log.Printf("[DATABASE ERROR] Failed to migrate chat %d to %d: %v", from, to, err)
+		return false
+	case res.MatchedCount == 0:
+		// Already migrated (Telegram sends the service message to both chats) or never subscribed
+		return false
+	}
+	for _, c := range []*mongo.Collection{alertCollection, portfolioHistoryCollection} {
+		if c == nil {
+			continue
+		}
+		if _, err := c.UpdateMany(ctx, bson.M{"chat_id": from}, bson.M{"$set": bson.M{"chat_id": to}}); err != nil {
+			log.Printf("[DATABASE ERROR] Failed to migrate %s of chat %d: %v", c.Name(), from, err)
+		}
+	}
+	log.Printf("[MIGRATION] Chat %d migrated to %d", from, to)
+	return true
+}
+
+// handleChatMigration applies the migrate_to/migrate_from service messages; true when m
+// was one
+func handleChatMigration(m *tele.Message) bool {
+	switch {
+	case m.MigrateTo != 0:
+		migrateChatID(m.Chat.ID, m.MigrateTo)
+	case m.MigrateFrom != 0:
+		migrateChatID(m.MigrateFrom, m.Chat.ID)
+	default:
+		return false
+	}
+	return true
+}
+
+// migratedTo returns the new ID from a "group chat was upgraded to a supergroup" error
+func migratedTo(err error) (int64, bool) {
+	var group tele.GroupError
+	if errors.As(err, &group) && group.MigratedTo != 0 {
+		return group.MigratedTo, true
+	}
+	var groupPtr *tele.GroupError
+	if errors.As(err, &groupPtr) && groupPtr != nil && groupPtr.MigratedTo != 0 {
+		return groupPtr.MigratedTo, true
+	}
+	return 0, false
+}
+
+// --- DEAD CHATS ---
+
+// recordChatNotFound counts a "chat not found" send and marks the chat dead once that
+// happened defaultDeadChatAttempts times with no delivery in between (markDelivered
+// clears the count). Dead chats are left out of the broadcast audience.
+func recordChatNotFound(chatID int64) {
+	if userCollection == nil {
+		return
+	}
+	var after struct {
+		NotFound int  `bson:"not_found_count"`
+		Dead     bool `bson:"dead"`
+	}
+	err := userCollection.FindOneAndUpdate(context.TODO(), bson.M{"chat_id": chatID},
+		bson.M{"$inc": bson.M{"not_found_count": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&after)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Printf("[DATABASE ERROR] Failed to count missing chat %d: %v", chatID, err)
+		}
+		return
+	}
+	if after.Dead || after.NotFound < envInt("DEAD_CHAT_ATTEMPTS", defaultDeadChatAttempts) {
+		return
+	}
+	if err := store.MarkDead(context.TODO(), chatID, clock()); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to mark chat %d dead: %v", chatID, err)
+		return
+	}
+	log.Printf("[BROADCAST] Chat %d not found %d times in a row, marked dead", chatID, after.NotFound)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// migratingBotAPI answers sendMessage to a group that was upgraded with Telegram's
+// migrate_to_chat_id error, and every other send with success
+type migratingBotAPI struct {
+	mu       sync.Mutex
+	from, to int64
+	sentTo   []string
+}
+
+func (f *migratingBotAPI) RoundTrip(req *http.Request) (*http.Response, error) {
+	var params struct {
+		ChatID string `json:"chat_id"`
+	}
+	raw, _ := io.ReadAll(req.Body)
+	json.Unmarshal(raw, &params)
+	f.mu.Lock()
+	f.sentTo = append(f.sentTo, params.ChatID)
+	f.mu.Unlock()
+	body := fmt.Sprintf(`{"ok":true,"result":{"message_id":3,"date":0,"chat":{"id":%s}}}`, params.ChatID)
+	if params.ChatID == fmt.Sprint(f.from) {
+		body = fmt.Sprintf(`{"ok":false,"error_code":400,"description":"Bad Request: group chat was upgraded to a supergroup chat","parameters":{"migrate_to_chat_id":%d}}`, f.to)
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}, nil
+}
+
+func TestMigratedTo(t *testing.T) {
+	group := tele.GroupError{MigratedTo: -1001234567890}
+	tests := []struct {
+		name string
+		err  error
+		to   int64
+		ok   bool
+	}{
+		{"value", group, -1001234567890, true},
+		{"pointer", &group, -1001234567890, true},
+		{"wrapped", fmt.Errorf("send: %w", group), -1001234567890, true},
+		{"no new ID", tele.GroupError{}, 0, false},
+		{"other error", tele.ErrChatNotFound, 0, false},
+		{"nil", nil, 0, false},
+	}
+	for _, tt := range tests {
+		if to, ok := migratedTo(tt.err); to != tt.to || ok != tt.ok {
+			t.Errorf("%s: migratedTo = %d, %v; want %d, %v", tt.name, to, ok, tt.to, tt.ok)
+		}
+	}
+}
+
+func TestHandleChatMigration(t *testing.T) {
+	withDatabase(t, false, nil)
+	tests := []struct {
+		name string
+		m    *tele.Message
+		want bool
+	}{
+		{"migrate_to", &tele.Message{Chat: &tele.Chat{ID: -5}, MigrateTo: -1005}, true},
+		{"migrate_from", &tele.Message{Chat: &tele.Chat{ID: -1005}, MigrateFrom: -5}, true},
+		{"ordinary message", &tele.Message{Chat: &tele.Chat{ID: -5}, Text: "/report"}, false},
+	}
+	for _, tt := range tests {
+		if got := handleChatMigration(tt.m); got != tt.want {
+			t.Errorf("%s: handleChatMigration = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if migrateChatID(-5, -5) || migrateChatID(0, -1005) {
+		t.Error("migrateChatID accepted a no-op migration")
+	}
+}
+
+func TestDeliverFollowsMigration(t *testing.T) {
+	withDatabase(t, false, nil)
+	api := &migratingBotAPI{from: -5, to: -1005}
+	b, err := tele.NewBot(tele.Settings{Token: "test", Offline: true, Client: &http.Client{Transport: api}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := deliver(b, -5, 0, "📊 report", nil)
+	if err != nil || msg == nil || msg.Chat.ID != -1005 {
+		t.Fatalf("deliver = %+v, %v; want the message resent to the supergroup", msg, err)
+	}
+	if got := strings.Join(api.sentTo, ","); got != "-5,-1005" {
+		t.Errorf("sent to %s, want the old group then the supergroup", got)
+	}
+
+	api.sentTo = nil
+	if _, err := deliver(b, -1005, 0, "📊 report", nil); err != nil || len(api.sentTo) != 1 {
+		t.Errorf("a send that needs no migration went to %v (err %v)", api.sentTo, err)
+	}
+	if _, err := deliver(b, 7, 0, "x", nil); errors.Is(err, tele.ErrGroupMigrated) {
+		t.Error("an ordinary chat reported a migration")
+	}
+}
+
+func TestIsValidChatID(t *testing.T) {
+	tests := []struct {
+		id   int64
+		want bool
+	}{
+		{42, true},
+		{-5, true},
+		{-1001234567890, true},
+		{0, false},
+		{1 << 52, false},
+		{-(1 << 52), false},
+		{-(1 << 62), false},
+	}
+	for _, tt := range tests {
+		if got := isValidChatID(tt.id); got != tt.want {
+			t.Errorf("isValidChatID(%d) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
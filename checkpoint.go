@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// defaultResumeStaleness is how old a checkpointed report may be and still be resent as
+// is; BROADCAST_RESUME_STALENESS overrides it. Older runs resume with a fresh report.
+const defaultResumeStaleness = 2 * time.Hour
+
+// resumeClaimTTL keeps a second /resume from racing a resume still in flight
+const resumeClaimTTL = 15 * time.Minute
+
+// errCheckpointQuote marks quotes that were unavailable when the checkpointed report was built
+var errCheckpointQuote = errors.New("quote unavailable when the report was built")
+
+// broadcastCheckpoint is the latest broadcast run: who it was for, who already has it and
+// the report it sent, so a run that died midway can be finished with /resume
+type broadcastCheckpoint struct {
+	StartedAt   time.Time    `bson:"started_at"`
+	ContentHash string       `bson:"content_hash"`
+	Report      storedReport `bson:"report"`
+	Recipients  []int64      `bson:"recipients"`
+	Delivered   []int64      `bson:"delivered"`
+	SlotSilent  *bool        `bson:"slot_silent,omitempty"`
+	Finished    bool         `bson:"finished"`
+}
+
+// storedReport is the part of a MarketReport a resume needs to resend it. Experiment
+// variants aren't kept, so resumed chats get the control rendering.
+type storedReport struct {
+	At        time.Time              `bson:"at"`
+	Text      string                 `bson:"text"`
+	CardsText string                 `bson:"cards_text"`
+	Plain     string                 `bson:"plain"`
+	Menu      string                 `bson:"menu,omitempty"`
+	Headlines []Headline             `bson:"headlines,omitempty"`
+	Quotes    map[string]storedQuote `bson:"quotes"`
+	UsdVnd    float64                `bson:"usd_vnd"`
+}
+
+// storedQuote is a MarketData without its error; OK is false for quotes that failed
+type storedQuote struct {
+	OK         bool    `bson:"ok"`
+	Price      float64 `bson:"price"`
+	Percent    float64 `bson:"percent"`
+	HasPercent bool    `bson:"has_percent"`
+	Source     string  `bson:"source,omitempty"`
+}
+
+// --- BROADCAST CHECKPOINT ---
+
+// checkpointDocID is the settings document holding the latest run's checkpoint
+func checkpointDocID() string {
+	return activeProfile().collectionName("broadcast_checkpoint")
+}
+
+// reportContentHash fingerprints a report's renderings. It is computed from the stored
+// renderings themselves, so it catches a damaged checkpoint document, not a newer report.
+func reportContentHash(r storedReport) string {
+	sum := sha256.Sum256([]byte(r.Text + "\x00" + r.CardsText + "\x00" + r.Plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// storeReport keeps what storedReport needs from report
+func storeReport(report MarketReport) storedReport {
+	s := storedReport{At: report.At, Text: report.Text, CardsText: report.CardsText, Plain: report.Plain,
+		Headlines: report.Headlines, UsdVnd: report.UsdVnd, Quotes: make(map[string]storedQuote, len(report.Quotes))}
+	if report.Menu != nil {
+		if raw, err := json.Marshal(report.Menu); err == nil {
+			s.Menu = string(raw)
+		}
+	}
+	for symbol, d := range report.Quotes {
+		s.Quotes[symbol] = storedQuote{OK: d.Err == nil, Price: d.Price, Percent: d.Percent, HasPercent: d.HasPercent, Source: d.Source}
+	}
+	return s
+}
+
+// restore rebuilds the MarketReport a checkpoint sent
+func (s storedReport) restore() MarketReport {
+	report := MarketReport{At: s.At, Text: s.Text, CardsText: s.CardsText, Plain: s.Plain, Headlines: s.Headlines,
+		UsdVnd: s.UsdVnd, Quotes: make(map[string]MarketData, len(s.Quotes))}
+	if s.Menu != "" {
+		menu := &tele.ReplyMarkup{}
+		if json.Unmarshal([]byte(s.Menu), menu) == nil {
+			report.Menu = menu
+		}
+	}
+	for symbol, q := range s.Quotes {
+		d := MarketData{Price: q.Price, Change: "N/A", Source: q.Source}
+		switch {
+		case !q.OK:
+			d.Err = errCheckpointQuote
+		case q.HasPercent:
+			d.Percent, d.HasPercent, d.Change = q.Percent, true, formatPercent(q.Percent)
+		}
+		report.Quotes[symbol] = d
+	}
+	return report
+}
+
+// startCheckpoint replaces the checkpoint with a new run of report to ids
+func startCheckpoint(report MarketReport, ids []int64, slotSilent *bool) {
+	if settingsCollection == nil {
+		return
+	}
+	stored := storeReport(report)
+	cp := broadcastCheckpoint{StartedAt: clock(), ContentHash: reportContentHash(stored), Report: stored,
+		Recipients: ids, Delivered: []int64{}, SlotSilent: slotSilent}
+	_, err := settingsCollection.ReplaceOne(context.TODO(), bson.M{"_id": checkpointDocID()}, cp,
+		options.Replace().SetUpsert(true))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to save broadcast checkpoint: %v", err)
+	}
+}
+
+// checkpointDelivered records chats that now have the report sent at at, stamping their
+// last delivery (see markDelivered) with the chunk rather than at the end of the run, so a
+// run that dies midway doesn't leave them looking like they missed it. Their queued writes
+// go first, so a chat is never checkpointed ahead of its own records.
+func checkpointDelivered(ids []int64, at time.Time) {
+	if len(ids) == 0 {
+		return
+	}
+	markDelivered(ids, at)
+	flushWrites()
+	if settingsCollection == nil {
+		return
+	}
+	_, err := settingsCollection.UpdateOne(context.TODO(), bson.M{"_id": checkpointDocID()},
+		bson.M{"$addToSet": bson.M{"delivered": bson.M{"$each": ids}}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to update broadcast checkpoint: %v", err)
+	}
+}
+
+// finishCheckpoint marks the run complete; the checkpoint stays until the next run
+func finishCheckpoint() {
+	flushWrites()
+	if settingsCollection == nil {
+		return
+	}
+	_, err := settingsCollection.UpdateOne(context.TODO(), bson.M{"_id": checkpointDocID()},
+		bson.M{"$set": bson.M{"finished": true}, "$unset": bson.M{"resume_claimed_at": ""}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to finish broadcast checkpoint: %v", err)
+	}
+}
+
+// loadCheckpoint returns the latest run's checkpoint
+func loadCheckpoint() (broadcastCheckpoint, bool) {
+	var cp broadcastCheckpoint
+	if settingsCollection == nil {
+		return cp, false
+	}
+	err := settingsCollection.FindOne(context.TODO(), bson.M{"_id": checkpointDocID()}).Decode(&cp)
+	return cp, err == nil
+}
+
+// remaining lists the run's recipients that haven't got the report
+func (cp broadcastCheckpoint) remaining() []int64 {
+	done := make(map[int64]bool, len(cp.Delivered))
+	for _, id := range cp.Delivered {
+		done[id] = true
+	}
+	var left []int64
+	for _, id := range cp.Recipients {
+		if !done[id] {
+			left = append(left, id)
+		}
+	}
+	return left
+}
+
+// incompleteCheckpoint returns the latest run when it stopped before reaching everyone
+func incompleteCheckpoint() (broadcastCheckpoint, bool) {
+	cp, ok := loadCheckpoint()
+	if !ok || cp.Finished || len(cp.remaining()) == 0 {
+		return cp, false
+	}
+	return cp, true
+}
+
+// claimResume marks the checkpoint as being resumed; false when another resume holds it
+func claimResume() bool {
+	now := clock()
+	res, err := settingsCollection.UpdateOne(context.TODO(), bson.M{
+		"_id": checkpointDocID(), "finished": false,
+		"$or": bson.A{bson.M{"resume_claimed_at": bson.M{"$exists": false}}, bson.M{"resume_claimed_at": bson.M{"$lt": now.Add(-resumeClaimTTL)}}},
+	}, bson.M{"$set": bson.M{"resume_claimed_at": now}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to claim broadcast resume: %v", err)
+		return false
+	}
+	return res.ModifiedCount > 0
+}
+
+// resendable reports whether the checkpointed report may go out again as is: it is within
+// the staleness window and its document is intact (content hash)
+func (cp broadcastCheckpoint) resendable(now time.Time, staleness time.Duration) bool {
+	return reportContentHash(cp.Report) == cp.ContentHash && now.Sub(cp.Report.At) <= staleness
+}
+
+// resumeBroadcast finishes an interrupted run for the recipients it didn't reach who are
+// still subscribed. The checkpointed report is resent while it is within the staleness
+// window; an older (or damaged) one is replaced by a fresh report for the same remainder,
+// so no chat ever gets yesterday's prices.
+func resumeBroadcast(b *tele.Bot) string {
+	cp, ok := incompleteCheckpoint()
+	if !ok {
+		return "ℹ️ Không có lần gửi bản tin nào đang dang dở."
+	}
+	if !claimResume() {
+		return "⏳ Một lần gửi tiếp khác đang chạy."
+	}
+	subscribed := loadUsers()
+	users := make(map[int64]bool)
+	for _, id := range cp.remaining() {
+		if subscribed[id] {
+			users[id] = true
+		}
+	}
+	staleness := envDuration("BROADCAST_RESUME_STALENESS", defaultResumeStaleness)
+	var report MarketReport
+	fresh := !cp.resendable(clock(), staleness)
+	if fresh {
+		log.Printf("[BROADCAST] Resuming with a fresh report; checkpointed one from %s is stale or damaged", cp.Report.At.Format(time.RFC3339))
+		report = buildMarketReport()
+	} else {
+		report = cp.Report.restore()
+	}
+	log.Printf("[BROADCAST] Resuming run from %s for %d of %d recipients", cp.StartedAt.Format(time.RFC3339), len(users), len(cp.Recipients))
+	if len(users) > 0 {
+		broadcastReport(b, users, report, cp.SlotSilent, true)
+	} else {
+		finishCheckpoint()
+	}
+	saveSnapshot(report)
+	recordBroadcastRun()
+	note := "bản tin đã lưu"
+	if fresh {
+		note = "bản tin mới (bản cũ quá hạn)"
+	}
+	return fmt.Sprintf("✅ Đã gửi tiếp %s cho %d người nhận còn lại (trong tổng số %d).", note, len(users), len(cp.Recipients))
+}
+
+// resumeHint is the watchdog alarm's suggestion when the latest run stopped midway
+func resumeHint() string {
+	cp, ok := incompleteCheckpoint()
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("\nLần gửi lúc %s dừng ở %d/%d người nhận, gõ /resume để gửi tiếp.",
+		cp.StartedAt.In(vnLocation).Format("15:04 02/01"), len(cp.Recipients)-len(cp.remaining()), len(cp.Recipients))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCheckpointRemaining(t *testing.T) {
+	cp := broadcastCheckpoint{Recipients: []int64{1, 2, 3, 4}, Delivered: []int64{3, 1}}
+	if got := cp.remaining(); !reflect.DeepEqual(got, []int64{2, 4}) {
+		t.Errorf("remaining = %v, want [2 4]", got)
+	}
+}
+
+func TestCheckpointResendable(t *testing.T) {
+	builtAt := time.Date(2026, 3, 2, 8, 0, 0, 0, vnLocation)
+	report := storeReport(MarketReport{At: builtAt, Text: "report", CardsText: "cards", Plain: "plain"})
+	cp := broadcastCheckpoint{Report: report, ContentHash: reportContentHash(report)}
+	staleness := 2 * time.Hour
+
+	if !cp.resendable(builtAt.Add(30*time.Minute), staleness) {
+		t.Error("a fresh checkpoint should be resent as is")
+	}
+	if !cp.resendable(builtAt.Add(staleness), staleness) {
+		t.Error("a checkpoint exactly at the staleness window should still be resent")
+	}
+	if cp.resendable(builtAt.Add(staleness+time.Minute), staleness) {
+		t.Error("a stale checkpoint must be replaced by a fresh report")
+	}
+	damaged := cp
+	damaged.Report.Text = "truncated"
+	if damaged.resendable(builtAt.Add(time.Minute), staleness) {
+		t.Error("a damaged checkpoint must be replaced by a fresh report")
+	}
+}
+
+func TestStoredReportRestore(t *testing.T) {
+	report := MarketReport{
+		At: time.Date(2026, 3, 2, 8, 0, 0, 0, vnLocation), Text: "t", UsdVnd: 25400,
+		Quotes: map[string]MarketData{
+			"btc":  {Price: 65000, Percent: 1.5, HasPercent: true, Change: formatPercent(1.5)},
+			"gold": {Err: errors.New("timeout")},
+		},
+	}
+	restored := storeReport(report).restore()
+	if d := restored.Quotes["btc"]; d.Price != 65000 || !d.HasPercent || d.Change != formatPercent(1.5) {
+		t.Errorf("btc restored as %+v", d)
+	}
+	if d := restored.Quotes["gold"]; !errors.Is(d.Err, errCheckpointQuote) {
+		t.Errorf("failed quote restored with err %v, want errCheckpointQuote", d.Err)
+	}
+	if restored.UsdVnd != report.UsdVnd || !restored.At.Equal(report.At) {
+		t.Errorf("restored report header %v %v", restored.At, restored.UsdVnd)
+	}
+}
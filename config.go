@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Config holds the settings operators can tune live without redeploying.
+// Env vars seed the values; the profile's config document in the settings collection overrides them.
+type Config struct {
+	UsdVndCacheTTL         time.Duration
+	BroadcastJitterWindow  time.Duration
+	BroadcastChunkSize     int
+	NewsCount              int
+	FeedURL                string
+	Symbols                []string
+	AlertRearmBuffer       float64
+	AlertMaxFiresPerDay    int
+	AlertCooldown          time.Duration
+	Experiment             *Experiment
+	PortfolioAlertCooldown time.Duration
+	// QuietStart and QuietEnd bound the VN-time hours when broadcasts are sent silently
+	QuietStart int
+	QuietEnd   int
+	// VolLowBand and VolHighBand classify /vol readings (annualized, 0.5 = 50%)
+	VolLowBand  float64
+	VolHighBand float64
+	// MoverThreshold is the default minimum move (percent) for the biggest-movers section
+	MoverThreshold float64
+	// BroadcastSlots are the expected broadcast times (VN minutes after midnight) the
+	// watchdog checks; empty disables it. BroadcastGrace is how late a run may be.
+	BroadcastSlots []int
+	BroadcastGrace time.Duration
+	// SourceOverrides pins symbols to a quote provider tried before the default chain
+	SourceOverrides map[string]string
+	// Holidays adds to (or, with Off, removes from) the built-in holiday calendar
+	Holidays []Holiday
+}
+
+// configDoc is the stored shape of the config document; zero fields mean "not overridden"
+type configDoc struct {
+	UsdVndCacheTTL         string            `bson:"usdvnd_cache_ttl,omitempty"`
+	BroadcastJitterWindow  string            `bson:"broadcast_jitter_window,omitempty"`
+	BroadcastChunkSize     int               `bson:"broadcast_chunk_size,omitempty"`
+	NewsCount              int               `bson:"news_count,omitempty"`
+	FeedURL                string            `bson:"feed_url,omitempty"`
+	Symbols                []string          `bson:"symbols,omitempty"`
+	AlertRearmBuffer       float64           `bson:"alert_rearm_buffer,omitempty"`
+	AlertMaxFiresPerDay    int               `bson:"alert_max_fires_per_day,omitempty"`
+	AlertCooldown          string            `bson:"alert_cooldown,omitempty"`
+	Experiment             *Experiment       `bson:"experiment,omitempty"`
+	PortfolioAlertCooldown string            `bson:"portfolio_alert_cooldown,omitempty"`
+	QuietHours             string            `bson:"quiet_hours,omitempty"`
+	VolLowBand             float64           `bson:"vol_low_band,omitempty"`
+	VolHighBand            float64           `bson:"vol_high_band,omitempty"`
+	MoverThreshold         float64           `bson:"mover_threshold,omitempty"`
+	BroadcastSchedule      string            `bson:"broadcast_schedule,omitempty"`
+	BroadcastGrace         string            `bson:"broadcast_grace,omitempty"`
+	SourceOverrides        map[string]string `bson:"source_overrides,omitempty"`
+	Holidays               []Holiday         `bson:"holidays,omitempty"`
+}
+
+// Built-in defaults, used when neither env nor the settings document provide a value.
+// Symbols and FeedURL come from the active profile.
+var defaultConfig = Config{
+	UsdVndCacheTTL:         6 * time.Hour,
+	BroadcastJitterWindow:  0,
+	BroadcastChunkSize:     25,
+	NewsCount:              8,
+	AlertRearmBuffer:       0.3,
+	AlertMaxFiresPerDay:    5,
+	AlertCooldown:          30 * time.Minute,
+	PortfolioAlertCooldown: 6 * time.Hour,
+	QuietStart:             22,
+	QuietEnd:               7,
+	VolLowBand:             0.3,
+	VolHighBand:            0.7,
+	MoverThreshold:         2,
+	BroadcastGrace:         30 * time.Minute,
+}
+
+var (
+	settingsCollection *mongo.Collection
+
+	configMu       sync.Mutex
+	cachedConfig   Config
+	configLoadedAt time.Time
+)
+
+// --- CONFIG LOGIC ---
+
+// configFromEnv builds the seed config from env vars over the profile and built-in defaults
+func configFromEnv() Config {
+	cfg := defaultConfig
+	profile := activeProfile()
+	cfg.Symbols = profile.Symbols
+	cfg.FeedURL = profile.FeedURL
+	cfg.UsdVndCacheTTL = envDuration("USDVND_CACHE_TTL", cfg.UsdVndCacheTTL)
+	cfg.BroadcastJitterWindow = envDuration("BROADCAST_JITTER_WINDOW", cfg.BroadcastJitterWindow)
+	cfg.BroadcastChunkSize = envInt("BROADCAST_CHUNK_SIZE", cfg.BroadcastChunkSize)
+	cfg.NewsCount = envInt("NEWS_COUNT", cfg.NewsCount)
+	cfg.AlertRearmBuffer = envFloat("ALERT_REARM_BUFFER", cfg.AlertRearmBuffer)
+	cfg.AlertMaxFiresPerDay = envInt("ALERT_MAX_FIRES_PER_DAY", cfg.AlertMaxFiresPerDay)
+	cfg.AlertCooldown = envDuration("ALERT_COOLDOWN", cfg.AlertCooldown)
+	cfg.PortfolioAlertCooldown = envDuration("PORTFOLIO_ALERT_COOLDOWN", cfg.PortfolioAlertCooldown)
+	cfg.VolLowBand = envFloat("VOL_LOW_BAND", cfg.VolLowBand)
+	cfg.VolHighBand = envFloat("VOL_HIGH_BAND", cfg.VolHighBand)
+	cfg.MoverThreshold = envFloat("MOVER_THRESHOLD", cfg.MoverThreshold)
+	cfg.BroadcastGrace = envDuration("BROADCAST_GRACE", cfg.BroadcastGrace)
+	if slots, ok := parseBroadcastSchedule(os.Getenv("BROADCAST_SCHEDULE")); ok {
+		cfg.BroadcastSlots = slots
+	}
+	if start, end, ok := parseQuietHours(os.Getenv("QUIET_HOURS")); ok {
+		cfg.QuietStart, cfg.QuietEnd = start, end
+	}
+	if v := os.Getenv("NEWS_FEED_URL"); v != "" {
+		cfg.FeedURL = v
+	}
+	if v := os.Getenv("REPORT_SYMBOLS"); v != "" {
+		cfg.Symbols = splitSymbols(v)
+	}
+	return cfg
+}
+
+// applyConfigDoc overlays the stored document on cfg, ignoring invalid values
+func applyConfigDoc(cfg Config, doc configDoc) Config {
+	if d, err := time.ParseDuration(doc.UsdVndCacheTTL); err == nil && d > 0 {
+		cfg.UsdVndCacheTTL = d
+	}
+	if d, err := time.ParseDuration(doc.BroadcastJitterWindow); err == nil && d >= 0 {
+		cfg.BroadcastJitterWindow = d
+	}
+	if doc.BroadcastChunkSize > 0 {
+		cfg.BroadcastChunkSize = doc.BroadcastChunkSize
+	}
+	if doc.NewsCount > 0 {
+		cfg.NewsCount = doc.NewsCount
+	}
+	if doc.FeedURL != "" {
+		cfg.FeedURL = doc.FeedURL
+	}
+	if len(doc.Symbols) > 0 {
+		// Documents written before canonical IDs may still hold provider symbols
+		cfg.Symbols = splitSymbols(strings.Join(doc.Symbols, ","))
+	}
+	if doc.AlertRearmBuffer > 0 {
+		cfg.AlertRearmBuffer = doc.AlertRearmBuffer
+	}
+	if doc.AlertMaxFiresPerDay > 0 {
+		cfg.AlertMaxFiresPerDay = doc.AlertMaxFiresPerDay
+	}
+	if d, err := time.ParseDuration(doc.AlertCooldown); err == nil && d >= 0 {
+		cfg.AlertCooldown = d
+	}
+	if d, err := time.ParseDuration(doc.PortfolioAlertCooldown); err == nil && d >= 0 {
+		cfg.PortfolioAlertCooldown = d
+	}
+	if doc.VolLowBand > 0 {
+		cfg.VolLowBand = doc.VolLowBand
+	}
+	if doc.VolHighBand > 0 {
+		cfg.VolHighBand = doc.VolHighBand
+	}
+	if doc.MoverThreshold > 0 {
+		cfg.MoverThreshold = doc.MoverThreshold
+	}
+	if start, end, ok := parseQuietHours(doc.QuietHours); ok {
+		cfg.QuietStart, cfg.QuietEnd = start, end
+	}
+	if slots, ok := parseBroadcastSchedule(doc.BroadcastSchedule); ok {
+		cfg.BroadcastSlots = slots
+	}
+	if d, err := time.ParseDuration(doc.BroadcastGrace); err == nil && d >= 0 {
+		cfg.BroadcastGrace = d
+	}
+	if doc.Experiment.active() {
+		cfg.Experiment = doc.Experiment
+	}
+	for id, provider := range doc.SourceOverrides {
+		if !validSourceOverride(id, provider) {
+			continue
+		}
+		if cfg.SourceOverrides == nil {
+			cfg.SourceOverrides = make(map[string]string)
+		}
+		cfg.SourceOverrides[id] = provider
+	}
+	for _, h := range doc.Holidays {
+		if validHoliday(h) {
+			cfg.Holidays = append(cfg.Holidays, h)
+		}
+	}
+	return cfg
+}
+
+// loadConfig returns the current config, re-reading the settings document at most
+// once per CONFIG_REFRESH_INTERVAL (default 1 minute)
+func loadConfig() Config {
+	configMu.Lock()
+	defer configMu.Unlock()
+	if !configLoadedAt.IsZero() && clock().Sub(configLoadedAt) < envDuration("CONFIG_REFRESH_INTERVAL", time.Minute) {
+		return cachedConfig
+	}
+	return refreshConfigLocked()
+}
+
+// reloadConfig forces a re-read of the settings document (admin /reload)
+func reloadConfig() Config {
+	configMu.Lock()
+	defer configMu.Unlock()
+	return refreshConfigLocked()
+}
+
+func refreshConfigLocked() Config {
+	cfg := configFromEnv()
+	if settingsCollection != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		var doc configDoc
+		err := settingsCollection.FindOne(ctx, bson.M{"_id": activeProfile().ConfigID}).Decode(&doc)
+		switch {
+		case err == nil:
+			cfg = applyConfigDoc(cfg, doc)
+		case err == mongo.ErrNoDocuments:
+			// Nothing stored yet, env seed stands
+		default:
+			// Keep serving the last good config rather than flapping back to env values
+			log.Printf("[CONFIG ERROR] Failed to load settings: %v", err)
+			if !configLoadedAt.IsZero() {
+				return cachedConfig
+			}
+		}
+	}
+	cachedConfig = cfg
+	configLoadedAt = clock()
+	log.Println("[CONFIG] Configuration loaded")
+	return cfg
+}
+
+// describeConfig renders the active config for the /reload reply
+func describeConfig(cfg Config) string {
+	return fmt.Sprintf("⚙️ Cấu hình hiện tại:\n"+
+		"• USD/VND cache: %s\n"+
+		"• Broadcast jitter: %s (chunk %d)\n"+
+		"• Số tin: %d\n"+
+		"• Feed: %s\n"+
+		"• Mã hiển thị: %s\n"+
+		"• Cảnh báo lặp: vùng đệm %.2f%%, tối đa %d lần/ngày\n"+
+		"• Giờ yên tĩnh: %02d:00–%02d:00\n"+
+		"• Lịch bản tin (watchdog): %s, trễ tối đa %s\n"+
+		"• Nguồn giá ghim: %s",
+		cfg.UsdVndCacheTTL, cfg.BroadcastJitterWindow, cfg.BroadcastChunkSize,
+		cfg.NewsCount, cfg.FeedURL, strings.Join(cfg.Symbols, ", "),
+		cfg.AlertRearmBuffer, cfg.AlertMaxFiresPerDay, cfg.QuietStart, cfg.QuietEnd,
+		formatBroadcastSchedule(cfg.BroadcastSlots), cfg.BroadcastGrace, describeOverrides(cfg.SourceOverrides))
+}
+
+// splitSymbols parses a comma-separated symbol list into canonical IDs, dropping blanks
+func splitSymbols(raw string) []string {
+	var out []string
+	for _, s := range strings.Split(raw, ",") {
+		if s = resolveSymbol(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// describeOverrides renders source overrides as "btc → coingecko, usdvnd → vcb"
+func describeOverrides(overrides map[string]string) string {
+	if len(overrides) == 0 {
+		return "không"
+	}
+	parts := make([]string, 0, len(overrides))
+	for id, provider := range overrides {
+		parts = append(parts, id+" → "+provider)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
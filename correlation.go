@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// minCorrelationOverlap is the fewest shared days a pair needs before its correlation is shown
+const minCorrelationOverlap = 10
+
+// strongCorrelation is the |r| from which a cell is colored
+const strongCorrelation = 0.5
+
+// correlationSymbols are the matrix's rows and columns with their short labels. DXY is only
+// there when it is among the report's symbols, since only report symbols are snapshotted.
+var correlationSymbols = []struct{ ID, Short string }{
+	{"gold", "GOLD"}, {"btc", "BTC"}, {"eurusd", "EUR"}, {"dxy", "DXY"},
+}
+
+// --- CORRELATION MATRIX ---
+
+// loadDailySeries turns the snapshots of the last days into one close per Vietnam day and
+// symbol (the day's latest snapshot wins), oldest first. Backfilled closes count too.
+func loadDailySeries(symbols []string, days int) map[string][]SeriesPoint {
+	series := make(map[string][]SeriesPoint, len(symbols))
+	if snapshotCollection == nil {
+		return series
+	}
+	since := clock().AddDate(0, 0, -days)
+	cursor, err := snapshotCollection.Find(context.TODO(), bson.M{"at": bson.M{"$gte": since}},
+		options.Find().SetSort(bson.D{{Key: "at", Value: 1}}))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load snapshots for correlation: %v", err)
+		return series
+	}
+	var snaps []Snapshot
+	if err := cursor.All(context.TODO(), &snaps); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to decode snapshots for correlation: %v", err)
+		return series
+	}
+	for _, s := range snaps {
+		local := s.At.In(vnLocation)
+		day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, vnLocation)
+		for _, symbol := range symbols {
+			price, ok := s.Prices[symbol]
+			if !ok || price <= 0 {
+				continue
+			}
+			points := series[symbol]
+			if n := len(points); n > 0 && points[n-1].Date.Equal(day) {
+				points[n-1].Close = price
+				continue
+			}
+			series[symbol] = append(points, SeriesPoint{Date: day, Close: price})
+		}
+	}
+	return series
+}
+
+// correlationMatrix correlates the daily returns of every pair of symbols over the dates
+// both have. Pairs sharing fewer than minOverlap days (or with a flat series) are left out:
+// ok[i][j] is false. The diagonal is 1 for any symbol with enough data of its own.
+func correlationMatrix(series map[string][]SeriesPoint, symbols []string, minOverlap int) (r [][]float64, ok [][]bool) {
+	n := len(symbols)
+	r, ok = make([][]float64, n), make([][]bool, n)
+	for i := range symbols {
+		r[i], ok[i] = make([]float64, n), make([]bool, n)
+	}
+	for i := 0; i < n; i++ {
+		if len(series[symbols[i]]) >= minOverlap {
+			r[i][i], ok[i][i] = 1, true
+		}
+		for j := i + 1; j < n; j++ {
+			xs, ys := alignByDate(series[symbols[i]], series[symbols[j]])
+			if len(xs) < minOverlap {
+				continue
+			}
+			v, err := pearson(simpleReturns(xs), simpleReturns(ys))
+			if err != nil {
+				continue
+			}
+			r[i][j], r[j][i] = v, v
+			ok[i][j], ok[j][i] = true, true
+		}
+	}
+	return r, ok
+}
+
+// correlationCell renders one matrix cell: 🟩/🟥 for strong positive/negative, ⬜ otherwise
+func correlationCell(r float64, ok bool) string {
+	if !ok {
+		return "   —   "
+	}
+	mark := "⬜"
+	switch {
+	case r >= strongCorrelation:
+		mark = "🟩"
+	case r <= -strongCorrelation:
+		mark = "🟥"
+	}
+	return fmt.Sprintf("%s%+.2f", mark, r)
+}
+
+// formatCorrelationRows renders the matrix as aligned rows (a header of short labels, then
+// one row per symbol); anyPair is false when no two symbols shared enough days
+func formatCorrelationRows(r [][]float64, ok [][]bool) (rows string, anyPair bool) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%-5s", "")
+	for _, s := range correlationSymbols {
+		fmt.Fprintf(&sb, " %-7s", s.Short)
+	}
+	for i, s := range correlationSymbols {
+		fmt.Fprintf(&sb, "\n%-5s", s.Short)
+		for j := range correlationSymbols {
+			fmt.Fprintf(&sb, " %s", correlationCell(r[i][j], ok[i][j]))
+			anyPair = anyPair || (ok[i][j] && i != j)
+		}
+	}
+	return sb.String(), anyPair
+}
+
+// snapshotCorrelations computes the correlationSymbols matrix over the last days of
+// stored snapshots and renders it
+func snapshotCorrelations(days int) (rows string, anyPair bool) {
+	ids := make([]string, len(correlationSymbols))
+	for i, s := range correlationSymbols {
+		ids[i] = s.ID
+	}
+	return formatCorrelationRows(correlationMatrix(loadDailySeries(ids, days), ids, minCorrelationOverlap))
+}
+
+// correlationReply builds the reply for "/correlation [30d]" from stored snapshots only,
+// so it never spends API credits
+func correlationReply(payload string) string {
+	days, err := parseWindowDays(payload)
+	if err != nil {
+		return fmt.Sprintf("⚠️ Khoảng thời gian không hợp lệ. Dùng từ 2d đến %dd, ví dụ `30d`.", maxAnalysisDays)
+	}
+	rows, anyPair := snapshotCorrelations(days)
+	if !anyPair {
+		return fmt.Sprintf("ℹ️ Chưa đủ dữ liệu: cần ít nhất %d ngày bản tin chung cho mỗi cặp. Quản trị viên có thể nạp lịch sử bằng /backfill.", minCorrelationOverlap)
+	}
+	return fmt.Sprintf("🔗 **Ma trận tương quan %d ngày** _(lợi suất ngày, từ bản tin đã lưu)_\n```\n%s\n```\n"+
+		"🟩 cùng chiều mạnh (≥ %.1f) · 🟥 ngược chiều mạnh · — không đủ %d ngày chung",
+		days, rows, strongCorrelation, minCorrelationOverlap)
+}
+
+// correlationSection is the weekly digest's plain-text copy of the default /correlation
+// matrix
+func correlationSection() string {
+	rows, anyPair := snapshotCorrelations(defaultAnalysisDays)
+	if !anyPair {
+		return fmt.Sprintf("🔗 Tương quan %d ngày: chưa đủ dữ liệu (cần %d ngày bản tin chung).", defaultAnalysisDays, minCorrelationOverlap)
+	}
+	return fmt.Sprintf("🔗 Tương quan %d ngày (lợi suất ngày):\n%s\n🟩 ≥ %.1f · 🟥 ≤ -%.1f · — không đủ %d ngày chung",
+		defaultAnalysisDays, rows, strongCorrelation, strongCorrelation, minCorrelationOverlap)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSeries is one point per day from 2026-03-01 for each close, skipping the days in gaps
+func testSeries(closes []float64, gaps ...int) []SeriesPoint {
+	skip := make(map[int]bool, len(gaps))
+	for _, g := range gaps {
+		skip[g] = true
+	}
+	var points []SeriesPoint
+	for i, c := range closes {
+		if !skip[i] {
+			points = append(points, SeriesPoint{Date: time.Date(2026, 3, 1+i, 0, 0, 0, 0, vnLocation), Close: c})
+		}
+	}
+	return points
+}
+
+func TestCorrelationMatrix(t *testing.T) {
+	base := make([]float64, 20)
+	scaled, inverse, flat := make([]float64, 20), make([]float64, 20), make([]float64, 20)
+	for i := range base {
+		base[i] = 100 + 10*math.Sin(float64(i))
+		scaled[i], inverse[i], flat[i] = 2*base[i], 1/base[i], 50
+	}
+	series := map[string][]SeriesPoint{
+		// Gaps on different days in each series: only shared dates are paired
+		"a":     testSeries(base, 3, 7),
+		"b":     testSeries(scaled, 5, 11, 12),
+		"inv":   testSeries(inverse, 0),
+		"short": testSeries(base[:6]),
+		"flat":  testSeries(flat),
+	}
+	symbols := []string{"a", "b", "inv", "short", "flat", "missing"}
+	r, ok := correlationMatrix(series, symbols, 10)
+
+	if !ok[0][1] || math.Abs(r[0][1]-1) > 1e-9 || r[1][0] != r[0][1] {
+		t.Errorf("a~b = %v (%v), want exactly 1 on the shared dates", r[0][1], ok[0][1])
+	}
+	if !ok[0][2] || r[0][2] > -0.9 {
+		t.Errorf("a~inv = %v (%v), want strongly negative", r[0][2], ok[0][2])
+	}
+	for _, j := range []int{3, 4, 5} {
+		if ok[0][j] || ok[j][0] {
+			t.Errorf("a~%s shown with r %v; too short, flat or missing", symbols[j], r[0][j])
+		}
+	}
+	for i, want := range []bool{true, true, true, false, true, false} {
+		if ok[i][i] != want {
+			t.Errorf("diagonal of %s = %v, want %v", symbols[i], ok[i][i], want)
+		}
+	}
+
+	// Raising the overlap past the shared days of a and b hides the pair
+	if _, ok := correlationMatrix(series, []string{"a", "b"}, 16); ok[0][1] {
+		t.Error("a~b shown with fewer shared days than the minimum")
+	}
+}
+
+func TestCorrelationCell(t *testing.T) {
+	tests := []struct {
+		r    float64
+		ok   bool
+		want string
+	}{
+		{0.82, true, "🟩+0.82"},
+		{0.5, true, "🟩+0.50"},
+		{0.49, true, "⬜+0.49"},
+		{-0.12, true, "⬜-0.12"},
+		{-0.5, true, "🟥-0.50"},
+		{1, true, "🟩+1.00"},
+		{0.9, false, "   —   "},
+	}
+	for _, tt := range tests {
+		if got := correlationCell(tt.r, tt.ok); got != tt.want {
+			t.Errorf("correlationCell(%v, %v) = %q, want %q", tt.r, tt.ok, got, tt.want)
+		}
+	}
+}
+
+func TestFormatCorrelationRows(t *testing.T) {
+	n := len(correlationSymbols)
+	r, ok := make([][]float64, n), make([][]bool, n)
+	for i := range r {
+		r[i], ok[i] = make([]float64, n), make([]bool, n)
+		r[i][i], ok[i][i] = 1, true
+	}
+	if _, anyPair := formatCorrelationRows(r, ok); anyPair {
+		t.Error("a diagonal-only matrix reported a pair")
+	}
+	r[0][1], r[1][0], ok[0][1], ok[1][0] = -0.61, -0.61, true, true
+	rows, anyPair := formatCorrelationRows(r, ok)
+	lines := strings.Split(rows, "\n")
+	if !anyPair || len(lines) != n+1 {
+		t.Fatalf("rows = %q, anyPair %v", rows, anyPair)
+	}
+	if !strings.HasPrefix(lines[1], "GOLD  🟩+1.00 🟥-0.61    —   ") || !strings.HasPrefix(lines[0], "      GOLD    BTC") {
+		t.Errorf("rows =\n%s", rows)
+	}
+}
+
+func TestCorrelationReplyWithoutSnapshots(t *testing.T) {
+	withDatabase(t, false, nil)
+	if got := correlationReply("1d"); !strings.HasPrefix(got, "⚠️ Khoảng thời gian không hợp lệ") {
+		t.Errorf("correlationReply(1d) = %q", got)
+	}
+	if got := correlationReply(""); !strings.Contains(got, "Chưa đủ dữ liệu") {
+		t.Errorf("correlationReply without snapshots = %q", got)
+	}
+	if got := correlationSection(); !strings.Contains(got, "chưa đủ dữ liệu") || strings.Contains(got, "```") {
+		t.Errorf("correlationSection without snapshots = %q", got)
+	}
+}
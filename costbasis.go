@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Cost basis currencies accepted by /buy
+const (
+	currencyUSD = "USD"
+	currencyVND = "VND"
+)
+
+// rateSnapshotMaxGap is how far from a purchase a broadcast snapshot's USD/VND rate may be
+// and still count as the rate of that day; beyond it the current rate is used, flagged
+const rateSnapshotMaxGap = 7 * 24 * time.Hour
+
+// Lot is one purchase: Price is per unit in Currency, At the purchase date
+type Lot struct {
+	Quantity float64   `bson:"qty"`
+	Price    float64   `bson:"price"`
+	Currency string    `bson:"currency"`
+	At       time.Time `bson:"at"`
+}
+
+// CostBasis is a holding's purchase cost in both currencies. Approximate is set when some
+// lot had no USD/VND snapshot near its date and was converted at today's rate.
+type CostBasis struct {
+	Quantity    float64
+	USD         float64
+	VND         float64
+	Currency    string
+	Approximate bool
+}
+
+// --- COST BASIS ---
+
+// parseAmount reads a price typed in a currency's local habit: VND amounts have no
+// decimals, so "7.500.000" and "7,500,000" both mean seven and a half million; USD
+// amounts take "," as a thousands separator
+func parseAmount(raw, currency string) (float64, error) {
+	if currency == currencyVND {
+		raw = strings.NewReplacer(".", "", ",", "").Replace(raw)
+	} else {
+		raw = strings.ReplaceAll(raw, ",", "")
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid amount %q", raw)
+	}
+	return v, nil
+}
+
+// usdVndNear returns the USD/VND rate of the broadcast snapshot closest to at. exact is
+// false when no snapshot lies within rateSnapshotMaxGap (e.g. purchases dated before the
+// first snapshot) and the current rate was used instead.
+func usdVndNear(at time.Time) (rate float64, exact bool, err error) {
+	if snapshotCollection != nil {
+		best, bestGap := 0.0, rateSnapshotMaxGap+1
+		for _, dir := range []int{-1, 1} {
+			filter := bson.M{"usd_vnd": bson.M{"$gt": 0}, "at": bson.M{"$lte": at}}
+			if dir > 0 {
+				filter["at"] = bson.M{"$gt": at}
+			}
+			var s Snapshot
+			err := snapshotCollection.FindOne(context.TODO(), filter,
+				options.FindOne().SetSort(bson.D{{Key: "at", Value: -dir}}).SetProjection(bson.M{"at": 1, "usd_vnd": 1})).Decode(&s)
+			if err != nil {
+				continue
+			}
+			gap := s.At.Sub(at)
+			if gap < 0 {
+				gap = -gap
+			}
+			if gap < bestGap {
+				best, bestGap = s.UsdVnd, gap
+			}
+		}
+		if bestGap <= rateSnapshotMaxGap {
+			return best, true, nil
+		}
+	}
+	rate, err = getCachedUsdVnd(os.Getenv("TWELVE_DATA_API_KEY"))
+	return rate, false, err
+}
+
+// costBasis converts a holding's lots into USD and VND, each at the rate nearest its date
+func costBasis(lots []Lot) (CostBasis, error) {
+	var cb CostBasis
+	for _, l := range lots {
+		rate, exact, err := usdVndNear(l.At)
+		if err != nil || rate <= 0 {
+			return CostBasis{}, fmt.Errorf("no USD/VND rate for %s", l.At.Format("2006-01-02"))
+		}
+		cost := l.Quantity * l.Price
+		if l.Currency == currencyVND {
+			cb.VND += cost
+			cb.USD += cost / rate
+		} else {
+			cb.USD += cost
+			cb.VND += cost * rate
+		}
+		cb.Quantity += l.Quantity
+		cb.Approximate = cb.Approximate || !exact
+		switch cb.Currency {
+		case "":
+			cb.Currency = l.Currency
+		case l.Currency:
+		default:
+			// Mixed currencies are reported in USD
+			cb.Currency = currencyUSD
+		}
+	}
+	return cb, nil
+}
+
+// profitLine renders a holding's P&L at price (USD) against its cost basis: in the cost
+// currency first, then converted to VND at today's rate
+func profitLine(cb CostBasis, price, usdVnd float64, style string) string {
+	valueUSD := cb.Quantity * price
+	pnlUSD := valueUSD - cb.USD
+	pct := 0.0
+	if cb.USD > 0 {
+		pct = pnlUSD / cb.USD * 100
+	}
+	var line string
+	if cb.Currency == currencyVND {
+		line = fmt.Sprintf("Giá vốn %s VNĐ", formatNumber(cb.VND, 0, style))
+	} else {
+		line = fmt.Sprintf("Giá vốn $%s", formatNumber(cb.USD, 2, style))
+	}
+	if usdVnd > 0 && cb.Currency == currencyVND {
+		pnlVND := valueUSD*usdVnd - cb.VND
+		line += fmt.Sprintf(" | Lãi/lỗ: %s%s VNĐ (%+.2f%%)", signOf(pnlVND), formatNumber(math.Abs(pnlVND), 0, style), pct)
+	} else {
+		line += fmt.Sprintf(" | Lãi/lỗ: %s$%s (%+.2f%%)", signOf(pnlUSD), formatNumber(math.Abs(pnlUSD), 2, style), pct)
+		if usdVnd > 0 {
+			pnlVND := valueUSD*usdVnd - cb.VND
+			line += fmt.Sprintf(" ≈ %s%s VNĐ", signOf(pnlVND), formatNumber(math.Abs(pnlVND), 0, style))
+		}
+	}
+	if cb.Approximate {
+		line += " _(quy đổi theo tỷ giá hiện tại)_"
+	}
+	return line
+}
+
+// buyReply handles "/buy SYMBOL QTY PRICE [USD|VND] [YYYY-MM-DD]": it adds QTY to the
+// holding and records the purchase at PRICE per unit (default USD, dated today)
+func buyReply(chatID int64, args []string) string {
+	usage := "ℹ️ Cú pháp: `/buy btc 0.1 65000` hoặc `/buy gold 0.1 7500000 VND 2024-05-20`"
+	if len(args) < 3 || len(args) > 5 {
+		return usage
+	}
+	symbol := resolveSymbol(args[0])
+	qty, err := strconv.ParseFloat(strings.ReplaceAll(args[1], ",", "."), 64)
+	if err != nil || qty <= 0 {
+		return "⚠️ Số lượng không hợp lệ."
+	}
+	currency := currencyUSD
+	at := clock()
+	for _, extra := range args[3:] {
+		switch up := strings.ToUpper(extra); {
+		case up == currencyUSD || up == currencyVND:
+			currency = up
+		default:
+			t, err := time.ParseInLocation("2006-01-02", extra, vnLocation)
+			if err != nil || t.After(clock()) {
+				return usage
+			}
+			at = t
+		}
+	}
+	price, err := parseAmount(args[2], currency)
+	if err != nil {
+		return "⚠️ Giá mua không hợp lệ."
+	}
+	if !isUsdQuoted(symbol) {
+		return "⚠️ Chỉ hỗ trợ mã định giá bằng USD (ví dụ btc, gold, AAPL)."
+	}
+
+	holdings := getHoldings(chatID)
+	lot := Lot{Quantity: qty, Price: price, Currency: currency, At: at}
+	found := false
+	for i := range holdings {
+		if holdings[i].Symbol == symbol {
+			holdings[i].Quantity += qty
+			holdings[i].Lots = append(holdings[i].Lots, lot)
+			found = true
+		}
+	}
+	if !found {
+		if len(holdings) >= maxHoldings {
+			return fmt.Sprintf("⚠️ Danh mục tối đa %d mã.", maxHoldings)
+		}
+		holdings = append(holdings, Holding{Symbol: symbol, Quantity: qty, Lots: []Lot{lot}})
+	}
+	if !saveHoldings(chatID, holdings) {
+		return "ℹ️ Bạn cần đăng ký bằng /start trước."
+	}
+	return renderPortfolio(holdings, getNumberStyle(chatID))
+}
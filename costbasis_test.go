@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		raw, currency string
+		want          float64
+		ok            bool
+	}{
+		{"65000", currencyUSD, 65000, true},
+		{"65,000.50", currencyUSD, 65000.5, true},
+		{"7.500.000", currencyVND, 7500000, true},
+		{"7,500,000", currencyVND, 7500000, true},
+		{"0", currencyUSD, 0, false},
+		{"-5", currencyVND, 0, false},
+		{"abc", currencyUSD, 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseAmount(tt.raw, tt.currency)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("parseAmount(%q, %s) = %v, %v; want %v, ok %v", tt.raw, tt.currency, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCostBasis(t *testing.T) {
+	withDatabase(t, false, nil)
+	usdVndChain(t)
+	// Without snapshots every lot is converted at today's rate and flagged as such
+	setUsdVndCache(25000, clock())
+	at := clock().AddDate(0, -1, 0)
+	vnd := Lot{Quantity: 1, Price: 2500000, Currency: currencyVND, At: at}
+	usd := Lot{Quantity: 0.5, Price: 200, Currency: currencyUSD, At: at}
+
+	cb, err := costBasis([]Lot{vnd, vnd})
+	if err != nil || cb != (CostBasis{Quantity: 2, USD: 200, VND: 5000000, Currency: currencyVND, Approximate: true}) {
+		t.Errorf("VND lots = %+v, %v", cb, err)
+	}
+	cb, err = costBasis([]Lot{vnd, usd})
+	if err != nil || cb != (CostBasis{Quantity: 1.5, USD: 200, VND: 5000000, Currency: currencyUSD, Approximate: true}) {
+		t.Errorf("mixed lots = %+v, %v; want them reported in USD", cb, err)
+	}
+
+	setUsdVndCache(0, time.Time{})
+	if _, err := costBasis([]Lot{usd}); err == nil {
+		t.Error("a cost basis without any USD/VND rate succeeded")
+	}
+}
+
+func TestProfitLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		cb    CostBasis
+		price float64
+		rate  float64
+		want  string
+	}{
+		{"USD loss", CostBasis{Quantity: 1, USD: 100, VND: 2500000, Currency: currencyUSD}, 90, 25000,
+			"Giá vốn $100.00 | Lãi/lỗ: -$10.00 (-10.00%) ≈ -250,000 VNĐ"},
+		{"VND gain", CostBasis{Quantity: 1, USD: 100, VND: 2500000, Currency: currencyVND}, 110, 25000,
+			"Giá vốn 2,500,000 VNĐ | Lãi/lỗ: +250,000 VNĐ (+10.00%)"},
+		{"no rate", CostBasis{Quantity: 2, USD: 100, VND: 2500000, Currency: currencyUSD, Approximate: true}, 60, 0,
+			"Giá vốn $100.00 | Lãi/lỗ: +$20.00 (+20.00%) _(quy đổi theo tỷ giá hiện tại)_"},
+	}
+	for _, tt := range tests {
+		if got := profitLine(tt.cb, tt.price, tt.rate, numberStyleIntl); got != tt.want {
+			t.Errorf("%s: profitLine = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuyReplyArguments(t *testing.T) {
+	withDatabase(t, false, nil)
+	usage := "ℹ️ Cú pháp: `/buy btc 0.1 65000` hoặc `/buy gold 0.1 7500000 VND 2024-05-20`"
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"btc", "0.1"}, usage},
+		{[]string{"btc", "abc", "65000"}, "⚠️ Số lượng không hợp lệ."},
+		{[]string{"btc", "0.1", "free"}, "⚠️ Giá mua không hợp lệ."},
+		{[]string{"btc", "0.1", "65000", "EUR"}, usage},
+		{[]string{"btc", "0.1", "65000", "2099-01-01"}, usage},
+		{[]string{"btc", "0,1", "65000", "usd", "2024-05-20"}, "ℹ️ Bạn cần đăng ký bằng /start trước."},
+	}
+	for _, tt := range tests {
+		if got := buyReply(7, tt.args); got != tt.want {
+			t.Errorf("buyReply(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
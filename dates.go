@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Weekday and month names per language, indexed by time.Weekday and time.Month-1.
+// Languages are the news languages (newsLangVI, newsLangEN).
+var (
+	weekdayNames = map[string][7]string{
+		newsLangVI: {"Chủ Nhật", "Thứ Hai", "Thứ Ba", "Thứ Tư", "Thứ Năm", "Thứ Sáu", "Thứ Bảy"},
+		newsLangEN: {"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+	}
+	// Vietnamese dates stay numeric ("26/05"), so only English needs month names
+	monthNames = map[string][12]string{
+		newsLangEN: {"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+	}
+)
+
+// lunarDateSuffix, when set, returns text appended to a formatted date (e.g. " (mùng 1 Tết)"
+// around the lunar new year); empty for no suffix. Nil keeps dates solar-only.
+var lunarDateSuffix func(t time.Time, lang string) string
+
+// --- DATE FORMATTING ---
+
+// dateLang maps a language onto one with name tables, Vietnamese by default
+func dateLang(lang string) string {
+	if _, ok := weekdayNames[lang]; ok {
+		return lang
+	}
+	return newsLangVI
+}
+
+// formatDay renders a day with its weekday in loc: "Thứ Hai, 26/05" or "Mon, May 26"
+func formatDay(t time.Time, lang string, loc *time.Location) string {
+	return formatDate(t, lang, loc, false)
+}
+
+// formatDayYear is formatDay with the year: "Thứ Hai, 26/05/2025" or "Mon, May 26, 2025"
+func formatDayYear(t time.Time, lang string, loc *time.Location) string {
+	return formatDate(t, lang, loc, true)
+}
+
+// formatDateTime renders a report timestamp: "Thứ Hai, 26/05/2025 08:00"
+func formatDateTime(t time.Time, lang string, loc *time.Location) string {
+	return formatDayYear(t, lang, loc) + " " + t.In(loc).Format("15:04")
+}
+
+// formatDate renders t in loc for lang; the lunar hook sees the local time
+func formatDate(t time.Time, lang string, loc *time.Location, withYear bool) string {
+	lang = dateLang(lang)
+	t = t.In(loc)
+	weekday := weekdayNames[lang][t.Weekday()]
+	var s string
+	if lang == newsLangEN {
+		s = fmt.Sprintf("%s, %s %d", weekday, monthNames[lang][t.Month()-1], t.Day())
+		if withYear {
+			s += fmt.Sprintf(", %d", t.Year())
+		}
+	} else {
+		s = fmt.Sprintf("%s, %02d/%02d", weekday, t.Day(), int(t.Month()))
+		if withYear {
+			s += fmt.Sprintf("/%d", t.Year())
+		}
+	}
+	if lunarDateSuffix != nil {
+		s += lunarDateSuffix(t, lang)
+	}
+	return s
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatDayWeekdays(t *testing.T) {
+	vi := []string{"Thứ Hai", "Thứ Ba", "Thứ Tư", "Thứ Năm", "Thứ Sáu", "Thứ Bảy", "Chủ Nhật"}
+	en := []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+	// 2025-05-26 is a Monday
+	for i := 0; i < 7; i++ {
+		day := time.Date(2025, 5, 26+i, 12, 0, 0, 0, vnLocation)
+		if got, want := formatDay(day, newsLangVI, vnLocation), vi[i]+", "+day.Format("02/01"); got != want {
+			t.Errorf("formatDay(%s, vi) = %q, want %q", day.Format("2006-01-02"), got, want)
+		}
+		if got, want := formatDay(day, newsLangEN, vnLocation), en[i]+", "+day.Format("Jan 2"); got != want {
+			t.Errorf("formatDay(%s, en) = %q, want %q", day.Format("2006-01-02"), got, want)
+		}
+	}
+}
+
+func TestFormatDayMonths(t *testing.T) {
+	for m := time.January; m <= time.December; m++ {
+		day := time.Date(2025, m, 3, 12, 0, 0, 0, vnLocation)
+		if got, want := formatDayYear(day, newsLangEN, vnLocation), day.Format("Mon, Jan 2, 2006"); got != want {
+			t.Errorf("formatDayYear(%s, en) = %q, want %q", m, got, want)
+		}
+	}
+}
+
+func TestFormatDateTime(t *testing.T) {
+	// 01:00 UTC on Sunday is 08:00 Sunday in Vietnam but still 21:00 Saturday in New York
+	at := time.Date(2025, 6, 1, 1, 0, 0, 0, time.UTC)
+	if got := formatDateTime(at, newsLangVI, vnLocation); got != "Chủ Nhật, 01/06/2025 08:00" {
+		t.Errorf("Vietnam time = %q", got)
+	}
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	if got := formatDateTime(at, newsLangEN, ny); got != "Sat, May 31, 2025 21:00" {
+		t.Errorf("New York time = %q", got)
+	}
+}
+
+func TestFormatDateFallbacksAndLunarHook(t *testing.T) {
+	day := time.Date(2026, 2, 17, 9, 0, 0, 0, vnLocation)
+	if got := formatDay(day, "fr", vnLocation); got != "Thứ Ba, 17/02" {
+		t.Errorf("unknown language = %q, want Vietnamese", got)
+	}
+
+	saved := lunarDateSuffix
+	t.Cleanup(func() { lunarDateSuffix = saved })
+	lunarDateSuffix = func(t time.Time, lang string) string {
+		if lang == newsLangVI && t.Month() == 2 && t.Day() == 17 {
+			return " (mùng 1 Tết)"
+		}
+		return ""
+	}
+	if got := formatDayYear(day, newsLangVI, vnLocation); got != "Thứ Ba, 17/02/2026 (mùng 1 Tết)" {
+		t.Errorf("with the lunar hook = %q", got)
+	}
+	if got := formatDay(day, newsLangEN, vnLocation); got != "Tue, Feb 17" {
+		t.Errorf("English with the lunar hook = %q", got)
+	}
+}
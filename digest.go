@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxMissedReports caps how many missed broadcasts the catch-up line mentions
+const maxMissedReports = 3
+
+var snapshotCollection *mongo.Collection
+
+// Snapshot is the set of prices (and session percent changes) a broadcast went out with
+type Snapshot struct {
+	At      time.Time          `bson:"at"`
+	Prices  map[string]float64 `bson:"prices"`
+	Changes map[string]float64 `bson:"changes,omitempty"`
+	// UsdVnd is the exchange rate the report used, for converting VND cost bases
+	UsdVnd float64 `bson:"usd_vnd,omitempty"`
+	// Source is "backfill" for daily closes written by /backfill, empty for broadcasts
+	Source string `bson:"source,omitempty"`
+	// Sources records where a price came from when it wasn't Twelve Data (a /source
+	// override, or the USD/VND fallback chain under "usdvnd")
+	Sources map[string]string `bson:"sources,omitempty"`
+}
+
+// --- MISSED BROADCAST DIGEST ---
+
+// saveSnapshot records the prices of a broadcast report, stamped with its generation time
+// so it matches the last_delivered_at of the chats that received it
+func saveSnapshot(report MarketReport) {
+	if snapshotCollection == nil {
+		return
+	}
+	prices := make(map[string]float64)
+	changes := make(map[string]float64)
+	sources := make(map[string]string)
+	for symbol, d := range report.Quotes {
+		if d.Err == nil && d.Price > 0 {
+			prices[symbol] = d.Price
+			if d.HasPercent {
+				changes[symbol] = d.Percent
+			}
+			if d.Source != "" {
+				sources[symbol] = d.Source
+			}
+		}
+	}
+	if report.UsdVndSource != "" {
+		sources["usdvnd"] = report.UsdVndSource
+	}
+	snap := Snapshot{At: report.At, Prices: prices, Changes: changes, UsdVnd: report.UsdVnd, Sources: sources}
+	if _, err := snapshotCollection.InsertOne(context.TODO(), snap); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to save snapshot: %v", err)
+	}
+}
+
+// broadcastSnapshotsFilter selects the snapshots broadcasts wrote, leaving out /backfill's
+// daily closes
+func broadcastSnapshotsFilter() bson.M {
+	return bson.M{"source": bson.M{"$ne": snapshotSourceBackfill}}
+}
+
+// loadRecentSnapshots returns the latest broadcast snapshots, oldest first (backfilled
+// daily closes weren't sent to anyone, so they never count as missed)
+func loadRecentSnapshots(limit int) []Snapshot {
+	if snapshotCollection == nil {
+		return nil
+	}
+	cursor, err := snapshotCollection.Find(context.TODO(), broadcastSnapshotsFilter(),
+		options.Find().SetSort(bson.D{{Key: "at", Value: -1}}).SetLimit(int64(limit)))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load snapshots: %v", err)
+		return nil
+	}
+	var snaps []Snapshot
+	if err := cursor.All(context.TODO(), &snaps); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to decode snapshots: %v", err)
+		return nil
+	}
+	for i, j := 0, len(snaps)-1; i < j; i, j = i+1, j-1 {
+		snaps[i], snaps[j] = snaps[j], snaps[i]
+	}
+	return snaps
+}
+
+// loadLastDelivered returns each subscriber's last successful broadcast time
+func loadLastDelivered() map[int64]time.Time {
+	last := make(map[int64]time.Time)
+	if userCollection == nil {
+		return last
+	}
+	cursor, err := userCollection.Find(context.TODO(), bson.M{"last_delivered_at": bson.M{"$exists": true}},
+		options.Find().SetProjection(bson.M{"chat_id": 1, "last_delivered_at": 1}))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load delivery times: %v", err)
+		return last
+	}
+	defer cursor.Close(context.TODO())
+	for cursor.Next(context.TODO()) {
+		var result struct {
+			ChatID          int64     `bson:"chat_id"`
+			LastDeliveredAt time.Time `bson:"last_delivered_at"`
+		}
+		if cursor.Decode(&result) == nil {
+			last[result.ChatID] = result.LastDeliveredAt
+		}
+	}
+	return last
+}
+
+// markDelivered stamps the chats that received this broadcast
+func markDelivered(ids []int64, at time.Time) {
+	if userCollection == nil || len(ids) == 0 {
+		return
+	}
+	_, err := userCollection.UpdateMany(context.TODO(), bson.M{"chat_id": bson.M{"$in": ids}},
+		bson.M{"$set": bson.M{"last_delivered_at": at}, "$unset": bson.M{"not_found_count": ""}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to record deliveries: %v", err)
+	}
+}
+
+// missedReports returns the broadcasts a chat missed: snapshots after its last delivery
+// and before now, newest maxMissedReports only. A chat that was never delivered to
+// (new subscriber) has nothing to catch up on.
+func missedReports(snapshots []Snapshot, lastDelivered, now time.Time) []Snapshot {
+	if lastDelivered.IsZero() {
+		return nil
+	}
+	var missed []Snapshot
+	for _, s := range snapshots {
+		if s.At.After(lastDelivered) && s.At.Before(now) {
+			missed = append(missed, s)
+		}
+	}
+	if len(missed) > maxMissedReports {
+		missed = missed[len(missed)-maxMissedReports:]
+	}
+	return missed
+}
+
+// missedDigest renders the catch-up line: the missed dates and how each symbol moved
+// from the first missed report to now ("" when nothing was missed)
+func missedDigest(missed []Snapshot, current map[string]MarketData, symbols []string) string {
+	if len(missed) == 0 {
+		return ""
+	}
+	dates := make([]string, len(missed))
+	for i, s := range missed {
+		dates[i] = formatDay(s.At, newsLangVI, vnLocation)
+	}
+	var deltas []string
+	for _, symbol := range symbols {
+		from, ok := missed[0].Prices[symbol]
+		d := current[symbol]
+		if !ok || from <= 0 || d.Err != nil || d.Price <= 0 {
+			continue
+		}
+		deltas = append(deltas, fmt.Sprintf("%s %s", lookupAsset(symbol).Label, formatPercent((d.Price/from-1)*100)))
+	}
+	line := "📭 Bạn đã bỏ lỡ bản tin ngày " + strings.Join(dates, "; ")
+	if len(deltas) > 0 {
+		line += "\nTừ đó đến nay: " + strings.Join(deltas, " · ")
+	}
+	return line
+}
+
+// --- WEEKLY DIGEST ---
+
+// weeklyDigest is the Sunday admin report (?action=weekly): the week's API spend, the news
+// sentiment trend and the correlation matrix, all read from stored records. It goes out as
+// plain text, so the matrix is sent without a code block.
+func weeklyDigest(records []InvocationMetrics, now time.Time) string {
+	return formatMetricsSummary(summarizeMetrics(records)) + "\n\n" +
+		formatSentimentTrend(loadSentimentWeek(now)) + "\n\n" +
+		correlationSection()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWeeklyDigestSections(t *testing.T) {
+	withDatabase(t, false, nil)
+	now := time.Date(2026, 3, 8, 9, 0, 0, 0, vnLocation)
+	records := []InvocationMetrics{{Trigger: "broadcast", At: now.Add(-24 * time.Hour), APICalls: map[string]int{"quote": 3}}}
+	got := weeklyDigest(records, now)
+	sections := []string{"📊 Báo cáo tuần", "📰 Xu hướng tin tức", "🔗 Tương quan 30 ngày"}
+	last := -1
+	for _, s := range sections {
+		i := strings.Index(got, s)
+		if i <= last {
+			t.Fatalf("section %q missing or out of order in:\n%s", s, got)
+		}
+		last = i
+	}
+	if strings.Contains(got, "```") || strings.Contains(got, "**") {
+		t.Errorf("the plain-text digest contains Markdown:\n%s", got)
+	}
+}
+
+func TestMissedReports(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 8, 0, 0, 0, vnLocation) }
+	var snapshots []Snapshot
+	for d := 1; d <= 8; d++ {
+		snapshots = append(snapshots, Snapshot{At: day(d)})
+	}
+	tests := []struct {
+		name          string
+		lastDelivered time.Time
+		now           time.Time
+		want          []int
+	}{
+		{"up to date", day(7), day(8), nil},
+		{"one-day gap", day(6), day(8), []int{7}},
+		{"multi-day gap capped", day(1), day(8).Add(time.Hour), []int{6, 7, 8}},
+		{"exactly the cap", day(4), day(8), []int{5, 6, 7}},
+		{"new subscriber", time.Time{}, day(8), nil},
+	}
+	for _, tt := range tests {
+		got := missedReports(snapshots, tt.lastDelivered, tt.now)
+		var days []int
+		for _, s := range got {
+			days = append(days, s.At.Day())
+		}
+		if !reflect.DeepEqual(days, tt.want) {
+			t.Errorf("%s: missed days %v, want %v", tt.name, days, tt.want)
+		}
+	}
+}
+
+func TestMissedDigest(t *testing.T) {
+	if got := missedDigest(nil, nil, []string{"gold"}); got != "" {
+		t.Errorf("nothing missed = %q", got)
+	}
+	missed := []Snapshot{
+		{At: time.Date(2026, 3, 5, 8, 0, 0, 0, vnLocation), Prices: map[string]float64{"gold": 2000, "btc": 60000}},
+		{At: time.Date(2026, 3, 6, 8, 0, 0, 0, vnLocation), Prices: map[string]float64{"gold": 2100}},
+	}
+	current := map[string]MarketData{
+		"gold": {Price: 2100},
+		"btc":  {Err: errors.New("rate limited")},
+		"eth":  {Price: 3000},
+	}
+	got := missedDigest(missed, current, []string{"gold", "btc", "eth"})
+	want := "📭 Bạn đã bỏ lỡ bản tin ngày " + formatDay(missed[0].At, newsLangVI, vnLocation) + "; " +
+		formatDay(missed[1].At, newsLangVI, vnLocation) +
+		"\nTừ đó đến nay: " + lookupAsset("gold").Label + " " + formatPercent(5)
+	if got != want {
+		t.Errorf("missedDigest = %q, want %q (deltas from the first missed report, failed quotes and unpriced symbols left out)", got, want)
+	}
+}
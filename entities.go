@@ -0,0 +1,61 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strings"
+)
+
+// telegramEntityLimit is the most formatting entities Telegram accepts in one message;
+// beyond it the whole send is rejected
+const telegramEntityLimit = 100
+
+var (
+	markdownLinkPattern = regexp.MustCompile(`\[([^\]]*)\]\(([^)\s]+)\)`)
+	codeSpanPattern     = regexp.MustCompile("`[^`]+`")
+	newsTitleBold       = regexp.MustCompile(`(🔹 )\*\*(.+?)\*\*`)
+)
+
+// --- ENTITY LIMIT ---
+
+// countEntities estimates the entities Telegram will parse out of a Markdown message:
+// links, code spans, bold and italic runs. Link and code contents are removed before
+// counting emphasis, since markers inside them aren't formatting.
+func countEntities(text string) int {
+	n := len(markdownLinkPattern.FindAllStringIndex(text, -1))
+	text = markdownLinkPattern.ReplaceAllString(text, "")
+	n += len(codeSpanPattern.FindAllStringIndex(text, -1))
+	text = codeSpanPattern.ReplaceAllString(text, "")
+	n += strings.Count(text, "**") / 2
+	text = strings.ReplaceAll(text, "**", "")
+	n += strings.Count(text, "*") / 2
+	n += strings.Count(text, "_") / 2
+	return n
+}
+
+// fitEntityLimit downgrades a Markdown message until it fits telegramEntityLimit, least
+// important formatting first: news titles lose their bold, then links become plain URLs
+// starting from the last one. The same input always gets the same steps, and any
+// downgrade is logged.
+func fitEntityLimit(text string) string {
+	count := countEntities(text)
+	if count <= telegramEntityLimit {
+		return text
+	}
+	before := count
+	text = newsTitleBold.ReplaceAllString(text, "$1$2")
+	count = countEntities(text)
+	links := 0
+	for count > telegramEntityLimit {
+		loc := markdownLinkPattern.FindAllStringSubmatchIndex(text, -1)
+		if len(loc) == 0 {
+			break
+		}
+		last := loc[len(loc)-1]
+		text = text[:last[0]] + text[last[4]:last[5]] + text[last[1]:]
+		links++
+		count = countEntities(text)
+	}
+	log.Printf("[ENTITIES] Downgraded message from %d to %d entities (news bold dropped, %d links made plain)", before, count, links)
+	return text
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCountEntities(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"plain text", 0},
+		{"**bold** and *italic* and _italic_", 3},
+		{"`code` then [link](https://example.com/a_b_c)", 2},
+		// Markers inside links and code spans aren't formatting
+		{"[**not bold**](https://x.y) `*_not_*`", 2},
+		{"**Vàng** `$2,400` _(+1%)_ [Xem](https://x.y)", 4},
+	}
+	for _, tt := range tests {
+		if got := countEntities(tt.text); got != tt.want {
+			t.Errorf("countEntities(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+// newsText is a report with n headlines, each a bold title and a link (two entities)
+func newsText(n int) string {
+	var sb strings.Builder
+	sb.WriteString("📊 **Bản tin**\n")
+	for i := range n {
+		fmt.Fprintf(&sb, "🔹 **Tin %d**\n🔗 [Xem chi tiết](https://example.com/%d)\n", i, i)
+	}
+	return sb.String()
+}
+
+func TestFitEntityLimit(t *testing.T) {
+	short := newsText(10)
+	if got := fitEntityLimit(short); got != short {
+		t.Error("a message under the limit was changed")
+	}
+
+	// Dropping the news bold is enough: every link survives
+	got := fitEntityLimit(newsText(60))
+	if n := countEntities(got); n > telegramEntityLimit || strings.Contains(got, "🔹 **") || strings.Count(got, "](https://") != 60 {
+		t.Errorf("60 headlines: %d entities, %d links", n, strings.Count(got, "](https://"))
+	}
+	if !strings.HasPrefix(got, "📊 **Bản tin**") {
+		t.Error("the report title lost its bold")
+	}
+
+	// Beyond that, links become plain URLs from the last one up
+	got = fitEntityLimit(newsText(120))
+	if n := countEntities(got); n != telegramEntityLimit {
+		t.Errorf("120 headlines: %d entities, want exactly %d", n, telegramEntityLimit)
+	}
+	if !strings.Contains(got, "[Xem chi tiết](https://example.com/0)") || !strings.HasSuffix(got, "🔹 Tin 119\n🔗 https://example.com/119\n") {
+		t.Errorf("the wrong links were made plain:\n%s", got[len(got)-200:])
+	}
+	if fitEntityLimit(newsText(120)) != got {
+		t.Error("the same input was downgraded differently")
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Experiment variants; "A" is the control (default layout)
+const (
+	variantControl   = "A"
+	variantTreatment = "B"
+)
+
+// Experiment is an A/B test of the broadcast layout, configured in the settings document:
+//
+//	"experiment": { "name": "quotes-first", "percent": 10, "quotes_first": true, "news_count": 5 }
+//
+// Assignment is a hash of the chat ID, so nothing is stored per user and removing the
+// field (or setting percent to 0) reverts everyone to the default immediately.
+type Experiment struct {
+	Name        string `bson:"name"`
+	Percent     int    `bson:"percent"`
+	QuotesFirst bool   `bson:"quotes_first,omitempty"`
+	NewsCount   int    `bson:"news_count,omitempty"`
+}
+
+// ReportText is one rendering of a report, with and without the news list
+type ReportText struct {
+	Text      string
+	CardsText string
+}
+
+var (
+	broadcastLogCollection *mongo.Collection
+	clickCollection        *mongo.Collection
+)
+
+// --- A/B EXPERIMENTS ---
+
+// active reports whether the experiment should be applied
+func (e *Experiment) active() bool {
+	return e != nil && e.Name != "" && e.Percent > 0
+}
+
+// assignVariant deterministically buckets a chat into the experiment ("" when none runs).
+// The name is part of the hash so each experiment gets a fresh split.
+func assignVariant(e *Experiment, chatID int64) string {
+	if !e.active() {
+		return ""
+	}
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s:%d", e.Name, chatID)
+	if int(h.Sum32()%100) < e.Percent {
+		return variantTreatment
+	}
+	return variantControl
+}
+
+// textFor picks the rendering for a chat's news mode
+func (t ReportText) textFor(cards bool) string {
+	if cards && t.CardsText != "" {
+		return t.CardsText
+	}
+	return t.Text
+}
+
+// clickSigningKey signs tracked links; tracking is off without a key and a public URL
+func clickSigningKey() []byte {
+	return []byte(os.Getenv("ADMIN_ACTION_KEY"))
+}
+
+// clickPayload is what a tracked link carries
+type clickPayload struct {
+	Experiment string `json:"e"`
+	Variant    string `json:"v"`
+	URL        string `json:"u"`
+}
+
+// trackedLink wraps an article link so the click is counted for the variant before
+// redirecting. Links are signed so the endpoint can't be used as an open redirect.
+func trackedLink(e *Experiment, variant, link string) string {
+	base := os.Getenv("PUBLIC_BASE_URL")
+	key := clickSigningKey()
+	if base == "" || len(key) == 0 || !e.active() {
+		return link
+	}
+	raw, _ := json.Marshal(clickPayload{Experiment: e.Name, Variant: variant, URL: link})
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(token))
+	sig := base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:12])
+	return fmt.Sprintf("%s?click=%s&sig=%s", strings.TrimRight(base, "/"), token, sig)
+}
+
+// handleClick records a tracked-link click and redirects to the article
+func handleClick(request events.LambdaFunctionURLRequest) events.LambdaFunctionURLResponse {
+	token := request.QueryStringParameters["click"]
+	sig, _ := base64.RawURLEncoding.DecodeString(request.QueryStringParameters["sig"])
+	key := clickSigningKey()
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(token))
+	if len(key) == 0 || !hmac.Equal(sig, mac.Sum(nil)[:12]) {
+		return events.LambdaFunctionURLResponse{StatusCode: 403, Body: "Forbidden"}
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	var p clickPayload
+	if err != nil || json.Unmarshal(raw, &p) != nil {
+		return events.LambdaFunctionURLResponse{StatusCode: 400, Body: "Bad link"}
+	}
+	if u, err := url.Parse(p.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return events.LambdaFunctionURLResponse{StatusCode: 400, Body: "Bad link"}
+	}
+	initDatabase()
+	if clickCollection != nil {
+		_, err := clickCollection.InsertOne(context.TODO(), bson.M{
+			"experiment": p.Experiment, "variant": p.Variant, "url": p.URL, "at": clock(),
+		})
+		if err != nil {
+			log.Printf("[DATABASE ERROR] Failed to record click: %v", err)
+		}
+	}
+	return events.LambdaFunctionURLResponse{StatusCode: 302, Headers: map[string]string{"Location": p.URL}}
+}
+
+// recordBroadcastLog stores how many chats got each variant in this broadcast and, for
+// split-news chats, the IDs of both messages
+func recordBroadcastLog(e *Experiment, sentByVariant map[string]int, total int, splits []SplitDelivery) {
+	if broadcastLogCollection == nil {
+		return
+	}
+	doc := bson.M{"at": clock(), "profile": activeProfile().Name, "sent": total}
+	if len(splits) > 0 {
+		doc["splits"] = splits
+	}
+	if e.active() {
+		doc["experiment"] = e.Name
+		doc["variants"] = sentByVariant
+	}
+	if _, err := broadcastLogCollection.InsertOne(context.TODO(), doc); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to write broadcast log: %v", err)
+	}
+}
+
+// experimentStats sums deliveries and clicks per variant for an experiment
+func experimentStats(name string) (sent, clicks map[string]int) {
+	sent, clicks = map[string]int{}, map[string]int{}
+	if broadcastLogCollection == nil || clickCollection == nil {
+		return sent, clicks
+	}
+	cursor, err := broadcastLogCollection.Find(context.TODO(), bson.M{"experiment": name})
+	if err == nil {
+		var logs []struct {
+			Variants map[string]int `bson:"variants"`
+		}
+		if cursor.All(context.TODO(), &logs) == nil {
+			for _, l := range logs {
+				for v, n := range l.Variants {
+					sent[v] += n
+				}
+			}
+		}
+	}
+	for _, v := range []string{variantControl, variantTreatment} {
+		n, err := clickCollection.CountDocuments(context.TODO(), bson.M{"experiment": name, "variant": v})
+		if err == nil {
+			clicks[v] = int(n)
+		}
+	}
+	return sent, clicks
+}
+
+// experimentReply renders the admin /experiment summary
+func experimentReply() string {
+	e := loadConfig().Experiment
+	if !e.active() {
+		return "🧪 Không có thử nghiệm nào đang chạy."
+	}
+	sent, clicks := experimentStats(e.Name)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🧪 Thử nghiệm %q: %d%% nhận biến thể B (quotes_first=%t, news_count=%d)\n",
+		e.Name, e.Percent, e.QuotesFirst, e.NewsCount)
+	for _, v := range []string{variantControl, variantTreatment} {
+		ctr := 0.0
+		if sent[v] > 0 {
+			ctr = float64(clicks[v]) * 100 / float64(sent[v])
+		}
+		fmt.Fprintf(&sb, "• %s: %d lượt gửi, %d lượt nhấn (%.1f%%)\n", v, sent[v], clicks[v], ctr)
+	}
+	return sb.String()
+}
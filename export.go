@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// --- EXPORT ---
+
+// writeTradingViewList writes symbols as a TradingView import list (comma-separated
+// EXCHANGE:SYMBOL) and returns the ones with no mapping
+func writeTradingViewList(w io.Writer, symbols []string) ([]string, error) {
+	var unsupported []string
+	first := true
+	for _, s := range symbols {
+		tv, ok := tradingViewSymbols[s]
+		if !ok {
+			unsupported = append(unsupported, s)
+			continue
+		}
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return unsupported, err
+			}
+		}
+		if _, err := io.WriteString(w, tv); err != nil {
+			return unsupported, err
+		}
+		first = false
+	}
+	return unsupported, nil
+}
+
+// writeAlertsCSV streams a chat's active alerts straight from the cursor
+func writeAlertsCSV(w io.Writer, chatID int64) error {
+	if alertCollection == nil {
+		return fmt.Errorf("database unavailable")
+	}
+	cursor, err := alertCollection.Find(context.TODO(), bson.M{"chat_id": chatID, "triggered": false},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.TODO())
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"symbol", "type", "direction", "target", "percent", "recurring", "created_at", "description"})
+	for cursor.Next(context.TODO()) {
+		var a Alert
+		if err := cursor.Decode(&a); err != nil {
+			log.Printf("[DATABASE WARNING] Skipping undecodable alert: %v", err)
+			continue
+		}
+		typ := a.Type
+		if typ == "" {
+			typ = alertTypePrice
+		}
+		cw.Write([]string{a.Symbol, typ, a.Direction,
+			strconv.FormatFloat(a.Target, 'f', -1, 64), strconv.FormatFloat(a.Percent, 'f', -1, 64),
+			strconv.FormatBool(a.Recurring), a.CreatedAt.UTC().Format(time.RFC3339), describeAlert(a)})
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// sendExport handles "/export watchlist|alerts"; files are piped into the upload as they
+// are written
+func sendExport(b *tele.Bot, chat *tele.Chat, payload string) error {
+	var doc *tele.Document
+	pr, pw := io.Pipe()
+	day := clock().In(vnLocation).Format("20060102")
+	switch strings.ToLower(strings.TrimSpace(payload)) {
+	case "watchlist":
+		watchlist := getWatchlist(chat.ID)
+		if len(watchlist) == 0 {
+			_, err := b.Send(chat, "ℹ️ Danh sách theo dõi trống. Thêm bằng `/watch add btc`.", &tele.SendOptions{ParseMode: tele.ModeMarkdown})
+			return err
+		}
+		var unsupported []string
+		for _, s := range watchlist {
+			if _, ok := tradingViewSymbols[s]; !ok {
+				unsupported = append(unsupported, s)
+			}
+		}
+		if len(unsupported) == len(watchlist) {
+			_, err := b.Send(chat, "⚠️ Không có mã nào xuất được sang TradingView (không hỗ trợ: "+strings.Join(unsupported, ", ")+").")
+			return err
+		}
+		go func() {
+			_, err := writeTradingViewList(pw, watchlist)
+			pw.CloseWithError(err)
+		}()
+		caption := "📄 Danh sách theo dõi cho TradingView (Import list)"
+		if len(unsupported) > 0 {
+			caption += "\nKhông hỗ trợ: " + strings.Join(unsupported, ", ")
+		}
+		doc = &tele.Document{File: tele.FromReader(pr), FileName: "watchlist_" + day + ".txt", Caption: caption}
+	case "alerts":
+		go func() {
+			pw.CloseWithError(writeAlertsCSV(pw, chat.ID))
+		}()
+		doc = &tele.Document{File: tele.FromReader(pr), FileName: "alerts_" + day + ".csv", Caption: "📄 Cảnh báo đang hoạt động"}
+	case "metrics":
+		if !hasRole(chat.ID, roleViewer) {
+			_, err := b.Send(chat, "ℹ️ Cú pháp: /export watchlist hoặc /export alerts")
+			return err
+		}
+		go func() {
+			pw.CloseWithError(writeMetricsCSV(pw))
+		}()
+		doc = &tele.Document{File: tele.FromReader(pr), FileName: "metrics_" + day + ".csv", Caption: "📊 Số liệu các lượt chạy 7 ngày qua"}
+	default:
+		_, err := b.Send(chat, "ℹ️ Cú pháp: /export watchlist hoặc /export alerts")
+		return err
+	}
+	_, err := b.Send(chat, doc)
+	pr.Close()
+	if err != nil {
+		log.Printf("[EXPORT ERROR] Failed to send export to %d: %v", chat.ID, err)
+		_, err = b.Send(chat, "⚠️ Không thể xuất dữ liệu lúc này.")
+	}
+	return err
+}
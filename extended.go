@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"time"
+)
+
+// ExtendedQuote holds the regular-session close plus pre/post-market fields for US equities
+type ExtendedQuote struct {
+	Symbol          string
+	Name            string
+	Exchange        string
+	Close           float64
+	PercentChange   float64
+	IsMarketOpen    bool
+	HasExtended     bool
+	ExtendedPrice   float64
+	ExtendedPercent float64
+	ExtendedTime    time.Time
+}
+
+// --- EXTENDED HOURS LOGIC ---
+
+// hasExtendedHours reports whether a symbol can trade pre/post market.
+// Forex, crypto and metals trade around the clock and have no such session.
+func hasExtendedHours(symbol string) bool {
+	return !isPair(symbol) && !usdAssets[symbol]
+}
+
+// getExtendedQuote fetches a quote with prepost=true so Twelve Data includes extended-hours fields
+func getExtendedQuote(symbol string, apiKey string) (ExtendedQuote, error) {
+	log.Printf("[API] Fetching extended-hours quote for %s...", symbol)
+	apiUrl := twelveDataURL("quote", url.Values{"symbol": {twelveDataSymbol(symbol)}, "prepost": {"true"}, "apikey": {apiKey}})
+	countAPICall("quote")
+	body, err := fetchBody(context.Background(), apiUrl, 15*time.Second, jsonContentTypes)
+	if err != nil {
+		log.Printf("[API ERROR] Request failed for %s: %v", symbol, err)
+		return ExtendedQuote{}, err
+	}
+
+	var result struct {
+		Symbol                string    `json:"symbol"`
+		Name                  string    `json:"name"`
+		Exchange              string    `json:"exchange"`
+		Close                 FlexFloat `json:"close"`
+		PercentChange         FlexFloat `json:"percent_change"`
+		IsMarketOpen          bool      `json:"is_market_open"`
+		ExtendedPrice         FlexFloat `json:"extended_price"`
+		ExtendedPercentChange FlexFloat `json:"extended_percent_change"`
+		ExtendedTimestamp     int64     `json:"extended_timestamp"`
+		Message               string    `json:"message"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return ExtendedQuote{}, err
+	}
+	if result.Message != "" {
+		log.Printf("[API ERROR] Message from TwelveData for %s: %s", symbol, result.Message)
+		return ExtendedQuote{}, fmt.Errorf("twelvedata: %s", result.Message)
+	}
+
+	q := ExtendedQuote{
+		Symbol:       result.Symbol,
+		Name:         result.Name,
+		Exchange:     result.Exchange,
+		IsMarketOpen: result.IsMarketOpen,
+	}
+	q.Close = result.Close.Value
+	q.PercentChange = result.PercentChange.Value
+	if p := result.ExtendedPrice.Value; p > 0 {
+		q.HasExtended = true
+		q.ExtendedPrice = p
+		q.ExtendedPercent = result.ExtendedPercentChange.Value
+		if result.ExtendedTimestamp > 0 {
+			q.ExtendedTime = time.Unix(result.ExtendedTimestamp, 0)
+		}
+	}
+	return q, nil
+}
+
+// extendedReply builds the reply for "/extended AAPL"
+func extendedReply(arg string) string {
+	symbol := resolveSymbol(arg)
+	if symbol == "" {
+		return "ℹ️ Cú pháp: `/extended AAPL` (mã cổ phiếu Mỹ)."
+	}
+	if !hasExtendedHours(symbol) {
+		return fmt.Sprintf("ℹ️ %s giao dịch liên tục, không có phiên trước/sau giờ.", symbol)
+	}
+
+	q, err := getExtendedQuote(symbol, os.Getenv("TWELVE_DATA_API_KEY"))
+	if err != nil {
+		return fmt.Sprintf("⚠️ Không lấy được dữ liệu cho %s.", symbol)
+	}
+
+	title := q.Symbol
+	if q.Name != "" {
+		title = fmt.Sprintf("%s — %s", q.Symbol, q.Name)
+	}
+	msg := fmt.Sprintf("🏛 **%s**\n• Phiên chính (đóng cửa): `$%.2f` (%s)\n", title, q.Close, formatPercent(q.PercentChange))
+	if q.IsMarketOpen {
+		msg += "🟢 _Phiên chính đang mở cửa._"
+		return msg
+	}
+	msg += "🔴 _Phiên chính đã đóng cửa._\n"
+	if !q.HasExtended {
+		return msg + "ℹ️ Chưa có dữ liệu giao dịch ngoài giờ."
+	}
+	msg += fmt.Sprintf("🌙 **Ngoài giờ (pre/post-market):** `$%.2f` (%s)", q.ExtendedPrice, formatPercent(q.ExtendedPercent))
+	if !q.ExtendedTime.IsZero() {
+		msg += fmt.Sprintf("\n🕒 _Lúc %s_", q.ExtendedTime.Format("02/01 15:04"))
+	}
+	return msg
+}
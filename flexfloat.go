@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FlexFloat decodes the numbers Twelve Data sends, which arrive as JSON strings for some
+// symbol classes and as JSON numbers for others. null, "" and an absent field leave it
+// invalid (Valid false) rather than zero; anything else that isn't a number is an error,
+// so a garbled quote fails loudly instead of becoming a silent 0.
+type FlexFloat struct {
+	Value float64
+	Valid bool
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (f *FlexFloat) UnmarshalJSON(data []byte) error {
+	*f = FlexFloat{}
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	raw := string(data)
+	if strings.HasPrefix(raw, `"`) {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			return nil
+		}
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %s", data)
+	}
+	f.Value, f.Valid = v, true
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestFlexFloatDecoding(t *testing.T) {
+	tests := []struct {
+		json    string
+		value   float64
+		valid   bool
+		wantErr bool
+	}{
+		{json: `{"v":"123.45"}`, value: 123.45, valid: true},
+		{json: `{"v":123.45}`, value: 123.45, valid: true},
+		{json: `{"v":" -0.42 "}`, value: -0.42, valid: true},
+		{json: `{"v":"1e3"}`, value: 1000, valid: true},
+		{json: `{"v":0}`, value: 0, valid: true},
+		{json: `{"v":null}`},
+		{json: `{"v":""}`},
+		{json: `{"v":"  "}`},
+		{json: `{}`},
+		{json: `{"v":"abc"}`, wantErr: true},
+		{json: `{"v":true}`, wantErr: true},
+		{json: `{"v":"12,5"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var got struct {
+			V FlexFloat `json:"v"`
+		}
+		err := json.Unmarshal([]byte(tt.json), &got)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.json, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (got.V.Value != tt.value || got.V.Valid != tt.valid) {
+			t.Errorf("%s = %+v, want {Value:%v Valid:%v}", tt.json, got.V, tt.value, tt.valid)
+		}
+	}
+}
+
+func TestFlexFloatResetsOnReuse(t *testing.T) {
+	f := FlexFloat{Value: 7, Valid: true}
+	if err := json.Unmarshal([]byte(`null`), &f); err != nil || f.Valid || f.Value != 0 {
+		t.Errorf("null over a valid value = %+v, %v", f, err)
+	}
+}
+
+func TestParseQuote(t *testing.T) {
+	md := parseQuote("BTC/USD", []byte(`{"close":"64250.5","open":64000,"percent_change":-1.25}`))
+	if md.Err != nil || md.Price != 64250.5 || md.Open != 64000 || md.Percent != -1.25 || !md.HasPercent {
+		t.Errorf("mixed string and number quote = %+v", md)
+	}
+
+	md = parseQuote("VNINDEX", []byte(`{"close":1280.4,"percent_change":null}`))
+	if md.Err != nil || md.Price != 1280.4 || md.HasPercent {
+		t.Errorf("quote without a percent change = %+v; want a price and HasPercent false", md)
+	}
+
+	md = parseQuote("BTC/USD", []byte(`{"code":429,"message":"You have run out of API credits"}`))
+	if !errors.Is(md.Err, errRateLimited) {
+		t.Errorf("429 message = %v, want errRateLimited", md.Err)
+	}
+
+	md = parseQuote("NOPE", []byte(`{"code":404,"message":"symbol not found"}`))
+	if !errors.Is(md.Err, errUnknownSymbol) {
+		t.Errorf("404 message = %v, want errUnknownSymbol", md.Err)
+	}
+
+	for _, body := range []string{`{"close":"0"}`, `{"close":""}`, `{"close":"n/a"}`, `not json`} {
+		if md := parseQuote("BTC/USD", []byte(body)); md.Err == nil || md.Price != 0 {
+			t.Errorf("parseQuote(%s) = %+v, want an error", body, md)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// floodCacheTTL bounds how long a stale backoff read may let sends through after another
+// invocation hit a flood wait
+const floodCacheTTL = 5 * time.Second
+
+// defaultFloodMaxWait caps how long one broadcast waits out flood backoffs before leaving
+// the rest to the missed-digest path
+const defaultFloodMaxWait = 3 * time.Minute
+
+// errFloodDeferred is returned by deliver while a flood backoff is in effect; nothing was sent
+var errFloodDeferred = errors.New("telegram flood backoff in effect")
+
+var (
+	floodMu       sync.Mutex
+	floodUntil    time.Time
+	floodLoadedAt time.Time
+)
+
+// --- FLOOD WAIT ---
+
+// floodDocID is the settings document holding this profile's shared backoff
+func floodDocID() string {
+	return activeProfile().collectionName("flood_backoff")
+}
+
+// floodBackoffUntil returns when sends may resume; the zero time when they already can
+func floodBackoffUntil() time.Time {
+	floodMu.Lock()
+	defer floodMu.Unlock()
+	if settingsCollection == nil || (!floodLoadedAt.IsZero() && clock().Sub(floodLoadedAt) < floodCacheTTL) {
+		return floodUntil
+	}
+	var doc struct {
+		Until time.Time `bson:"until"`
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := settingsCollection.FindOne(ctx, bson.M{"_id": floodDocID()}).Decode(&doc)
+	floodLoadedAt = clock()
+	if err == nil && doc.Until.After(floodUntil) {
+		floodUntil = doc.Until
+	}
+	return floodUntil
+}
+
+// recordFloodWait pushes the shared backoff out by retryAfter seconds; $max keeps a
+// concurrent invocation from shortening a longer wait
+func recordFloodWait(retryAfter int) time.Time {
+	if retryAfter <= 0 {
+		retryAfter = 1
+	}
+	until := clock().Add(time.Duration(retryAfter) * time.Second)
+	floodMu.Lock()
+	if until.After(floodUntil) {
+		floodUntil = until
+	}
+	floodMu.Unlock()
+	log.Printf("[BROADCAST] Flood wait of %ds, backing off until %s", retryAfter, until.Format(time.RFC3339))
+	if settingsCollection != nil {
+		_, err := settingsCollection.UpdateOne(context.TODO(), bson.M{"_id": floodDocID()},
+			bson.M{"$max": bson.M{"until": until}}, options.Update().SetUpsert(true))
+		if err != nil {
+			log.Printf("[DATABASE ERROR] Failed to save flood backoff: %v", err)
+		}
+	}
+	return until
+}
+
+// floodRetryAfter extracts retry_after from telebot's FloodError
+func floodRetryAfter(err error) (int, bool) {
+	var flood tele.FloodError
+	if errors.As(err, &flood) {
+		return flood.RetryAfter, true
+	}
+	var floodPtr *tele.FloodError
+	if errors.As(err, &floodPtr) && floodPtr != nil {
+		return floodPtr.RetryAfter, true
+	}
+	return 0, false
+}
+
+// isFloodDeferral reports whether a send failed only because of flood limits, so the
+// recipient should be retried rather than dropped
+func isFloodDeferral(err error) bool {
+	if errors.Is(err, errFloodDeferred) {
+		return true
+	}
+	_, ok := floodRetryAfter(err)
+	return ok
+}
+
+// waitFloodBackoff sleeps until the shared backoff ends; false if that is past the deadline
+func waitFloodBackoff(deadline time.Time) bool {
+	until := floodBackoffUntil()
+	if until.After(deadline) {
+		return false
+	}
+	if wait := until.Sub(clock()); wait > 0 {
+		time.Sleep(wait)
+	}
+	return true
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// floodBotAPI answers sendMessage with a 429 and retry_after while flooding is set
+type floodBotAPI struct {
+	mu       sync.Mutex
+	flooding bool
+	sends    int
+}
+
+func (f *floodBotAPI) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	f.sends++
+	body := `{"ok":true,"result":{"message_id":1,"date":0,"chat":{"id":7}}}`
+	if f.flooding {
+		body = `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 30","parameters":{"retry_after":30}}`
+	}
+	f.mu.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}, nil
+}
+
+// chatFloodAPI flood-limits one chat: its first sendMessage answers 429 with retryAfter,
+// and every later one goes through. It records which chats got a message and what the
+// admin was told.
+type chatFloodAPI struct {
+	mu         sync.Mutex
+	limited    int64
+	retryAfter int
+	flooded    bool
+	delivered  map[int64]int
+	admin      []string
+}
+
+func (f *chatFloodAPI) RoundTrip(req *http.Request) (*http.Response, error) {
+	var params struct {
+		ChatID string `json:"chat_id"`
+		Text   string `json:"text"`
+	}
+	if req.Body != nil {
+		raw, _ := io.ReadAll(req.Body)
+		json.Unmarshal(raw, &params)
+		req.Body.Close()
+	}
+	var chatID int64
+	fmt.Sscan(params.ChatID, &chatID)
+	f.mu.Lock()
+	body := fmt.Sprintf(`{"ok":true,"result":{"message_id":1,"date":0,"chat":{"id":%d}}}`, chatID)
+	switch {
+	case chatID == f.limited && !f.flooded:
+		f.flooded = true
+		body = fmt.Sprintf(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after %d","parameters":{"retry_after":%d}}`,
+			f.retryAfter, f.retryAfter)
+	case chatID == 99:
+		f.admin = append(f.admin, params.Text)
+	default:
+		f.delivered[chatID]++
+	}
+	f.mu.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}, nil
+}
+
+// withFloodState fixes the clock and starts without a backoff
+func withFloodState(t *testing.T, now time.Time) {
+	t.Helper()
+	withDatabase(t, false, nil)
+	savedClock := clock
+	clock = func() time.Time { return now }
+	reset := func() {
+		floodMu.Lock()
+		floodUntil, floodLoadedAt = time.Time{}, time.Time{}
+		floodMu.Unlock()
+	}
+	reset()
+	t.Cleanup(func() {
+		clock = savedClock
+		reset()
+	})
+}
+
+func TestFloodRetryAfter(t *testing.T) {
+	withFloodState(t, time.Date(2026, 3, 2, 8, 0, 0, 0, vnLocation))
+	api := &floodBotAPI{flooding: true}
+	b, err := tele.NewBot(tele.Settings{Token: "test", Offline: true, Client: &http.Client{Transport: api}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, flood := b.Send(&tele.Chat{ID: 7}, "x")
+	tests := []struct {
+		name  string
+		err   error
+		after int
+		ok    bool
+	}{
+		{"flood", flood, 30, true},
+		{"wrapped", fmt.Errorf("send: %w", flood), 30, true},
+		{"other error", tele.ErrChatNotFound, 0, false},
+		{"nil", nil, 0, false},
+	}
+	for _, tt := range tests {
+		if after, ok := floodRetryAfter(tt.err); after != tt.after || ok != tt.ok {
+			t.Errorf("%s: floodRetryAfter = %d, %v; want %d, %v", tt.name, after, ok, tt.after, tt.ok)
+		}
+	}
+	if !isFloodDeferral(flood) || !isFloodDeferral(errFloodDeferred) || isFloodDeferral(errors.New("blocked")) {
+		t.Error("isFloodDeferral misclassified an error")
+	}
+}
+
+func TestDeliverHonorsFloodBackoff(t *testing.T) {
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, vnLocation)
+	withFloodState(t, now)
+	api := &floodBotAPI{flooding: true}
+	b, err := tele.NewBot(tele.Settings{Token: "test", Offline: true, Client: &http.Client{Transport: api}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := deliver(b, 7, 0, "report", nil); !isFloodDeferral(err) {
+		t.Fatalf("a 429 returned %v, want a flood error", err)
+	}
+	if until := floodBackoffUntil(); !until.Equal(now.Add(30 * time.Second)) {
+		t.Fatalf("backoff until %s, want 30s from now", until)
+	}
+	// During the backoff sends fail fast, without calling Telegram
+	api.flooding = false
+	if _, err := deliver(b, 8, 0, "report", nil); !errors.Is(err, errFloodDeferred) || api.sends != 1 {
+		t.Errorf("during the backoff: err %v after %d sends, want errFloodDeferred and no new send", err, api.sends)
+	}
+	// A shorter retry_after never shortens the wait already recorded
+	recordFloodWait(5)
+	if until := floodBackoffUntil(); !until.Equal(now.Add(30 * time.Second)) {
+		t.Errorf("a shorter flood wait moved the backoff to %s", until)
+	}
+
+	later := now.Add(31 * time.Second)
+	clock = func() time.Time { return later }
+	if _, err := deliver(b, 8, 0, "report", nil); err != nil || api.sends != 2 {
+		t.Errorf("after the backoff: err %v after %d sends", err, api.sends)
+	}
+}
+
+func TestWaitFloodBackoff(t *testing.T) {
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, vnLocation)
+	withFloodState(t, now)
+	if !waitFloodBackoff(now.Add(time.Minute)) {
+		t.Error("no backoff, yet the wait gave up")
+	}
+	recordFloodWait(120)
+	if waitFloodBackoff(now.Add(time.Minute)) {
+		t.Error("waited for a backoff that ends after the deadline")
+	}
+}
+
+func TestBroadcastFloodRequeue(t *testing.T) {
+	users := map[int64]bool{7: true, 8: true, 9: true, 10: true}
+	tests := []struct {
+		name       string
+		retryAfter int
+		maxWait    string
+		// requeued reports whether the limited chat is retried within FLOOD_MAX_WAIT
+		requeued bool
+	}{
+		{"wait within budget", 1, "10s", true},
+		{"wait past budget", 30, "2s", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withFloodState(t, time.Now())
+			clock = time.Now
+			t.Setenv("ADMIN_CHAT_ID", "99")
+			t.Setenv("FLOOD_MAX_WAIT", tt.maxWait)
+			api := &chatFloodAPI{limited: 8, retryAfter: tt.retryAfter, delivered: map[int64]int{}}
+			b, err := tele.NewBot(tele.Settings{Token: "test", Offline: true, Client: &http.Client{Transport: api}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+			broadcastReport(b, users, previewTestReport(), nil, false)
+			if elapsed, budget := time.Since(start), envDuration("FLOOD_MAX_WAIT", 0); elapsed > budget {
+				t.Errorf("broadcast took %s, past FLOOD_MAX_WAIT %s", elapsed, budget)
+			}
+
+			api.mu.Lock()
+			defer api.mu.Unlock()
+			undelivered := 0
+			for id := range users {
+				switch n := api.delivered[id]; {
+				case n > 1:
+					t.Errorf("chat %d got the report %d times", id, n)
+				case n == 0:
+					undelivered++
+				}
+			}
+			if tt.requeued && undelivered != 0 {
+				t.Errorf("%d chats undelivered after the flood wait, want every chat requeued", undelivered)
+			}
+			if !tt.requeued && api.delivered[8] != 0 {
+				t.Error("the limited chat was sent to before its retry_after expired")
+			}
+			// Whoever is left is reported as deferred, so the missed digest picks them up
+			if len(api.admin) != 1 || !strings.Contains(api.admin[0], fmt.Sprintf(", %d chưa gửi được", undelivered)) {
+				t.Errorf("admin was told %q, want %d undelivered", api.admin, undelivered)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Number styles for /format: Vietnamese "1.234.567,89" or international "1,234,567.89"
+const (
+	numberStyleVN   = "vn"
+	numberStyleIntl = "intl"
+)
+
+// --- NUMBER FORMAT ---
+
+// formatNumber renders val with the given decimals and the style's separators
+func formatNumber(val float64, decimals int, style string) string {
+	group, point := ".", ","
+	if style == numberStyleIntl {
+		group, point = ",", "."
+	}
+	str := strconv.FormatFloat(math.Abs(val), 'f', decimals, 64)
+	whole, frac, _ := strings.Cut(str, ".")
+	var parts []string
+	for i := len(whole); i > 0; i -= 3 {
+		start := i - 3
+		if start < 0 {
+			start = 0
+		}
+		parts = append([]string{whole[start:i]}, parts...)
+	}
+	out := strings.Join(parts, group)
+	if frac != "" {
+		out += point + frac
+	}
+	if val < 0 && strings.Trim(str, "0.") != "" {
+		out = "-" + out
+	}
+	return out
+}
+
+// getNumberStyle returns a chat's number style, Vietnamese by default
+func getNumberStyle(chatID int64) string {
+	if userCollection == nil {
+		return numberStyleVN
+	}
+	var result struct {
+		NumberStyle string `bson:"number_style"`
+	}
+	userCollection.FindOne(context.TODO(), bson.M{"chat_id": chatID},
+		options.FindOne().SetProjection(bson.M{"number_style": 1})).Decode(&result)
+	if result.NumberStyle == numberStyleIntl {
+		return numberStyleIntl
+	}
+	return numberStyleVN
+}
+
+// setNumberStyle stores a chat's number style; false if the chat isn't subscribed
+func setNumberStyle(chatID int64, style string) bool {
+	if userCollection == nil {
+		return false
+	}
+	result, err := userCollection.UpdateOne(context.TODO(), bson.M{"chat_id": chatID},
+		bson.M{"$set": bson.M{"number_style": style, "updated_at": clock()}, "$inc": bson.M{settingsRevField: 1}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to save number style for %d: %v", chatID, err)
+		return false
+	}
+	return result.MatchedCount > 0
+}
+
+// formatReply handles "/format vn|intl"
+func formatReply(chatID int64, arg string) string {
+	style := strings.ToLower(strings.TrimSpace(arg))
+	if style != numberStyleVN && style != numberStyleIntl {
+		return "ℹ️ Cú pháp: /format vn (1.234.567,89) hoặc /format intl (1,234,567.89)"
+	}
+	if !setNumberStyle(chatID, style) {
+		return "ℹ️ Bạn cần đăng ký bằng /start trước."
+	}
+	return "✅ Định dạng số: " + formatNumber(1234567.89, 2, style)
+}
+
+// --- CONVERT ---
+
+// usdValue returns the USD price of one unit of code: 1 for USD, the USD/VND rate's inverse
+// for VND, and the quote of the CODE/USD asset otherwise
+func usdValue(code, apiKey string) (float64, error) {
+	switch code {
+	case "USD":
+		return 1, nil
+	case "VND":
+		rate, err := getCachedUsdVnd(apiKey)
+		if err != nil || rate <= 0 {
+			return 0, fmt.Errorf("no USD/VND rate")
+		}
+		return 1 / rate, nil
+	}
+	symbol := resolveSymbol(code + "USD")
+	d := fetchQuotes([]string{symbol})[symbol]
+	if d.Err != nil || d.Price <= 0 {
+		return 0, fmt.Errorf("no quote for %s", symbol)
+	}
+	return d.Price, nil
+}
+
+// convertReply handles "/convert 100 USD VND" (or BTC, XAU, EUR... on either side)
+func convertReply(chatID int64, payload string) string {
+	args := strings.Fields(payload)
+	if len(args) != 3 {
+		return "ℹ️ Cú pháp: `/convert 100 USD VND` hoặc `/convert 0.5 BTC VND`"
+	}
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(args[0], ",", ""), 64)
+	if err != nil || amount <= 0 {
+		return "⚠️ Số tiền không hợp lệ."
+	}
+	from, to := strings.ToUpper(args[1]), strings.ToUpper(args[2])
+	apiKey := os.Getenv("TWELVE_DATA_API_KEY")
+	fromUSD, err := usdValue(from, apiKey)
+	if err != nil {
+		return fmt.Sprintf("⚠️ Không lấy được giá của %s.", from)
+	}
+	toUSD, err := usdValue(to, apiKey)
+	if err != nil {
+		return fmt.Sprintf("⚠️ Không lấy được giá của %s.", to)
+	}
+	style := getNumberStyle(chatID)
+	result := amount * fromUSD / toUSD
+	return fmt.Sprintf("💱 %s %s ≈ **%s %s**", formatNumber(amount, amountDecimals(amount, from), style), from,
+		formatNumber(result, amountDecimals(result, to), style), to)
+}
+
+// amountDecimals picks how many decimals an amount of code needs: none for VND, more for
+// fractions of a coin
+func amountDecimals(v float64, code string) int {
+	switch {
+	case code == "VND":
+		return 0
+	case v < 1:
+		return 6
+	default:
+		return 2
+	}
+}
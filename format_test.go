@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		val      float64
+		decimals int
+		style    string
+		want     string
+	}{
+		{1234567.891, 2, numberStyleVN, "1.234.567,89"},
+		{1234567.891, 2, numberStyleIntl, "1,234,567.89"},
+		{25432, 0, numberStyleVN, "25.432"},
+		{999, 0, numberStyleIntl, "999"},
+		{1000, 0, numberStyleIntl, "1,000"},
+		{0.000123, 6, numberStyleVN, "0,000123"},
+		{-1234.5, 1, numberStyleVN, "-1.234,5"},
+		// Rounding to zero drops the sign
+		{-0.001, 2, numberStyleIntl, "0.00"},
+		{0, 0, numberStyleVN, "0"},
+		// Unknown styles fall back to Vietnamese separators
+		{1234.5, 1, "", "1.234,5"},
+	}
+	for _, tt := range tests {
+		if got := formatNumber(tt.val, tt.decimals, tt.style); got != tt.want {
+			t.Errorf("formatNumber(%v, %d, %q) = %q, want %q", tt.val, tt.decimals, tt.style, got, tt.want)
+		}
+	}
+	if got := formatVnd(25432.6); got != "25.433" {
+		t.Errorf("formatVnd = %q", got)
+	}
+}
+
+func TestAmountDecimals(t *testing.T) {
+	tests := []struct {
+		v    float64
+		code string
+		want int
+	}{
+		{2543260, "VND", 0},
+		{0.5, "VND", 0},
+		{0.0123, "BTC", 6},
+		{100, "USD", 2},
+	}
+	for _, tt := range tests {
+		if got := amountDecimals(tt.v, tt.code); got != tt.want {
+			t.Errorf("amountDecimals(%v, %s) = %d, want %d", tt.v, tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestConvertReply(t *testing.T) {
+	withDatabase(t, false, nil)
+	f := usdVndChain(t)
+	setUsdVndCache(25000, clock())
+	f.set(twelveDataHost, jsonResponse(`{"symbol":"BTC/USD","close":"60000","percent_change":"1"}`))
+	tests := []struct {
+		payload, want string
+	}{
+		{"100 USD VND", "💱 100,00 USD ≈ **2.500.000 VND**"},
+		{"0.5 btc vnd", "💱 0,500000 BTC ≈ **750.000.000 VND**"},
+		{"1,000 USD USD", "💱 1.000,00 USD ≈ **1.000,00 USD**"},
+		{"abc USD VND", "⚠️ Số tiền không hợp lệ."},
+		{"-5 USD VND", "⚠️ Số tiền không hợp lệ."},
+		{"100 USD", "ℹ️ Cú pháp: `/convert 100 USD VND` hoặc `/convert 0.5 BTC VND`"},
+	}
+	for _, tt := range tests {
+		if got := convertReply(1, tt.payload); got != tt.want {
+			t.Errorf("convertReply(%q) = %q, want %q", tt.payload, got, tt.want)
+		}
+	}
+}
+
+func TestFormatReplyWithoutDatabase(t *testing.T) {
+	withDatabase(t, false, nil)
+	if got := formatReply(1, "intl"); got != "ℹ️ Bạn cần đăng ký bằng /start trước." {
+		t.Errorf("formatReply without a subscription = %q", got)
+	}
+	if got := formatReply(1, "us"); got != "ℹ️ Cú pháp: /format vn (1.234.567,89) hoặc /format intl (1,234,567.89)" {
+		t.Errorf("formatReply(us) = %q", got)
+	}
+	if got := getNumberStyle(1); got != numberStyleVN {
+		t.Errorf("default number style = %q", got)
+	}
+}
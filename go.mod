@@ -7,6 +7,7 @@ require (
 	github.com/joho/godotenv v1.5.1
 	github.com/mmcdole/gofeed v1.3.0
 	go.mongodb.org/mongo-driver v1.17.6
+	golang.org/x/image v0.18.0
 	gopkg.in/telebot.v3 v3.3.8
 )
 
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// Bounds for /history csv N
+const (
+	defaultHistoryCSVCount = 30
+	maxHistoryCSVCount     = 365
+)
+
+// Historical closes never change, so they are cached for the lifetime of the container
+var (
+	historyCacheMu sync.Mutex
+	historyCache   = make(map[string]SeriesPoint)
+)
+
+// Analysis windows end today, so their series are cached per day
+var (
+	seriesCacheMu sync.Mutex
+	seriesCache   = make(map[string][]SeriesPoint)
+)
+
+// earliestHistoryDate is the oldest date we ask Twelve Data about; older requests are refused
+var earliestHistoryDate = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// SeriesPoint is one daily bar from the /time_series endpoint
+type SeriesPoint struct {
+	Date  time.Time
+	Close float64
+}
+
+// --- TIME SERIES LOGIC ---
+
+// getTimeSeries fetches daily closes for symbol between start and end (inclusive), oldest first
+func getTimeSeries(symbol string, apiKey string, start, end time.Time) ([]SeriesPoint, error) {
+	log.Printf("[API] Fetching time series for %s (%s → %s)...", symbol, start.Format("2006-01-02"), end.Format("2006-01-02"))
+	apiUrl := twelveDataURL("time_series", url.Values{
+		"symbol":     {twelveDataSymbol(symbol)},
+		"interval":   {"1day"},
+		"start_date": {start.Format("2006-01-02")},
+		"end_date":   {end.Format("2006-01-02")},
+		"apikey":     {apiKey},
+	})
+	countAPICall("time_series")
+	body, err := fetchBody(context.Background(), apiUrl, 15*time.Second, jsonContentTypes)
+	if err != nil {
+		log.Printf("[API ERROR] Time series request failed for %s: %v", symbol, err)
+		return nil, err
+	}
+
+	var result struct {
+		Values []struct {
+			Datetime string    `json:"datetime"`
+			Close    FlexFloat `json:"close"`
+		} `json:"values"`
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	if result.Status == "error" {
+		log.Printf("[API ERROR] Message from TwelveData for %s: %s", symbol, result.Message)
+		return nil, fmt.Errorf("twelvedata: %s", result.Message)
+	}
+
+	points := make([]SeriesPoint, 0, len(result.Values))
+	for _, v := range result.Values {
+		// Daily bars come as "2006-01-02"; keep only the date part if a time is attached
+		d, err := time.Parse("2006-01-02", strings.SplitN(v.Datetime, " ", 2)[0])
+		if err != nil {
+			continue
+		}
+		c := v.Close.Value
+		if c == 0 {
+			continue
+		}
+		points = append(points, SeriesPoint{Date: d, Close: c})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Date.Before(points[j].Date) })
+	return points, nil
+}
+
+// getRecentSeries returns the last days of daily closes for symbol, cached until the
+// (Vietnam-time) day changes
+func getRecentSeries(symbol string, apiKey string, days int) ([]SeriesPoint, error) {
+	end := clock()
+	key := fmt.Sprintf("%s|%d|%s", symbol, days, end.In(vnLocation).Format("2006-01-02"))
+	seriesCacheMu.Lock()
+	if points, ok := seriesCache[key]; ok {
+		seriesCacheMu.Unlock()
+		log.Printf("[CACHE] Using cached series for %s", key)
+		countCache(true)
+		return points, nil
+	}
+	seriesCacheMu.Unlock()
+	countCache(false)
+
+	points, err := getTimeSeries(symbol, apiKey, end.AddDate(0, 0, -days), end)
+	if err != nil {
+		return nil, err
+	}
+	seriesCacheMu.Lock()
+	seriesCache[key] = points
+	seriesCacheMu.Unlock()
+	return points, nil
+}
+
+// getHistoricalClose returns the close on date, or on the nearest earlier trading day
+// when the market was shut (weekends, holidays)
+func getHistoricalClose(symbol string, apiKey string, date time.Time) (SeriesPoint, error) {
+	key := symbol + "|" + date.Format("2006-01-02")
+	historyCacheMu.Lock()
+	if p, ok := historyCache[key]; ok {
+		historyCacheMu.Unlock()
+		log.Printf("[CACHE] Using cached close for %s", key)
+		countCache(true)
+		return p, nil
+	}
+	historyCacheMu.Unlock()
+	countCache(false)
+
+	// Look back a week so a weekend or holiday still resolves to the previous session
+	points, err := getTimeSeries(symbol, apiKey, date.AddDate(0, 0, -7), date)
+	if err != nil {
+		return SeriesPoint{}, err
+	}
+	if len(points) == 0 {
+		return SeriesPoint{}, fmt.Errorf("no data")
+	}
+	p := points[len(points)-1]
+
+	historyCacheMu.Lock()
+	historyCache[key] = p
+	historyCacheMu.Unlock()
+	return p, nil
+}
+
+// usdVndOnDateReply builds the reply for "/usdvnd YYYY-MM-DD"
+func usdVndOnDateReply(arg string) string {
+	arg = strings.TrimSpace(arg)
+	if arg == "" {
+		return "ℹ️ Cú pháp: `/usdvnd 2024-06-01` (định dạng YYYY-MM-DD)."
+	}
+	date, err := time.Parse("2006-01-02", arg)
+	if err != nil {
+		return "⚠️ Ngày không hợp lệ. Vui lòng dùng định dạng YYYY-MM-DD, ví dụ `/usdvnd 2024-06-01`."
+	}
+	if date.After(clock()) {
+		return "⚠️ Không thể tra cứu tỷ giá của một ngày trong tương lai."
+	}
+	if date.Before(earliestHistoryDate) {
+		return fmt.Sprintf("⚠️ Chỉ hỗ trợ tra cứu từ ngày %s trở đi.", earliestHistoryDate.Format("02/01/2006"))
+	}
+
+	p, err := getHistoricalClose("usdvnd", os.Getenv("TWELVE_DATA_API_KEY"), date)
+	if err != nil {
+		return fmt.Sprintf("⚠️ Không có dữ liệu tỷ giá USD/VND cho ngày %s.", date.Format("02/01/2006"))
+	}
+	msg := fmt.Sprintf("💵 **Tỷ giá USD/VND ngày %s**\n1$ ≈ **%s VNĐ**", date.Format("02/01/2006"), formatVnd(p.Close))
+	if !p.Date.Equal(date) {
+		msg += fmt.Sprintf("\n_(Thị trường nghỉ, dùng phiên gần nhất %s)_", p.Date.Format("02/01/2006"))
+	}
+	return msg
+}
+
+// --- BROADCAST HISTORY EXPORT ---
+
+// parseHistoryCSVArgs parses "csv [N]" into a snapshot count
+func parseHistoryCSVArgs(payload string) (int, error) {
+	args := strings.Fields(payload)
+	if len(args) == 0 || len(args) > 2 || strings.ToLower(args[0]) != "csv" {
+		return 0, fmt.Errorf("usage")
+	}
+	if len(args) == 1 {
+		return defaultHistoryCSVCount, nil
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n < 1 || n > maxHistoryCSVCount {
+		return 0, fmt.Errorf("invalid count")
+	}
+	return n, nil
+}
+
+// writeSnapshotsCSV streams the last n broadcast snapshots, oldest first, as
+// timestamp,symbol,price,change rows straight from the cursor
+func writeSnapshotsCSV(w io.Writer, n int) error {
+	if snapshotCollection == nil {
+		return fmt.Errorf("database unavailable")
+	}
+	total, err := snapshotCollection.CountDocuments(context.TODO(), broadcastSnapshotsFilter())
+	if err != nil {
+		return err
+	}
+	skip := total - int64(n)
+	if skip < 0 {
+		skip = 0
+	}
+	cursor, err := snapshotCollection.Find(context.TODO(), broadcastSnapshotsFilter(),
+		options.Find().SetSort(bson.D{{Key: "at", Value: 1}}).SetSkip(skip))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.TODO())
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"timestamp", "symbol", "price", "change"})
+	for cursor.Next(context.TODO()) {
+		var snap Snapshot
+		if err := cursor.Decode(&snap); err != nil {
+			log.Printf("[DATABASE WARNING] Skipping undecodable snapshot: %v", err)
+			continue
+		}
+		symbols := make([]string, 0, len(snap.Prices))
+		for symbol := range snap.Prices {
+			symbols = append(symbols, symbol)
+		}
+		sort.Strings(symbols)
+		for _, symbol := range symbols {
+			change := ""
+			if c, ok := snap.Changes[symbol]; ok {
+				change = strconv.FormatFloat(c, 'f', 2, 64)
+			}
+			cw.Write([]string{snap.At.UTC().Format(time.RFC3339), symbol,
+				strconv.FormatFloat(snap.Prices[symbol], 'f', -1, 64), change})
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// sendHistoryCSV handles "/history csv N": the CSV is piped into the upload as it is
+// written, so the export never sits in memory whole
+func sendHistoryCSV(b *tele.Bot, chat *tele.Chat, payload string) error {
+	n, err := parseHistoryCSVArgs(payload)
+	if err != nil {
+		_, err = b.Send(chat, fmt.Sprintf("ℹ️ Cú pháp: `/history csv 30` (tối đa %d bản tin gần nhất)", maxHistoryCSVCount),
+			&tele.SendOptions{ParseMode: tele.ModeMarkdown})
+		return err
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeSnapshotsCSV(pw, n))
+	}()
+	doc := &tele.Document{
+		File:     tele.FromReader(pr),
+		FileName: fmt.Sprintf("broadcasts_%s.csv", clock().In(vnLocation).Format("20060102")),
+		Caption:  fmt.Sprintf("📄 %d bản tin gần nhất", n),
+	}
+	_, err = b.Send(chat, doc)
+	pr.Close()
+	if err != nil {
+		log.Printf("[HISTORY ERROR] Failed to send CSV to %d: %v", chat.ID, err)
+		_, err = b.Send(chat, "⚠️ Không thể xuất dữ liệu lúc này.")
+	}
+	return err
+}
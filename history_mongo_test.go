@@ -0,0 +1,45 @@
+//go:build mongo
+
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSnapshotsCSVSkipsBackfill checks that /history csv exports only broadcast snapshots:
+//
+//	MONGODB_TEST_URI=mongodb://localhost:27017 go test -tags mongo -run SnapshotsCSV
+func TestSnapshotsCSVSkipsBackfill(t *testing.T) {
+	db := mongoTestDB(t)
+	saved := snapshotCollection
+	snapshotCollection = db.Collection("snapshots")
+	t.Cleanup(func() { snapshotCollection = saved })
+
+	start := time.Date(2026, 3, 2, 1, 0, 0, 0, time.UTC)
+	var docs []interface{}
+	for i := range 3 {
+		// Each broadcast is followed by a later backfilled close, so counting backfill rows
+		// would shift the window past the broadcasts
+		docs = append(docs,
+			Snapshot{At: start.Add(time.Duration(i) * 24 * time.Hour), Prices: map[string]float64{"btc": 60000}},
+			Snapshot{At: start.Add(time.Duration(i)*24*time.Hour + time.Hour), Prices: map[string]float64{"btc": 1},
+				Source: snapshotSourceBackfill})
+	}
+	if _, err := snapshotCollection.InsertMany(context.Background(), docs); err != nil {
+		t.Fatal(err)
+	}
+
+	var sb strings.Builder
+	if err := writeSnapshotsCSV(&sb, 2); err != nil {
+		t.Fatal(err)
+	}
+	want := "timestamp,symbol,price,change\n" +
+		"2026-03-03T01:00:00Z,btc,60000,\n" +
+		"2026-03-04T01:00:00Z,btc,60000,\n"
+	if got := sb.String(); got != want {
+		t.Errorf("csv =\n%s\nwant\n%s", got, want)
+	}
+}
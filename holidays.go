@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	_ "time/tzdata" // America/New_York must resolve in the Lambda image too
+)
+
+// Holiday calendars: US market holidays and Vietnamese public holidays
+const (
+	holidayCalendarUS = "us"
+	holidayCalendarVN = "vn"
+)
+
+// What the scheduled broadcast does on a holiday (HOLIDAY_EDITION): the usual report with
+// the affected lines annotated, a quotes-only report, or nothing
+const (
+	holidayEditionFull = "full"
+	holidayEditionSlim = "slim"
+	holidayEditionSkip = "skip"
+)
+
+// holidayQuoteNote marks a quote whose market is closed for a holiday
+const holidayQuoteNote = " · 🏖 nghỉ lễ, dữ liệu phiên trước"
+
+// Holiday is one closed day of a calendar. Date is the day in the calendar's own
+// timezone (New York for "us", Vietnam for "vn"). Off in a settings entry removes a
+// built-in day instead of adding one.
+type Holiday struct {
+	Calendar string `bson:"calendar"`
+	Date     string `bson:"date"`
+	Name     string `bson:"name"`
+	Off      bool   `bson:"off,omitempty"`
+}
+
+// usMarketLocation is where US market days begin and end
+var usMarketLocation = mustLoadLocation("America/New_York")
+
+// builtinHolidays is the embedded calendar. US days are the NYSE's closures. VN days are
+// the official days off; the 2027 ones follow the Labour Code's pattern and are corrected
+// through the settings document once the government publishes the schedule.
+var builtinHolidays = concatHolidays(
+	usHolidays("New Year's Day", "2025-01-01", "2026-01-01", "2027-01-01"),
+	usHolidays("National Day of Mourning", "2025-01-09"),
+	usHolidays("Martin Luther King Jr. Day", "2025-01-20", "2026-01-19", "2027-01-18"),
+	usHolidays("Presidents' Day", "2025-02-17", "2026-02-16", "2027-02-15"),
+	usHolidays("Good Friday", "2025-04-18", "2026-04-03", "2027-03-26"),
+	usHolidays("Memorial Day", "2025-05-26", "2026-05-25", "2027-05-31"),
+	usHolidays("Juneteenth", "2025-06-19", "2026-06-19", "2027-06-18"),
+	usHolidays("Independence Day", "2025-07-04", "2026-07-03", "2027-07-05"),
+	usHolidays("Labor Day", "2025-09-01", "2026-09-07", "2027-09-06"),
+	usHolidays("Thanksgiving", "2025-11-27", "2026-11-26", "2027-11-25"),
+	usHolidays("Christmas", "2025-12-25", "2026-12-25", "2027-12-24"),
+
+	vnHolidays("Tết Dương lịch", "2025-01-01", "2026-01-01", "2027-01-01"),
+	vnHolidayRange("Tết Nguyên Đán", "2025-01-25", "2025-02-02"),
+	vnHolidayRange("Tết Nguyên Đán", "2026-02-14", "2026-02-22"),
+	vnHolidayRange("Tết Nguyên Đán", "2027-02-04", "2027-02-10"),
+	vnHolidays("Giỗ Tổ Hùng Vương", "2025-04-07", "2026-04-27", "2027-04-16"),
+	vnHolidays("Ngày Thống nhất", "2025-04-30", "2026-04-30", "2027-04-30"),
+	vnHolidays("Quốc tế Lao động", "2025-05-01", "2025-05-02", "2026-05-01", "2027-05-03"),
+	vnHolidays("Quốc khánh", "2025-09-01", "2025-09-02", "2026-09-01", "2026-09-02", "2027-09-02", "2027-09-03"),
+)
+
+// --- HOLIDAY CALENDAR ---
+
+// mustLoadLocation loads a zone from the embedded tz database
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(fmt.Sprintf("timezone %s: %v", name, err))
+	}
+	return loc
+}
+
+func usHolidays(name string, dates ...string) []Holiday {
+	return calendarHolidays(holidayCalendarUS, name, dates)
+}
+
+func vnHolidays(name string, dates ...string) []Holiday {
+	return calendarHolidays(holidayCalendarVN, name, dates)
+}
+
+func calendarHolidays(calendar, name string, dates []string) []Holiday {
+	days := make([]Holiday, len(dates))
+	for i, d := range dates {
+		days[i] = Holiday{Calendar: calendar, Date: d, Name: name}
+	}
+	return days
+}
+
+// vnHolidayRange lists every day from first to last inclusive, for Tết's run of days off
+func vnHolidayRange(name, first, last string) []Holiday {
+	from, _ := time.Parse("2006-01-02", first)
+	to, _ := time.Parse("2006-01-02", last)
+	var dates []string
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		dates = append(dates, d.Format("2006-01-02"))
+	}
+	return vnHolidays(name, dates...)
+}
+
+func concatHolidays(lists ...[]Holiday) []Holiday {
+	var all []Holiday
+	for _, l := range lists {
+		all = append(all, l...)
+	}
+	return all
+}
+
+// validHoliday reports whether a settings entry names a known calendar and a real date
+func validHoliday(h Holiday) bool {
+	if h.Calendar != holidayCalendarUS && h.Calendar != holidayCalendarVN {
+		return false
+	}
+	_, err := time.Parse("2006-01-02", h.Date)
+	return err == nil
+}
+
+// holidayLocation is the timezone a calendar's dates are in
+func holidayLocation(calendar string) *time.Location {
+	if calendar == holidayCalendarUS {
+		return usMarketLocation
+	}
+	return vnLocation
+}
+
+// holidayOn returns the calendar's holiday on the day containing t, read in the
+// calendar's own timezone: 08:00 in Vietnam on 5 July is still 4 July in New York. Settings
+// entries take precedence over the built-in ones, and an Off entry cancels the day.
+func holidayOn(calendar string, t time.Time, extra []Holiday) (Holiday, bool) {
+	date := t.In(holidayLocation(calendar)).Format("2006-01-02")
+	for _, list := range [][]Holiday{extra, builtinHolidays} {
+		for _, h := range list {
+			if h.Calendar == calendar && h.Date == date {
+				return h, !h.Off
+			}
+		}
+	}
+	return Holiday{}, false
+}
+
+// activeHolidays returns the holidays in effect at t, by calendar
+func activeHolidays(t time.Time, extra []Holiday) map[string]Holiday {
+	active := make(map[string]Holiday)
+	for _, calendar := range []string{holidayCalendarUS, holidayCalendarVN} {
+		if h, ok := holidayOn(calendar, t, extra); ok {
+			active[calendar] = h
+		}
+	}
+	return active
+}
+
+// holidayCalendar is the calendar whose holidays close a symbol's market: Vietnam's for
+// USD/VND, none for crypto and other currency pairs (traded through holidays), the US
+// one for everything else (metals, indices, stocks)
+func holidayCalendar(symbol string) string {
+	switch {
+	case symbol == "usdvnd":
+		return holidayCalendarVN
+	case isPair(symbol), symbol == "btc", symbol == "eth", symbol == "sol":
+		return ""
+	}
+	return holidayCalendarUS
+}
+
+// holidayBanner names the holidays in effect, e.g. "🏖 Nghỉ lễ: Independence Day (Mỹ)"
+func holidayBanner(active map[string]Holiday) string {
+	var names []string
+	for calendar, h := range active {
+		where := "Mỹ"
+		if calendar == holidayCalendarVN {
+			where = "VN"
+		}
+		names = append(names, fmt.Sprintf("%s (%s)", h.Name, where))
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	return "🏖 *Nghỉ lễ:* " + strings.Join(names, " · ")
+}
+
+// holidayEdition returns what the scheduled broadcast does at t: "" on a normal day, else
+// HOLIDAY_EDITION (full by default). A holiday only counts when it touches the report: the
+// USD/VND line always, the other calendar only through one of the report's symbols.
+func holidayEdition(t time.Time, cfg Config) string {
+	active := activeHolidays(t, cfg.Holidays)
+	_, affected := active[holidayCalendarVN]
+	for _, symbol := range cfg.Symbols {
+		if _, ok := active[holidayCalendar(symbol)]; ok {
+			affected = true
+		}
+	}
+	if !affected {
+		return ""
+	}
+	switch mode := strings.ToLower(os.Getenv("HOLIDAY_EDITION")); mode {
+	case holidayEditionSlim, holidayEditionSkip:
+		return mode
+	case "", holidayEditionFull:
+	default:
+		log.Printf("[HOLIDAY] Unknown HOLIDAY_EDITION %q, sending the full report", mode)
+	}
+	return holidayEditionFull
+}
+
+// slimHolidayReport drops the news from a holiday report, leaving the annotated quotes
+func slimHolidayReport(r MarketReport, symbols []string) MarketReport {
+	r.Text = r.CardsText
+	r.Headlines = nil
+	r.Variants = nil
+	r.Menu = reportMenu("", newsLangVI, false)
+	r.Plain = renderPlainReport(activeProfile().Title, r.At, symbols, r.Quotes, r.UsdVnd, nil)
+	if banner := holidayBanner(activeHolidays(r.At, loadConfig().Holidays)); banner != "" {
+		r.Plain = stripMarkdown(banner) + "\n\n" + r.Plain
+	}
+	return r
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// ictMorning is 08:00 Vietnam time on date, when the morning report goes out
+func ictMorning(date string) time.Time {
+	d, _ := time.ParseInLocation("2006-01-02", date, vnLocation)
+	return d.Add(8 * time.Hour)
+}
+
+func TestHolidayOn(t *testing.T) {
+	tests := []struct {
+		calendar string
+		date     string
+		want     string
+	}{
+		// 08:00 ICT on 2 January is still 1 January in New York
+		{holidayCalendarUS, "2026-01-02", "New Year's Day"},
+		{holidayCalendarVN, "2026-01-02", ""},
+		// Independence Day 2027 is observed on Monday 5 July: at 08:00 ICT that morning New
+		// York is still on Sunday the 4th, and the closure shows the next morning
+		{holidayCalendarUS, "2027-07-05", ""},
+		{holidayCalendarUS, "2027-07-06", "Independence Day"},
+		// The last day of Tết 2026 and the first working day after it
+		{holidayCalendarVN, "2026-02-22", "Tết Nguyên Đán"},
+		{holidayCalendarVN, "2026-02-23", ""},
+		{holidayCalendarUS, "2026-02-23", ""},
+	}
+	for _, tt := range tests {
+		h, ok := holidayOn(tt.calendar, ictMorning(tt.date), nil)
+		if got := map[bool]string{true: h.Name}[ok]; got != tt.want {
+			t.Errorf("holidayOn(%s, %s 08:00 ICT) = %q, want %q", tt.calendar, tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestHolidayOnSettingsOverride(t *testing.T) {
+	extra := []Holiday{
+		{Calendar: holidayCalendarVN, Date: "2026-02-22", Off: true},
+		{Calendar: holidayCalendarVN, Date: "2026-02-23", Name: "Nghỉ bù"},
+	}
+	if _, ok := holidayOn(holidayCalendarVN, ictMorning("2026-02-22"), extra); ok {
+		t.Error("an Off entry didn't cancel the built-in day")
+	}
+	if h, ok := holidayOn(holidayCalendarVN, ictMorning("2026-02-23"), extra); !ok || h.Name != "Nghỉ bù" {
+		t.Errorf("added day = %+v, %v", h, ok)
+	}
+}
+
+func TestHolidayCalendar(t *testing.T) {
+	for symbol, want := range map[string]string{
+		"usdvnd": holidayCalendarVN,
+		"btc":    "",
+		"eurusd": "",
+		"gold":   holidayCalendarUS,
+		"aapl":   holidayCalendarUS,
+	} {
+		if got := holidayCalendar(symbol); got != want {
+			t.Errorf("holidayCalendar(%q) = %q, want %q", symbol, got, want)
+		}
+	}
+}
+
+func TestHolidayEdition(t *testing.T) {
+	crypto := Config{Symbols: []string{"btc", "eth"}}
+	stocks := Config{Symbols: []string{"btc", "gold"}}
+	t.Setenv("HOLIDAY_EDITION", "")
+	if got := holidayEdition(ictMorning("2026-01-02"), crypto); got != "" {
+		t.Errorf("US holiday with crypto-only symbols = %q, want none", got)
+	}
+	if got := holidayEdition(ictMorning("2026-01-02"), stocks); got != holidayEditionFull {
+		t.Errorf("US holiday with gold = %q, want full", got)
+	}
+	// USD/VND is always in the report, so a Vietnamese holiday always counts
+	if got := holidayEdition(ictMorning("2026-02-22"), crypto); got != holidayEditionFull {
+		t.Errorf("Tết with crypto-only symbols = %q, want full", got)
+	}
+	if got := holidayEdition(ictMorning("2026-02-23"), stocks); got != "" {
+		t.Errorf("normal day = %q, want none", got)
+	}
+	t.Setenv("HOLIDAY_EDITION", "SKIP")
+	if got := holidayEdition(ictMorning("2026-02-22"), crypto); got != holidayEditionSkip {
+		t.Errorf("HOLIDAY_EDITION=SKIP gives %q", got)
+	}
+	t.Setenv("HOLIDAY_EDITION", "weird")
+	if got := holidayEdition(ictMorning("2026-02-22"), crypto); got != holidayEditionFull {
+		t.Errorf("unknown HOLIDAY_EDITION gives %q, want full", got)
+	}
+}
+
+func TestValidHoliday(t *testing.T) {
+	for h, want := range map[Holiday]bool{
+		{Calendar: holidayCalendarUS, Date: "2026-07-03"}: true,
+		{Calendar: holidayCalendarVN, Date: "2026-02-30"}: false,
+		{Calendar: "uk", Date: "2026-12-26"}:              false,
+		{Calendar: holidayCalendarVN, Date: "03/07/2026"}: false,
+	} {
+		if got := validHoliday(h); got != want {
+			t.Errorf("validHoliday(%+v) = %v, want %v", h, got, want)
+		}
+	}
+}
+
+func TestHolidayBanner(t *testing.T) {
+	active := map[string]Holiday{
+		holidayCalendarVN: {Name: "Tết Dương lịch"},
+		holidayCalendarUS: {Name: "New Year's Day"},
+	}
+	want := "🏖 *Nghỉ lễ:* New Year's Day (Mỹ) · Tết Dương lịch (VN)"
+	if got := holidayBanner(active); got != want {
+		t.Errorf("holidayBanner = %q, want %q", got, want)
+	}
+	if got := holidayBanner(nil); got != "" {
+		t.Errorf("holidayBanner(nil) = %q", got)
+	}
+}
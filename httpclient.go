@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultMaxBodyBytes caps any outbound response we read into memory (override: HTTP_MAX_BODY_BYTES)
+const defaultMaxBodyBytes = 1 << 20
+
+// Content types accepted by each kind of call site
+var (
+	jsonContentTypes = []string{"application/json"}
+	textContentTypes = []string{"text/plain", "text/html"}
+	feedContentTypes = []string{"application/rss+xml", "application/atom+xml", "application/xml", "text/xml"}
+)
+
+// errResponseTooLarge is returned when a body exceeds the configured size limit
+var errResponseTooLarge = errors.New("response too large")
+
+// HTTPStatusError carries a non-2xx status so callers can react to e.g. 429
+type HTTPStatusError struct {
+	StatusCode int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("unexpected HTTP status %d", e.StatusCode)
+}
+
+// twelveDataBaseURL is the root of every Twelve Data endpoint
+const twelveDataBaseURL = "https://api.twelvedata.com/"
+
+// --- HTTP FETCH HELPER ---
+
+// twelveDataURL builds a Twelve Data URL for endpoint ("quote", "time_series",
+// "symbol_search", ...). Values are query-escaped like url.Values.Encode, except that the
+// slash of a pair symbol stays literal ("symbol=XAU/USD", not "XAU%2FUSD"): that is the
+// form Twelve Data documents, and a slash is legal in a query string anyway. The same
+// goes for the commas of a batch ("symbol=XAU/USD,BTC/USD").
+func twelveDataURL(endpoint string, params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range params[k] {
+			escaped := url.QueryEscape(v)
+			if k == "symbol" {
+				escaped = strings.ReplaceAll(escaped, "%2F", "/")
+				// Batch quotes separate symbols with literal commas too
+				escaped = strings.ReplaceAll(escaped, "%2C", ",")
+			}
+			parts = append(parts, url.QueryEscape(k)+"="+escaped)
+		}
+	}
+	return twelveDataBaseURL + endpoint + "?" + strings.Join(parts, "&")
+}
+
+// fetchBody GETs rawURL with the given timeout and returns the body, refusing responses
+// larger than the size limit or whose Content-Type isn't one of contentTypes. The bytes
+// read are added to ctx's trace span, if it carries one.
+func fetchBody(ctx context.Context, rawURL string, timeout time.Duration, contentTypes []string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode}
+	}
+	if err := checkContentType(resp.Header.Get("Content-Type"), contentTypes); err != nil {
+		return nil, err
+	}
+
+	limit := int64(envInt("HTTP_MAX_BODY_BYTES", defaultMaxBodyBytes))
+	// Read one byte past the limit so an exactly-full body is distinguishable from a truncated one
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		log.Printf("[HTTP ERROR] %s exceeded %d bytes%s", req.URL.Host, limit, spanTag(ctx))
+		return nil, errResponseTooLarge
+	}
+	if s := spanFrom(ctx); s != nil {
+		s.bytes.Add(int64(len(body)))
+	}
+	log.Printf("[HTTP] %s %s read %d bytes%s", req.URL.Host, req.URL.Path, len(body), spanTag(ctx))
+	return body, nil
+}
+
+// checkContentType accepts header when its media type matches one of allowed
+func checkContentType(header string, allowed []string) error {
+	if len(allowed) == 0 {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return fmt.Errorf("unexpected content type %q", header)
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(mediaType, a) {
+			return nil
+		}
+	}
+	return fmt.Errorf("unexpected content type %q", mediaType)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// countMetrics starts an invocation record for the test and returns it
+func countMetrics(t *testing.T) *InvocationMetrics {
+	t.Helper()
+	beginMetrics("test")
+	metricsMu.Lock()
+	m := currentMetrics
+	metricsMu.Unlock()
+	t.Cleanup(func() {
+		metricsMu.Lock()
+		currentMetrics = nil
+		metricsMu.Unlock()
+	})
+	return m
+}
+
+func TestTwelveDataURL(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		params   url.Values
+		want     string
+	}{
+		{"quote", url.Values{"symbol": {"XAU/USD"}, "apikey": {"k"}},
+			"https://api.twelvedata.com/quote?apikey=k&symbol=XAU/USD"},
+		{"quote", url.Values{"symbol": {"XAU/USD,BTC/USD,AAPL"}},
+			"https://api.twelvedata.com/quote?symbol=XAU/USD,BTC/USD,AAPL"},
+		// Only the symbol keeps its slashes and commas; everything else is escaped
+		{"symbol_search", url.Values{"symbol": {"S&P 500"}, "apikey": {"a/b,c&d"}},
+			"https://api.twelvedata.com/symbol_search?apikey=a%2Fb%2Cc%26d&symbol=S%26P+500"},
+		{"quote", url.Values{"symbol": {"BRK.B?x=1#y"}},
+			"https://api.twelvedata.com/quote?symbol=BRK.B%3Fx%3D1%23y"},
+		{"api_usage", url.Values{}, "https://api.twelvedata.com/api_usage?"},
+	}
+	for _, tt := range tests {
+		got := twelveDataURL(tt.endpoint, tt.params)
+		if got != tt.want {
+			t.Errorf("twelveDataURL(%s, %v) = %s, want %s", tt.endpoint, tt.params, got, tt.want)
+		}
+		u, err := url.Parse(got)
+		if err != nil {
+			t.Errorf("%s does not parse: %v", got, err)
+			continue
+		}
+		if sym := tt.params.Get("symbol"); u.Query().Get("symbol") != sym {
+			t.Errorf("%s decodes to symbol %q, want %q", got, u.Query().Get("symbol"), sym)
+		}
+	}
+}
+
+func TestAPICallsCountedWhenFetched(t *testing.T) {
+	m := countMetrics(t)
+	twelveDataURL("quote", url.Values{"symbol": {"XAU/USD"}})
+	if len(m.APICalls) != 0 {
+		t.Fatalf("building a URL counted %v", m.APICalls)
+	}
+	f := withFakeHTTP(t)
+	f.set(twelveDataHost, jsonResponse(`{"symbol":"XAU/USD","close":"3000","percent_change":"0.5"}`))
+	getMarketData("gold", "k")
+	if m.APICalls["quote"] != 1 || f.count(twelveDataHost) != 1 {
+		t.Errorf("one quote fetch counted %v, %d requests", m.APICalls, f.count(twelveDataHost))
+	}
+}
+
+func TestFetchBodyLimits(t *testing.T) {
+	t.Setenv("HTTP_MAX_BODY_BYTES", "16")
+	f := withFakeHTTP(t)
+	tests := []struct {
+		name string
+		resp fakeResponse
+		want error
+	}{
+		{"exactly at the limit", fakeResponse{Status: 200, ContentType: "application/json", Body: strings.Repeat("x", 16)}, nil},
+		{"one byte over", fakeResponse{Status: 200, ContentType: "application/json", Body: strings.Repeat("x", 17)}, errResponseTooLarge},
+		{"charset parameter", fakeResponse{Status: 200, ContentType: "Application/JSON; charset=utf-8", Body: "{}"}, nil},
+		{"mislabeled", fakeResponse{Status: 200, ContentType: "text/html", Body: "<html>"}, errors.New("unexpected content type")},
+		{"no content type", fakeResponse{Status: 200, Body: "{}"}, errors.New("unexpected content type")},
+		{"status", fakeResponse{Status: http.StatusTooManyRequests, ContentType: "application/json"}, &HTTPStatusError{StatusCode: 429}},
+	}
+	for _, tt := range tests {
+		f.set("limits.example", tt.resp)
+		body, err := fetchBody(context.Background(), "https://limits.example/", 0, jsonContentTypes)
+		switch {
+		case tt.want == nil && (err != nil || string(body) != tt.resp.Body):
+			t.Errorf("%s: %q, %v", tt.name, body, err)
+		case tt.want != nil && (err == nil || !strings.Contains(err.Error(), tt.want.Error())):
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
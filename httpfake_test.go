@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeResponse is what a fakeHTTP host answers; Err fails the request instead
+type fakeResponse struct {
+	Status      int
+	ContentType string
+	Body        string
+	Err         error
+}
+
+// fakeHTTP answers outbound requests by host, counting them. Hosts without a response
+// fail, so a test never reaches the network.
+type fakeHTTP struct {
+	mu        sync.Mutex
+	responses map[string]fakeResponse
+	hits      map[string]int
+}
+
+func (f *fakeHTTP) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	f.hits[req.URL.Host]++
+	r, ok := f.responses[req.URL.Host]
+	f.mu.Unlock()
+	if !ok {
+		return nil, errors.New("no fake response for " + req.URL.Host)
+	}
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return &http.Response{
+		StatusCode: r.Status,
+		Header:     http.Header{"Content-Type": []string{r.ContentType}},
+		Body:       io.NopCloser(strings.NewReader(r.Body)),
+		Request:    req,
+	}, nil
+}
+
+// set replaces a host's response
+func (f *fakeHTTP) set(host string, r fakeResponse) {
+	f.mu.Lock()
+	f.responses[host] = r
+	f.mu.Unlock()
+}
+
+// count is how many requests a host got
+func (f *fakeHTTP) count(host string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.hits[host]
+}
+
+// withFakeHTTP routes http.DefaultTransport, which fetchBody's clients use, to a fakeHTTP
+// for the rest of the test
+func withFakeHTTP(t *testing.T) *fakeHTTP {
+	t.Helper()
+	f := &fakeHTTP{responses: map[string]fakeResponse{}, hits: map[string]int{}}
+	saved := http.DefaultTransport
+	http.DefaultTransport = f
+	t.Cleanup(func() { http.DefaultTransport = saved })
+	return f
+}
+
+// jsonResponse is a 200 application/json answer
+func jsonResponse(body string) fakeResponse {
+	return fakeResponse{Status: http.StatusOK, ContentType: "application/json", Body: body}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	tele "gopkg.in/telebot.v3"
+)
+
+// Report card size: the 1.91:1 link-preview shape Facebook and most feeds show uncropped
+const (
+	cardWidth  = 1200
+	cardHeight = 630
+)
+
+// maxCardRows is how many quotes fit on a card; further symbols are left off
+const maxCardRows = 8
+
+// minCardFontSize is as far as a long value shrinks before it is cut with "…"
+const minCardFontSize = 14
+
+// DejaVu Sans covers the Vietnamese letters (precomposed and combining) and the arrows; see
+// fonts/LICENSE-DejaVu.txt
+var (
+	//go:embed fonts/DejaVuSans.ttf
+	cardFontRegular []byte
+	//go:embed fonts/DejaVuSans-Bold.ttf
+	cardFontBold []byte
+)
+
+// Card colours
+var (
+	cardBackground = color.RGBA{0x10, 0x18, 0x2b, 0xff}
+	cardPanel      = color.RGBA{0x1a, 0x25, 0x3d, 0xff}
+	cardText       = color.RGBA{0xf1, 0xf5, 0xf9, 0xff}
+	cardMuted      = color.RGBA{0x94, 0xa3, 0xb8, 0xff}
+	cardUp         = color.RGBA{0x22, 0xc5, 0x5e, 0xff}
+	cardDown       = color.RGBA{0xef, 0x44, 0x44, 0xff}
+	cardAccent     = color.RGBA{0xf5, 0xb7, 0x31, 0xff}
+)
+
+// reportCard is what the PNG card shows: the report's market section, without the news
+type reportCard struct {
+	Title  string
+	At     time.Time
+	UsdVnd float64
+	Rows   []cardRow
+	Brand  string
+}
+
+// cardRow is one quote line; OK is false for a quote that couldn't be fetched
+type cardRow struct {
+	Label      string
+	Price      string
+	Percent    float64
+	HasPercent bool
+	OK         bool
+}
+
+var (
+	cardFontsOnce sync.Once
+	cardFonts     [2]*opentype.Font // regular, bold
+	cardFontsErr  error
+
+	cardFacesMu sync.Mutex
+	cardFaces   = map[cardFaceKey]font.Face{}
+)
+
+// cardFaceKey identifies a cached face
+type cardFaceKey struct {
+	bold bool
+	size float64
+}
+
+// --- REPORT CARD IMAGE ---
+
+// cardFace returns the embedded font at size, parsing the fonts on first use
+func cardFace(bold bool, size float64) (font.Face, error) {
+	cardFontsOnce.Do(func() {
+		for i, raw := range [][]byte{cardFontRegular, cardFontBold} {
+			if cardFonts[i], cardFontsErr = opentype.Parse(raw); cardFontsErr != nil {
+				return
+			}
+		}
+	})
+	if cardFontsErr != nil {
+		return nil, fmt.Errorf("card font: %w", cardFontsErr)
+	}
+	key := cardFaceKey{bold, size}
+	cardFacesMu.Lock()
+	defer cardFacesMu.Unlock()
+	if face, ok := cardFaces[key]; ok {
+		return face, nil
+	}
+	f := cardFonts[0]
+	if bold {
+		f = cardFonts[1]
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("card font face: %w", err)
+	}
+	cardFaces[key] = face
+	return face, nil
+}
+
+// cardSupports drops the runes the font has no glyph for (the labels' emoji), so they
+// don't render as boxes
+func cardSupports(face font.Face, s string) string {
+	kept := strings.Map(func(r rune) rune {
+		if _, ok := face.GlyphAdvance(r); !ok {
+			return -1
+		}
+		return r
+	}, s)
+	return strings.Join(strings.Fields(kept), " ")
+}
+
+// fitCardText returns s at the largest size up to size that fits in width, shrinking to
+// minCardFontSize and then cutting the end with "…", so a long number never overflows
+// into the next column
+func fitCardText(s string, bold bool, size float64, width int) (string, font.Face, error) {
+	limit := fixed.I(width)
+	for ; size >= minCardFontSize; size -= 2 {
+		face, err := cardFace(bold, size)
+		if err != nil {
+			return "", nil, err
+		}
+		s = cardSupports(face, s)
+		if font.MeasureString(face, s) <= limit {
+			return s, face, nil
+		}
+	}
+	face, err := cardFace(bold, minCardFontSize)
+	if err != nil {
+		return "", nil, err
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && font.MeasureString(face, string(runes)+"…") > limit {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…", face, nil
+}
+
+// drawCardText draws s with its baseline at y, starting at x, or ending at x when
+// alignRight is set, within width
+func drawCardText(dst draw.Image, s string, bold bool, size float64, x, y, width int, col color.Color, alignRight bool) error {
+	s, face, err := fitCardText(s, bold, size, width)
+	if err != nil {
+		return err
+	}
+	d := &font.Drawer{Dst: dst, Src: image.NewUniform(col), Face: face}
+	start := fixed.I(x)
+	if alignRight {
+		start -= d.MeasureString(s)
+	}
+	d.Dot = fixed.Point26_6{X: start, Y: fixed.I(y)}
+	d.DrawString(s)
+	return nil
+}
+
+// newReportCard takes the card's content from a built report, in the report's symbol order
+func newReportCard(report MarketReport, symbols []string, brand string) reportCard {
+	card := reportCard{Title: stripMarkdown(activeProfile().Title), At: report.At, UsdVnd: report.UsdVnd, Brand: brand}
+	for _, symbol := range symbols {
+		if len(card.Rows) == maxCardRows {
+			break
+		}
+		d, ok := report.Quotes[symbol]
+		if !ok {
+			continue
+		}
+		row := cardRow{Label: lookupAsset(symbol).Label, OK: d.Err == nil && d.Price > 0}
+		if row.OK {
+			row.Price = strings.Trim(fmt.Sprintf(lookupAsset(symbol).PriceFormat, d.Price), "`")
+			row.Percent, row.HasPercent = d.Percent, d.HasPercent
+		}
+		card.Rows = append(card.Rows, row)
+	}
+	return card
+}
+
+// render draws the card: title and date, the USD/VND rate, one row per quote with a
+// coloured change arrow, and the bot's name at the bottom
+func (c reportCard) render() (*image.RGBA, error) {
+	img := image.NewRGBA(image.Rect(0, 0, cardWidth, cardHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(cardBackground), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, 12, cardHeight), image.NewUniform(cardAccent), image.Point{}, draw.Src)
+
+	const left, right = 60, cardWidth - 60
+	if err := drawCardText(img, c.Title, true, 44, left, 82, right-left, cardText, false); err != nil {
+		return nil, err
+	}
+	date := formatDateTime(c.At, newsLangVI, vnLocation)
+	if err := drawCardText(img, date, false, 26, left, 128, 640, cardMuted, false); err != nil {
+		return nil, err
+	}
+	if c.UsdVnd > 0 {
+		rate := fmt.Sprintf("USD/VND ≈ %s VNĐ", formatVnd(c.UsdVnd))
+		if err := drawCardText(img, rate, false, 26, right, 128, 420, cardText, true); err != nil {
+			return nil, err
+		}
+	}
+
+	const top, bottom = 160, 560
+	draw.Draw(img, image.Rect(left-20, top, right+20, bottom), image.NewUniform(cardPanel), image.Point{}, draw.Src)
+	if len(c.Rows) > 0 {
+		rowHeight := min(72, (bottom-top-20)/len(c.Rows))
+		size := min(34, float64(rowHeight)*0.52)
+		for i, row := range c.Rows {
+			baseline := top + 10 + rowHeight*i + rowHeight/2 + int(size*0.35)
+			if err := drawCardText(img, row.Label, false, size, left, baseline, 500, cardText, false); err != nil {
+				return nil, err
+			}
+			price, change, changeColor := "n/a", "", cardMuted
+			if row.OK {
+				price = row.Price
+			}
+			if row.OK && row.HasPercent {
+				switch {
+				case row.Percent > 0:
+					change, changeColor = fmt.Sprintf("▲ +%.2f%%", row.Percent), cardUp
+				case row.Percent < 0:
+					change, changeColor = fmt.Sprintf("▼ %.2f%%", row.Percent), cardDown
+				default:
+					change = "■ 0.00%"
+				}
+			}
+			if err := drawCardText(img, price, true, size, 880, baseline, 300, cardText, true); err != nil {
+				return nil, err
+			}
+			if change != "" {
+				if err := drawCardText(img, change, true, size, right, baseline, 230, changeColor, true); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if c.Brand != "" {
+		if err := drawCardText(img, c.Brand, false, 22, left, 604, right-left, cardMuted, false); err != nil {
+			return nil, err
+		}
+	}
+	return img, nil
+}
+
+// reportCardPNG renders the report's market section as a PNG card
+func reportCardPNG(report MarketReport, symbols []string, brand string) ([]byte, error) {
+	img, err := newReportCard(report, symbols, brand).render()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// cardBrand is the footer naming the bot, e.g. "@market_bot · Nhịp đập thị trường"
+func cardBrand(b *tele.Bot) string {
+	if b.Me == nil || b.Me.Username == "" {
+		return "Nhịp đập thị trường"
+	}
+	return "@" + b.Me.Username + " · Nhịp đập thị trường"
+}
+
+// channelCardEnabled reports whether channel broadcasts get the PNG card after the text
+// (CHANNEL_IMAGE_CARD=true)
+func channelCardEnabled() bool {
+	return os.Getenv("CHANNEL_IMAGE_CARD") == "true"
+}
+
+// channelCard is a broadcast's card for channels: rendered once, uploaded with the first
+// post and sent by Telegram's file ID after that
+type channelCard struct {
+	png    []byte
+	fileID string
+}
+
+// newChannelCard renders the card when channel cards are on; nil otherwise or on failure
+func newChannelCard(b *tele.Bot, report MarketReport, symbols []string) *channelCard {
+	if !channelCardEnabled() {
+		return nil
+	}
+	raw, err := reportCardPNG(report, symbols, cardBrand(b))
+	if err != nil {
+		log.Printf("[BROADCAST ERROR] Failed to render the channel card: %v", err)
+		return nil
+	}
+	return &channelCard{png: raw}
+}
+
+// send posts the card to a channel, after its text report
+func (c *channelCard) send(b *tele.Bot, chatID int64, threadID int) {
+	photo := &tele.Photo{File: tele.FromReader(bytes.NewReader(c.png))}
+	if c.fileID != "" {
+		photo = &tele.Photo{File: tele.File{FileID: c.fileID}}
+	}
+	msg, err := deliver(b, chatID, threadID, photo, &tele.SendOptions{DisableNotification: true})
+	if err != nil {
+		log.Printf("[BROADCAST ERROR] Failed to send the card to %d: %v", chatID, err)
+		return
+	}
+	if c.fileID == "" && msg != nil && msg.Photo != nil {
+		c.fileID = msg.Photo.FileID
+	}
+}
+
+// imageReply handles the admin "/image": the current report's market section as a PNG
+// card, ready to post elsewhere
+func imageReply(r *Request) error {
+	report := buildMarketReport()
+	card, err := reportCardPNG(report, loadConfig().Symbols, cardBrand(r.Bot))
+	if err != nil {
+		return r.Reply(fmt.Sprintf("⚠️ Không thể tạo ảnh: %v", err))
+	}
+	photo := &tele.Photo{File: tele.FromReader(bytes.NewReader(card)),
+		Caption: "🖼 " + formatDateTime(report.At, newsLangVI, vnLocation)}
+	return r.Reply(photo)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"image"
+	"image/png"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden images in testdata")
+
+// maxCardHashDistance is how many of the 256 perceptual-hash bits may differ from the
+// golden card: enough for font rasterizer and hinting changes, far below a layout change
+const maxCardHashDistance = 20
+
+// cardHash is a 16×16 difference hash: the image is box-averaged to 17×16 grey cells and
+// each bit says whether a cell is brighter than its right neighbour
+func cardHash(img image.Image) [4]uint64 {
+	const w, h = 17, 16
+	b := img.Bounds()
+	var cells [h][w]float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/w, b.Min.X+(x+1)*b.Dx()/w
+			y0, y1 := b.Min.Y+y*b.Dy()/h, b.Min.Y+(y+1)*b.Dy()/h
+			var sum float64
+			for py := y0; py < y1; py++ {
+				for px := x0; px < x1; px++ {
+					r, g, bl, _ := img.At(px, py).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+				}
+			}
+			cells[y][x] = sum / float64((x1-x0)*(y1-y0))
+		}
+	}
+	var hash [4]uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			if cells[y][x] > cells[y][x+1] {
+				bit := y*(w-1) + x
+				hash[bit/64] |= 1 << (bit % 64)
+			}
+		}
+	}
+	return hash
+}
+
+func hashDistance(a, b [4]uint64) int {
+	d := 0
+	for i := range a {
+		d += bits.OnesCount64(a[i] ^ b[i])
+	}
+	return d
+}
+
+func testCard() reportCard {
+	return reportCard{
+		Title:  "💰 NHỊP ĐẬP THỊ TRƯỜNG",
+		At:     time.Date(2026, 2, 23, 8, 0, 0, 0, vnLocation),
+		UsdVnd: 25432,
+		Brand:  "@market_bot · Nhịp đập thị trường",
+		Rows: []cardRow{
+			{Label: "🟡 Vàng (XAUUSD)", Price: "$2345.67", Percent: 1.25, HasPercent: true, OK: true},
+			{Label: "₿ Bitcoin", Price: "$123456789012.34", Percent: -3.5, HasPercent: true, OK: true},
+			{Label: "🇪🇺 EURUSD", Price: "1.0842", HasPercent: true, OK: true},
+			{Label: "Cổ phiếu Hòa Phát — HPG", OK: false},
+		},
+	}
+}
+
+func TestReportCardMatchesGolden(t *testing.T) {
+	img, err := testCard().render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != cardWidth || b.Dy() != cardHeight {
+		t.Fatalf("card is %dx%d, want %dx%d", b.Dx(), b.Dy(), cardWidth, cardHeight)
+	}
+	golden := filepath.Join("testdata", "report_card.golden.png")
+	if *updateGolden {
+		f, err := os.Create(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := png.Encode(f, img); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	f, err := os.Open(golden)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("%s is missing; run go test -run TestReportCardMatchesGolden -update", golden)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	want, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := hashDistance(cardHash(img), cardHash(want)); d > maxCardHashDistance {
+		t.Errorf("card differs from the golden image by %d hash bits (max %d)", d, maxCardHashDistance)
+	}
+
+	// The hash must tell a different card apart, or the comparison above proves nothing
+	other := testCard()
+	other.Rows = other.Rows[:1]
+	otherImg, err := other.render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := hashDistance(cardHash(otherImg), cardHash(want)); d <= maxCardHashDistance {
+		t.Errorf("a card with fewer rows is only %d hash bits from the golden image", d)
+	}
+}
+
+func TestCardFontCoversVietnamese(t *testing.T) {
+	const sample = "ÀÁẢÃẠĂẰẮẲẴẶÂẦẤẨẪẬĐÈÉẺẼẸÊỀẾỂỄỆÌÍỈĨỊÒÓỎÕỌÔỒỐỔỖỘƠỜỚỞỠỢÙÚỦŨỤƯỪỨỬỮỰỲÝỶỸỴ" +
+		"àáảãạăằắẳẵặâầấẩẫậđèéẻẽẹêềếểễệìíỉĩịòóỏõọôồốổỗộơờớởỡợùúủũụưừứửữựỳýỷỹỵ▲▼■≈…"
+	for _, bold := range []bool{false, true} {
+		face, err := cardFace(bold, 24)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range sample {
+			if _, ok := face.GlyphAdvance(r); !ok {
+				t.Errorf("bold=%v font has no glyph for %q", bold, r)
+			}
+		}
+		if got := cardSupports(face, "Nhịp đập thị trường"); got != "Nhịp đập thị trường" {
+			t.Errorf("bold=%v dropped letters: %q", bold, got)
+		}
+	}
+}
+
+func TestCardDropsMissingGlyphs(t *testing.T) {
+	face, err := cardFace(false, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cardSupports(face, "🟡 Vàng (XAUUSD)"); got != "Vàng (XAUUSD)" {
+		t.Errorf("cardSupports = %q, want the emoji dropped", got)
+	}
+}
+
+func TestFitCardTextNeverOverflows(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+		cut   bool
+	}{
+		{"$2345.67", 300, false},
+		{"$123456789012.34", 300, false},
+		{"$123456789012345678901234567890.00", 300, true},
+		{"USD/VND ≈ 25.432 VNĐ", 120, true},
+	}
+	for _, tt := range tests {
+		s, face, err := fitCardText(tt.text, true, 34, tt.width)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w := font.MeasureString(face, s); w > fixed.I(tt.width) {
+			t.Errorf("%q rendered %d px wide in a %d px column", s, w.Round(), tt.width)
+		}
+		if cut := strings.HasSuffix(s, "…"); cut != tt.cut {
+			t.Errorf("%q fitted as %q, cut=%v want %v", tt.text, s, cut, tt.cut)
+		}
+	}
+}
+
+func TestNewReportCard(t *testing.T) {
+	report := MarketReport{
+		At: time.Date(2026, 2, 23, 8, 0, 0, 0, vnLocation),
+		Quotes: map[string]MarketData{
+			"gold": {Price: 2345.678, Percent: 1.2, HasPercent: true},
+			"btc":  {Err: errors.New("timeout")},
+		},
+	}
+	card := newReportCard(report, []string{"gold", "eth", "btc"}, "@bot")
+	if len(card.Rows) != 2 {
+		t.Fatalf("rows = %+v, want gold and btc (eth has no quote)", card.Rows)
+	}
+	if r := card.Rows[0]; !r.OK || r.Price != "$2345.68" || r.Percent != 1.2 {
+		t.Errorf("gold row = %+v", r)
+	}
+	if r := card.Rows[1]; r.OK {
+		t.Errorf("failed quote shown as %+v", r)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"math"
+)
+
+// errNotEnoughData is returned when a statistic has too few points to be meaningful
+var errNotEnoughData = errors.New("not enough data")
+
+// --- INDICATORS ---
+
+// alignByDate keeps only the dates present in both series, returning the paired closes
+// in date order. Inputs must be sorted oldest first, as getTimeSeries returns them.
+func alignByDate(a, b []SeriesPoint) ([]float64, []float64) {
+	var xs, ys []float64
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i].Date.Before(b[j].Date):
+			i++
+		case b[j].Date.Before(a[i].Date):
+			j++
+		default:
+			xs = append(xs, a[i].Close)
+			ys = append(ys, b[j].Close)
+			i++
+			j++
+		}
+	}
+	return xs, ys
+}
+
+// simpleReturns converts closes into period-over-period returns (len-1 values)
+func simpleReturns(closes []float64) []float64 {
+	if len(closes) < 2 {
+		return nil
+	}
+	out := make([]float64, 0, len(closes)-1)
+	for i := 1; i < len(closes); i++ {
+		if closes[i-1] == 0 {
+			out = append(out, 0)
+			continue
+		}
+		out = append(out, closes[i]/closes[i-1]-1)
+	}
+	return out
+}
+
+// logReturns converts closes into period-over-period log returns (len-1 values)
+func logReturns(closes []float64) []float64 {
+	if len(closes) < 2 {
+		return nil
+	}
+	out := make([]float64, 0, len(closes)-1)
+	for i := 1; i < len(closes); i++ {
+		if closes[i-1] <= 0 || closes[i] <= 0 {
+			continue
+		}
+		out = append(out, math.Log(closes[i]/closes[i-1]))
+	}
+	return out
+}
+
+// annualizedVolatility is the sample standard deviation of returns scaled to a year of
+// periodsPerYear observations
+func annualizedVolatility(returns []float64, periodsPerYear float64) (float64, error) {
+	n := len(returns)
+	if n < 5 || periodsPerYear <= 0 {
+		return 0, errNotEnoughData
+	}
+	var mean float64
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(n)
+	var ss float64
+	for _, r := range returns {
+		ss += (r - mean) * (r - mean)
+	}
+	return math.Sqrt(ss/float64(n-1)) * math.Sqrt(periodsPerYear), nil
+}
+
+// describeVolatility reads an annualized volatility against the configured bands
+func describeVolatility(v, low, high float64) string {
+	switch {
+	case v < low:
+		return "thấp"
+	case v > high:
+		return "cao"
+	default:
+		return "bình thường"
+	}
+}
+
+// pearson returns the Pearson correlation coefficient of two equal-length samples
+func pearson(xs, ys []float64) (float64, error) {
+	n := len(xs)
+	if n != len(ys) || n < 3 {
+		return 0, errNotEnoughData
+	}
+	var mx, my float64
+	for i := 0; i < n; i++ {
+		mx += xs[i]
+		my += ys[i]
+	}
+	mx /= float64(n)
+	my /= float64(n)
+
+	var cov, vx, vy float64
+	for i := 0; i < n; i++ {
+		dx, dy := xs[i]-mx, ys[i]-my
+		cov += dx * dy
+		vx += dx * dx
+		vy += dy * dy
+	}
+	if vx == 0 || vy == 0 {
+		// A flat series has no defined correlation
+		return 0, errNotEnoughData
+	}
+	return cov / math.Sqrt(vx*vy), nil
+}
+
+// describeCorrelation gives a plain-language reading of a correlation coefficient
+func describeCorrelation(r float64) string {
+	strength := "gần như không tương quan"
+	switch a := math.Abs(r); {
+	case a >= 0.7:
+		strength = "tương quan mạnh"
+	case a >= 0.4:
+		strength = "tương quan vừa"
+	case a >= 0.2:
+		strength = "tương quan yếu"
+	}
+	if math.Abs(r) < 0.2 {
+		return strength
+	}
+	if r > 0 {
+		return strength + " cùng chiều"
+	}
+	return strength + " ngược chiều"
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWindowDays(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want int
+		ok   bool
+	}{
+		{"", defaultAnalysisDays, true},
+		{"30d", 30, true},
+		{" 90D ", 90, true},
+		{"7", 7, true},
+		{"2d", 2, true},
+		{"365d", 365, true},
+		{"1d", 0, false},
+		{"366d", 0, false},
+		{"-5d", 0, false},
+		{"abc", 0, false},
+		{"30dd", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseWindowDays(tt.arg)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("parseWindowDays(%q) = %d, %v; want %d, ok %v", tt.arg, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestAlignByDate(t *testing.T) {
+	day := func(d int, c float64) SeriesPoint {
+		return SeriesPoint{Date: time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC), Close: c}
+	}
+	// Crypto trades every day, FX skips the weekend of the 7th and 8th
+	crypto := []SeriesPoint{day(5, 1), day(6, 2), day(7, 3), day(8, 4), day(9, 5)}
+	fx := []SeriesPoint{day(4, 10), day(5, 11), day(6, 12), day(9, 13)}
+	xs, ys := alignByDate(crypto, fx)
+	if !reflect.DeepEqual(xs, []float64{1, 2, 5}) || !reflect.DeepEqual(ys, []float64{11, 12, 13}) {
+		t.Errorf("aligned = %v / %v, want the 5th, 6th and 9th", xs, ys)
+	}
+	if xs, ys := alignByDate(nil, fx); xs != nil || ys != nil {
+		t.Errorf("aligning with an empty series = %v / %v", xs, ys)
+	}
+}
+
+func TestSimpleReturns(t *testing.T) {
+	got := simpleReturns([]float64{100, 110, 99, 0, 5})
+	want := []float64{0.1, -0.1, -1, 0}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-12 {
+			t.Fatalf("simpleReturns = %v, want %v (a zero close yields 0, not Inf)", got, want)
+		}
+	}
+	if simpleReturns([]float64{100}) != nil {
+		t.Error("one close has no return")
+	}
+}
+
+func TestPearson(t *testing.T) {
+	xs := []float64{0.01, -0.02, 0.03, 0.005, -0.01}
+	double, inverse := make([]float64, len(xs)), make([]float64, len(xs))
+	for i, x := range xs {
+		double[i], inverse[i] = 2*x+0.001, -x
+	}
+	if r, err := pearson(xs, double); err != nil || math.Abs(r-1) > 1e-9 {
+		t.Errorf("pearson(x, 2x+c) = %v, %v; want 1", r, err)
+	}
+	if r, err := pearson(xs, inverse); err != nil || math.Abs(r+1) > 1e-9 {
+		t.Errorf("pearson(x, -x) = %v, %v; want -1", r, err)
+	}
+	tests := []struct {
+		name   string
+		xs, ys []float64
+	}{
+		{"too short", xs[:2], []float64{1, 2}},
+		{"flat", xs, []float64{1, 1, 1, 1, 1}},
+		{"mismatched", xs, []float64{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		if _, err := pearson(tt.xs, tt.ys); !errors.Is(err, errNotEnoughData) {
+			t.Errorf("%s: err = %v, want errNotEnoughData", tt.name, err)
+		}
+	}
+}
+
+func TestDescribeCorrelation(t *testing.T) {
+	tests := []struct {
+		r    float64
+		want string
+	}{
+		{0.85, "tương quan mạnh cùng chiều"},
+		{0.7, "tương quan mạnh cùng chiều"},
+		{-0.5, "tương quan vừa ngược chiều"},
+		{0.25, "tương quan yếu cùng chiều"},
+		{0.19, "gần như không tương quan"},
+		{-0.1, "gần như không tương quan"},
+	}
+	for _, tt := range tests {
+		if got := describeCorrelation(tt.r); got != tt.want {
+			t.Errorf("describeCorrelation(%v) = %q, want %q", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestCorrReplyArguments(t *testing.T) {
+	if got := corrReply("btc"); got != "ℹ️ Cú pháp: `/corr btc eth 30d`" {
+		t.Errorf("one symbol = %q", got)
+	}
+	if got := corrReply("btc eth 1d"); got != "⚠️ Khoảng thời gian không hợp lệ. Dùng từ 2d đến 365d, ví dụ `30d`." {
+		t.Errorf("bad window = %q", got)
+	}
+}
+
+func TestLogReturns(t *testing.T) {
+	got := logReturns([]float64{100, 110, 0, 99, 99})
+	// Pairs touching the zero close are skipped rather than becoming ±Inf
+	want := []float64{math.Log(1.1), 0}
+	if len(got) != len(want) {
+		t.Fatalf("logReturns = %v, want %v", got, want)
+	}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-12 {
+			t.Fatalf("logReturns = %v, want %v", got, want)
+		}
+	}
+	if logReturns([]float64{100}) != nil {
+		t.Error("one close has no return")
+	}
+}
+
+func TestAnnualizedVolatility(t *testing.T) {
+	a := math.Log(1.01)
+	returns := []float64{a, -a, a, -a, a, -a, a, -a, a, -a}
+	want := a * math.Sqrt(10.0/9) * math.Sqrt(365)
+	if got, err := annualizedVolatility(returns, 365); err != nil || math.Abs(got-want) > 1e-12 {
+		t.Errorf("annualizedVolatility = %v, %v; want %v", got, err, want)
+	}
+	if got, err := annualizedVolatility(make([]float64, 5), 252); err != nil || got != 0 {
+		t.Errorf("flat returns = %v, %v; want 0", got, err)
+	}
+	if _, err := annualizedVolatility(returns[:4], 365); !errors.Is(err, errNotEnoughData) {
+		t.Errorf("four returns: err = %v, want errNotEnoughData", err)
+	}
+	if _, err := annualizedVolatility(returns, 0); !errors.Is(err, errNotEnoughData) {
+		t.Errorf("no periods per year: err = %v, want errNotEnoughData", err)
+	}
+}
+
+func TestDescribeVolatility(t *testing.T) {
+	tests := []struct {
+		v    float64
+		want string
+	}{
+		{0.1, "thấp"},
+		{0.3, "bình thường"},
+		{0.5, "bình thường"},
+		{0.7, "bình thường"},
+		{0.9, "cao"},
+	}
+	for _, tt := range tests {
+		if got := describeVolatility(tt.v, 0.3, 0.7); got != tt.want {
+			t.Errorf("describeVolatility(%v) = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestVolReply(t *testing.T) {
+	withDatabase(t, false, nil)
+	t.Setenv("VOL_LOW_BAND", "0.05")
+	t.Setenv("VOL_HIGH_BAND", "0.1")
+	reloadConfig()
+	t.Cleanup(func() {
+		configMu.Lock()
+		configLoadedAt = configLoadedAt.AddDate(-1, 0, 0)
+		configMu.Unlock()
+	})
+	now := time.Date(2026, 3, 31, 9, 0, 0, 0, vnLocation)
+	savedClock := clock
+	clock = func() time.Time { return now }
+	t.Cleanup(func() { clock = savedClock })
+
+	symbol := resolveSymbol("btc")
+	var series []SeriesPoint
+	for i := range 31 {
+		series = append(series, SeriesPoint{Date: now.AddDate(0, 0, i-30), Close: 100 + float64(i%2)})
+	}
+	key := fmt.Sprintf("%s|%d|%s", symbol, 30, now.Format("2006-01-02"))
+	seriesCacheMu.Lock()
+	seriesCache[key] = series
+	seriesCacheMu.Unlock()
+	t.Cleanup(func() {
+		seriesCacheMu.Lock()
+		delete(seriesCache, key)
+		seriesCacheMu.Unlock()
+	})
+
+	got := volReply("btc 30d")
+	for _, want := range []string{"(30 ngày)", "Đánh giá: cao _(ngưỡng 5%–10%)_", "dựa trên 30 phiên"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("volReply is missing %q:\n%s", want, got)
+		}
+	}
+	if got := volReply(""); got != "ℹ️ Cú pháp: `/vol btc 30d`" {
+		t.Errorf("no symbol = %q", got)
+	}
+	if got := volReply("btc 1d"); !strings.HasPrefix(got, "⚠️ Khoảng thời gian không hợp lệ") {
+		t.Errorf("bad window = %q", got)
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// inlineCacheTime is how long Telegram may reuse an inline answer, in seconds
+const inlineCacheTime = 60
+
+// Gold weights: a Vietnamese lượng (tael) is 37.5 g and a chỉ a tenth of it
+const (
+	gramsPerTroyOunce = 31.1034768
+	gramsPerTael      = 37.5
+)
+
+// Units an inline amount can be in
+const (
+	unitUSD  = "usd"
+	unitVND  = "vnd"
+	unitTael = "tael"
+	unitChi  = "chi"
+	unitBTC  = "btc"
+)
+
+// amountUnits maps the words people type after an amount onto a unit
+var amountUnits = map[string]string{
+	"usd": unitUSD, "$": unitUSD, "đô": unitUSD, "đô la": unitUSD, "do": unitUSD, "dola": unitUSD, "đô-la": unitUSD,
+	"vnd": unitVND, "vnđ": unitVND, "đ": unitVND, "đồng": unitVND, "dong": unitVND,
+	"lượng": unitTael, "luong": unitTael, "cây": unitTael, "cay": unitTael,
+	"chỉ": unitChi, "chi": unitChi,
+	"btc": unitBTC, "bitcoin": unitBTC,
+}
+
+// amountReading is one interpretation of an inline query: an amount in a unit
+type amountReading struct {
+	Amount float64
+	Unit   string
+}
+
+// inlineRates are the rates a conversion card uses, all from caches; zero means unknown
+type inlineRates struct {
+	UsdVnd  float64
+	GoldUsd float64 // per troy ounce
+	BtcUsd  float64
+	At      time.Time
+}
+
+// --- INLINE CONVERSION ---
+
+// parseAmountNumber reads a number written with Vietnamese ("1.500.000,5") or
+// international ("1,500,000.5") separators. A single separator followed by exactly three
+// digits ("1.500", "2,000") could be either, so both values come back, the Vietnamese
+// reading first; everything else has one reading or none.
+func parseAmountNumber(s string) []float64 {
+	if s == "" || strings.Trim(s, "0123456789.,") != "" {
+		return nil
+	}
+	dots, commas := strings.Count(s, "."), strings.Count(s, ",")
+	parse := func(group, point string) (float64, bool) {
+		parts := strings.Split(strings.Replace(s, point, "\x00", 1), "\x00")
+		whole := strings.Split(parts[0], group)
+		for i, p := range whole {
+			// Groups after the first must be exactly three digits
+			if p == "" || (i > 0 && len(p) != 3) || (i == 0 && len(whole) > 1 && (len(p) > 3 || p[0] == '0')) {
+				return 0, false
+			}
+		}
+		num := strings.Join(whole, "")
+		if len(parts) == 2 {
+			if parts[1] == "" || strings.ContainsAny(parts[1], ".,") {
+				return 0, false
+			}
+			num += "." + parts[1]
+		}
+		v, err := strconv.ParseFloat(num, 64)
+		return v, err == nil && v > 0
+	}
+	var values []float64
+	add := func(group, point string) {
+		if v, ok := parse(group, point); ok {
+			for _, seen := range values {
+				if seen == v {
+					return
+				}
+			}
+			values = append(values, v)
+		}
+	}
+	switch {
+	case dots+commas == 0:
+		add(".", ",")
+	case dots > 0 && commas > 0:
+		// Both present: whichever comes last is the decimal point
+		if strings.LastIndex(s, ",") > strings.LastIndex(s, ".") {
+			add(".", ",")
+		} else {
+			add(",", ".")
+		}
+	case dots > 1:
+		add(".", ",")
+	case commas > 1:
+		add(",", ".")
+	default:
+		sep := "."
+		if commas == 1 {
+			sep = ","
+		}
+		if _, frac, _ := strings.Cut(s, sep); len(frac) == 3 {
+			add(".", ",")
+			add(",", ".")
+		} else if sep == "," {
+			add(".", ",")
+		} else {
+			add(",", ".")
+		}
+	}
+	return values
+}
+
+// parseInlineAmount reads "500 usd", "2 lượng vàng", "1.500.000đ" or "0,5 btc" into every
+// plausible reading. A bare number could be dollars or dong, so it reads as both (dong
+// only when whole). nil means the query isn't an amount this bot converts.
+func parseInlineAmount(query string) []amountReading {
+	q := strings.ToLower(strings.Join(strings.Fields(query), " "))
+	q = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(q, "vàng"), "vang"))
+	dollarSign := strings.HasPrefix(q, "$")
+	q = strings.TrimSpace(strings.TrimPrefix(q, "$"))
+	end := strings.IndexFunc(q, func(r rune) bool { return !unicode.IsDigit(r) && r != '.' && r != ',' })
+	number, word := q, ""
+	if end >= 0 {
+		number, word = q[:end], strings.TrimSpace(q[end:])
+	}
+	values := parseAmountNumber(number)
+	if len(values) == 0 {
+		return nil
+	}
+	units := []string{unitUSD, unitVND}
+	switch {
+	case word != "":
+		unit, ok := amountUnits[word]
+		if !ok || dollarSign && unit != unitUSD {
+			return nil
+		}
+		units = []string{unit}
+	case dollarSign:
+		units = []string{unitUSD}
+	}
+	var readings []amountReading
+	for _, unit := range units {
+		for _, v := range values {
+			if unit == unitVND && v != math.Trunc(v) {
+				// Dong has no fractions; "1.5" is never a VND amount
+				continue
+			}
+			readings = append(readings, amountReading{Amount: v, Unit: unit})
+		}
+	}
+	return readings
+}
+
+// inlineUnitLabel names a unit on a card
+func inlineUnitLabel(unit string) string {
+	switch unit {
+	case unitTael:
+		return "lượng vàng"
+	case unitChi:
+		return "chỉ vàng"
+	}
+	return strings.ToUpper(unit)
+}
+
+// convertReading turns a reading into VND and USD values; ok is false when a rate it needs
+// isn't cached
+func convertReading(r amountReading, rates inlineRates) (vnd, usd float64, ok bool) {
+	switch r.Unit {
+	case unitUSD:
+		usd = r.Amount
+	case unitVND:
+		if rates.UsdVnd <= 0 {
+			return 0, 0, false
+		}
+		return r.Amount, r.Amount / rates.UsdVnd, true
+	case unitTael, unitChi:
+		taels := r.Amount
+		if r.Unit == unitChi {
+			taels /= 10
+		}
+		if rates.GoldUsd <= 0 {
+			return 0, 0, false
+		}
+		usd = taels * gramsPerTael / gramsPerTroyOunce * rates.GoldUsd
+	case unitBTC:
+		if rates.BtcUsd <= 0 {
+			return 0, 0, false
+		}
+		usd = r.Amount * rates.BtcUsd
+	}
+	if rates.UsdVnd <= 0 {
+		return 0, 0, false
+	}
+	return usd * rates.UsdVnd, usd, true
+}
+
+// conversionCard renders one reading as an inline result; nil when it can't be converted
+func conversionCard(r amountReading, rates inlineRates) *tele.ArticleResult {
+	vnd, usd, ok := convertReading(r, rates)
+	if !ok {
+		return nil
+	}
+	// The amount is shown with the decimals it was typed with
+	typed := strconv.FormatFloat(r.Amount, 'f', -1, 64)
+	_, frac, _ := strings.Cut(typed, ".")
+	amount := formatNumber(r.Amount, len(frac), numberStyleVN)
+	result := fmt.Sprintf("%s VNĐ", formatVnd(vnd))
+	if r.Unit == unitVND {
+		result = fmt.Sprintf("%s USD", formatNumber(usd, amountDecimals(usd, "USD"), numberStyleVN))
+	}
+	title := fmt.Sprintf("%s %s ≈ %s", amount, inlineUnitLabel(r.Unit), result)
+	note := fmt.Sprintf("Tỷ giá 1$ ≈ %s VNĐ", formatVnd(rates.UsdVnd))
+	switch r.Unit {
+	case unitTael, unitChi:
+		note = fmt.Sprintf("Giá vàng thế giới quy đổi (≈ %s USD), chưa gồm chênh lệch giá trong nước", formatNumber(usd, 0, numberStyleVN))
+	case unitBTC:
+		note = fmt.Sprintf("≈ %s USD · %s", formatNumber(usd, 0, numberStyleVN), note)
+	}
+	if !rates.At.IsZero() {
+		note += " · " + rates.At.In(vnLocation).Format("15:04 02/01")
+	}
+	return &tele.ArticleResult{
+		ResultBase:  tele.ResultBase{ParseMode: tele.ModeMarkdown},
+		Title:       title,
+		Description: note,
+		Text:        fmt.Sprintf("💱 %s %s ≈ *%s*\n_%s_", amount, inlineUnitLabel(r.Unit), result, note),
+	}
+}
+
+// cachedInlineRates gathers rates without spending API calls: the latest snapshot's
+// prices and rate, with the in-memory or stored USD/VND rate when it is newer
+func cachedInlineRates() inlineRates {
+	var rates inlineRates
+	if snapshotCollection != nil {
+		var snap Snapshot
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		err := snapshotCollection.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.D{{Key: "at", Value: -1}})).Decode(&snap)
+		if err == nil {
+			rates = inlineRates{UsdVnd: snap.UsdVnd, GoldUsd: snap.Prices["gold"], BtcUsd: snap.Prices["btc"], At: snap.At}
+		}
+	}
+	usdVndMu.Lock()
+	rate, at := cachedUsdVnd, lastCacheUpdate
+	usdVndMu.Unlock()
+	if rate <= 0 {
+		if stored, ok := loadStoredUsdVnd(); ok {
+			rate, at = stored.Rate, stored.At
+		}
+	}
+	if rate > 0 && (rates.UsdVnd <= 0 || at.After(rates.At)) {
+		rates.UsdVnd = rate
+		if rates.At.IsZero() {
+			rates.At = at
+		}
+	}
+	return rates
+}
+
+// handleInlineQuery answers "@bot 500 usd" with one conversion card per reading of the
+// query, so an ambiguous amount offers each interpretation to pick from
+func handleInlineQuery(b *tele.Bot, q *tele.Query) {
+	readings := parseInlineAmount(q.Text)
+	results := tele.Results{}
+	if len(readings) > 0 {
+		rates := cachedInlineRates()
+		for i, r := range readings {
+			if card := conversionCard(r, rates); card != nil {
+				card.SetResultID(strconv.Itoa(i))
+				results = append(results, card)
+			}
+		}
+	}
+	if err := b.Answer(q, &tele.QueryResponse{Results: results, CacheTime: inlineCacheTime}); err != nil {
+		log.Printf("[INLINE ERROR] Failed to answer %q: %v", q.Text, err)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParseAmountNumber(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []float64
+	}{
+		{"500", []float64{500}},
+		{"1.500", []float64{1500, 1.5}},
+		{"2,000", []float64{2, 2000}},
+		{"1,500.5", []float64{1500.5}},
+		{"1.500.000,5", []float64{1500000.5}},
+		{"1,500,000", []float64{1500000}},
+		{"0,5", []float64{0.5}},
+		{"1.5", []float64{1.5}},
+		{"12,34,567", nil},
+		{"1..5", nil},
+		{"1.500,", nil},
+		{"0", nil},
+		{"", nil},
+		{"5k", nil},
+	}
+	for _, tt := range tests {
+		if got := parseAmountNumber(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseAmountNumber(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseInlineAmount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []amountReading
+	}{
+		{"500 usd", []amountReading{{500, unitUSD}}},
+		{"$500", []amountReading{{500, unitUSD}}},
+		{"$500 vnd", nil},
+		{"1.500", []amountReading{{1500, unitUSD}, {1.5, unitUSD}, {1500, unitVND}}},
+		{"1,500.5", []amountReading{{1500.5, unitUSD}}},
+		{"0,5 btc", []amountReading{{0.5, unitBTC}}},
+		{"2 lượng vàng", []amountReading{{2, unitTael}}},
+		{"3 chỉ", []amountReading{{3, unitChi}}},
+		{"1.500.000đ", []amountReading{{1500000, unitVND}}},
+		{"  25.000.000   VNĐ ", []amountReading{{25000000, unitVND}}},
+		{"500 eur", nil},
+		{"btc", nil},
+	}
+	for _, tt := range tests {
+		if got := parseInlineAmount(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseInlineAmount(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestConvertReading(t *testing.T) {
+	rates := inlineRates{UsdVnd: 25000, GoldUsd: 2000, BtcUsd: 60000}
+	tests := []struct {
+		r        amountReading
+		vnd, usd float64
+	}{
+		{amountReading{100, unitUSD}, 2500000, 100},
+		{amountReading{2500000, unitVND}, 2500000, 100},
+		{amountReading{0.5, unitBTC}, 750000000, 30000},
+		{amountReading{1, unitTael}, 37.5 / gramsPerTroyOunce * 2000 * 25000, 37.5 / gramsPerTroyOunce * 2000},
+		{amountReading{10, unitChi}, 37.5 / gramsPerTroyOunce * 2000 * 25000, 37.5 / gramsPerTroyOunce * 2000},
+	}
+	for _, tt := range tests {
+		vnd, usd, ok := convertReading(tt.r, rates)
+		if !ok || math.Abs(vnd-tt.vnd) > 1e-6 || math.Abs(usd-tt.usd) > 1e-9 {
+			t.Errorf("convertReading(%+v) = %v, %v, %v; want %v, %v", tt.r, vnd, usd, ok, tt.vnd, tt.usd)
+		}
+	}
+	if _, _, ok := convertReading(amountReading{1, unitBTC}, inlineRates{UsdVnd: 25000}); ok {
+		t.Error("BTC converted without a BTC price")
+	}
+	if _, _, ok := convertReading(amountReading{1, unitUSD}, inlineRates{}); ok {
+		t.Error("USD converted without a USD/VND rate")
+	}
+}
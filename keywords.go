@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// Keyword spike detection: the last spikeWindow is compared against the hourly rate of the
+// spikeBaselineDays before it
+const (
+	spikeWindow       = 6 * time.Hour
+	spikeBaselineDays = 7
+	spikeRatio        = 3.0
+	spikeMinItems     = 4
+	spikeCooldown     = 12 * time.Hour
+	spikeTopHeadlines = 3
+)
+
+// Followed keyword limits per chat
+const (
+	maxKeywords      = 10
+	maxKeywordLength = 50
+)
+
+var (
+	newsArchiveCollection  *mongo.Collection
+	keywordSpikeCollection *mongo.Collection
+)
+
+// ArchivedHeadline is a feed item as first seen by the bot, keyed by its link
+type ArchivedHeadline struct {
+	Link    string    `bson:"_id"`
+	Title   string    `bson:"title"`
+	TitleVi string    `bson:"title_vi,omitempty"`
+	SeenAt  time.Time `bson:"seen_at"`
+	// Sentiment is the headline's positive/negative/neutral tag (see sentiment.go)
+	Sentiment string `bson:"sentiment,omitempty"`
+}
+
+// --- NEWS ARCHIVE ---
+
+// archiveHeadlines records feed items the first time they are seen, tagged with their
+// sentiment; only items this call inserted reach the daily sentiment counters. translated
+// may be nil or parallel to originals.
+func archiveHeadlines(originals, translated []Headline) {
+	if newsArchiveCollection == nil || len(originals) == 0 {
+		return
+	}
+	now := clock()
+	writes := make([]mongo.WriteModel, 0, len(originals))
+	var tags []string
+	for i, h := range originals {
+		if h.Link == "" {
+			continue
+		}
+		set := bson.M{"title": h.Title, "seen_at": now}
+		titles := []string{h.Title}
+		if i < len(translated) && translated[i].Title != h.Title {
+			set["title_vi"] = translated[i].Title
+			titles = append(titles, translated[i].Title)
+		}
+		set["sentiment"] = headlineSentiment(titles...)
+		tags = append(tags, set["sentiment"].(string))
+		writes = append(writes, mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": h.Link}).
+			SetUpdate(bson.M{"$setOnInsert": set}).SetUpsert(true))
+	}
+	if len(writes) == 0 {
+		return
+	}
+	result, err := newsArchiveCollection.BulkWrite(context.TODO(), writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to archive headlines: %v", err)
+		return
+	}
+	// UpsertedIDs is keyed by write index: those are the items seen for the first time
+	inserted := make([]string, 0, len(result.UpsertedIDs))
+	for i := range result.UpsertedIDs {
+		inserted = append(inserted, tags[i])
+	}
+	countSentiments(now, inserted)
+}
+
+// --- KEYWORD SPIKES ---
+
+// isKeywordSpike decides whether recent matches in spikeWindow stand out against the
+// baseline matches of the spikeBaselineDays before it. Without a full week of archive
+// (covered is false) nothing is a spike, since the baseline would be meaningless.
+func isKeywordSpike(recent, baseline int, covered bool) bool {
+	if !covered || recent < spikeMinItems {
+		return false
+	}
+	hourly := float64(baseline) / float64(spikeBaselineDays*24)
+	expected := hourly * spikeWindow.Hours()
+	return float64(recent) > spikeRatio*expected
+}
+
+// keywordFilter matches archived titles (either language) containing keyword literally,
+// ignoring case
+func keywordFilter(keyword string, from, to time.Time) bson.M {
+	re := bson.M{"$regex": regexp.QuoteMeta(keyword), "$options": "i"}
+	return bson.M{
+		"seen_at": bson.M{"$gte": from, "$lt": to},
+		"$or":     []bson.M{{"title": re}, {"title_vi": re}},
+	}
+}
+
+// archiveCovers reports whether the archive reaches back a full baseline before since
+func archiveCovers(since time.Time) bool {
+	var oldest ArchivedHeadline
+	err := newsArchiveCollection.FindOne(context.TODO(), bson.M{},
+		options.FindOne().SetSort(bson.D{{Key: "seen_at", Value: 1}}).SetProjection(bson.M{"seen_at": 1})).Decode(&oldest)
+	return err == nil && !oldest.SeenAt.After(since.AddDate(0, 0, -spikeBaselineDays))
+}
+
+// loadFollowedKeywords maps each followed keyword to its followers
+func loadFollowedKeywords() map[string][]int64 {
+	out := make(map[string][]int64)
+	if userCollection == nil {
+		return out
+	}
+	cursor, err := userCollection.Find(context.TODO(), bson.M{"keywords.0": bson.M{"$exists": true}},
+		options.Find().SetProjection(bson.M{"chat_id": 1, "keywords": 1}))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load followed keywords: %v", err)
+		return out
+	}
+	defer cursor.Close(context.TODO())
+	for cursor.Next(context.TODO()) {
+		var result struct {
+			ChatID   int64    `bson:"chat_id"`
+			Keywords []string `bson:"keywords"`
+		}
+		if cursor.Decode(&result) == nil {
+			for _, k := range result.Keywords {
+				out[k] = append(out[k], result.ChatID)
+			}
+		}
+	}
+	return out
+}
+
+// claimSpikeAlert marks a chat as alerted for keyword; false if it already was within
+// spikeCooldown, so a sustained spike notifies once per cooldown even across containers
+func claimSpikeAlert(chatID int64, keyword string, now time.Time) bool {
+	if keywordSpikeCollection == nil {
+		return false
+	}
+	id := fmt.Sprintf("%d|%s", chatID, keyword)
+	res, err := keywordSpikeCollection.UpdateOne(context.TODO(),
+		bson.M{"_id": id, "at": bson.M{"$lte": now.Add(-spikeCooldown)}},
+		bson.M{"$set": bson.M{"at": now}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to claim keyword spike: %v", err)
+		return false
+	}
+	if res.MatchedCount > 0 {
+		return true
+	}
+	// Either alerted recently, or the marker doesn't exist yet
+	_, err = keywordSpikeCollection.InsertOne(context.TODO(), bson.M{"_id": id, "at": now})
+	return err == nil
+}
+
+// checkKeywordSpikes counts archive matches for every followed keyword and sends each
+// follower of a spiking keyword one message with the top headlines; it returns how many
+// keywords spiked
+func checkKeywordSpikes(b *tele.Bot) int {
+	if newsArchiveCollection == nil {
+		return 0
+	}
+	now := clock()
+	since := now.Add(-spikeWindow)
+	covered := archiveCovers(since)
+	if !covered {
+		log.Println("[NEWS] Archive has under a week of data; skipping spike detection")
+		return 0
+	}
+	followers := loadFollowedKeywords()
+	threads := loadThreadIDs()
+	premium := premiumChats()
+	spiked := 0
+	for keyword, chats := range followers {
+		recent, err1 := newsArchiveCollection.CountDocuments(context.TODO(), keywordFilter(keyword, since, now))
+		baseline, err2 := newsArchiveCollection.CountDocuments(context.TODO(),
+			keywordFilter(keyword, since.AddDate(0, 0, -spikeBaselineDays), since))
+		if err1 != nil || err2 != nil {
+			log.Printf("[DATABASE ERROR] Failed to count headlines for %q", keyword)
+			continue
+		}
+		if !isKeywordSpike(int(recent), int(baseline), covered) {
+			continue
+		}
+		spiked++
+		text := spikeMessage(keyword, int(recent), int(baseline))
+		for _, id := range chats {
+			// Keyword alerts are a premium feature; a lapsed follower keeps the list but gets no pushes
+			if !premium[id] || !claimSpikeAlert(id, keyword, now) {
+				continue
+			}
+			if _, err := deliver(b, id, threads[id], text, &tele.SendOptions{ParseMode: tele.ModeMarkdown, DisableWebPagePreview: true}); err != nil {
+				log.Printf("[NEWS] Failed to send spike for %q to %d: %v", keyword, id, err)
+			}
+		}
+	}
+	return spiked
+}
+
+// spikeMessage bundles the latest matching headlines for a spiking keyword
+func spikeMessage(keyword string, recent, baseline int) string {
+	now := clock()
+	cursor, err := newsArchiveCollection.Find(context.TODO(), keywordFilter(keyword, now.Add(-spikeWindow), now),
+		options.Find().SetSort(bson.D{{Key: "seen_at", Value: -1}}).SetLimit(spikeTopHeadlines))
+	var top []ArchivedHeadline
+	if err == nil {
+		cursor.All(context.TODO(), &top)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🔥 **Chủ đề nóng: %s**\n_%d tin trong %d giờ qua (tuần trước trung bình %.1f)._\n",
+		keyword, recent, int(spikeWindow.Hours()), float64(baseline)/float64(spikeBaselineDays*24)*spikeWindow.Hours())
+	for _, h := range top {
+		title := h.TitleVi
+		if title == "" {
+			title = h.Title
+		}
+		fmt.Fprintf(&sb, "\n🔹 %s\n🔗 [Xem chi tiết](%s)\n", title, h.Link)
+	}
+	return sb.String()
+}
+
+// followReply handles "/follow" (list), "/follow <keyword>" and "/unfollow <keyword>";
+// a multi-word keyword goes in quotes
+func followReply(chatID int64, args []string, remove bool) string {
+	if userCollection == nil {
+		return "⚠️ Không có kết nối cơ sở dữ liệu."
+	}
+	if len(args) == 0 {
+		var result struct {
+			Keywords []string `bson:"keywords"`
+		}
+		userCollection.FindOne(context.TODO(), bson.M{"chat_id": chatID},
+			options.FindOne().SetProjection(bson.M{"keywords": 1})).Decode(&result)
+		if len(result.Keywords) == 0 {
+			return "ℹ️ Bạn chưa theo dõi từ khóa nào. Ví dụ: `/follow \"bitcoin etf\"`"
+		}
+		return "🔥 **Từ khóa đang theo dõi:** " + strings.Join(result.Keywords, ", ") +
+			"\n_Bot báo khi một từ khóa xuất hiện nhiều bất thường trong tin tức (tối đa 12 giờ một lần)._"
+	}
+	keyword := strings.ToLower(strings.TrimSpace(strings.Join(args, " ")))
+	if keyword == "" || len([]rune(keyword)) > maxKeywordLength {
+		return fmt.Sprintf("⚠️ Từ khóa phải từ 1 đến %d ký tự.", maxKeywordLength)
+	}
+	filter := bson.M{"chat_id": chatID}
+	update := bson.M{"$addToSet": bson.M{"keywords": keyword}, "$set": bson.M{"updated_at": clock()}}
+	if remove {
+		update = bson.M{"$pull": bson.M{"keywords": keyword}, "$set": bson.M{"updated_at": clock()}}
+	} else {
+		// Refuse once the list is full, unless the keyword is already on it
+		filter["$or"] = []bson.M{{fmt.Sprintf("keywords.%d", maxKeywords-1): bson.M{"$exists": false}}, {"keywords": keyword}}
+	}
+	res, err := userCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to update keywords for %d: %v", chatID, err)
+		return "⚠️ Không thể lưu lúc này."
+	}
+	if res.MatchedCount == 0 {
+		if n, _ := userCollection.CountDocuments(context.TODO(), bson.M{"chat_id": chatID}); remove || n == 0 {
+			return "ℹ️ Bạn cần đăng ký bằng /start trước."
+		}
+		return fmt.Sprintf("⚠️ Tối đa %d từ khóa.", maxKeywords)
+	}
+	if remove {
+		return fmt.Sprintf("✅ Đã bỏ theo dõi \"%s\".", keyword)
+	}
+	return fmt.Sprintf("✅ Đang theo dõi \"%s\". Bot sẽ báo khi chủ đề này nóng lên.", keyword)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestIsKeywordSpike(t *testing.T) {
+	// A week of baseline is 168 hours, so the 6-hour window expects baseline/28 items
+	tests := []struct {
+		recent, baseline int
+		covered          bool
+		want             bool
+	}{
+		{4, 0, true, true},
+		{3, 0, true, false},
+		{10, 0, false, false},
+		{10, 93, true, true},
+		{10, 94, true, false},
+		{40, 280, true, true},
+		{30, 280, true, false},
+	}
+	for _, tt := range tests {
+		if got := isKeywordSpike(tt.recent, tt.baseline, tt.covered); got != tt.want {
+			t.Errorf("isKeywordSpike(%d, %d, %v) = %v, want %v", tt.recent, tt.baseline, tt.covered, got, tt.want)
+		}
+	}
+}
+
+func TestKeywordFilter(t *testing.T) {
+	from := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	to := from.Add(spikeWindow)
+	got := keywordFilter("s&p 500 (spx)", from, to)
+	re := bson.M{"$regex": `s&p 500 \(spx\)`, "$options": "i"}
+	want := bson.M{
+		"seen_at": bson.M{"$gte": from, "$lt": to},
+		"$or":     []bson.M{{"title": re}, {"title_vi": re}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("keywordFilter = %v, want %v", got, want)
+	}
+	// The keyword is matched literally, whatever regex syntax it contains
+	if !regexp.MustCompile("(?i)" + re["$regex"].(string)).MatchString("S&P 500 (SPX) hits a record") {
+		t.Error("the escaped pattern doesn't match the literal keyword")
+	}
+}
+
+func TestKeywordsWithoutDatabase(t *testing.T) {
+	withDatabase(t, false, nil)
+	if got := followReply(7, []string{"bitcoin etf"}, false); got != "⚠️ Không có kết nối cơ sở dữ liệu." {
+		t.Errorf("followReply = %q", got)
+	}
+	if got := checkKeywordSpikes(nil); got != 0 {
+		t.Errorf("checkKeywordSpikes = %d without an archive", got)
+	}
+	archiveHeadlines([]Headline{{Title: "Gold rises", Link: "https://example.com/a"}}, nil)
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// Leaderboard rules
+const (
+	leaderboardSize     = 10
+	leaderboardMinVotes = 5
+)
+
+var (
+	predictionCollection    *mongo.Collection
+	predictorPrefCollection *mongo.Collection
+)
+
+// Prediction is one user's answer to one daily poll, keyed by (poll_id, user_id).
+// Correct stays nil until the poll is resolved, and for polls that ended flat.
+type Prediction struct {
+	PollID    string    `bson:"poll_id"`
+	UserID    int64     `bson:"user_id"`
+	Name      string    `bson:"name"`
+	Option    int       `bson:"option"`
+	Date      string    `bson:"date"`
+	Month     string    `bson:"month"`
+	Correct   *bool     `bson:"correct,omitempty"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+// LeaderboardEntry is one ranked row
+type LeaderboardEntry struct {
+	UserID  int64
+	Name    string
+	Votes   int
+	Correct int
+	Streak  int
+}
+
+// --- PREDICTION LEADERBOARD ---
+
+// predictionMonth maps a poll date ("2006-01-02", already in Vietnam time) to its
+// leaderboard month ("2006-01")
+func predictionMonth(date string) string {
+	if len(date) < 7 {
+		return date
+	}
+	return date[:7]
+}
+
+// previousMonth returns the month before "2006-01"
+func previousMonth(month string) string {
+	t, err := time.Parse("2006-01", month)
+	if err != nil {
+		return month
+	}
+	return t.AddDate(0, -1, 0).Format("2006-01")
+}
+
+// displayName picks a readable name for a voter
+func displayName(u *tele.User) string {
+	if u == nil {
+		return ""
+	}
+	if u.Username != "" {
+		return "@" + u.Username
+	}
+	return strings.TrimSpace(u.FirstName + " " + u.LastName)
+}
+
+// recordPollAnswer stores (or replaces, when the user changes their vote) a poll answer.
+// Answers to polls we didn't send, and retracted votes, are ignored.
+func recordPollAnswer(a *tele.PollAnswer) {
+	if a == nil || a.Sender == nil || pollCollection == nil || predictionCollection == nil {
+		return
+	}
+	var poll DailyPoll
+	if err := pollCollection.FindOne(context.TODO(), bson.M{"poll_id": a.PollID}).Decode(&poll); err != nil {
+		log.Printf("[POLL] Answer for unknown poll %s ignored", a.PollID)
+		return
+	}
+	if poll.Resolved {
+		return
+	}
+	filter := bson.M{"poll_id": a.PollID, "user_id": a.Sender.ID}
+	if len(a.Options) == 0 {
+		predictionCollection.DeleteOne(context.TODO(), filter)
+		return
+	}
+	update := bson.M{"$set": Prediction{
+		PollID:    a.PollID,
+		UserID:    a.Sender.ID,
+		Name:      displayName(a.Sender),
+		Option:    a.Options[0],
+		Date:      poll.Date,
+		Month:     predictionMonth(poll.Date),
+		UpdatedAt: clock(),
+	}}
+	if _, err := predictionCollection.UpdateOne(context.TODO(), filter, update, options.Update().SetUpsert(true)); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to record prediction from %d: %v", a.Sender.ID, err)
+	}
+}
+
+// gradePredictions marks the answers to the given polls correct or not; flat outcomes stay ungraded
+func gradePredictions(pollIDs []string, outcome int) {
+	if predictionCollection == nil || len(pollIDs) == 0 || outcome < 0 {
+		return
+	}
+	filter := bson.M{"poll_id": bson.M{"$in": pollIDs}}
+	right := bson.M{"$and": []bson.M{filter, {"option": outcome}}}
+	wrong := bson.M{"$and": []bson.M{filter, {"option": bson.M{"$ne": outcome}}}}
+	if _, err := predictionCollection.UpdateMany(context.TODO(), right, bson.M{"$set": bson.M{"correct": true}}); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to grade predictions: %v", err)
+	}
+	if _, err := predictionCollection.UpdateMany(context.TODO(), wrong, bson.M{"$set": bson.M{"correct": false}}); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to grade predictions: %v", err)
+	}
+}
+
+// rankPredictions builds the leaderboard from a month of graded predictions: most correct
+// first, ties broken by the longest run of consecutive correct days
+func rankPredictions(preds []Prediction) []LeaderboardEntry {
+	byUser := make(map[int64][]Prediction)
+	for _, p := range preds {
+		if p.Correct != nil {
+			byUser[p.UserID] = append(byUser[p.UserID], p)
+		}
+	}
+
+	var entries []LeaderboardEntry
+	for userID, list := range byUser {
+		if len(list) < leaderboardMinVotes {
+			continue
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Date < list[j].Date })
+		e := LeaderboardEntry{UserID: userID, Votes: len(list)}
+		run := 0
+		for _, p := range list {
+			e.Name = p.Name
+			if *p.Correct {
+				e.Correct++
+				run++
+				if run > e.Streak {
+					e.Streak = run
+				}
+			} else {
+				run = 0
+			}
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Correct != entries[j].Correct {
+			return entries[i].Correct > entries[j].Correct
+		}
+		if entries[i].Streak != entries[j].Streak {
+			return entries[i].Streak > entries[j].Streak
+		}
+		return entries[i].UserID < entries[j].UserID
+	})
+	if len(entries) > leaderboardSize {
+		entries = entries[:leaderboardSize]
+	}
+	return entries
+}
+
+// loadLeaderboard ranks the given month's predictions
+func loadLeaderboard(month string) []LeaderboardEntry {
+	if predictionCollection == nil {
+		return nil
+	}
+	cursor, err := predictionCollection.Find(context.TODO(), bson.M{"month": month, "correct": bson.M{"$exists": true}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load predictions: %v", err)
+		return nil
+	}
+	var preds []Prediction
+	if err := cursor.All(context.TODO(), &preds); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to decode predictions: %v", err)
+		return nil
+	}
+	return rankPredictions(preds)
+}
+
+// hiddenPredictors returns the users who asked to appear anonymously
+func hiddenPredictors() map[int64]bool {
+	hidden := make(map[int64]bool)
+	if predictorPrefCollection == nil {
+		return hidden
+	}
+	cursor, err := predictorPrefCollection.Find(context.TODO(), bson.M{"hidden": true})
+	if err != nil {
+		return hidden
+	}
+	defer cursor.Close(context.TODO())
+	for cursor.Next(context.TODO()) {
+		var pref struct {
+			UserID int64 `bson:"user_id"`
+		}
+		if cursor.Decode(&pref) == nil {
+			hidden[pref.UserID] = true
+		}
+	}
+	return hidden
+}
+
+// setPredictorHidden stores the /leaderboard hide|show preference
+func setPredictorHidden(userID int64, hidden bool) error {
+	if predictorPrefCollection == nil {
+		return fmt.Errorf("database unavailable")
+	}
+	_, err := predictorPrefCollection.UpdateOne(context.TODO(), bson.M{"user_id": userID},
+		bson.M{"$set": bson.M{"user_id": userID, "hidden": hidden}}, options.Update().SetUpsert(true))
+	return err
+}
+
+// formatLeaderboard renders a month's ranking ("" when nobody qualifies)
+func formatLeaderboard(month string, entries []LeaderboardEntry, hidden map[int64]bool) string {
+	if len(entries) == 0 {
+		return ""
+	}
+	t, _ := time.Parse("2006-01", month)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🏆 **BẢNG XẾP HẠNG DỰ ĐOÁN THÁNG %s**\n", t.Format("01/2006"))
+	medals := []string{"🥇", "🥈", "🥉"}
+	for i, e := range entries {
+		rank := fmt.Sprintf("%d.", i+1)
+		if i < len(medals) {
+			rank = medals[i]
+		}
+		name := e.Name
+		if hidden[e.UserID] || name == "" {
+			name = "Ẩn danh"
+		}
+		fmt.Fprintf(&sb, "%s %s — %d/%d đúng (chuỗi %d)\n", rank, name, e.Correct, e.Votes, e.Streak)
+	}
+	fmt.Fprintf(&sb, "_Cần tối thiểu %d lượt dự đoán để được xếp hạng._", leaderboardMinVotes)
+	return sb.String()
+}
+
+// leaderboardReply handles "/leaderboard [hide|show]"
+func leaderboardReply(userID int64, arg string) string {
+	switch strings.ToLower(strings.TrimSpace(arg)) {
+	case "hide":
+		if err := setPredictorHidden(userID, true); err != nil {
+			return "⚠️ Không thể lưu cài đặt lúc này."
+		}
+		return "🙈 Tên của bạn sẽ hiển thị là \"Ẩn danh\" trên bảng xếp hạng."
+	case "show":
+		if err := setPredictorHidden(userID, false); err != nil {
+			return "⚠️ Không thể lưu cài đặt lúc này."
+		}
+		return "👀 Tên của bạn sẽ hiển thị trên bảng xếp hạng."
+	case "":
+		month := clock().In(vnLocation).Format("2006-01")
+		board := formatLeaderboard(month, loadLeaderboard(month), hiddenPredictors())
+		if board == "" {
+			return fmt.Sprintf("ℹ️ Tháng này chưa có ai đủ %d lượt dự đoán để xếp hạng.", leaderboardMinVotes)
+		}
+		return board
+	default:
+		return "ℹ️ Cú pháp: /leaderboard, /leaderboard hide hoặc /leaderboard show"
+	}
+}
+
+// monthlyLeaderboardAnnouncement returns last month's final ranking the first time it is
+// called in a new (Vietnam-time) month, and "" on every later call that month
+func monthlyLeaderboardAnnouncement() string {
+	if settingsCollection == nil {
+		return ""
+	}
+	month := clock().In(vnLocation).Format("2006-01")
+	id := activeProfile().collectionName("leaderboard_announced")
+	// Claim the month atomically so two concurrent broadcasts can't both announce
+	res, err := settingsCollection.UpdateOne(context.TODO(),
+		bson.M{"_id": id, "month": bson.M{"$ne": month}},
+		bson.M{"$set": bson.M{"month": month}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to claim leaderboard announcement: %v", err)
+		return ""
+	}
+	if res.MatchedCount == 0 {
+		// Either already announced this month, or the marker doesn't exist yet
+		if _, err := settingsCollection.InsertOne(context.TODO(), bson.M{"_id": id, "month": month}); err != nil {
+			return ""
+		}
+	}
+	last := previousMonth(month)
+	return formatLeaderboard(last, loadLeaderboard(last), hiddenPredictors())
+}
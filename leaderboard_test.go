@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// graded builds a user's predictions for consecutive March days from a pattern of
+// 'y' (correct), 'n' (wrong) and '-' (flat, ungraded)
+func graded(userID int64, name, pattern string) []Prediction {
+	var preds []Prediction
+	for i, c := range pattern {
+		p := Prediction{PollID: fmt.Sprintf("%d-%d", userID, i), UserID: userID, Name: name,
+			Date: fmt.Sprintf("2026-03-%02d", i+1), Month: "2026-03"}
+		if c != '-' {
+			correct := c == 'y'
+			p.Correct = &correct
+		}
+		preds = append(preds, p)
+	}
+	return preds
+}
+
+func TestPredictionMonths(t *testing.T) {
+	if got := predictionMonth("2026-03-31"); got != "2026-03" {
+		t.Errorf("predictionMonth = %q", got)
+	}
+	if got := previousMonth("2026-01"); got != "2025-12" {
+		t.Errorf("previousMonth(2026-01) = %q, want 2025-12", got)
+	}
+	if got := previousMonth("2026-03"); got != "2026-02" {
+		t.Errorf("previousMonth(2026-03) = %q", got)
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	tests := []struct {
+		u    *tele.User
+		want string
+	}{
+		{&tele.User{Username: "trader", FirstName: "An"}, "@trader"},
+		{&tele.User{FirstName: "An", LastName: "Nguyễn"}, "An Nguyễn"},
+		{&tele.User{FirstName: "An"}, "An"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := displayName(tt.u); got != tt.want {
+			t.Errorf("displayName(%+v) = %q, want %q", tt.u, got, tt.want)
+		}
+	}
+}
+
+func TestRankPredictions(t *testing.T) {
+	var preds []Prediction
+	preds = append(preds, graded(1, "@a", "yyynyy")...)  // 5 correct, streak 3
+	preds = append(preds, graded(2, "@b", "nyyyyy")...)  // 5 correct, streak 5
+	preds = append(preds, graded(3, "@c", "yyyy")...)    // too few votes
+	preds = append(preds, graded(4, "@d", "yy--yyn")...) // flat days neither count nor break the streak
+	preds = append(preds, graded(5, "@e", "yynyny")...)  // 4 correct
+	entries := rankPredictions(preds)
+
+	var got []string
+	for _, e := range entries {
+		got = append(got, fmt.Sprintf("%s %d/%d s%d", e.Name, e.Correct, e.Votes, e.Streak))
+	}
+	want := "@b 5/6 s5,@a 5/6 s3,@d 4/5 s4,@e 4/6 s2"
+	if strings.Join(got, ",") != want {
+		t.Errorf("ranking = %s, want %s", strings.Join(got, ","), want)
+	}
+
+	var many []Prediction
+	for id := int64(100); id < 100+leaderboardSize+3; id++ {
+		many = append(many, graded(id, "", "yyyyy")...)
+	}
+	if entries := rankPredictions(many); len(entries) != leaderboardSize || entries[0].UserID != 100 {
+		t.Errorf("%d entries starting with %d, want %d with ties by user ID", len(entries), entries[0].UserID, leaderboardSize)
+	}
+}
+
+func TestFormatLeaderboard(t *testing.T) {
+	entries := []LeaderboardEntry{
+		{UserID: 1, Name: "@a", Votes: 6, Correct: 5, Streak: 3},
+		{UserID: 2, Name: "@b", Votes: 6, Correct: 4, Streak: 2},
+		{UserID: 3, Votes: 5, Correct: 3, Streak: 1},
+		{UserID: 4, Name: "@d", Votes: 5, Correct: 2, Streak: 1},
+	}
+	got := formatLeaderboard("2026-03", entries, map[int64]bool{2: true})
+	for _, want := range []string{
+		"🏆 **BẢNG XẾP HẠNG DỰ ĐOÁN THÁNG 03/2026**\n",
+		"🥇 @a — 5/6 đúng (chuỗi 3)\n",
+		"🥈 Ẩn danh — 4/6 đúng (chuỗi 2)\n",
+		"🥉 Ẩn danh — 3/5 đúng (chuỗi 1)\n",
+		"4. @d — 2/5 đúng (chuỗi 1)\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("leaderboard is missing %q:\n%s", want, got)
+		}
+	}
+	if formatLeaderboard("2026-03", nil, nil) != "" {
+		t.Error("an empty month rendered a board")
+	}
+}
+
+func TestLeaderboardReplyWithoutDatabase(t *testing.T) {
+	saved, savedPrefs := predictionCollection, predictorPrefCollection
+	predictionCollection, predictorPrefCollection = nil, nil
+	t.Cleanup(func() { predictionCollection, predictorPrefCollection = saved, savedPrefs })
+	if got := leaderboardReply(1, ""); !strings.Contains(got, "chưa có ai đủ 5 lượt") {
+		t.Errorf("empty board = %q", got)
+	}
+	if got := leaderboardReply(1, "hide"); got != "⚠️ Không thể lưu cài đặt lúc này." {
+		t.Errorf("hide without a database = %q", got)
+	}
+	if got := leaderboardReply(1, "top"); !strings.HasPrefix(got, "ℹ️ Cú pháp") {
+		t.Errorf("unknown argument = %q", got)
+	}
+}
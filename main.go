@@ -3,13 +3,17 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"html"
 	"log"
-	"net/http"
+	"math"
+	"math/rand"
 	"net/url"
 	"os"
 	"os/signal"
+	"regexp"
 	"strconv"
 	"strings"
 	"syscall"
@@ -18,7 +22,6 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/joho/godotenv"
-	"github.com/mmcdole/gofeed"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -27,26 +30,137 @@ import (
 
 // Global variables
 var (
-	cachedUsdVnd    float64
-	lastCacheUpdate time.Time
-	cacheDuration   = 6 * time.Hour
-	userCollection  *mongo.Collection
+	marketDB       *mongo.Database
+	userCollection *mongo.Collection
+	indexesEnsured bool
+	usersMigrated  bool
+	// databaseErr is why the last initDatabase couldn't reach MongoDB; while set the bot
+	// runs degraded (collections nil, quotes still served)
+	databaseErr error
+
+	// Vietnam has no DST, so a fixed zone avoids depending on tzdata in the Lambda image
+	vnLocation = time.FixedZone("ICT", 7*60*60)
+
+	// clock is read wherever the bot needs the current time (report timestamps, cache
+	// ages, poll dates); tests swap it for a fixed time. Broadcast pacing and latency
+	// measurements use the real clock since they actually sleep and time things.
+	clock = time.Now
 )
 
 // PriceResponse updated to include percent_change from API
 type PriceResponse struct {
-	Price         string `json:"price"`
-	PercentChange string `json:"percent_change"`
-	Code          int    `json:"code"`
-	Message       string `json:"message"`
+	Price         FlexFloat `json:"price"`
+	PercentChange FlexFloat `json:"percent_change"`
+	Code          int       `json:"code"`
+	Message       string    `json:"message"`
 }
 
-// MarketData struct to hold both price and formatted change string
+// MarketData struct to hold both price and formatted change string.
+// Err is set when the quote could not be fetched; Price is 0 in that case.
 type MarketData struct {
 	Price  float64
 	Change string
+	Err    error
+	// Percent is the session percent change; HasPercent is false when the API omitted it
+	Percent    float64
+	HasPercent bool
+	// Open is the session's opening price, 0 when the provider didn't give one
+	Open float64
+	// Source is the overriding provider (see /source); empty for the default chain
+	Source string
+}
+
+// MarketReport is a rendered report plus the quotes and headlines it was built from.
+// CardsText is the same report without the news section, for chats that get headlines
+// as separate messages.
+type MarketReport struct {
+	Text      string
+	CardsText string
+	// Plain is the report without Markdown, aligned with spaces (/plaintext on)
+	Plain     string
+	Menu      *tele.ReplyMarkup
+	Quotes    map[string]MarketData
+	Headlines []Headline
+	// UsdVnd is the USD/VND rate the report was rendered with (0 when unavailable)
+	UsdVnd float64
+	// UsdVndSource is where the rate came from when it wasn't Twelve Data (override or
+	// fallback chain), for the snapshot's provenance
+	UsdVndSource string
+	// At is when the report was generated; it stamps the snapshot and deliveries
+	At time.Time
+	// Variants holds the experiment renderings by variant, with tracked links; nil when
+	// no experiment is running
+	Variants map[string]ReportText
+}
+
+// Prepend puts an announcement above every rendering of the report
+func (r *MarketReport) Prepend(text string) {
+	r.Text = text + "\n\n" + r.Text
+	r.CardsText = text + "\n\n" + r.CardsText
+	for v, t := range r.Variants {
+		r.Variants[v] = ReportText{Text: text + "\n\n" + t.Text, CardsText: text + "\n\n" + t.CardsText}
+	}
+}
+
+// Asset describes how a canonical asset ID is labeled and formatted in the report
+type Asset struct {
+	Symbol      string
+	Label       string
+	PriceFormat string
+}
+
+// assetRegistry lists the symbols the report knows how to label
+var assetRegistry = []Asset{
+	{Symbol: "gold", Label: "🟡 Vàng (XAUUSD)", PriceFormat: "`$%.2f`"},
+	{Symbol: "eurusd", Label: "🇪🇺 EURUSD", PriceFormat: "`%.4f`"},
+	{Symbol: "btc", Label: "₿ Bitcoin", PriceFormat: "`$%.2f`"},
+	{Symbol: "eth", Label: "Ξ Ethereum", PriceFormat: "`$%.2f`"},
+	{Symbol: "sol", Label: "◎ Solana", PriceFormat: "`$%.2f`"},
+}
+
+// tradingViewSymbols maps canonical asset IDs to TradingView's EXCHANGE:SYMBOL form,
+// for /export watchlist
+var tradingViewSymbols = map[string]string{
+	"gold":   "OANDA:XAUUSD",
+	"silver": "OANDA:XAGUSD",
+	"eurusd": "FX:EURUSD",
+	"gbpusd": "FX:GBPUSD",
+	"usdjpy": "FX:USDJPY",
+	"usdvnd": "FX_IDC:USDVND",
+	"btc":    "BITSTAMP:BTCUSD",
+	"eth":    "BITSTAMP:ETHUSD",
+	"sol":    "COINBASE:SOLUSD",
+	"aapl":   "NASDAQ:AAPL",
+	"msft":   "NASDAQ:MSFT",
+	"nvda":   "NASDAQ:NVDA",
+	"tsla":   "NASDAQ:TSLA",
+	"spy":    "AMEX:SPY",
+}
+
+// usdAssets are the canonical IDs that aren't pairs but are priced in USD (metals, coins)
+var usdAssets = map[string]bool{"gold": true, "silver": true, "btc": true, "eth": true, "sol": true}
+
+// isPair reports whether a canonical ID is a currency pair ("eurusd", "usdvnd", "eur/gbp")
+func isPair(id string) bool {
+	return strings.Contains(id, "/") || (len(id) == 6 && (strings.HasPrefix(id, "usd") || strings.HasSuffix(id, "usd")))
+}
+
+// lookupAsset returns the registry entry for symbol, or a generic one for unknown symbols
+func lookupAsset(symbol string) Asset {
+	for _, a := range assetRegistry {
+		if a.Symbol == symbol {
+			return a
+		}
+	}
+	return Asset{Symbol: symbol, Label: "📊 " + symbol, PriceFormat: "`%.4f`"}
 }
 
+// maxBatchUpdates bounds how many updates one webhook request may carry
+const maxBatchUpdates = 100
+
+// errRateLimited marks quotes that failed because the Twelve Data credits ran out
+var errRateLimited = errors.New("twelvedata: rate limited")
+
 // --- CONSTANTS ---
 
 const helpMessage = `📖 *HƯỚNG DẪN SỬ DỤNG BOT*
@@ -55,16 +169,108 @@ Dưới đây là danh sách các lệnh bạn có thể sử dụng:
 
 🚀 *Khởi đầu:*
 /start - Đăng ký nhận bản tin thị trường tự động hàng ngày.
+/donate - Ủng hộ bot và nâng cấp gói premium.
 
 📊 *Tra cứu:*
-/update - Xem ngay báo cáo thị trường mới nhất (Vàng, BTC, Ngoại tệ & Tin tức).
+/report - Xem ngay báo cáo thị trường mới nhất (Vàng, BTC, Ngoại tệ & Tin tức).
+/news hoặc /news en - Xem tin tức mới nhất (en: bản gốc tiếng Anh, chỉ cho lần này).
+/follow "bitcoin etf" - Theo dõi một từ khóa: bot báo khi chủ đề này xuất hiện nhiều bất thường trong tin tức (/unfollow để bỏ, /follow để xem). Tính năng premium.
+/usdvnd YYYY-MM-DD - Tra cứu tỷ giá USD/VND của một ngày trong quá khứ.
+/extended AAPL - Xem giá cổ phiếu Mỹ ngoài giờ (pre/post-market).
+/corr btc eth 30d - Hệ số tương quan giữa hai tài sản.
+/correlation - Ma trận tương quan 30 ngày của vàng, BTC, EUR/USD, DXY.
+/vol btc 30d - Độ biến động thực tế (năm hóa) và đánh giá thấp/bình thường/cao.
+/open btc gold - Thay đổi từ giá mở cửa hôm nay, bên cạnh thay đổi so với đóng cửa phiên trước.
+/spread eurusd gbpusd - Tỷ lệ và chênh lệch giữa hai mã, kèm thay đổi trong phiên.
+/symbols crypto - Các mã được hỗ trợ theo nhóm (ngoại tệ, tiền mã hóa, chỉ số, hàng hóa, cổ phiếu).
+/convert 100 USD VND - Quy đổi tiền tệ hoặc tài sản (ví dụ /convert 0.5 BTC VND).
+@tên\_bot 500 usd - Quy đổi nhanh ngay trong nhóm (usd, đô, lượng, chỉ, btc, vnd).
+/history csv 30 - Xuất giá của 30 bản tin gần nhất thành tệp CSV.
+/export watchlist hoặc /export alerts - Xuất danh sách theo dõi (định dạng TradingView) hoặc cảnh báo (CSV).
+/watch add btc hoặc /watch remove btc - Quản lý danh sách theo dõi (/watch để xem).
+/import BTC/USD, ETH/USD, OANDA:XAUUSD - Thêm nhiều mã vào danh sách theo dõi cùng lúc (hoặc trả lời tin nhắn chứa danh sách bằng /import).
+/trywatch btc,eth - Xem trước giá các mã mà không lưu vào danh sách theo dõi.
+/setherethread - (Nhóm có Chủ đề) Quản trị viên gõ trong một chủ đề để bot gửi bản tin vào đó.
+/board on hoặc /board off - Ghim bảng giá danh sách theo dõi, tự cập nhật tại chỗ.
+/newsmode cards hoặc /newsmode list - Nhận tin tức thành từng tin nhắn riêng có ảnh xem trước, hoặc dạng danh sách gọn (mặc định).
+/newsmode split - Tách tin: bảng giá và tin tức thành hai tin nhắn để chuyển tiếp riêng.
+/plaintext on|off - Nhận bản tin dạng văn bản thuần (không định dạng), cột số được căn thẳng hàng.
+/movethreshold 2% - Chỉ hiện các mã biến động từ 2% trở lên trong mục biến động đáng chú ý.
+/format vn|intl - Định dạng số: 1.234.567 (mặc định) hoặc 1,234,567.
+/settings silent on|off|auto - Bản tin im lặng: luôn, không bao giờ, hoặc tự động trong giờ yên tĩnh (22:00–07:00).
+/settings pin on|off - Ghim bản tin mới nhất lên đầu cuộc trò chuyện.
 /help - Xem danh sách lệnh và hướng dẫn này.
 
+🗳 *Bình chọn:*
+/poll off hoặc /poll on - Tắt/bật câu hỏi dự đoán giá vàng gửi kèm bản tin sáng (trừ cuối tuần).
+/leaderboard - Bảng xếp hạng dự đoán đúng trong tháng (/leaderboard hide để hiện là "Ẩn danh", /leaderboard show để hiện tên).
+
+🔔 *Cảnh báo:*
+/alert btc above 70000 - Báo khi giá vượt (hoặc below: giảm dưới) một mức. Thêm repeat để báo mỗi lần giá chạm lại mức này.
+/alert btc - Chọn nhanh mức giá cảnh báo từ bàn phím.
+/alert btc move 3% - Báo khi giá biến động 3% trong phiên (thêm since để tính từ lúc tạo).
+/trail btc 3% - Trailing stop: báo khi giá giảm 3% từ đỉnh kể từ lúc đặt.
+/alerts - Xem cảnh báo đang hoạt động (/alerts delete 2 để xóa).
+/alertall 5% - Tạo cảnh báo biến động cho mọi mã trong danh sách theo dõi.
+/clearalerts - Xóa tất cả cảnh báo.
+/portfolioalert < 100000000 - Báo khi tổng giá trị danh mục (VNĐ) giảm dưới (hoặc >: vượt trên) một ngưỡng.
+
+💼 *Danh mục:*
+/portfolio add btc 0.5 - Thêm tài sản (remove để bỏ, /portfolio để xem giá trị).
+/buy gold 0.1 7500000 VND - Ghi nhận lần mua (giá mỗi đơn vị, USD hoặc VND, thêm ngày YYYY-MM-DD nếu cần) để xem lãi/lỗ.
+/target btc 75000 - Đặt giá mục tiêu cho một mã trong danh mục (off để bỏ); /portfolio hiển thị tiến độ.
+/performance - Thay đổi giá trị danh mục 7 ngày, 30 ngày, từ đầu tháng kèm biểu đồ nhỏ.
+/allocation - Tỷ trọng từng tài sản trong danh mục, cảnh báo khi một mã chiếm quá 50%.
+
 ❌ *Ngừng nhận tin:*
-/quit hoặc /cancel - Hủy đăng ký và xóa dữ liệu của bạn khỏi hệ thống nhận tin tự động.
+/quit - Hủy đăng ký và xóa dữ liệu của bạn khỏi hệ thống nhận tin tự động.
 
 💡 *Mẹo:* Bạn có thể nhấn nút "Cập nhật giá mới" bên dưới mỗi bản tin để làm mới dữ liệu nhanh chóng.`
 
+// --- CONFIG HELPERS ---
+
+// envDuration reads a duration env var (e.g. "3m"), falling back to def when unset or invalid
+func envDuration(name string, def time.Duration) time.Duration {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		log.Printf("[CONFIG] Invalid %s=%q, using %s", name, raw, def)
+		return def
+	}
+	return d
+}
+
+// envInt reads a positive integer env var, falling back to def when unset or invalid
+func envInt(name string, def int) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		log.Printf("[CONFIG] Invalid %s=%q, using %d", name, raw, def)
+		return def
+	}
+	return n
+}
+
+// envFloat reads a positive float env var, falling back to def when unset or invalid
+func envFloat(name string, def float64) float64 {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil || f <= 0 {
+		log.Printf("[CONFIG] Invalid %s=%q, using %g", name, raw, def)
+		return def
+	}
+	return f
+}
+
 // --- DATABASE LOGIC ---
 
 // initDatabase initializes connection to MongoDB Atlas
@@ -75,217 +281,762 @@ func initDatabase() {
 	defer cancel()
 
 	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err == nil {
+		// Connect is lazy; without a ping an unreachable cluster only shows up as every
+		// later query hanging until its server-selection timeout
+		err = client.Ping(ctx, nil)
+	}
 	if err != nil {
-		log.Printf("[DATABASE ERROR] Connection failed: %v", err)
+		log.Printf("[DATABASE ERROR] Connection failed, running degraded: %v", err)
+		databaseErr = err
+		bindCollections(nil)
 		return
 	}
-	userCollection = client.Database("market_bot").Collection("users")
+	databaseErr = nil
+	bindCollections(client.Database("market_bot"))
 	log.Println("[DATABASE] Connected to MongoDB Atlas")
+	ensureIndexes()
+	migrateUsers()
+}
+
+// databaseAvailable is the capability check for handlers that must persist something
+func databaseAvailable() bool {
+	return databaseErr == nil && userCollection != nil
+}
+
+// bindCollections points the collection globals at db; a nil db clears them, so every
+// store helper takes its "no database" path instead of waiting on a dead cluster
+func bindCollections(db *mongo.Database) {
+	coll := func(name string) *mongo.Collection {
+		if db == nil {
+			return nil
+		}
+		return db.Collection(name)
+	}
+	marketDB = db
+	userCollection = coll(activeProfile().collectionName("users"))
+	settingsCollection = coll("settings")
+	pollCollection = coll(activeProfile().collectionName("polls"))
+	broadcastLogCollection = coll(activeProfile().collectionName("broadcast_log"))
+	clickCollection = coll(activeProfile().collectionName("clicks"))
+	snapshotCollection = coll(activeProfile().collectionName("snapshots"))
+	alertCollection = coll(activeProfile().collectionName("alerts"))
+	predictionCollection = coll(activeProfile().collectionName("predictions"))
+	predictorPrefCollection = coll(activeProfile().collectionName("predictor_prefs"))
+	newsSetCollection = coll(activeProfile().collectionName("news_sets"))
+	adminCollection = coll(activeProfile().collectionName("admins"))
+	usageCollection = coll(activeProfile().collectionName("command_usage"))
+	unknownInputCollection = coll(activeProfile().collectionName("unknown_inputs"))
+	portfolioHistoryCollection = coll(activeProfile().collectionName("portfolio_history"))
+	newsArchiveCollection = coll("news_archive")
+	newsSentimentCollection = coll("news_sentiment_daily")
+	keywordSpikeCollection = coll(activeProfile().collectionName("keyword_spikes"))
+	metricsCollection = coll(activeProfile().collectionName("metrics"))
+	// Symbol metadata describes the provider, not the bot variant, so profiles share it
+	symbolMetaCollection = coll("symbol_meta")
+	symbolProbeCollection = coll("symbol_probes")
+	symbolHealthCollection = coll("symbol_health")
+	outboxCollection = coll(activeProfile().collectionName("outbox"))
+	textHintCollection = coll(activeProfile().collectionName("text_hints"))
+	failedUpdateCollection = coll(activeProfile().collectionName("failed_updates"))
+	replyRetryCollection = coll(activeProfile().collectionName("reply_retries"))
+	store = nil
+	if db != nil {
+		store = mongoStore{users: userCollection, alerts: alertCollection}
+	}
+}
+
+// ensureIndexes creates the chat_id and (poll_id, user_id) indexes once per container; CreateOne is a no-op if it exists
+func ensureIndexes() {
+	if indexesEnsured || userCollection == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := userCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "chat_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to ensure chat_id index: %v", err)
+		return
+	}
+	if predictionCollection != nil {
+		_, err = predictionCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "poll_id", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		if err != nil {
+			log.Printf("[DATABASE ERROR] Failed to ensure predictions index: %v", err)
+			return
+		}
+	}
+	if newsSetCollection != nil {
+		_, err = newsSetCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "created_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(newsSetTTL.Seconds())),
+		})
+		if err != nil {
+			log.Printf("[DATABASE ERROR] Failed to ensure news set TTL index: %v", err)
+			return
+		}
+	}
+	if metricsCollection != nil {
+		_, err = metricsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(metricsRetention.Seconds())),
+		})
+		if err != nil {
+			log.Printf("[DATABASE ERROR] Failed to ensure metrics TTL index: %v", err)
+			return
+		}
+	}
+	if newsArchiveCollection != nil {
+		_, err = newsArchiveCollection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "seen_at", Value: 1}}})
+		if err != nil {
+			log.Printf("[DATABASE ERROR] Failed to ensure news archive index: %v", err)
+			return
+		}
+	}
+	if portfolioHistoryCollection != nil {
+		_, err = portfolioHistoryCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "chat_id", Value: 1}, {Key: "day", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		if err != nil {
+			log.Printf("[DATABASE ERROR] Failed to ensure portfolio history index: %v", err)
+			return
+		}
+	}
+	if symbolMetaCollection != nil {
+		_, err = symbolMetaCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "fetched_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(symbolMetaTTL.Seconds())),
+		})
+		if err != nil {
+			log.Printf("[DATABASE ERROR] Failed to ensure symbol metadata TTL index: %v", err)
+			return
+		}
+	}
+	if outboxCollection != nil {
+		for _, keys := range []bson.D{{{Key: "at", Value: 1}}, {{Key: "chat_id", Value: 1}, {Key: "at", Value: -1}}} {
+			opts := options.Index()
+			if len(keys) == 1 {
+				opts.SetExpireAfterSeconds(int32(outboxRetention.Seconds()))
+			}
+			if _, err = outboxCollection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: keys, Options: opts}); err != nil {
+				log.Printf("[DATABASE ERROR] Failed to ensure outbox index: %v", err)
+				return
+			}
+		}
+	}
+	if textHintCollection != nil {
+		_, err = textHintCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(textHintRetention.Seconds())),
+		})
+		if err != nil {
+			log.Printf("[DATABASE ERROR] Failed to ensure text hint TTL index: %v", err)
+			return
+		}
+	}
+	if failedUpdateCollection != nil {
+		_, err = failedUpdateCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(failedUpdateRetention.Seconds())),
+		})
+		if err != nil {
+			log.Printf("[DATABASE ERROR] Failed to ensure failed update TTL index: %v", err)
+			return
+		}
+	}
+	if replyRetryCollection != nil {
+		_, err = replyRetryCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(replyRetryRetention.Seconds())),
+		})
+		if err != nil {
+			log.Printf("[DATABASE ERROR] Failed to ensure reply retry TTL index: %v", err)
+			return
+		}
+	}
+	if symbolProbeCollection != nil {
+		_, err = symbolProbeCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "probed_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(symbolProbeTTL.Seconds())),
+		})
+		if err != nil {
+			log.Printf("[DATABASE ERROR] Failed to ensure symbol probe TTL index: %v", err)
+			return
+		}
+	}
+	indexesEnsured = true
 }
 
-// loadUsers retrieves all subscribed chat IDs
+// loadUsers retrieves all subscribed chat IDs, leaving out chats marked dead
 func loadUsers() map[int64]bool {
 	users := make(map[int64]bool)
-	if userCollection == nil {
+	if store == nil {
 		log.Println("[DATABASE ERROR] Collection is nil")
 		return users
 	}
-	cursor, err := userCollection.Find(context.TODO(), bson.M{})
-	if err != nil {
-		log.Printf("[DATABASE ERROR] Failed to find users: %v", err)
-		return users
-	}
-	defer cursor.Close(context.TODO())
-
-	for cursor.Next(context.TODO()) {
-		var result struct {
-			ChatID int64 `bson:"chat_id"`
+	for after := int64(math.MinInt64); ; {
+		page, err := store.Subscribers(context.TODO(), after, subscriberPageSize)
+		if err != nil {
+			log.Printf("[DATABASE ERROR] Failed to find users: %v", err)
+			return users
+		}
+		for _, id := range page {
+			users[id] = true
+		}
+		if len(page) < subscriberPageSize {
+			return users
 		}
-		cursor.Decode(&result)
-		users[result.ChatID] = true
+		after = page[len(page)-1]
 	}
-	return users
 }
 
-// saveUser adds or updates a user chat ID in the database
-func saveUser(id int64) {
-	if userCollection == nil {
+// saveUser adds or updates a user chat ID in the database. Defaults are only seeded on
+// insert, so a repeated /start never resets an existing user's settings; returns true
+// when the user was already subscribed.
+func saveUser(id int64) bool {
+	if store == nil {
 		log.Println("[DATABASE ERROR] Cannot save, collection is nil")
-		return
+		return false
 	}
-	filter := bson.M{"chat_id": id}
-	update := bson.M{"$set": bson.M{"chat_id": id, "updated_at": time.Now()}}
-	_, err := userCollection.UpdateOne(context.TODO(), filter, update, options.Update().SetUpsert(true))
+	existed, err := store.SaveUser(context.TODO(), id, clock())
 	if err != nil {
 		log.Printf("[DATABASE ERROR] Failed to save user %d: %v", id, err)
-	} else {
-		log.Printf("[DATABASE] User %d saved/updated", id)
+		return false
 	}
+	log.Printf("[DATABASE] User %d saved/updated", id)
+	return existed
 }
 
 // removeUser deletes a user from MongoDB by chat ID
 func removeUser(id int64) bool {
-	if userCollection == nil {
+	if store == nil {
 		log.Println("[DATABASE ERROR] Cannot delete, collection is nil")
 		return false
 	}
-	filter := bson.M{"chat_id": id}
-	result, err := userCollection.DeleteOne(context.TODO(), filter)
+	removed, err := store.RemoveUser(context.TODO(), id)
 	if err != nil {
 		log.Printf("[DATABASE ERROR] Failed to remove user %d: %v", id, err)
 		return false
 	}
-	return result.DeletedCount > 0
+	return removed
 }
 
-// --- MARKET DATA LOGIC ---
+// isValidChatID rejects IDs Telegram can never issue: zero, and anything outside
+// the 52-bit range Telegram guarantees for user, group and supergroup IDs
+func isValidChatID(id int64) bool {
+	const maxChatID = 1 << 52
+	return id != 0 && id > -maxChatID && id < maxChatID
+}
 
-// getMarketData fetches financial data from Twelve Data API
-func getMarketData(symbol string, apiKey string) MarketData {
-	log.Printf("[API] Fetching quote for %s...", symbol)
-	apiUrl := fmt.Sprintf("https://api.twelvedata.com/quote?symbol=%s&apikey=%s", symbol, apiKey)
-	// Explicit client with timeout to prevent Lambda from hanging
-	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Get(apiUrl)
+// removeInvalidUsers deletes documents whose chat ID can never receive a message
+func removeInvalidUsers(ids []int64) {
+	if userCollection == nil || len(ids) == 0 {
+		return
+	}
+	result, err := userCollection.DeleteMany(context.TODO(), bson.M{"chat_id": bson.M{"$in": ids}})
 	if err != nil {
-		log.Printf("[API ERROR] Request failed for %s: %v", symbol, err)
-		return MarketData{Price: 0, Change: "0.00%"}
+		log.Printf("[DATABASE ERROR] Failed to remove invalid users: %v", err)
+		return
 	}
-	defer resp.Body.Close()
+	log.Printf("[DATABASE] Removed %d user(s) with invalid chat IDs", result.DeletedCount)
+}
 
-	var result struct {
-		Close         string `json:"close"`
-		PercentChange string `json:"percent_change"`
-		Message       string `json:"message"`
-	}
-	json.NewDecoder(resp.Body).Decode(&result)
+// --- MARKET DATA LOGIC ---
 
-	if result.Message != "" {
-		log.Printf("[API ERROR] Message from TwelveData for %s: %s", symbol, result.Message)
-		return MarketData{Price: 0, Change: "N/A"}
+// translateToVietnamese uses Google Apps Script to translate one news headline, giving up
+// after timeout or when ctx ends
+func translateToVietnamese(ctx context.Context, text string, timeout time.Duration) string {
+	scriptURL := os.Getenv("GOOGLE_SCRIPT_URL")
+	if scriptURL == "" {
+		return text
 	}
-
-	p, _ := strconv.ParseFloat(result.Close, 64)
-	c, _ := strconv.ParseFloat(result.PercentChange, 64)
-	// Format change string with market trend indicators
-	changeStr := fmt.Sprintf("%.2f%%", c)
-	if c > 0 {
-		changeStr = "📈 +" + changeStr
-	} else if c < 0 {
-		changeStr = "📉 " + changeStr
+	apiURL := fmt.Sprintf("%s?text=%s&source=en&target=vi", scriptURL, url.QueryEscape(text))
+	countTranslation()
+	body, err := fetchBody(ctx, apiURL, timeout, textContentTypes)
+	if err != nil {
+		log.Printf("[TRANSLATE ERROR] %v", err)
+		return text
+	}
+	cleaned, ok := cleanTranslation(string(body))
+	if !ok {
+		log.Printf("[TRANSLATE ERROR] Unusable translation for %q: %q", text, truncateForLog(string(body), 120))
+		return text
 	}
+	return cleaned
+}
 
-	return MarketData{Price: p, Change: changeStr}
+// isQuotePair reports whether first and last form a matching pair of wrapping quotes
+func isQuotePair(first, last rune) bool {
+	return (first == '"' && last == '"') || (first == '“' && last == '”') || (first == '\'' && last == '\'')
 }
 
-// getCachedUsdVnd manages caching for USD/VND rates to save API credits
-func getCachedUsdVnd(apiKey string) (float64, error) {
-	if time.Since(lastCacheUpdate) < cacheDuration && cachedUsdVnd > 0 {
-		log.Println("[CACHE] Using cached USD/VND rate")
-		return cachedUsdVnd, nil
+// htmlTagPattern matches a single HTML tag such as <b>, </span> or <br/>
+var htmlTagPattern = regexp.MustCompile(`<[^<>]+>`)
+
+// cleanTranslation turns the Apps Script output into a plain headline: inline tags and
+// entities are stripped and wrapping quotes trimmed. ok is false for whole HTML pages
+// (error or login pages) and anything that still doesn't look like plain text.
+func cleanTranslation(raw string) (string, bool) {
+	s := strings.TrimSpace(raw)
+	lower := strings.ToLower(s)
+	if strings.Contains(lower, "<html") || strings.Contains(lower, "<!doctype") || strings.Contains(lower, "<body") {
+		return "", false
 	}
-	data := getMarketData("USD/VND", apiKey)
-	if data.Price == 0 {
-		return 25000, fmt.Errorf("API_ERROR")
+	s = htmlTagPattern.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = strings.Join(strings.Fields(s), " ")
+	for {
+		r := []rune(s)
+		if len(r) < 2 || !isQuotePair(r[0], r[len(r)-1]) {
+			break
+		}
+		s = strings.TrimSpace(string(r[1 : len(r)-1]))
+	}
+	if s == "" || strings.ContainsAny(s, "<>") {
+		return "", false
 	}
-	cachedUsdVnd = data.Price
-	lastCacheUpdate = time.Now()
-	return cachedUsdVnd, nil
+	return s, true
 }
 
-// translateToVietnamese uses Google Apps Script to translate news headlines
-func translateToVietnamese(text string) string {
-	scriptURL := os.Getenv("GOOGLE_SCRIPT_URL")
-	if scriptURL == "" {
-		return text
-	}
-	apiURL := fmt.Sprintf("%s?text=%s&source=en&target=vi", scriptURL, url.QueryEscape(text))
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(apiURL)
-	if err != nil || resp == nil {
-		return text
+// formatPercent formats a percent change with market trend indicators
+func formatPercent(c float64) string {
+	s := fmt.Sprintf("%.2f%%", c)
+	if c > 0 {
+		return "📈 +" + s
+	} else if c < 0 {
+		return "📉 " + s
 	}
-	defer resp.Body.Close()
-	body, _ := ioutil.ReadAll(resp.Body)
-	return string(body)
+	return s
 }
 
 // formatVnd adds thousands separators to currency values
 func formatVnd(val float64) string {
-	str := fmt.Sprintf("%.0f", val)
-	var result []string
-	for i := len(str); i > 0; i -= 3 {
-		start := i - 3
-		if start < 0 {
-			start = 0
+	return formatNumber(val, 0, numberStyleVN)
+}
+
+// allRateLimited reports whether every quote failed because the API credits ran out
+func allRateLimited(quotes ...MarketData) bool {
+	for _, q := range quotes {
+		if !errors.Is(q.Err, errRateLimited) {
+			return false
 		}
-		result = append([]string{str[start:i]}, result...)
 	}
-	return strings.Join(result, ".")
+	return len(quotes) > 0
+}
+
+// quoteLine renders one asset row of the report, or a placeholder when its quote failed
+func quoteLine(label string, priceFormat string, d MarketData) string {
+	if errors.Is(d.Err, errSymbolQuarantined) {
+		return fmt.Sprintf("• %s: ⏸ tạm ngưng (mã không còn dữ liệu)", label)
+	}
+	if d.Err != nil {
+		return fmt.Sprintf("• %s: ⚠️ không có dữ liệu", label)
+	}
+	return fmt.Sprintf("• %s: "+priceFormat+" (%s)", label, d.Price, d.Change)
 }
 
 // getMarketUpdate aggregates all market news and data into a single message
 func getMarketUpdate() (string, *tele.ReplyMarkup) {
+	report := buildMarketReport()
+	return fitEntityLimit(report.Text), report.Menu
+}
+
+// buildMarketReport renders the report and keeps the quotes it was built from, keyed by
+// symbol, for features that need the raw numbers (polls, snapshots)
+func buildMarketReport() MarketReport {
 	log.Println("[SYSTEM] Generating market update report...")
 	apiKey := os.Getenv("TWELVE_DATA_API_KEY")
-	now := time.Now()
-	dateStr := now.Format("02/01/2006 15:04:05")
-
-	gold := getMarketData("XAU/USD", apiKey)
-	eur := getMarketData("EUR/USD", apiKey)
-	btc := getMarketData("BTC/USD", apiKey)
-	usdToVnd, _ := getCachedUsdVnd(apiKey)
-
-	if gold.Price == 0 {
-		return fmt.Sprintf("📅 **Bản tin [%s]**\n⚠️ API credits exhausted or market closed.", dateStr), nil
-	}
-
-	log.Println("[RSS] Fetching news from Investing.com...")
-	fp := gofeed.NewParser()
-	feed, _ := fp.ParseURL("https://www.investing.com/rss/news_25.rss")
-	newsList := ""
-	if feed != nil {
-		for i, item := range feed.Items {
-			if i >= 8 {
-				break
-			}
-			viTitle := translateToVietnamese(item.Title)
-			newsList += fmt.Sprintf("🔹 **%s**\n🔗 [Xem chi tiết](%s)\n\n", viTitle, item.Link)
-		}
-	}
-
-	report := fmt.Sprintf(
-		"💰 **NHỊP ĐẬP THỊ TRƯỜNG**\n📅 *Cập nhật: %s*\n"+
-			"━━━━━━━━━━━━━━━━━━\n\n"+
-			"🔴 **TIN TỨC QUAN TRỌNG:**\n\n%s"+
-			"📈 **XU HƯỚNG THỊ TRƯỜNG:**\n"+
-			"• 💵 Tỷ giá USD/VND: 1$ ≈ **%s VNĐ**\n"+
-			"• 🟡 Vàng (XAUUSD): `$%.2f` (%s)\n"+
-			"• 🇪🇺 EURUSD: `%.4f` (%s)\n"+
-			"• ₿ Bitcoin: `$%.2f` (%s)\n\n"+
-			"━━━━━━━━━━━━━━━━━━\n"+
-			"💡 *Nhấn nút bên dưới để cập nhật nhanh*",
-		dateStr, newsList, formatVnd(usdToVnd),
-		gold.Price, gold.Change,
-		eur.Price, eur.Change,
-		btc.Price, btc.Change,
+	cfg := loadConfig()
+	now := clock()
+	dateStr := formatDateTime(now, newsLangVI, vnLocation)
+
+	batch := getMarketDataBatch(cfg.Symbols, apiKey)
+	quotes := make([]MarketData, len(cfg.Symbols))
+	for i, symbol := range cfg.Symbols {
+		quotes[i] = batch[symbol]
+	}
+	usdRate, rateErr := getUsdVndRate(apiKey)
+	usdToVnd := usdRate.Rate
+	usdLine := "1$ ≈ **" + formatVnd(usdToVnd) + " VNĐ**" + usdVndNote(usdRate)
+	if rateErr != nil {
+		usdLine = "⚠️ không có dữ liệu"
+	}
+	holidays := activeHolidays(now, cfg.Holidays)
+	if _, ok := holidays[holidayCalendarVN]; ok && rateErr == nil {
+		usdLine += holidayQuoteNote
+	}
+
+	// Only give up entirely when every quote was refused for lack of API credits;
+	// otherwise render whatever came back and flag the missing assets inline
+	bySymbol := make(map[string]MarketData, len(quotes))
+	for i, symbol := range cfg.Symbols {
+		bySymbol[symbol] = quotes[i]
+	}
+	if allRateLimited(quotes...) {
+		text := fmt.Sprintf("📅 **Bản tin [%s]**\n⚠️ API credits exhausted or market closed.", dateStr)
+		return MarketReport{Text: text, CardsText: text, Plain: stripMarkdown(text), Quotes: bySymbol, At: now}
+	}
+
+	headlines, originals := fetchHeadlines(cfg, newsLangVI)
+
+	rows := make([]string, len(cfg.Symbols))
+	for i, symbol := range cfg.Symbols {
+		asset := lookupAsset(symbol)
+		rows[i] = quoteLine(asset.Label, asset.PriceFormat, quotes[i])
+		if _, ok := holidays[holidayCalendar(symbol)]; ok && quotes[i].Err == nil {
+			rows[i] += holidayQuoteNote
+		}
+	}
+
+	marketSection := fmt.Sprintf(
+		"📈 **XU HƯỚNG THỊ TRƯỜNG:**\n"+
+			"• 💵 Tỷ giá USD/VND: %s\n"+
+			"%s\n\n",
+		usdLine, strings.Join(rows, "\n")+sourcesNote(cfg.Symbols, bySymbol),
 	)
+	render := func(news string, quotesFirst bool) string {
+		body := news + marketSection
+		if quotesFirst {
+			body = marketSection + news
+		}
+		return fmt.Sprintf(
+			"%s\n📅 *Cập nhật: %s*\n"+
+				"━━━━━━━━━━━━━━━━━━\n\n"+
+				"%s"+
+				"━━━━━━━━━━━━━━━━━━\n"+
+				"💡 *Nhấn nút bên dưới để cập nhật nhanh*",
+			activeProfile().Title, dateStr, body,
+		)
+	}
+	plain := func(link string) string { return link }
+
+	prices := make(map[string]float64, len(bySymbol))
+	changes := make(map[string]float64, len(bySymbol))
+	for symbol, d := range bySymbol {
+		if d.Err == nil && d.Price > 0 {
+			prices[symbol] = d.Price
+			if d.HasPercent {
+				changes[symbol] = d.Percent
+			}
+		}
+	}
+	setID := saveNewsSet(NewsSet{
+		Original:   originals,
+		Translated: headlines,
+		Symbols:    cfg.Symbols,
+		Prices:     prices,
+		Changes:    changes,
+		UsdVnd:     usdToVnd,
+	})
+	menu := reportMenu(setID, newsLangVI, len(headlines) > 0)
+
+	report := MarketReport{
+		Text:      render(renderNewsSection(headlines, plain), false),
+		CardsText: render("", false),
+		Plain:     renderPlainReport(activeProfile().Title, now, cfg.Symbols, bySymbol, usdToVnd, headlines),
+		Menu:      menu,
+		Quotes:    bySymbol,
+		UsdVnd:    usdToVnd,
+		Headlines: headlines,
+		At:        now,
+	}
+	if usdRate.Source != rateSourceLive && usdRate.Source != rateSourceCache {
+		report.UsdVndSource = usdRate.Source
+	}
+	if exp := cfg.Experiment; exp.active() {
+		track := func(variant string) func(string) string {
+			return func(link string) string { return trackedLink(exp, variant, link) }
+		}
+		treatment := headlines
+		if exp.NewsCount > 0 && exp.NewsCount < len(treatment) {
+			treatment = treatment[:exp.NewsCount]
+		}
+		report.Variants = map[string]ReportText{
+			variantControl: {
+				Text:      render(renderNewsSection(headlines, track(variantControl)), false),
+				CardsText: report.CardsText,
+			},
+			variantTreatment: {
+				Text:      render(renderNewsSection(treatment, track(variantTreatment)), exp.QuotesFirst),
+				CardsText: render("", exp.QuotesFirst),
+			},
+		}
+	}
+	if banner := holidayBanner(holidays); banner != "" {
+		report.Prepend(banner)
+		report.Plain = stripMarkdown(banner) + "\n\n" + report.Plain
+	}
+	return report
+}
+
+// --- BROADCAST ---
+
+// broadcastReport sends the scheduled report to every subscriber in staggered chunks.
+// With a jitter window configured, chunks are spread evenly across the window (plus a
+// random offset) so Telegram and the quote API don't take the whole load in one burst.
+// Only the scheduled broadcast is paced; interactive replies are always sent immediately.
+// slotSilent is the schedule slot's silent flag; nil leaves it to the quiet hours.
+// Progress is checkpointed so /resume can finish a run that dies midway; resume marks
+// such a run, which continues the existing checkpoint instead of starting one.
+// Per-recipient database writes are batched for the run and flushed before every
+// checkpoint write.
+func broadcastReport(b *tele.Bot, users map[int64]bool, report MarketReport, slotSilent *bool, resume bool) {
+	batch := startWriteBatch()
+	defer finishWriteBatch(batch)
+	cfg := loadConfig()
+	silentPrefs := loadSilentPrefs()
+	pins := loadPinnedReports()
+	quiet := inQuietHours(clock(), cfg.QuietStart, cfg.QuietEnd)
+	aud := loadBroadcastAudience()
+	cardUsers, splitUsers, plainUsers, channels := aud.Cards, aud.Split, aud.Plain, aud.Channels
+	threads := loadThreadIDs()
+	exp := cfg.Experiment
+	sentByVariant := make(map[string]int)
+	var delivered []int64
+	window := cfg.BroadcastJitterWindow
+	chunkSize := cfg.BroadcastChunkSize
+
+	ids := make([]int64, 0, len(users))
+	var invalid []int64
+	for id := range users {
+		if !isValidChatID(id) {
+			log.Printf("[BROADCAST WARNING] Skipping invalid chat ID %d", id)
+			invalid = append(invalid, id)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	removeInvalidUsers(invalid)
+	// Shuffle so the same subscribers aren't always at the front of the queue
+	rand.Shuffle(len(ids), func(i, j int) { ids[i], ids[j] = ids[j], ids[i] })
+
+	botUsername := ""
+	if b.Me != nil {
+		botUsername = b.Me.Username
+	}
+	plan := planBroadcastFor(report, ids, aud, botUsername, true)
+	trackWatchedSymbols(b, plan.Quotes, aud.Watchlists)
+	var card *channelCard
+	if len(channels) > 0 {
+		card = newChannelCard(b, report, cfg.Symbols)
+	}
+	if !resume {
+		startCheckpoint(report, ids, slotSilent)
+	}
 
-	menu := &tele.ReplyMarkup{}
-	btnUpdate := menu.Data("🔄 Cập nhật giá mới", "btn_update_price")
-	menu.Inline(menu.Row(btnUpdate))
+	chunks := (len(ids) + chunkSize - 1) / chunkSize
+	var spacing time.Duration
+	if window > 0 && chunks > 1 {
+		spacing = window / time.Duration(chunks)
+	}
+	log.Printf("[BROADCAST] Sending to %d users in %d chunks (window %s)", len(ids), chunks, window)
 
-	return report, menu
+	start := time.Now()
+	sent := 0
+	// A retry after a flood wait resumes where the chat left off: the market half of a split
+	// report is never sent twice
+	marketSent := make(map[int64]*tele.Message)
+	newsSent := make(map[int64]int)
+	sendTo := func(id int64) error {
+		silent := broadcastSilent(silentPrefs[id], slotSilent, quiet)
+		opts := &tele.SendOptions{
+			ParseMode:             tele.ModeMarkdown,
+			ReplyMarkup:           report.Menu,
+			DisableWebPagePreview: true,
+			DisableNotification:   silent,
+		}
+		if channels[id] {
+			// Readers can't use the buttons; a press would edit the post for everyone
+			opts.ReplyMarkup = nil
+		} else if plainUsers[id] {
+			opts.ParseMode = tele.ModeDefault
+		}
+		msg, ok := marketSent[id]
+		if !ok {
+			var err error
+			msg, err = deliver(b, id, threads[id], plan.textFor(id), opts)
+			if err != nil {
+				log.Printf("[BROADCAST ERROR] Failed to send to %d: %v", id, err)
+				return err
+			}
+			marketSent[id] = msg
+			if channels[id] && card != nil {
+				card.send(b, id, threads[id])
+			}
+			if previous, ok := pins[id]; ok {
+				pinReport(b, msg, previous)
+			}
+			if cardUsers[id] && !plainUsers[id] && !channels[id] {
+				sendNewsCards(b, id, threads[id], report.Headlines, silent)
+			}
+			sentByVariant[assignVariant(exp, id)]++
+			delivered = append(delivered, id)
+			sent++
+		}
+		if splitUsers[id] && !channels[id] && len(report.Headlines) > 0 {
+			// The keyboard stays on the market half; the news half replies to it
+			news, err := deliver(b, id, threads[id], splitNewsText(report.Headlines, plainUsers[id]), &tele.SendOptions{ParseMode: opts.ParseMode,
+				DisableWebPagePreview: true, DisableNotification: true, ReplyTo: msg})
+			if err != nil {
+				log.Printf("[BROADCAST ERROR] Failed to send news half to %d: %v", id, err)
+				return err
+			}
+			newsSent[id] = news.ID
+		}
+		return nil
+	}
+	var deferred []int64
+	for i := 0; i < chunks; i++ {
+		if spacing > 0 && i > 0 {
+			target := start.Add(time.Duration(i)*spacing + time.Duration(rand.Int63n(int64(spacing/2)+1)))
+			if wait := time.Until(target); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+		end := (i + 1) * chunkSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		checkpointed := len(delivered)
+		for _, id := range ids[i*chunkSize : end] {
+			if err := sendTo(id); isFloodDeferral(err) {
+				deferred = append(deferred, id)
+			}
+		}
+		checkpointDelivered(delivered[checkpointed:], report.At)
+	}
+	// Recipients hit by a flood wait are retried once the backoff expires, within a budget;
+	// anyone still left stays unmarked so the missed digest picks them up
+	deferredTotal := len(deferred)
+	deadline := time.Now().Add(envDuration("FLOOD_MAX_WAIT", defaultFloodMaxWait))
+	for len(deferred) > 0 && waitFloodBackoff(deadline) && time.Now().Before(deadline) {
+		retry := deferred
+		deferred = nil
+		checkpointed := len(delivered)
+		for _, id := range retry {
+			if err := sendTo(id); isFloodDeferral(err) {
+				deferred = append(deferred, id)
+			}
+		}
+		checkpointDelivered(delivered[checkpointed:], report.At)
+	}
+	if deferredTotal > 0 {
+		log.Printf("[BROADCAST] Deferred %d sends for flood wait, %d still undelivered", deferredTotal, len(deferred))
+		sendAdmin(b, fmt.Sprintf("⏳ Bản tin gặp giới hạn gửi của Telegram: %d lượt bị hoãn, %d chưa gửi được (sẽ nhận bản tổng hợp bị lỡ).",
+			deferredTotal, len(deferred)))
+	}
+	log.Printf("[BROADCAST] Delivered %d/%d in %s", sent, len(ids), time.Since(start).Round(time.Second))
+	var splits []SplitDelivery
+	for id, msg := range marketSent {
+		if splitUsers[id] {
+			splits = append(splits, SplitDelivery{ChatID: id, MarketMessageID: msg.ID, NewsMessageID: newsSent[id]})
+		}
+	}
+	recordBroadcastLog(exp, sentByVariant, sent, splits)
+	finishCheckpoint()
 }
 
 // --- HANDLERS (AWS LAMBDA) ---
 
+// callbackRefreshTimeout bounds the report rebuild behind the update button, leaving the
+// invocation time to show an error instead of the "updating" state forever
+const callbackRefreshTimeout = 20 * time.Second
+
+// refreshReportCallback serves the update-price button for both modes. The callback is
+// answered before any work so the client's spinner stops at once; the message then shows
+// an "updating" state, and ends either refreshed or with an error notice (build panic,
+// timeout or failed edit) rather than stuck mid-update.
+func refreshReportCallback(b *tele.Bot, cb *tele.Callback, build func() (string, *tele.ReplyMarkup)) {
+	// Telegram omits the message for very old inline keyboards; there is nothing to edit
+	if cb.Message == nil {
+		b.Respond(cb, &tele.CallbackResponse{Text: "Tin nhắn đã quá cũ, vui lòng gõ /report."})
+		return
+	}
+	if err := b.Respond(cb, &tele.CallbackResponse{Text: "🔄 Đang xử lý..."}); err != nil {
+		log.Printf("[CALLBACK ERROR] Failed to answer callback: %v", err)
+	}
+	original, markup := cb.Message.Text, cb.Message.ReplyMarkup
+	b.Edit(cb.Message, original+"\n\n⌛ *Đang cập nhật dữ liệu...*", &tele.SendOptions{
+		ParseMode:   tele.ModeMarkdown,
+		ReplyMarkup: markup,
+	})
+
+	type result struct {
+		text string
+		menu *tele.ReplyMarkup
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- result{err: fmt.Errorf("panic: %v", r)}
+			}
+		}()
+		text, menu := build()
+		done <- result{text: text, menu: menu}
+	}()
+
+	var res result
+	select {
+	case res = <-done:
+	case <-time.After(callbackRefreshTimeout):
+		res.err = fmt.Errorf("timed out after %s", callbackRefreshTimeout)
+	}
+	if res.err == nil {
+		_, res.err = b.Edit(cb.Message, res.text+"\n\n✅ *Cập nhật thành công!*", &tele.SendOptions{
+			ParseMode:             tele.ModeMarkdown,
+			ReplyMarkup:           res.menu,
+			DisableWebPagePreview: true,
+		})
+	}
+	if res.err != nil {
+		log.Printf("[CALLBACK ERROR] Report refresh failed in %d: %v", cb.Message.Chat.ID, res.err)
+		b.Edit(cb.Message, original+"\n\n⚠️ *Không thể cập nhật lúc này, vui lòng thử lại sau.*", &tele.SendOptions{
+			ParseMode:   tele.ModeMarkdown,
+			ReplyMarkup: markup,
+		})
+	}
+}
+
 // Handler processes AWS Lambda requests (Function URL triggers)
-func Handler(ctx context.Context, request events.LambdaFunctionURLRequest) (events.LambdaFunctionURLResponse, error) {
+func Handler(ctx context.Context, request events.LambdaFunctionURLRequest) (resp events.LambdaFunctionURLResponse, err error) {
+	defer recoverLambda(&request, &resp, &err)
+	// Webhook bodies are vetted before anything else runs; junk never reaches Mongo or the bot
+	if request.Body != "" && request.QueryStringParameters["action"] == "" && request.QueryStringParameters["click"] == "" {
+		body, rejected := webhookBody(request)
+		if rejected != nil {
+			return *rejected, nil
+		}
+		request.Body, request.IsBase64Encoded = body, false
+	}
+	// Tracked news links from experiment broadcasts redirect through here
+	if request.QueryStringParameters["click"] != "" {
+		return handleClick(request), nil
+	}
+	trigger := "interactive"
+	if action := request.QueryStringParameters["action"]; action != "" {
+		trigger = action
+	} else if request.Body == "" {
+		trigger = "broadcast"
+	}
+	beginMetrics(trigger)
+	defer finishMetrics()
+	defer checkBroadcastWatchdog()
+	// Maintenance calls (?action=...) never touch the Telegram update path
+	if action := request.QueryStringParameters["action"]; action != "" {
+		return handleAction(ctx, action, request), nil
+	}
 	initDatabase()
 	token := os.Getenv("TELEGRAM_TOKEN")
 	// Initialize bot in synchronous mode for Lambda environment
-	b, err := tele.NewBot(tele.Settings{
+	b, err := newBot(tele.Settings{
 		Token:       token,
 		Synchronous: true,
 	})
@@ -297,77 +1048,203 @@ func Handler(ctx context.Context, request events.LambdaFunctionURLRequest) (even
 	// EventBridge or direct URL calls without body are treated as broadcast triggers
 	if request.Body == "" {
 		log.Println("[LAMBDA] Empty body trigger detected")
+		announceMaintenance(b)
+		if w, ok := activeMaintenance(clock()); ok {
+			log.Printf("[MAINTENANCE] Skipping broadcast, window ends %s", w.End.Format(time.RFC3339))
+			recordBroadcastRun()
+			return events.LambdaFunctionURLResponse{StatusCode: 200, Body: "maintenance"}, nil
+		}
+		if !databaseAvailable() {
+			// Without the subscriber list there is no audience, and sending blind would skip
+			// delivery stamps and snapshots; abort loudly instead
+			log.Printf("[BROADCAST] Aborted, database unavailable: %v", databaseErr)
+			notifyAdmin(b, fmt.Sprintf("🚨 Bản tin bị hủy: không kết nối được MongoDB (%v).", databaseErr))
+			return events.LambdaFunctionURLResponse{StatusCode: 503, Body: "database unavailable"}, nil
+		}
 		users := loadUsers()
-		msg, menu := getMarketUpdate()
-		for id := range users {
-			b.Send(&tele.Chat{ID: id}, msg, &tele.SendOptions{
-				ParseMode:             tele.ModeMarkdown,
-				ReplyMarkup:           menu,
-				DisableWebPagePreview: true,
-			})
+		// Building the report spends API credits; don't do it for an empty audience
+		if len(users) == 0 {
+			log.Println("[BROADCAST] No active subscribers, skipping report generation")
+			recordBroadcastRun()
+			return events.LambdaFunctionURLResponse{StatusCode: 200, Body: "no subscribers"}, nil
+		}
+		// On a holiday HOLIDAY_EDITION may skip the report or send it without news
+		edition := holidayEdition(clock(), loadConfig())
+		if edition == holidayEditionSkip {
+			log.Println("[HOLIDAY] Skipping the holiday edition")
+			recordBroadcastRun()
+			return events.LambdaFunctionURLResponse{StatusCode: 200, Body: "holiday"}, nil
+		}
+		report := buildMarketReport()
+		if edition == holidayEditionSlim {
+			report = slimHolidayReport(report, loadConfig().Symbols)
 		}
+		// Yesterday's poll is settled against the same gold quote the report shows
+		gold := report.Quotes[pollSymbol]
+		if announcement := resolvePendingPolls(b, gold.Price); announcement != "" {
+			report.Prepend(announcement)
+		}
+		if board := monthlyLeaderboardAnnouncement(); board != "" {
+			report.Prepend(board)
+		}
+		// A schedule slot can force its broadcast silent or loud with ?silent=true|false
+		var slotSilent *bool
+		if v, err := strconv.ParseBool(request.QueryStringParameters["silent"]); err == nil {
+			slotSilent = &v
+		}
+		broadcastReport(b, users, report, slotSilent, false)
+		saveSnapshot(report)
+		recordBroadcastRun()
+		sendDailyPolls(b, loadPollRecipients(), gold.Price)
 		return events.LambdaFunctionURLResponse{StatusCode: 200, Body: "Broadcast complete"}, nil
 	}
 
-	var update tele.Update
-	if err := json.Unmarshal([]byte(request.Body), &update); err != nil {
-		log.Printf("[ERROR] Failed to parse Telegram update: %v", err)
-		return events.LambdaFunctionURLResponse{StatusCode: 200, Body: "Malformed request"}, nil
+	// A relay or test harness may POST a JSON array of updates instead of a single one
+	var updates []tele.Update
+	if trimmed := strings.TrimSpace(request.Body); strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal([]byte(trimmed), &updates); err != nil {
+			log.Printf("[ERROR] Failed to parse Telegram update batch: %v", err)
+			return events.LambdaFunctionURLResponse{StatusCode: 200, Body: "Malformed request"}, nil
+		}
+		if len(updates) > maxBatchUpdates {
+			log.Printf("[ERROR] Update batch of %d exceeds the limit of %d", len(updates), maxBatchUpdates)
+			return events.LambdaFunctionURLResponse{StatusCode: 413, Body: "Batch too large"}, nil
+		}
+	} else {
+		var update tele.Update
+		if err := json.Unmarshal([]byte(request.Body), &update); err != nil {
+			log.Printf("[ERROR] Failed to parse Telegram update: %v", err)
+			return events.LambdaFunctionURLResponse{StatusCode: 200, Body: "Malformed request"}, nil
+		}
+		updates = append(updates, update)
+	}
+	handleUpdates(b, updates)
+	return events.LambdaFunctionURLResponse{StatusCode: 200, Body: "Processed"}, nil
+}
+
+// updateHandler handles one webhook update; tests swap it
+var updateHandler = handleUpdate
+
+// handleUpdates processes a webhook's updates in order. Each one recovers on its own, so a
+// panicking update is reported (its callback answered) and the rest of the batch still runs.
+func handleUpdates(b *tele.Bot, updates []tele.Update) {
+	for _, update := range updates {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					reportPanic(b, &update, r)
+				}
+			}()
+			updateHandler(b, update)
+		}()
+	}
+}
+
+// handleUpdate processes one webhook update: poll answers, button callbacks and commands
+func handleUpdate(b *tele.Bot, update tele.Update) {
+	if update.PollAnswer != nil {
+		recordPollAnswer(update.PollAnswer)
+		return
+	}
+	if update.Query != nil {
+		handleInlineQuery(b, update.Query)
+		return
 	}
 
 	if update.Callback != nil {
 		log.Printf("[LAMBDA] Callback interaction: %s", update.Callback.Data)
-		b.Edit(update.Callback.Message, update.Callback.Message.Text+"\n\n⌛ *Đang cập nhật dữ liệu...*", &tele.SendOptions{
-			ParseMode:   tele.ModeMarkdown,
-			ReplyMarkup: update.Callback.Message.ReplyMarkup,
-		})
-
-		msg, menu := getMarketUpdate()
-		b.Edit(update.Callback.Message, msg+"\n\n✅ *Cập nhật thành công!*", &tele.SendOptions{
-			ParseMode:             tele.ModeMarkdown,
-			ReplyMarkup:           menu,
-			DisableWebPagePreview: true,
-		})
-		b.Respond(update.Callback, &tele.CallbackResponse{})
-		return events.LambdaFunctionURLResponse{StatusCode: 200}, nil
+		unique, data, _ := strings.Cut(strings.TrimPrefix(update.Callback.Data, "\f"), "|")
+		if unique == "btn_news_lang" && update.Callback.Message != nil {
+			b.Respond(update.Callback, &tele.CallbackResponse{Text: toggleNewsLanguage(b, update.Callback.Message, data)})
+			return
+		}
+		if strings.HasPrefix(unique, "btn_alert_") {
+			b.Respond(update.Callback, &tele.CallbackResponse{Text: handleAlertCallback(b, update.Callback, unique, data)})
+			return
+		}
+		if unique == "btn_run" {
+			b.Respond(update.Callback, &tele.CallbackResponse{Text: runSuggestedCommand(b, update.ID, update.Callback, data)})
+			return
+		}
+		if unique == "btn_cfg" {
+			b.Respond(update.Callback, &tele.CallbackResponse{Text: handleSettingsCallback(b, update.Callback, data)})
+			return
+		}
+		if unique == "btn_share" && update.Callback.Message != nil {
+			b.Respond(update.Callback, &tele.CallbackResponse{Text: shareReport(b, update.Callback.Message.Chat, data)})
+			return
+		}
+		refreshReportCallback(b, update.Callback, getMarketUpdate)
+		return
 	}
 	// Handle Standard Messages
 	if update.Message != nil {
-		m := update.Message
-		log.Printf("[LAMBDA] Message from %d: %s", m.Chat.ID, m.Text)
-		switch m.Text {
-		case "/start":
-			saveUser(m.Chat.ID)
-			b.Send(m.Chat, "Chào mừng Trader! Bạn đã đăng ký nhận bản tin tự động hàng ngày. Gõ /help để xem hướng dẫn.")
-		case "/help":
-			b.Send(m.Chat, helpMessage, &tele.SendOptions{ParseMode: tele.ModeMarkdown})
-		case "/update":
-			tmpMsg, _ := b.Send(m.Chat, "⌛ *Đang lấy dữ liệu thị trường mới nhất...*", &tele.SendOptions{ParseMode: tele.ModeMarkdown})
-			msg, menu := getMarketUpdate()
-			b.Edit(tmpMsg, msg, &tele.SendOptions{
-				ParseMode:             tele.ModeMarkdown,
-				ReplyMarkup:           menu,
-				DisableWebPagePreview: true,
-			})
-		case "/quit", "/cancel":
-			if removeUser(m.Chat.ID) {
-				b.Send(m.Chat, "❌ Bạn đã hủy đăng ký nhận bản tin thành công. Hẹn gặp lại!")
-			} else {
-				b.Send(m.Chat, "ℹ️ Bạn hiện chưa đăng ký nhận bản tin hoặc đã hủy trước đó.")
-			}
-		default:
-			// Fallback message for unrecognized commands
-			b.Send(m.Chat, "🤖 Lệnh không hợp lệ. Vui lòng dùng /help để xem danh sách các lệnh hỗ trợ.")
-		}
+		log.Printf("[LAMBDA] Message from %d: %s", update.Message.Chat.ID, update.Message.Text)
+		dispatchCommand(b, update.ID, update.Message)
+		return
+	}
+	switch {
+	case update.EditedMessage != nil:
+		handleEditedMessage(b, update.ID, update.EditedMessage)
+	case update.ChannelPost != nil:
+		handleChannelPost(b, update.ID, update.ChannelPost)
+	case update.MyChatMember != nil:
+		handleMyChatMember(b, update.MyChatMember)
+	default:
+		log.Printf("[LAMBDA] Ignoring update %d of an unhandled type", update.ID)
 	}
-
-	return events.LambdaFunctionURLResponse{StatusCode: 200, Body: "Processed"}, nil
 }
 
 // --- MAIN (LOCAL & PROD) ---
 
 func main() {
 	godotenv.Load()
+	check := flag.Bool("check", false, "run the deployment self-check and exit")
+	restore := flag.String("restore", "", "restore a backup dump (file or directory) into MONGODB_URI and exit")
+	force := flag.Bool("force", false, "with -restore, allow restoring into a non-empty users collection")
+	backfill := flag.Int("backfill", 0, "backfill N days of daily snapshots from Twelve Data and exit")
+	replay := flag.String("replay", "", "replay recorded Lambda request JSON files (more may follow the flags) with a dry-run sender against a local database and exit")
+	flag.Parse()
+
+	if *replay != "" {
+		if err := runReplay(append([]string{*replay}, flag.Args()...)); err != nil {
+			log.Fatalf("[REPLAY ERROR] %v", err)
+		}
+		return
+	}
+
+	if *backfill > 0 {
+		days, err := parseBackfillDays(strconv.Itoa(*backfill))
+		if err != nil {
+			log.Fatalf("[BACKFILL ERROR] %v", err)
+		}
+		initDatabase()
+		written, err := runBackfill(days, func(done, total int, symbol string) {
+			log.Printf("[BACKFILL] %d/%d %s", done, total, symbol)
+		})
+		if err != nil {
+			log.Fatalf("[BACKFILL ERROR] %v", err)
+		}
+		log.Printf("[BACKFILL] Completed: %d days", written)
+		return
+	}
+
+	if *restore != "" {
+		if err := runRestore(*restore, *force); err != nil {
+			log.Fatalf("[RESTORE ERROR] %v", err)
+		}
+		log.Println("[RESTORE] Completed")
+		return
+	}
+
+	if *check {
+		results, ok := runSelfCheck(context.Background())
+		fmt.Print(formatCheckResults(results))
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
 
 	if os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != "" {
 		// Execution environment is AWS Lambda
@@ -378,63 +1255,93 @@ func main() {
 		initDatabase()
 
 		token := os.Getenv("TELEGRAM_TOKEN")
-		b, err := tele.NewBot(tele.Settings{
+		b, err := newBot(tele.Settings{
 			Token:  token,
 			Poller: &tele.LongPoller{Timeout: 10 * time.Second},
 		})
 		if err != nil {
 			log.Fatal(err)
 		}
+		// Must be registered before the handlers so every one of them is wrapped
+		b.Use(recoverMiddleware(b))
+
+		// Commands go through the same router and middleware as the Lambda path
+		for command := range commandRoutes {
+			b.Handle(command, func(c tele.Context) error {
+				return dispatchCommand(b, c.Update().ID, c.Message())
+			})
+		}
+		for command := range commandAliases {
+			b.Handle(command, func(c tele.Context) error {
+				return dispatchCommand(b, c.Update().ID, c.Message())
+			})
+		}
 
-		b.Handle("/start", func(c tele.Context) error {
-			saveUser(c.Chat().ID)
-			return c.Send("🛠 Chế độ thử nghiệm đã sẵn sàng. Bạn đã được đăng ký. Gõ /help để xem hướng dẫn.")
+		b.Handle(tele.OnPollAnswer, func(c tele.Context) error {
+			recordPollAnswer(c.PollAnswer())
+			return nil
+		})
+		b.Handle(tele.OnQuery, func(c tele.Context) error {
+			handleInlineQuery(b, c.Query())
+			return nil
 		})
 
-		b.Handle("/help", func(c tele.Context) error {
-			return c.Send(helpMessage, &tele.SendOptions{ParseMode: tele.ModeMarkdown})
+		// Catch-all handler for text that doesn't match specific commands
+		b.Handle(tele.OnText, func(c tele.Context) error {
+			return dispatchCommand(b, c.Update().ID, c.Message())
 		})
 
-		b.Handle("/update", func(c tele.Context) error {
-			tmpMsg, _ := b.Send(c.Chat(), "⌛ *Đang cập nhật dữ liệu...*", &tele.SendOptions{ParseMode: tele.ModeMarkdown})
-			msg, menu := getMarketUpdate()
-			_, err = b.Edit(tmpMsg, msg, &tele.SendOptions{
-				ParseMode:             tele.ModeMarkdown,
-				ReplyMarkup:           menu,
-				DisableWebPagePreview: true,
-			})
-			return err
+		b.Handle(tele.OnEdited, func(c tele.Context) error {
+			handleEditedMessage(b, c.Update().ID, c.Update().EditedMessage)
+			return nil
+		})
+		b.Handle(tele.OnChannelPost, func(c tele.Context) error {
+			handleChannelPost(b, c.Update().ID, c.Update().ChannelPost)
+			return nil
+		})
+		b.Handle(tele.OnMigration, func(c tele.Context) error {
+			handleChatMigration(c.Message())
+			return nil
+		})
+		b.Handle(tele.OnMyChatMember, func(c tele.Context) error {
+			handleMyChatMember(b, c.Update().MyChatMember)
+			return nil
 		})
 
-		b.Handle("/quit", func(c tele.Context) error {
-			if removeUser(c.Chat().ID) {
-				return c.Send("❌ Đã hủy đăng ký nhận tin.")
+		b.Handle("\fbtn_update_price", func(c tele.Context) error {
+			refreshReportCallback(b, c.Callback(), getMarketUpdate)
+			return nil
+		})
+
+		b.Handle("\fbtn_news_lang", func(c tele.Context) error {
+			if c.Callback().Message == nil {
+				return c.Respond(&tele.CallbackResponse{Text: "Tin nhắn đã quá cũ, vui lòng gõ /report."})
 			}
-			return c.Send("ℹ️ Bạn chưa đăng ký.")
+			return c.Respond(&tele.CallbackResponse{Text: toggleNewsLanguage(b, c.Callback().Message, c.Callback().Data)})
 		})
 
-		b.Handle("/cancel", func(c tele.Context) error {
-			if removeUser(c.Chat().ID) {
-				return c.Send("❌ Đã hủy đăng ký nhận tin.")
+		b.Handle("\fbtn_share", func(c tele.Context) error {
+			if c.Callback().Message == nil {
+				return c.Respond(&tele.CallbackResponse{Text: "Tin nhắn đã quá cũ, vui lòng gõ /report."})
 			}
-			return c.Send("ℹ️ Bạn chưa đăng ký.")
+			return c.Respond(&tele.CallbackResponse{Text: shareReport(b, c.Callback().Message.Chat, c.Callback().Data)})
 		})
 
-		// Catch-all handler for text that doesn't match specific commands
-		b.Handle(tele.OnText, func(c tele.Context) error {
-			return c.Send("🤖 Lệnh không hợp lệ. Vui lòng dùng /help để xem danh sách các lệnh hỗ trợ.")
+		b.Handle("\fbtn_cfg", func(c tele.Context) error {
+			return c.Respond(&tele.CallbackResponse{Text: handleSettingsCallback(b, c.Callback(), c.Callback().Data)})
 		})
 
-		b.Handle("\fbtn_update_price", func(c tele.Context) error {
-			c.Respond(&tele.CallbackResponse{Text: "🔄 Đang lấy dữ liệu mới..."})
-			msg, menu := getMarketUpdate()
-			return c.Edit(msg+"\n\n✅ *Cập nhật thành công!*", &tele.SendOptions{
-				ParseMode:             tele.ModeMarkdown,
-				ReplyMarkup:           menu,
-				DisableWebPagePreview: true,
-			})
+		b.Handle("\fbtn_run", func(c tele.Context) error {
+			return c.Respond(&tele.CallbackResponse{Text: runSuggestedCommand(b, c.Update().ID, c.Callback(), c.Callback().Data)})
 		})
 
+		for _, unique := range []string{"btn_alert_set", "btn_alert_custom", "btn_alert_del"} {
+			unique := unique
+			b.Handle("\f"+unique, func(c tele.Context) error {
+				return c.Respond(&tele.CallbackResponse{Text: handleAlertCallback(b, c.Callback(), unique, c.Callback().Data)})
+			})
+		}
+
 		stop := make(chan os.Signal, 1)
 		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 		go b.Start()
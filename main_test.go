@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// withDatabase binds the collection globals to db with the given connection error,
+// restoring the previous binding afterwards
+func withDatabase(t testing.TB, db bool, dbErr error) {
+	t.Helper()
+	savedDB, savedErr := marketDB, databaseErr
+	t.Cleanup(func() {
+		bindCollections(savedDB)
+		databaseErr = savedErr
+	})
+	if db {
+		bindCollections(testCollections(t, "users")[0].Database())
+	} else {
+		bindCollections(nil)
+	}
+	databaseErr = dbErr
+}
+
+func TestBindCollections(t *testing.T) {
+	withDatabase(t, true, nil)
+	if userCollection == nil || alertCollection == nil || symbolMetaCollection == nil || store == nil {
+		t.Fatal("bindCollections left collections unbound")
+	}
+	if !databaseAvailable() {
+		t.Error("databaseAvailable = false with bound collections")
+	}
+
+	bindCollections(nil)
+	if userCollection != nil || alertCollection != nil || snapshotCollection != nil || symbolMetaCollection != nil || store != nil {
+		t.Error("bindCollections(nil) left a collection bound")
+	}
+	if databaseAvailable() {
+		t.Error("databaseAvailable = true without collections")
+	}
+}
+
+func TestDatabaseAvailableAfterFailedPing(t *testing.T) {
+	withDatabase(t, true, errors.New("server selection timeout"))
+	if databaseAvailable() {
+		t.Error("databaseAvailable = true while databaseErr is set")
+	}
+}
+
+func TestStartWhileDegraded(t *testing.T) {
+	withDatabase(t, false, errors.New("server selection timeout"))
+	api := &fakeBotAPI{}
+	r := &Request{Bot: newFakeBot(t, api), Message: &tele.Message{Chat: &tele.Chat{ID: 42}}, Command: "/start"}
+	if err := commandRoutes["/start"].handler(r); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.calls) != 1 || api.calls[0].Method != "sendMessage" || !strings.Contains(api.calls[0].Text, "tạm thời không khả dụng") {
+		t.Errorf("degraded /start sent %+v, want one unavailable notice", api.calls)
+	}
+}
+
+func TestRefreshReportCallback(t *testing.T) {
+	callback := func() *tele.Callback {
+		return &tele.Callback{ID: "cb", Data: "\fbtn_update_price", Sender: &tele.User{ID: 7},
+			Message: &tele.Message{ID: 5, Chat: &tele.Chat{ID: 7}, Text: "📊 old report"}}
+	}
+	tests := []struct {
+		name    string
+		build   func() (string, *tele.ReplyMarkup)
+		deleted bool
+		last    string
+	}{
+		{"refreshed", func() (string, *tele.ReplyMarkup) { return "📊 new report", nil }, false, "📊 new report\n\n✅ *Cập nhật thành công!*"},
+		{"build panics", func() (string, *tele.ReplyMarkup) { panic("boom") }, false, "📊 old report\n\n⚠️ *Không thể cập nhật lúc này, vui lòng thử lại sau.*"},
+		// The refreshed edit fails, so the error edit is attempted too
+		{"edit fails", func() (string, *tele.ReplyMarkup) { return "📊 new report", nil }, true, "📊 old report\n\n⚠️ *Không thể cập nhật lúc này, vui lòng thử lại sau.*"},
+	}
+	for _, tt := range tests {
+		api := &fakeBotAPI{deleted: tt.deleted}
+		refreshReportCallback(newFakeBot(t, api), callback(), tt.build)
+		methods := api.methods()
+		if len(methods) == 0 || methods[0] != "answerCallbackQuery" {
+			t.Errorf("%s: calls %v, want the callback answered first", tt.name, methods)
+		}
+		edits := api.edits()
+		if len(edits) < 2 || !strings.HasSuffix(edits[0], "⌛ *Đang cập nhật dữ liệu...*") || edits[len(edits)-1] != tt.last {
+			t.Errorf("%s: edits %q, want the updating state then %q", tt.name, edits, tt.last)
+		}
+	}
+}
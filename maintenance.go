@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// maintenanceCacheTTL bounds how stale the per-command maintenance check may be
+const maintenanceCacheTTL = 30 * time.Second
+
+// MaintenanceWindow is a scheduled pause, stored in the settings collection under the
+// profile's maintenance kind. The announced flags are claimed atomically so each
+// transition is announced once.
+type MaintenanceWindow struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty"`
+	Kind           string             `bson:"kind"`
+	Start          time.Time          `bson:"start"`
+	End            time.Time          `bson:"end"`
+	Message        string             `bson:"message"`
+	StartAnnounced bool               `bson:"start_announced"`
+	EndAnnounced   bool               `bson:"end_announced"`
+}
+
+var (
+	maintenanceMu       sync.Mutex
+	cachedMaintenance   []MaintenanceWindow
+	maintenanceLoadedAt time.Time
+)
+
+// --- MAINTENANCE WINDOWS ---
+
+// maintenanceKind tags this profile's windows in the settings collection
+func maintenanceKind() string {
+	return activeProfile().collectionName("maintenance")
+}
+
+// loadMaintenanceWindows returns the windows that haven't finished announcing, cached briefly
+func loadMaintenanceWindows() []MaintenanceWindow {
+	maintenanceMu.Lock()
+	defer maintenanceMu.Unlock()
+	if !maintenanceLoadedAt.IsZero() && clock().Sub(maintenanceLoadedAt) < maintenanceCacheTTL {
+		return cachedMaintenance
+	}
+	if settingsCollection == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cursor, err := settingsCollection.Find(ctx,
+		bson.M{"kind": maintenanceKind(), "$or": []bson.M{{"end": bson.M{"$gt": clock()}}, {"end_announced": false}}},
+		options.Find().SetSort(bson.D{{Key: "start", Value: 1}}))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load maintenance windows: %v", err)
+		return cachedMaintenance
+	}
+	var windows []MaintenanceWindow
+	if err := cursor.All(ctx, &windows); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to decode maintenance windows: %v", err)
+		return cachedMaintenance
+	}
+	cachedMaintenance = windows
+	maintenanceLoadedAt = clock()
+	return windows
+}
+
+// invalidateMaintenance forces the next check to re-read the windows
+func invalidateMaintenance() {
+	maintenanceMu.Lock()
+	maintenanceLoadedAt = time.Time{}
+	maintenanceMu.Unlock()
+}
+
+// activeMaintenance returns the window covering now, if any
+func activeMaintenance(now time.Time) (MaintenanceWindow, bool) {
+	for _, w := range loadMaintenanceWindows() {
+		if !now.Before(w.Start) && now.Before(w.End) {
+			return w, true
+		}
+	}
+	return MaintenanceWindow{}, false
+}
+
+// maintenanceNotice is the reply interactive commands get during a window
+func maintenanceNotice(w MaintenanceWindow, now time.Time) string {
+	remaining := w.End.Sub(now).Round(time.Minute)
+	if remaining < time.Minute {
+		remaining = time.Minute
+	}
+	return fmt.Sprintf("🛠 %s\n⏳ Dự kiến hoạt động trở lại sau %s (lúc %s).",
+		w.Message, remaining, w.End.In(vnLocation).Format("15:04 02/01"))
+}
+
+// maintenanceMiddleware answers commands with the maintenance notice while a window is
+// active; admins pass through so they can still operate the bot
+func maintenanceMiddleware(next HandlerFunc) HandlerFunc {
+	return func(r *Request) error {
+		if w, ok := activeMaintenance(clock()); ok && !hasRole(r.ActorID(), roleViewer) {
+			return r.Reply(maintenanceNotice(w, clock()))
+		}
+		return next(r)
+	}
+}
+
+// announceMaintenance sends the enter/leave announcement of any window that just crossed
+// a boundary. Called from the scheduled paths; each transition is claimed before sending.
+func announceMaintenance(b *tele.Bot) {
+	now := clock()
+	for _, w := range loadMaintenanceWindows() {
+		var field, text string
+		switch {
+		case !now.Before(w.End) && !w.EndAnnounced:
+			field, text = "end_announced", "✅ Bảo trì đã kết thúc, bot hoạt động bình thường trở lại."
+		case !now.Before(w.Start) && now.Before(w.End) && !w.StartAnnounced:
+			field, text = "start_announced", maintenanceNotice(w, now)
+		default:
+			continue
+		}
+		res, err := settingsCollection.UpdateOne(context.TODO(),
+			bson.M{"_id": w.ID, field: false}, bson.M{"$set": bson.M{field: true}})
+		if err != nil || res.ModifiedCount == 0 {
+			continue
+		}
+		// A window that both started and ended between runs only needs the closing message
+		if field == "end_announced" && !w.StartAnnounced {
+			settingsCollection.UpdateOne(context.TODO(), bson.M{"_id": w.ID}, bson.M{"$set": bson.M{"start_announced": true}})
+		}
+		invalidateMaintenance()
+		threads := loadThreadIDs()
+		sent := 0
+		for id := range loadUsers() {
+			if _, err := deliver(b, id, threads[id], text, nil); err != nil {
+				log.Printf("[MAINTENANCE ERROR] Failed to announce to %d: %v", id, err)
+				continue
+			}
+			sent++
+		}
+		log.Printf("[MAINTENANCE] Announced %s of window %s to %d chats", field, w.ID.Hex(), sent)
+	}
+}
+
+// maintenanceReply handles the admin "/maintenance <start> <duration> <message>",
+// "/maintenance" (list) and "/maintenance cancel". start is "now" or "2006-01-02T15:04"
+// in Vietnam time; the message may be quoted.
+func maintenanceReply(args []string) string {
+	usage := "ℹ️ Cú pháp: /maintenance now|2006-01-02T15:04 2h <thông báo>, /maintenance (xem), /maintenance cancel"
+	if settingsCollection == nil {
+		return "⚠️ Không có kết nối cơ sở dữ liệu."
+	}
+	now := clock()
+	if len(args) == 0 {
+		windows := loadMaintenanceWindows()
+		if len(windows) == 0 {
+			return "ℹ️ Không có lịch bảo trì."
+		}
+		var sb strings.Builder
+		sb.WriteString("🛠 Lịch bảo trì:\n")
+		for _, w := range windows {
+			fmt.Fprintf(&sb, "• %s → %s: %s\n", w.Start.In(vnLocation).Format("15:04 02/01"),
+				w.End.In(vnLocation).Format("15:04 02/01"), w.Message)
+		}
+		return sb.String()
+	}
+	if len(args) == 1 && strings.ToLower(args[0]) == "cancel" {
+		// Active windows end now (and get their closing announcement); future ones are dropped
+		settingsCollection.DeleteMany(context.TODO(), bson.M{"kind": maintenanceKind(), "start": bson.M{"$gt": now}})
+		res, err := settingsCollection.UpdateMany(context.TODO(),
+			bson.M{"kind": maintenanceKind(), "start": bson.M{"$lte": now}, "end": bson.M{"$gt": now}},
+			bson.M{"$set": bson.M{"end": now}})
+		invalidateMaintenance()
+		if err != nil {
+			return "⚠️ Không thể lưu lúc này."
+		}
+		return fmt.Sprintf("✅ Đã hủy lịch bảo trì (%d đang diễn ra được kết thúc).", res.ModifiedCount)
+	}
+	if len(args) < 3 {
+		return usage
+	}
+	start := now
+	if strings.ToLower(args[0]) != "now" {
+		t, err := time.ParseInLocation("2006-01-02T15:04", args[0], vnLocation)
+		if err != nil {
+			return usage
+		}
+		start = t
+	}
+	duration, err := time.ParseDuration(args[1])
+	if err != nil || duration <= 0 {
+		return "⚠️ Thời lượng không hợp lệ, ví dụ 30m hoặc 2h."
+	}
+	end := start.Add(duration)
+	if !end.After(now) {
+		return "⚠️ Khoảng bảo trì đã kết thúc trong quá khứ."
+	}
+	overlaps, err := settingsCollection.CountDocuments(context.TODO(), bson.M{
+		"kind": maintenanceKind(), "start": bson.M{"$lt": end}, "end": bson.M{"$gt": start},
+	})
+	if err != nil {
+		return "⚠️ Không thể lưu lúc này."
+	}
+	if overlaps > 0 {
+		return "⚠️ Trùng với một lịch bảo trì khác. Dùng /maintenance để xem."
+	}
+	_, err = settingsCollection.InsertOne(context.TODO(), MaintenanceWindow{
+		Kind:    maintenanceKind(),
+		Start:   start,
+		End:     end,
+		Message: strings.Join(args[2:], " "),
+	})
+	invalidateMaintenance()
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to save maintenance window: %v", err)
+		return "⚠️ Không thể lưu lúc này."
+	}
+	return fmt.Sprintf("✅ Đã lên lịch bảo trì %s → %s.", start.In(vnLocation).Format("15:04 02/01"),
+		end.In(vnLocation).Format("15:04 02/01"))
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryStore is the in-memory Store for tests. One mutex guards everything, so handler
+// tests may hit it from many goroutines.
+type memoryStore struct {
+	mu     sync.Mutex
+	users  map[int64]*memoryUser
+	alerts map[primitive.ObjectID]Alert
+}
+
+type memoryUser struct {
+	list watchedList
+	dead bool
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{users: map[int64]*memoryUser{}, alerts: map[primitive.ObjectID]Alert{}}
+}
+
+func (s *memoryStore) SaveUser(_ context.Context, chatID int64, _ time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u, ok := s.users[chatID]; ok {
+		u.dead = false
+		return true, nil
+	}
+	s.users[chatID] = &memoryUser{list: watchedList{ChatID: chatID, Watchlist: []string{}, Tier: tierFree}}
+	return false, nil
+}
+
+func (s *memoryStore) RemoveUser(_ context.Context, chatID int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.users[chatID]
+	delete(s.users, chatID)
+	return ok, nil
+}
+
+func (s *memoryStore) MarkDead(_ context.Context, chatID int64, _ time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u, ok := s.users[chatID]; ok {
+		u.dead = true
+	}
+	return nil
+}
+
+func (s *memoryStore) Subscribers(_ context.Context, after int64, limit int) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []int64
+	for id, u := range s.users {
+		if id > after && !u.dead {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+func (s *memoryStore) Watchlist(_ context.Context, chatID int64) (watchedList, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[chatID]
+	if !ok {
+		return watchedList{}, false, nil
+	}
+	w := u.list
+	w.Watchlist = append([]string{}, u.list.Watchlist...)
+	return w, true, nil
+}
+
+func (s *memoryStore) WatchSymbols(_ context.Context, chatID int64, symbols []string, _ time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[chatID]
+	if !ok {
+		return false, nil
+	}
+	for _, symbol := range symbols {
+		if !containsString(u.list.Watchlist, symbol) {
+			u.list.Watchlist = append(u.list.Watchlist, symbol)
+		}
+	}
+	return true, nil
+}
+
+func (s *memoryStore) UnwatchSymbol(_ context.Context, chatID int64, symbol string, _ time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[chatID]
+	if !ok {
+		return false, nil
+	}
+	kept := u.list.Watchlist[:0]
+	for _, s := range u.list.Watchlist {
+		if s != symbol {
+			kept = append(kept, s)
+		}
+	}
+	u.list.Watchlist = kept
+	return true, nil
+}
+
+func (s *memoryStore) InsertAlert(_ context.Context, a Alert) (primitive.ObjectID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a.ID.IsZero() {
+		a.ID = primitive.NewObjectID()
+	}
+	if _, ok := s.alerts[a.ID]; ok {
+		return primitive.NilObjectID, fmt.Errorf("duplicate alert %s", a.ID.Hex())
+	}
+	s.alerts[a.ID] = a
+	return a.ID, nil
+}
+
+func (s *memoryStore) PendingAlerts(_ context.Context, chatID int64) ([]Alert, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var alerts []Alert
+	for _, a := range s.alerts {
+		if a.ChatID == chatID && !a.Triggered {
+			alerts = append(alerts, a)
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if !alerts[i].CreatedAt.Equal(alerts[j].CreatedAt) {
+			return alerts[i].CreatedAt.Before(alerts[j].CreatedAt)
+		}
+		return alerts[i].ID.Hex() < alerts[j].ID.Hex()
+	})
+	return alerts, nil
+}
+
+func (s *memoryStore) ClaimAlert(_ context.Context, id primitive.ObjectID, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.alerts[id]
+	if !ok || a.Triggered {
+		return false, nil
+	}
+	a.Triggered, a.TriggeredAt = true, now
+	s.alerts[id] = a
+	return true, nil
+}
+
+func (s *memoryStore) DeleteAlert(_ context.Context, id primitive.ObjectID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.alerts[id]
+	delete(s.alerts, id)
+	return ok, nil
+}
+
+func (s *memoryStore) DeleteChatAlerts(_ context.Context, chatID int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for id, a := range s.alerts {
+		if a.ChatID == chatID {
+			delete(s.alerts, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *memoryStore) PurgeTriggeredAlerts(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for id, a := range s.alerts {
+		if a.Triggered && a.TriggeredAt.Before(before) {
+			delete(s.alerts, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMemoryStoreConformance(t *testing.T) {
+	runStoreConformance(t, func(t *testing.T) Store { return newMemoryStore() })
+}
+
+// TestMemoryStoreConcurrentUse mixes every kind of call from many goroutines; run it with
+// -race
+func TestMemoryStoreConcurrentUse(t *testing.T) {
+	s := newMemoryStore()
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 8, 0, 0, 0, vnLocation)
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			chatID := int64(g + 1)
+			for i := 0; i < 50; i++ {
+				s.SaveUser(ctx, chatID, now)
+				s.WatchSymbols(ctx, chatID, []string{"btc", fmt.Sprint("s", i%5)}, now)
+				s.UnwatchSymbol(ctx, chatID, "btc", now)
+				s.Watchlist(ctx, chatID)
+				id, _ := s.InsertAlert(ctx, Alert{ChatID: chatID, Symbol: "btc", CreatedAt: now})
+				s.ClaimAlert(ctx, id, now)
+				s.PendingAlerts(ctx, chatID)
+				s.Subscribers(ctx, 0, 10)
+				s.PurgeTriggeredAlerts(ctx, now.Add(time.Second))
+				if i%10 == 0 {
+					s.MarkDead(ctx, chatID, now)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	for g := 0; g < 16; g++ {
+		w, ok, _ := s.Watchlist(ctx, int64(g+1))
+		if !ok || len(w.Watchlist) != 5 {
+			t.Errorf("chat %d watchlist = %v, %v; want s0..s4", g+1, w.Watchlist, ok)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Invocation metrics are kept for metricsRetention (TTL index) and summarized weekly
+const (
+	metricsRetention  = 35 * 24 * time.Hour
+	metricsReportDays = 7
+	slowestShown      = 3
+)
+
+var (
+	metricsCollection *mongo.Collection
+	metricsMu         sync.Mutex
+	currentMetrics    *InvocationMetrics
+)
+
+// InvocationMetrics is what one Lambda invocation spent: Twelve Data calls by endpoint,
+// cache hits and misses (USD/VND rate, price series), quotes served by a fallback source
+// keyed "symbol/source", headline translations and wall time. Trigger is "broadcast",
+// "interactive" or the ?action= name.
+type InvocationMetrics struct {
+	At            time.Time      `bson:"at"`
+	Trigger       string         `bson:"trigger"`
+	DurationMs    int64          `bson:"duration_ms"`
+	APICalls      map[string]int `bson:"api_calls,omitempty"`
+	CacheHits     int            `bson:"cache_hits"`
+	CacheMisses   int            `bson:"cache_misses"`
+	Fallbacks     map[string]int `bson:"fallbacks,omitempty"`
+	Translations  int            `bson:"translations"`
+	WriteFailures int            `bson:"write_failures,omitempty"`
+}
+
+// --- INVOCATION METRICS ---
+
+// beginMetrics starts counting for an invocation. Lambda runs one invocation per container
+// at a time, so a single current record suffices; local mode never calls this and the
+// counters below are no-ops there.
+func beginMetrics(trigger string) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	currentMetrics = &InvocationMetrics{At: clock(), Trigger: trigger, APICalls: map[string]int{}, Fallbacks: map[string]int{}}
+}
+
+// withMetrics applies f to the current record, if an invocation is being counted
+func withMetrics(f func(m *InvocationMetrics)) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	if currentMetrics != nil {
+		f(currentMetrics)
+	}
+}
+
+// countAPICall records one Twelve Data request
+func countAPICall(endpoint string) {
+	withMetrics(func(m *InvocationMetrics) { m.APICalls[endpoint]++ })
+}
+
+// countCache records a lookup in one of the caches in front of Twelve Data
+func countCache(hit bool) {
+	withMetrics(func(m *InvocationMetrics) {
+		if hit {
+			m.CacheHits++
+		} else {
+			m.CacheMisses++
+		}
+	})
+}
+
+// countFallback records a quote for symbol served by source because the preferred one failed
+func countFallback(symbol, source string) {
+	withMetrics(func(m *InvocationMetrics) { m.Fallbacks[symbol+"/"+source]++ })
+}
+
+// countTranslation records one headline sent to the translation script
+func countTranslation() {
+	withMetrics(func(m *InvocationMetrics) { m.Translations++ })
+}
+
+// finishMetrics stamps the duration and stores the record
+func finishMetrics() {
+	metricsMu.Lock()
+	m := currentMetrics
+	currentMetrics = nil
+	metricsMu.Unlock()
+	if m == nil || metricsCollection == nil {
+		return
+	}
+	m.DurationMs = clock().Sub(m.At).Milliseconds()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if _, err := metricsCollection.InsertOne(ctx, m); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to save invocation metrics: %v", err)
+	}
+}
+
+// loadMetrics returns the records of the last days days, oldest first
+func loadMetrics(days int) ([]InvocationMetrics, error) {
+	if metricsCollection == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+	cursor, err := metricsCollection.Find(context.TODO(),
+		bson.M{"at": bson.M{"$gte": clock().AddDate(0, 0, -days)}},
+		options.Find().SetSort(bson.D{{Key: "at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var records []InvocationMetrics
+	err = cursor.All(context.TODO(), &records)
+	return records, err
+}
+
+// MetricsSummary aggregates invocation records for the weekly report
+type MetricsSummary struct {
+	Invocations       int
+	CallsByEndpoint   map[string]int
+	CallsByTrigger    map[string]int
+	CacheHits         int
+	CacheMisses       int
+	Fallbacks         map[string]int
+	Translations      int
+	Broadcasts        int
+	AvgBroadcastMs    int64
+	SlowestInvocation []InvocationMetrics
+}
+
+// summarizeMetrics aggregates records; it only reads its input
+func summarizeMetrics(records []InvocationMetrics) MetricsSummary {
+	s := MetricsSummary{Invocations: len(records), CallsByEndpoint: map[string]int{}, CallsByTrigger: map[string]int{},
+		Fallbacks: map[string]int{}}
+	var broadcastMs int64
+	for _, r := range records {
+		for endpoint, n := range r.APICalls {
+			s.CallsByEndpoint[endpoint] += n
+			s.CallsByTrigger[r.Trigger] += n
+		}
+		s.CacheHits += r.CacheHits
+		s.CacheMisses += r.CacheMisses
+		for key, n := range r.Fallbacks {
+			s.Fallbacks[key] += n
+		}
+		s.Translations += r.Translations
+		if r.Trigger == "broadcast" {
+			s.Broadcasts++
+			broadcastMs += r.DurationMs
+		}
+	}
+	if s.Broadcasts > 0 {
+		s.AvgBroadcastMs = broadcastMs / int64(s.Broadcasts)
+	}
+	slowest := append([]InvocationMetrics(nil), records...)
+	sort.SliceStable(slowest, func(i, j int) bool { return slowest[i].DurationMs > slowest[j].DurationMs })
+	s.SlowestInvocation = slowest[:min(slowestShown, len(slowest))]
+	return s
+}
+
+// sortedCounts renders a count map as "a 12, b 3", largest first
+func sortedCounts(counts map[string]int) string {
+	if len(counts) == 0 {
+		return "0"
+	}
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s %d", k, counts[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatMetricsSummary renders the weekly admin report; every list in it is bounded
+// (endpoints, triggers, three slowest), so it stays well within one message
+func formatMetricsSummary(s MetricsSummary) string {
+	total := 0
+	for _, n := range s.CallsByEndpoint {
+		total += n
+	}
+	hitRate := "n/a"
+	if lookups := s.CacheHits + s.CacheMisses; lookups > 0 {
+		hitRate = fmt.Sprintf("%.0f%% (%d/%d)", float64(s.CacheHits)/float64(lookups)*100, s.CacheHits, lookups)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "📊 Báo cáo tuần (%d ngày, %d lượt chạy)\n", metricsReportDays, s.Invocations)
+	fmt.Fprintf(&sb, "• Twelve Data: %d lượt gọi\n", total)
+	fmt.Fprintf(&sb, "  - Theo endpoint: %s\n", sortedCounts(s.CallsByEndpoint))
+	fmt.Fprintf(&sb, "  - Theo nguồn: %s\n", sortedCounts(s.CallsByTrigger))
+	fmt.Fprintf(&sb, "• Cache (USD/VND, chuỗi giá): %s\n", hitRate)
+	fmt.Fprintf(&sb, "• Nguồn dự phòng: %s\n", sortedCounts(s.Fallbacks))
+	fmt.Fprintf(&sb, "• Tiêu đề đã dịch: %d\n", s.Translations)
+	if s.Broadcasts > 0 {
+		fmt.Fprintf(&sb, "• Bản tin: %d lần, trung bình %s\n", s.Broadcasts, (time.Duration(s.AvgBroadcastMs) * time.Millisecond).Round(time.Second))
+	}
+	if len(s.SlowestInvocation) > 0 {
+		sb.WriteString("• Chậm nhất:\n")
+		for _, r := range s.SlowestInvocation {
+			fmt.Fprintf(&sb, "  - %s %s: %s\n", r.At.In(vnLocation).Format("02/01 15:04"), r.Trigger,
+				(time.Duration(r.DurationMs) * time.Millisecond).Round(100*time.Millisecond))
+		}
+	}
+	sb.WriteString("Chi tiết: /export metrics")
+	return sb.String()
+}
+
+// writeMetricsCSV streams the last week's records as CSV, one row per invocation
+func writeMetricsCSV(w io.Writer) error {
+	records, err := loadMetrics(metricsReportDays)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"at", "trigger", "duration_ms", "api_calls", "cache_hits", "cache_misses", "translations", "fallbacks"})
+	for _, r := range records {
+		cw.Write([]string{r.At.Format(time.RFC3339), r.Trigger, strconv.FormatInt(r.DurationMs, 10),
+			countList(r.APICalls), strconv.Itoa(r.CacheHits), strconv.Itoa(r.CacheMisses), strconv.Itoa(r.Translations),
+			countList(r.Fallbacks)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// countList renders a count map as "a=1;b=2" for a CSV cell
+func countList(counts map[string]int) string {
+	parts := make([]string, 0, len(counts))
+	for k, n := range counts {
+		parts = append(parts, fmt.Sprintf("%s=%d", k, n))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ";")
+}
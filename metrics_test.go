@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummarizeMetrics(t *testing.T) {
+	at := time.Date(2026, 3, 2, 8, 0, 0, 0, vnLocation)
+	records := []InvocationMetrics{
+		{At: at, Trigger: "broadcast", DurationMs: 9000, APICalls: map[string]int{"quote": 2, "time_series": 1},
+			CacheHits: 1, CacheMisses: 1, Fallbacks: map[string]int{"usdvnd/vcb": 1}, Translations: 8},
+		{At: at.Add(time.Hour), Trigger: "interactive", DurationMs: 400, APICalls: map[string]int{"quote": 1}, CacheHits: 2},
+		{At: at.Add(2 * time.Hour), Trigger: "broadcast", DurationMs: 5000, APICalls: map[string]int{"quote": 2},
+			Fallbacks: map[string]int{"usdvnd/vcb": 1, "btc/twelvedata": 2}},
+		{At: at.Add(3 * time.Hour), Trigger: "alerts", DurationMs: 12000},
+	}
+	s := summarizeMetrics(records)
+	if s.Invocations != 4 || s.CallsByEndpoint["quote"] != 5 || s.CallsByEndpoint["time_series"] != 1 {
+		t.Errorf("calls by endpoint = %v over %d invocations", s.CallsByEndpoint, s.Invocations)
+	}
+	if s.CallsByTrigger["broadcast"] != 5 || s.CallsByTrigger["interactive"] != 1 || s.CallsByTrigger["alerts"] != 0 {
+		t.Errorf("calls by trigger = %v", s.CallsByTrigger)
+	}
+	if s.CacheHits != 3 || s.CacheMisses != 1 || s.Fallbacks["usdvnd/vcb"] != 2 || s.Fallbacks["btc/twelvedata"] != 2 {
+		t.Errorf("cache %d/%d, fallbacks %v", s.CacheHits, s.CacheMisses, s.Fallbacks)
+	}
+	if s.Broadcasts != 2 || s.AvgBroadcastMs != 7000 {
+		t.Errorf("%d broadcasts averaging %dms, want 2 averaging 7000ms", s.Broadcasts, s.AvgBroadcastMs)
+	}
+	if len(s.SlowestInvocation) != slowestShown || s.SlowestInvocation[0].Trigger != "alerts" || s.SlowestInvocation[2].DurationMs != 5000 {
+		t.Errorf("slowest = %+v", s.SlowestInvocation)
+	}
+	if records[0].Trigger != "broadcast" {
+		t.Error("summarizeMetrics reordered its input")
+	}
+
+	got := formatMetricsSummary(s)
+	for _, want := range []string{
+		"• Twelve Data: 6 lượt gọi\n",
+		"  - Theo endpoint: quote 5, time_series 1\n",
+		"• Cache (USD/VND, chuỗi giá): 75% (3/4)\n",
+		"• Nguồn dự phòng: btc/twelvedata 2, usdvnd/vcb 2\n",
+		"• Bản tin: 2 lần, trung bình 7s\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("summary is missing %q:\n%s", want, got)
+		}
+	}
+	if empty := formatMetricsSummary(summarizeMetrics(nil)); !strings.Contains(empty, "Cache (USD/VND, chuỗi giá): n/a") {
+		t.Errorf("empty summary:\n%s", empty)
+	}
+}
+
+func TestUsdVndFallbackCounted(t *testing.T) {
+	f := usdVndChain(t)
+	m := countMetrics(t)
+	f.set(vcbHost, vcbResponse("25,500.00"))
+	if r, err := getUsdVndRate("k"); err != nil || r.Source != rateSourceVCB {
+		t.Fatalf("rate = %+v, %v; want Vietcombank", r, err)
+	}
+	if m.CacheMisses != 1 || m.Fallbacks["usdvnd/vcb"] != 1 || m.APICalls["quote"] != 1 {
+		t.Errorf("metrics = %+v, want a cache miss, a quote call and a Vietcombank fallback", m)
+	}
+	if _, err := getUsdVndRate("k"); err != nil || m.CacheMisses != 2 || m.Fallbacks["usdvnd/vcb"] != 2 {
+		t.Errorf("metrics after a second lookup = %+v", m)
+	}
+}
+
+func TestOverrideFallbackCounted(t *testing.T) {
+	m := countMetrics(t)
+	saved := quoteProviders[providerCoinGecko]
+	t.Cleanup(func() { quoteProviders[providerCoinGecko] = saved })
+	p := saved
+	p.Quote = func(string) MarketData { return MarketData{Err: errors.New("coingecko down")} }
+	quoteProviders[providerCoinGecko] = p
+
+	quoteOverridden([]string{"btc", "gold"}, map[string]string{"btc": providerCoinGecko})
+	if len(m.Fallbacks) != 1 || m.Fallbacks["btc/twelvedata"] != 1 {
+		t.Errorf("fallbacks = %v, want the failed btc override", m.Fallbacks)
+	}
+}
+
+func TestSeriesCacheCounted(t *testing.T) {
+	m := countMetrics(t)
+	f := withFakeHTTP(t)
+	f.set(twelveDataHost, jsonResponse(`{"status":"ok","values":[{"datetime":"2026-03-01","close":"3000"}]}`))
+	seriesCacheMu.Lock()
+	saved := seriesCache
+	seriesCache = map[string][]SeriesPoint{}
+	seriesCacheMu.Unlock()
+	t.Cleanup(func() {
+		seriesCacheMu.Lock()
+		seriesCache = saved
+		seriesCacheMu.Unlock()
+	})
+
+	for range 2 {
+		if _, err := getRecentSeries("gold", "k", 30); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if m.CacheMisses != 1 || m.CacheHits != 1 || m.APICalls["time_series"] != 1 {
+		t.Errorf("metrics = %+v, want one miss, one hit and one time_series call", m)
+	}
+}
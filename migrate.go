@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// --- USER DOCUMENT DEFAULTS ---
+
+// userDefaults are the per-user fields every document should carry, with the value a new
+// subscriber starts with. A field added here is backfilled on existing users by migrateUsers.
+func userDefaults() bson.M {
+	return bson.M{
+		"news_mode":    newsModeList,
+		"number_style": numberStyleVN,
+		"silent":       silentAuto,
+		"watchlist":    []string{},
+		"tier":         tierFree,
+		"rev":          int64(0),
+	}
+}
+
+// migrateUsers fills in missing default fields on existing user documents, once per
+// container. Fields already present are never touched ($ifNull), so it is safe to re-run
+// and can't race a user changing a setting; readers still treat absent fields as defaults.
+func migrateUsers() {
+	if usersMigrated || userCollection == nil {
+		return
+	}
+	filter, update := userBackfill()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	res, err := userCollection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		log.Printf("[MIGRATION ERROR] Failed to backfill user defaults: %v", err)
+		return
+	}
+	if res.ModifiedCount > 0 {
+		log.Printf("[MIGRATION] Backfilled default fields on %d user documents", res.ModifiedCount)
+	}
+	usersMigrated = true
+}
+
+// userBackfill is migrateUsers' query: documents missing any default field, and a pipeline
+// update that sets each field only where it is absent or null
+func userBackfill() (bson.M, mongo.Pipeline) {
+	defaults := userDefaults()
+	fields := make([]string, 0, len(defaults))
+	for field := range defaults {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	missing := make([]bson.M, 0, len(fields))
+	set := bson.M{}
+	for _, field := range fields {
+		missing = append(missing, bson.M{field: bson.M{"$exists": false}})
+		set[field] = bson.M{"$ifNull": bson.A{"$" + field, bson.M{"$literal": defaults[field]}}}
+	}
+	return bson.M{"$or": missing}, mongo.Pipeline{{{Key: "$set", Value: set}}}
+}
+
+// --- SYMBOL MIGRATION ---
+
+// canonicalList resolves every entry of a stored symbol list; changed is false when it was
+// already canonical
+func canonicalList(symbols []string) (out []string, changed bool) {
+	seen := make(map[string]bool, len(symbols))
+	for _, s := range symbols {
+		id := resolveSymbol(s)
+		changed = changed || id != s
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out, changed || len(out) != len(symbols)
+}
+
+// canonicalKeys re-keys a stored price map by canonical ID
+func canonicalKeys(m map[string]float64) (map[string]float64, bool) {
+	out := make(map[string]float64, len(m))
+	changed := false
+	for s, v := range m {
+		id := resolveSymbol(s)
+		changed = changed || id != s
+		out[id] = v
+	}
+	return out, changed
+}
+
+// migrateDocs rewrites each document matched by filter whose fix returns a non-nil $set,
+// returning how many were updated
+func migrateDocs(ctx context.Context, coll *mongo.Collection, filter bson.M, fix func(bson.Raw) bson.M) (int, error) {
+	if coll == nil {
+		return 0, nil
+	}
+	cursor, err := coll.Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+	updated := 0
+	for cursor.Next(ctx) {
+		set := fix(cursor.Current)
+		if set == nil {
+			continue
+		}
+		id := cursor.Current.Lookup("_id")
+		if _, err := coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set}); err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, cursor.Err()
+}
+
+// migrateSymbols rewrites provider symbols stored before canonical asset IDs (watchlists,
+// holdings, alerts, polls, snapshots, news sets and the config document). It is idempotent,
+// so it can be re-run after a partial failure.
+func migrateSymbols(ctx context.Context) (string, error) {
+	var report []string
+	step := func(name string, n int, err error) error {
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		report = append(report, fmt.Sprintf("%s: %d", name, n))
+		return nil
+	}
+
+	n, err := migrateDocs(ctx, userCollection, bson.M{"watchlist.0": bson.M{"$exists": true}}, func(raw bson.Raw) bson.M {
+		var doc struct {
+			Watchlist []string `bson:"watchlist"`
+		}
+		if bson.Unmarshal(raw, &doc) != nil {
+			return nil
+		}
+		if list, changed := canonicalList(doc.Watchlist); changed {
+			return bson.M{"watchlist": list}
+		}
+		return nil
+	})
+	if err := step("watchlists", n, err); err != nil {
+		return "", err
+	}
+
+	n, err = migrateDocs(ctx, userCollection, bson.M{"holdings.0": bson.M{"$exists": true}}, func(raw bson.Raw) bson.M {
+		var doc struct {
+			Holdings []Holding `bson:"holdings"`
+		}
+		if bson.Unmarshal(raw, &doc) != nil {
+			return nil
+		}
+		changed := false
+		merged := make(map[string]int)
+		var out []Holding
+		for _, h := range doc.Holdings {
+			id := resolveSymbol(h.Symbol)
+			changed = changed || id != h.Symbol
+			// Two spellings of one asset collapse into a single holding
+			if i, ok := merged[id]; ok {
+				out[i].Quantity += h.Quantity
+				out[i].Lots = append(out[i].Lots, h.Lots...)
+				changed = true
+				continue
+			}
+			merged[id] = len(out)
+			h.Symbol = id
+			out = append(out, h)
+		}
+		if changed {
+			return bson.M{"holdings": out}
+		}
+		return nil
+	})
+	if err := step("holdings", n, err); err != nil {
+		return "", err
+	}
+
+	symbolField := func(raw bson.Raw) bson.M {
+		s, ok := raw.Lookup("symbol").StringValueOK()
+		if !ok || s == "" {
+			return nil
+		}
+		if id := resolveSymbol(s); id != s {
+			return bson.M{"symbol": id}
+		}
+		return nil
+	}
+	n, err = migrateDocs(ctx, alertCollection, bson.M{"symbol": bson.M{"$regex": "[A-Z]"}}, symbolField)
+	if err := step("alerts", n, err); err != nil {
+		return "", err
+	}
+	n, err = migrateDocs(ctx, pollCollection, bson.M{"symbol": bson.M{"$regex": "[A-Z]"}}, symbolField)
+	if err := step("polls", n, err); err != nil {
+		return "", err
+	}
+
+	priceMaps := func(raw bson.Raw) bson.M {
+		var doc struct {
+			Symbols []string           `bson:"symbols"`
+			Prices  map[string]float64 `bson:"prices"`
+			Changes map[string]float64 `bson:"changes"`
+		}
+		if bson.Unmarshal(raw, &doc) != nil {
+			return nil
+		}
+		set := bson.M{}
+		if len(doc.Symbols) > 0 {
+			if list, changed := canonicalList(doc.Symbols); changed {
+				set["symbols"] = list
+			}
+		}
+		if m, changed := canonicalKeys(doc.Prices); changed {
+			set["prices"] = m
+		}
+		if m, changed := canonicalKeys(doc.Changes); changed {
+			set["changes"] = m
+		}
+		if len(set) == 0 {
+			return nil
+		}
+		return set
+	}
+	n, err = migrateDocs(ctx, snapshotCollection, bson.M{}, priceMaps)
+	if err := step("snapshots", n, err); err != nil {
+		return "", err
+	}
+	n, err = migrateDocs(ctx, newsSetCollection, bson.M{}, priceMaps)
+	if err := step("news_sets", n, err); err != nil {
+		return "", err
+	}
+
+	n, err = migrateDocs(ctx, settingsCollection, bson.M{"_id": activeProfile().ConfigID, "symbols.0": bson.M{"$exists": true}},
+		func(raw bson.Raw) bson.M {
+			var doc configDoc
+			if bson.Unmarshal(raw, &doc) != nil {
+				return nil
+			}
+			if list, changed := canonicalList(doc.Symbols); changed {
+				return bson.M{"symbols": list}
+			}
+			return nil
+		})
+	if err := step("config", n, err); err != nil {
+		return "", err
+	}
+	reloadConfig()
+
+	summary := "Migrated symbols — " + strings.Join(report, ", ")
+	log.Printf("[MIGRATION] %s", summary)
+	return summary, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestUserBackfill(t *testing.T) {
+	filter, update := userBackfill()
+	var fields []string
+	for field := range userDefaults() {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	missing := make([]bson.M, len(fields))
+	for i, field := range fields {
+		missing[i] = bson.M{field: bson.M{"$exists": false}}
+	}
+	// Sorted, so the query is the same on every run
+	if want := (bson.M{"$or": missing}); !reflect.DeepEqual(filter, want) {
+		t.Errorf("filter = %v, want %v", filter, want)
+	}
+	if len(update) != 1 || len(update[0]) != 1 || update[0][0].Key != "$set" {
+		t.Fatalf("update = %v, want one $set stage", update)
+	}
+	set := update[0][0].Value.(bson.M)
+	for field, v := range userDefaults() {
+		// Present values win; only absent or null fields take the default
+		keep := bson.M{"$ifNull": bson.A{"$" + field, bson.M{"$literal": v}}}
+		if !reflect.DeepEqual(set[field], keep) {
+			t.Errorf("$set.%s = %v, want %v", field, set[field], keep)
+		}
+	}
+	if len(set) != len(userDefaults()) {
+		t.Errorf("$set touches %d fields, want %d", len(set), len(userDefaults()))
+	}
+}
+
+func TestMigrateUsersWithoutDatabase(t *testing.T) {
+	withDatabase(t, false, nil)
+	saved := usersMigrated
+	usersMigrated = false
+	t.Cleanup(func() { usersMigrated = saved })
+	migrateUsers()
+	if usersMigrated {
+		t.Error("marked migrated without a database")
+	}
+}
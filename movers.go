@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxMoveThreshold bounds /movethreshold; past it the section would never show
+const maxMoveThreshold = 50.0
+
+// --- BIGGEST MOVERS ---
+
+// moversSection lists the symbols whose session move is at least threshold percent,
+// largest first; "" when none qualify so the section is hidden
+func moversSection(symbols []string, quotes map[string]MarketData, threshold float64) string {
+	type mover struct {
+		symbol  string
+		percent float64
+	}
+	var movers []mover
+	seen := make(map[string]bool)
+	for _, s := range symbols {
+		d := quotes[s]
+		if seen[s] || d.Err != nil || !d.HasPercent || math.Abs(d.Percent) < threshold {
+			continue
+		}
+		seen[s] = true
+		movers = append(movers, mover{s, d.Percent})
+	}
+	if len(movers) == 0 {
+		return ""
+	}
+	sort.Slice(movers, func(i, j int) bool { return math.Abs(movers[i].percent) > math.Abs(movers[j].percent) })
+	rows := make([]string, len(movers))
+	for i, m := range movers {
+		icon := "🟢"
+		if m.percent < 0 {
+			icon = "🔴"
+		}
+		rows[i] = fmt.Sprintf("%s %s: %s", icon, lookupAsset(m.symbol).Label, formatPercent(m.percent))
+	}
+	return fmt.Sprintf("🚀 **BIẾN ĐỘNG ĐÁNG CHÚ Ý (≥ %g%%):**\n", threshold) + strings.Join(rows, "\n")
+}
+
+// moveThresholdOf returns a user's threshold, or the configured default
+func moveThresholdOf(u *User) float64 {
+	if u != nil && u.MoveThreshold > 0 {
+		return u.MoveThreshold
+	}
+	return loadConfig().MoverThreshold
+}
+
+// loadMoveThresholds returns the chats with a custom threshold
+func loadMoveThresholds() map[int64]float64 {
+	out := make(map[int64]float64)
+	if userCollection == nil {
+		return out
+	}
+	cursor, err := userCollection.Find(context.TODO(), bson.M{"move_threshold": bson.M{"$gt": 0}},
+		options.Find().SetProjection(bson.M{"chat_id": 1, "move_threshold": 1}))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load move thresholds: %v", err)
+		return out
+	}
+	defer cursor.Close(context.TODO())
+	for cursor.Next(context.TODO()) {
+		var result struct {
+			ChatID        int64   `bson:"chat_id"`
+			MoveThreshold float64 `bson:"move_threshold"`
+		}
+		if cursor.Decode(&result) == nil {
+			out[result.ChatID] = result.MoveThreshold
+		}
+	}
+	return out
+}
+
+// moveThresholdReply handles "/movethreshold 2%" and "/movethreshold default"
+func moveThresholdReply(chatID int64, arg string) string {
+	arg = strings.ToLower(strings.TrimSpace(arg))
+	if arg == "" {
+		return fmt.Sprintf("ℹ️ Cú pháp: `/movethreshold 2%%` (mặc định %g%%, `/movethreshold default` để dùng lại mặc định)",
+			loadConfig().MoverThreshold)
+	}
+	update := bson.M{"$unset": bson.M{"move_threshold": ""}}
+	reply := fmt.Sprintf("✅ Dùng ngưỡng mặc định %g%% cho mục biến động đáng chú ý.", loadConfig().MoverThreshold)
+	if arg != "default" {
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(arg, "%"), 64)
+		if err != nil || pct <= 0 || pct > maxMoveThreshold {
+			return fmt.Sprintf("⚠️ Ngưỡng không hợp lệ. Dùng số từ 0 đến %g, ví dụ `2%%`.", maxMoveThreshold)
+		}
+		update = bson.M{"$set": bson.M{"move_threshold": pct}}
+		reply = fmt.Sprintf("✅ Chỉ các mã biến động từ %g%% trở lên sẽ hiện trong mục biến động đáng chú ý.", pct)
+	}
+	if userCollection == nil {
+		return "ℹ️ Bạn cần đăng ký bằng /start trước."
+	}
+	res, err := userCollection.UpdateOne(context.TODO(), bson.M{"chat_id": chatID}, update)
+	if err != nil || res.MatchedCount == 0 {
+		return "ℹ️ Bạn cần đăng ký bằng /start trước."
+	}
+	return reply
+}
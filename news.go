@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// --- NEWS ---
+
+// fetchHeadlines reads the configured feed and returns the headlines in lang alongside the
+// originals. Only newsLangVI translates; any other language returns the originals twice.
+func fetchHeadlines(cfg Config, lang string) (headlines, originals []Headline) {
+	log.Println("[RSS] Fetching news from Investing.com...")
+	body, err := fetchBody(context.Background(), cfg.FeedURL, 15*time.Second, feedContentTypes)
+	if err != nil {
+		log.Printf("[RSS ERROR] %v", err)
+		return nil, nil
+	}
+	feed, _ := gofeed.NewParser().Parse(bytes.NewReader(body))
+	if feed == nil {
+		return nil, nil
+	}
+	// The whole feed goes to the archive (keyword spikes count it); only the top items are shown
+	var titles []string
+	all := make([]Headline, len(feed.Items))
+	for i, item := range feed.Items {
+		all[i] = Headline{Title: item.Title, Link: item.Link}
+		if i < cfg.NewsCount {
+			titles = append(titles, item.Title)
+			originals = append(originals, all[i])
+		}
+	}
+	if lang != newsLangVI {
+		archiveHeadlines(all, nil)
+		return originals, originals
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), translateBatchDeadline)
+	translated := translateAll(ctx, titles)
+	cancel()
+	for i, h := range originals {
+		headlines = append(headlines, Headline{Title: translated[i], Link: h.Link})
+	}
+	archiveHeadlines(all, headlines)
+	return headlines, originals
+}
+
+// renderNewsSection renders the report's headline block; link rewrites each article URL
+// (click tracking for experiments, identity otherwise)
+func renderNewsSection(list []Headline, link func(string) string) string {
+	newsList := ""
+	for _, h := range list {
+		newsList += fmt.Sprintf("🔹 **%s**\n🔗 [Xem chi tiết](%s)\n\n", h.Title, link(h.Link))
+	}
+	return "🔴 **TIN TỨC QUAN TRỌNG:**\n\n" + newsList
+}
+
+// newsReply handles "/news" and the one-off "/news en": the latest headlines, translated
+// or in the original English for this reply only. Nothing about the chat is stored.
+func newsReply(payload string) string {
+	lang := newsLangVI
+	switch strings.ToLower(strings.TrimSpace(payload)) {
+	case "", newsLangVI:
+	case newsLangEN:
+		lang = newsLangEN
+	default:
+		return "ℹ️ Cú pháp: /news (tiếng Việt) hoặc /news en (bản gốc tiếng Anh)"
+	}
+	headlines, _ := fetchHeadlines(loadConfig(), lang)
+	if len(headlines) == 0 {
+		return "⚠️ Không lấy được tin tức lúc này, vui lòng thử lại sau."
+	}
+	return strings.TrimSpace(renderNewsSection(headlines, func(link string) string { return link }))
+}
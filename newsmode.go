@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// News delivery modes; "list" is the condensed default. "split" ("tách tin") sends the
+// market section and then the news section as a reply to it, so either can be forwarded alone.
+const (
+	newsModeList  = "list"
+	newsModeCards = "cards"
+	newsModeSplit = "split"
+)
+
+// cardSendInterval spaces card messages to one chat, which Telegram limits to about one per second
+const cardSendInterval = time.Second
+
+// Headline is one translated news item
+type Headline struct {
+	Title string
+	Link  string
+}
+
+// --- NEWS MODE ---
+
+// textFor picks the rendering for a chat's news mode
+func (r MarketReport) textFor(cards bool) string {
+	if cards && r.CardsText != "" {
+		return r.CardsText
+	}
+	return r.Text
+}
+
+// getNewsMode returns the chat's news mode, defaulting to the condensed list
+func getNewsMode(chatID int64) string {
+	if userCollection == nil {
+		return newsModeList
+	}
+	var result struct {
+		NewsMode string `bson:"news_mode"`
+	}
+	err := userCollection.FindOne(context.TODO(), bson.M{"chat_id": chatID},
+		options.FindOne().SetProjection(bson.M{"news_mode": 1})).Decode(&result)
+	if err != nil || (result.NewsMode != newsModeCards && result.NewsMode != newsModeSplit) {
+		return newsModeList
+	}
+	return result.NewsMode
+}
+
+// setNewsMode stores the user's news mode; returns false if they aren't subscribed
+func setNewsMode(chatID int64, mode string) bool {
+	if userCollection == nil {
+		return false
+	}
+	result, err := userCollection.UpdateOne(context.TODO(), bson.M{"chat_id": chatID},
+		bson.M{"$set": bson.M{"news_mode": mode, "updated_at": clock()}, "$inc": bson.M{settingsRevField: 1}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to update news mode for %d: %v", chatID, err)
+		return false
+	}
+	return result.MatchedCount > 0
+}
+
+// loadNewsModeUsers returns the subscribers whose news mode is mode
+func loadNewsModeUsers(mode string) map[int64]bool {
+	users := make(map[int64]bool)
+	if userCollection == nil {
+		return users
+	}
+	cursor, err := userCollection.Find(context.TODO(), bson.M{"news_mode": mode},
+		options.Find().SetProjection(bson.M{"chat_id": 1}))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load news modes: %v", err)
+		return users
+	}
+	defer cursor.Close(context.TODO())
+	for cursor.Next(context.TODO()) {
+		var result struct {
+			ChatID int64 `bson:"chat_id"`
+		}
+		if cursor.Decode(&result) == nil {
+			users[result.ChatID] = true
+		}
+	}
+	return users
+}
+
+// SplitDelivery records the two messages of a split broadcast; NewsMessageID is 0 when
+// the news half never went out
+type SplitDelivery struct {
+	ChatID          int64 `bson:"chat_id"`
+	MarketMessageID int   `bson:"market_message_id"`
+	NewsMessageID   int   `bson:"news_message_id"`
+}
+
+// sendNewsCards sends each headline as its own message with the link preview enabled.
+// The headlines are already capped at the configured news count.
+func sendNewsCards(b *tele.Bot, chatID int64, threadID int, headlines []Headline, silent bool) {
+	for i, h := range headlines {
+		if i > 0 {
+			time.Sleep(cardSendInterval)
+		}
+		_, err := deliver(b, chatID, threadID, fmt.Sprintf("🔹 **%s**\n🔗 [Xem chi tiết](%s)", h.Title, h.Link),
+			&tele.SendOptions{ParseMode: tele.ModeMarkdown, DisableNotification: silent})
+		if err != nil {
+			log.Printf("[BROADCAST ERROR] Failed to send news card to %d: %v", chatID, err)
+			return
+		}
+	}
+}
+
+// newsModeReply handles "/newsmode cards|list"
+func newsModeReply(chatID int64, arg string) string {
+	mode := strings.ToLower(strings.TrimSpace(arg))
+	switch mode {
+	case newsModeCards, newsModeList, newsModeSplit:
+		if !setNewsMode(chatID, mode) {
+			return "ℹ️ Bạn cần đăng ký bằng /start trước."
+		}
+		switch mode {
+		case newsModeCards:
+			return "🖼 Tin tức sẽ được gửi thành từng tin nhắn riêng kèm ảnh xem trước."
+		case newsModeSplit:
+			return "✂️ Bản tin sẽ tách làm hai: bảng giá trước, tin tức trả lời ngay bên dưới, tiện chuyển tiếp riêng từng phần."
+		}
+		return "📋 Tin tức sẽ được gửi dạng danh sách gọn trong bản tin."
+	default:
+		return "ℹ️ Cú pháp: /newsmode cards, /newsmode split (tách tin) hoặc /newsmode list"
+	}
+}
+
+// splitNewsText is the news half of a split report, sent as a reply to the market half
+func splitNewsText(headlines []Headline, plain bool) string {
+	text := strings.TrimSpace(renderNewsSection(headlines, func(link string) string { return link }))
+	if plain {
+		return stripMarkdown(text)
+	}
+	return text
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	tele "gopkg.in/telebot.v3"
+)
+
+// News languages for the 🌐 toggle
+const (
+	newsLangVI = "vi"
+	newsLangEN = "en"
+)
+
+// newsSetTTL is how long a report's 🌐 and 📤 buttons keep working
+const newsSetTTL = 30 * 24 * time.Hour
+
+var newsSetCollection *mongo.Collection
+
+// NewsSet is the data a report was built from: its headlines in both languages and the
+// quotes it showed. The report's buttons refer to it by ID, since the feed and prices have
+// moved on by the time someone presses them.
+type NewsSet struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	Original   []Headline         `bson:"original"`
+	Translated []Headline         `bson:"translated"`
+	Symbols    []string           `bson:"symbols,omitempty"`
+	Prices     map[string]float64 `bson:"prices,omitempty"`
+	Changes    map[string]float64 `bson:"changes,omitempty"`
+	UsdVnd     float64            `bson:"usd_vnd,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at"`
+}
+
+// --- NEWS LANGUAGE TOGGLE ---
+
+// saveNewsSet stores a report's data and returns the set ID ("" if it can't be stored)
+func saveNewsSet(set NewsSet) string {
+	if newsSetCollection == nil {
+		return ""
+	}
+	set.CreatedAt = clock()
+	res, err := newsSetCollection.InsertOne(context.TODO(), set)
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to save news set: %v", err)
+		return ""
+	}
+	id, _ := res.InsertedID.(primitive.ObjectID)
+	return id.Hex()
+}
+
+// loadNewsSet fetches a stored news set by its hex ID
+func loadNewsSet(hexID string) (NewsSet, bool) {
+	var set NewsSet
+	id, err := primitive.ObjectIDFromHex(hexID)
+	if err != nil || newsSetCollection == nil {
+		return set, false
+	}
+	if err := newsSetCollection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&set); err != nil {
+		return set, false
+	}
+	return set, true
+}
+
+// reportMenu builds the report keyboard. With a stored set it adds 📤 share and, when the
+// report has news, the 🌐 button offering the language the news isn't in.
+func reportMenu(setID, shownLang string, hasNews bool) *tele.ReplyMarkup {
+	menu := &tele.ReplyMarkup{}
+	btnUpdate := menu.Data("🔄 Cập nhật giá mới", "btn_update_price")
+	if setID == "" {
+		menu.Inline(menu.Row(btnUpdate))
+		return menu
+	}
+	btnShare := menu.Data("📤 Chia sẻ", "btn_share", setID)
+	if !hasNews {
+		menu.Inline(menu.Row(btnUpdate, btnShare))
+		return menu
+	}
+	btnLang := menu.Data("🌐 English", "btn_news_lang", setID, newsLangEN)
+	if shownLang == newsLangEN {
+		btnLang = menu.Data("🌐 Tiếng Việt", "btn_news_lang", setID, newsLangVI)
+	}
+	menu.Inline(menu.Row(btnUpdate, btnLang), menu.Row(btnShare))
+	return menu
+}
+
+// utf16Len is a string's length in the UTF-16 units Telegram measures entities in
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+// swapHeadlines replaces each "from" title with its "to" counterpart in a delivered message,
+// shifting the message's entities to match. Only titles are touched, so personal sections
+// and formatting around the news block survive. ok is false if no title was found.
+func swapHeadlines(text string, entities tele.Entities, from, to []Headline) (string, tele.Entities, bool) {
+	out := append(tele.Entities(nil), entities...)
+	found := false
+	for i := range from {
+		if i >= len(to) || from[i].Title == to[i].Title {
+			continue
+		}
+		oldLine := "🔹 " + from[i].Title + "\n"
+		at := strings.Index(text, oldLine)
+		if at < 0 {
+			continue
+		}
+		found = true
+		start := utf16Len(text[:at]) + utf16Len("🔹 ")
+		oldLen, newLen := utf16Len(from[i].Title), utf16Len(to[i].Title)
+		delta := newLen - oldLen
+		text = text[:at] + "🔹 " + to[i].Title + "\n" + text[at+len(oldLine):]
+
+		kept := out[:0]
+		for _, e := range out {
+			end := e.Offset + e.Length
+			switch {
+			case e.Offset >= start+oldLen:
+				e.Offset += delta
+			case e.Offset <= start && end >= start+oldLen:
+				e.Length += delta
+			case end <= start:
+			default:
+				// Formatting inside the old title has no counterpart in the new one
+				continue
+			}
+			kept = append(kept, e)
+		}
+		out = kept
+	}
+	return text, out, found
+}
+
+// toggleNewsLanguage re-renders a delivered report's headlines in the requested language and
+// returns the text for the callback answer. data is "<set id>|<lang>".
+func toggleNewsLanguage(b *tele.Bot, msg *tele.Message, data string) string {
+	setID, lang, _ := strings.Cut(data, "|")
+	set, ok := loadNewsSet(setID)
+	if !ok {
+		return "Tin nhắn đã quá cũ, vui lòng gõ /report."
+	}
+	from, to := set.Translated, set.Original
+	if lang == newsLangVI {
+		from, to = set.Original, set.Translated
+	}
+	text, entities, found := swapHeadlines(msg.Text, msg.Entities, from, to)
+	if !found {
+		return "Tin nhắn này không có tin tức để chuyển ngôn ngữ."
+	}
+	_, err := b.Edit(msg, text, &tele.SendOptions{
+		Entities:              entities,
+		ReplyMarkup:           reportMenu(setID, lang, true),
+		DisableWebPagePreview: true,
+	})
+	if err != nil {
+		log.Printf("[NEWS ERROR] Failed to switch news language for %d: %v", msg.Chat.ID, err)
+		return "⚠️ Không thể chuyển ngôn ngữ lúc này."
+	}
+	return ""
+}
+
+// --- SHARE ---
+
+// shareHeadlines caps the headlines in a shared summary
+const shareHeadlines = 3
+
+// compactReport renders a stored report as a forward-friendly summary: prices as of the
+// report, a few headlines, no buttons or personal sections, and a link to the bot
+func compactReport(set NewsSet, botUsername string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s\n📅 *%s*\n\n", activeProfile().Title, formatDateTime(set.CreatedAt, newsLangVI, vnLocation))
+	if set.UsdVnd > 0 {
+		fmt.Fprintf(&sb, "• 💵 USD/VND: **%s VNĐ**\n", formatVnd(set.UsdVnd))
+	}
+	for _, symbol := range set.Symbols {
+		price, ok := set.Prices[symbol]
+		if !ok {
+			continue
+		}
+		asset := lookupAsset(symbol)
+		d := MarketData{Price: price, Change: "N/A"}
+		if c, ok := set.Changes[symbol]; ok {
+			d.Change, d.Percent, d.HasPercent = formatPercent(c), c, true
+		}
+		sb.WriteString(quoteLine(asset.Label, asset.PriceFormat, d) + "\n")
+	}
+	for i, h := range set.Translated {
+		if i == 0 {
+			sb.WriteString("\n📰 **Tin nổi bật:**\n")
+		}
+		if i >= shareHeadlines {
+			break
+		}
+		fmt.Fprintf(&sb, "🔹 [%s](%s)\n", h.Title, h.Link)
+	}
+	if botUsername != "" {
+		fmt.Fprintf(&sb, "\n🤖 Nhận bản tin miễn phí: https://t.me/%s?start=share", botUsername)
+	}
+	return sb.String()
+}
+
+// shareReport sends the compact version of a stored report to chat as a new message the
+// user can forward; returns the text for the callback answer
+func shareReport(b *tele.Bot, chat *tele.Chat, setID string) string {
+	set, ok := loadNewsSet(setID)
+	if !ok {
+		return "Tin nhắn đã quá cũ, vui lòng gõ /report."
+	}
+	username := ""
+	if b.Me != nil {
+		username = b.Me.Username
+	}
+	_, err := b.Send(chat, compactReport(set, username), &tele.SendOptions{
+		ParseMode:             tele.ModeMarkdown,
+		DisableWebPagePreview: true,
+	})
+	if err != nil {
+		log.Printf("[SHARE ERROR] Failed to send summary to %d: %v", chat.ID, err)
+		return "⚠️ Không thể tạo bản chia sẻ lúc này."
+	}
+	return "📤 Đã gửi bản tóm tắt, bạn có thể chuyển tiếp tin nhắn này."
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// outboxRetention is how long outbound messages are kept (TTL index)
+const outboxRetention = 30 * 24 * time.Hour
+
+// sentListSize is how many outbound messages /sent lists
+const sentListSize = 10
+
+// maxTemplateLen caps the template name derived from a message's first line
+const maxTemplateLen = 48
+
+// outboxMethods are the Bot API calls that put a message in a chat
+var outboxMethods = map[string]bool{
+	"sendMessage": true, "sendPhoto": true, "sendDocument": true, "sendPoll": true,
+	"copyMessage": true, "forwardMessage": true, "editMessageText": true, "editMessageCaption": true,
+}
+
+var templateDigits = regexp.MustCompile(`[0-9]+([.,][0-9]+)*`)
+
+var outboxCollection *mongo.Collection
+
+// OutboxEntry is one outbound message. Text is stored only with OUTBOX_FULL_TEXT; otherwise
+// TextHash (SHA-256 prefix) proves what was sent and Template says which message it was.
+type OutboxEntry struct {
+	ChatID    int64     `bson:"chat_id"`
+	Method    string    `bson:"method"`
+	MessageID int       `bson:"message_id,omitempty"`
+	Template  string    `bson:"template,omitempty"`
+	TextHash  string    `bson:"text_hash,omitempty"`
+	Text      string    `bson:"text,omitempty"`
+	Error     string    `bson:"error,omitempty"`
+	Trigger   string    `bson:"trigger"`
+	At        time.Time `bson:"at"`
+}
+
+// outboxTransport records every message-sending Bot API call that passes through it
+type outboxTransport struct {
+	base http.RoundTripper
+}
+
+// --- OUTBOX AUDIT LOG ---
+
+// newBot creates every bot the program uses, with the outbox transport installed, so no
+// send can bypass the audit log
+func newBot(pref tele.Settings) (*tele.Bot, error) {
+	if replayMode {
+		// -replay: nothing reaches Telegram, every call is printed by the dry-run transport
+		pref.Client, pref.Offline = &http.Client{Transport: outboxTransport{base: dryRunTransport{}}}, true
+	}
+	if pref.Client == nil {
+		pref.Client = &http.Client{Timeout: time.Minute, Transport: outboxTransport{base: http.DefaultTransport}}
+	}
+	return tele.NewBot(pref)
+}
+
+func (t outboxTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := path.Base(req.URL.Path)
+	if !outboxMethods[method] {
+		return t.base.RoundTrip(req)
+	}
+	var reqBody []byte
+	if req.Body != nil && strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		reqBody, _ = io.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		recordOutbound(method, reqBody, nil, err)
+		return resp, err
+	}
+	respBody, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	if readErr == nil {
+		recordOutbound(method, reqBody, respBody, nil)
+	}
+	return resp, nil
+}
+
+// recordOutbound stores one outbound call; multipart uploads (documents) have no parsed
+// request, so only the response identifies them
+func recordOutbound(method string, reqBody, respBody []byte, sendErr error) {
+	if outboxCollection == nil {
+		return
+	}
+	var params struct {
+		ChatID   json.RawMessage `json:"chat_id"`
+		Text     string          `json:"text"`
+		Caption  string          `json:"caption"`
+		Question string          `json:"question"`
+	}
+	json.Unmarshal(reqBody, &params)
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+		Result      struct {
+			MessageID int `json:"message_id"`
+			Chat      struct {
+				ID int64 `json:"id"`
+			} `json:"chat"`
+		} `json:"result"`
+	}
+	json.Unmarshal(respBody, &result)
+
+	e := OutboxEntry{Method: method, MessageID: result.Result.MessageID, Trigger: currentTrigger(), At: clock()}
+	e.ChatID = result.Result.Chat.ID
+	if e.ChatID == 0 {
+		e.ChatID, _ = strconv.ParseInt(strings.Trim(string(params.ChatID), `"`), 10, 64)
+	}
+	switch {
+	case sendErr != nil:
+		e.Error = sendErr.Error()
+	case !result.OK:
+		e.Error = result.Description
+	}
+	text := params.Text
+	if text == "" {
+		text = params.Caption
+	}
+	if text == "" {
+		text = params.Question
+	}
+	if text != "" {
+		sum := sha256.Sum256([]byte(text))
+		e.TextHash = hex.EncodeToString(sum[:8])
+		e.Template = messageTemplate(text)
+		if os.Getenv("OUTBOX_FULL_TEXT") == "true" {
+			e.Text = text
+		}
+	}
+	// Broadcasts batch these; elsewhere the record is written at once
+	queueWrite(outboxCollection, mongo.NewInsertOneModel().SetDocument(e))
+}
+
+// messageTemplate names a message by its first line with numbers masked, so "Bản tin
+// [26/05 08:00]" and the next day's report share a name without revealing prices
+func messageTemplate(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	line = strings.TrimSpace(templateDigits.ReplaceAllString(stripMarkdown(line), "#"))
+	if utf8.RuneCountInString(line) > maxTemplateLen {
+		line = string([]rune(line)[:maxTemplateLen]) + "…"
+	}
+	return line
+}
+
+// currentTrigger is the invocation trigger recorded with each outbound message
+func currentTrigger() string {
+	trigger := "local"
+	withMetrics(func(m *InvocationMetrics) { trigger = m.Trigger })
+	return trigger
+}
+
+// sentReply handles the admin "/sent <chat_id>": the chat's latest outbound messages
+func sentReply(args []string) string {
+	usage := "ℹ️ Cú pháp: /sent <chat_id>"
+	if len(args) != 1 {
+		return usage
+	}
+	chatID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return usage
+	}
+	if outboxCollection == nil {
+		return "⚠️ Không có kết nối cơ sở dữ liệu."
+	}
+	cursor, err := outboxCollection.Find(context.TODO(), bson.M{"chat_id": chatID},
+		options.Find().SetSort(bson.D{{Key: "at", Value: -1}}).SetLimit(sentListSize))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load outbox for %d: %v", chatID, err)
+		return "⚠️ Không thể tải nhật ký gửi lúc này."
+	}
+	var entries []OutboxEntry
+	if err := cursor.All(context.TODO(), &entries); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to decode outbox for %d: %v", chatID, err)
+		return "⚠️ Không thể tải nhật ký gửi lúc này."
+	}
+	if len(entries) == 0 {
+		return fmt.Sprintf("ℹ️ Không có tin nào gửi tới %d trong %d ngày qua.", chatID, int(outboxRetention.Hours()/24))
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "📤 %d tin gần nhất gửi tới %d:\n", len(entries), chatID)
+	for _, e := range entries {
+		fmt.Fprintf(&sb, "\n• %s [%s] %s", e.At.In(vnLocation).Format("02/01 15:04:05"), e.Trigger, e.Method)
+		if e.MessageID != 0 {
+			fmt.Fprintf(&sb, " #%d", e.MessageID)
+		}
+		if e.Error != "" {
+			sb.WriteString(" ⚠️ " + e.Error)
+		}
+		if e.Text != "" {
+			sb.WriteString("\n  " + truncateRunes(e.Text, 300))
+		} else if e.Template != "" {
+			fmt.Fprintf(&sb, "\n  %s (%s)", e.Template, e.TextHash)
+		}
+	}
+	return truncateRunes(sb.String(), 4000)
+}
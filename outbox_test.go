@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// recordOutbox binds the outbox to a test collection and returns the entries written to it
+func recordOutbox(t *testing.T) func() []OutboxEntry {
+	t.Helper()
+	var mu sync.Mutex
+	var entries []OutboxEntry
+	savedColl, savedWrite := outboxCollection, bulkWrite
+	outboxCollection = testCollections(t, "outbox")[0]
+	bulkWrite = func(_ context.Context, _ *mongo.Collection, models []mongo.WriteModel, _ ...*options.BulkWriteOptions) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, m := range models {
+			entries = append(entries, m.(*mongo.InsertOneModel).Document.(OutboxEntry))
+		}
+		return nil
+	}
+	t.Cleanup(func() { outboxCollection, bulkWrite = savedColl, savedWrite })
+	return func() []OutboxEntry {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]OutboxEntry(nil), entries...)
+	}
+}
+
+func TestOutboxTransport(t *testing.T) {
+	entries := recordOutbox(t)
+	api := &fakeBotAPI{}
+	b, err := tele.NewBot(tele.Settings{Token: "test", Offline: true, Client: &http.Client{Transport: outboxTransport{base: api}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.Send(&tele.Chat{ID: 1}, "📊 Bản tin [26/05 08:00]\nBTC 60,000.50"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Raw("getChat", map[string]string{"chat_id": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	api.deleted = true
+	b.Edit(&tele.Message{ID: 5, Chat: &tele.Chat{ID: 42}}, "updated")
+	t.Setenv("OUTBOX_FULL_TEXT", "true")
+	b.Send(&tele.Chat{ID: 1}, "full text")
+
+	got := entries()
+	if len(got) != 3 {
+		t.Fatalf("recorded %d entries, want the two sends and the edit: %+v", len(got), got)
+	}
+	sent := got[0]
+	if sent.Method != "sendMessage" || sent.ChatID != 1 || sent.MessageID != 1 || sent.Trigger != "local" {
+		t.Errorf("send entry = %+v", sent)
+	}
+	if sent.Template != "📊 Bản tin [#/# #:#]" || len(sent.TextHash) != 16 || sent.Text != "" {
+		t.Errorf("send entry template %q, hash %q, text %q", sent.Template, sent.TextHash, sent.Text)
+	}
+	edit := got[1]
+	if edit.Method != "editMessageText" || edit.ChatID != 42 || !strings.Contains(edit.Error, "message to edit not found") {
+		t.Errorf("failed edit entry = %+v; want chat 42 from the request and the error", edit)
+	}
+	if got[2].Text != "full text" {
+		t.Errorf("OUTBOX_FULL_TEXT entry text = %q", got[2].Text)
+	}
+	// The bot still sees the response the transport read
+	if len(api.calls) != 4 {
+		t.Errorf("%d calls reached the API, want 4", len(api.calls))
+	}
+}
+
+func TestMessageTemplate(t *testing.T) {
+	tests := []struct{ text, want string }{
+		{"📊 **BÁO CÁO THỊ TRƯỜNG** [26/05 08:00]\n₿ Bitcoin: `$60000.00`", "📊 BÁO CÁO THỊ TRƯỜNG [#/# #:#]"},
+		{"  🔔 BTC vượt 65,000.5\n", "🔔 BTC vượt #"},
+		{"", ""},
+		{strings.Repeat("á", 60), strings.Repeat("á", maxTemplateLen) + "…"},
+	}
+	for _, tt := range tests {
+		if got := messageTemplate(tt.text); got != tt.want {
+			t.Errorf("messageTemplate(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSentReplyArguments(t *testing.T) {
+	saved := outboxCollection
+	outboxCollection = nil
+	t.Cleanup(func() { outboxCollection = saved })
+	for _, args := range [][]string{nil, {"x"}, {"1", "2"}} {
+		if got := sentReply(args); !strings.HasPrefix(got, "ℹ️ Cú pháp: /sent") {
+			t.Errorf("sentReply(%v) = %q, want the usage", args, got)
+		}
+	}
+	if got := sentReply([]string{"42"}); !strings.Contains(got, "cơ sở dữ liệu") {
+		t.Errorf("sentReply without a database = %q", got)
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// performanceDays is how much portfolio history /performance reads and charts
+const performanceDays = 31
+
+var portfolioHistoryCollection *mongo.Collection
+
+// PortfolioSnapshot is one chat's portfolio value for a Vietnam-time day, written by the
+// scheduled broadcast (the day's last run wins). Prices are the per-symbol prices used;
+// Stale lists symbols whose quote failed and were valued at their last known price.
+type PortfolioSnapshot struct {
+	ChatID   int64              `bson:"chat_id"`
+	Day      string             `bson:"day"`
+	At       time.Time          `bson:"at"`
+	ValueUSD float64            `bson:"value_usd"`
+	ValueVND float64            `bson:"value_vnd"`
+	Prices   map[string]float64 `bson:"prices"`
+	Stale    []string           `bson:"stale,omitempty"`
+}
+
+// --- PORTFOLIO PERFORMANCE ---
+
+// valuePortfolio prices holdings at the current quotes, carrying a failed quote forward
+// from last (the previous snapshot's prices). ok is false if some symbol has no price at all.
+func valuePortfolio(holdings []Holding, quotes map[string]MarketData, last map[string]float64) (usd float64, prices map[string]float64, stale []string, ok bool) {
+	prices = make(map[string]float64, len(holdings))
+	for _, h := range holdings {
+		price := 0.0
+		if d, found := quotes[h.Symbol]; found && d.Err == nil && d.Price > 0 {
+			price = d.Price
+		} else if p := last[h.Symbol]; p > 0 {
+			price = p
+			stale = append(stale, h.Symbol)
+		} else {
+			return 0, nil, nil, false
+		}
+		prices[h.Symbol] = price
+		usd += h.Quantity * price
+	}
+	return usd, prices, stale, true
+}
+
+// latestPortfolioSnapshots returns each chat's most recent snapshot from before day
+func latestPortfolioSnapshots(chatIDs []int64, day string) map[int64]PortfolioSnapshot {
+	out := make(map[int64]PortfolioSnapshot)
+	if portfolioHistoryCollection == nil || len(chatIDs) == 0 {
+		return out
+	}
+	cursor, err := portfolioHistoryCollection.Aggregate(context.TODO(), mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"chat_id": bson.M{"$in": chatIDs}, "day": bson.M{"$lt": day}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "day", Value: -1}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$chat_id", "doc": bson.M{"$first": "$$ROOT"}}}},
+	})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load portfolio history: %v", err)
+		return out
+	}
+	defer cursor.Close(context.TODO())
+	for cursor.Next(context.TODO()) {
+		var result struct {
+			Doc PortfolioSnapshot `bson:"doc"`
+		}
+		if cursor.Decode(&result) == nil {
+			out[result.Doc.ChatID] = result.Doc
+		}
+	}
+	return out
+}
+
+// snapshotPortfolios values every non-empty portfolio among chatIDs and (when persist is
+// set) stores today's snapshot, returning today's and the previous snapshot per chat for the
+// broadcast line. quotes is extended with any holding symbol it doesn't cover yet.
+func snapshotPortfolios(chatIDs []int64, quotes map[string]MarketData, persist bool) (today, previous map[int64]PortfolioSnapshot) {
+	today = make(map[int64]PortfolioSnapshot)
+	if portfolioHistoryCollection == nil {
+		return today, nil
+	}
+	portfolios := loadHoldings(chatIDs)
+	var owners []int64
+	var missing []string
+	seen := make(map[string]bool)
+	for id, holdings := range portfolios {
+		if len(holdings) == 0 {
+			continue
+		}
+		owners = append(owners, id)
+		for _, h := range holdings {
+			if _, ok := quotes[h.Symbol]; !ok && !seen[h.Symbol] {
+				seen[h.Symbol] = true
+				missing = append(missing, h.Symbol)
+			}
+		}
+	}
+	if len(owners) == 0 {
+		return today, nil
+	}
+	for symbol, d := range fetchQuotes(missing) {
+		quotes[symbol] = d
+	}
+	usdVnd, rateErr := getCachedUsdVnd(os.Getenv("TWELVE_DATA_API_KEY"))
+
+	now := clock()
+	day := now.In(vnLocation).Format("2006-01-02")
+	previous = latestPortfolioSnapshots(owners, day)
+	var writes []mongo.WriteModel
+	for _, id := range owners {
+		usd, prices, stale, ok := valuePortfolio(portfolios[id], quotes, previous[id].Prices)
+		if !ok {
+			log.Printf("[PORTFOLIO] Skipping snapshot for %d: a holding has no price", id)
+			continue
+		}
+		snap := PortfolioSnapshot{ChatID: id, Day: day, At: now, ValueUSD: usd, Prices: prices, Stale: stale}
+		if rateErr == nil {
+			snap.ValueVND = usd * usdVnd
+		}
+		today[id] = snap
+		writes = append(writes, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"chat_id": id, "day": day}).SetReplacement(snap).SetUpsert(true))
+	}
+	if !persist {
+		return today, previous
+	}
+	if len(writes) > 0 {
+		if _, err := portfolioHistoryCollection.BulkWrite(context.TODO(), writes, options.BulkWrite().SetOrdered(false)); err != nil {
+			log.Printf("[DATABASE ERROR] Failed to save portfolio snapshots: %v", err)
+		}
+	}
+	log.Printf("[PORTFOLIO] Snapshotted %d/%d portfolios", len(writes), len(owners))
+	return today, previous
+}
+
+// percentChange is the change from old to new in percent; ok is false without a base
+func percentChange(old, new float64) (float64, bool) {
+	if old <= 0 {
+		return 0, false
+	}
+	return (new/old - 1) * 100, true
+}
+
+// portfolioDeltaLine is the one-line portfolio summary in a holder's broadcast
+func portfolioDeltaLine(today PortfolioSnapshot, previous PortfolioSnapshot, hasPrevious bool) string {
+	line := fmt.Sprintf("💼 **Danh mục:** `$%.2f`", today.ValueUSD)
+	if pct, ok := percentChange(previous.ValueUSD, today.ValueUSD); hasPrevious && ok {
+		line += fmt.Sprintf(" (%+.2f%% từ %s)", pct, previous.Day)
+	}
+	if len(today.Stale) > 0 {
+		line += " ⚠️"
+	}
+	return line
+}
+
+// sparkline draws values as a row of block characters scaled between their min and max
+func sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	const blocks = "▁▂▃▄▅▆▇█"
+	levels := []rune(blocks)
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	var sb strings.Builder
+	for _, v := range values {
+		i := len(levels) / 2
+		if hi > lo {
+			i = int((v - lo) / (hi - lo) * float64(len(levels)-1))
+		}
+		sb.WriteRune(levels[i])
+	}
+	return sb.String()
+}
+
+// snapshotOnOrBefore returns the latest snapshot dated day or earlier; history is oldest first
+func snapshotOnOrBefore(history []PortfolioSnapshot, day string) (PortfolioSnapshot, bool) {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Day <= day {
+			return history[i], true
+		}
+	}
+	return PortfolioSnapshot{}, false
+}
+
+// loadPortfolioHistory returns a chat's snapshots from the last days days, oldest first
+func loadPortfolioHistory(chatID int64, days int) []PortfolioSnapshot {
+	if portfolioHistoryCollection == nil {
+		return nil
+	}
+	since := clock().In(vnLocation).AddDate(0, 0, -days).Format("2006-01-02")
+	cursor, err := portfolioHistoryCollection.Find(context.TODO(),
+		bson.M{"chat_id": chatID, "day": bson.M{"$gte": since}},
+		options.Find().SetSort(bson.D{{Key: "day", Value: 1}}))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load portfolio history for %d: %v", chatID, err)
+		return nil
+	}
+	defer cursor.Close(context.TODO())
+	var history []PortfolioSnapshot
+	if err := cursor.All(context.TODO(), &history); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to decode portfolio history for %d: %v", chatID, err)
+	}
+	return history
+}
+
+// performancePeriod is one /performance comparison: its label and the day whose value
+// is the base
+type performancePeriod struct {
+	label string
+	day   string
+}
+
+// performancePeriods returns the 7-day, 30-day and month-to-date bases for a Vietnam-time now
+func performancePeriods(now time.Time) []performancePeriod {
+	return []performancePeriod{
+		{"7 ngày", now.AddDate(0, 0, -7).Format("2006-01-02")},
+		{"30 ngày", now.AddDate(0, 0, -30).Format("2006-01-02")},
+		// Day 0 of this month is the last day of the previous one, whatever its length, so
+		// month-to-date compares against last month's final value
+		{"Từ đầu tháng", time.Date(now.Year(), now.Month(), 0, 0, 0, 0, 0, vnLocation).Format("2006-01-02")},
+	}
+}
+
+// performanceReply handles "/performance": 7-day, 30-day and month-to-date change of the
+// recorded daily portfolio values, with a sparkline of the last month
+func performanceReply(chatID int64) string {
+	// One extra month of history so month-to-date can find last month's closing value
+	history := loadPortfolioHistory(chatID, performanceDays+31)
+	if len(history) == 0 {
+		return "ℹ️ Chưa có lịch sử danh mục. Giá trị được ghi lại mỗi ngày cùng bản tin khi bạn có tài sản (/portfolio add)."
+	}
+	style := getNumberStyle(chatID)
+	latest := history[len(history)-1]
+	now := clock().In(vnLocation)
+
+	var sb strings.Builder
+	sb.WriteString("📈 **HIỆU SUẤT DANH MỤC**\n")
+	fmt.Fprintf(&sb, "• Giá trị (%s): `$%s`", latest.Day, formatNumber(latest.ValueUSD, 2, style))
+	if latest.ValueVND > 0 {
+		fmt.Fprintf(&sb, " ≈ %s VNĐ", formatNumber(latest.ValueVND, 0, style))
+	}
+	sb.WriteString("\n")
+	for _, p := range performancePeriods(now) {
+		base, ok := snapshotOnOrBefore(history, p.day)
+		if pct, hasBase := percentChange(base.ValueUSD, latest.ValueUSD); ok && hasBase && base.Day < latest.Day {
+			fmt.Fprintf(&sb, "• %s: `%+.2f%%` (%s$%s)\n", p.label, pct, signOf(latest.ValueUSD-base.ValueUSD),
+				formatNumber(math.Abs(latest.ValueUSD-base.ValueUSD), 2, style))
+		} else {
+			fmt.Fprintf(&sb, "• %s: _chưa đủ dữ liệu_\n", p.label)
+		}
+	}
+
+	cutoff := now.AddDate(0, 0, -performanceDays).Format("2006-01-02")
+	var values []float64
+	for _, s := range history {
+		if s.Day >= cutoff {
+			values = append(values, s.ValueUSD)
+		}
+	}
+	if len(values) > 1 {
+		fmt.Fprintf(&sb, "\n`%s`\n_%d ngày gần nhất_", sparkline(values), len(values))
+	}
+	if len(latest.Stale) > 0 {
+		fmt.Fprintf(&sb, "\n⚠️ _Giá của %s lấy từ lần gần nhất do lỗi dữ liệu hôm đó._", strings.Join(latest.Stale, ", "))
+	}
+	return sb.String()
+}
+
+// signOf returns "+" or "-" for a signed amount
+func signOf(v float64) string {
+	if v < 0 {
+		return "-"
+	}
+	return "+"
+}
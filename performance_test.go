@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// dailyHistory is one snapshot per day from first to last inclusive, oldest first, each
+// valued at its day of the year
+func dailyHistory(first, last string) []PortfolioSnapshot {
+	day, _ := time.ParseInLocation("2006-01-02", first, vnLocation)
+	end, _ := time.ParseInLocation("2006-01-02", last, vnLocation)
+	var history []PortfolioSnapshot
+	for ; !day.After(end); day = day.AddDate(0, 0, 1) {
+		history = append(history, PortfolioSnapshot{Day: day.Format("2006-01-02"), ValueUSD: float64(day.YearDay())})
+	}
+	return history
+}
+
+func TestPercentChange(t *testing.T) {
+	tests := []struct {
+		old, new float64
+		want     float64
+		ok       bool
+	}{
+		{100, 110, 10, true},
+		{200, 150, -25, true},
+		{80, 80, 0, true},
+		{0, 50, 0, false},
+		{-10, 50, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := percentChange(tt.old, tt.new)
+		if ok != tt.ok || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("percentChange(%v, %v) = %v, %v; want %v, %v", tt.old, tt.new, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSnapshotOnOrBefore(t *testing.T) {
+	// 2026-03-05 is missing: a base that falls on it uses the day before
+	history := append(dailyHistory("2026-03-01", "2026-03-04"), dailyHistory("2026-03-06", "2026-03-08")...)
+	tests := []struct {
+		day, want string
+		ok        bool
+	}{
+		{"2026-03-03", "2026-03-03", true},
+		{"2026-03-05", "2026-03-04", true},
+		{"2026-03-20", "2026-03-08", true},
+		{"2026-02-28", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := snapshotOnOrBefore(history, tt.day)
+		if ok != tt.ok || got.Day != tt.want {
+			t.Errorf("snapshotOnOrBefore(%s) = %s, %v; want %s, %v", tt.day, got.Day, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPerformancePeriods(t *testing.T) {
+	history := dailyHistory("2026-01-01", "2026-06-30")
+	tests := []struct {
+		name string
+		now  time.Time
+		// 7-day, 30-day and month-to-date base days
+		want [3]string
+	}{
+		// February 2026 has 28 days
+		{"after a 28-day month", time.Date(2026, 3, 15, 8, 0, 0, 0, vnLocation),
+			[3]string{"2026-03-08", "2026-02-13", "2026-02-28"}},
+		// March has 31
+		{"after a 31-day month", time.Date(2026, 4, 3, 8, 0, 0, 0, vnLocation),
+			[3]string{"2026-03-27", "2026-03-04", "2026-03-31"}},
+		// April has 30
+		{"after a 30-day month", time.Date(2026, 5, 31, 8, 0, 0, 0, vnLocation),
+			[3]string{"2026-05-24", "2026-05-01", "2026-04-30"}},
+		{"first of the month", time.Date(2026, 3, 1, 8, 0, 0, 0, vnLocation),
+			[3]string{"2026-02-22", "2026-01-30", "2026-02-28"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			periods := performancePeriods(tt.now)
+			if len(periods) != len(tt.want) {
+				t.Fatalf("%d periods, want %d", len(periods), len(tt.want))
+			}
+			latest, _ := snapshotOnOrBefore(history, tt.now.Format("2006-01-02"))
+			for i, p := range periods {
+				if p.day != tt.want[i] {
+					t.Errorf("%s base = %s, want %s", p.label, p.day, tt.want[i])
+				}
+				base, ok := snapshotOnOrBefore(history, p.day)
+				day, _ := time.ParseInLocation("2006-01-02", tt.want[i], vnLocation)
+				wantPct := (float64(tt.now.YearDay())/float64(day.YearDay()) - 1) * 100
+				if pct, hasBase := percentChange(base.ValueUSD, latest.ValueUSD); !ok || !hasBase || math.Abs(pct-wantPct) > 1e-9 {
+					t.Errorf("%s change = %v, want %v", p.label, pct, wantPct)
+				}
+			}
+		})
+	}
+}
+
+func TestValuePortfolio(t *testing.T) {
+	holdings := []Holding{{Symbol: "btc", Quantity: 0.5}, {Symbol: "gold", Quantity: 2}}
+	last := map[string]float64{"btc": 58000, "gold": 2300}
+
+	usd, prices, stale, ok := valuePortfolio(holdings, map[string]MarketData{
+		"btc": {Price: 60000}, "gold": {Price: 2400},
+	}, last)
+	if !ok || usd != 34800 || len(stale) != 0 {
+		t.Errorf("all quoted: %v, %v, stale %v", usd, ok, stale)
+	}
+	if !reflect.DeepEqual(prices, map[string]float64{"btc": 60000, "gold": 2400}) {
+		t.Errorf("all quoted: prices %v", prices)
+	}
+
+	// A failed quote, like a missing one, is valued at the last snapshot's price and reported
+	for _, gold := range []map[string]MarketData{
+		{"btc": {Price: 60000}, "gold": {Err: errors.New("timeout")}},
+		{"btc": {Price: 60000}},
+	} {
+		usd, prices, stale, ok = valuePortfolio(holdings, gold, last)
+		if !ok || usd != 34600 || prices["gold"] != 2300 || !reflect.DeepEqual(stale, []string{"gold"}) {
+			t.Errorf("gold failed: %v, %v, prices %v, stale %v; want 34600 with gold stale at 2300", usd, ok, prices, stale)
+		}
+	}
+
+	// Without a previous price there is nothing to carry forward
+	if _, _, _, ok := valuePortfolio(holdings, map[string]MarketData{"btc": {Price: 60000}}, nil); ok {
+		t.Error("valued a portfolio with an unpriced holding")
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// --- PINNED REPORTS ---
+
+// loadPinnedReports returns the chats that want their report pinned, mapped to the message
+// ID of the report pinned last time (0 if none yet)
+func loadPinnedReports() map[int64]int {
+	pins := make(map[int64]int)
+	if userCollection == nil {
+		return pins
+	}
+	cursor, err := userCollection.Find(context.TODO(), bson.M{"pin_report": true},
+		options.Find().SetProjection(bson.M{"chat_id": 1, "pinned_message_id": 1}))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load pin preferences: %v", err)
+		return pins
+	}
+	defer cursor.Close(context.TODO())
+	for cursor.Next(context.TODO()) {
+		var result struct {
+			ChatID          int64 `bson:"chat_id"`
+			PinnedMessageID int   `bson:"pinned_message_id"`
+		}
+		if cursor.Decode(&result) == nil {
+			pins[result.ChatID] = result.PinnedMessageID
+		}
+	}
+	return pins
+}
+
+// getPinReport reports whether a chat has report pinning on
+func getPinReport(chatID int64) bool {
+	if userCollection == nil {
+		return false
+	}
+	var result struct {
+		PinReport bool `bson:"pin_report"`
+	}
+	userCollection.FindOne(context.TODO(), bson.M{"chat_id": chatID},
+		options.FindOne().SetProjection(bson.M{"pin_report": 1})).Decode(&result)
+	return result.PinReport
+}
+
+// setPinReport stores a chat's pin preference; false if the chat isn't subscribed
+func setPinReport(chatID int64, on bool) bool {
+	if userCollection == nil {
+		return false
+	}
+	result, err := userCollection.UpdateOne(context.TODO(), bson.M{"chat_id": chatID},
+		bson.M{"$set": bson.M{"pin_report": on, "updated_at": clock()}, "$inc": bson.M{settingsRevField: 1}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to save pin preference for %d: %v", chatID, err)
+		return false
+	}
+	return result.MatchedCount > 0
+}
+
+// isPinRightsError reports whether Telegram refused the pin for lack of permission
+func isPinRightsError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "not enough rights") || strings.Contains(msg, "chat_admin_required")
+}
+
+// pinReport pins a freshly sent report and unpins the previous one. Without pin permission
+// the preference is switched off and the chat told once, so later broadcasts don't retry.
+func pinReport(b *tele.Bot, msg *tele.Message, previous int) {
+	chatID := msg.Chat.ID
+	if err := b.Pin(msg, tele.Silent); err != nil {
+		if isPinRightsError(err) {
+			log.Printf("[PIN] No pin permission in %d, disabling", chatID)
+			setPinReport(chatID, false)
+			b.Send(&tele.Chat{ID: chatID}, "⚠️ Bot không có quyền ghim tin nhắn nên đã tắt ghim bản tin. Cấp quyền \"Ghim tin nhắn\" rồi gõ /settings pin on để bật lại.")
+			return
+		}
+		log.Printf("[PIN ERROR] Failed to pin report in %d: %v", chatID, err)
+		return
+	}
+	if previous != 0 && previous != msg.ID {
+		// The old report may already be unpinned or deleted; that's fine
+		if err := b.Unpin(&tele.Chat{ID: chatID}, previous); err != nil {
+			log.Printf("[PIN] Could not unpin %d in %d: %v", previous, chatID, err)
+		}
+	}
+	queueWrite(userCollection, mongo.NewUpdateOneModel().SetFilter(bson.M{"chat_id": chatID}).
+		SetUpdate(bson.M{"$set": bson.M{"pinned_message_id": msg.ID}}))
+}
+
+// pinSettingReply handles "/settings pin on|off"
+func pinSettingReply(chatID int64, arg string) string {
+	if arg != "on" && arg != "off" {
+		return "ℹ️ Cú pháp: /settings pin on|off"
+	}
+	if !setPinReport(chatID, arg == "on") {
+		return "ℹ️ Bạn cần đăng ký bằng /start trước."
+	}
+	if arg == "on" {
+		return "📌 Bản tin mới sẽ được ghim và bản cũ được bỏ ghim. Trong nhóm, bot cần quyền \"Ghim tin nhắn\"."
+	}
+	return "✅ Đã tắt ghim bản tin."
+}
+
+// onOff renders a boolean setting
+func onOff(v bool) string {
+	if v {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// --- PLAIN-TEXT REPORT ---
+
+// padRight and padLeft pad s with spaces to width runes
+func padRight(s string, width int) string {
+	return s + strings.Repeat(" ", max(0, width-utf8.RuneCountInString(s)))
+}
+
+func padLeft(s string, width int) string {
+	return strings.Repeat(" ", max(0, width-utf8.RuneCountInString(s))) + s
+}
+
+// plainQuoteTable renders quotes as space-aligned columns (name, price, change); names are
+// the upper-cased IDs since emoji labels have no reliable width
+func plainQuoteTable(symbols []string, quotes map[string]MarketData) string {
+	rows := make([][3]string, len(symbols))
+	var widths [3]int
+	for i, symbol := range symbols {
+		d := quotes[symbol]
+		row := [3]string{strings.ToUpper(symbol), "n/a", ""}
+		if d.Err == nil && d.Price > 0 {
+			row[1] = strings.Trim(fmt.Sprintf(lookupAsset(symbol).PriceFormat, d.Price), "`")
+			row[2] = d.Change
+		}
+		rows[i] = row
+		for c, cell := range row {
+			widths[c] = max(widths[c], utf8.RuneCountInString(cell))
+		}
+	}
+	lines := make([]string, len(rows))
+	for i, row := range rows {
+		line := padRight(row[0], widths[0]) + "  " + padLeft(row[1], widths[1])
+		if widths[2] > 0 {
+			line += "  " + padLeft(row[2], widths[2])
+		}
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderPlainReport renders the report without any Markdown, for clients and relays that
+// strip formatting
+func renderPlainReport(title string, at time.Time, symbols []string, quotes map[string]MarketData,
+	usdVnd float64, headlines []Headline) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s\nCập nhật: %s\n\n", stripMarkdown(title), formatDateTime(at, newsLangVI, vnLocation))
+	sb.WriteString("THỊ TRƯỜNG\n")
+	if usdVnd > 0 {
+		fmt.Fprintf(&sb, "USD/VND ≈ %s VNĐ\n", formatVnd(usdVnd))
+	}
+	sb.WriteString(plainQuoteTable(symbols, quotes))
+	if len(headlines) > 0 {
+		sb.WriteString("\n\nTIN TỨC\n")
+		for _, h := range headlines {
+			fmt.Fprintf(&sb, "- %s\n  %s\n", h.Title, h.Link)
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// stripMarkdown drops the legacy-Markdown markers from a string meant for plain text
+func stripMarkdown(s string) string {
+	return strings.NewReplacer("**", "", "*", "", "_", "", "`", "").Replace(s)
+}
+
+// plainWatchSection lists a plain-text reader's extra watchlist symbols
+func plainWatchSection(extras []string, quotes map[string]MarketData) string {
+	if len(extras) == 0 {
+		return ""
+	}
+	return "THEO DÕI\n" + plainQuoteTable(extras, quotes)
+}
+
+// loadPlainUsers returns the chats that chose plain-text reports
+func loadPlainUsers() map[int64]bool {
+	users := make(map[int64]bool)
+	if userCollection == nil {
+		return users
+	}
+	cursor, err := userCollection.Find(context.TODO(), bson.M{"plain_text": true},
+		options.Find().SetProjection(bson.M{"chat_id": 1}))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load plain-text preferences: %v", err)
+		return users
+	}
+	defer cursor.Close(context.TODO())
+	for cursor.Next(context.TODO()) {
+		var result struct {
+			ChatID int64 `bson:"chat_id"`
+		}
+		if cursor.Decode(&result) == nil {
+			users[result.ChatID] = true
+		}
+	}
+	return users
+}
+
+// plainTextReply handles "/plaintext on|off"
+func plainTextReply(chatID int64, payload string) string {
+	arg := strings.ToLower(strings.TrimSpace(payload))
+	if arg != "on" && arg != "off" {
+		return "Cú pháp: /plaintext on hoặc /plaintext off"
+	}
+	if userCollection == nil {
+		return "Không thể lưu lúc này."
+	}
+	result, err := userCollection.UpdateOne(context.TODO(), bson.M{"chat_id": chatID},
+		bson.M{"$set": bson.M{"plain_text": arg == "on", "updated_at": clock()}, "$inc": bson.M{settingsRevField: 1}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to save plain-text preference for %d: %v", chatID, err)
+		return "Không thể lưu lúc này."
+	}
+	if result.MatchedCount == 0 {
+		return "Bạn cần đăng ký bằng /start trước."
+	}
+	if arg == "on" {
+		return "Bản tin sẽ được gửi dạng văn bản thuần, không định dạng."
+	}
+	return "✅ Bản tin sẽ được gửi với định dạng như bình thường."
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// broadcastUser is everything about a subscriber that shapes their copy of the broadcast
+type broadcastUser struct {
+	ID    int64
+	Cards bool
+	// Plain users get the Markdown-free rendering, without experiment variants or digests
+	Plain         bool
+	Variant       string
+	Extras        []string
+	LastDelivered time.Time
+	// MoveThreshold is the minimum move (percent) for the biggest-movers section
+	MoveThreshold float64
+	// Portfolio is the holder's one-line portfolio delta ("" without holdings)
+	Portfolio string
+	// Channel chats all share the channel rendering (see channelText)
+	Channel bool
+}
+
+// BroadcastPlan maps every recipient onto a prerendered text. Shared holds one rendering per
+// distinct (variant, news mode, watchlist extras, move threshold) combination plus one for
+// channels; Personal holds the few texts that are unique to one chat (catch-up digests,
+// portfolio lines).
+type BroadcastPlan struct {
+	Shared   map[string]string
+	Keys     map[int64]string
+	Personal map[int64]string
+	// Quotes are the prices the plan was rendered from, watchlist extras included
+	Quotes map[string]MarketData
+}
+
+// broadcastAudience is the per-chat state that shapes each copy of a broadcast, loaded
+// once per run
+type broadcastAudience struct {
+	Cards, Split, Plain, Channels map[int64]bool
+	Watchlists                    map[int64][]string
+	Thresholds                    map[int64]float64
+	// LastDelivered and Snapshots drive the missed-report digest
+	LastDelivered map[int64]time.Time
+	Snapshots     []Snapshot
+}
+
+// --- BROADCAST PLANNER ---
+
+// watchlistExtras returns the watchlist symbols the report doesn't already show, sorted so
+// users with the same set share a rendering
+func watchlistExtras(watchlist []string, reportSymbols []string) []string {
+	inReport := make(map[string]bool, len(reportSymbols))
+	for _, s := range reportSymbols {
+		inReport[s] = true
+	}
+	var extras []string
+	for _, s := range watchlist {
+		if !inReport[s] {
+			extras = append(extras, s)
+		}
+	}
+	sort.Strings(extras)
+	return extras
+}
+
+// watchSection renders the per-user watchlist block appended to the market section
+func watchSection(extras []string, quotes map[string]MarketData) string {
+	if len(extras) == 0 {
+		return ""
+	}
+	rows := make([]string, len(extras))
+	for i, symbol := range extras {
+		asset := lookupAsset(symbol)
+		rows[i] = quoteLine(asset.Label, asset.PriceFormat, quotes[symbol])
+	}
+	return "⭐ **DANH SÁCH THEO DÕI:**\n" + strings.Join(rows, "\n")
+}
+
+// reportFooter starts the closing block of a rendered report
+const reportFooter = "━━━━━━━━━━━━━━━━━━\n💡"
+
+// insertBeforeFooter places section above the report's footer (or at the end of reports
+// without one)
+func insertBeforeFooter(text, section string) string {
+	if section == "" {
+		return text
+	}
+	if i := strings.LastIndex(text, reportFooter); i >= 0 {
+		return text[:i] + section + "\n\n" + text[i:]
+	}
+	return text + "\n\n" + section
+}
+
+// planKey identifies the shared rendering a user gets
+func planKey(u broadcastUser) string {
+	if u.Channel {
+		return "channel"
+	}
+	if u.Plain {
+		return "plain|" + strings.Join(u.Extras, ",")
+	}
+	return fmt.Sprintf("%s|%t|%s|%g", u.Variant, u.Cards, strings.Join(u.Extras, ","), u.MoveThreshold)
+}
+
+// planBroadcast renders each distinct combination once and maps users onto it. quotes
+// must already cover every extra symbol (see unionExtras). Only users with a missed-report
+// digest or a portfolio line get a per-user text; channels never do.
+func planBroadcast(report MarketReport, users []broadcastUser, quotes map[string]MarketData,
+	snapshots []Snapshot, symbols []string, botUsername string) BroadcastPlan {
+	plan := BroadcastPlan{Shared: map[string]string{}, Keys: map[int64]string{}, Personal: map[int64]string{}, Quotes: quotes}
+	for _, u := range users {
+		key := planKey(u)
+		plan.Keys[u.ID] = key
+		if u.Channel {
+			if _, ok := plan.Shared[key]; !ok {
+				plan.Shared[key] = channelText(report, symbols, botUsername)
+			}
+			continue
+		}
+		if u.Plain {
+			if _, ok := plan.Shared[key]; !ok {
+				plan.Shared[key] = report.Plain
+				if section := plainWatchSection(u.Extras, quotes); section != "" {
+					plan.Shared[key] += "\n\n" + section
+				}
+			}
+			if u.Portfolio != "" {
+				plan.Personal[u.ID] = plan.Shared[key] + "\n\n" + stripMarkdown(u.Portfolio)
+			}
+			continue
+		}
+		if _, ok := plan.Shared[key]; !ok {
+			text := report.textFor(u.Cards)
+			if t, ok := report.Variants[u.Variant]; ok {
+				text = t.textFor(u.Cards)
+			}
+			shown := append(append([]string(nil), symbols...), u.Extras...)
+			text = insertBeforeFooter(text, moversSection(shown, quotes, u.MoveThreshold))
+			text = insertBeforeFooter(text, watchSection(u.Extras, quotes))
+			plan.Shared[key] = text
+		}
+		if digest := missedDigest(missedReports(snapshots, u.LastDelivered, report.At), quotes, symbols); digest != "" {
+			plan.Personal[u.ID] = digest + "\n\n" + plan.Shared[key]
+		}
+		if u.Portfolio != "" {
+			plan.Personal[u.ID] = insertBeforeFooter(plan.textFor(u.ID), u.Portfolio)
+		}
+	}
+	// Watchlist and digest sections add entities on top of the report's own
+	for key, text := range plan.Shared {
+		if !strings.HasPrefix(key, "plain|") {
+			plan.Shared[key] = fitEntityLimit(text)
+		}
+	}
+	for id, text := range plan.Personal {
+		if !strings.HasPrefix(plan.Keys[id], "plain|") {
+			plan.Personal[id] = fitEntityLimit(text)
+		}
+	}
+	log.Printf("[BROADCAST] Planned %d users onto %d shared renderings (%d personalized)",
+		len(users), len(plan.Shared), len(plan.Personal))
+	return plan
+}
+
+// textFor returns the text planned for a chat
+func (p BroadcastPlan) textFor(id int64) string {
+	if text, ok := p.Personal[id]; ok {
+		return text
+	}
+	return p.Shared[p.Keys[id]]
+}
+
+// unionExtras lists every extra symbol any user needs that isn't quoted yet
+func unionExtras(users []broadcastUser, quotes map[string]MarketData) []string {
+	seen := make(map[string]bool)
+	var missing []string
+	for _, u := range users {
+		for _, s := range u.Extras {
+			if _, ok := quotes[s]; !ok && !seen[s] {
+				seen[s] = true
+				missing = append(missing, s)
+			}
+		}
+	}
+	return missing
+}
+
+// loadBroadcastAudience reads every subscriber's rendering preferences
+func loadBroadcastAudience() broadcastAudience {
+	return broadcastAudience{
+		Cards:         loadNewsModeUsers(newsModeCards),
+		Split:         loadNewsModeUsers(newsModeSplit),
+		Plain:         loadPlainUsers(),
+		Channels:      loadChannels(),
+		Watchlists:    loadWatchlists(),
+		Thresholds:    loadMoveThresholds(),
+		LastDelivered: loadLastDelivered(),
+		Snapshots:     loadRecentSnapshots(maxMissedReports + 1),
+	}
+}
+
+// planBroadcastFor describes ids to the planner and renders their copies: watchlist extras
+// are quoted once for everyone (quarantined ones skipped), and holders get their portfolio
+// line. persist records today's portfolio snapshots, as the broadcast does; a preview
+// passes false.
+func planBroadcastFor(report MarketReport, ids []int64, aud broadcastAudience, botUsername string, persist bool) BroadcastPlan {
+	cfg := loadConfig()
+	recipients := make([]broadcastUser, len(ids))
+	for i, id := range ids {
+		recipients[i] = broadcastUser{
+			ID:            id,
+			Channel:       aud.Channels[id],
+			Cards:         aud.Cards[id] || aud.Split[id],
+			Plain:         aud.Plain[id],
+			Variant:       assignVariant(cfg.Experiment, id),
+			Extras:        watchlistExtras(aud.Watchlists[id], cfg.Symbols),
+			LastDelivered: aud.LastDelivered[id],
+			MoveThreshold: cfg.MoverThreshold,
+		}
+		if t, ok := aud.Thresholds[id]; ok {
+			recipients[i].MoveThreshold = t
+		}
+		if recipients[i].Channel {
+			recipients[i].Extras = nil
+		}
+	}
+	quotes := make(map[string]MarketData, len(report.Quotes))
+	for symbol, d := range report.Quotes {
+		quotes[symbol] = d
+	}
+	// Quarantined symbols aren't quoted; the broadcast retries them once a week
+	missing, held := withoutQuarantined(unionExtras(recipients, quotes), persist)
+	for symbol, d := range held {
+		quotes[symbol] = d
+	}
+	for symbol, d := range fetchQuotes(missing) {
+		quotes[symbol] = d
+	}
+	todayPortfolios, previousPortfolios := snapshotPortfolios(ids, quotes, persist)
+	for i := range recipients {
+		if snap, ok := todayPortfolios[recipients[i].ID]; ok && !recipients[i].Channel {
+			prev, hasPrev := previousPortfolios[recipients[i].ID]
+			recipients[i].Portfolio = portfolioDeltaLine(snap, prev, hasPrev)
+		}
+	}
+	return planBroadcast(report, recipients, quotes, aud.Snapshots, cfg.Symbols, botUsername)
+}
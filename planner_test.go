@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+// benchmarkAudience is 1,000 chats spread over 20 renderings: list and card layouts at nine
+// mover thresholds each, plus plain-text chats and channels
+func benchmarkAudience() ([]int64, broadcastAudience) {
+	aud := broadcastAudience{
+		Cards:      map[int64]bool{},
+		Plain:      map[int64]bool{},
+		Channels:   map[int64]bool{},
+		Thresholds: map[int64]float64{},
+	}
+	ids := make([]int64, 1000)
+	for i := range ids {
+		id := int64(i + 1)
+		ids[i] = id
+		switch {
+		case i%50 == 0:
+			aud.Plain[id] = true
+		case i%50 == 1:
+			id = -1000000 - id
+			ids[i] = id
+			aud.Channels[id] = true
+		default:
+			aud.Cards[id] = i%2 == 0
+			aud.Thresholds[id] = 0.5 + float64((i/2)%9)*0.5
+		}
+	}
+	return ids, aud
+}
+
+func BenchmarkPlanBroadcast(b *testing.B) {
+	withDatabase(b, false, nil)
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(os.Stderr) })
+	report := previewTestReport()
+	ids, aud := benchmarkAudience()
+	if plan := planBroadcastFor(report, ids, aud, "market_bot", false); len(plan.Shared) != 20 || len(plan.Keys) != len(ids) {
+		b.Fatalf("planned %d users onto %d renderings, want 1000 onto 20", len(plan.Keys), len(plan.Shared))
+	}
+	b.ResetTimer()
+	for range b.N {
+		planBroadcastFor(report, ids, aud, "market_bot", false)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// The daily poll asks whether gold closes higher than the price at broadcast time
+const (
+	pollSymbol   = "gold"
+	pollQuestion = "Vàng sẽ đóng cửa cao hơn hay thấp hơn hôm nay?"
+	pollOptionUp = 0
+	pollOptionDn = 1
+)
+
+var pollCollection *mongo.Collection
+
+// DailyPoll is one poll message sent to one chat; every chat gets its own Telegram poll
+type DailyPoll struct {
+	ChatID         int64     `bson:"chat_id"`
+	MessageID      int       `bson:"message_id"`
+	PollID         string    `bson:"poll_id"`
+	Date           string    `bson:"date"`
+	Symbol         string    `bson:"symbol"`
+	ReferencePrice float64   `bson:"reference_price"`
+	Resolved       bool      `bson:"resolved"`
+	CreatedAt      time.Time `bson:"created_at"`
+}
+
+// --- DAILY POLL ---
+
+// isPollDay reports whether a poll should go out on t; markets are closed at weekends
+func isPollDay(t time.Time) bool {
+	wd := t.In(vnLocation).Weekday()
+	return wd != time.Saturday && wd != time.Sunday
+}
+
+// loadPollRecipients returns subscribers who haven't opted out of the daily poll
+func loadPollRecipients() []int64 {
+	var ids []int64
+	if userCollection == nil {
+		return ids
+	}
+	cursor, err := userCollection.Find(context.TODO(), bson.M{"poll_opt_out": bson.M{"$ne": true}},
+		options.Find().SetProjection(bson.M{"chat_id": 1}))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load poll recipients: %v", err)
+		return ids
+	}
+	defer cursor.Close(context.TODO())
+	for cursor.Next(context.TODO()) {
+		var result struct {
+			ChatID int64 `bson:"chat_id"`
+		}
+		if cursor.Decode(&result) == nil && isValidChatID(result.ChatID) {
+			ids = append(ids, result.ChatID)
+		}
+	}
+	return ids
+}
+
+// setPollOptOut stores the user's poll preference; returns false if they aren't subscribed
+func setPollOptOut(id int64, optOut bool) bool {
+	if userCollection == nil {
+		return false
+	}
+	result, err := userCollection.UpdateOne(context.TODO(), bson.M{"chat_id": id},
+		bson.M{"$set": bson.M{"poll_opt_out": optOut, "updated_at": clock()}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to update poll preference for %d: %v", id, err)
+		return false
+	}
+	return result.MatchedCount > 0
+}
+
+// newDailyPoll builds the poll message
+func newDailyPoll(anonymous bool) *tele.Poll {
+	poll := &tele.Poll{Type: tele.PollRegular, Question: pollQuestion, Anonymous: anonymous}
+	poll.AddOptions("📈 Cao hơn", "📉 Thấp hơn")
+	return poll
+}
+
+// sendDailyPolls sends today's poll to every recipient and records each poll with the
+// reference price so the next broadcast can announce the outcome
+func sendDailyPolls(b *tele.Bot, recipients []int64, reference float64) {
+	now := clock()
+	if !isPollDay(now) {
+		log.Println("[POLL] Weekend, skipping daily poll")
+		return
+	}
+	if pollCollection == nil || reference <= 0 {
+		log.Println("[POLL] No reference price or database, skipping daily poll")
+		return
+	}
+	date := now.In(vnLocation).Format("2006-01-02")
+	// A second cron slot on the same day must not send a second poll
+	if n, err := pollCollection.CountDocuments(context.TODO(), bson.M{"date": date}); err == nil && n > 0 {
+		log.Printf("[POLL] Polls for %s already sent", date)
+		return
+	}
+
+	threads := loadThreadIDs()
+	sent := 0
+	for _, id := range recipients {
+		// Votes must be attributable for the leaderboard; channels only allow anonymous polls
+		msg, err := deliver(b, id, threads[id], newDailyPoll(false), nil)
+		if err != nil {
+			msg, err = deliver(b, id, threads[id], newDailyPoll(true), nil)
+		}
+		if err != nil || msg.Poll == nil {
+			log.Printf("[POLL ERROR] Failed to send poll to %d: %v", id, err)
+			continue
+		}
+		_, err = pollCollection.InsertOne(context.TODO(), DailyPoll{
+			ChatID:         id,
+			MessageID:      msg.ID,
+			PollID:         msg.Poll.ID,
+			Date:           date,
+			Symbol:         pollSymbol,
+			ReferencePrice: reference,
+			CreatedAt:      now,
+		})
+		if err != nil {
+			log.Printf("[DATABASE ERROR] Failed to store poll for %d: %v", id, err)
+			continue
+		}
+		sent++
+	}
+	log.Printf("[POLL] Sent %d/%d polls for %s at reference %.2f", sent, len(recipients), date, reference)
+}
+
+// pollOutcome returns the winning option for a move from reference to current, or -1 when flat
+func pollOutcome(reference, current float64) int {
+	switch {
+	case current > reference:
+		return pollOptionUp
+	case current < reference:
+		return pollOptionDn
+	default:
+		return -1
+	}
+}
+
+// resolvePendingPolls closes every unresolved poll, tallies the votes across all chats and
+// returns the announcement for the top of today's broadcast ("" when nothing to announce)
+func resolvePendingPolls(b *tele.Bot, current float64) string {
+	if pollCollection == nil || current <= 0 {
+		return ""
+	}
+	cursor, err := pollCollection.Find(context.TODO(), bson.M{"resolved": false})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load pending polls: %v", err)
+		return ""
+	}
+	var pending []DailyPoll
+	if err := cursor.All(context.TODO(), &pending); err != nil || len(pending) == 0 {
+		return ""
+	}
+
+	// Polls are normally all from the previous session; announce against the latest one
+	latest := pending[0]
+	for _, p := range pending {
+		if p.Date > latest.Date {
+			latest = p
+		}
+	}
+	outcome := pollOutcome(latest.ReferencePrice, current)
+
+	correct, total := 0, 0
+	var pollIDs []string
+	for _, p := range pending {
+		pollIDs = append(pollIDs, p.PollID)
+		result, err := b.StopPoll(tele.StoredMessage{MessageID: strconv.Itoa(p.MessageID), ChatID: p.ChatID})
+		if err != nil {
+			// The chat may have deleted the poll or blocked the bot; it still counts as resolved
+			log.Printf("[POLL ERROR] Failed to stop poll in %d: %v", p.ChatID, err)
+			continue
+		}
+		if p.Date != latest.Date {
+			continue
+		}
+		total += result.VoterCount
+		if outcome >= 0 && outcome < len(result.Options) {
+			correct += result.Options[outcome].VoterCount
+		}
+	}
+	if _, err := pollCollection.UpdateMany(context.TODO(), bson.M{"resolved": false}, bson.M{"$set": bson.M{"resolved": true}}); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to mark polls resolved: %v", err)
+	}
+	gradePredictions(pollIDs, outcome)
+
+	var sb strings.Builder
+	sb.WriteString("🗳 **KẾT QUẢ DỰ ĐOÁN:** ")
+	switch outcome {
+	case pollOptionUp:
+		fmt.Fprintf(&sb, "Vàng tăng 📈 (`$%.2f` → `$%.2f`)", latest.ReferencePrice, current)
+	case pollOptionDn:
+		fmt.Fprintf(&sb, "Vàng giảm 📉 (`$%.2f` → `$%.2f`)", latest.ReferencePrice, current)
+	default:
+		fmt.Fprintf(&sb, "Vàng đi ngang (`$%.2f`)", current)
+	}
+	if total > 0 && outcome >= 0 {
+		fmt.Fprintf(&sb, "\n🎯 %.0f%% người bình chọn đã đoán đúng (%d/%d).", float64(correct)*100/float64(total), correct, total)
+	}
+	return sb.String()
+}
+
+// pollPreferenceReply handles "/poll on|off"
+func pollPreferenceReply(chatID int64, arg string) string {
+	switch strings.ToLower(strings.TrimSpace(arg)) {
+	case "off":
+		if !setPollOptOut(chatID, true) {
+			return "ℹ️ Bạn cần đăng ký bằng /start trước."
+		}
+		return "🔕 Đã tắt bình chọn dự đoán hàng ngày."
+	case "on":
+		if !setPollOptOut(chatID, false) {
+			return "ℹ️ Bạn cần đăng ký bằng /start trước."
+		}
+		return "🗳 Đã bật bình chọn dự đoán hàng ngày."
+	default:
+		return "ℹ️ Cú pháp: /poll on hoặc /poll off"
+	}
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// alertTypePortfolio fires on the total value of a chat's holdings, in VND
+const alertTypePortfolio = "portfolio"
+
+// maxHoldings bounds a portfolio to what one batch quote can price
+const maxHoldings = 20
+
+// alertOriginTarget marks the price alert that notifies when a holding reaches its target
+const alertOriginTarget = "target"
+
+// Holding is a quantity of one symbol in a user's portfolio, with an optional profit
+// target price (0 = none) and the purchases recorded with /buy (see costbasis.go)
+type Holding struct {
+	Symbol   string  `bson:"symbol"`
+	Quantity float64 `bson:"qty"`
+	Target   float64 `bson:"target,omitempty"`
+	Lots     []Lot   `bson:"lots,omitempty"`
+}
+
+// --- PORTFOLIO ---
+
+// isUsdQuoted reports whether a symbol's price is in USD: pairs against USD, metals and
+// coins, and plain tickers (US equities). Other pairs can't be summed into a USD/VND total.
+func isUsdQuoted(symbol string) bool {
+	return usdAssets[symbol] || !isPair(symbol) || strings.HasSuffix(symbol, "usd")
+}
+
+// getHoldings returns a chat's portfolio
+func getHoldings(chatID int64) []Holding {
+	if userCollection == nil {
+		return nil
+	}
+	var result struct {
+		Holdings []Holding `bson:"holdings"`
+	}
+	userCollection.FindOne(context.TODO(), bson.M{"chat_id": chatID},
+		options.FindOne().SetProjection(bson.M{"holdings": 1})).Decode(&result)
+	return result.Holdings
+}
+
+// loadHoldings returns the portfolios of the given chats
+func loadHoldings(chatIDs []int64) map[int64][]Holding {
+	out := make(map[int64][]Holding)
+	if userCollection == nil || len(chatIDs) == 0 {
+		return out
+	}
+	cursor, err := userCollection.Find(context.TODO(), bson.M{"chat_id": bson.M{"$in": chatIDs}},
+		options.Find().SetProjection(bson.M{"chat_id": 1, "holdings": 1}))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load portfolios: %v", err)
+		return out
+	}
+	defer cursor.Close(context.TODO())
+	for cursor.Next(context.TODO()) {
+		var result struct {
+			ChatID   int64     `bson:"chat_id"`
+			Holdings []Holding `bson:"holdings"`
+		}
+		if cursor.Decode(&result) == nil {
+			out[result.ChatID] = result.Holdings
+		}
+	}
+	return out
+}
+
+// saveHoldings replaces a chat's portfolio; false if the chat isn't subscribed
+func saveHoldings(chatID int64, holdings []Holding) bool {
+	if userCollection == nil {
+		return false
+	}
+	result, err := userCollection.UpdateOne(context.TODO(), bson.M{"chat_id": chatID},
+		bson.M{"$set": bson.M{"holdings": holdings, "updated_at": clock()}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to save portfolio for %d: %v", chatID, err)
+		return false
+	}
+	return result.MatchedCount > 0
+}
+
+// portfolioValueUSD sums the holdings at the given quotes; any missing quote is an error,
+// since a partial total would be misleading
+func portfolioValueUSD(holdings []Holding, quotes map[string]MarketData) (float64, error) {
+	total := 0.0
+	for _, h := range holdings {
+		d, ok := quotes[h.Symbol]
+		if !ok || d.Err != nil || d.Price <= 0 {
+			return 0, fmt.Errorf("no quote for %s", h.Symbol)
+		}
+		total += h.Quantity * d.Price
+	}
+	return total, nil
+}
+
+// holdingSymbols lists the symbols of a portfolio
+func holdingSymbols(holdings []Holding) []string {
+	symbols := make([]string, len(holdings))
+	for i, h := range holdings {
+		symbols[i] = h.Symbol
+	}
+	return symbols
+}
+
+// portfolioReply handles "/portfolio", "/portfolio add SYMBOL QTY" and "/portfolio remove SYMBOL"
+func portfolioReply(chatID int64, payload string) string {
+	usage := "ℹ️ Cú pháp: /portfolio, `/portfolio add btc 0.5` hoặc `/portfolio remove btc`"
+	args := strings.Fields(payload)
+	holdings := getHoldings(chatID)
+	style := getNumberStyle(chatID)
+	if len(args) == 0 {
+		return renderPortfolio(holdings, style)
+	}
+	if len(args) < 2 {
+		return usage
+	}
+	symbol := resolveSymbol(args[1])
+	switch strings.ToLower(args[0]) {
+	case "add":
+		if len(args) != 3 {
+			return usage
+		}
+		qty, err := strconv.ParseFloat(args[2], 64)
+		if err != nil || qty <= 0 {
+			return "⚠️ Số lượng không hợp lệ."
+		}
+		if !isUsdQuoted(symbol) {
+			return "⚠️ Chỉ hỗ trợ mã định giá bằng USD (ví dụ btc, gold, AAPL)."
+		}
+		found := false
+		for i := range holdings {
+			if holdings[i].Symbol == symbol {
+				holdings[i].Quantity += qty
+				found = true
+			}
+		}
+		if !found {
+			if len(holdings) >= maxHoldings {
+				return fmt.Sprintf("⚠️ Danh mục tối đa %d mã.", maxHoldings)
+			}
+			holdings = append(holdings, Holding{Symbol: symbol, Quantity: qty})
+		}
+	case "remove":
+		kept := holdings[:0]
+		for _, h := range holdings {
+			if h.Symbol != symbol {
+				kept = append(kept, h)
+			}
+		}
+		holdings = kept
+		clearTargetAlerts(chatID, symbol)
+	default:
+		return usage
+	}
+	if !saveHoldings(chatID, holdings) {
+		return "ℹ️ Bạn cần đăng ký bằng /start trước."
+	}
+	return renderPortfolio(holdings, style)
+}
+
+// renderPortfolio values a portfolio at current quotes, in the chat's number style
+func renderPortfolio(holdings []Holding, style string) string {
+	if len(holdings) == 0 {
+		return "ℹ️ Danh mục trống. Thêm bằng `/portfolio add btc 0.5`."
+	}
+	apiKey := os.Getenv("TWELVE_DATA_API_KEY")
+	quotes := fetchQuotes(holdingSymbols(holdings))
+	usdVnd, rateErr := getCachedUsdVnd(apiKey)
+
+	var sb strings.Builder
+	sb.WriteString("💼 **DANH MỤC CỦA BẠN**\n")
+	for _, h := range holdings {
+		d := quotes[h.Symbol]
+		if d.Err != nil {
+			fmt.Fprintf(&sb, "• %s × %g: ⚠️ không có dữ liệu\n", h.Symbol, h.Quantity)
+			continue
+		}
+		fmt.Fprintf(&sb, "• %s × %g: `$%s`\n", h.Symbol, h.Quantity, formatNumber(h.Quantity*d.Price, 2, style))
+		if h.Target > 0 {
+			fmt.Fprintf(&sb, "   %s\n", targetProgress(d.Price, h.Target, style))
+		}
+		if len(h.Lots) > 0 {
+			rate := 0.0
+			if rateErr == nil {
+				rate = usdVnd
+			}
+			if cb, err := costBasis(h.Lots); err == nil {
+				fmt.Fprintf(&sb, "   %s\n", profitLine(cb, d.Price, rate, style))
+			}
+		}
+	}
+	total, err := portfolioValueUSD(holdings, quotes)
+	if err != nil {
+		sb.WriteString("_Chưa tính được tổng do thiếu dữ liệu giá._")
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, "**Tổng:** `$%s`", formatNumber(total, 2, style))
+	if rateErr == nil {
+		fmt.Fprintf(&sb, " ≈ **%s VNĐ**", formatNumber(total*usdVnd, 0, style))
+	}
+	return sb.String()
+}
+
+// concentrationLimit is the weight above which /allocation warns about one position
+const concentrationLimit = 50.0
+
+// allocationBar draws a weight as a 10-cell text bar
+func allocationBar(weight float64) string {
+	filled := int(math.Round(weight / 10))
+	if filled > 10 {
+		filled = 10
+	}
+	return strings.Repeat("█", filled) + strings.Repeat("░", 10-filled)
+}
+
+// allocationReply handles "/allocation": each position's share of the portfolio value,
+// heaviest first. Positions without a quote are listed apart and left out of the weights.
+func allocationReply(chatID int64) string {
+	holdings := getHoldings(chatID)
+	if len(holdings) == 0 {
+		return "ℹ️ Danh mục trống. Thêm bằng `/portfolio add btc 0.5`."
+	}
+	quotes := fetchQuotes(holdingSymbols(holdings))
+	type position struct {
+		symbol string
+		value  float64
+	}
+	var priced []position
+	var missing []string
+	total := 0.0
+	for _, h := range holdings {
+		d := quotes[h.Symbol]
+		if d.Err != nil || d.Price <= 0 {
+			missing = append(missing, h.Symbol)
+			continue
+		}
+		priced = append(priced, position{h.Symbol, h.Quantity * d.Price})
+		total += h.Quantity * d.Price
+	}
+	if total <= 0 {
+		return "⚠️ Chưa tính được tỷ trọng do thiếu dữ liệu giá."
+	}
+	sort.Slice(priced, func(i, j int) bool { return priced[i].value > priced[j].value })
+
+	style := getNumberStyle(chatID)
+	var sb strings.Builder
+	sb.WriteString("🥧 **PHÂN BỔ DANH MỤC**\n")
+	var concentrated []string
+	for _, p := range priced {
+		weight := p.value / total * 100
+		fmt.Fprintf(&sb, "• %s: `%s` %s%%\n", p.symbol, allocationBar(weight), formatNumber(weight, 1, style))
+		if weight > concentrationLimit {
+			concentrated = append(concentrated, p.symbol)
+		}
+	}
+	fmt.Fprintf(&sb, "**Tổng:** `$%s`", formatNumber(total, 2, style))
+	if len(concentrated) > 0 {
+		fmt.Fprintf(&sb, "\n⚠️ Tập trung quá mức: %s chiếm hơn %g%% danh mục.", strings.Join(concentrated, ", "), concentrationLimit)
+	}
+	if len(missing) > 0 {
+		fmt.Fprintf(&sb, "\n_Không có dữ liệu giá, chưa tính: %s._", strings.Join(missing, ", "))
+	}
+	return sb.String()
+}
+
+// --- PORTFOLIO ALERTS ---
+
+// stepPortfolioAlert advances a portfolio alert by one valuation. It fires when the value
+// is past the threshold, then disarms until the value comes back, and never fires again
+// within cooldown of the last notification.
+func stepPortfolioAlert(a Alert, valueVnd float64, now time.Time, cooldown time.Duration) (Alert, bool) {
+	crossed := valueVnd >= a.Target
+	if a.Direction == "below" {
+		crossed = valueVnd <= a.Target
+	}
+	if a.Disarmed {
+		if !crossed {
+			a.Disarmed = false
+		}
+		return a, false
+	}
+	if !crossed || (!a.LastFiredAt.IsZero() && now.Sub(a.LastFiredAt) < cooldown) {
+		return a, false
+	}
+	a.Disarmed = true
+	a.LastFiredAt = now
+	return a, true
+}
+
+// evaluatePortfolioAlerts values each alerting chat's portfolio and notifies crossings.
+// A chat whose holdings can't all be quoted, or no USD/VND rate, is skipped this cycle.
+func evaluatePortfolioAlerts(b *tele.Bot, alerts []Alert, threads map[int64]int) (checked, fired int) {
+	if len(alerts) == 0 {
+		return 0, 0
+	}
+	usdVnd, err := getCachedUsdVnd(os.Getenv("TWELVE_DATA_API_KEY"))
+	if err != nil {
+		log.Printf("[ALERT] No USD/VND rate, skipping %d portfolio alerts", len(alerts))
+		return 0, 0
+	}
+	chatIDs := make([]int64, len(alerts))
+	for i, a := range alerts {
+		chatIDs[i] = a.ChatID
+	}
+	portfolios := loadHoldings(chatIDs)
+	var symbols []string
+	for _, h := range portfolios {
+		symbols = append(symbols, holdingSymbols(h)...)
+	}
+	quotes := fetchQuotes(symbols)
+	cooldown := loadConfig().PortfolioAlertCooldown
+
+	for _, a := range alerts {
+		holdings := portfolios[a.ChatID]
+		valueUSD, err := portfolioValueUSD(holdings, quotes)
+		if err != nil || len(holdings) == 0 {
+			log.Printf("[ALERT] Skipping portfolio alert for %d this cycle: %v", a.ChatID, err)
+			continue
+		}
+		checked++
+		valueVnd := valueUSD * usdVnd
+		next, fire := stepPortfolioAlert(a, valueVnd, clock(), cooldown)
+		if next.Disarmed == a.Disarmed && !fire {
+			continue
+		}
+		if !saveRecurringState(a, next) || !fire {
+			continue
+		}
+		msg := fmt.Sprintf("🔔 **CẢNH BÁO DANH MỤC:** %s\n• Giá trị hiện tại: **%s VNĐ**", describeAlert(a), formatVnd(valueVnd))
+		if _, err := deliver(b, a.ChatID, threads[a.ChatID], msg, &tele.SendOptions{ParseMode: tele.ModeMarkdown}); err != nil {
+			log.Printf("[ALERT ERROR] Failed to notify %d: %v", a.ChatID, err)
+			continue
+		}
+		fired++
+	}
+	return checked, fired
+}
+
+// portfolioAlertReply handles "/portfolioalert < 100000000" (or ">"), threshold in VND
+func portfolioAlertReply(chatID int64, payload string) string {
+	args := strings.Fields(payload)
+	if len(args) != 2 || (args[0] != "<" && args[0] != ">") {
+		return "ℹ️ Cú pháp: `/portfolioalert < 100000000` hoặc `/portfolioalert > 500000000` (VNĐ)"
+	}
+	target, err := strconv.ParseFloat(strings.ReplaceAll(args[1], ".", ""), 64)
+	if err != nil || target <= 0 {
+		return "⚠️ Ngưỡng không hợp lệ."
+	}
+	if len(getHoldings(chatID)) == 0 {
+		return "ℹ️ Danh mục trống. Thêm bằng `/portfolio add btc 0.5` trước."
+	}
+	direction := "above"
+	if args[0] == "<" {
+		direction = "below"
+	}
+	return saveAlert(Alert{ChatID: chatID, Type: alertTypePortfolio, Direction: direction, Target: target, CreatedAt: clock()})
+}
+
+// targetProgress renders how far price has come toward a holding's target
+func targetProgress(price, target float64, style string) string {
+	if price >= target {
+		return fmt.Sprintf("🎯 Mục tiêu `$%s`: ✅ đã đạt", formatNumber(target, 2, style))
+	}
+	return fmt.Sprintf("🎯 Mục tiêu `$%s`: đạt %s%%, còn `$%s` (%s)",
+		formatNumber(target, 2, style), formatNumber(price/target*100, 1, style),
+		formatNumber(target-price, 2, style), formatPercent((target/price-1)*100))
+}
+
+// clearTargetAlerts removes the pending target alert of one holding
+func clearTargetAlerts(chatID int64, symbol string) {
+	if alertCollection == nil {
+		return
+	}
+	_, err := alertCollection.DeleteMany(context.TODO(), bson.M{
+		"chat_id": chatID, "symbol": symbol, "origin": alertOriginTarget, "triggered": false,
+	})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to clear target alerts for %d: %v", chatID, err)
+	}
+}
+
+// targetReply handles "/target SYMBOL PRICE" and "/target SYMBOL off". The target is
+// stored on the holding, and a one-shot price alert notifies when it is reached; a
+// target the price is already past is recorded as reached, without an alert.
+func targetReply(chatID int64, payload string) string {
+	usage := "ℹ️ Cú pháp: `/target btc 75000` hoặc `/target btc off`"
+	args := strings.Fields(payload)
+	if len(args) != 2 {
+		return usage
+	}
+	symbol := resolveSymbol(args[0])
+	holdings := getHoldings(chatID)
+	idx := -1
+	for i, h := range holdings {
+		if h.Symbol == symbol {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return fmt.Sprintf("ℹ️ %s chưa có trong danh mục. Thêm bằng `/portfolio add %s <số lượng>`.", symbol, symbol)
+	}
+
+	if strings.ToLower(args[1]) == "off" {
+		holdings[idx].Target = 0
+		if !saveHoldings(chatID, holdings) {
+			return "⚠️ Không thể lưu lúc này."
+		}
+		clearTargetAlerts(chatID, symbol)
+		return fmt.Sprintf("🗑 Đã bỏ mục tiêu của %s.", symbol)
+	}
+	target, err := strconv.ParseFloat(strings.ReplaceAll(args[1], ",", ""), 64)
+	if err != nil || target <= 0 {
+		return "⚠️ Mức giá không hợp lệ."
+	}
+	holdings[idx].Target = target
+	if !saveHoldings(chatID, holdings) {
+		return "⚠️ Không thể lưu lúc này."
+	}
+	clearTargetAlerts(chatID, symbol)
+
+	style := getNumberStyle(chatID)
+	d := getMarketData(symbol, os.Getenv("TWELVE_DATA_API_KEY"))
+	if d.Err != nil || d.Price <= 0 {
+		return fmt.Sprintf("✅ Đã đặt mục tiêu `$%s` cho %s. Chưa lấy được giá hiện tại nên chưa tạo thông báo.",
+			formatNumber(target, 2, style), symbol)
+	}
+	reply := fmt.Sprintf("✅ Đã đặt mục tiêu cho %s.\n%s", symbol, targetProgress(d.Price, target, style))
+	if d.Price >= target {
+		return reply + "\n_Giá hiện tại đã vượt mục tiêu nên sẽ không có thông báo._"
+	}
+	alert := Alert{ChatID: chatID, Symbol: symbol, Type: alertTypePrice, Direction: "above", Target: target,
+		Origin: alertOriginTarget, CreatedAt: clock()}
+	return reply + "\n" + saveAlert(alert)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	tele "gopkg.in/telebot.v3"
+)
+
+// --- BROADCAST PREVIEW ---
+
+// previewReply handles the admin "/preview <chat_id>": that chat's copy of the broadcast,
+// rendered by the broadcast planner itself, is sent here under a preview label. Nothing is
+// recorded for the chat (no delivery stamp, portfolio snapshot or pin) and the keyboard is
+// left off so a tap can't act on the admin chat.
+func previewReply(r *Request) error {
+	usage := "ℹ️ Cú pháp: /preview <chat_id>"
+	if len(r.Args) != 1 {
+		return r.Reply(usage)
+	}
+	chatID, err := strconv.ParseInt(r.Args[0], 10, 64)
+	if err != nil {
+		return r.Reply(usage)
+	}
+	if userCollection == nil {
+		return r.Reply("⚠️ Không có kết nối cơ sở dữ liệu.")
+	}
+	var u User
+	if err := userCollection.FindOne(context.TODO(), bson.M{"chat_id": chatID}).Decode(&u); err != nil {
+		return r.Reply(fmt.Sprintf("⚠️ Không tìm thấy chat %d trong danh sách đăng ký.", chatID))
+	}
+
+	report := buildMarketReport()
+	aud := loadBroadcastAudience()
+	botUsername := ""
+	if r.Bot.Me != nil {
+		botUsername = r.Bot.Me.Username
+	}
+	plan := planBroadcastFor(report, []int64{chatID}, aud, botUsername, false)
+
+	var traits []string
+	switch {
+	case aud.Channels[chatID]:
+		traits = append(traits, "kênh")
+	case aud.Split[chatID]:
+		traits = append(traits, "tin tách riêng")
+	case aud.Cards[chatID]:
+		traits = append(traits, "tin dạng thẻ")
+	default:
+		traits = append(traits, "tin dạng danh sách")
+	}
+	if aud.Plain[chatID] {
+		traits = append(traits, "không định dạng")
+	}
+	if exp := loadConfig().Experiment; exp.active() {
+		traits = append(traits, "nhóm "+assignVariant(exp, chatID))
+	}
+	if _, err := r.Bot.Send(r.Message.Chat, fmt.Sprintf("👁 XEM TRƯỚC bản tin của chat %d (%s). Tin dưới đây chỉ gửi cho bạn.",
+		chatID, strings.Join(traits, ", "))); err != nil {
+		return err
+	}
+
+	opts := &tele.SendOptions{ParseMode: tele.ModeMarkdown, DisableWebPagePreview: true}
+	if aud.Plain[chatID] {
+		opts.ParseMode = tele.ModeDefault
+	}
+	msg, err := r.Bot.Send(r.Message.Chat, plan.textFor(chatID), opts)
+	if err != nil {
+		return err
+	}
+	switch {
+	case aud.Channels[chatID] || len(report.Headlines) == 0:
+	case aud.Split[chatID]:
+		_, err = r.Bot.Send(r.Message.Chat, splitNewsText(report.Headlines, aud.Plain[chatID]),
+			&tele.SendOptions{ParseMode: opts.ParseMode, DisableWebPagePreview: true, ReplyTo: msg})
+	case aud.Cards[chatID] && !aud.Plain[chatID]:
+		_, err = r.Bot.Send(r.Message.Chat, fmt.Sprintf("ℹ️ Chat này nhận thêm %d tin dạng thẻ sau bản tin.", len(report.Headlines)))
+	}
+	return err
+}
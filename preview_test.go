@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+func previewTestReport() MarketReport {
+	return MarketReport{
+		Text:      "📊 **BÁO CÁO**\n\n₿ Bitcoin: `$60000.00`\n\n" + reportFooter + " list",
+		CardsText: "📊 **BÁO CÁO**\n\n₿ Bitcoin: `$60000.00`\n\n" + reportFooter + " cards",
+		Plain:     "BÁO CÁO\n\nBitcoin: $60000.00",
+		Quotes:    map[string]MarketData{"btc": {Price: 60000, Change: "+1.00%", Percent: 1, HasPercent: true}},
+		Headlines: []Headline{{Title: "Fed giữ nguyên lãi suất", Link: "https://example.com/fed"}},
+		At:        time.Date(2026, 3, 2, 8, 0, 0, 0, vnLocation),
+	}
+}
+
+// TestPreviewMatchesBroadcastPlan checks that planning one chat alone, as /preview does,
+// gives it the same text it gets in the full broadcast
+func TestPreviewMatchesBroadcastPlan(t *testing.T) {
+	withDatabase(t, false, nil)
+	report := previewTestReport()
+	aud := broadcastAudience{
+		Cards:      map[int64]bool{2: true},
+		Split:      map[int64]bool{3: true},
+		Plain:      map[int64]bool{4: true},
+		Channels:   map[int64]bool{-1005: true},
+		Thresholds: map[int64]float64{6: 0.5},
+	}
+	ids := []int64{1, 2, 3, 4, -1005, 6}
+	full := planBroadcastFor(report, ids, aud, "market_bot", false)
+	for _, id := range ids {
+		preview := planBroadcastFor(report, []int64{id}, aud, "market_bot", false)
+		if got, want := preview.textFor(id), full.textFor(id); got != want || got == "" {
+			t.Errorf("chat %d preview = %q, broadcast = %q", id, got, want)
+		}
+	}
+	if full.textFor(2) == full.textFor(1) {
+		t.Error("card and list chats got the same rendering")
+	}
+	if strings.Contains(full.textFor(4), "**") {
+		t.Errorf("plain-text chat got Markdown: %q", full.textFor(4))
+	}
+}
+
+func TestSplitNewsText(t *testing.T) {
+	headlines := previewTestReport().Headlines
+	md := splitNewsText(headlines, false)
+	if !strings.HasPrefix(md, "🔴 **TIN TỨC QUAN TRỌNG:**") || !strings.Contains(md, "(https://example.com/fed)") {
+		t.Errorf("Markdown news half = %q", md)
+	}
+	if strings.HasSuffix(md, "\n") {
+		t.Error("news half keeps trailing blank lines")
+	}
+	if plain := splitNewsText(headlines, true); strings.Contains(plain, "**") || !strings.Contains(plain, "Fed giữ nguyên lãi suất") {
+		t.Errorf("plain news half = %q", plain)
+	}
+}
+
+func TestPreviewReplyArguments(t *testing.T) {
+	withDatabase(t, false, nil)
+	for _, args := range [][]string{nil, {"abc"}, {"1", "2"}} {
+		api := &fakeBotAPI{}
+		r := &Request{Bot: newFakeBot(t, api), Message: &tele.Message{Chat: &tele.Chat{ID: 99}}, Args: args}
+		if err := previewReply(r); err != nil {
+			t.Fatal(err)
+		}
+		if len(api.calls) != 1 || !strings.HasPrefix(api.calls[0].Text, "ℹ️ Cú pháp: /preview") {
+			t.Errorf("/preview %v sent %+v, want the usage", args, api.calls)
+		}
+	}
+	api := &fakeBotAPI{}
+	r := &Request{Bot: newFakeBot(t, api), Message: &tele.Message{Chat: &tele.Chat{ID: 99}}, Args: []string{"42"}}
+	if err := previewReply(r); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.calls) != 1 || !strings.Contains(api.calls[0].Text, "cơ sở dữ liệu") {
+		t.Errorf("/preview without a database sent %+v", api.calls)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"log"
+	"os"
+)
+
+// Profile lets one binary serve differently branded bots. Each deployment picks its
+// profile with BOT_PROFILE and brings its own TELEGRAM_TOKEN; subscribers and the live
+// config document are kept apart per profile.
+type Profile struct {
+	Name     string
+	Title    string
+	ConfigID string
+	Symbols  []string
+	FeedURL  string
+}
+
+// profiles are the built-in variants; "markets" is the default general bot
+var profiles = map[string]Profile{
+	"markets": {
+		Name:     "markets",
+		Title:    "💰 **NHỊP ĐẬP THỊ TRƯỜNG**",
+		ConfigID: "config",
+		Symbols:  []string{"gold", "eurusd", "btc"},
+		FeedURL:  "https://www.investing.com/rss/news_25.rss",
+	},
+	"crypto": {
+		Name:     "crypto",
+		Title:    "₿ **NHỊP ĐẬP CRYPTO**",
+		ConfigID: "config_crypto",
+		Symbols:  []string{"btc", "eth", "sol"},
+		FeedURL:  "https://www.investing.com/rss/news_301.rss",
+	},
+}
+
+// collectionName scopes a per-bot collection to the profile: the default profile keeps
+// the bare name ("users"), others get a suffix ("users_crypto")
+func (p Profile) collectionName(base string) string {
+	if p.Name == "markets" {
+		return base
+	}
+	return base + "_" + p.Name
+}
+
+// activeProfile returns the profile named by BOT_PROFILE, falling back to "markets"
+func activeProfile() Profile {
+	name := os.Getenv("BOT_PROFILE")
+	if name == "" {
+		return profiles["markets"]
+	}
+	p, ok := profiles[name]
+	if !ok {
+		log.Printf("[CONFIG] Unknown BOT_PROFILE %q, using markets", name)
+		return profiles["markets"]
+	}
+	return p
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// defaultQuarantineDays is how many consecutive days a watched symbol may fail to quote
+// before it is quarantined; SYMBOL_QUARANTINE_DAYS overrides it
+const defaultQuarantineDays = 3
+
+// quarantineRetryInterval is how often a quarantined symbol is quoted again to see if
+// it came back
+const quarantineRetryInterval = 7 * 24 * time.Hour
+
+// errSymbolQuarantined marks watched symbols that were not quoted because they are quarantined
+var errSymbolQuarantined = errors.New("symbol quarantined")
+
+// symbolHealthCollection counts failures per symbol, shared by every profile and chat so a
+// dead symbol is probed once however many users watch it
+var symbolHealthCollection *mongo.Collection
+
+// Symbol health storage; tests swap these for in-memory fakes
+var (
+	quarantinedSymbols = loadQuarantined
+	symbolSucceeded    = recordSymbolSuccess
+	symbolFailed       = recordSymbolFailure
+)
+
+// symbolHealth is one watched symbol's failure record
+type symbolHealth struct {
+	Symbol         string    `bson:"_id"`
+	Failures       int       `bson:"failures"`
+	LastFailureDay string    `bson:"last_failure_day"`
+	Quarantined    bool      `bson:"quarantined"`
+	QuarantinedAt  time.Time `bson:"quarantined_at,omitempty"`
+	RetryAt        time.Time `bson:"retry_at,omitempty"`
+}
+
+// --- SYMBOL QUARANTINE ---
+
+// loadQuarantined returns the quarantined symbols by symbol
+func loadQuarantined() map[string]symbolHealth {
+	held := make(map[string]symbolHealth)
+	if symbolHealthCollection == nil {
+		return held
+	}
+	cursor, err := symbolHealthCollection.Find(context.TODO(), bson.M{"quarantined": true})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load quarantined symbols: %v", err)
+		return held
+	}
+	var docs []symbolHealth
+	if err := cursor.All(context.TODO(), &docs); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to decode quarantined symbols: %v", err)
+		return held
+	}
+	for _, d := range docs {
+		held[d.Symbol] = d
+	}
+	return held
+}
+
+// withoutQuarantined splits symbols into those to quote and placeholder quotes for the
+// quarantined ones. With retry, quarantined symbols whose weekly retry is due are quoted.
+func withoutQuarantined(symbols []string, retry bool) ([]string, map[string]MarketData) {
+	held := quarantinedSymbols()
+	placeholders := make(map[string]MarketData)
+	var quote []string
+	now := clock()
+	for _, s := range symbols {
+		if h, ok := held[s]; ok && !(retry && !now.Before(h.RetryAt)) {
+			placeholders[s] = MarketData{Change: "N/A", Err: errSymbolQuarantined}
+			continue
+		}
+		quote = append(quote, s)
+	}
+	return quote, placeholders
+}
+
+// trackWatchedSymbols records how each watched symbol quoted in a broadcast and tells its
+// watchers when it is quarantined or comes back. Failures are only counted when the provider
+// answered for something else, so an outage or a spent quota doesn't quarantine everything.
+func trackWatchedSymbols(b *tele.Bot, quotes map[string]MarketData, watchlists map[int64][]string) {
+	providerUp := false
+	for _, d := range quotes {
+		if d.Err == nil {
+			providerUp = true
+			break
+		}
+	}
+	watchers := make(map[string][]int64)
+	for id, list := range watchlists {
+		for _, s := range list {
+			if !isRegistrySymbol(s) {
+				watchers[s] = append(watchers[s], id)
+			}
+		}
+	}
+	for s, ids := range watchers {
+		d, ok := quotes[s]
+		switch {
+		case !ok || errors.Is(d.Err, errSymbolQuarantined):
+		case d.Err == nil:
+			if symbolSucceeded(s) {
+				log.Printf("[QUARANTINE] %s quotes again, released", s)
+				notifyWatchers(b, ids, fmt.Sprintf("✅ %s đã có dữ liệu trở lại và được hiển thị như trước trong danh sách theo dõi.", s))
+			}
+		case providerUp && !errors.Is(d.Err, errRateLimited):
+			if symbolFailed(s) {
+				log.Printf("[QUARANTINE] %s quarantined after %d failed days", s, quarantineDays())
+				notifyWatchers(b, ids, fmt.Sprintf("⚠️ %s không còn dữ liệu, gõ /watch remove %s hoặc /watch add <mã khác>. Bot sẽ thử lại mỗi tuần và báo khi mã có dữ liệu trở lại.", s, s))
+			}
+		}
+	}
+}
+
+// quarantineDays is the configured number of failed days before quarantine
+func quarantineDays() int {
+	return envInt("SYMBOL_QUARANTINE_DAYS", defaultQuarantineDays)
+}
+
+// recordSymbolSuccess resets a symbol's failure count; true when it was quarantined and
+// this call released it (so only one container notifies)
+func recordSymbolSuccess(s string) bool {
+	if symbolHealthCollection == nil {
+		return false
+	}
+	var before symbolHealth
+	err := symbolHealthCollection.FindOneAndUpdate(context.TODO(),
+		bson.M{"_id": s, "$or": bson.A{bson.M{"failures": bson.M{"$gt": 0}}, bson.M{"quarantined": true}}},
+		bson.M{"$set": bson.M{"failures": 0, "quarantined": false}, "$unset": bson.M{"retry_at": "", "quarantined_at": ""}},
+	).Decode(&before)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Printf("[DATABASE ERROR] Failed to reset failures for %s: %v", s, err)
+		}
+		return false
+	}
+	return before.Quarantined
+}
+
+// recordSymbolFailure counts a failed day for s (at most one per Vietnam day) and
+// quarantines it on reaching quarantineDays; true when this call quarantined it. A failed
+// weekly retry just schedules the next one.
+func recordSymbolFailure(s string) bool {
+	if symbolHealthCollection == nil {
+		return false
+	}
+	now := clock()
+	retried, err := symbolHealthCollection.UpdateOne(context.TODO(), bson.M{"_id": s, "quarantined": true},
+		bson.M{"$set": bson.M{"retry_at": now.Add(quarantineRetryInterval)}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to reschedule retry for %s: %v", s, err)
+		return false
+	}
+	if retried.MatchedCount > 0 {
+		return false
+	}
+	today := now.In(vnLocation).Format("2006-01-02")
+	var after symbolHealth
+	// A document already counted today fails the filter, and the upsert's duplicate key
+	// means there is nothing to add
+	err = symbolHealthCollection.FindOneAndUpdate(context.TODO(),
+		bson.M{"_id": s, "last_failure_day": bson.M{"$ne": today}},
+		bson.M{"$inc": bson.M{"failures": 1}, "$set": bson.M{"last_failure_day": today}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&after)
+	if mongo.IsDuplicateKeyError(err) {
+		return false
+	}
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to count failure for %s: %v", s, err)
+		return false
+	}
+	if after.Failures < quarantineDays() {
+		return false
+	}
+	result, err := symbolHealthCollection.UpdateOne(context.TODO(), bson.M{"_id": s, "quarantined": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{"quarantined": true, "quarantined_at": now, "retry_at": now.Add(quarantineRetryInterval)}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to quarantine %s: %v", s, err)
+		return false
+	}
+	return result.ModifiedCount > 0
+}
+
+// notifyWatchers sends a symbol notice to each watching chat
+func notifyWatchers(b *tele.Bot, ids []int64, text string) {
+	if b == nil {
+		return
+	}
+	for _, id := range ids {
+		if _, err := deliver(b, id, getThreadID(id), text, nil); err != nil {
+			log.Printf("[QUARANTINE] Failed to notify %d: %v", id, err)
+		}
+	}
+}
+
+// statusReply handles "/status" (viewers and up): database state and the quarantined symbols
+// with how many chats still watch each
+func statusReply() string {
+	var sb strings.Builder
+	sb.WriteString("🩺 **TRẠNG THÁI**\n")
+	if !databaseAvailable() {
+		fmt.Fprintf(&sb, "• Cơ sở dữ liệu: ⚠️ không khả dụng (%v)", databaseErr)
+		return sb.String()
+	}
+	sb.WriteString("• Cơ sở dữ liệu: ✅ hoạt động\n")
+	held := quarantinedSymbols()
+	if len(held) == 0 {
+		sb.WriteString("• Mã bị tạm ngưng: không có")
+		return sb.String()
+	}
+	watching := make(map[string]int)
+	for _, list := range loadWatchlists() {
+		for _, s := range list {
+			watching[s]++
+		}
+	}
+	symbols := make([]string, 0, len(held))
+	for s := range held {
+		symbols = append(symbols, s)
+	}
+	sort.Strings(symbols)
+	fmt.Fprintf(&sb, "• Mã bị tạm ngưng (%d):", len(held))
+	for _, s := range symbols {
+		h := held[s]
+		fmt.Fprintf(&sb, "\n  – %s: %d chat theo dõi, từ %s, thử lại %s", s, watching[s],
+			h.QuarantinedAt.In(vnLocation).Format("02/01"), h.RetryAt.In(vnLocation).Format("02/01 15:04"))
+	}
+	return sb.String()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSymbolHealth stands in for the symbol health collection: quarantined is what
+// quarantinedSymbols returns, and the record calls are logged and answered from release
+// and quarantine
+type fakeSymbolHealth struct {
+	quarantined         map[string]symbolHealth
+	successes, failures []string
+	release, quarantine map[string]bool
+}
+
+func withFakeSymbolHealth(t *testing.T) *fakeSymbolHealth {
+	t.Helper()
+	f := &fakeSymbolHealth{quarantined: map[string]symbolHealth{}, release: map[string]bool{}, quarantine: map[string]bool{}}
+	savedHeld, savedOK, savedFail := quarantinedSymbols, symbolSucceeded, symbolFailed
+	quarantinedSymbols = func() map[string]symbolHealth { return f.quarantined }
+	symbolSucceeded = func(s string) bool {
+		f.successes = append(f.successes, s)
+		return f.release[s]
+	}
+	symbolFailed = func(s string) bool {
+		f.failures = append(f.failures, s)
+		return f.quarantine[s]
+	}
+	t.Cleanup(func() { quarantinedSymbols, symbolSucceeded, symbolFailed = savedHeld, savedOK, savedFail })
+	return f
+}
+
+func TestWithoutQuarantined(t *testing.T) {
+	f := withFakeSymbolHealth(t)
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, vnLocation)
+	savedClock := clock
+	clock = func() time.Time { return now }
+	t.Cleanup(func() { clock = savedClock })
+	f.quarantined["dead"] = symbolHealth{Symbol: "dead", Quarantined: true, RetryAt: now.Add(24 * time.Hour)}
+	f.quarantined["due"] = symbolHealth{Symbol: "due", Quarantined: true, RetryAt: now}
+
+	quote, held := withoutQuarantined([]string{"aapl", "dead", "due"}, false)
+	if !reflect.DeepEqual(quote, []string{"aapl"}) || len(held) != 2 || !errors.Is(held["due"].Err, errSymbolQuarantined) {
+		t.Errorf("without retry: quote %v, held %v", quote, held)
+	}
+	quote, held = withoutQuarantined([]string{"aapl", "dead", "due"}, true)
+	if !reflect.DeepEqual(quote, []string{"aapl", "due"}) || len(held) != 1 || !errors.Is(held["dead"].Err, errSymbolQuarantined) {
+		t.Errorf("with the weekly retry due: quote %v, held %v", quote, held)
+	}
+}
+
+func TestTrackWatchedSymbols(t *testing.T) {
+	watchlists := map[int64][]string{1: {"btc", "aapl", "dead", "limited"}, 2: {"aapl", "gone", "back"}}
+	quotes := map[string]MarketData{
+		"btc":     {Price: 60000},
+		"aapl":    {Price: 190},
+		"back":    {Price: 5},
+		"dead":    {Err: errSymbolQuarantined},
+		"limited": {Err: errRateLimited},
+		"gone":    {Err: errUnknownSymbol},
+	}
+
+	t.Run("counts successes and real failures only", func(t *testing.T) {
+		f := withFakeSymbolHealth(t)
+		trackWatchedSymbols(nil, quotes, watchlists)
+		sort.Strings(f.successes)
+		if !reflect.DeepEqual(f.successes, []string{"aapl", "back"}) {
+			t.Errorf("successes = %v; registry symbols and unquoted ones must be skipped", f.successes)
+		}
+		if !reflect.DeepEqual(f.failures, []string{"gone"}) {
+			t.Errorf("failures = %v; quarantined and rate-limited quotes don't count", f.failures)
+		}
+	})
+
+	t.Run("a provider outage counts nothing", func(t *testing.T) {
+		f := withFakeSymbolHealth(t)
+		down := map[string]MarketData{"aapl": {Err: errors.New("timeout")}, "gone": {Err: errors.New("timeout")}}
+		trackWatchedSymbols(nil, down, watchlists)
+		if len(f.failures) != 0 {
+			t.Errorf("failures during an outage = %v", f.failures)
+		}
+	})
+
+	t.Run("watchers hear about quarantine and release", func(t *testing.T) {
+		withDatabase(t, false, nil)
+		f := withFakeSymbolHealth(t)
+		f.quarantine["gone"], f.release["back"] = true, true
+		api := &fakeBotAPI{}
+		trackWatchedSymbols(newFakeBot(t, api), quotes, watchlists)
+		var texts []string
+		for _, c := range api.calls {
+			texts = append(texts, c.Text)
+		}
+		if len(texts) != 2 || !strings.Contains(strings.Join(texts, "\n"), "⚠️ gone không còn dữ liệu") ||
+			!strings.Contains(strings.Join(texts, "\n"), "✅ back đã có dữ liệu trở lại") {
+			t.Errorf("notices = %q, want one quarantine and one release notice to chat 2", texts)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxRawRunes keeps /raw, escapes included, well inside Telegram's 4096-character message
+// limit, leaving room for the code fence
+const maxRawRunes = 3000
+
+// --- RAW PROVIDER OUTPUT (ADMIN) ---
+
+// truncateRunes cuts s to at most n runes, marking the cut with an ellipsis
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:n]) + "\n…"
+}
+
+// rawCodeBlock wraps text in a MarkdownV2 pre block. Inside pre only "\" and "`" need
+// escaping. The limit counts escaped runes, so text full of backslashes or backticks still
+// fits in one message, and an escape is never split by the cut.
+func rawCodeBlock(text string) string {
+	var b strings.Builder
+	n := 0
+	for _, c := range text {
+		w := 1
+		if c == '\\' || c == '`' {
+			w = 2
+		}
+		if n+w > maxRawRunes {
+			b.WriteString("\n…")
+			break
+		}
+		if w == 2 {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+		n += w
+	}
+	return "```json\n" + b.String() + "\n```"
+}
+
+// rawReply handles the admin "/raw SYMBOL": the provider's /quote response as received,
+// pretty-printed when it is valid JSON. The result is meant for ModeMarkdownV2.
+func rawReply(ctx context.Context, payload string) string {
+	symbol := resolveSymbol(payload)
+	if symbol == "" {
+		return "ℹ️ Cú pháp: /raw btc"
+	}
+	apiUrl := twelveDataURL("quote", url.Values{"symbol": {twelveDataSymbol(symbol)}, "apikey": {os.Getenv("TWELVE_DATA_API_KEY")}})
+	countAPICall("quote")
+	body, err := fetchBody(ctx, apiUrl, 15*time.Second, jsonContentTypes)
+	if err != nil {
+		return rawCodeBlock("error: " + err.Error())
+	}
+	var pretty bytes.Buffer
+	if json.Indent(&pretty, body, "", "  ") == nil {
+		body = pretty.Bytes()
+	}
+	return rawCodeBlock(string(body))
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateRunes(t *testing.T) {
+	if got := truncateRunes("giá vàng", 8); got != "giá vàng" {
+		t.Errorf("a string at the limit was cut: %q", got)
+	}
+	// Counted in runes, so a multi-byte character is never split
+	if got := truncateRunes("giá vàng", 3); got != "giá\n…" {
+		t.Errorf("truncateRunes = %q, want %q", got, "giá\n…")
+	}
+}
+
+func TestRawCodeBlock(t *testing.T) {
+	got := rawCodeBlock("{\"note\": \"a `tick` and C:\\\\path\"}")
+	want := "```json\n{\"note\": \"a \\`tick\\` and C:\\\\\\\\path\"}\n```"
+	if got != want {
+		t.Errorf("rawCodeBlock = %q, want %q", got, want)
+	}
+
+	// Escapes count toward the limit, and a cut never leaves a dangling backslash
+	long := strings.Repeat("`", maxRawRunes)
+	got = rawCodeBlock(long)
+	body := strings.TrimSuffix(strings.TrimPrefix(got, "```json\n"), "\n```")
+	if n := strings.Count(body, "\\`"); n != maxRawRunes/2 || strings.Count(body, "`") != n {
+		t.Errorf("kept %d escaped backticks, want %d", n, maxRawRunes/2)
+	}
+	if !strings.HasSuffix(body, "\n…") || utf8.RuneCountInString(got) > 4096 {
+		t.Errorf("the truncated block is %d runes and ends %q", utf8.RuneCountInString(got), got[len(got)-12:])
+	}
+}
+
+func TestRawReply(t *testing.T) {
+	f := withFakeHTTP(t)
+	if got := rawReply(context.Background(), ""); got != "ℹ️ Cú pháp: /raw btc" {
+		t.Errorf("no symbol = %q", got)
+	}
+
+	f.set(twelveDataHost, jsonResponse(`{"symbol":"BTC/USD","close":"64000.5"}`))
+	got := rawReply(context.Background(), "btc")
+	if want := "```json\n{\n  \"symbol\": \"BTC/USD\",\n  \"close\": \"64000.5\"\n}\n```"; got != want {
+		t.Errorf("rawReply = %q, want the response pretty-printed in a block", got)
+	}
+
+	f.set(twelveDataHost, fakeResponse{Err: errors.New("connection reset")})
+	if got := rawReply(context.Background(), "btc"); !strings.HasPrefix(got, "```json\nerror: ") {
+		t.Errorf("a failed fetch = %q, want the error in the block", got)
+	}
+}
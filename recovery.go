@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	tele "gopkg.in/telebot.v3"
+)
+
+// Admin alerts are throttled so a poison update can't flood the admin chat
+var (
+	adminAlertMu       sync.Mutex
+	lastAdminAlert     time.Time
+	adminAlertCooldown = 10 * time.Minute
+)
+
+// --- ADMIN NOTIFICATIONS ---
+
+// adminChatID returns the chat configured in ADMIN_CHAT_ID, or 0 when unset
+func adminChatID() int64 {
+	id, _ := strconv.ParseInt(os.Getenv("ADMIN_CHAT_ID"), 10, 64)
+	return id
+}
+
+// notifyAdmin sends a plain-text alarm to the admin chat, at most once per cooldown
+func notifyAdmin(b *tele.Bot, text string) {
+	admin := adminChatID()
+	if b == nil || admin == 0 {
+		return
+	}
+	adminAlertMu.Lock()
+	if clock().Sub(lastAdminAlert) < adminAlertCooldown {
+		adminAlertMu.Unlock()
+		log.Println("[ADMIN] Alert throttled")
+		return
+	}
+	lastAdminAlert = clock()
+	adminAlertMu.Unlock()
+
+	sendAdmin(b, text)
+}
+
+// sendAdmin delivers an unthrottled plain-text message to the admin chat (reports, summaries)
+func sendAdmin(b *tele.Bot, text string) {
+	admin := adminChatID()
+	if b == nil || admin == 0 {
+		return
+	}
+	if _, err := b.Send(&tele.Chat{ID: admin}, text); err != nil {
+		log.Printf("[ADMIN ERROR] Failed to notify admin: %v", err)
+	}
+}
+
+// --- PANIC RECOVERY ---
+
+// describeUpdate summarizes an update for panic logs without dumping the whole payload
+func describeUpdate(u *tele.Update) string {
+	switch {
+	case u == nil:
+		return "no update"
+	case u.Callback != nil:
+		return fmt.Sprintf("update %d callback %q from %d", u.ID, u.Callback.Data, u.Callback.Sender.ID)
+	case u.Message != nil:
+		return fmt.Sprintf("update %d message %q in chat %d", u.ID, u.Message.Text, u.Message.Chat.ID)
+	default:
+		return fmt.Sprintf("update %d", u.ID)
+	}
+}
+
+// reportPanic logs a recovered panic with its stack trace, alerts the admin, and
+// answers any pending callback so the user's client stops spinning
+func reportPanic(b *tele.Bot, u *tele.Update, r interface{}) {
+	where := describeUpdate(u)
+	log.Printf("[PANIC] %v (%s)\n%s", r, where, debug.Stack())
+	notifyAdmin(b, fmt.Sprintf("🚨 Bot panic: %v\n%s", r, where))
+
+	if b != nil && u != nil && u.Callback != nil {
+		b.Respond(u.Callback, &tele.CallbackResponse{Text: "⚠️ Đã xảy ra lỗi, vui lòng thử lại sau."})
+	}
+}
+
+// recoverLambda is deferred on the first line of Handler. It turns a panic into a 200
+// response so Telegram doesn't redeliver the poison update forever. request is read at
+// panic time, so it sees the body after webhook decoding.
+func recoverLambda(request *events.LambdaFunctionURLRequest, resp *events.LambdaFunctionURLResponse, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	var update *tele.Update
+	if request.Body != "" {
+		var u tele.Update
+		if json.Unmarshal([]byte(request.Body), &u) == nil {
+			update = &u
+		}
+	}
+	// Offline bot: no getMe round trip, we only need the token to call the API
+	b, _ := newBot(tele.Settings{Token: os.Getenv("TELEGRAM_TOKEN"), Synchronous: true, Offline: true})
+	reportPanic(b, update, r)
+	captureFailedRequest(*request, r)
+
+	*resp = events.LambdaFunctionURLResponse{StatusCode: 200, Body: "Recovered"}
+	*err = nil
+}
+
+// recoverMiddleware is the local-mode equivalent of recoverLambda for telebot handlers
+func recoverMiddleware(b *tele.Bot) tele.MiddlewareFunc {
+	return func(next tele.HandlerFunc) tele.HandlerFunc {
+		return func(c tele.Context) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					u := c.Update()
+					reportPanic(b, &u, r)
+					err = nil
+				}
+			}()
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	tele "gopkg.in/telebot.v3"
+)
+
+// withBotTransport routes the bots newBot builds to api for the rest of the test, with the
+// admin alert throttle reset
+func withBotTransport(t *testing.T, api *fakeBotAPI) {
+	t.Helper()
+	saved := http.DefaultTransport
+	http.DefaultTransport = api
+	adminAlertMu.Lock()
+	savedAlert := lastAdminAlert
+	lastAdminAlert = time.Time{}
+	adminAlertMu.Unlock()
+	t.Cleanup(func() {
+		http.DefaultTransport = saved
+		adminAlertMu.Lock()
+		lastAdminAlert = savedAlert
+		adminAlertMu.Unlock()
+	})
+	t.Setenv("ADMIN_CHAT_ID", "99")
+	t.Setenv("TELEGRAM_TOKEN", "test")
+}
+
+// methods lists the Bot API methods api was called with
+func (f *fakeBotAPI) methods() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []string
+	for _, c := range f.calls {
+		out = append(out, c.Method)
+	}
+	return out
+}
+
+func panickingHandler(request events.LambdaFunctionURLRequest) (resp events.LambdaFunctionURLResponse, err error) {
+	defer recoverLambda(&request, &resp, &err)
+	panic("boom")
+}
+
+func TestRecoverLambda(t *testing.T) {
+	api := &fakeBotAPI{}
+	withBotTransport(t, api)
+	request := events.LambdaFunctionURLRequest{Body: `{"update_id":5,"callback_query":{"id":"cb1","from":{"id":7},"data":"x"}}`}
+
+	resp, err := panickingHandler(request)
+	if err != nil || resp.StatusCode != http.StatusOK || resp.Body != "Recovered" {
+		t.Fatalf("recovered response = %+v, %v; want 200 so Telegram doesn't redeliver", resp, err)
+	}
+	if got := strings.Join(api.methods(), ","); got != "sendMessage,answerCallbackQuery" {
+		t.Fatalf("Bot API calls = %s, want the admin alert and the callback answer", got)
+	}
+	if !strings.Contains(api.calls[0].Text, "🚨 Bot panic: boom") || !strings.Contains(api.calls[0].Text, `callback "x" from 7`) {
+		t.Errorf("admin alert = %q", api.calls[0].Text)
+	}
+
+	// A second panic inside the cooldown still answers the callback but doesn't alert again
+	panickingHandler(request)
+	if got := strings.Join(api.methods(), ","); got != "sendMessage,answerCallbackQuery,answerCallbackQuery" {
+		t.Errorf("Bot API calls after a throttled panic = %s", got)
+	}
+}
+
+// The recovery defer is installed before the webhook body is decoded, and still reads the
+// decoded body when describing the update
+func TestRecoverLambdaAfterDecoding(t *testing.T) {
+	api := &fakeBotAPI{}
+	withBotTransport(t, api)
+	body := `{"update_id":6,"callback_query":{"id":"cb2","from":{"id":8},"data":"y"}}`
+	request := events.LambdaFunctionURLRequest{Body: base64.StdEncoding.EncodeToString([]byte(body)), IsBase64Encoded: true}
+	resp, err := func() (resp events.LambdaFunctionURLResponse, err error) {
+		defer recoverLambda(&request, &resp, &err)
+		request.Body, request.IsBase64Encoded = body, false
+		panic("boom")
+	}()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("recovered response = %+v, %v", resp, err)
+	}
+	if got := strings.Join(api.methods(), ","); got != "sendMessage,answerCallbackQuery" {
+		t.Errorf("Bot API calls = %s, want the callback from the decoded body answered", got)
+	}
+}
+
+func TestHandleUpdatesRecoversEach(t *testing.T) {
+	api := &fakeBotAPI{}
+	withBotTransport(t, api)
+	var handled []int
+	saved := updateHandler
+	updateHandler = func(_ *tele.Bot, u tele.Update) {
+		if u.ID == 1 {
+			panic("boom")
+		}
+		handled = append(handled, u.ID)
+	}
+	t.Cleanup(func() { updateHandler = saved })
+
+	handleUpdates(newFakeBot(t, api), []tele.Update{
+		{ID: 1, Callback: &tele.Callback{ID: "cb1", Data: "x", Sender: &tele.User{ID: 7}}},
+		{ID: 2, Message: &tele.Message{Text: "/help", Chat: &tele.Chat{ID: 7}}},
+		{ID: 3, Message: &tele.Message{Text: "/help", Chat: &tele.Chat{ID: 8}}},
+	})
+	if len(handled) != 2 || handled[0] != 2 || handled[1] != 3 {
+		t.Errorf("handled %v after update 1 panicked, want [2 3]", handled)
+	}
+	if got := strings.Join(api.methods(), ","); got != "sendMessage,answerCallbackQuery" {
+		t.Errorf("Bot API calls = %s, want the admin alert and update 1's callback answered", got)
+	}
+	if !strings.Contains(api.calls[0].Text, `update 1 callback "x" from 7`) {
+		t.Errorf("admin alert = %q, want the failing update described", api.calls[0].Text)
+	}
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	api := &fakeBotAPI{}
+	withBotTransport(t, api)
+	b := newFakeBot(t, api)
+	c := b.NewContext(tele.Update{ID: 3, Message: &tele.Message{Text: "/report", Chat: &tele.Chat{ID: 7}}})
+	h := recoverMiddleware(b)(func(tele.Context) error { panic("boom") })
+	if err := h(c); err != nil {
+		t.Errorf("recovered handler returned %v", err)
+	}
+	if got := strings.Join(api.methods(), ","); got != "sendMessage" {
+		t.Errorf("Bot API calls = %s, want the admin alert", got)
+	}
+}
+
+func TestDescribeUpdate(t *testing.T) {
+	tests := []struct {
+		u    *tele.Update
+		want string
+	}{
+		{nil, "no update"},
+		{&tele.Update{ID: 1, Callback: &tele.Callback{Data: "btn", Sender: &tele.User{ID: 7}}}, `update 1 callback "btn" from 7`},
+		{&tele.Update{ID: 2, Message: &tele.Message{Text: "/x", Chat: &tele.Chat{ID: -5}}}, `update 2 message "/x" in chat -5`},
+		{&tele.Update{ID: 3}, "update 3"},
+	}
+	for _, tt := range tests {
+		if got := describeUpdate(tt.u); got != tt.want {
+			t.Errorf("describeUpdate = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+// Telegram omits the message of callbacks on very old inline keyboards
+func TestCallbackWithoutMessage(t *testing.T) {
+	withDatabase(t, false, nil)
+	for _, data := range []string{"\fbtn_refresh", "\fbtn_news_lang|en", "\fbtn_share|1", "\fbtn_alert_set|btn|1", "\fbtn_cfg|news", "\fbtn_run|/help"} {
+		api := &fakeBotAPI{}
+		b := newFakeBot(t, api)
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("callback %q without a message panicked: %v", data, r)
+				}
+			}()
+			handleUpdate(b, tele.Update{ID: 1, Callback: &tele.Callback{ID: "cb", Data: data, Sender: &tele.User{ID: 7}}})
+		}()
+		if methods := api.methods(); len(methods) == 0 || methods[len(methods)-1] != "answerCallbackQuery" {
+			t.Errorf("callback %q without a message got %v, want it answered", data, methods)
+		}
+	}
+}
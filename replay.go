@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+// failedUpdateRetention is how long captured failing requests are kept (TTL index)
+const failedUpdateRetention = 14 * 24 * time.Hour
+
+// maxDryRunRunes is how much of each would-be message a replay prints
+const maxDryRunRunes = 300
+
+// failedUpdateCollection holds webhook requests that panicked, when CAPTURE_FAILED_UPDATES is on
+var failedUpdateCollection *mongo.Collection
+
+// replayMode makes newBot build offline bots whose Bot API calls print instead of sending
+var replayMode bool
+
+// dryRunSends counts the Bot API calls the current replayed payload made
+var dryRunSends atomic.Int64
+
+// capturedRequest is the part of a Lambda request a capture keeps: the update and the path.
+// Headers (the webhook secret), query parameters (action keys) and the request context are
+// never stored. The keys match events.LambdaFunctionURLRequest's JSON, so an exported
+// capture's "request" is a replayable request as is.
+type capturedRequest struct {
+	RawPath         string `bson:"rawPath" json:"rawPath"`
+	Body            string `bson:"body" json:"body"`
+	IsBase64Encoded bool   `bson:"isBase64Encoded" json:"isBase64Encoded"`
+}
+
+// --- FAILED UPDATE CAPTURE ---
+
+// captureFailedRequest stores a webhook request whose handling panicked, for -replay. The
+// update itself is kept unredacted; the bot token lives in the environment, not the request.
+func captureFailedRequest(request events.LambdaFunctionURLRequest, cause interface{}) {
+	if replayMode || os.Getenv("CAPTURE_FAILED_UPDATES") != "true" || failedUpdateCollection == nil || request.Body == "" {
+		return
+	}
+	doc := struct {
+		At      time.Time       `bson:"at"`
+		Error   string          `bson:"error"`
+		Request capturedRequest `bson:"request"`
+	}{clock(), fmt.Sprint(cause), capturedRequest{RawPath: request.RawPath, Body: request.Body, IsBase64Encoded: request.IsBase64Encoded}}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if _, err := failedUpdateCollection.InsertOne(ctx, doc); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to capture failing request: %v", err)
+	}
+}
+
+// --- PAYLOAD REPLAY ---
+
+// dryRunTransport answers every Bot API call locally, printing what would have been sent
+type dryRunTransport struct{}
+
+func (dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := path.Base(req.URL.Path)
+	params := map[string]interface{}{}
+	if req.Body != nil {
+		if strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+			raw, _ := io.ReadAll(req.Body)
+			json.Unmarshal(raw, &params)
+		}
+		req.Body.Close()
+	}
+	n := dryRunSends.Add(1)
+	chatID, _ := strconv.ParseInt(fmt.Sprint(params["chat_id"]), 10, 64)
+	text := fmt.Sprint(params["text"])
+	if params["text"] == nil {
+		text = fmt.Sprint(params["caption"])
+		if params["caption"] == nil {
+			text = ""
+		}
+	}
+	fmt.Printf("  → %s chat=%v %s\n", method, params["chat_id"], strings.ReplaceAll(truncateRunes(text, maxDryRunRunes), "\n", "\n    "))
+	// A message-shaped result decodes for sends and edits; bool-returning calls only check "ok"
+	result, _ := json.Marshal(map[string]interface{}{
+		"ok": true,
+		"result": map[string]interface{}{
+			"message_id": n, "date": clock().Unix(), "chat": map[string]interface{}{"id": chatID},
+		},
+	})
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(string(result))),
+		Request:    req,
+	}, nil
+}
+
+// replayDatabaseAllowed refuses any MongoDB but a local one: production is an Atlas
+// cluster, and a replay writes whatever the handlers write. An empty URI is fine, the
+// handlers then run in their degraded no-database mode.
+func replayDatabaseAllowed(uri string) error {
+	if uri == "" {
+		return nil
+	}
+	if prod := os.Getenv("PRODUCTION_MONGODB_URI"); prod != "" && prod == uri {
+		return fmt.Errorf("MONGODB_URI is the production database")
+	}
+	// Checked before parsing, which would resolve the SRV record
+	if strings.HasPrefix(uri, connstring.SchemeMongoDBSRV+"://") {
+		return fmt.Errorf("MONGODB_URI is a mongodb+srv cluster; replay only runs against a local database")
+	}
+	cs, err := connstring.ParseAndValidate(uri)
+	if err != nil {
+		return fmt.Errorf("MONGODB_URI: %w", err)
+	}
+	for _, host := range cs.Hosts {
+		name, _, err := net.SplitHostPort(host)
+		if err != nil {
+			name = host
+		}
+		ip := net.ParseIP(name)
+		if name != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return fmt.Errorf("MONGODB_URI host %s is not local; replay only runs against a local database", host)
+		}
+	}
+	return nil
+}
+
+// loadReplayRequest reads a recorded request: a bare Lambda request JSON or an exported
+// capture document with the request under "request"
+func loadReplayRequest(file string) (events.LambdaFunctionURLRequest, error) {
+	var request events.LambdaFunctionURLRequest
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return request, err
+	}
+	var captured struct {
+		Request *events.LambdaFunctionURLRequest `json:"request"`
+	}
+	if err := json.Unmarshal(raw, &captured); err != nil {
+		return request, fmt.Errorf("not a JSON object: %w", err)
+	}
+	if captured.Request != nil {
+		request = *captured.Request
+	} else if err := json.Unmarshal(raw, &request); err != nil {
+		return request, err
+	}
+	if request.Body == "" {
+		// An empty body is the broadcast trigger, never a recorded update
+		return request, fmt.Errorf("no update body")
+	}
+	return request, nil
+}
+
+// runReplay feeds recorded requests through Handler, exactly as Lambda would, with an
+// offline bot whose calls are printed instead of sent, and prints each payload's outcome.
+// Quote and news fetches are real.
+func runReplay(files []string) error {
+	if err := replayDatabaseAllowed(os.Getenv("MONGODB_URI")); err != nil {
+		return err
+	}
+	replayMode = true
+	failed := 0
+	for _, file := range files {
+		fmt.Printf("▶ %s\n", file)
+		request, err := loadReplayRequest(file)
+		if err != nil {
+			fmt.Printf("✗ %s: skipped, %v\n", file, err)
+			failed++
+			continue
+		}
+		// Captures drop the headers; the webhook gate only needs the content type
+		request.Headers = map[string]string{"content-type": "application/json"}
+		request.QueryStringParameters = nil
+		dryRunSends.Store(0)
+		start := time.Now()
+		resp, err := Handler(context.Background(), request)
+		outcome := fmt.Sprintf("%d %s", resp.StatusCode, resp.Body)
+		switch {
+		case err != nil:
+			outcome = "error: " + err.Error()
+		case resp.Body == "Recovered":
+			outcome = "panicked (recovered)"
+		}
+		mark := "✓"
+		if err != nil || resp.Body != "Processed" {
+			mark = "✗"
+			failed++
+		}
+		fmt.Printf("%s %s: %s, %d Bot API calls, %s\n", mark, file, outcome, dryRunSends.Load(), time.Since(start).Round(time.Millisecond))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d payloads failed", failed, len(files))
+	}
+	return nil
+}
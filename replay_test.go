@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+func TestReplayDatabaseAllowed(t *testing.T) {
+	t.Setenv("PRODUCTION_MONGODB_URI", "mongodb://10.0.0.5:27017/bot")
+	tests := []struct {
+		uri string
+		ok  bool
+	}{
+		{"", true},
+		{"mongodb://localhost:27017/bot", true},
+		{"mongodb://127.0.0.1/bot", true},
+		{"mongodb://[::1]:27017/bot", true},
+		{"mongodb://localhost:27017,127.0.0.2:27018/bot?replicaSet=rs0", true},
+		{"mongodb://10.0.0.5:27017/bot", false},
+		{"mongodb+srv://cluster0.example.mongodb.net/bot", false},
+		{"mongodb://db.example.com:27017/bot", false},
+		{"mongodb://localhost:27017,db.example.com:27017/bot", false},
+		{"not a uri", false},
+	}
+	for _, tt := range tests {
+		if err := replayDatabaseAllowed(tt.uri); (err == nil) != tt.ok {
+			t.Errorf("replayDatabaseAllowed(%q) = %v, want allowed %v", tt.uri, err, tt.ok)
+		}
+	}
+}
+
+func TestLoadReplayRequest(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	update := `{\"update_id\":1}`
+	tests := []struct {
+		name, content, body string
+		ok                  bool
+	}{
+		{"bare.json", `{"rawPath":"/","body":"` + update + `"}`, `{"update_id":1}`, true},
+		{"capture.json", `{"at":"2026-03-02T00:00:00Z","error":"boom","request":{"rawPath":"/","body":"` + update + `","isBase64Encoded":false}}`, `{"update_id":1}`, true},
+		{"broadcast.json", `{"rawPath":"/","body":""}`, "", false},
+		{"array.json", `[1,2]`, "", false},
+		{"garbage.json", `not json`, "", false},
+	}
+	for _, tt := range tests {
+		request, err := loadReplayRequest(write(tt.name, tt.content))
+		if (err == nil) != tt.ok || request.Body != tt.body {
+			t.Errorf("%s: body %q, err %v; want body %q, ok %v", tt.name, request.Body, err, tt.body, tt.ok)
+		}
+	}
+	if _, err := loadReplayRequest(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("a missing file loaded")
+	}
+}
+
+func TestDryRunTransport(t *testing.T) {
+	dryRunSends.Store(0)
+	t.Cleanup(func() { dryRunSends.Store(0) })
+	client := &http.Client{Transport: dryRunTransport{}}
+	resp, err := client.Post("https://api.telegram.org/bottest/sendMessage", "application/json", strings.NewReader(`{"chat_id":"42","text":"hi"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || dryRunSends.Load() != 1 {
+		t.Errorf("status %d, %d sends counted", resp.StatusCode, dryRunSends.Load())
+	}
+
+	// The bot sees a message sent to the chat it asked for
+	b, err := tele.NewBot(tele.Settings{Token: "test", Offline: true, Client: &http.Client{Transport: dryRunTransport{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := deliver(b, 42, 0, "📊 report", nil)
+	if err != nil || msg.Chat.ID != 42 || dryRunSends.Load() != 2 {
+		t.Errorf("deliver = %+v, %v after %d sends", msg, err, dryRunSends.Load())
+	}
+}
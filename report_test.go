@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// withReportSymbols makes loadConfig return the environment defaults with symbols
+func withReportSymbols(t *testing.T, symbols string) {
+	t.Helper()
+	t.Setenv("REPORT_SYMBOLS", symbols)
+	reloadConfig()
+	t.Cleanup(func() {
+		configMu.Lock()
+		configLoadedAt = configLoadedAt.AddDate(-1, 0, 0)
+		configMu.Unlock()
+	})
+}
+
+func TestAllRateLimited(t *testing.T) {
+	limited := MarketData{Err: errRateLimited}
+	tests := []struct {
+		quotes []MarketData
+		want   bool
+	}{
+		{[]MarketData{limited, limited}, true},
+		{[]MarketData{limited, {Err: fmt.Errorf("batch: %w", errRateLimited)}}, true},
+		{[]MarketData{limited, {Price: 1}}, false},
+		{[]MarketData{limited, {Err: errors.New("timeout")}}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := allRateLimited(tt.quotes...); got != tt.want {
+			t.Errorf("allRateLimited(%v) = %v, want %v", tt.quotes, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteLine(t *testing.T) {
+	tests := []struct {
+		d    MarketData
+		want string
+	}{
+		{MarketData{Price: 3012.5, Change: "📈 +0.50%"}, "• Vàng: `$3012.50` (📈 +0.50%)"},
+		{MarketData{Err: errors.New("timeout")}, "• Vàng: ⚠️ không có dữ liệu"},
+		{MarketData{Err: errRateLimited}, "• Vàng: ⚠️ không có dữ liệu"},
+		{MarketData{Err: errSymbolQuarantined}, "• Vàng: ⏸ tạm ngưng (mã không còn dữ liệu)"},
+	}
+	for _, tt := range tests {
+		if got := quoteLine("Vàng", "`$%.2f`", tt.d); got != tt.want {
+			t.Errorf("quoteLine(%+v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestReportRendersAvailableAssets(t *testing.T) {
+	withDatabase(t, false, nil)
+	f := usdVndChain(t)
+	withReportSymbols(t, "gold,eurusd,btc")
+	f.set(twelveDataHost, jsonResponse(`{
+		"XAU/USD": {"symbol":"XAU/USD","close":"3012.5","percent_change":"0.5"},
+		"EUR/USD": {"code":500,"message":"internal error","status":"error"},
+		"BTC/USD": {"symbol":"BTC/USD","close":"61000","percent_change":"-1.2"}
+	}`))
+	report := buildMarketReport()
+	for _, want := range []string{
+		lookupAsset("gold").Label + ": `$3012.50`",
+		lookupAsset("eurusd").Label + ": ⚠️ không có dữ liệu",
+		lookupAsset("btc").Label + ": `$61000.00`",
+	} {
+		if !strings.Contains(report.Text, want) {
+			t.Errorf("report is missing %q:\n%s", want, report.Text)
+		}
+	}
+
+	f.set(twelveDataHost, jsonResponse(`{"code":429,"message":"You have run out of API credits","status":"error"}`))
+	if report := buildMarketReport(); !strings.Contains(report.Text, "API credits exhausted") {
+		t.Errorf("a fully rate-limited report = %q", report.Text)
+	}
+}
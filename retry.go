@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// replyRetryDelay is how long a failed /report waits before its retry, so a passing quote
+// timeout or Telegram error has a chance to clear
+const replyRetryDelay = 5 * time.Second
+
+// replyRetryRetention drops retries nobody processed (TTL index); a placeholder that old
+// is better left with its error notice
+const replyRetryRetention = time.Hour
+
+// selfInvokeTimeout bounds the call that starts a retry invocation; the invoked function
+// keeps running after the caller hangs up
+const selfInvokeTimeout = 2 * time.Second
+
+// Placeholder texts while a retry is pending and once it failed too
+const (
+	retryPendingText = "⚠️ Lỗi tạm thời, thử lại..."
+	retryFailedText  = "❌ Không lấy được dữ liệu thị trường lúc này. Vui lòng gõ /report để thử lại sau."
+)
+
+// errReportUnavailable marks a report in which every quote failed for a reason other than
+// exhausted credits (those won't come back within a retry)
+var errReportUnavailable = errors.New("every quote failed")
+
+// replyRetryCollection holds failed /report replies waiting for their one retry
+var replyRetryCollection *mongo.Collection
+
+// reportBuilder builds the report a reply delivers; tests swap it for a fixed report
+var reportBuilder = buildMarketReport
+
+// reportReply is a /report answer: the placeholder it replaces and how the chat reads
+// reports. Queued for a retry, its _id is the placeholder, so one placeholder is never
+// queued twice.
+type reportReply struct {
+	ID            string    `bson:"_id"`
+	ChatID        int64     `bson:"chat_id"`
+	MessageID     int       `bson:"message_id"`
+	ThreadID      int       `bson:"thread_id,omitempty"`
+	Plain         bool      `bson:"plain,omitempty"`
+	Cards         bool      `bson:"cards,omitempty"`
+	MoveThreshold float64   `bson:"move_threshold"`
+	At            time.Time `bson:"at"`
+	DueAt         time.Time `bson:"due_at"`
+}
+
+// --- REPLY RETRY QUEUE ---
+
+// placeholderGone reports whether an edit failed because the user deleted the placeholder
+func placeholderGone(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "message to edit not found")
+}
+
+// placeholder is the message a reply edits
+func (r reportReply) placeholder() tele.Editable {
+	return &tele.StoredMessage{MessageID: strconv.Itoa(r.MessageID), ChatID: r.ChatID}
+}
+
+// deliverReportReply builds the report and edits it into the placeholder. The error is
+// errReportUnavailable when no quote came back, or the edit's error.
+func deliverReportReply(b *tele.Bot, reply reportReply) error {
+	report := reportBuilder()
+	quotes := make([]MarketData, 0, len(report.Quotes))
+	unavailable := len(report.Quotes) > 0
+	for _, d := range report.Quotes {
+		quotes = append(quotes, d)
+		unavailable = unavailable && d.Err != nil
+	}
+	if unavailable && !allRateLimited(quotes...) {
+		return errReportUnavailable
+	}
+	var err error
+	if reply.Plain {
+		_, err = b.Edit(reply.placeholder(), report.Plain, &tele.SendOptions{ReplyMarkup: report.Menu, DisableWebPagePreview: true})
+	} else {
+		text := fitEntityLimit(insertBeforeFooter(report.textFor(reply.Cards),
+			moversSection(loadConfig().Symbols, report.Quotes, reply.MoveThreshold)))
+		_, err = b.Edit(reply.placeholder(), text, &tele.SendOptions{
+			ParseMode:             tele.ModeMarkdown,
+			ReplyMarkup:           report.Menu,
+			DisableWebPagePreview: true,
+		})
+	}
+	if errors.Is(err, tele.ErrMessageNotModified) || errors.Is(err, tele.ErrSameMessageContent) {
+		err = nil
+	}
+	if err == nil && reply.Cards && !reply.Plain {
+		sendNewsCards(b, reply.ChatID, reply.ThreadID, report.Headlines, false)
+	}
+	return err
+}
+
+// queueReplyRetry handles a failed /report: the placeholder says a retry is coming and the
+// reply is queued for exactly one. Nothing is queued when the user deleted the
+// placeholder; without a database the failure is final at once.
+func queueReplyRetry(b *tele.Bot, reply reportReply, cause error) {
+	if placeholderGone(cause) {
+		log.Printf("[RETRY] Placeholder %d in %d is gone, not retrying", reply.MessageID, reply.ChatID)
+		return
+	}
+	if replyRetryCollection == nil {
+		b.Edit(reply.placeholder(), retryFailedText)
+		return
+	}
+	if _, err := b.Edit(reply.placeholder(), retryPendingText); placeholderGone(err) {
+		return
+	}
+	now := clock()
+	reply.ID = fmt.Sprintf("%d:%d", reply.ChatID, reply.MessageID)
+	reply.At, reply.DueAt = now, now.Add(replyRetryDelay)
+	_, err := replyRetryCollection.InsertOne(context.TODO(), reply)
+	switch {
+	case mongo.IsDuplicateKeyError(err):
+		log.Printf("[RETRY] Placeholder %s is already queued", reply.ID)
+		return
+	case err != nil:
+		log.Printf("[DATABASE ERROR] Failed to queue reply retry %s: %v", reply.ID, err)
+		b.Edit(reply.placeholder(), retryFailedText)
+		return
+	}
+	log.Printf("[RETRY] Queued %s after: %v", reply.ID, cause)
+	triggerReplyRetries(b)
+}
+
+// triggerReplyRetries gets the queue processed now rather than at the next cron tick:
+// locally after the retry delay, on Lambda by calling the function's own URL with
+// ?action=retries (needs PUBLIC_BASE_URL and ADMIN_ACTION_KEY)
+func triggerReplyRetries(b *tele.Bot) {
+	if os.Getenv("AWS_LAMBDA_FUNCTION_NAME") == "" {
+		time.AfterFunc(replyRetryDelay, func() { processReplyRetries(b) })
+		return
+	}
+	base, key := os.Getenv("PUBLIC_BASE_URL"), os.Getenv("ADMIN_ACTION_KEY")
+	if base == "" || key == "" {
+		log.Println("[RETRY] No PUBLIC_BASE_URL/ADMIN_ACTION_KEY, leaving the retry to the next cron tick")
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), selfInvokeTimeout)
+	defer cancel()
+	target := strings.TrimRight(base, "/") + "?action=retries&key=" + url.QueryEscape(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, nil)
+	if err != nil {
+		log.Printf("[RETRY ERROR] Bad PUBLIC_BASE_URL: %v", err)
+		return
+	}
+	resp, err := http.DefaultClient.Do(req)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		// Expected: the retry invocation is still running
+	case err != nil:
+		log.Printf("[RETRY ERROR] Self-invoke failed, leaving the retry to the next cron tick: %v", err)
+	default:
+		resp.Body.Close()
+	}
+}
+
+// processReplyRetries runs the queued retries that are due by the end of the retry delay,
+// waiting for each one's due time. Each is claimed by deleting it, so it runs once
+// however many invocations process the queue; the placeholder then shows the report or
+// the final failure notice.
+func processReplyRetries(b *tele.Bot) int {
+	if replyRetryCollection == nil {
+		return 0
+	}
+	processed := 0
+	for {
+		var reply reportReply
+		err := replyRetryCollection.FindOneAndDelete(context.TODO(),
+			bson.M{"due_at": bson.M{"$lte": clock().Add(replyRetryDelay)}},
+			options.FindOneAndDelete().SetSort(bson.D{{Key: "due_at", Value: 1}})).Decode(&reply)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return processed
+		}
+		if err != nil {
+			log.Printf("[DATABASE ERROR] Failed to claim reply retry: %v", err)
+			return processed
+		}
+		if wait := reply.DueAt.Sub(clock()); wait > 0 {
+			time.Sleep(wait)
+		}
+		processed++
+		runReplyRetry(b, reply)
+	}
+}
+
+// runReplyRetry makes a claimed reply's one retry. When it fails again the placeholder
+// gets the final failure notice, unless the user deleted it.
+func runReplyRetry(b *tele.Bot, reply reportReply) error {
+	err := deliverReportReply(b, reply)
+	switch {
+	case err == nil:
+		log.Printf("[RETRY] %s succeeded", reply.ID)
+	case placeholderGone(err):
+		log.Printf("[RETRY] %s dropped, placeholder deleted", reply.ID)
+	default:
+		log.Printf("[RETRY] %s failed again: %v", reply.ID, err)
+		b.Edit(reply.placeholder(), retryFailedText)
+	}
+	return err
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// botAPICall is one Bot API request a fakeBotAPI answered
+type botAPICall struct {
+	Method string
+	Text   string
+}
+
+// fakeBotAPI answers Bot API calls in-process, recording them. Edits fail with "message to
+// edit not found" while deleted is set.
+type fakeBotAPI struct {
+	mu      sync.Mutex
+	calls   []botAPICall
+	deleted bool
+}
+
+func (f *fakeBotAPI) RoundTrip(req *http.Request) (*http.Response, error) {
+	params := map[string]interface{}{}
+	if req.Body != nil {
+		raw, _ := io.ReadAll(req.Body)
+		json.Unmarshal(raw, &params)
+		req.Body.Close()
+	}
+	method := path.Base(req.URL.Path)
+	text, _ := params["text"].(string)
+	f.mu.Lock()
+	f.calls = append(f.calls, botAPICall{Method: method, Text: text})
+	deleted := f.deleted
+	f.mu.Unlock()
+	body := `{"ok":true,"result":{"message_id":1,"date":0,"chat":{"id":1}}}`
+	if deleted && method == "editMessageText" {
+		body = `{"ok":false,"error_code":400,"description":"Bad Request: message to edit not found"}`
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}, nil
+}
+
+func (f *fakeBotAPI) edits() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var texts []string
+	for _, c := range f.calls {
+		if c.Method == "editMessageText" {
+			texts = append(texts, c.Text)
+		}
+	}
+	return texts
+}
+
+// newFakeBot is a bot whose API calls go to api
+func newFakeBot(t *testing.T, api *fakeBotAPI) *tele.Bot {
+	t.Helper()
+	b, err := tele.NewBot(tele.Settings{Token: "test", Offline: true, Client: &http.Client{Transport: api}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// withReport makes replies deliver report
+func withReport(t *testing.T, report MarketReport) {
+	t.Helper()
+	saved := reportBuilder
+	reportBuilder = func() MarketReport { return report }
+	t.Cleanup(func() { reportBuilder = saved })
+}
+
+func TestRunReplyRetry(t *testing.T) {
+	good := MarketReport{Text: "📊 report", Quotes: map[string]MarketData{"btc": {Price: 60000}}}
+	failed := MarketReport{Text: "📊 report", Quotes: map[string]MarketData{"btc": {Err: errors.New("timeout")}}}
+	reply := reportReply{ID: "1:7", ChatID: 1, MessageID: 7}
+
+	t.Run("success on retry", func(t *testing.T) {
+		withReport(t, good)
+		api := &fakeBotAPI{}
+		if err := runReplyRetry(newFakeBot(t, api), reply); err != nil {
+			t.Fatalf("runReplyRetry = %v", err)
+		}
+		if edits := api.edits(); len(edits) != 1 || !strings.Contains(edits[0], "📊 report") {
+			t.Errorf("edits = %q, want the report only", edits)
+		}
+	})
+
+	t.Run("failure on retry", func(t *testing.T) {
+		withReport(t, failed)
+		api := &fakeBotAPI{}
+		if err := runReplyRetry(newFakeBot(t, api), reply); !errors.Is(err, errReportUnavailable) {
+			t.Fatalf("runReplyRetry = %v, want errReportUnavailable", err)
+		}
+		if edits := api.edits(); len(edits) != 1 || edits[0] != retryFailedText {
+			t.Errorf("edits = %q, want the final failure notice", edits)
+		}
+	})
+
+	t.Run("deleted placeholder", func(t *testing.T) {
+		withReport(t, good)
+		api := &fakeBotAPI{deleted: true}
+		if err := runReplyRetry(newFakeBot(t, api), reply); !placeholderGone(err) {
+			t.Fatalf("runReplyRetry = %v, want the placeholder gone", err)
+		}
+		if edits := api.edits(); len(edits) != 1 {
+			t.Errorf("edits = %q, want only the report attempt, no failure notice", edits)
+		}
+	})
+}
+
+func TestDeliverReportReplyRateLimited(t *testing.T) {
+	// Exhausted credits won't come back within a retry, so the report goes out as it is
+	withReport(t, MarketReport{Text: "📊 report", Quotes: map[string]MarketData{"btc": {Err: errRateLimited}}})
+	api := &fakeBotAPI{}
+	if err := deliverReportReply(newFakeBot(t, api), reportReply{ChatID: 1, MessageID: 7}); err != nil {
+		t.Fatalf("deliverReportReply = %v", err)
+	}
+	if edits := api.edits(); len(edits) != 1 {
+		t.Errorf("edits = %q, want the report", edits)
+	}
+}
+
+func TestQueueReplyRetry(t *testing.T) {
+	saved := replyRetryCollection
+	replyRetryCollection = nil
+	t.Cleanup(func() { replyRetryCollection = saved })
+	reply := reportReply{ChatID: 1, MessageID: 7}
+
+	api := &fakeBotAPI{}
+	queueReplyRetry(newFakeBot(t, api), reply, errors.New("telegram: message to edit not found (400)"))
+	if len(api.calls) != 0 {
+		t.Errorf("a deleted placeholder was edited: %+v", api.calls)
+	}
+
+	api = &fakeBotAPI{}
+	queueReplyRetry(newFakeBot(t, api), reply, errReportUnavailable)
+	if edits := api.edits(); len(edits) != 1 || edits[0] != retryFailedText {
+		t.Errorf("without a database edits = %q, want the final failure notice at once", edits)
+	}
+}
@@ -0,0 +1,556 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	tele "gopkg.in/telebot.v3"
+)
+
+// Per-chat command rate limit, counted in fixed windows
+const (
+	commandRateWindow = time.Minute
+	commandRateMax    = 20
+)
+
+// dedupSize is how many recent update IDs are remembered to drop Telegram redeliveries
+const dedupSize = 1000
+
+// invalidCommandText answers unknown commands and commands the chat may not use
+const invalidCommandText = "🤖 Lệnh không hợp lệ. Vui lòng dùng /help để xem danh sách các lệnh hỗ trợ."
+
+// User is the subscriber document as the command handlers see it
+type User struct {
+	ChatID      int64    `bson:"chat_id"`
+	NewsMode    string   `bson:"news_mode"`
+	NumberStyle string   `bson:"number_style"`
+	Silent      string   `bson:"silent"`
+	Watchlist   []string `bson:"watchlist"`
+	// MoveThreshold is the /movethreshold percent; 0 means the configured default
+	MoveThreshold float64 `bson:"move_threshold"`
+	PlainText     bool    `bson:"plain_text"`
+	// ChatType is the Telegram chat type, which picks the broadcast format (see channel.go)
+	ChatType string `bson:"chat_type"`
+	// PendingAlert is set while "Nhập giá khác" waits for a typed price (see alertladder.go)
+	PendingAlert *PendingAlert `bson:"pending_alert,omitempty"`
+	// Tier is the subscription tier (see tiers.go); PremiumUntil, when set, is when premium lapses
+	Tier         string    `bson:"tier"`
+	PremiumUntil time.Time `bson:"premium_until,omitempty"`
+}
+
+// Request is one command invocation, shared by the Lambda and local dispatch
+type Request struct {
+	Bot      *tele.Bot
+	Message  *tele.Message
+	UpdateID int
+	Command  string
+	Payload  string
+	// Args is Payload split on whitespace, with "double-quoted" text kept as one argument
+	// (see splitArgs)
+	Args []string
+	// Alias is the name the user typed when it was an alias of Command
+	Alias string
+	// User is the chat's document, loaded once by userMiddleware; nil when not subscribed
+	User *User
+	// Actor, when set, is whose roles gate the command instead of the chat's (a channel
+	// post has no sender, so its verified owner is recorded here)
+	Actor int64
+	// Ctx carries the update's trace span (see trace.go); read it with Context
+	Ctx context.Context
+}
+
+// HandlerFunc handles one command
+type HandlerFunc func(r *Request) error
+
+// Middleware wraps a handler with a cross-cutting concern
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// alias maps an old or alternate command name onto its canonical route. Renamed commands
+// set notice so each user is told the new name once.
+type alias struct {
+	target string
+	notice bool
+}
+
+// route is a command handler plus the middleware specific to it, applied inside the
+// default stack
+type route struct {
+	handler    HandlerFunc
+	middleware []Middleware
+}
+
+// ChatID is the chat the command came from
+func (r *Request) ChatID() int64 {
+	return r.Message.Chat.ID
+}
+
+// ActorID is the identity role checks apply to
+func (r *Request) ActorID() int64 {
+	if r.Actor != 0 {
+		return r.Actor
+	}
+	return r.ChatID()
+}
+
+// Reply sends text back to the chat
+func (r *Request) Reply(what interface{}, opts ...interface{}) error {
+	_, err := r.Bot.Send(r.Message.Chat, what, opts...)
+	return err
+}
+
+// --- COMMAND ROUTER ---
+
+// defaultMiddleware wraps every route, outermost first
+var defaultMiddleware = []Middleware{traceMiddleware, logMiddleware, dedupMiddleware, rateLimitMiddleware, maintenanceMiddleware, userMiddleware, tierMiddleware, deprecationMiddleware}
+
+// chain wraps h in mws so that mws[0] runs first
+func chain(h HandlerFunc, mws ...Middleware) HandlerFunc {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// commandAliases keeps old names working after a rename
+var commandAliases = map[string]alias{
+	"/update": {target: "/report", notice: true},
+	"/cancel": {target: "/quit"},
+}
+
+// parseCommand splits "  /Cmd@Bot  payload " into the lower-cased "/cmd", the trimmed
+// payload and the bot it was addressed to ("" when none). The command ends at any
+// whitespace, newlines included. Text that doesn't start with a slash has no command.
+func parseCommand(text string) (command, payload, mention string) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return "", text, ""
+	}
+	end := strings.IndexFunc(text, unicode.IsSpace)
+	if end < 0 {
+		end = len(text)
+	}
+	command, payload = strings.ToLower(text[:end]), strings.TrimSpace(text[end:])
+	if i := strings.IndexByte(command, '@'); i > 0 {
+		command, mention = command[:i], command[i+1:]
+	}
+	return command, payload, mention
+}
+
+// dispatchCommand routes a message through the middleware stack to its handler; unknown
+// commands get a suggestion and plain text a hint (see suggest.go)
+func dispatchCommand(b *tele.Bot, updateID int, m *tele.Message) error {
+	return dispatchAs(b, updateID, m, 0)
+}
+
+// dispatchAs routes a message whose role checks apply to actor (0 means the chat itself)
+func dispatchAs(b *tele.Bot, updateID int, m *tele.Message, actor int64) error {
+	if m == nil || m.Chat == nil || handleChatMigration(m) {
+		return nil
+	}
+	command, payload, mention := parseCommand(m.Text)
+	// In groups, "/report@OtherBot" is for another bot; stay quiet
+	if mention != "" && b.Me != nil && b.Me.Username != "" && !strings.EqualFold(mention, b.Me.Username) {
+		return nil
+	}
+	typed := ""
+	if a, ok := commandAliases[command]; ok {
+		typed, command = command, a.target
+	}
+	rt, ok := commandRoutes[command]
+	switch {
+	case !ok && command == "":
+		rt = route{handler: plainTextHandler}
+	case !ok:
+		rt = route{handler: unknownCommandHandler}
+	}
+	h := chain(chain(rt.handler, rt.middleware...), defaultMiddleware...)
+	return h(&Request{Bot: b, Message: m, UpdateID: updateID, Command: command, Payload: payload,
+		Args: splitArgs(payload), Alias: typed, Actor: actor})
+}
+
+// splitArgs splits a payload on whitespace like a shell would for double quotes:
+// `"Bitcoin ETF approval" 3` is two arguments. Phone keyboards' curly quotes count too,
+// and \" inside quotes is a literal quote. An unterminated quote runs to the end of the
+// payload rather than failing, so a stray quote never swallows the whole command.
+func splitArgs(payload string) []string {
+	var args []string
+	var cur strings.Builder
+	inQuote, inToken, escaped := false, false, false
+	for _, c := range payload {
+		switch {
+		case escaped:
+			cur.WriteRune(c)
+			escaped = false
+		case inQuote && c == '\\':
+			escaped = true
+		case c == '"' || c == '“' || c == '”':
+			inQuote = !inQuote
+			inToken = true
+		case !inQuote && unicode.IsSpace(c):
+			if inToken {
+				args = append(args, cur.String())
+				cur.Reset()
+				inToken = false
+			}
+		default:
+			cur.WriteRune(c)
+			inToken = true
+		}
+	}
+	if escaped {
+		cur.WriteRune('\\')
+	}
+	if inToken {
+		args = append(args, cur.String())
+	}
+	return args
+}
+
+// deprecationMiddleware tells a user, once, that the command they typed was renamed.
+// The notice is claimed atomically on the user document, so it is never repeated.
+func deprecationMiddleware(next HandlerFunc) HandlerFunc {
+	return func(r *Request) error {
+		err := next(r)
+		a, ok := commandAliases[r.Alias]
+		if !ok || !a.notice || r.User == nil || userCollection == nil {
+			return err
+		}
+		res, dbErr := userCollection.UpdateOne(context.TODO(),
+			bson.M{"chat_id": r.ChatID(), "deprecation_notices": bson.M{"$ne": r.Alias}},
+			bson.M{"$addToSet": bson.M{"deprecation_notices": r.Alias}})
+		if dbErr == nil && res.ModifiedCount > 0 {
+			r.Reply(fmt.Sprintf("ℹ️ Lệnh %s đã đổi thành %s. Lệnh cũ vẫn dùng được.", r.Alias, a.target))
+		}
+		return err
+	}
+}
+
+// logMiddleware logs each command with its chat, span, how long it took and how many bytes
+// its fetches read, and feeds the /usage counters
+func logMiddleware(next HandlerFunc) HandlerFunc {
+	return func(r *Request) error {
+		start := time.Now()
+		err := next(r)
+		if _, known := commandRoutes[r.Command]; known {
+			recordCommandUsage(r.Command)
+		} else {
+			recordUnknownInput(r.Message)
+		}
+		var read int64
+		if s := spanFrom(r.Ctx); s != nil {
+			read = s.bytes.Load()
+		}
+		r.Logf("[COMMAND] command=%s chat=%d update=%d duration=%s bytes=%d err=%v",
+			r.Command, r.ChatID(), r.UpdateID, time.Since(start).Round(time.Millisecond), read, err)
+		return err
+	}
+}
+
+var (
+	dedupMu    sync.Mutex
+	seenOrder  []int
+	seenUpdate = make(map[int]bool)
+)
+
+// dedupMiddleware drops an update already handled by this instance (Telegram redelivers
+// webhooks that time out)
+func dedupMiddleware(next HandlerFunc) HandlerFunc {
+	return func(r *Request) error {
+		if r.UpdateID != 0 {
+			dedupMu.Lock()
+			if seenUpdate[r.UpdateID] {
+				dedupMu.Unlock()
+				r.Logf("[COMMAND] Duplicate update %d dropped", r.UpdateID)
+				return nil
+			}
+			seenUpdate[r.UpdateID] = true
+			seenOrder = append(seenOrder, r.UpdateID)
+			if len(seenOrder) > dedupSize {
+				delete(seenUpdate, seenOrder[0])
+				seenOrder = seenOrder[1:]
+			}
+			dedupMu.Unlock()
+		}
+		return next(r)
+	}
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+var (
+	rateMu      sync.Mutex
+	rateWindows = make(map[int64]*rateWindow)
+)
+
+// rateLimitMiddleware allows commandRateMax commands per chat per window; the rest are
+// answered once with a warning and never reach the handler
+func rateLimitMiddleware(next HandlerFunc) HandlerFunc {
+	return func(r *Request) error {
+		now := clock()
+		rateMu.Lock()
+		w := rateWindows[r.ChatID()]
+		if w == nil || now.Sub(w.start) >= commandRateWindow {
+			w = &rateWindow{start: now}
+			rateWindows[r.ChatID()] = w
+		}
+		w.count++
+		count := w.count
+		rateMu.Unlock()
+		if count > commandRateMax {
+			if count == commandRateMax+1 {
+				return r.Reply("⏳ Bạn gửi lệnh quá nhanh, vui lòng thử lại sau ít phút.")
+			}
+			return nil
+		}
+		return next(r)
+	}
+}
+
+// userMiddleware loads the chat's user document once for the handler
+func userMiddleware(next HandlerFunc) HandlerFunc {
+	return func(r *Request) error {
+		if userCollection != nil {
+			var u User
+			if err := userCollection.FindOne(context.TODO(), bson.M{"chat_id": r.ChatID()}).Decode(&u); err == nil {
+				r.User = &u
+				if u.ChatType != string(r.Message.Chat.Type) {
+					setChatType(u.ChatID, r.Message.Chat.Type)
+				}
+			}
+		}
+		return next(r)
+	}
+}
+
+// newsModeOf returns the request chat's news mode
+func (r *Request) newsModeOf() string {
+	if r.User != nil && r.User.NewsMode == newsModeCards {
+		return newsModeCards
+	}
+	return newsModeList
+}
+
+// markdown is the send option most replies use
+func markdown() *tele.SendOptions {
+	return &tele.SendOptions{ParseMode: tele.ModeMarkdown}
+}
+
+// commandRoutes maps each command to its handler
+var commandRoutes = map[string]route{
+	"/start": {handler: func(r *Request) error {
+		if !databaseAvailable() {
+			return r.Reply("⚠️ Đăng ký tạm thời không khả dụng, vui lòng thử lại sau ít phút. Các lệnh xem giá vẫn hoạt động.")
+		}
+		existed := saveUser(r.ChatID())
+		setChatType(r.ChatID(), r.Message.Chat.Type)
+		if existed {
+			return r.Reply("👋 Chào mừng trở lại! Bạn vẫn đang nhận bản tin, các cài đặt được giữ nguyên. Gõ /help để xem hướng dẫn.")
+		}
+		return r.Reply("Chào mừng Trader! Bạn đã đăng ký nhận bản tin tự động hàng ngày. Gõ /help để xem hướng dẫn.")
+	}},
+	"/help": {handler: func(r *Request) error {
+		return r.Reply(helpMessage, markdown())
+	}},
+	"/report": {handler: func(r *Request) error {
+		tmpMsg, err := r.Bot.Send(r.Message.Chat, "⌛ *Đang lấy dữ liệu thị trường mới nhất...*", markdown())
+		if err != nil {
+			return err
+		}
+		reply := reportReply{
+			ChatID:        r.ChatID(),
+			MessageID:     tmpMsg.ID,
+			ThreadID:      r.Message.ThreadID,
+			Plain:         r.User != nil && r.User.PlainText,
+			Cards:         r.newsModeOf() == newsModeCards,
+			MoveThreshold: moveThresholdOf(r.User),
+		}
+		if err := deliverReportReply(r.Bot, reply); err != nil {
+			// Handled here: the retry queue logs the failure and owns the placeholder now
+			queueReplyRetry(r.Bot, reply, err)
+		}
+		return nil
+	}},
+	"/news": {handler: func(r *Request) error {
+		return r.Reply(newsReply(r.Payload), markdown())
+	}},
+	"/follow": {handler: func(r *Request) error {
+		return r.Reply(followReply(r.ChatID(), r.Args, false), markdown())
+	}},
+	"/unfollow": {handler: func(r *Request) error {
+		return r.Reply(followReply(r.ChatID(), r.Args, true), markdown())
+	}},
+	"/plaintext": {handler: func(r *Request) error {
+		return r.Reply(plainTextReply(r.ChatID(), r.Payload))
+	}},
+	"/usdvnd": {handler: func(r *Request) error {
+		return r.Reply(usdVndOnDateReply(r.Payload), markdown())
+	}},
+	"/extended": {handler: func(r *Request) error {
+		return r.Reply(extendedReply(r.Payload), markdown())
+	}},
+	"/corr": {handler: func(r *Request) error {
+		return r.Reply(corrReply(r.Payload), markdown())
+	}},
+	"/correlation": {handler: func(r *Request) error {
+		return r.Reply(correlationReply(r.Payload), markdown())
+	}},
+	"/vol": {handler: func(r *Request) error {
+		return r.Reply(volReply(r.Payload), markdown())
+	}},
+	"/spread": {handler: func(r *Request) error {
+		return r.Reply(spreadReply(r.Payload), markdown())
+	}},
+	"/symbols": {handler: func(r *Request) error {
+		return r.Reply(symbolsReply(r.Payload), markdown())
+	}},
+	"/open": {handler: func(r *Request) error {
+		return r.Reply(openReply(r.Payload), markdown())
+	}},
+	"/convert": {handler: func(r *Request) error {
+		return r.Reply(convertReply(r.ChatID(), r.Payload), markdown())
+	}},
+	"/history": {handler: func(r *Request) error {
+		return sendHistoryCSV(r.Bot, r.Message.Chat, r.Payload)
+	}},
+	"/export": {handler: func(r *Request) error {
+		return sendExport(r.Bot, r.Message.Chat, r.Payload)
+	}},
+	"/watch": {handler: func(r *Request) error {
+		return r.Reply(watchReply(r.ChatID(), r.Payload), markdown())
+	}},
+	"/import": {handler: func(r *Request) error {
+		replied := ""
+		if r.Message.ReplyTo != nil {
+			replied = r.Message.ReplyTo.Text
+		}
+		limit := freeWatchlistLimit
+		if r.User != nil {
+			limit = watchlistLimit(effectiveTier(r.User.Tier, r.User.PremiumUntil, clock()))
+		}
+		return r.Reply(importReply(r.ChatID(), r.Payload, replied, limit), markdown())
+	}},
+	"/trywatch": {handler: func(r *Request) error {
+		return r.Reply(tryWatchReply(r.ChatID(), r.Payload), markdown())
+	}},
+	"/board": {handler: func(r *Request) error {
+		return r.Reply(boardReply(r.Bot, r.ChatID(), r.Payload), markdown())
+	}},
+	"/setherethread": {handler: func(r *Request) error {
+		return r.Reply(setHereThreadReply(r.Bot, r.Message), &tele.SendOptions{ThreadID: r.Message.ThreadID})
+	}},
+	"/newsmode": {handler: func(r *Request) error {
+		return r.Reply(newsModeReply(r.ChatID(), r.Payload))
+	}},
+	"/movethreshold": {handler: func(r *Request) error {
+		return r.Reply(moveThresholdReply(r.ChatID(), r.Payload), markdown())
+	}},
+	"/format": {handler: func(r *Request) error {
+		return r.Reply(formatReply(r.ChatID(), r.Payload))
+	}},
+	"/settings": {handler: func(r *Request) error {
+		if strings.TrimSpace(r.Payload) == "" {
+			text, menu := settingsMenuFor(r.ChatID())
+			return r.Reply(text, &tele.SendOptions{ParseMode: tele.ModeMarkdown, ReplyMarkup: menu})
+		}
+		return r.Reply(settingsReply(r.ChatID(), r.Payload), markdown())
+	}},
+	"/portfolio": {handler: func(r *Request) error {
+		return r.Reply(portfolioReply(r.ChatID(), r.Payload), markdown())
+	}},
+	"/buy": {handler: func(r *Request) error {
+		return r.Reply(buyReply(r.ChatID(), r.Args), markdown())
+	}},
+	"/target": {handler: func(r *Request) error {
+		return r.Reply(targetReply(r.ChatID(), r.Payload), markdown())
+	}},
+	"/performance": {handler: func(r *Request) error {
+		return r.Reply(performanceReply(r.ChatID()), markdown())
+	}},
+	"/allocation": {handler: func(r *Request) error {
+		return r.Reply(allocationReply(r.ChatID()), markdown())
+	}},
+	"/portfolioalert": {handler: func(r *Request) error {
+		return r.Reply(portfolioAlertReply(r.ChatID(), r.Payload), markdown())
+	}},
+	"/alert": {handler: func(r *Request) error {
+		if len(r.Args) == 1 {
+			text, menu := alertLadderReply(resolveSymbol(r.Args[0]))
+			return r.Reply(text, &tele.SendOptions{ParseMode: tele.ModeMarkdown, ReplyMarkup: menu})
+		}
+		return r.Reply(alertReply(r.ChatID(), r.Payload), markdown())
+	}},
+	"/trail": {handler: func(r *Request) error {
+		return r.Reply(trailReply(r.ChatID(), r.Payload), markdown())
+	}},
+	"/alertall": {handler: func(r *Request) error {
+		return r.Reply(alertAllReply(r.ChatID(), r.Payload), markdown())
+	}},
+	"/clearalerts": {handler: func(r *Request) error {
+		return r.Reply(clearAlertsReply(r.ChatID()))
+	}},
+	"/alerts": {handler: func(r *Request) error {
+		return r.Reply(alertsReply(r.ChatID(), r.Payload), markdown())
+	}},
+	"/poll": {handler: func(r *Request) error {
+		return r.Reply(pollPreferenceReply(r.ChatID(), r.Payload))
+	}},
+	"/leaderboard": {handler: func(r *Request) error {
+		if r.Message.Sender == nil {
+			return nil
+		}
+		return r.Reply(leaderboardReply(r.Message.Sender.ID, r.Payload), markdown())
+	}},
+	"/experiment": {handler: func(r *Request) error {
+		return r.Reply(experimentReply())
+	}, middleware: []Middleware{requireRole(roleViewer)}},
+	"/raw": {handler: func(r *Request) error {
+		return r.Reply(rawReply(r.Context(), r.Payload), &tele.SendOptions{ParseMode: tele.ModeMarkdownV2})
+	}, middleware: []Middleware{requireRole(roleAdmin)}},
+	"/usage": {handler: func(r *Request) error {
+		return r.Reply(usageReply())
+	}, middleware: []Middleware{requireRole(roleViewer)}},
+	"/maintenance": {handler: func(r *Request) error {
+		return r.Reply(maintenanceReply(r.Args))
+	}, middleware: []Middleware{requireRole(roleAdmin)}},
+	"/grant": {handler: func(r *Request) error {
+		return r.Reply(grantReply(r.Bot, r.Args))
+	}, middleware: []Middleware{requireRole(roleAdmin)}},
+	"/source": {handler: func(r *Request) error {
+		return r.Reply(sourceReply(r.Args))
+	}, middleware: []Middleware{requireRole(roleAdmin)}},
+	"/resume": {handler: func(r *Request) error {
+		return r.Reply(resumeBroadcast(r.Bot))
+	}, middleware: []Middleware{requireRole(roleAdmin)}},
+	"/status": {handler: func(r *Request) error {
+		return r.Reply(statusReply(), markdown())
+	}, middleware: []Middleware{requireRole(roleViewer)}},
+	"/sent": {handler: func(r *Request) error {
+		return r.Reply(sentReply(r.Args))
+	}, middleware: []Middleware{requireRole(roleAdmin)}},
+	"/preview":  {handler: previewReply, middleware: []Middleware{requireRole(roleAdmin)}},
+	"/backfill": {handler: backfillReply, middleware: []Middleware{requireRole(roleAdmin)}},
+	"/image":    {handler: imageReply, middleware: []Middleware{requireRole(roleAdmin)}},
+	"/donate": {handler: func(r *Request) error {
+		return r.Reply(donateText())
+	}},
+	"/admin": {handler: func(r *Request) error {
+		return r.Reply(adminReply(r.Payload))
+	}, middleware: []Middleware{requireRole(roleOwner)}},
+	"/reload": {handler: func(r *Request) error {
+		return r.Reply(describeConfig(reloadConfig()))
+	}, middleware: []Middleware{requireRole(roleAdmin)}},
+	"/quit": {handler: func(r *Request) error {
+		if removeUser(r.ChatID()) {
+			return r.Reply("❌ Bạn đã hủy đăng ký nhận bản tin thành công. Hẹn gặp lại!")
+		}
+		return r.Reply("ℹ️ Bạn hiện chưa đăng ký nhận bản tin hoặc đã hủy trước đó.")
+	}},
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// routerTest is a database-less bot whose only admin is ADMIN_CHAT_ID 99, with the dedup
+// and rate-limit state cleared
+func routerTest(t *testing.T) (*tele.Bot, *fakeBotAPI) {
+	t.Helper()
+	withDatabase(t, false, nil)
+	t.Setenv("ADMIN_CHAT_ID", "99")
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, vnLocation)
+	savedClock := clock
+	clock = func() time.Time { return now }
+	reset := func() {
+		adminMu.Lock()
+		cachedAdmins = nil
+		adminMu.Unlock()
+		dedupMu.Lock()
+		seenUpdate, seenOrder = make(map[int]bool), nil
+		dedupMu.Unlock()
+		rateMu.Lock()
+		rateWindows = make(map[int64]*rateWindow)
+		rateMu.Unlock()
+	}
+	reset()
+	t.Cleanup(func() {
+		clock = savedClock
+		reset()
+	})
+	api := &fakeBotAPI{}
+	return newFakeBot(t, api), api
+}
+
+// sent is the text of every sendMessage api answered
+func (f *fakeBotAPI) sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var texts []string
+	for _, c := range f.calls {
+		if c.Method == "sendMessage" {
+			texts = append(texts, c.Text)
+		}
+	}
+	return texts
+}
+
+func privateMessage(chatID int64, text string) *tele.Message {
+	return &tele.Message{Chat: &tele.Chat{ID: chatID, Type: tele.ChatPrivate}, Text: text}
+}
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(r *Request) error {
+				order = append(order, name)
+				return next(r)
+			}
+		}
+	}
+	h := chain(func(*Request) error {
+		order = append(order, "handler")
+		return nil
+	}, mark("outer"), mark("inner"))
+	h(&Request{})
+	if got := strings.Join(order, ","); got != "outer,inner,handler" {
+		t.Errorf("order = %s, want outer,inner,handler", got)
+	}
+}
+
+func TestDispatchRoutes(t *testing.T) {
+	b, api := routerTest(t)
+	b.Me = &tele.User{Username: "MarketBot"}
+
+	dispatchCommand(b, 0, privateMessage(7, "/help"))
+	dispatchCommand(b, 0, privateMessage(7, "/report@OtherBot"))
+	dispatchCommand(b, 0, privateMessage(7, "/xyzzyplugh"))
+	// /admin is owner-only: other chats are told it doesn't exist
+	dispatchCommand(b, 0, privateMessage(7, "/admin list"))
+	dispatchCommand(b, 0, privateMessage(99, "/admin list"))
+
+	sent := api.sent()
+	if len(sent) != 4 {
+		t.Fatalf("sent %d replies, want 4 (the other bot's command ignored): %q", len(sent), sent)
+	}
+	if sent[0] != helpMessage {
+		t.Errorf("/help replied %q", sent[0])
+	}
+	if sent[1] != invalidCommandText || sent[2] != invalidCommandText {
+		t.Errorf("unknown and gated commands replied %q, %q", sent[1], sent[2])
+	}
+	if sent[3] == invalidCommandText {
+		t.Error("the owner was refused /admin")
+	}
+}
+
+func TestDedupMiddleware(t *testing.T) {
+	b, api := routerTest(t)
+	for range 2 {
+		dispatchCommand(b, 501, privateMessage(7, "/help"))
+	}
+	// Updates without an ID (local polling) are never deduplicated
+	for range 2 {
+		dispatchCommand(b, 0, privateMessage(7, "/help"))
+	}
+	if got := len(api.sent()); got != 3 {
+		t.Errorf("sent %d replies, want 3 (the redelivered update dropped)", got)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	b, api := routerTest(t)
+	for range commandRateMax + 3 {
+		dispatchCommand(b, 0, privateMessage(7, "/help"))
+	}
+	sent := api.sent()
+	if len(sent) != commandRateMax+1 || !strings.HasPrefix(sent[commandRateMax], "⏳") {
+		t.Fatalf("sent %d replies, want %d answered then one warning", len(sent), commandRateMax)
+	}
+	// Other chats have their own window, and a new window lets the chat back in
+	dispatchCommand(b, 0, privateMessage(8, "/help"))
+	now := clock().Add(commandRateWindow)
+	clock = func() time.Time { return now }
+	dispatchCommand(b, 0, privateMessage(7, "/help"))
+	if sent := api.sent(); len(sent) != commandRateMax+3 || sent[len(sent)-1] != helpMessage {
+		t.Errorf("after the window: %d replies, last %q", len(sent), sent[len(sent)-1])
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		text, command, payload, mention string
+	}{
+		{"/report", "/report", "", ""},
+		{"  /Report@MarketBot  gold ", "/report", "gold", "marketbot"},
+		{"/alert\ngold 2400", "/alert", "gold 2400", ""},
+		{"gold price?", "", "gold price?", ""},
+		{"", "", "", ""},
+	}
+	for _, tt := range tests {
+		command, payload, mention := parseCommand(tt.text)
+		if command != tt.command || payload != tt.payload || mention != tt.mention {
+			t.Errorf("parseCommand(%q) = %q, %q, %q; want %q, %q, %q",
+				tt.text, command, payload, mention, tt.command, tt.payload, tt.mention)
+		}
+	}
+}
+
+func TestDispatchNormalizesCommand(t *testing.T) {
+	b, api := routerTest(t)
+	b.Me = &tele.User{Username: "MarketBot"}
+	for _, text := range []string{"  /HELP@marketbot \n", "/Help", "/help\nplease"} {
+		dispatchCommand(b, 0, privateMessage(7, text))
+	}
+	for i, got := range api.sent() {
+		if got != helpMessage {
+			t.Errorf("reply %d = %q, want the help text", i, got)
+		}
+	}
+	if n := len(api.sent()); n != 3 {
+		t.Errorf("sent %d replies, want 3", n)
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    []string
+	}{
+		{"", nil},
+		{"  btc   70000 ", []string{"btc", "70000"}},
+		{`"Bitcoin ETF approval" 3`, []string{"Bitcoin ETF approval", "3"}},
+		{"“giá vàng” tăng", []string{"giá vàng", "tăng"}},
+		{`"say \"hi\"" now`, []string{`say "hi"`, "now"}},
+		{`a""b`, []string{"ab"}},
+		{`"" x`, []string{"", "x"}},
+		// An unterminated quote runs to the end instead of failing
+		{`x "open quote`, []string{"x", "open quote"}},
+		{`"trailing\`, []string{`trailing\`}},
+		{"line\nbreak", []string{"line", "break"}},
+	}
+	for _, tt := range tests {
+		if got := splitArgs(tt.payload); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", tt.payload, got, tt.want)
+		}
+	}
+}
+
+func TestStatusOpenToViewers(t *testing.T) {
+	b, api := routerTest(t)
+	adminMu.Lock()
+	cachedAdmins = map[int64]string{99: roleOwner, 7: roleViewer}
+	adminLoadedAt = clock()
+	adminMu.Unlock()
+
+	dispatchCommand(b, 0, privateMessage(7, "/status"))
+	dispatchCommand(b, 0, privateMessage(8, "/status"))
+	sent := api.sent()
+	if len(sent) != 2 {
+		t.Fatalf("sent %d replies, want 2: %q", len(sent), sent)
+	}
+	if sent[0] == invalidCommandText {
+		t.Error("a viewer was refused /status")
+	}
+	if sent[1] != invalidCommandText {
+		t.Errorf("a stranger got /status: %q", sent[1])
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// Self-check budgets: each check gets its own timeout, the whole suite is capped
+const (
+	selfCheckTimeout      = 8 * time.Second
+	selfCheckSuiteTimeout = 20 * time.Second
+)
+
+// requiredEnv lists the variables the bot cannot work without
+var requiredEnv = []string{"TELEGRAM_TOKEN", "TWELVE_DATA_API_KEY", "MONGODB_URI", "GOOGLE_SCRIPT_URL"}
+
+// CheckResult is one row of the self-check report
+type CheckResult struct {
+	Name     string
+	OK       bool
+	Detail   string
+	Duration time.Duration
+}
+
+// selfCheck is a single non-destructive validation step
+type selfCheck struct {
+	name string
+	run  func(ctx context.Context) (string, error)
+}
+
+// --- SELF CHECK ---
+
+// runSelfCheck runs every check concurrently under the suite deadline and returns the
+// results in a stable order, plus whether all of them passed
+func runSelfCheck(ctx context.Context) ([]CheckResult, bool) {
+	ctx, cancel := context.WithTimeout(ctx, selfCheckSuiteTimeout)
+	defer cancel()
+
+	checks := []selfCheck{
+		{"config", checkConfig},
+		{"mongodb", checkMongo},
+		{"twelvedata", checkTwelveData},
+		{"rss feed", checkFeed},
+		{"translation", checkTranslation},
+		{"telegram", checkTelegram},
+	}
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c selfCheck) {
+			defer wg.Done()
+			cctx, ccancel := context.WithTimeout(ctx, selfCheckTimeout)
+			defer ccancel()
+
+			start := time.Now()
+			done := make(chan CheckResult, 1)
+			go func() {
+				detail, err := c.run(cctx)
+				if err != nil {
+					done <- CheckResult{Name: c.name, Detail: err.Error()}
+					return
+				}
+				done <- CheckResult{Name: c.name, OK: true, Detail: detail}
+			}()
+			// Don't trust every client library to honor the context; stop waiting at the deadline
+			select {
+			case r := <-done:
+				results[i] = r
+			case <-cctx.Done():
+				results[i] = CheckResult{Name: c.name, Detail: "timed out"}
+			}
+			results[i].Duration = time.Since(start)
+		}(i, c)
+	}
+	wg.Wait()
+
+	ok := true
+	for _, r := range results {
+		ok = ok && r.OK
+	}
+	return results, ok
+}
+
+// formatCheckResults renders the results as a plain-text pass/fail table
+func formatCheckResults(results []CheckResult) string {
+	var sb strings.Builder
+	for _, r := range results {
+		status := "PASS"
+		if !r.OK {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&sb, "%-4s  %-12s %6dms  %s\n", status, r.Name, r.Duration.Milliseconds(), r.Detail)
+	}
+	return sb.String()
+}
+
+func checkConfig(ctx context.Context) (string, error) {
+	var missing []string
+	for _, name := range requiredEnv {
+		if os.Getenv(name) == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+	return "all required variables set", nil
+}
+
+func checkMongo(ctx context.Context) (string, error) {
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(os.Getenv("MONGODB_URI")))
+	if err != nil {
+		return "", err
+	}
+	defer client.Disconnect(context.Background())
+	if err := client.Ping(ctx, nil); err != nil {
+		return "", err
+	}
+
+	cursor, err := client.Database("market_bot").Collection(activeProfile().collectionName("users")).Indexes().List(ctx)
+	if err != nil {
+		return "", err
+	}
+	var indexes []bson.M
+	if err := cursor.All(ctx, &indexes); err != nil {
+		return "", err
+	}
+	for _, idx := range indexes {
+		if key, ok := idx["key"].(bson.M); ok {
+			if _, ok := key["chat_id"]; ok {
+				return "connected, chat_id index present", nil
+			}
+		}
+	}
+	return "", fmt.Errorf("connected but chat_id index is missing")
+}
+
+func checkTwelveData(ctx context.Context) (string, error) {
+	// /api_usage doesn't consume credits
+	apiUrl := twelveDataURL("api_usage", url.Values{"apikey": {os.Getenv("TWELVE_DATA_API_KEY")}})
+	countAPICall("api_usage")
+	body, err := selfCheckGet(ctx, apiUrl, jsonContentTypes)
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(body, `"status":"error"`) {
+		return "", fmt.Errorf("api error: %s", truncateForLog(body, 120))
+	}
+	return "api key accepted", nil
+}
+
+func checkFeed(ctx context.Context) (string, error) {
+	body, err := selfCheckGet(ctx, configFromEnv().FeedURL, feedContentTypes)
+	if err != nil {
+		return "", err
+	}
+	if !strings.Contains(body, "<rss") && !strings.Contains(body, "<feed") {
+		return "", fmt.Errorf("response is not an RSS feed")
+	}
+	return "feed reachable", nil
+}
+
+func checkTranslation(ctx context.Context) (string, error) {
+	scriptURL := os.Getenv("GOOGLE_SCRIPT_URL")
+	if scriptURL == "" {
+		return "", fmt.Errorf("GOOGLE_SCRIPT_URL not set")
+	}
+	body, err := selfCheckGet(ctx, fmt.Sprintf("%s?text=%s&source=en&target=vi", scriptURL, url.QueryEscape("gold")), textContentTypes)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("translated %q", truncateForLog(body, 40)), nil
+}
+
+func checkTelegram(ctx context.Context) (string, error) {
+	// NewBot performs getMe unless Offline is set
+	b, err := newBot(tele.Settings{Token: os.Getenv("TELEGRAM_TOKEN"), Synchronous: true})
+	if err != nil {
+		return "", err
+	}
+	return "@" + b.Me.Username, nil
+}
+
+// selfCheckGet performs a bounded GET through the shared fetch helper
+func selfCheckGet(ctx context.Context, rawURL string, contentTypes []string) (string, error) {
+	body, err := fetchBody(ctx, rawURL, selfCheckTimeout, contentTypes)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// truncateForLog shortens s to at most n runes for log and status output
+func truncateForLog(s string, n int) string {
+	r := []rune(strings.TrimSpace(s))
+	if len(r) <= n {
+		return string(r)
+	}
+	return string(r[:n]) + "…"
+}
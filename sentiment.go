@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Headline sentiment tags stored on archived items
+const (
+	sentimentPositive = "positive"
+	sentimentNegative = "negative"
+	sentimentNeutral  = "neutral"
+)
+
+// sentimentTrendDays is how many days the weekly trend covers
+const sentimentTrendDays = 7
+
+// sentimentBarWidth is the longest bar in the trend, for the busiest day
+const sentimentBarWidth = 8
+
+// Finance headline cues. Single words match as word prefixes ("surg" covers surge, surged,
+// surging); phrases match anywhere in the title.
+var (
+	positiveCues = []string{"surg", "rall", "gain", "soar", "jump", "rebound", "climb", "rise", "rising", "rose",
+		"record high", "beat", "boom", "upgrade", "tăng", "phục hồi", "kỷ lục", "khởi sắc", "bứt phá"}
+	negativeCues = []string{"fall", "fell", "drop", "plung", "slump", "crash", "loss", "declin", "fear", "recession",
+		"sell-off", "selloff", "tumbl", "sink", "sank", "downgrade", "crisis", "default", "giảm", "lao dốc", "sụt",
+		"khủng hoảng", "bán tháo", "thua lỗ"}
+)
+
+// newsSentimentCollection holds one counter document per Vietnam day, bumped as new items
+// are archived so the weekly trend never scans the archive
+var newsSentimentCollection *mongo.Collection
+
+// sentimentDay is one day's archived headline counts by tag
+type sentimentDay struct {
+	Day      string `bson:"_id"`
+	Positive int    `bson:"positive"`
+	Negative int    `bson:"negative"`
+	Neutral  int    `bson:"neutral"`
+}
+
+// --- NEWS SENTIMENT ---
+
+// countCues counts the cues in a lower-cased title
+func countCues(title string, words []string, cues []string) int {
+	n := 0
+	for _, cue := range cues {
+		if strings.Contains(cue, " ") {
+			if strings.Contains(title, cue) {
+				n++
+			}
+			continue
+		}
+		for _, w := range words {
+			if strings.HasPrefix(w, cue) {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+// headlineSentiment tags a headline from its original and translated titles by which cues
+// outnumber the other
+func headlineSentiment(titles ...string) string {
+	score := 0
+	for _, t := range titles {
+		t = strings.ToLower(t)
+		words := strings.FieldsFunc(t, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' })
+		score += countCues(t, words, positiveCues) - countCues(t, words, negativeCues)
+	}
+	switch {
+	case score > 0:
+		return sentimentPositive
+	case score < 0:
+		return sentimentNegative
+	}
+	return sentimentNeutral
+}
+
+// sentimentDayKey is the counter document for the Vietnam day containing t
+func sentimentDayKey(t time.Time) string {
+	return t.In(vnLocation).Format("2006-01-02")
+}
+
+// countSentiments adds newly archived items' tags to the day's counters
+func countSentiments(at time.Time, tags []string) {
+	if newsSentimentCollection == nil || len(tags) == 0 {
+		return
+	}
+	inc := bson.M{}
+	for _, tag := range tags {
+		n, _ := inc[tag].(int)
+		inc[tag] = n + 1
+	}
+	_, err := newsSentimentCollection.UpdateOne(context.TODO(), bson.M{"_id": sentimentDayKey(at)},
+		bson.M{"$inc": inc}, options.Update().SetUpsert(true))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to count headline sentiment: %v", err)
+	}
+}
+
+// loadSentimentWeek returns the counters for the sentimentTrendDays days ending with now's
+// day, oldest first; days without archived items are zero
+func loadSentimentWeek(now time.Time) []sentimentDay {
+	days := make([]sentimentDay, sentimentTrendDays)
+	index := make(map[string]int, sentimentTrendDays)
+	for i := range days {
+		key := sentimentDayKey(now.AddDate(0, 0, i-(sentimentTrendDays-1)))
+		days[i].Day = key
+		index[key] = i
+	}
+	if newsSentimentCollection == nil {
+		return days
+	}
+	cursor, err := newsSentimentCollection.Find(context.TODO(), bson.M{"_id": bson.M{"$gte": days[0].Day, "$lte": days[len(days)-1].Day}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load headline sentiment: %v", err)
+		return days
+	}
+	var stored []sentimentDay
+	if err := cursor.All(context.TODO(), &stored); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to decode headline sentiment: %v", err)
+		return days
+	}
+	for _, d := range stored {
+		if i, ok := index[d.Day]; ok {
+			days[i] = d
+		}
+	}
+	return days
+}
+
+// sentimentBar draws n against the week's busiest count
+func sentimentBar(n, busiest int, full string) string {
+	if busiest == 0 {
+		return ""
+	}
+	width := (n*sentimentBarWidth + busiest - 1) / busiest
+	return strings.Repeat(full, width) + strings.Repeat("░", sentimentBarWidth-width)
+}
+
+// formatSentimentTrend renders a week of counters as paired bars (positive over negative)
+// per day, then the most positive and most negative days by net count. Days without
+// items show empty bars and are never called out.
+func formatSentimentTrend(days []sentimentDay) string {
+	busiest := 0
+	for _, d := range days {
+		busiest = max(busiest, d.Positive, d.Negative)
+	}
+	if busiest == 0 {
+		return "📰 Xu hướng tin tức: chưa có tiêu đề nào được lưu trong tuần."
+	}
+	var sb strings.Builder
+	sb.WriteString("📰 Xu hướng tin tức (tích cực ▲ / tiêu cực ▼):\n")
+	best, worst := -1, -1
+	for i, d := range days {
+		t, _ := time.ParseInLocation("2006-01-02", d.Day, vnLocation)
+		fmt.Fprintf(&sb, "%s\n  ▲ %s %d\n  ▼ %s %d\n", formatDay(t, newsLangVI, vnLocation),
+			sentimentBar(d.Positive, busiest, "█"), d.Positive, sentimentBar(d.Negative, busiest, "▓"), d.Negative)
+		if d.Positive+d.Negative+d.Neutral == 0 {
+			continue
+		}
+		net := d.Positive - d.Negative
+		if best < 0 || net > days[best].Positive-days[best].Negative {
+			best = i
+		}
+		if worst < 0 || net < days[worst].Positive-days[worst].Negative {
+			worst = i
+		}
+	}
+	dayName := func(i int) string {
+		t, _ := time.ParseInLocation("2006-01-02", days[i].Day, vnLocation)
+		return formatDay(t, newsLangVI, vnLocation)
+	}
+	fmt.Fprintf(&sb, "• Tích cực nhất: %s (%+d)\n", dayName(best), days[best].Positive-days[best].Negative)
+	fmt.Fprintf(&sb, "• Tiêu cực nhất: %s (%+d)", dayName(worst), days[worst].Positive-days[worst].Negative)
+	return sb.String()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeadlineSentiment(t *testing.T) {
+	tests := []struct {
+		titles []string
+		want   string
+	}{
+		{[]string{"Gold surges to record high as dollar weakens"}, sentimentPositive},
+		{[]string{"Stocks tumble as recession fears grow"}, sentimentNegative},
+		{[]string{"Fed holds rates steady"}, sentimentNeutral},
+		{[]string{"Bitcoin rallies, then falls back"}, sentimentNeutral},
+		// Prefixes match whole words only: "surprise" isn't "rise", "shrine" isn't "rise"
+		{[]string{"Surprise move by the central bank"}, sentimentNeutral},
+		{[]string{"Oil prices drop", "Giá dầu giảm mạnh"}, sentimentNegative},
+		{[]string{"Markets mixed", "Chứng khoán khởi sắc, VN-Index tăng điểm"}, sentimentPositive},
+		{[]string{"Sell-off deepens in tech"}, sentimentNegative},
+		{nil, sentimentNeutral},
+	}
+	for _, tt := range tests {
+		if got := headlineSentiment(tt.titles...); got != tt.want {
+			t.Errorf("headlineSentiment(%q) = %s, want %s", tt.titles, got, tt.want)
+		}
+	}
+}
+
+func TestLoadSentimentWeekWithoutDatabase(t *testing.T) {
+	saved := newsSentimentCollection
+	newsSentimentCollection = nil
+	t.Cleanup(func() { newsSentimentCollection = saved })
+	// 23:30 UTC on the 1st is the 2nd in Vietnam
+	days := loadSentimentWeek(time.Date(2026, 3, 1, 23, 30, 0, 0, time.UTC))
+	if len(days) != sentimentTrendDays || days[0].Day != "2026-02-24" || days[6].Day != "2026-03-02" {
+		t.Errorf("week = %+v, want 2026-02-24 through 2026-03-02", days)
+	}
+}
+
+func TestSentimentBar(t *testing.T) {
+	tests := []struct {
+		n, busiest int
+		want       string
+	}{
+		{8, 8, "████████"},
+		{0, 8, "░░░░░░░░"},
+		{1, 8, "█░░░░░░░"},
+		// Any count gets at least one block
+		{1, 100, "█░░░░░░░"},
+		{0, 0, ""},
+	}
+	for _, tt := range tests {
+		if got := sentimentBar(tt.n, tt.busiest, "█"); got != tt.want {
+			t.Errorf("sentimentBar(%d, %d) = %q, want %q", tt.n, tt.busiest, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSentimentTrend(t *testing.T) {
+	days := []sentimentDay{
+		{Day: "2026-03-02", Positive: 4, Negative: 1},
+		{Day: "2026-03-03"},
+		{Day: "2026-03-04", Positive: 1, Negative: 6, Neutral: 2},
+		{Day: "2026-03-05", Neutral: 3},
+	}
+	got := formatSentimentTrend(days)
+	for _, want := range []string{
+		"Thứ Hai, 02/03\n  ▲ ██████░░ 4\n  ▼ ▓▓░░░░░░ 1\n",
+		"Thứ Ba, 03/03\n  ▲ ░░░░░░░░ 0\n",
+		"• Tích cực nhất: Thứ Hai, 02/03 (+3)",
+		"• Tiêu cực nhất: Thứ Tư, 04/03 (-5)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("trend is missing %q:\n%s", want, got)
+		}
+	}
+
+	empty := formatSentimentTrend([]sentimentDay{{Day: "2026-03-02"}, {Day: "2026-03-03", Neutral: 2}})
+	if !strings.Contains(empty, "chưa có tiêu đề") {
+		t.Errorf("a week without tagged items = %q", empty)
+	}
+}
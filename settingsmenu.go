@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// settingsRevField counts the writes to a user's settings. Every settings write bumps it,
+// and the /settings menu saves only against the revision it opened with.
+const settingsRevField = "rev"
+
+// settingsSaveAttempts bounds the re-read and merge rounds of one save that keeps losing
+// the compare-and-swap to other writes
+const settingsSaveAttempts = 3
+
+// settingsConflictText is shown when the same setting was changed elsewhere meanwhile
+const settingsConflictText = "⚠️ Cài đặt đã thay đổi ở nơi khác, thử lại."
+
+// settingField is one setting of the /settings menu. Values are the stored values, in
+// menu order; a Bool field is stored as true/false and shown as off/on.
+type settingField struct {
+	Key    string
+	Label  string
+	Values []string
+	Names  []string
+	Bool   bool
+}
+
+// settingFields is the menu, in order. The menu's callback data encodes a full set of
+// values as one digit per field (the value's index), so fields are only ever appended.
+var settingFields = []settingField{
+	{Key: "news_mode", Label: "Kiểu tin tức", Values: []string{newsModeList, newsModeCards, newsModeSplit}, Names: []string{"Danh sách", "Thẻ riêng", "Tách tin"}},
+	{Key: "silent", Label: "Bản tin im lặng", Values: []string{silentAuto, silentOn, silentOff}, Names: []string{"Theo giờ yên tĩnh", "Luôn im lặng", "Luôn có thông báo"}},
+	{Key: "pin_report", Label: "Ghim bản tin", Values: []string{"off", "on"}, Names: []string{"Tắt", "Bật"}, Bool: true},
+	{Key: "plain_text", Label: "Văn bản thuần", Values: []string{"off", "on"}, Names: []string{"Tắt", "Bật"}, Bool: true},
+	{Key: "number_style", Label: "Định dạng số", Values: []string{numberStyleVN, numberStyleIntl}, Names: []string{"1.234,5", "1,234.5"}},
+}
+
+// settingValues maps a setting's key to its value; as a change set it holds only the
+// fields that changed
+type settingValues map[string]string
+
+// settingsMenuState is everything the menu shows, carried in its buttons' callback data:
+// the revision and values it opened with, the edits so far and the open submenu (-1 for
+// the main menu)
+type settingsMenuState struct {
+	Rev    int64
+	Base   settingValues
+	Draft  settingValues
+	Open   int
+	Notice string
+}
+
+// --- SETTINGS MENU ---
+
+// encodeSettings packs values as one digit per field
+func encodeSettings(v settingValues) string {
+	var sb strings.Builder
+	for _, f := range settingFields {
+		i := 0
+		for j, value := range f.Values {
+			if value == v[f.Key] {
+				i = j
+			}
+		}
+		sb.WriteByte(byte('0' + i))
+	}
+	return sb.String()
+}
+
+// decodeSettings unpacks encodeSettings; false when the code doesn't fit the fields
+func decodeSettings(code string) (settingValues, bool) {
+	if len(code) != len(settingFields) {
+		return nil, false
+	}
+	v := make(settingValues, len(settingFields))
+	for i, f := range settingFields {
+		idx := int(code[i] - '0')
+		if idx < 0 || idx >= len(f.Values) {
+			return nil, false
+		}
+		v[f.Key] = f.Values[idx]
+	}
+	return v, true
+}
+
+// diffSettings returns the fields whose value in next differs from base
+func diffSettings(base, next settingValues) settingValues {
+	changes := settingValues{}
+	for field, v := range next {
+		if base[field] != v {
+			changes[field] = v
+		}
+	}
+	return changes
+}
+
+// mergeSettings combines my edits with the changes saved elsewhere since the menu opened,
+// both as change sets against base. A field changed on both sides to different values is
+// a conflict; the same change on both sides is already stored. write is what my save
+// still has to store and merged the resulting settings.
+func mergeSettings(base, mine, theirs settingValues) (merged, write settingValues, conflicts []string) {
+	merged, write = settingValues{}, settingValues{}
+	for field, v := range base {
+		merged[field] = v
+	}
+	for field, v := range theirs {
+		merged[field] = v
+	}
+	for field, v := range mine {
+		t, changedThere := theirs[field]
+		switch {
+		case changedThere && t != v:
+			conflicts = append(conflicts, field)
+		case !changedThere:
+			write[field] = v
+			merged[field] = v
+		}
+	}
+	sort.Strings(conflicts)
+	return merged, write, conflicts
+}
+
+// settingsDoc is the part of a user document the menu edits
+type settingsDoc struct {
+	Rev         int64  `bson:"rev"`
+	NewsMode    string `bson:"news_mode"`
+	Silent      string `bson:"silent"`
+	PinReport   bool   `bson:"pin_report"`
+	PlainText   bool   `bson:"plain_text"`
+	NumberStyle string `bson:"number_style"`
+}
+
+// values reads the document as menu values, absent fields as their defaults
+func (d settingsDoc) values() settingValues {
+	v, _ := decodeSettings(encodeSettings(settingValues{
+		"news_mode": d.NewsMode, "silent": d.Silent, "pin_report": onOff(d.PinReport),
+		"plain_text": onOff(d.PlainText), "number_style": d.NumberStyle,
+	}))
+	return v
+}
+
+// loadSettings returns the chat's settings and their revision; false when not subscribed
+func loadSettings(chatID int64) (settingValues, int64, bool) {
+	if userCollection == nil {
+		return nil, 0, false
+	}
+	var doc settingsDoc
+	err := userCollection.FindOne(context.TODO(), bson.M{"chat_id": chatID},
+		options.FindOne().SetProjection(bson.M{settingsRevField: 1, "news_mode": 1, "silent": 1, "pin_report": 1, "plain_text": 1, "number_style": 1})).Decode(&doc)
+	if err != nil {
+		return nil, 0, false
+	}
+	return doc.values(), doc.Rev, true
+}
+
+// revFilter matches the chat's document at revision rev; documents from before the counter
+// existed are at revision 0
+func revFilter(chatID, rev int64) bson.M {
+	if rev == 0 {
+		return bson.M{"chat_id": chatID, "$or": bson.A{bson.M{settingsRevField: 0}, bson.M{settingsRevField: bson.M{"$exists": false}}}}
+	}
+	return bson.M{"chat_id": chatID, settingsRevField: rev}
+}
+
+// casSettings stores changes only if the document is still at rev, bumping the revision
+func casSettings(chatID, rev int64, changes settingValues) (bool, error) {
+	set := bson.M{"updated_at": clock()}
+	for _, f := range settingFields {
+		if v, ok := changes[f.Key]; ok {
+			if f.Bool {
+				set[f.Key] = v == "on"
+			} else {
+				set[f.Key] = v
+			}
+		}
+	}
+	res, err := userCollection.UpdateOne(context.TODO(), revFilter(chatID, rev),
+		bson.M{"$set": set, "$inc": bson.M{settingsRevField: 1}})
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+// saveSettings stores the menu's edits with optimistic concurrency. While the document is
+// still at the menu's revision the edits go in as they are; otherwise the settings are
+// re-read and my edits merged with what changed meanwhile. The returned state is the menu
+// to show next: closed on success, reopened on the stored settings (my non-conflicting
+// edits kept) with a notice when a field truly conflicts.
+func saveSettings(chatID int64, st settingsMenuState) (settingsMenuState, bool) {
+	mine := diffSettings(st.Base, st.Draft)
+	for attempt := 0; attempt < settingsSaveAttempts; attempt++ {
+		current, rev, ok := loadSettings(chatID)
+		if !ok {
+			return settingsMenuState{Notice: "ℹ️ Bạn cần đăng ký bằng /start trước."}, false
+		}
+		theirs := settingValues{}
+		if rev != st.Rev {
+			theirs = diffSettings(st.Base, current)
+		}
+		merged, write, conflicts := mergeSettings(st.Base, mine, theirs)
+		if len(conflicts) > 0 {
+			draft := settingValues{}
+			for field, v := range merged {
+				draft[field] = v
+			}
+			for field, v := range write {
+				draft[field] = v
+			}
+			return settingsMenuState{Rev: rev, Base: current, Draft: draft, Open: -1, Notice: settingsConflictText}, false
+		}
+		if len(write) == 0 {
+			return settingsMenuState{Base: merged, Draft: merged, Open: -1, Notice: "✅ Không có gì thay đổi."}, true
+		}
+		stored, err := casSettings(chatID, rev, write)
+		if err != nil {
+			log.Printf("[DATABASE ERROR] Failed to save settings for %d: %v", chatID, err)
+			return settingsMenuState{Rev: st.Rev, Base: st.Base, Draft: st.Draft, Open: -1, Notice: "⚠️ Không thể lưu lúc này."}, false
+		}
+		if stored {
+			return settingsMenuState{Base: merged, Draft: merged, Open: -1, Notice: "✅ Đã lưu cài đặt."}, true
+		}
+		// Another write landed between the read and the swap; merge against it
+	}
+	current, rev, _ := loadSettings(chatID)
+	return settingsMenuState{Rev: rev, Base: current, Draft: current, Open: -1, Notice: settingsConflictText}, false
+}
+
+// settingName shows a field's value
+func settingName(f settingField, value string) string {
+	for i, v := range f.Values {
+		if v == value {
+			return f.Names[i]
+		}
+	}
+	return value
+}
+
+// settingsData is a menu button's callback data: the state the button leads to
+func settingsData(st settingsMenuState, op string) []string {
+	return []string{strconv.FormatInt(st.Rev, 10), encodeSettings(st.Base), encodeSettings(st.Draft), op}
+}
+
+// renderSettingsMenu draws the menu for st. The main menu lists every field (edited ones
+// marked ✏️) with Lưu and Hủy; a field's submenu lists its values. A closed menu (after
+// saving or cancelling) has no buttons.
+func renderSettingsMenu(st settingsMenuState, closed bool) (string, *tele.ReplyMarkup) {
+	var sb strings.Builder
+	sb.WriteString("⚙️ *Cài đặt của bạn*\n")
+	for _, f := range settingFields {
+		mark := ""
+		if st.Draft[f.Key] != st.Base[f.Key] {
+			mark = " ✏️"
+		}
+		fmt.Fprintf(&sb, "• %s: %s%s\n", f.Label, settingName(f, st.Draft[f.Key]), mark)
+	}
+	cfg := loadConfig()
+	fmt.Fprintf(&sb, "_Giờ yên tĩnh %02d:00–%02d:00._", cfg.QuietStart, cfg.QuietEnd)
+	if st.Notice != "" {
+		sb.WriteString("\n" + st.Notice)
+	}
+	if closed {
+		return sb.String(), nil
+	}
+	menu := &tele.ReplyMarkup{}
+	var rows []tele.Row
+	if st.Open >= 0 && st.Open < len(settingFields) {
+		f := settingFields[st.Open]
+		for _, v := range f.Values {
+			next := st
+			next.Draft = settingValues{}
+			for field, value := range st.Draft {
+				next.Draft[field] = value
+			}
+			next.Draft[f.Key] = v
+			label := settingName(f, v)
+			if v == st.Draft[f.Key] {
+				label = "✅ " + label
+			}
+			rows = append(rows, menu.Row(menu.Data(label, "btn_cfg", settingsData(next, "m")...)))
+		}
+		rows = append(rows, menu.Row(menu.Data("⬅️ Quay lại", "btn_cfg", settingsData(st, "m")...)))
+	} else {
+		for i, f := range settingFields {
+			rows = append(rows, menu.Row(menu.Data(f.Label, "btn_cfg", settingsData(st, "f"+strconv.Itoa(i))...)))
+		}
+		rows = append(rows, menu.Row(
+			menu.Data("💾 Lưu", "btn_cfg", settingsData(st, "s")...),
+			menu.Data("✖️ Hủy", "btn_cfg", settingsData(st, "x")...),
+		))
+	}
+	menu.Inline(rows...)
+	return sb.String(), menu
+}
+
+// settingsMenuFor opens the menu on the chat's stored settings
+func settingsMenuFor(chatID int64) (string, *tele.ReplyMarkup) {
+	values, rev, ok := loadSettings(chatID)
+	if !ok {
+		return "ℹ️ Bạn cần đăng ký bằng /start trước.", nil
+	}
+	return renderSettingsMenu(settingsMenuState{Rev: rev, Base: values, Draft: values, Open: -1}, false)
+}
+
+// parseSettingsData reads a menu button's callback data back into the state and operation
+func parseSettingsData(data string) (settingsMenuState, string, bool) {
+	parts := strings.Split(data, "|")
+	if len(parts) != 4 {
+		return settingsMenuState{}, "", false
+	}
+	rev, err := strconv.ParseInt(parts[0], 10, 64)
+	base, okBase := decodeSettings(parts[1])
+	draft, okDraft := decodeSettings(parts[2])
+	if err != nil || !okBase || !okDraft {
+		return settingsMenuState{}, "", false
+	}
+	return settingsMenuState{Rev: rev, Base: base, Draft: draft, Open: -1}, parts[3], true
+}
+
+// handleSettingsCallback handles the menu's buttons by editing the menu message; the
+// returned text is the callback toast
+func handleSettingsCallback(b *tele.Bot, cb *tele.Callback, data string) string {
+	if cb.Message == nil || cb.Message.Chat == nil {
+		return "Tin nhắn đã quá cũ, vui lòng gõ /settings."
+	}
+	st, op, ok := parseSettingsData(data)
+	if !ok {
+		return "⚠️ Menu không hợp lệ, vui lòng gõ /settings."
+	}
+	closed, toast := false, ""
+	switch {
+	case strings.HasPrefix(op, "f"):
+		st.Open, _ = strconv.Atoi(op[1:])
+	case op == "s":
+		var saved bool
+		st, saved = saveSettings(cb.Message.Chat.ID, st)
+		closed, toast = saved, st.Notice
+	case op == "x":
+		st.Draft, st.Notice, closed = st.Base, "Đã hủy, không có gì thay đổi.", true
+	}
+	text, menu := renderSettingsMenu(st, closed)
+	_, err := b.Edit(cb.Message, text, &tele.SendOptions{ParseMode: tele.ModeMarkdown, ReplyMarkup: menu})
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		log.Printf("[SETTINGS ERROR] Failed to update the menu in %d: %v", cb.Message.Chat.ID, err)
+	}
+	return toast
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testSettings() settingValues {
+	return settingValues{"news_mode": newsModeList, "silent": silentAuto, "pin_report": "off", "plain_text": "off", "number_style": numberStyleVN}
+}
+
+func TestMergeSettings(t *testing.T) {
+	tests := []struct {
+		name      string
+		mine      settingValues
+		theirs    settingValues
+		merged    settingValues
+		write     settingValues
+		conflicts []string
+	}{
+		{
+			name:   "nothing changed elsewhere",
+			mine:   settingValues{"silent": silentOn},
+			theirs: settingValues{},
+			merged: settingValues{"news_mode": newsModeList, "silent": silentOn, "pin_report": "off", "plain_text": "off", "number_style": numberStyleVN},
+			write:  settingValues{"silent": silentOn},
+		},
+		{
+			name:   "different fields merge",
+			mine:   settingValues{"silent": silentOn, "pin_report": "on"},
+			theirs: settingValues{"news_mode": newsModeCards},
+			merged: settingValues{"news_mode": newsModeCards, "silent": silentOn, "pin_report": "on", "plain_text": "off", "number_style": numberStyleVN},
+			write:  settingValues{"silent": silentOn, "pin_report": "on"},
+		},
+		{
+			name:   "the same change on both sides is already stored",
+			mine:   settingValues{"number_style": numberStyleIntl, "plain_text": "on"},
+			theirs: settingValues{"number_style": numberStyleIntl},
+			merged: settingValues{"news_mode": newsModeList, "silent": silentAuto, "pin_report": "off", "plain_text": "on", "number_style": numberStyleIntl},
+			write:  settingValues{"plain_text": "on"},
+		},
+		{
+			name:      "the same field changed to different values conflicts",
+			mine:      settingValues{"silent": silentOn, "pin_report": "on"},
+			theirs:    settingValues{"silent": silentOff},
+			merged:    settingValues{"news_mode": newsModeList, "silent": silentOff, "pin_report": "on", "plain_text": "off", "number_style": numberStyleVN},
+			write:     settingValues{"pin_report": "on"},
+			conflicts: []string{"silent"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, write, conflicts := mergeSettings(testSettings(), tt.mine, tt.theirs)
+			if !reflect.DeepEqual(merged, tt.merged) {
+				t.Errorf("merged = %v, want %v", merged, tt.merged)
+			}
+			if !reflect.DeepEqual(write, tt.write) {
+				t.Errorf("write = %v, want %v", write, tt.write)
+			}
+			if !reflect.DeepEqual(conflicts, tt.conflicts) {
+				t.Errorf("conflicts = %v, want %v", conflicts, tt.conflicts)
+			}
+		})
+	}
+}
+
+func TestDiffSettings(t *testing.T) {
+	next := testSettings()
+	next["silent"], next["number_style"] = silentOff, numberStyleIntl
+	want := settingValues{"silent": silentOff, "number_style": numberStyleIntl}
+	if got := diffSettings(testSettings(), next); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffSettings = %v, want %v", got, want)
+	}
+	if got := diffSettings(testSettings(), testSettings()); len(got) != 0 {
+		t.Errorf("unchanged settings diff to %v", got)
+	}
+}
+
+func TestSettingsEncoding(t *testing.T) {
+	v := settingValues{"news_mode": newsModeSplit, "silent": silentOff, "pin_report": "on", "plain_text": "off", "number_style": numberStyleIntl}
+	code := encodeSettings(v)
+	if code != "22101" {
+		t.Errorf("encodeSettings = %q, want 22101", code)
+	}
+	if got, ok := decodeSettings(code); !ok || !reflect.DeepEqual(got, v) {
+		t.Errorf("decodeSettings(%q) = %v, %v", code, got, ok)
+	}
+	for _, bad := range []string{"", "2210", "221019", "92101"} {
+		if _, ok := decodeSettings(bad); ok {
+			t.Errorf("decodeSettings(%q) accepted", bad)
+		}
+	}
+	// Missing fields read as their defaults
+	if got := (settingsDoc{}).values(); !reflect.DeepEqual(got, testSettings()) {
+		t.Errorf("empty document reads as %v", got)
+	}
+}
+
+func TestSettingsCallbackFits(t *testing.T) {
+	v := testSettings()
+	st := settingsMenuState{Rev: 1 << 40, Base: v, Draft: v}
+	for _, op := range []string{"f4", "m", "s", "x"} {
+		data := settingsData(st, op)
+		if !fitsCallback("btn_cfg", data...) {
+			t.Errorf("callback data %v is over %d bytes", data, maxCallbackData)
+		}
+		got, gotOp, ok := parseSettingsData(strings.Join(data, "|"))
+		if !ok || gotOp != op || got.Rev != st.Rev || !reflect.DeepEqual(got.Draft, v) {
+			t.Errorf("parseSettingsData round trip for %q = %+v, %q, %v", op, got, gotOp, ok)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Per-user silent broadcast preference; "auto" follows the schedule slot and quiet hours
+const (
+	silentAuto = "auto"
+	silentOn   = "on"
+	silentOff  = "off"
+)
+
+// --- SILENT BROADCASTS ---
+
+// parseQuietHours parses "22-7" (VN-time hours, end exclusive) into its bounds
+func parseQuietHours(raw string) (int, int, bool) {
+	a, b, ok := strings.Cut(strings.TrimSpace(raw), "-")
+	if !ok {
+		return 0, 0, false
+	}
+	start, err1 := strconv.Atoi(strings.TrimSpace(a))
+	end, err2 := strconv.Atoi(strings.TrimSpace(b))
+	if err1 != nil || err2 != nil || start < 0 || start > 23 || end < 0 || end > 23 {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// inQuietHours reports whether now falls in [start, end) Vietnam time; the window may wrap
+// past midnight, and start == end disables it
+func inQuietHours(now time.Time, start, end int) bool {
+	h := now.In(vnLocation).Hour()
+	if start <= end {
+		return h >= start && h < end
+	}
+	return h >= start || h < end
+}
+
+// broadcastSilent decides disable_notification for one recipient: the user's preference wins,
+// then the schedule slot's flag, then the quiet hours
+func broadcastSilent(pref string, slot *bool, quiet bool) bool {
+	switch pref {
+	case silentOn:
+		return true
+	case silentOff:
+		return false
+	}
+	if slot != nil {
+		return *slot
+	}
+	return quiet
+}
+
+// loadSilentPrefs returns the chats with an explicit on/off preference
+func loadSilentPrefs() map[int64]string {
+	prefs := make(map[int64]string)
+	if userCollection == nil {
+		return prefs
+	}
+	cursor, err := userCollection.Find(context.TODO(), bson.M{"silent": bson.M{"$in": []string{silentOn, silentOff}}},
+		options.Find().SetProjection(bson.M{"chat_id": 1, "silent": 1}))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load silent preferences: %v", err)
+		return prefs
+	}
+	defer cursor.Close(context.TODO())
+	for cursor.Next(context.TODO()) {
+		var result struct {
+			ChatID int64  `bson:"chat_id"`
+			Silent string `bson:"silent"`
+		}
+		if cursor.Decode(&result) == nil {
+			prefs[result.ChatID] = result.Silent
+		}
+	}
+	return prefs
+}
+
+// getSilentPref returns a chat's silent preference
+func getSilentPref(chatID int64) string {
+	if userCollection == nil {
+		return silentAuto
+	}
+	var result struct {
+		Silent string `bson:"silent"`
+	}
+	userCollection.FindOne(context.TODO(), bson.M{"chat_id": chatID},
+		options.FindOne().SetProjection(bson.M{"silent": 1})).Decode(&result)
+	if result.Silent == "" {
+		return silentAuto
+	}
+	return result.Silent
+}
+
+// setSilentPref stores a chat's silent preference; false if the chat isn't subscribed
+func setSilentPref(chatID int64, pref string) bool {
+	if userCollection == nil {
+		return false
+	}
+	result, err := userCollection.UpdateOne(context.TODO(), bson.M{"chat_id": chatID},
+		bson.M{"$set": bson.M{"silent": pref, "updated_at": clock()}, "$inc": bson.M{settingsRevField: 1}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to save silent preference for %d: %v", chatID, err)
+		return false
+	}
+	return result.MatchedCount > 0
+}
+
+// settingsReply handles "/settings silent on|off|auto" and "/settings pin on|off"; a bare
+// "/settings" opens the menu (settingsmenu.go)
+func settingsReply(chatID int64, payload string) string {
+	args := strings.Fields(strings.ToLower(payload))
+	if len(args) == 2 && args[0] == "pin" {
+		return pinSettingReply(chatID, args[1])
+	}
+	if len(args) != 2 || args[0] != "silent" {
+		return "ℹ️ Cú pháp: /settings, /settings silent on|off|auto hoặc /settings pin on|off"
+	}
+	switch args[1] {
+	case silentOn, silentOff, silentAuto:
+	default:
+		return "ℹ️ Cú pháp: /settings silent on|off|auto"
+	}
+	if !setSilentPref(chatID, args[1]) {
+		return "ℹ️ Bạn cần đăng ký bằng /start trước."
+	}
+	switch args[1] {
+	case silentOn:
+		return "🔕 Bản tin sẽ luôn được gửi im lặng. Cảnh báo giá vẫn có thông báo."
+	case silentOff:
+		return "🔔 Bản tin sẽ luôn có thông báo."
+	default:
+		return "🌙 Bản tin được gửi im lặng trong giờ yên tĩnh."
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseQuietHours(t *testing.T) {
+	tests := []struct {
+		raw        string
+		start, end int
+		ok         bool
+	}{
+		{"22-7", 22, 7, true},
+		{" 0 - 6 ", 0, 6, true},
+		{"8-8", 8, 8, true},
+		{"22", 0, 0, false},
+		{"24-7", 0, 0, false},
+		{"22-x", 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, end, ok := parseQuietHours(tt.raw)
+		if start != tt.start || end != tt.end || ok != tt.ok {
+			t.Errorf("parseQuietHours(%q) = %d, %d, %v; want %d, %d, %v", tt.raw, start, end, ok, tt.start, tt.end, tt.ok)
+		}
+	}
+}
+
+func TestInQuietHours(t *testing.T) {
+	savedClock := clock
+	t.Cleanup(func() { clock = savedClock })
+	tests := []struct {
+		hour, minute int
+		start, end   int
+		want         bool
+	}{
+		// 22-7 wraps past midnight; the end hour is exclusive
+		{21, 59, 22, 7, false},
+		{22, 0, 22, 7, true},
+		{23, 30, 22, 7, true},
+		{0, 0, 22, 7, true},
+		{6, 59, 22, 7, true},
+		{7, 0, 22, 7, false},
+		{12, 0, 22, 7, false},
+		// A window within one day
+		{1, 0, 1, 5, true},
+		{5, 0, 1, 5, false},
+		{0, 59, 1, 5, false},
+		// start == end disables the window
+		{8, 0, 8, 8, false},
+	}
+	for _, tt := range tests {
+		now := time.Date(2026, 3, 2, tt.hour, tt.minute, 0, 0, vnLocation)
+		clock = func() time.Time { return now }
+		if got := inQuietHours(clock(), tt.start, tt.end); got != tt.want {
+			t.Errorf("%02d:%02d in %d-%d = %v, want %v", tt.hour, tt.minute, tt.start, tt.end, got, tt.want)
+		}
+	}
+	// The hour is read in Vietnam time whatever zone the clock reports: 16:00 UTC is 23:00
+	if !inQuietHours(time.Date(2026, 3, 2, 16, 0, 0, 0, time.UTC), 22, 7) {
+		t.Error("a UTC time wasn't converted to Vietnam time")
+	}
+}
+
+func TestBroadcastSilent(t *testing.T) {
+	on, off := true, false
+	tests := []struct {
+		pref  string
+		slot  *bool
+		quiet bool
+		want  bool
+	}{
+		// auto (or unset) follows the slot flag, then the quiet hours
+		{silentAuto, nil, true, true},
+		{silentAuto, nil, false, false},
+		{"", &on, false, true},
+		{silentAuto, &off, true, false},
+		// An explicit preference wins over both
+		{silentOn, &off, false, true},
+		{silentOff, &on, true, false},
+	}
+	for _, tt := range tests {
+		slot := "unset"
+		if tt.slot != nil {
+			slot = map[bool]string{true: "silent", false: "loud"}[*tt.slot]
+		}
+		if got := broadcastSilent(tt.pref, tt.slot, tt.quiet); got != tt.want {
+			t.Errorf("broadcastSilent(%q, slot %s, quiet %v) = %v, want %v", tt.pref, slot, tt.quiet, got, tt.want)
+		}
+	}
+}
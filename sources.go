@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Registered quote providers; "twelvedata" is the default chain
+const (
+	providerTwelveData = "twelvedata"
+	providerVCB        = "vcb"
+	providerCoinGecko  = "coingecko"
+)
+
+const coinGeckoPriceURL = "https://api.coingecko.com/api/v3/simple/price"
+
+// coinGeckoIDs maps canonical asset IDs onto CoinGecko coin IDs
+var coinGeckoIDs = map[string]string{
+	"btc":     "bitcoin",
+	"eth":     "ethereum",
+	"sol":     "solana",
+	"bnb/usd": "binancecoin",
+	"xrp/usd": "ripple",
+}
+
+// quoteProvider is a source a symbol can be pinned to with /source
+type quoteProvider struct {
+	Label    string
+	Supports func(id string) bool
+	Quote    func(id string) MarketData
+}
+
+var quoteProviders = map[string]quoteProvider{
+	providerTwelveData: {
+		Label:    "Twelve Data",
+		Supports: func(string) bool { return true },
+		Quote:    func(id string) MarketData { return getMarketData(id, os.Getenv("TWELVE_DATA_API_KEY")) },
+	},
+	providerVCB: {
+		Label:    "Vietcombank",
+		Supports: func(id string) bool { return id == "usdvnd" },
+		Quote:    vcbQuote,
+	},
+	providerCoinGecko: {
+		Label: "CoinGecko",
+		Supports: func(id string) bool {
+			_, ok := coinGeckoIDs[id]
+			return ok
+		},
+		Quote: coinGeckoQuote,
+	},
+}
+
+// --- QUOTE SOURCE OVERRIDES ---
+
+// validSourceOverride reports whether provider is registered and can quote id
+func validSourceOverride(id, provider string) bool {
+	p, ok := quoteProviders[provider]
+	return ok && p.Supports(id)
+}
+
+// providerLabel names a provider for users
+func providerLabel(provider string) string {
+	if p, ok := quoteProviders[provider]; ok {
+		return p.Label
+	}
+	return provider
+}
+
+// quoteOverridden quotes the symbols pinned to a provider other than the default chain.
+// Symbols whose provider fails are returned in rest, so the chain still quotes them.
+func quoteOverridden(symbols []string, overrides map[string]string) (quotes map[string]MarketData, rest []string) {
+	quotes = make(map[string]MarketData)
+	for _, id := range symbols {
+		provider := overrides[id]
+		if provider == "" || provider == providerTwelveData {
+			rest = append(rest, id)
+			continue
+		}
+		d := quoteProviders[provider].Quote(id)
+		if d.Err != nil {
+			log.Printf("[API ERROR] Source override %s for %s failed, using the default chain: %v", provider, id, d.Err)
+			countFallback(id, providerTwelveData)
+			rest = append(rest, id)
+			continue
+		}
+		d.Source = provider
+		quotes[id] = d
+	}
+	return quotes, rest
+}
+
+// sourcesNote lists the report symbols quoted from an override, for the market section
+func sourcesNote(symbols []string, quotes map[string]MarketData) string {
+	var parts []string
+	for _, id := range symbols {
+		if src := quotes[id].Source; src != "" {
+			parts = append(parts, lookupAsset(id).Label+" — "+providerLabel(src))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "\n_Nguồn riêng: " + strings.Join(parts, ", ") + "_"
+}
+
+// vcbQuote quotes USD/VND from Vietcombank (no session change)
+func vcbQuote(id string) MarketData {
+	rate, err := fetchVCBUsdVnd()
+	if err == nil && !usdVndInBand(rate) {
+		err = fmt.Errorf("rate %g outside the sanity band", rate)
+	}
+	if err != nil {
+		return MarketData{Change: "N/A", Err: err}
+	}
+	return MarketData{Price: rate, Change: "N/A"}
+}
+
+// coinGeckoQuote quotes a coin in USD with its 24-hour change from CoinGecko's free API
+func coinGeckoQuote(id string) MarketData {
+	coin := coinGeckoIDs[id]
+	apiUrl := coinGeckoPriceURL + "?" + url.Values{"ids": {coin}, "vs_currencies": {"usd"},
+		"include_24hr_change": {"true"}}.Encode()
+	body, err := fetchBody(context.Background(), apiUrl, 10*time.Second, jsonContentTypes)
+	if err != nil {
+		return MarketData{Change: "N/A", Err: err}
+	}
+	var result map[string]struct {
+		USD       float64  `json:"usd"`
+		Change24h *float64 `json:"usd_24h_change"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return MarketData{Change: "N/A", Err: err}
+	}
+	q, ok := result[coin]
+	if !ok || q.USD <= 0 {
+		return MarketData{Change: "N/A", Err: fmt.Errorf("coingecko: no price for %s", coin)}
+	}
+	d := MarketData{Price: q.USD, Change: "N/A"}
+	if q.Change24h != nil {
+		d.Percent, d.HasPercent, d.Change = *q.Change24h, true, formatPercent(*q.Change24h)
+	}
+	return d
+}
+
+// sourceReply handles the admin "/source" (list), "/source <symbol> <provider>" and
+// "/source <symbol> off" (back to the default chain)
+func sourceReply(args []string) string {
+	providers := make([]string, 0, len(quoteProviders))
+	for name := range quoteProviders {
+		providers = append(providers, name)
+	}
+	sort.Strings(providers)
+	usage := "ℹ️ Cú pháp: /source <mã> <" + strings.Join(providers, "|") + "> hoặc /source <mã> off"
+	if len(args) == 0 {
+		overrides := loadConfig().SourceOverrides
+		if len(overrides) == 0 {
+			return "ℹ️ Chưa có mã nào được ghim nguồn; mọi mã dùng chuỗi mặc định.\n" + usage
+		}
+		ids := make([]string, 0, len(overrides))
+		for id := range overrides {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		lines := make([]string, len(ids))
+		for i, id := range ids {
+			lines[i] = "• " + id + " → " + providerLabel(overrides[id])
+		}
+		return "🔌 Nguồn giá đã ghim:\n" + strings.Join(lines, "\n")
+	}
+	if len(args) != 2 {
+		return usage
+	}
+	if settingsCollection == nil {
+		return "⚠️ Không có kết nối cơ sở dữ liệu."
+	}
+	id, provider := resolveSymbol(args[0]), strings.ToLower(args[1])
+	if strings.ContainsAny(id, ".$") {
+		return "⚠️ Mã không hợp lệ."
+	}
+	field := "source_overrides." + id
+	update := bson.M{"$set": bson.M{field: provider}}
+	if provider == "off" {
+		update = bson.M{"$unset": bson.M{field: ""}}
+	} else if !validSourceOverride(id, provider) {
+		return fmt.Sprintf("⚠️ Nguồn %q không hỗ trợ %s.\n%s", provider, id, usage)
+	}
+	_, err := settingsCollection.UpdateOne(context.TODO(), bson.M{"_id": activeProfile().ConfigID}, update,
+		options.Update().SetUpsert(true))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to save source override for %s: %v", id, err)
+		return "⚠️ Không thể lưu lúc này."
+	}
+	reloadConfig()
+	if provider == "off" {
+		return fmt.Sprintf("✅ %s quay lại chuỗi nguồn mặc định.", id)
+	}
+	return fmt.Sprintf("✅ %s lấy giá từ %s trước; nếu lỗi sẽ dùng chuỗi mặc định.", id, providerLabel(provider))
+}
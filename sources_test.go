@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const coinGeckoHost = "api.coingecko.com"
+
+func TestValidSourceOverride(t *testing.T) {
+	tests := []struct {
+		id, provider string
+		want         bool
+	}{
+		{"btc", providerCoinGecko, true},
+		{"gold", providerCoinGecko, false},
+		{"usdvnd", providerVCB, true},
+		{"btc", providerVCB, false},
+		{"anything", providerTwelveData, true},
+		{"btc", "binance", false},
+	}
+	for _, tt := range tests {
+		if got := validSourceOverride(tt.id, tt.provider); got != tt.want {
+			t.Errorf("validSourceOverride(%q, %q) = %v, want %v", tt.id, tt.provider, got, tt.want)
+		}
+	}
+}
+
+func TestApplyConfigDocDropsInvalidOverrides(t *testing.T) {
+	cfg := applyConfigDoc(Config{}, configDoc{SourceOverrides: map[string]string{
+		"btc": providerCoinGecko, "gold": providerCoinGecko, "usdvnd": providerVCB, "eth": "binance",
+	}})
+	want := map[string]string{"btc": providerCoinGecko, "usdvnd": providerVCB}
+	if !reflect.DeepEqual(cfg.SourceOverrides, want) {
+		t.Errorf("SourceOverrides = %v, want %v", cfg.SourceOverrides, want)
+	}
+	if got := describeOverrides(cfg.SourceOverrides); got != "btc → coingecko, usdvnd → vcb" {
+		t.Errorf("describeOverrides = %q", got)
+	}
+	if got := describeOverrides(nil); got != "không" {
+		t.Errorf("describeOverrides(nil) = %q", got)
+	}
+}
+
+func TestQuoteOverridden(t *testing.T) {
+	saved := quoteProviders[providerCoinGecko]
+	t.Cleanup(func() { quoteProviders[providerCoinGecko] = saved })
+	p := saved
+	p.Quote = func(id string) MarketData {
+		if id == "eth" {
+			return MarketData{Err: errors.New("coingecko down")}
+		}
+		return MarketData{Price: 61000}
+	}
+	quoteProviders[providerCoinGecko] = p
+
+	overrides := map[string]string{"btc": providerCoinGecko, "eth": providerCoinGecko, "gold": providerTwelveData}
+	quotes, rest := quoteOverridden([]string{"btc", "eth", "gold", "sol"}, overrides)
+	if len(quotes) != 1 || quotes["btc"].Price != 61000 || quotes["btc"].Source != providerCoinGecko {
+		t.Errorf("quotes = %+v, want btc from CoinGecko", quotes)
+	}
+	if !reflect.DeepEqual(rest, []string{"eth", "gold", "sol"}) {
+		t.Errorf("rest = %v, want the failed override and the unpinned symbols", rest)
+	}
+
+	if note := sourcesNote([]string{"btc", "gold"}, quotes); note != "\n_Nguồn riêng: ₿ Bitcoin — CoinGecko_" {
+		t.Errorf("sourcesNote = %q", note)
+	}
+	if note := sourcesNote([]string{"gold"}, quotes); note != "" {
+		t.Errorf("sourcesNote without overrides = %q", note)
+	}
+}
+
+func TestCoinGeckoQuote(t *testing.T) {
+	f := withFakeHTTP(t)
+	f.set(coinGeckoHost, jsonResponse(`{"bitcoin":{"usd":61234.5,"usd_24h_change":-2.5}}`))
+	d := coinGeckoQuote("btc")
+	if d.Err != nil || d.Price != 61234.5 || !d.HasPercent || d.Percent != -2.5 {
+		t.Errorf("coinGeckoQuote(btc) = %+v", d)
+	}
+
+	f.set(coinGeckoHost, jsonResponse(`{"bitcoin":{"usd":61234.5}}`))
+	if d := coinGeckoQuote("btc"); d.Err != nil || d.HasPercent || d.Change != "N/A" {
+		t.Errorf("quote without a change = %+v", d)
+	}
+	for _, body := range []string{`{}`, `{"bitcoin":{"usd":0}}`, `not json`} {
+		f.set(coinGeckoHost, jsonResponse(body))
+		if d := coinGeckoQuote("btc"); d.Err == nil {
+			t.Errorf("coinGeckoQuote on %s = %+v, want an error", body, d)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+func TestRepeatedStartKeepsSettings(t *testing.T) {
+	withDatabase(t, true, nil)
+	mem := newMemoryStore()
+	store = mem
+	api := &fakeBotAPI{}
+	b := newFakeBot(t, api)
+	start := func() string {
+		api.calls = nil
+		r := &Request{Bot: b, Message: &tele.Message{Chat: &tele.Chat{ID: 42}}, Command: "/start"}
+		if err := commandRoutes["/start"].handler(r); err != nil {
+			t.Fatal(err)
+		}
+		return api.calls[0].Text
+	}
+
+	if got := start(); !strings.HasPrefix(got, "Chào mừng Trader!") {
+		t.Errorf("first /start = %q", got)
+	}
+	mem.WatchSymbols(context.Background(), 42, []string{"btc", "gold"}, clock())
+	if got := start(); !strings.HasPrefix(got, "👋 Chào mừng trở lại!") {
+		t.Errorf("repeated /start = %q", got)
+	}
+	if w, _, _ := mem.Watchlist(context.Background(), 42); !reflect.DeepEqual(w.Watchlist, []string{"btc", "gold"}) {
+		t.Errorf("repeated /start left the watchlist %v", w.Watchlist)
+	}
+}
+
+func TestSaveUserWithoutStore(t *testing.T) {
+	withDatabase(t, false, nil)
+	if saveUser(42) {
+		t.Error("saveUser without a database reported an existing user")
+	}
+}
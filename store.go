@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// subscriberPageSize is how many chat IDs loadUsers reads per page
+const subscriberPageSize = 1000
+
+// triggeredAlertRetention is how long a fired one-shot alert is kept before the alert run
+// purges it
+const triggeredAlertRetention = 30 * 24 * time.Hour
+
+// Store is the storage behind subscriptions, watchlists and alerts. mongoStore is the
+// deployed backend; every backend must pass the conformance suite in store_test.go.
+type Store interface {
+	// SaveUser subscribes a chat, seeding userDefaults only on insert and reviving a chat
+	// marked dead; existed reports whether it was already subscribed
+	SaveUser(ctx context.Context, chatID int64, now time.Time) (existed bool, err error)
+	// RemoveUser unsubscribes a chat; false when it wasn't subscribed
+	RemoveUser(ctx context.Context, chatID int64) (bool, error)
+	// MarkDead leaves a subscribed chat out of Subscribers until its next SaveUser
+	MarkDead(ctx context.Context, chatID int64, now time.Time) error
+	// Subscribers returns up to limit live chat IDs greater than after, in ascending order;
+	// the first page starts after math.MinInt64
+	Subscribers(ctx context.Context, after int64, limit int) ([]int64, error)
+
+	// Watchlist returns the chat's stored watchlist; false when not subscribed
+	Watchlist(ctx context.Context, chatID int64) (watchedList, bool, error)
+	// WatchSymbols appends the symbols not already watched, in order; false when not subscribed
+	WatchSymbols(ctx context.Context, chatID int64, symbols []string, now time.Time) (bool, error)
+	// UnwatchSymbol removes a symbol; false when not subscribed
+	UnwatchSymbol(ctx context.Context, chatID int64, symbol string, now time.Time) (bool, error)
+
+	// InsertAlert stores a new alert and returns its ID
+	InsertAlert(ctx context.Context, a Alert) (primitive.ObjectID, error)
+	// PendingAlerts returns a chat's untriggered alerts, oldest first
+	PendingAlerts(ctx context.Context, chatID int64) ([]Alert, error)
+	// ClaimAlert marks an alert triggered; exactly one of any concurrent callers gets true
+	ClaimAlert(ctx context.Context, id primitive.ObjectID, now time.Time) (bool, error)
+	// DeleteAlert removes one alert; false when it was already gone
+	DeleteAlert(ctx context.Context, id primitive.ObjectID) (bool, error)
+	// DeleteChatAlerts removes every alert of a chat and returns how many
+	DeleteChatAlerts(ctx context.Context, chatID int64) (int64, error)
+	// PurgeTriggeredAlerts removes alerts triggered before the cutoff and returns how many
+	PurgeTriggeredAlerts(ctx context.Context, before time.Time) (int64, error)
+}
+
+// store is bound with the collections; nil while there is no database
+var store Store
+
+// --- MONGO STORE ---
+
+// mongoStore keeps users in the profile's users collection and alerts in its alerts
+// collection
+type mongoStore struct {
+	users  *mongo.Collection
+	alerts *mongo.Collection
+}
+
+func (s mongoStore) SaveUser(ctx context.Context, chatID int64, now time.Time) (bool, error) {
+	onInsert := bson.M{"chat_id": chatID, "created_at": now}
+	for field, v := range userDefaults() {
+		onInsert[field] = v
+	}
+	update := bson.M{
+		"$set":         bson.M{"updated_at": now},
+		"$setOnInsert": onInsert,
+		"$unset":       bson.M{"dead": "", "not_found_count": ""},
+	}
+	res, err := s.users.UpdateOne(ctx, bson.M{"chat_id": chatID}, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	return res.UpsertedCount == 0, nil
+}
+
+func (s mongoStore) RemoveUser(ctx context.Context, chatID int64) (bool, error) {
+	res, err := s.users.DeleteOne(ctx, bson.M{"chat_id": chatID})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
+
+func (s mongoStore) MarkDead(ctx context.Context, chatID int64, now time.Time) error {
+	_, err := s.users.UpdateOne(ctx, bson.M{"chat_id": chatID}, bson.M{"$set": bson.M{"dead": true, "dead_at": now}})
+	return err
+}
+
+func (s mongoStore) Subscribers(ctx context.Context, after int64, limit int) ([]int64, error) {
+	filter := bson.M{"dead": bson.M{"$ne": true}, "chat_id": bson.M{"$gt": after}}
+	cursor, err := s.users.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "chat_id", Value: 1}}).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{"chat_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var ids []int64
+	for cursor.Next(ctx) {
+		var doc struct {
+			ChatID int64 `bson:"chat_id"`
+		}
+		if cursor.Decode(&doc) == nil {
+			ids = append(ids, doc.ChatID)
+		}
+	}
+	return ids, cursor.Err()
+}
+
+func (s mongoStore) Watchlist(ctx context.Context, chatID int64) (watchedList, bool, error) {
+	var w watchedList
+	err := s.users.FindOne(ctx, bson.M{"chat_id": chatID}, options.FindOne().SetProjection(watchedListProjection)).Decode(&w)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return w, false, nil
+	}
+	return w, err == nil, err
+}
+
+func (s mongoStore) WatchSymbols(ctx context.Context, chatID int64, symbols []string, now time.Time) (bool, error) {
+	res, err := s.users.UpdateOne(ctx, bson.M{"chat_id": chatID}, bson.M{
+		"$addToSet": bson.M{"watchlist": bson.M{"$each": symbols}},
+		"$set":      bson.M{"updated_at": now},
+	})
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+func (s mongoStore) UnwatchSymbol(ctx context.Context, chatID int64, symbol string, now time.Time) (bool, error) {
+	res, err := s.users.UpdateOne(ctx, bson.M{"chat_id": chatID}, bson.M{
+		"$pull": bson.M{"watchlist": symbol},
+		"$set":  bson.M{"updated_at": now},
+	})
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+func (s mongoStore) InsertAlert(ctx context.Context, a Alert) (primitive.ObjectID, error) {
+	if a.ID.IsZero() {
+		a.ID = primitive.NewObjectID()
+	}
+	_, err := s.alerts.InsertOne(ctx, a)
+	return a.ID, err
+}
+
+func (s mongoStore) PendingAlerts(ctx context.Context, chatID int64) ([]Alert, error) {
+	cursor, err := s.alerts.Find(ctx, bson.M{"chat_id": chatID, "triggered": false},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var alerts []Alert
+	err = cursor.All(ctx, &alerts)
+	return alerts, err
+}
+
+func (s mongoStore) ClaimAlert(ctx context.Context, id primitive.ObjectID, now time.Time) (bool, error) {
+	res, err := s.alerts.UpdateOne(ctx, bson.M{"_id": id, "triggered": false},
+		bson.M{"$set": bson.M{"triggered": true, "triggered_at": now}})
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (s mongoStore) DeleteAlert(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	res, err := s.alerts.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
+
+func (s mongoStore) DeleteChatAlerts(ctx context.Context, chatID int64) (int64, error) {
+	res, err := s.alerts.DeleteMany(ctx, bson.M{"chat_id": chatID})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+func (s mongoStore) PurgeTriggeredAlerts(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.alerts.DeleteMany(ctx, bson.M{"triggered": true, "triggered_at": bson.M{"$lt": before}})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
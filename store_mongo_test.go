@@ -0,0 +1,60 @@
+//go:build mongo
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TestMongoStoreConformance runs the Store suite against a real MongoDB:
+//
+//	MONGODB_TEST_URI=mongodb://localhost:27017 go test -tags mongo -run MongoStore
+//
+// Each subtest gets its own collections in a throwaway database, dropped afterwards.
+func TestMongoStoreConformance(t *testing.T) {
+	db := mongoTestDB(t)
+	n := 0
+	runStoreConformance(t, func(t *testing.T) Store {
+		n++
+		users := db.Collection(fmt.Sprintf("users_%d", n))
+		// The unique chat_id index ensureIndexes creates, so concurrent upserts behave as deployed
+		_, err := users.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+			Keys:    bson.D{{Key: "chat_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return mongoStore{users: users, alerts: db.Collection(fmt.Sprintf("alerts_%d", n))}
+	})
+}
+
+// mongoTestDB is a throwaway database on MONGODB_TEST_URI, dropped when the test ends; the
+// test is skipped without the variable
+func mongoTestDB(t *testing.T) *mongo.Database {
+	t.Helper()
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := client.Database(fmt.Sprintf("market_bot_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		db.Drop(context.Background())
+		client.Disconnect(context.Background())
+	})
+	return db
+}
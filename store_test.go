@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// runStoreConformance is the behaviour every Store backend shares. newStore returns an
+// empty store per subtest; memstore_test.go runs it against the in-memory fake and
+// store_mongo_test.go (build tag mongo) against a real database.
+func runStoreConformance(t *testing.T, newStore func(t *testing.T) Store) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 8, 0, 0, 0, vnLocation)
+
+	t.Run("SaveUserUpserts", func(t *testing.T) {
+		s := newStore(t)
+		if existed, err := s.SaveUser(ctx, 42, now); err != nil || existed {
+			t.Fatalf("first SaveUser = %v, %v; want a new user", existed, err)
+		}
+		w, ok, err := s.Watchlist(ctx, 42)
+		if err != nil || !ok || len(w.Watchlist) != 0 || w.Tier != tierFree {
+			t.Fatalf("new user = %+v, %v, %v; want an empty free watchlist", w, ok, err)
+		}
+		s.WatchSymbols(ctx, 42, []string{"btc"}, now)
+		if existed, err := s.SaveUser(ctx, 42, now.Add(time.Hour)); err != nil || !existed {
+			t.Fatalf("repeated SaveUser = %v, %v; want existing", existed, err)
+		}
+		if w, _, _ := s.Watchlist(ctx, 42); !reflect.DeepEqual(w.Watchlist, []string{"btc"}) {
+			t.Errorf("repeated SaveUser reset the watchlist to %v", w.Watchlist)
+		}
+	})
+
+	t.Run("SubscribersFilterRemovedAndDead", func(t *testing.T) {
+		s := newStore(t)
+		for _, id := range []int64{1, 2, 3} {
+			s.SaveUser(ctx, id, now)
+		}
+		if err := s.MarkDead(ctx, 2, now); err != nil {
+			t.Fatal(err)
+		}
+		if removed, err := s.RemoveUser(ctx, 3); err != nil || !removed {
+			t.Fatalf("RemoveUser = %v, %v", removed, err)
+		}
+		if removed, _ := s.RemoveUser(ctx, 3); removed {
+			t.Error("removing a removed user reported true")
+		}
+		if err := s.MarkDead(ctx, 9, now); err != nil {
+			t.Errorf("MarkDead of an unknown chat = %v", err)
+		}
+		if ids, _ := s.Subscribers(ctx, math.MinInt64, 10); !reflect.DeepEqual(ids, []int64{1}) {
+			t.Errorf("Subscribers = %v, want [1]", ids)
+		}
+		if _, ok, _ := s.Watchlist(ctx, 9); ok {
+			t.Error("MarkDead subscribed an unknown chat")
+		}
+		// /start revives a dead chat
+		if existed, _ := s.SaveUser(ctx, 2, now); !existed {
+			t.Error("reviving a dead chat reported a new user")
+		}
+		if ids, _ := s.Subscribers(ctx, math.MinInt64, 10); !reflect.DeepEqual(ids, []int64{1, 2}) {
+			t.Errorf("Subscribers after revival = %v, want [1 2]", ids)
+		}
+	})
+
+	t.Run("SubscribersPageInOrder", func(t *testing.T) {
+		s := newStore(t)
+		want := []int64{-1001234567890, -5, 3, 7, 11, 1 << 40}
+		for _, id := range []int64{11, -5, 1 << 40, 3, -1001234567890, 7} {
+			s.SaveUser(ctx, id, now)
+		}
+		var got []int64
+		for after, pages := int64(math.MinInt64), 0; ; pages++ {
+			page, err := s.Subscribers(ctx, after, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page) > 4 || pages > len(want) {
+				t.Fatalf("page %d = %v, over the limit", pages, page)
+			}
+			got = append(got, page...)
+			if len(page) < 4 {
+				break
+			}
+			after = page[len(page)-1]
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("paged subscribers = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Watchlist", func(t *testing.T) {
+		s := newStore(t)
+		if ok, err := s.WatchSymbols(ctx, 5, []string{"btc"}, now); err != nil || ok {
+			t.Errorf("WatchSymbols for an unsubscribed chat = %v, %v", ok, err)
+		}
+		if ok, err := s.UnwatchSymbol(ctx, 5, "btc", now); err != nil || ok {
+			t.Errorf("UnwatchSymbol for an unsubscribed chat = %v, %v", ok, err)
+		}
+		s.SaveUser(ctx, 5, now)
+		s.WatchSymbols(ctx, 5, []string{"btc", "eth"}, now)
+		s.WatchSymbols(ctx, 5, []string{"eth", "sol"}, now)
+		if w, _, _ := s.Watchlist(ctx, 5); !reflect.DeepEqual(w.Watchlist, []string{"btc", "eth", "sol"}) {
+			t.Errorf("watchlist = %v, want [btc eth sol]", w.Watchlist)
+		}
+		if ok, _ := s.UnwatchSymbol(ctx, 5, "eth", now); !ok {
+			t.Error("UnwatchSymbol reported not subscribed")
+		}
+		if w, _, _ := s.Watchlist(ctx, 5); !reflect.DeepEqual(w.Watchlist, []string{"btc", "sol"}) {
+			t.Errorf("watchlist = %v, want [btc sol]", w.Watchlist)
+		}
+	})
+
+	t.Run("AlertLifecycle", func(t *testing.T) {
+		s := newStore(t)
+		second, _ := s.InsertAlert(ctx, Alert{ChatID: 7, Symbol: "eth", Type: alertTypePrice, CreatedAt: now.Add(time.Minute)})
+		first, _ := s.InsertAlert(ctx, Alert{ChatID: 7, Symbol: "btc", Type: alertTypeMove, CreatedAt: now})
+		s.InsertAlert(ctx, Alert{ChatID: 8, Symbol: "gold", Type: alertTypePrice, CreatedAt: now})
+		alerts, err := s.PendingAlerts(ctx, 7)
+		if err != nil || len(alerts) != 2 || alerts[0].ID != first || alerts[1].ID != second {
+			t.Fatalf("PendingAlerts = %+v, %v; want btc then eth", alerts, err)
+		}
+		if claimed, err := s.ClaimAlert(ctx, first, now); err != nil || !claimed {
+			t.Fatalf("ClaimAlert = %v, %v", claimed, err)
+		}
+		if claimed, _ := s.ClaimAlert(ctx, first, now); claimed {
+			t.Error("an alert was claimed twice")
+		}
+		if alerts, _ := s.PendingAlerts(ctx, 7); len(alerts) != 1 || alerts[0].ID != second {
+			t.Errorf("PendingAlerts after the claim = %+v", alerts)
+		}
+		if deleted, _ := s.DeleteAlert(ctx, second); !deleted {
+			t.Error("DeleteAlert reported nothing deleted")
+		}
+		if deleted, _ := s.DeleteAlert(ctx, second); deleted {
+			t.Error("deleting a deleted alert reported true")
+		}
+		if n, err := s.DeleteChatAlerts(ctx, 8); err != nil || n != 1 {
+			t.Errorf("DeleteChatAlerts = %d, %v; want 1", n, err)
+		}
+	})
+
+	t.Run("ClaimAlertIsAtomic", func(t *testing.T) {
+		s := newStore(t)
+		id, err := s.InsertAlert(ctx, Alert{ChatID: 7, Symbol: "btc", Type: alertTypePrice, CreatedAt: now})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		claims := 0
+		for i := 0; i < 32; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if claimed, err := s.ClaimAlert(ctx, id, now); err == nil && claimed {
+					mu.Lock()
+					claims++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		if claims != 1 {
+			t.Errorf("%d concurrent claims succeeded, want exactly 1", claims)
+		}
+	})
+
+	t.Run("PurgeTriggeredAlerts", func(t *testing.T) {
+		s := newStore(t)
+		old, _ := s.InsertAlert(ctx, Alert{ChatID: 7, Symbol: "btc", Type: alertTypePrice, CreatedAt: now})
+		recent, _ := s.InsertAlert(ctx, Alert{ChatID: 7, Symbol: "eth", Type: alertTypePrice, CreatedAt: now})
+		s.InsertAlert(ctx, Alert{ChatID: 7, Symbol: "sol", Type: alertTypePrice, CreatedAt: now.Add(-90 * 24 * time.Hour)})
+		s.ClaimAlert(ctx, old, now)
+		s.ClaimAlert(ctx, recent, now.Add(48*time.Hour))
+		if n, err := s.PurgeTriggeredAlerts(ctx, now.Add(24*time.Hour)); err != nil || n != 1 {
+			t.Errorf("PurgeTriggeredAlerts = %d, %v; want only the old fired alert", n, err)
+		}
+		if deleted, _ := s.DeleteAlert(ctx, recent); !deleted {
+			t.Error("the recently fired alert was purged")
+		}
+		if alerts, _ := s.PendingAlerts(ctx, 7); len(alerts) != 1 {
+			t.Errorf("an untriggered alert was purged: %+v", alerts)
+		}
+	})
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	tele "gopkg.in/telebot.v3"
+)
+
+// maxSuggestDistance is the largest edit distance a typo may be from a command
+const maxSuggestDistance = 2
+
+// textHintRetention keeps a day's hint marker past the day boundary in any timezone (TTL index)
+const textHintRetention = 48 * time.Hour
+
+// capabilityHint answers plain text in private chats, at most once a day
+const capabilityHint = "💡 Bot trả lời các lệnh bắt đầu bằng /, ví dụ /report (bản tin), /news (tin tức), /watch (danh sách theo dõi). Gõ /help để xem tất cả."
+
+// commandKeywords are Vietnamese words people type as commands ("/gia"), by the command
+// they mean
+var commandKeywords = map[string]string{
+	"giá": "/report", "gia": "/report", "vàng": "/report", "vang": "/report",
+	"tin": "/news", "tintuc": "/news",
+	"tygia": "/usdvnd", "theodoi": "/watch", "canhbao": "/alerts", "danhmuc": "/portfolio",
+	"dangky": "/start", "huy": "/quit", "huongdan": "/help",
+}
+
+var (
+	// textHintCollection marks chats that got today's capability hint
+	textHintCollection *mongo.Collection
+
+	// textHintDays does the same without a database: the Vietnam day each chat last got it
+	textHintMu   sync.Mutex
+	textHintDays = map[int64]string{}
+)
+
+// --- COMMAND SUGGESTIONS ---
+
+// editDistance is the edit distance between a and b in runes, counting a swap of two
+// neighbouring letters ("hepl") as one edit like an insert, delete or substitution
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	rows := make([][]int, len(ra)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(rb)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(ra)][len(rb)]
+}
+
+// suggestCommand returns the command closest to an unknown "/cmd", or "" when nothing is
+// close enough. names maps every name a user might type (commands, aliases, keywords
+// without the slash) onto the command it runs. A name may be one edit off per three
+// letters, up to maxSuggestDistance, so "/hi" doesn't turn into /quit; ties go to the
+// alphabetically first name.
+func suggestCommand(typed string, names map[string]string) string {
+	word := strings.TrimPrefix(typed, "/")
+	if word == "" {
+		return ""
+	}
+	keys := make([]string, 0, len(names))
+	for name := range names {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	best, bestDist := "", maxSuggestDistance+1
+	for _, name := range keys {
+		d := editDistance(word, name)
+		if d < bestDist && d <= min(maxSuggestDistance, len([]rune(name))/3) {
+			best, bestDist = names[name], d
+		}
+	}
+	return best
+}
+
+// suggestionNames lists the names suggestCommand matches against: ungated routes, aliases
+// of them (suggesting the new name) and the keyword hints
+func suggestionNames() map[string]string {
+	names := make(map[string]string, len(commandRoutes)+len(commandAliases)+len(commandKeywords))
+	for command, rt := range commandRoutes {
+		if len(rt.middleware) == 0 {
+			names[strings.TrimPrefix(command, "/")] = command
+		}
+	}
+	for old, a := range commandAliases {
+		if _, ok := names[strings.TrimPrefix(a.target, "/")]; ok {
+			names[strings.TrimPrefix(old, "/")] = a.target
+		}
+	}
+	for word, command := range commandKeywords {
+		names[word] = command
+	}
+	return names
+}
+
+// unknownCommandHandler answers an unknown command with the closest match and a button
+// that runs it, or the invalid-command reply when nothing is close
+func unknownCommandHandler(r *Request) error {
+	command := suggestCommand(r.Command, suggestionNames())
+	if command == "" || !fitsCallback("btn_run", command) {
+		return r.Reply(invalidCommandText)
+	}
+	menu := &tele.ReplyMarkup{}
+	menu.Inline(menu.Row(menu.Data("▶️ "+command, "btn_run", command)))
+	return r.Reply(fmt.Sprintf("🤔 Có phải bạn muốn %s? Gõ /help để xem danh sách lệnh.", command), menu)
+}
+
+// textHint answers plain text nobody asked for: a capability hint once a day in private
+// chats, nothing in groups
+func textHint(r *Request) error {
+	if r.Message.Chat.Type != tele.ChatPrivate || !claimTextHint(r.ChatID()) {
+		return nil
+	}
+	return r.Reply(capabilityHint)
+}
+
+// claimTextHint reports whether the chat hasn't had today's hint, marking it as had. The
+// marker's _id is chat and day, so a second insert the same day is a duplicate key. Without
+// a database the claim is kept in memory, so a degraded bot still hints once a day.
+func claimTextHint(chatID int64) bool {
+	now := clock()
+	day := now.In(vnLocation).Format("2006-01-02")
+	if textHintCollection == nil {
+		textHintMu.Lock()
+		defer textHintMu.Unlock()
+		if textHintDays[chatID] == day {
+			return false
+		}
+		textHintDays[chatID] = day
+		return true
+	}
+	id := fmt.Sprintf("%d:%s", chatID, day)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := textHintCollection.InsertOne(ctx, bson.M{"_id": id, "at": now})
+	if mongo.IsDuplicateKeyError(err) {
+		return false
+	}
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to record text hint for %d: %v", chatID, err)
+	}
+	return true
+}
+
+// runSuggestedCommand handles the suggestion button: the command runs as if the tapping
+// user had typed it in that chat. Only ungated routes run, whatever the button data says.
+func runSuggestedCommand(b *tele.Bot, updateID int, cb *tele.Callback, command string) string {
+	if cb.Message == nil || cb.Message.Chat == nil {
+		return "Tin nhắn đã quá cũ, vui lòng gõ lại lệnh."
+	}
+	if rt, ok := commandRoutes[command]; !ok || len(rt.middleware) > 0 {
+		return "⚠️ Lệnh không hợp lệ."
+	}
+	m := *cb.Message
+	m.Text, m.Sender, m.ReplyTo = command, cb.Sender, nil
+	if err := dispatchCommand(b, updateID, &m); err != nil {
+		log.Printf("[COMMAND] Suggested %s failed in %d: %v", command, m.Chat.ID, err)
+	}
+	return ""
+}
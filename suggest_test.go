@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClaimTextHintWithoutDatabase(t *testing.T) {
+	now := time.Date(2026, 3, 2, 23, 30, 0, 0, vnLocation)
+	savedClock, savedColl := clock, textHintCollection
+	clock, textHintCollection = func() time.Time { return now }, nil
+	textHintMu.Lock()
+	savedDays := textHintDays
+	textHintDays = map[int64]string{}
+	textHintMu.Unlock()
+	t.Cleanup(func() {
+		clock, textHintCollection = savedClock, savedColl
+		textHintMu.Lock()
+		textHintDays = savedDays
+		textHintMu.Unlock()
+	})
+
+	if !claimTextHint(1) {
+		t.Fatal("first hint of the day was refused")
+	}
+	if claimTextHint(1) {
+		t.Error("a second hint the same day was claimed")
+	}
+	if !claimTextHint(2) {
+		t.Error("another chat's hint was refused")
+	}
+	// An hour after 23:30 is the next Vietnam day
+	now = now.Add(time.Hour)
+	if !claimTextHint(1) {
+		t.Error("the next Vietnam day's hint was refused")
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"help", "help", 0},
+		{"hepl", "help", 1},
+		{"hlep", "help", 1},
+		{"hel", "help", 1},
+		{"helpp", "help", 1},
+		{"reprot", "report", 1},
+		{"giá", "gia", 1},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestCommand(t *testing.T) {
+	names := map[string]string{
+		"help": "/help", "report": "/report", "update": "/report", "quit": "/quit",
+		"news": "/news", "new": "/news", "gia": "/report", "watch": "/watch",
+	}
+	tests := []struct{ typed, want string }{
+		{"/hepl", "/help"},
+		{"/reprot", "/report"},
+		{"/updte", "/report"},
+		{"/gía", "/report"},
+		{"/wacth", "/watch"},
+		{"/hi", ""},
+		{"/qit", "/quit"},
+		{"/qi", ""},
+		{"/xyzzy", ""},
+		{"/", ""},
+		// One edit from both "new" and "news": the alphabetically first name wins
+		{"/newz", "/news"},
+	}
+	for _, tt := range tests {
+		if got := suggestCommand(tt.typed, names); got != tt.want {
+			t.Errorf("suggestCommand(%q) = %q, want %q", tt.typed, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestionNamesSkipGatedRoutes(t *testing.T) {
+	names := suggestionNames()
+	for command, rt := range commandRoutes {
+		if _, ok := names[command[1:]]; ok == (len(rt.middleware) > 0) {
+			t.Errorf("%s listed = %v with %d middleware", command, ok, len(rt.middleware))
+		}
+	}
+	if names["gia"] != "/report" {
+		t.Errorf("keyword gia suggests %q", names["gia"])
+	}
+}
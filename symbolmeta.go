@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Symbol metadata is cached for symbolMetaTTL (TTL index); a failed lookup is retried
+// after symbolMetaRetry rather than on every use
+const (
+	symbolMetaTTL   = 30 * 24 * time.Hour
+	symbolMetaRetry = 24 * time.Hour
+)
+
+// symbolMetaHourlyBudget caps the /symbol_search calls one container makes per hour, so
+// a listing full of unknown symbols can't drain the API credits
+const symbolMetaHourlyBudget = 10
+
+var (
+	symbolMetaCollection *mongo.Collection
+
+	symbolMetaMu      sync.Mutex
+	symbolMetaCache   = map[string]SymbolMeta{}
+	symbolMetaSpent   int
+	symbolMetaResetAt time.Time
+)
+
+// SymbolMeta is what Twelve Data knows about a symbol outside the asset registry. Failed
+// marks a lookup that found nothing, so it isn't repeated until symbolMetaRetry passes.
+type SymbolMeta struct {
+	Symbol    string    `bson:"_id"`
+	Name      string    `bson:"name,omitempty"`
+	Exchange  string    `bson:"exchange,omitempty"`
+	Type      string    `bson:"type,omitempty"`
+	Failed    bool      `bson:"failed,omitempty"`
+	FetchedAt time.Time `bson:"fetched_at"`
+}
+
+// --- SYMBOL METADATA ---
+
+// symbolLabel is how a canonical ID is shown to users: the registry label, the curated
+// /symbols name, or the instrument name Twelve Data reports ("Apple Inc (NASDAQ) — AAPL").
+// It falls back to the provider symbol whenever nothing better is known.
+func symbolLabel(id string) string {
+	for _, a := range assetRegistry {
+		if a.Symbol == id {
+			return a.Label
+		}
+	}
+	raw := twelveDataSymbol(id)
+	for _, c := range symbolDirectory {
+		for _, e := range c.Entries {
+			if e.ID == id {
+				return e.Name + " — " + raw
+			}
+		}
+	}
+	meta, ok := lookupSymbolMeta(id)
+	if !ok || meta.Name == "" {
+		return raw
+	}
+	name := meta.Name
+	if meta.Exchange != "" && !strings.EqualFold(meta.Exchange, "physical currency") {
+		name += " (" + meta.Exchange + ")"
+	}
+	return name + " — " + raw
+}
+
+// lookupSymbolMeta returns metadata from memory, then MongoDB, then (budget permitting)
+// Twelve Data, storing what it finds at each level
+func lookupSymbolMeta(id string) (SymbolMeta, bool) {
+	now := clock()
+	symbolMetaMu.Lock()
+	meta, ok := symbolMetaCache[id]
+	symbolMetaMu.Unlock()
+	if ok && now.Sub(meta.FetchedAt) < symbolMetaTTL && (!meta.Failed || now.Sub(meta.FetchedAt) < symbolMetaRetry) {
+		return meta, !meta.Failed
+	}
+	if symbolMetaCollection != nil {
+		var stored SymbolMeta
+		err := symbolMetaCollection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&stored)
+		if err == nil && (!stored.Failed || now.Sub(stored.FetchedAt) < symbolMetaRetry) {
+			cacheSymbolMeta(stored)
+			return stored, !stored.Failed
+		}
+	}
+	if !spendSymbolMetaBudget(now) {
+		log.Printf("[API] Symbol metadata budget spent, showing %s unlabelled", id)
+		return SymbolMeta{}, false
+	}
+	meta, err := searchSymbol(id)
+	if err != nil {
+		// Transport errors aren't cached; the symbol is retried on its next use
+		log.Printf("[API ERROR] Symbol search failed for %s: %v", id, err)
+		return SymbolMeta{}, false
+	}
+	cacheSymbolMeta(meta)
+	if symbolMetaCollection != nil {
+		_, err := symbolMetaCollection.ReplaceOne(context.TODO(), bson.M{"_id": id}, meta, options.Replace().SetUpsert(true))
+		if err != nil {
+			log.Printf("[DATABASE ERROR] Failed to store symbol metadata for %s: %v", id, err)
+		}
+	}
+	return meta, !meta.Failed
+}
+
+func cacheSymbolMeta(meta SymbolMeta) {
+	symbolMetaMu.Lock()
+	symbolMetaCache[meta.Symbol] = meta
+	symbolMetaMu.Unlock()
+}
+
+// spendSymbolMetaBudget takes one lookup from this hour's allowance
+func spendSymbolMetaBudget(now time.Time) bool {
+	symbolMetaMu.Lock()
+	defer symbolMetaMu.Unlock()
+	if now.After(symbolMetaResetAt) {
+		symbolMetaSpent, symbolMetaResetAt = 0, now.Add(time.Hour)
+	}
+	if symbolMetaSpent >= symbolMetaHourlyBudget {
+		return false
+	}
+	symbolMetaSpent++
+	return true
+}
+
+// searchSymbol asks /symbol_search for the exact provider symbol; no match is a Failed
+// entry, not an error
+func searchSymbol(id string) (SymbolMeta, error) {
+	raw := twelveDataSymbol(id)
+	apiUrl := twelveDataURL("symbol_search", url.Values{"symbol": {raw}, "outputsize": {"5"},
+		"apikey": {os.Getenv("TWELVE_DATA_API_KEY")}})
+	countAPICall("symbol_search")
+	body, err := fetchBody(context.Background(), apiUrl, 10*time.Second, jsonContentTypes)
+	if err != nil {
+		return SymbolMeta{}, err
+	}
+	var result struct {
+		Data []struct {
+			Symbol         string `json:"symbol"`
+			InstrumentName string `json:"instrument_name"`
+			Exchange       string `json:"exchange"`
+			InstrumentType string `json:"instrument_type"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return SymbolMeta{}, err
+	}
+	meta := SymbolMeta{Symbol: id, Failed: true, FetchedAt: clock()}
+	for _, d := range result.Data {
+		if strings.EqualFold(d.Symbol, raw) {
+			meta.Name, meta.Exchange, meta.Type, meta.Failed = d.InstrumentName, d.Exchange, d.InstrumentType, false
+			break
+		}
+	}
+	return meta, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// isolateSymbolMeta gives a test an empty metadata cache, a fresh hourly budget, no
+// database and a movable clock
+func isolateSymbolMeta(t *testing.T) (*fakeHTTP, *time.Time) {
+	t.Helper()
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, vnLocation)
+	savedClock, savedColl := clock, symbolMetaCollection
+	clock = func() time.Time { return now }
+	symbolMetaCollection = nil
+	resetSymbolMeta := func() {
+		symbolMetaMu.Lock()
+		symbolMetaCache, symbolMetaSpent, symbolMetaResetAt = map[string]SymbolMeta{}, 0, time.Time{}
+		symbolMetaMu.Unlock()
+	}
+	resetSymbolMeta()
+	t.Cleanup(func() {
+		clock, symbolMetaCollection = savedClock, savedColl
+		resetSymbolMeta()
+	})
+	return withFakeHTTP(t), &now
+}
+
+func TestSymbolLabelKnownSymbols(t *testing.T) {
+	f, _ := isolateSymbolMeta(t)
+	if got := symbolLabel("btc"); got != "₿ Bitcoin" {
+		t.Errorf("registry label = %q", got)
+	}
+	if got := symbolLabel("gbpusd"); got != "Bảng Anh / Đô la Mỹ — GBP/USD" {
+		t.Errorf("directory label = %q", got)
+	}
+	if n := f.count(twelveDataHost); n != 0 {
+		t.Errorf("known symbols made %d symbol searches", n)
+	}
+}
+
+func TestSymbolLabelSearch(t *testing.T) {
+	f, _ := isolateSymbolMeta(t)
+	f.set(twelveDataHost, jsonResponse(`{"data":[
+		{"symbol":"PLTRX","instrument_name":"Other","exchange":"OTC"},
+		{"symbol":"PLTR","instrument_name":"Palantir Technologies Inc","exchange":"NYSE","instrument_type":"Common Stock"}]}`))
+	want := "Palantir Technologies Inc (NYSE) — PLTR"
+	for i := 0; i < 3; i++ {
+		if got := symbolLabel("pltr"); got != want {
+			t.Fatalf("symbolLabel = %q, want %q", got, want)
+		}
+	}
+	if n := f.count(twelveDataHost); n != 1 {
+		t.Errorf("%d symbol searches, want 1 then cache hits", n)
+	}
+
+	f.set(twelveDataHost, jsonResponse(`{"data":[{"symbol":"CNY","instrument_name":"Chinese Yuan","exchange":"Physical Currency"}]}`))
+	if got := symbolLabel("cny"); got != "Chinese Yuan — CNY" {
+		t.Errorf("physical currency label = %q", got)
+	}
+}
+
+func TestSymbolLabelFailedLookupRetries(t *testing.T) {
+	f, now := isolateSymbolMeta(t)
+	f.set(twelveDataHost, jsonResponse(`{"data":[{"symbol":"ZZZA","instrument_name":"Near miss"}]}`))
+	if got := symbolLabel("zzz"); got != "ZZZ" {
+		t.Errorf("unmatched search label = %q, want the provider symbol", got)
+	}
+	symbolLabel("zzz")
+	if n := f.count(twelveDataHost); n != 1 {
+		t.Errorf("a failed lookup was repeated within the retry window (%d searches)", n)
+	}
+	*now = now.Add(symbolMetaRetry + time.Minute)
+	f.set(twelveDataHost, jsonResponse(`{"data":[{"symbol":"ZZZ","instrument_name":"Zed Corp","exchange":"NASDAQ"}]}`))
+	if got := symbolLabel("zzz"); got != "Zed Corp (NASDAQ) — ZZZ" {
+		t.Errorf("label after the retry window = %q", got)
+	}
+}
+
+func TestSymbolLabelTransportErrorNotCached(t *testing.T) {
+	f, _ := isolateSymbolMeta(t)
+	f.set(twelveDataHost, fakeResponse{Err: errors.New("connection reset")})
+	symbolLabel("abc")
+	symbolLabel("abc")
+	if n := f.count(twelveDataHost); n != 2 {
+		t.Errorf("%d searches after two transport errors, want each use to retry", n)
+	}
+}
+
+func TestSymbolMetaBudget(t *testing.T) {
+	f, now := isolateSymbolMeta(t)
+	f.set(twelveDataHost, fakeResponse{Err: errors.New("connection reset")})
+	for i := 0; i < symbolMetaHourlyBudget+5; i++ {
+		symbolLabel("abc")
+	}
+	if n := f.count(twelveDataHost); n != symbolMetaHourlyBudget {
+		t.Errorf("%d searches in one hour, want the budget of %d", n, symbolMetaHourlyBudget)
+	}
+	*now = now.Add(time.Hour + time.Second)
+	symbolLabel("abc")
+	if n := f.count(twelveDataHost); n != symbolMetaHourlyBudget+1 {
+		t.Errorf("the budget didn't reset after an hour (%d searches)", n)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// symbolProbeTTL is how long a symbol that quoted successfully is trusted without re-probing
+const symbolProbeTTL = 30 * 24 * time.Hour
+
+// defaultSymbolProbeDailyBudget caps the probe quotes spent per (Vietnam) day across all
+// containers; SYMBOL_PROBE_DAILY_BUDGET overrides it
+const defaultSymbolProbeDailyBudget = 50
+
+// maxSymbolSuggestions is how many alternatives a rejected symbol lists
+const maxSymbolSuggestions = 3
+
+var (
+	// errUnknownSymbol marks quotes Twelve Data rejected because the symbol doesn't exist
+	errUnknownSymbol = errors.New("twelvedata: unknown symbol")
+	// errPlanRestricted marks quotes for a symbol the current Twelve Data plan doesn't serve
+	errPlanRestricted = errors.New("twelvedata: symbol not available on this plan")
+)
+
+var (
+	symbolProbeCollection *mongo.Collection
+
+	symbolProbeMu    sync.Mutex
+	symbolProbeKnown = map[string]time.Time{}
+)
+
+// --- SYMBOL PROBE ---
+
+// symbolProbeBudgetDocID is the settings document counting today's probes
+func symbolProbeBudgetDocID(now time.Time) string {
+	return activeProfile().collectionName("symbol_probe_budget") + ":" + now.In(vnLocation).Format("2006-01-02")
+}
+
+// isRegistrySymbol reports whether id is one of the report's own assets (always quotable)
+func isRegistrySymbol(id string) bool {
+	for _, a := range assetRegistry {
+		if a.Symbol == id {
+			return true
+		}
+	}
+	return false
+}
+
+// isKnownSymbol reports whether id quoted successfully within symbolProbeTTL
+func isKnownSymbol(id string) bool {
+	now := clock()
+	symbolProbeMu.Lock()
+	at, ok := symbolProbeKnown[id]
+	symbolProbeMu.Unlock()
+	if ok && now.Sub(at) < symbolProbeTTL {
+		return true
+	}
+	if symbolProbeCollection == nil {
+		return false
+	}
+	var doc struct {
+		ProbedAt time.Time `bson:"probed_at"`
+	}
+	if err := symbolProbeCollection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&doc); err != nil {
+		return false
+	}
+	if now.Sub(doc.ProbedAt) >= symbolProbeTTL {
+		return false
+	}
+	symbolProbeMu.Lock()
+	symbolProbeKnown[id] = doc.ProbedAt
+	symbolProbeMu.Unlock()
+	return true
+}
+
+// markSymbolKnown records a successful quote so later adds skip the probe
+func markSymbolKnown(id string) {
+	if isRegistrySymbol(id) {
+		return
+	}
+	now := clock()
+	symbolProbeMu.Lock()
+	symbolProbeKnown[id] = now
+	symbolProbeMu.Unlock()
+	if symbolProbeCollection == nil {
+		return
+	}
+	_, err := symbolProbeCollection.UpdateOne(context.TODO(), bson.M{"_id": id},
+		bson.M{"$set": bson.M{"probed_at": now}}, options.Update().SetUpsert(true))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to record probed symbol %s: %v", id, err)
+	}
+}
+
+// spendSymbolProbeBudget takes one probe from today's shared allowance. The upsert only
+// matches while spent is under the budget; once it isn't, inserting a second document with
+// the same _id fails with a duplicate key, which means the budget is gone.
+func spendSymbolProbeBudget() bool {
+	if settingsCollection == nil {
+		return true
+	}
+	budget := envInt("SYMBOL_PROBE_DAILY_BUDGET", defaultSymbolProbeDailyBudget)
+	_, err := settingsCollection.UpdateOne(context.TODO(),
+		bson.M{"_id": symbolProbeBudgetDocID(clock()), "spent": bson.M{"$lt": budget}},
+		bson.M{"$inc": bson.M{"spent": 1}}, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return false
+	}
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to spend symbol probe budget: %v", err)
+	}
+	return true
+}
+
+// probeSymbol checks that a symbol outside the registry can be quoted before it is saved,
+// with one quote at most every symbolProbeTTL. The returned rejection is empty when the
+// symbol may be saved: known-good, probed fine, or not checkable right now (budget spent,
+// provider unreachable), where the save goes ahead rather than blocking the user.
+func probeSymbol(id string) string {
+	if isRegistrySymbol(id) || isKnownSymbol(id) {
+		return ""
+	}
+	if !spendSymbolProbeBudget() {
+		log.Printf("[API] Symbol probe budget spent, accepting %s unchecked", id)
+		return ""
+	}
+	d := getMarketData(id, os.Getenv("TWELVE_DATA_API_KEY"))
+	if d.Err == nil {
+		markSymbolKnown(id)
+		return ""
+	}
+	return symbolRejection(id, d.Err)
+}
+
+// symbolRejection explains a quote error that means the symbol can't be used at all;
+// empty for transient errors
+func symbolRejection(id string, err error) string {
+	switch {
+	case errors.Is(err, errUnknownSymbol):
+		text := fmt.Sprintf("⚠️ Không tìm thấy mã %s.", twelveDataSymbol(id))
+		if s := symbolSuggestions(id); len(s) > 0 {
+			text += " Có phải bạn muốn: " + strings.Join(s, ", ") + "?"
+		}
+		return text + " Xem /symbols để biết các mã hợp lệ."
+	case errors.Is(err, errPlanRestricted):
+		return fmt.Sprintf("⚠️ Mã %s không có trong gói dữ liệu hiện tại của bot, nên không thể theo dõi hay đặt cảnh báo.", twelveDataSymbol(id))
+	}
+	return ""
+}
+
+// symbolSuggestions lists aliases and curated symbols that look like id: one contains the
+// other, or they share their first three letters
+func symbolSuggestions(id string) []string {
+	raw := strings.ToUpper(strings.NewReplacer("/", "", ":", "").Replace(id))
+	if len(raw) < 2 {
+		return nil
+	}
+	seen := map[string]bool{}
+	var out []string
+	consider := func(name, canonical string) {
+		n := strings.ToUpper(strings.ReplaceAll(name, "/", ""))
+		if seen[canonical] || canonical == id {
+			return
+		}
+		if strings.Contains(n, raw) || strings.Contains(raw, n) || (len(n) >= 3 && len(raw) >= 3 && n[:3] == raw[:3]) {
+			seen[canonical] = true
+			out = append(out, canonical)
+		}
+	}
+	aliases := make([]string, 0, len(symbolAliases))
+	for alias := range symbolAliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		consider(alias, symbolAliases[alias])
+	}
+	for _, c := range symbolDirectory {
+		for _, e := range c.Entries {
+			consider(e.ID, e.ID)
+		}
+	}
+	if len(out) > maxSymbolSuggestions {
+		out = out[:maxSymbolSuggestions]
+	}
+	return out
+}
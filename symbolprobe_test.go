@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// isolateSymbolProbe gives a test an empty known-symbol cache and no database, so the
+// probe budget is unlimited and every probe goes to the fake Twelve Data host
+func isolateSymbolProbe(t *testing.T) *fakeHTTP {
+	t.Helper()
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, vnLocation)
+	savedClock, savedProbe, savedSettings := clock, symbolProbeCollection, settingsCollection
+	clock = func() time.Time { return now }
+	symbolProbeCollection, settingsCollection = nil, nil
+	resetKnown := func() {
+		symbolProbeMu.Lock()
+		symbolProbeKnown = map[string]time.Time{}
+		symbolProbeMu.Unlock()
+	}
+	resetKnown()
+	t.Cleanup(func() {
+		clock, symbolProbeCollection, settingsCollection = savedClock, savedProbe, savedSettings
+		resetKnown()
+	})
+	return withFakeHTTP(t)
+}
+
+func TestProbeSymbol(t *testing.T) {
+	t.Run("registry symbols are never probed", func(t *testing.T) {
+		f := isolateSymbolProbe(t)
+		if got := probeSymbol("btc"); got != "" || f.count(twelveDataHost) != 0 {
+			t.Errorf("probeSymbol(btc) = %q after %d quotes", got, f.count(twelveDataHost))
+		}
+	})
+
+	t.Run("a good quote is remembered", func(t *testing.T) {
+		f := isolateSymbolProbe(t)
+		f.set(twelveDataHost, jsonResponse(`{"symbol":"AAPL","close":"190.5","percent_change":"0.4"}`))
+		for i := 0; i < 3; i++ {
+			if got := probeSymbol("aapl"); got != "" {
+				t.Fatalf("probeSymbol(aapl) = %q", got)
+			}
+		}
+		if n := f.count(twelveDataHost); n != 1 {
+			t.Errorf("%d probe quotes, want 1", n)
+		}
+		if !isKnownSymbol("aapl") {
+			t.Error("aapl isn't known after a good probe")
+		}
+	})
+
+	t.Run("unknown symbols are rejected with suggestions", func(t *testing.T) {
+		f := isolateSymbolProbe(t)
+		f.set(twelveDataHost, jsonResponse(`{"code":404,"message":"**symbol** not found: GOLDX"}`))
+		got := probeSymbol("goldx")
+		if !strings.Contains(got, "Không tìm thấy mã GOLDX") || !strings.Contains(got, "gold") {
+			t.Errorf("probeSymbol(goldx) = %q, want a rejection suggesting gold", got)
+		}
+		if isKnownSymbol("goldx") {
+			t.Error("a rejected symbol was marked known")
+		}
+	})
+
+	t.Run("plan-restricted symbols are rejected", func(t *testing.T) {
+		f := isolateSymbolProbe(t)
+		f.set(twelveDataHost, jsonResponse(`{"code":403,"message":"available starting with Grow"}`))
+		if got := probeSymbol("spx"); !strings.Contains(got, "gói dữ liệu") {
+			t.Errorf("probeSymbol(spx) = %q, want the plan rejection", got)
+		}
+	})
+
+	t.Run("transient failures let the save through", func(t *testing.T) {
+		f := isolateSymbolProbe(t)
+		f.set(twelveDataHost, fakeResponse{Err: errors.New("connection reset")})
+		if got := probeSymbol("aapl"); got != "" {
+			t.Errorf("probeSymbol on a transport error = %q, want the save allowed", got)
+		}
+		probeSymbol("aapl")
+		if n := f.count(twelveDataHost); n != 2 {
+			t.Errorf("an unchecked symbol was cached as known (%d quotes)", n)
+		}
+	})
+}
+
+func TestSymbolRejection(t *testing.T) {
+	if got := symbolRejection("btc", errRateLimited); got != "" {
+		t.Errorf("rate limit rejection = %q, want none", got)
+	}
+	if got := symbolRejection("abc", fmt.Errorf("%w: nope", errUnknownSymbol)); !strings.HasSuffix(got, "/symbols để biết các mã hợp lệ.") {
+		t.Errorf("wrapped unknown symbol = %q", got)
+	}
+}
+
+func TestSymbolSuggestions(t *testing.T) {
+	if got := symbolSuggestions("bitcoi"); len(got) == 0 || got[0] != "btc" {
+		t.Errorf("symbolSuggestions(bitcoi) = %v, want btc first", got)
+	}
+	for _, s := range symbolSuggestions("gold") {
+		if s == "gold" {
+			t.Error("a symbol was suggested as an alternative to itself")
+		}
+	}
+	if got := symbolSuggestions("x"); got != nil {
+		t.Errorf("one-letter input suggested %v", got)
+	}
+	if got := symbolSuggestions("usd"); len(got) > maxSymbolSuggestions {
+		t.Errorf("%d suggestions, want at most %d", len(got), maxSymbolSuggestions)
+	}
+}
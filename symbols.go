@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// symbolsPageSize is how many symbols one /symbols page lists
+const symbolsPageSize = 10
+
+// symbolEntry is one example in the /symbols directory, under the ID users type
+type symbolEntry struct {
+	ID   string
+	Name string
+}
+
+// symbolCategory groups the directory by instrument type
+type symbolCategory struct {
+	Key     string
+	Aliases []string
+	Title   string
+	Entries []symbolEntry
+}
+
+// symbolDirectory is the curated list behind /symbols. It documents what the quote
+// provider covers; anything else it quotes works the same way when typed by ticker.
+var symbolDirectory = []symbolCategory{
+	{Key: "forex", Aliases: []string{"fx", "ngoaite"}, Title: "💱 Ngoại tệ", Entries: []symbolEntry{
+		{"eurusd", "Euro / Đô la Mỹ"},
+		{"gbpusd", "Bảng Anh / Đô la Mỹ"},
+		{"usdjpy", "Đô la Mỹ / Yên Nhật"},
+		{"usdvnd", "Đô la Mỹ / Việt Nam Đồng"},
+		{"aud/usd", "Đô la Úc / Đô la Mỹ"},
+		{"usd/cad", "Đô la Mỹ / Đô la Canada"},
+		{"usd/chf", "Đô la Mỹ / Franc Thụy Sĩ"},
+		{"nzd/usd", "Đô la New Zealand / Đô la Mỹ"},
+		{"usd/cny", "Đô la Mỹ / Nhân dân tệ"},
+		{"usd/sgd", "Đô la Mỹ / Đô la Singapore"},
+		{"eur/gbp", "Euro / Bảng Anh"},
+		{"eur/jpy", "Euro / Yên Nhật"},
+	}},
+	{Key: "crypto", Aliases: []string{"coin"}, Title: "₿ Tiền mã hóa", Entries: []symbolEntry{
+		{"btc", "Bitcoin"},
+		{"eth", "Ethereum"},
+		{"sol", "Solana"},
+		{"bnb/usd", "BNB"},
+		{"xrp/usd", "XRP"},
+		{"ada/usd", "Cardano"},
+		{"doge/usd", "Dogecoin"},
+		{"ton/usd", "Toncoin"},
+		{"avax/usd", "Avalanche"},
+		{"link/usd", "Chainlink"},
+		{"dot/usd", "Polkadot"},
+		{"ltc/usd", "Litecoin"},
+	}},
+	{Key: "indices", Aliases: []string{"index", "chiso"}, Title: "📈 Chỉ số", Entries: []symbolEntry{
+		{"spx", "S&P 500"},
+		{"ixic", "Nasdaq Composite"},
+		{"dji", "Dow Jones"},
+		{"spy", "ETF theo S&P 500"},
+		{"qqq", "ETF theo Nasdaq 100"},
+		{"dia", "ETF theo Dow Jones"},
+	}},
+	{Key: "commodities", Aliases: []string{"commodity", "hanghoa"}, Title: "🛢 Hàng hóa", Entries: []symbolEntry{
+		{"gold", "Vàng (XAU/USD)"},
+		{"silver", "Bạc (XAG/USD)"},
+		{"xpt/usd", "Bạch kim"},
+		{"xpd/usd", "Palladium"},
+		{"wti/usd", "Dầu thô WTI"},
+		{"xbr/usd", "Dầu Brent"},
+		{"ng/usd", "Khí tự nhiên"},
+	}},
+	{Key: "stocks", Aliases: []string{"stock", "cophieu"}, Title: "🏢 Cổ phiếu Mỹ", Entries: []symbolEntry{
+		{"aapl", "Apple"},
+		{"msft", "Microsoft"},
+		{"nvda", "Nvidia"},
+		{"tsla", "Tesla"},
+		{"amzn", "Amazon"},
+		{"googl", "Alphabet"},
+		{"meta", "Meta"},
+		{"nflx", "Netflix"},
+		{"amd", "AMD"},
+		{"intc", "Intel"},
+		{"jpm", "JPMorgan Chase"},
+		{"v", "Visa"},
+		{"ko", "Coca-Cola"},
+		{"dis", "Disney"},
+	}},
+}
+
+// --- SYMBOL DIRECTORY ---
+
+// findSymbolCategory looks a category up by key or alias
+func findSymbolCategory(name string) (symbolCategory, bool) {
+	for _, c := range symbolDirectory {
+		if c.Key == name {
+			return c, true
+		}
+		for _, alias := range c.Aliases {
+			if alias == name {
+				return c, true
+			}
+		}
+	}
+	return symbolCategory{}, false
+}
+
+// symbolsReply handles "/symbols" (an overview of every category) and
+// "/symbols crypto [page]" (one category, paginated)
+func symbolsReply(payload string) string {
+	args := strings.Fields(strings.ToLower(payload))
+	if len(args) == 0 {
+		var sb strings.Builder
+		sb.WriteString("📚 **CÁC MÃ ĐƯỢC HỖ TRỢ**\n")
+		for _, c := range symbolDirectory {
+			examples := make([]string, 0, 4)
+			for _, e := range c.Entries[:min(4, len(c.Entries))] {
+				examples = append(examples, "`"+e.ID+"`")
+			}
+			sb.WriteString(fmt.Sprintf("\n%s (%d mã): %s… → /symbols %s", c.Title, len(c.Entries), strings.Join(examples, ", "), c.Key))
+		}
+		sb.WriteString("\n\n_Đây là danh sách ví dụ; các mã khác của nhà cung cấp dữ liệu cũng dùng được. Gõ mã như trên với /watch, /alert hoặc /convert._")
+		return sb.String()
+	}
+	usage := "ℹ️ Cú pháp: /symbols hoặc /symbols forex|crypto|indices|commodities|stocks [trang]"
+	if len(args) > 2 {
+		return usage
+	}
+	c, ok := findSymbolCategory(args[0])
+	if !ok {
+		return usage
+	}
+	pages := (len(c.Entries) + symbolsPageSize - 1) / symbolsPageSize
+	page := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 || n > pages {
+			return fmt.Sprintf("⚠️ Trang không hợp lệ. Mục này có %d trang.", pages)
+		}
+		page = n
+	}
+	start := (page - 1) * symbolsPageSize
+	end := min(start+symbolsPageSize, len(c.Entries))
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s **(trang %d/%d)**\n", c.Title, page, pages))
+	for _, e := range c.Entries[start:end] {
+		sb.WriteString(fmt.Sprintf("\n• `%s` — %s", e.ID, e.Name))
+	}
+	if page < pages {
+		sb.WriteString(fmt.Sprintf("\n\n_Trang tiếp: /symbols %s %d_", c.Key, page+1))
+	}
+	return sb.String()
+}
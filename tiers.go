@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	tele "gopkg.in/telebot.v3"
+)
+
+// Subscription tiers, stored as "tier" on the user document; premium may carry an expiry
+const (
+	tierFree    = "free"
+	tierPremium = "premium"
+)
+
+// freeWatchlistLimit is how many watchlist symbols a free chat may keep
+const freeWatchlistLimit = 3
+
+// Gated features; every feature not listed in featureTiers is available to everyone
+const (
+	featureLargeWatchlist = "large_watchlist"
+	featureKeywordAlerts  = "keyword_alerts"
+)
+
+// featureTiers maps each gated feature to the tier it requires
+var featureTiers = map[string]string{
+	featureLargeWatchlist: tierPremium,
+	featureKeywordAlerts:  tierPremium,
+}
+
+// commandFeatures tells which gated feature (if any) a request would use
+var commandFeatures = map[string]func(r *Request) string{
+	"/watch": func(r *Request) string {
+		if len(r.Args) == 2 && strings.EqualFold(r.Args[0], "add") && r.User != nil &&
+			len(r.User.Watchlist) >= freeWatchlistLimit {
+			return featureLargeWatchlist
+		}
+		return ""
+	},
+	"/import": func(r *Request) string {
+		if r.User == nil || len(r.User.Watchlist) < freeWatchlistLimit {
+			// Below the limit the import itself stops at it, once it knows which symbols are real
+			return ""
+		}
+		replied := ""
+		if r.Message.ReplyTo != nil {
+			replied = r.Message.ReplyTo.Text
+		}
+		_, candidates := importCandidates(splitSymbolList(importText(r.Payload, replied)), r.User.Watchlist)
+		if len(candidates) > 0 {
+			return featureLargeWatchlist
+		}
+		return ""
+	},
+	"/follow": func(r *Request) string {
+		if len(r.Args) > 0 {
+			return featureKeywordAlerts
+		}
+		return ""
+	},
+}
+
+// --- SUBSCRIPTION TIERS ---
+
+// effectiveTier is the tier a user holds at now: premium past its expiry counts as free
+func effectiveTier(tier string, until time.Time, now time.Time) string {
+	if tier != tierPremium || (!until.IsZero() && !now.Before(until)) {
+		return tierFree
+	}
+	return tierPremium
+}
+
+// watchlistLimit is how many watchlist symbols a tier may keep
+func watchlistLimit(tier string) int {
+	if tierAllows(tier, featureLargeWatchlist) {
+		return maxWatchlistSize
+	}
+	return freeWatchlistLimit
+}
+
+// capWatchlist is the part of a watchlist a tier uses: the first watchlistLimit symbols.
+// A lapsed premium chat keeps the rest stored, paused, so renewing brings them back.
+func capWatchlist(list []string, tier string) []string {
+	if limit := watchlistLimit(tier); len(list) > limit {
+		return list[:limit]
+	}
+	return list
+}
+
+// tierAllows reports whether a tier may use feature
+func tierAllows(tier, feature string) bool {
+	required, gated := featureTiers[feature]
+	return !gated || required == tier
+}
+
+// expirePremium downgrades a chat whose premium has lapsed; true only for the call that
+// made the change, so the notice goes out once
+func expirePremium(chatID int64, now time.Time) bool {
+	if userCollection == nil {
+		return false
+	}
+	res, err := userCollection.UpdateOne(context.TODO(),
+		bson.M{"chat_id": chatID, "tier": tierPremium, "premium_until": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"tier": tierFree, "updated_at": now}, "$unset": bson.M{"premium_until": ""}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to expire premium for %d: %v", chatID, err)
+		return false
+	}
+	return res.ModifiedCount > 0
+}
+
+// tierMiddleware enforces featureTiers centrally: a lapsed premium is downgraded (with a
+// one-time notice) and a free chat asking for a gated feature gets the explanation instead
+func tierMiddleware(next HandlerFunc) HandlerFunc {
+	return func(r *Request) error {
+		if r.User == nil {
+			return next(r)
+		}
+		now := clock()
+		tier := effectiveTier(r.User.Tier, r.User.PremiumUntil, now)
+		if tier != r.User.Tier && r.User.Tier == tierPremium && expirePremium(r.ChatID(), now) {
+			r.Reply("ℹ️ Gói premium của bạn đã hết hạn, tài khoản đã chuyển về gói miễn phí. Xem /donate để gia hạn.")
+		}
+		if f, ok := commandFeatures[r.Command]; ok {
+			if feature := f(r); feature != "" && !tierAllows(tier, feature) {
+				return r.Reply(gatedFeatureText(feature))
+			}
+		}
+		return next(r)
+	}
+}
+
+// gatedFeatureText explains why a feature is unavailable on the free tier
+func gatedFeatureText(feature string) string {
+	what := "Tính năng này"
+	switch feature {
+	case featureLargeWatchlist:
+		what = fmt.Sprintf("Danh sách theo dõi trên %d mã", freeWatchlistLimit)
+	case featureKeywordAlerts:
+		what = "Thông báo chủ đề nóng theo từ khóa"
+	}
+	return what + " dành cho gói premium, vì mỗi lần cập nhật tốn thêm lượt gọi dữ liệu. " +
+		"Cảm ơn bạn đã thông cảm! Xem /donate để ủng hộ và nâng cấp."
+}
+
+// premiumChats returns the chats currently holding premium
+func premiumChats() map[int64]bool {
+	out := make(map[int64]bool)
+	if userCollection == nil {
+		return out
+	}
+	now := clock()
+	cursor, err := userCollection.Find(context.TODO(), bson.M{"tier": tierPremium, "$or": []bson.M{
+		{"premium_until": bson.M{"$exists": false}}, {"premium_until": bson.M{"$gt": now}},
+	}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load premium chats: %v", err)
+		return out
+	}
+	defer cursor.Close(context.TODO())
+	for cursor.Next(context.TODO()) {
+		var result struct {
+			ChatID int64 `bson:"chat_id"`
+		}
+		if cursor.Decode(&result) == nil {
+			out[result.ChatID] = true
+		}
+	}
+	return out
+}
+
+// grantReply handles the admin "/grant <chat_id> [YYYY-MM-DD|30d]" (no expiry when
+// omitted) and "/grant <chat_id> off"
+func grantReply(b *tele.Bot, args []string) string {
+	usage := "ℹ️ Cú pháp: /grant <chat_id> [YYYY-MM-DD|30d] hoặc /grant <chat_id> off"
+	if userCollection == nil {
+		return "⚠️ Không có kết nối cơ sở dữ liệu."
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return usage
+	}
+	chatID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return usage
+	}
+	now := clock()
+	update := bson.M{"$set": bson.M{"tier": tierPremium, "updated_at": now}, "$unset": bson.M{"premium_until": ""}}
+	var until time.Time
+	if len(args) == 2 {
+		arg := strings.ToLower(args[1])
+		switch {
+		case arg == "off":
+			update = bson.M{"$set": bson.M{"tier": tierFree, "updated_at": now}, "$unset": bson.M{"premium_until": ""}}
+		case strings.HasSuffix(arg, "d"):
+			days, err := strconv.Atoi(strings.TrimSuffix(arg, "d"))
+			if err != nil || days <= 0 {
+				return usage
+			}
+			until = now.AddDate(0, 0, days)
+		default:
+			t, err := time.ParseInLocation("2006-01-02", arg, vnLocation)
+			if err != nil || !t.After(now) {
+				return usage
+			}
+			until = t
+		}
+	}
+	if !until.IsZero() {
+		update = bson.M{"$set": bson.M{"tier": tierPremium, "premium_until": until, "updated_at": now}}
+	}
+	res, err := userCollection.UpdateOne(context.TODO(), bson.M{"chat_id": chatID}, update)
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to grant premium to %d: %v", chatID, err)
+		return "⚠️ Không thể lưu lúc này."
+	}
+	if res.MatchedCount == 0 {
+		return "⚠️ Không tìm thấy chat này (cần /start trước)."
+	}
+	if len(args) == 2 && strings.EqualFold(args[1], "off") {
+		return fmt.Sprintf("✅ Đã chuyển %d về gói miễn phí.", chatID)
+	}
+	notice := "🎉 Bạn đã được nâng cấp lên gói premium"
+	if !until.IsZero() {
+		notice += " đến " + until.In(vnLocation).Format("02/01/2006")
+	}
+	b.Send(&tele.Chat{ID: chatID}, notice+". Cảm ơn bạn đã ủng hộ!")
+	if until.IsZero() {
+		return fmt.Sprintf("✅ Đã cấp premium cho %d (không thời hạn).", chatID)
+	}
+	return fmt.Sprintf("✅ Đã cấp premium cho %d đến %s.", chatID, until.In(vnLocation).Format("02/01/2006"))
+}
+
+// donateText points users at how to support the bot (DONATE_URL, when configured)
+func donateText() string {
+	text := "💖 Bot miễn phí cho mọi người; gói premium mở danh sách theo dõi dài hơn và thông báo chủ đề nóng. " +
+		"Liên hệ quản trị viên để được nâng cấp sau khi ủng hộ."
+	if link := os.Getenv("DONATE_URL"); link != "" {
+		text += "\nỦng hộ tại: " + link
+	}
+	return text
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+func TestEffectiveTier(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, vnLocation)
+	tests := []struct {
+		tier  string
+		until time.Time
+		want  string
+	}{
+		{tierFree, time.Time{}, tierFree},
+		{"", time.Time{}, tierFree},
+		{tierPremium, time.Time{}, tierPremium},
+		{tierPremium, now.Add(time.Hour), tierPremium},
+		{tierPremium, now, tierFree},
+		{tierPremium, now.Add(-time.Hour), tierFree},
+	}
+	for _, tt := range tests {
+		if got := effectiveTier(tt.tier, tt.until, now); got != tt.want {
+			t.Errorf("effectiveTier(%q, %v) = %q, want %q", tt.tier, tt.until, got, tt.want)
+		}
+	}
+}
+
+func TestCapWatchlist(t *testing.T) {
+	list := []string{"btc", "eth", "sol", "gold", "aapl"}
+	if got := capWatchlist(list, tierFree); !reflect.DeepEqual(got, list[:freeWatchlistLimit]) {
+		t.Errorf("free tier uses %v, want the first %d symbols", got, freeWatchlistLimit)
+	}
+	if got := capWatchlist(list, tierPremium); !reflect.DeepEqual(got, list) {
+		t.Errorf("premium tier uses %v, want all of %v", got, list)
+	}
+	if got := capWatchlist(list[:2], tierFree); len(got) != 2 {
+		t.Errorf("a short list is capped to %v", got)
+	}
+}
+
+func TestImportGate(t *testing.T) {
+	gate := commandFeatures["/import"]
+	tests := []struct {
+		name      string
+		watchlist []string
+		payload   string
+		replied   string
+		want      string
+	}{
+		{"below the limit, the import enforces it", []string{"btc"}, "ETH SOL AAPL MSFT", "", ""},
+		{"at the limit, space separated", []string{"btc", "eth", "sol"}, "AAPL MSFT", "", featureLargeWatchlist},
+		{"at the limit, newline list in the replied message", []string{"btc", "eth", "sol"}, "", "AAPL\nMSFT;TSLA", featureLargeWatchlist},
+		{"at the limit, only symbols already watched", []string{"btc", "eth", "sol"}, "BTC/USD ETH", "", ""},
+		{"lapsed premium over the limit", []string{"btc", "eth", "sol", "gold"}, "GOLD AAPL", "", featureLargeWatchlist},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &tele.Message{}
+			if tt.replied != "" {
+				msg.ReplyTo = &tele.Message{Text: tt.replied}
+			}
+			r := &Request{Message: msg, Command: "/import", Payload: tt.payload, User: &User{Watchlist: tt.watchlist}}
+			if got := gate(r); got != tt.want {
+				t.Errorf("gate = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportCandidates(t *testing.T) {
+	present, candidates := importCandidates(splitSymbolList("btc/usd, ETH\nXAUUSD;aapl AAPL"), []string{"btc"})
+	if !reflect.DeepEqual(present, []string{"btc"}) {
+		t.Errorf("present = %v, want [btc]", present)
+	}
+	if want := []string{"eth", "gold", "aapl"}; !reflect.DeepEqual(candidates, want) {
+		t.Errorf("candidates = %v, want %v", candidates, want)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// --- FORUM TOPICS ---
+
+// loadThreadIDs returns the target topic of every chat that set one
+func loadThreadIDs() map[int64]int {
+	threads := make(map[int64]int)
+	if userCollection == nil {
+		return threads
+	}
+	cursor, err := userCollection.Find(context.TODO(), bson.M{"thread_id": bson.M{"$gt": 0}},
+		options.Find().SetProjection(bson.M{"chat_id": 1, "thread_id": 1}))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load topic settings: %v", err)
+		return threads
+	}
+	defer cursor.Close(context.TODO())
+	for cursor.Next(context.TODO()) {
+		var result struct {
+			ChatID   int64 `bson:"chat_id"`
+			ThreadID int   `bson:"thread_id"`
+		}
+		if cursor.Decode(&result) == nil {
+			threads[result.ChatID] = result.ThreadID
+		}
+	}
+	return threads
+}
+
+// getThreadID returns a single chat's target topic (0 = General / not a forum)
+func getThreadID(chatID int64) int {
+	if userCollection == nil {
+		return 0
+	}
+	var result struct {
+		ThreadID int `bson:"thread_id"`
+	}
+	userCollection.FindOne(context.TODO(), bson.M{"chat_id": chatID},
+		options.FindOne().SetProjection(bson.M{"thread_id": 1})).Decode(&result)
+	return result.ThreadID
+}
+
+// setThreadID stores (or with 0 clears) the chat's target topic; false if not subscribed
+func setThreadID(chatID int64, threadID int) bool {
+	if userCollection == nil {
+		return false
+	}
+	update := bson.M{"$set": bson.M{"thread_id": threadID, "updated_at": clock()}}
+	if threadID == 0 {
+		update = bson.M{"$unset": bson.M{"thread_id": ""}, "$set": bson.M{"updated_at": clock()}}
+	}
+	result, err := userCollection.UpdateOne(context.TODO(), bson.M{"chat_id": chatID}, update)
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to update topic for %d: %v", chatID, err)
+		return false
+	}
+	return result.MatchedCount > 0
+}
+
+// isThreadGone reports whether a send failed because the topic no longer exists
+func isThreadGone(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "thread not found")
+}
+
+// deliver sends to a chat, into its topic when it has one. If the topic was deleted the
+// setting is cleared, the group is told once (in General) and the send is retried there;
+// later sends go to General directly, so the notice never repeats. Flood waits are recorded
+// as a shared backoff, during which sends fail fast with errFloodDeferred. Upgraded groups
+// are migrated to their new ID, and "chat not found" counts towards marking a chat dead.
+func deliver(b *tele.Bot, chatID int64, threadID int, what interface{}, opts *tele.SendOptions) (*tele.Message, error) {
+	o := tele.SendOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	o.ThreadID = threadID
+	chat := &tele.Chat{ID: chatID}
+	// A flood wait hit by any invocation pauses every send until it expires
+	if until := floodBackoffUntil(); clock().Before(until) {
+		return nil, errFloodDeferred
+	}
+	msg, err := b.Send(chat, what, &o)
+	if retryAfter, ok := floodRetryAfter(err); ok {
+		recordFloodWait(retryAfter)
+		return nil, err
+	}
+	// A group upgraded to a supergroup answers with its new ID; move the chat and resend
+	if to, ok := migratedTo(err); ok {
+		log.Printf("[BROADCAST] Chat %d was upgraded to %d, migrating", chatID, to)
+		migrateChatID(chatID, to)
+		chatID, chat = to, &tele.Chat{ID: to}
+		msg, err = b.Send(chat, what, &o)
+	}
+	if errors.Is(err, tele.ErrChatNotFound) {
+		recordChatNotFound(chatID)
+	}
+	if threadID == 0 || !isThreadGone(err) {
+		return msg, err
+	}
+	log.Printf("[BROADCAST] Topic %d in %d is gone, falling back to General", threadID, chatID)
+	setThreadID(chatID, 0)
+	b.Send(chat, "⚠️ Chủ đề nhận bản tin đã bị xóa, bot sẽ gửi vào General. Quản trị viên có thể gõ /setherethread trong chủ đề mới để đổi lại.")
+	o.ThreadID = 0
+	return b.Send(chat, what, &o)
+}
+
+// setHereThreadReply handles "/setherethread", sent by a group admin inside the topic
+// that should receive the bot's posts (sent in General, it resets to General)
+func setHereThreadReply(b *tele.Bot, m *tele.Message) string {
+	// telebot's Chat has no is_forum field; a forum is recognised by topic messages instead
+	if m.Chat.Type != tele.ChatSuperGroup {
+		return "ℹ️ Lệnh này chỉ dùng trong siêu nhóm có bật Chủ đề (Topics)."
+	}
+	if m.Sender == nil {
+		return "⚠️ Không xác định được người gửi."
+	}
+	member, err := b.ChatMemberOf(m.Chat, m.Sender)
+	if err != nil || (member.Role != tele.Administrator && member.Role != tele.Creator) {
+		return "⛔ Chỉ quản trị viên nhóm mới dùng được lệnh này."
+	}
+	threadID := 0
+	if m.TopicMessage {
+		threadID = m.ThreadID
+	}
+	if !setThreadID(m.Chat.ID, threadID) {
+		return "ℹ️ Nhóm cần đăng ký bằng /start trước."
+	}
+	if threadID == 0 {
+		return "✅ Bot sẽ gửi bản tin vào General."
+	}
+	return "✅ Bot sẽ gửi bản tin vào chủ đề này."
+}
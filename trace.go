@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync/atomic"
+)
+
+// traceSpan follows one update through the router: every log line written for it carries
+// the span ID, and outbound fetches made with its context add the bytes they read
+type traceSpan struct {
+	ID    string
+	bytes atomic.Int64
+}
+
+type spanKey struct{}
+
+// --- UPDATE TRACE SPANS ---
+
+// newSpanID returns a short random ID; it only has to tell concurrent updates apart in logs
+func newSpanID() string {
+	return fmt.Sprintf("%08x", rand.Uint32())
+}
+
+// withSpan returns ctx carrying a new span
+func withSpan(ctx context.Context) (context.Context, *traceSpan) {
+	s := &traceSpan{ID: newSpanID()}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// spanFrom returns the span ctx carries, or nil
+func spanFrom(ctx context.Context) *traceSpan {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(spanKey{}).(*traceSpan)
+	return s
+}
+
+// spanTag is " span=ID" for log lines written under ctx, empty outside a span
+func spanTag(ctx context.Context) string {
+	if s := spanFrom(ctx); s != nil {
+		return " span=" + s.ID
+	}
+	return ""
+}
+
+// traceMiddleware opens the update's span and puts it on the request context
+func traceMiddleware(next HandlerFunc) HandlerFunc {
+	return func(r *Request) error {
+		r.Ctx, _ = withSpan(r.Context())
+		return next(r)
+	}
+}
+
+// Context is the request's context, carrying its span once traceMiddleware has run
+func (r *Request) Context() context.Context {
+	if r.Ctx == nil {
+		return context.Background()
+	}
+	return r.Ctx
+}
+
+// Logf logs a line tagged with the request's span
+func (r *Request) Logf(format string, args ...interface{}) {
+	log.Printf(format+"%s", append(args, spanTag(r.Ctx))...)
+}
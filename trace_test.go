@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"regexp"
+	"strings"
+	"testing"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// captureLog collects log output for the rest of the test
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	saved, flags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(saved)
+		log.SetFlags(flags)
+	})
+	return &buf
+}
+
+func TestRequestSpan(t *testing.T) {
+	withDatabase(t, false, nil)
+	f := withFakeHTTP(t)
+	f.set("feed.example", fakeResponse{Status: 200, ContentType: "application/json", Body: `{"ok":true}`})
+	logs := captureLog(t)
+
+	var spans []string
+	h := chain(func(r *Request) error {
+		s := spanFrom(r.Context())
+		if s == nil {
+			t.Fatal("the handler's context has no span")
+		}
+		spans = append(spans, s.ID)
+		_, err := fetchBody(r.Context(), "https://feed.example/x", 0, jsonContentTypes)
+		return err
+	}, traceMiddleware, logMiddleware)
+	for range 2 {
+		if err := h(&Request{Message: &tele.Message{Chat: &tele.Chat{ID: 7}}, Command: "/help", UpdateID: 9}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(spans) != 2 || spans[0] == spans[1] {
+		t.Fatalf("spans = %v, want one per update", spans)
+	}
+
+	out := logs.String()
+	for _, id := range spans {
+		fetch := regexp.MustCompile(`\[HTTP\] feed.example /x read 11 bytes span=` + id + `\n`)
+		command := regexp.MustCompile(`\[COMMAND\] command=/help chat=7 update=9 duration=\S+ bytes=11 err=<nil> span=` + id + `\n`)
+		if !fetch.MatchString(out) || !command.MatchString(out) {
+			t.Errorf("span %s missing from the fetch or command line:\n%s", id, out)
+		}
+	}
+}
+
+func TestLogfWithoutSpan(t *testing.T) {
+	logs := captureLog(t)
+	r := &Request{}
+	r.Logf("[COMMAND] plain %d", 1)
+	if got := logs.String(); got != "[COMMAND] plain 1\n" {
+		t.Errorf("Logf without a span = %q", got)
+	}
+	if r.Context() != context.Background() || spanTag(nil) != "" || strings.Contains(spanTag(context.Background()), "span") {
+		t.Error("a request without a span reported one")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Translation pool defaults; TRANSLATE_CONCURRENCY and TRANSLATE_TIMEOUT override them
+const (
+	defaultTranslateConcurrency = 4
+	defaultTranslateTimeout     = 8 * time.Second
+	// translateBatchDeadline bounds all of a report's translations together
+	translateBatchDeadline = 25 * time.Second
+)
+
+// --- TRANSLATION POOL ---
+
+// translateAll translates titles with at most TRANSLATE_CONCURRENCY calls in flight, each
+// bounded by TRANSLATE_TIMEOUT. Any title that fails, times out or is still queued when
+// ctx ends keeps its English original, so one slow call never sinks the batch.
+func translateAll(ctx context.Context, titles []string) []string {
+	out := make([]string, len(titles))
+	copy(out, titles)
+	workers := envInt("TRANSLATE_CONCURRENCY", defaultTranslateConcurrency)
+	if workers < 1 {
+		workers = 1
+	}
+	timeout := envDuration("TRANSLATE_TIMEOUT", defaultTranslateTimeout)
+
+	start := time.Now()
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, title := range titles {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			// Leave the rest in English rather than queueing work nobody will wait for
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int, title string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			out[i] = translateToVietnamese(ctx, title, timeout)
+		}(i, title)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		log.Printf("[TRANSLATE] Batch cut short after %s: %v", time.Since(start).Round(time.Millisecond), ctx.Err())
+	} else {
+		log.Printf("[TRANSLATE] Translated %d headlines in %s (%d workers)", len(titles), time.Since(start).Round(time.Millisecond), workers)
+	}
+	return out
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCleanTranslation(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+		ok   bool
+	}{
+		{"Giá vàng tăng mạnh", "Giá vàng tăng mạnh", true},
+		{`  "Giá vàng tăng mạnh"  `, "Giá vàng tăng mạnh", true},
+		{`“Giá vàng tăng”`, "Giá vàng tăng", true},
+		{`'"Lồng nhau"'`, "Lồng nhau", true},
+		{`"Chỉ mở ngoặc`, `"Chỉ mở ngoặc`, true},
+		{"<b>Fed</b> giữ lãi suất<br/>", "Fed giữ lãi suất", true},
+		{"S&amp;P 500 lập đỉnh", "S&P 500 lập đỉnh", true},
+		{"Dòng\n  xuống   dòng", "Dòng xuống dòng", true},
+		{"<!DOCTYPE html><html><body>Error</body></html>", "", false},
+		{"<HTML><head></head>Sign in</HTML>", "", false},
+		{`""`, "", false},
+		{"   ", "", false},
+		{"a &lt;script&gt; b", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := cleanTranslation(tt.raw)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("cleanTranslation(%q) = %q, %v; want %q, %v", tt.raw, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTranslateKeepsOriginalOnJunk(t *testing.T) {
+	t.Setenv("GOOGLE_SCRIPT_URL", "https://script.example/exec")
+	f := withFakeHTTP(t)
+	f.set("script.example", fakeResponse{Status: 200, ContentType: "text/plain", Body: `"Vàng tăng giá"`})
+	if got := translateToVietnamese(context.Background(), "Gold rises", time.Second); got != "Vàng tăng giá" {
+		t.Errorf("translation = %q", got)
+	}
+	f.set("script.example", fakeResponse{Status: 200, ContentType: "text/html", Body: "<html><body>Sign in</body></html>"})
+	if got := translateToVietnamese(context.Background(), "Gold rises", time.Second); got != "Gold rises" {
+		t.Errorf("an HTML page replaced the headline with %q", got)
+	}
+}
+
+// scriptAPI stands in for the Apps Script translator: it answers "vi:" plus the text after
+// delay, never answers titles starting with "hang", and records the most calls in flight
+type scriptAPI struct {
+	delay    time.Duration
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (f *scriptAPI) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	f.inFlight++
+	f.peak = max(f.peak, f.inFlight)
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.inFlight--
+		f.mu.Unlock()
+	}()
+	text := req.URL.Query().Get("text")
+	wait := f.delay
+	if strings.HasPrefix(text, "hang") {
+		wait = time.Hour
+	}
+	select {
+	case <-time.After(wait):
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/plain"}},
+		Body:       io.NopCloser(strings.NewReader("vi:" + text)),
+		Request:    req,
+	}, nil
+}
+
+// withScriptAPI routes the translator to api
+func withScriptAPI(t *testing.T, api *scriptAPI) {
+	t.Helper()
+	t.Setenv("GOOGLE_SCRIPT_URL", "https://script.example/exec")
+	saved := http.DefaultTransport
+	http.DefaultTransport = api
+	t.Cleanup(func() { http.DefaultTransport = saved })
+}
+
+func TestTranslateAllBoundsConcurrency(t *testing.T) {
+	api := &scriptAPI{delay: 20 * time.Millisecond}
+	withScriptAPI(t, api)
+	t.Setenv("TRANSLATE_CONCURRENCY", "2")
+	got := translateAll(context.Background(), []string{"a", "b", "c", "d", "e", "f"})
+	if want := []string{"vi:a", "vi:b", "vi:c", "vi:d", "vi:e", "vi:f"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("translateAll = %q, want %q in order", got, want)
+	}
+	if api.peak != 2 {
+		t.Errorf("peak concurrency %d, want 2", api.peak)
+	}
+}
+
+func TestTranslateAllKeepsSlowTitles(t *testing.T) {
+	withScriptAPI(t, &scriptAPI{})
+	t.Setenv("TRANSLATE_TIMEOUT", "50ms")
+	got := translateAll(context.Background(), []string{"a", "hang on", "b"})
+	if want := []string{"vi:a", "hang on", "vi:b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("a timed-out call: translateAll = %q, want %q", got, want)
+	}
+
+	// The batch deadline leaves queued titles in English instead of waiting for them
+	t.Setenv("TRANSLATE_CONCURRENCY", "1")
+	t.Setenv("TRANSLATE_TIMEOUT", "1m")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	got = translateAll(ctx, []string{"hang 1", "hang 2", "hang 3"})
+	if want := []string{"hang 1", "hang 2", "hang 3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after the deadline: translateAll = %q, want the originals", got)
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("the batch took %s after a 50ms deadline", took)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// twelveDataSymbols maps canonical asset IDs onto Twelve Data symbols. IDs missing here
+// are sent upper-cased ("aapl" → "AAPL", "eur/gbp" → "EUR/GBP"). This table and the
+// functions in this file are the only place Twelve Data's naming is known; another quote
+// provider would carry its own.
+var twelveDataSymbols = map[string]string{
+	"gold":   "XAU/USD",
+	"silver": "XAG/USD",
+	"eurusd": "EUR/USD",
+	"gbpusd": "GBP/USD",
+	"usdjpy": "USD/JPY",
+	"usdvnd": "USD/VND",
+	"btc":    "BTC/USD",
+	"eth":    "ETH/USD",
+	"sol":    "SOL/USD",
+}
+
+// --- TWELVE DATA CLIENT ---
+
+// twelveDataSymbol returns the Twelve Data symbol for a canonical asset ID
+func twelveDataSymbol(id string) string {
+	if s, ok := twelveDataSymbols[id]; ok {
+		return s
+	}
+	return strings.ToUpper(id)
+}
+
+// canonicalFromTwelveData maps a Twelve Data symbol back to its canonical asset ID
+func canonicalFromTwelveData(symbol string) string {
+	symbol = strings.ToUpper(symbol)
+	for id, s := range twelveDataSymbols {
+		if s == symbol {
+			return id
+		}
+	}
+	return strings.ToLower(symbol)
+}
+
+// getMarketData fetches financial data for a canonical asset ID from Twelve Data API
+func getMarketData(symbol string, apiKey string) MarketData {
+	log.Printf("[API] Fetching quote for %s...", symbol)
+	apiUrl := twelveDataURL("quote", url.Values{"symbol": {twelveDataSymbol(symbol)}, "apikey": {apiKey}})
+	countAPICall("quote")
+	// Explicit timeout to prevent Lambda from hanging
+	body, err := fetchBody(context.Background(), apiUrl, 15*time.Second, jsonContentTypes)
+	if err != nil {
+		log.Printf("[API ERROR] Request failed for %s: %v", symbol, err)
+		return quoteFetchError(err)
+	}
+	return parseQuote(symbol, body)
+}
+
+// Batch quote limits: Twelve Data takes at most 120 symbols per /quote call; larger lists are
+// split into chunks fetched a few at a time so a burst doesn't trip the per-minute limit
+const (
+	defaultQuoteBatchSize        = 120
+	defaultQuoteBatchConcurrency = 2
+)
+
+// getMarketDataBatch quotes any number of canonical IDs. Symbols pinned to another provider
+// (/source) are tried there first; the rest, and any whose provider failed, go to Twelve
+// Data in chunks of QUOTE_BATCH_SIZE fetched concurrently (at most QUOTE_BATCH_CONCURRENCY
+// at once) and merged.
+func getMarketDataBatch(symbols []string, apiKey string) map[string]MarketData {
+	overridden, rest := quoteOverridden(symbols, loadConfig().SourceOverrides)
+	quotes := fetchTwelveDataBatch(rest, apiKey)
+	for symbol, d := range overridden {
+		quotes[symbol] = d
+	}
+	return quotes
+}
+
+// fetchTwelveDataBatch quotes symbols from Twelve Data in concurrent chunks
+func fetchTwelveDataBatch(symbols []string, apiKey string) map[string]MarketData {
+	size := envInt("QUOTE_BATCH_SIZE", defaultQuoteBatchSize)
+	if size <= 0 || size > defaultQuoteBatchSize {
+		size = defaultQuoteBatchSize
+	}
+	if len(symbols) <= size {
+		return fetchQuoteChunk(symbols, apiKey)
+	}
+	log.Printf("[API] Splitting %d symbols into batches of %d", len(symbols), size)
+	quotes := make(map[string]MarketData, len(symbols))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, max(1, envInt("QUOTE_BATCH_CONCURRENCY", defaultQuoteBatchConcurrency)))
+	for start := 0; start < len(symbols); start += size {
+		chunk := symbols[start:min(start+size, len(symbols))]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			part := fetchQuoteChunk(chunk, apiKey)
+			mu.Lock()
+			for symbol, d := range part {
+				quotes[symbol] = d
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return quotes
+}
+
+// fetchQuoteChunk quotes several canonical IDs with one /quote call (Twelve Data accepts
+// a comma-separated list and answers with an object keyed by its own symbols)
+func fetchQuoteChunk(symbols []string, apiKey string) map[string]MarketData {
+	quotes := make(map[string]MarketData, len(symbols))
+	if len(symbols) == 1 {
+		quotes[symbols[0]] = getMarketData(symbols[0], apiKey)
+		return quotes
+	}
+	if len(symbols) == 0 {
+		return quotes
+	}
+	log.Printf("[API] Fetching batch quote for %d symbols...", len(symbols))
+	providerSymbols := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		providerSymbols[i] = twelveDataSymbol(symbol)
+	}
+	apiUrl := twelveDataURL("quote", url.Values{"symbol": {strings.Join(providerSymbols, ",")}, "apikey": {apiKey}})
+	countAPICall("quote")
+	body, err := fetchBody(context.Background(), apiUrl, 15*time.Second, jsonContentTypes)
+	if err != nil {
+		log.Printf("[API ERROR] Batch request failed: %v", err)
+		for _, symbol := range symbols {
+			quotes[symbol] = quoteFetchError(err)
+		}
+		return quotes
+	}
+	var bySymbol map[string]json.RawMessage
+	if err := json.Unmarshal(body, &bySymbol); err != nil {
+		log.Printf("[API ERROR] Malformed batch quote: %v", err)
+	}
+	for i, symbol := range symbols {
+		raw, ok := bySymbol[providerSymbols[i]]
+		if !ok {
+			// A top-level error (e.g. out of credits) comes back unkeyed
+			quotes[symbol] = parseQuote(symbol, body)
+			continue
+		}
+		quotes[symbol] = parseQuote(symbol, raw)
+	}
+	return quotes
+}
+
+// quoteFetchError maps a failed request to MarketData, keeping 429s recognisable
+func quoteFetchError(err error) MarketData {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusTooManyRequests {
+		return MarketData{Price: 0, Change: "N/A", Err: errRateLimited}
+	}
+	return MarketData{Price: 0, Change: "0.00%", Err: err}
+}
+
+// parseQuote decodes one /quote object
+func parseQuote(symbol string, body []byte) MarketData {
+	var result struct {
+		Close         FlexFloat `json:"close"`
+		Open          FlexFloat `json:"open"`
+		PercentChange FlexFloat `json:"percent_change"`
+		Code          int       `json:"code"`
+		Message       string    `json:"message"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		log.Printf("[API ERROR] Malformed quote for %s: %v", symbol, err)
+		return MarketData{Price: 0, Change: "N/A", Err: err}
+	}
+
+	if result.Message != "" {
+		log.Printf("[API ERROR] Message from TwelveData for %s: %s", symbol, result.Message)
+		switch result.Code {
+		case http.StatusTooManyRequests:
+			return MarketData{Price: 0, Change: "N/A", Err: errRateLimited}
+		case http.StatusBadRequest, http.StatusNotFound:
+			return MarketData{Price: 0, Change: "N/A", Err: fmt.Errorf("%w: %s", errUnknownSymbol, result.Message)}
+		case http.StatusForbidden:
+			return MarketData{Price: 0, Change: "N/A", Err: fmt.Errorf("%w: %s", errPlanRestricted, result.Message)}
+		}
+		return MarketData{Price: 0, Change: "N/A", Err: fmt.Errorf("twelvedata: %s", result.Message)}
+	}
+
+	p := result.Close.Value
+	if p == 0 {
+		return MarketData{Price: 0, Change: "N/A", Err: fmt.Errorf("twelvedata: empty quote for %s", symbol)}
+	}
+	c := result.PercentChange.Value
+	return MarketData{Price: p, Change: formatPercent(c), Percent: c, HasPercent: result.PercentChange.Valid, Open: result.Open.Value}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchQuoteAPI answers /quote for any comma-separated symbol list with a price of 1 per
+// symbol, recording each call's list size and the most calls in flight. Symbols in fail get
+// the out-of-credits error instead.
+type batchQuoteAPI struct {
+	mu       sync.Mutex
+	sizes    []int
+	inFlight int
+	peak     int
+	fail     map[string]bool
+}
+
+func (f *batchQuoteAPI) RoundTrip(req *http.Request) (*http.Response, error) {
+	symbols := strings.Split(req.URL.Query().Get("symbol"), ",")
+	f.mu.Lock()
+	f.sizes = append(f.sizes, len(symbols))
+	f.inFlight++
+	f.peak = max(f.peak, f.inFlight)
+	failed := f.fail[symbols[0]]
+	f.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	f.mu.Lock()
+	f.inFlight--
+	f.mu.Unlock()
+
+	body := `{"code":429,"message":"You have run out of API credits"}`
+	if !failed {
+		quotes := make(map[string]map[string]string, len(symbols))
+		for _, s := range symbols {
+			quotes[s] = map[string]string{"symbol": s, "close": "1", "percent_change": "0.5"}
+		}
+		raw, _ := json.Marshal(quotes)
+		body = string(raw)
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func withBatchQuoteAPI(t *testing.T, api *batchQuoteAPI) {
+	t.Helper()
+	saved := http.DefaultTransport
+	http.DefaultTransport = api
+	t.Cleanup(func() { http.DefaultTransport = saved })
+}
+
+func testSymbols(n int) []string {
+	symbols := make([]string, n)
+	for i := range symbols {
+		symbols[i] = fmt.Sprintf("t%03d", i)
+	}
+	return symbols
+}
+
+func TestGetMarketDataBatchChunks(t *testing.T) {
+	api := &batchQuoteAPI{}
+	withBatchQuoteAPI(t, api)
+	t.Setenv("QUOTE_BATCH_SIZE", "10")
+	t.Setenv("QUOTE_BATCH_CONCURRENCY", "2")
+
+	symbols := testSymbols(25)
+	quotes := getMarketDataBatch(symbols, "key")
+	if len(quotes) != len(symbols) {
+		t.Fatalf("got %d quotes, want %d", len(quotes), len(symbols))
+	}
+	for _, s := range symbols {
+		if d := quotes[s]; d.Err != nil || d.Price != 1 {
+			t.Errorf("%s = %+v, want the merged quote", s, d)
+		}
+	}
+	sort.Ints(api.sizes)
+	if fmt.Sprint(api.sizes) != "[5 10 10]" {
+		t.Errorf("chunk sizes %v, want [5 10 10]", api.sizes)
+	}
+	if api.peak != 2 {
+		t.Errorf("peak concurrency %d, want 2", api.peak)
+	}
+}
+
+func TestGetMarketDataBatchLimits(t *testing.T) {
+	api := &batchQuoteAPI{fail: map[string]bool{"T010": true}}
+	withBatchQuoteAPI(t, api)
+	// Sizes above Twelve Data's 120-symbol limit are clamped to it
+	t.Setenv("QUOTE_BATCH_SIZE", "500")
+	getMarketDataBatch(testSymbols(130), "key")
+	sort.Ints(api.sizes)
+	if fmt.Sprint(api.sizes) != "[10 120]" {
+		t.Errorf("chunk sizes %v, want [10 120]", api.sizes)
+	}
+
+	// A failed chunk marks only its own symbols
+	api.sizes = nil
+	t.Setenv("QUOTE_BATCH_SIZE", "10")
+	quotes := getMarketDataBatch(testSymbols(20), "key")
+	if quotes["t010"].Err != errRateLimited || quotes["t019"].Err != errRateLimited {
+		t.Errorf("the failed chunk = %+v, want rate limited", quotes["t010"])
+	}
+	if quotes["t000"].Err != nil || quotes["t009"].Price != 1 {
+		t.Errorf("the other chunk = %+v, want it quoted", quotes["t000"])
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	tele "gopkg.in/telebot.v3"
+)
+
+// editedCommandWindow is how soon after sending an edit still counts as a new command
+// ("/updtae" fixed into "/report")
+const editedCommandWindow = 30 * time.Second
+
+// --- EDITED MESSAGES, CHANNEL POSTS, MEMBERSHIP ---
+
+// isCommandText reports whether text is addressed to the command router
+func isCommandText(text string) bool {
+	return strings.HasPrefix(strings.TrimSpace(text), "/")
+}
+
+// handleEditedMessage re-dispatches a command that was edited shortly after it was sent;
+// older edits and edits of plain text are ignored
+func handleEditedMessage(b *tele.Bot, updateID int, m *tele.Message) {
+	if m == nil || !isCommandText(m.Text) {
+		return
+	}
+	if age := time.Duration(m.LastEdit-m.Unixtime) * time.Second; m.LastEdit == 0 || age > editedCommandWindow {
+		log.Printf("[UPDATE] Ignoring edit of message %d in %d made %s after sending", m.ID, m.Chat.ID, age)
+		return
+	}
+	log.Printf("[UPDATE] Edited command in %d: %s", m.Chat.ID, m.Text)
+	dispatchCommand(b, updateID, m)
+}
+
+// channelOwner returns the creator of a channel when they hold the owner role, or 0.
+// Channel posts carry no sender, so the channel's creator stands in for whoever posted.
+func channelOwner(b *tele.Bot, chat *tele.Chat) int64 {
+	admins, err := b.AdminsOf(chat)
+	if err != nil {
+		log.Printf("[UPDATE] Could not list admins of channel %d: %v", chat.ID, err)
+		return 0
+	}
+	for _, m := range admins {
+		if m.Role == tele.Creator && m.User != nil && hasRole(m.User.ID, roleOwner) {
+			return m.User.ID
+		}
+	}
+	return 0
+}
+
+// handleChannelPost runs commands posted in a channel the bot owner created, with the
+// owner's roles; everything else in channels is ignored without a reply
+func handleChannelPost(b *tele.Bot, updateID int, m *tele.Message) {
+	if m == nil || m.Chat == nil || !isCommandText(m.Text) {
+		return
+	}
+	owner := channelOwner(b, m.Chat)
+	if owner == 0 {
+		log.Printf("[UPDATE] Ignoring command in channel %d not owned by a bot owner", m.Chat.ID)
+		return
+	}
+	log.Printf("[UPDATE] Channel command in %d by owner %d: %s", m.Chat.ID, owner, m.Text)
+	dispatchAs(b, updateID, m, owner)
+}
+
+// handleMyChatMember records the bot being added to, removed from or blocked in a chat
+// on its user document (when there is one). A chat the bot was kicked from or left is
+// marked dead; a subscribed chat that adds it back is revived, and groups that just added
+// it are greeted.
+func handleMyChatMember(b *tele.Bot, u *tele.ChatMemberUpdate) {
+	if u == nil || u.Chat == nil || u.NewChatMember == nil {
+		return
+	}
+	status := string(u.NewChatMember.Role)
+	log.Printf("[UPDATE] Bot status in %d (%s) is now %s", u.Chat.ID, u.Chat.Type, status)
+	if userCollection != nil {
+		_, err := userCollection.UpdateOne(context.TODO(), bson.M{"chat_id": u.Chat.ID},
+			bson.M{"$set": bson.M{"bot_status": status, "bot_status_at": clock(), "chat_type": string(u.Chat.Type)}})
+		if err != nil {
+			log.Printf("[DATABASE ERROR] Failed to record bot status for %d: %v", u.Chat.ID, err)
+		}
+	}
+	wasIn := u.OldChatMember != nil && u.OldChatMember.Role != tele.Left && u.OldChatMember.Role != tele.Kicked
+	isIn := u.NewChatMember.Role == tele.Member || u.NewChatMember.Role == tele.Administrator
+	isOut := u.NewChatMember.Role == tele.Left || u.NewChatMember.Role == tele.Kicked
+	switch {
+	case isOut:
+		markChatGone(u.Chat.ID)
+	case !wasIn && isIn:
+		reviveChat(u.Chat.ID)
+	}
+	if !wasIn && isIn && (u.Chat.Type == tele.ChatGroup || u.Chat.Type == tele.ChatSuperGroup) {
+		b.Send(u.Chat, "👋 Cảm ơn đã thêm bot vào nhóm! Gõ /start để nhóm nhận bản tin thị trường, /help để xem các lệnh.")
+	}
+}
+
+// markChatGone leaves a chat the bot can no longer post in out of the broadcast audience
+func markChatGone(chatID int64) {
+	if store == nil {
+		return
+	}
+	if err := store.MarkDead(context.TODO(), chatID, clock()); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to mark chat %d dead: %v", chatID, err)
+	}
+}
+
+// reviveChat brings a subscribed chat back into the audience once the bot is back in it.
+// Being added never subscribes a chat on its own; that still takes /start.
+func reviveChat(chatID int64) {
+	if store == nil {
+		return
+	}
+	ctx := context.TODO()
+	_, subscribed, err := store.Watchlist(ctx, chatID)
+	if err != nil || !subscribed {
+		return
+	}
+	if _, err := store.SaveUser(ctx, chatID, clock()); err != nil {
+		log.Printf("[DATABASE ERROR] Failed to revive chat %d: %v", chatID, err)
+	}
+}
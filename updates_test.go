@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// groupChatID is the supergroup of the kicked.json and readded.json fixtures
+const groupChatID = -1001000000042
+
+// loadUpdate decodes a webhook update from testdata/updates
+func loadUpdate(t *testing.T, name string) tele.Update {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", "updates", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var update tele.Update
+	if err := json.Unmarshal(raw, &update); err != nil {
+		t.Fatalf("decoding %s: %v", name, err)
+	}
+	return update
+}
+
+func TestEditedCommandWindow(t *testing.T) {
+	tests := []struct {
+		fixture string
+		reruns  bool
+	}{
+		// Edited 12 seconds after sending
+		{"edited_recent.json", true},
+		// Edited two minutes after sending
+		{"edited_stale.json", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			b, api := routerTest(t)
+			handleUpdate(b, loadUpdate(t, tt.fixture))
+			sent := api.sent()
+			if tt.reruns && (len(sent) != 1 || sent[0] != helpMessage) {
+				t.Errorf("edit inside the window replied %q, want the help text", sent)
+			}
+			if !tt.reruns && len(sent) != 0 {
+				t.Errorf("edit outside the window replied %q", sent)
+			}
+		})
+	}
+}
+
+func TestChannelPostIgnored(t *testing.T) {
+	b, api := routerTest(t)
+	handleUpdate(b, loadUpdate(t, "channel_post.json"))
+	if sent := api.sent(); len(sent) != 0 {
+		t.Errorf("a channel not created by an owner got %q", sent)
+	}
+}
+
+func TestMyChatMemberKickAndReAdd(t *testing.T) {
+	b, api := routerTest(t)
+	mem := newMemoryStore()
+	store = mem
+	ctx := context.Background()
+	mem.SaveUser(ctx, groupChatID, clock())
+	subscribed := func() bool {
+		ids, _ := mem.Subscribers(ctx, groupChatID-1, 10)
+		return len(ids) == 1 && ids[0] == groupChatID
+	}
+
+	handleUpdate(b, loadUpdate(t, "kicked.json"))
+	if subscribed() {
+		t.Fatal("the chat that kicked the bot is still in the audience")
+	}
+	if sent := api.sent(); len(sent) != 0 {
+		t.Errorf("kicking the bot sent %q", sent)
+	}
+
+	handleUpdate(b, loadUpdate(t, "readded.json"))
+	if !subscribed() {
+		t.Error("adding the bot back did not revive the chat")
+	}
+	if sent := api.sent(); len(sent) != 1 || !strings.HasPrefix(sent[0], "👋") {
+		t.Errorf("re-added group got %q, want one greeting", sent)
+	}
+}
+
+func TestMyChatMemberAddDoesNotSubscribe(t *testing.T) {
+	b, _ := routerTest(t)
+	mem := newMemoryStore()
+	store = mem
+	handleUpdate(b, loadUpdate(t, "readded.json"))
+	if _, ok, _ := mem.Watchlist(context.Background(), groupChatID); ok {
+		t.Error("adding the bot subscribed a chat that never ran /start")
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// Unrecognized-input capture limits
+const (
+	maxUnknownInputLen     = 32
+	maxUnknownInputsPerDay = 100
+	usageReportDays        = 7
+	usageReportTopUnknown  = 10
+)
+
+var (
+	usageCollection        *mongo.Collection
+	unknownInputCollection *mongo.Collection
+)
+
+// --- USAGE ANALYTICS ---
+
+// usageDay is the Vietnam-time day a command is counted under
+func usageDay(t time.Time) string {
+	return t.In(vnLocation).Format("2006-01-02")
+}
+
+// recordCommandUsage bumps the per-day counter for a command
+func recordCommandUsage(command string) {
+	if usageCollection == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := usageCollection.UpdateOne(ctx,
+		bson.M{"day": usageDay(clock()), "command": command},
+		bson.M{"$inc": bson.M{"count": 1}}, options.Update().SetUpsert(true))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to count %s: %v", command, err)
+	}
+}
+
+// sanitizeInput keeps a short, single-line, printable prefix of what a user typed
+func sanitizeInput(text string) string {
+	var sb strings.Builder
+	n := 0
+	space := false
+	for _, r := range strings.TrimSpace(text) {
+		if n >= maxUnknownInputLen {
+			sb.WriteString("…")
+			break
+		}
+		switch {
+		case unicode.IsSpace(r):
+			if space {
+				continue
+			}
+			space = true
+			r = ' '
+		case !unicode.IsPrint(r):
+			continue
+		default:
+			space = false
+		}
+		sb.WriteRune(r)
+		n++
+	}
+	return sb.String()
+}
+
+// recordUnknownInput counts text that hit the invalid-command branch. Only private chats
+// are recorded, and each day keeps at most maxUnknownInputsPerDay distinct strings.
+func recordUnknownInput(m *tele.Message) {
+	if unknownInputCollection == nil || m.Chat.Type != tele.ChatPrivate {
+		return
+	}
+	text := sanitizeInput(m.Text)
+	if text == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	day := usageDay(clock())
+	filter := bson.M{"day": day, "text": text}
+	res, err := unknownInputCollection.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"count": 1}})
+	if err != nil || res.MatchedCount > 0 {
+		return
+	}
+	distinct, err := unknownInputCollection.CountDocuments(ctx, bson.M{"day": day})
+	if err != nil || distinct >= maxUnknownInputsPerDay {
+		return
+	}
+	unknownInputCollection.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"count": 1}}, options.Update().SetUpsert(true))
+}
+
+// usageReply renders the last week of command counts and the top unrecognized inputs (admin /usage)
+func usageReply() string {
+	if usageCollection == nil || unknownInputCollection == nil {
+		return "⚠️ Không có kết nối cơ sở dữ liệu."
+	}
+	today := usageDay(clock())
+	since := usageDay(clock().AddDate(0, 0, -(usageReportDays - 1)))
+	filter := bson.M{"day": bson.M{"$gte": since}}
+
+	type row struct {
+		Day     string `bson:"day"`
+		Command string `bson:"command"`
+		Text    string `bson:"text"`
+		Count   int    `bson:"count"`
+	}
+	var commands, unknown []row
+	if cursor, err := usageCollection.Find(context.TODO(), filter); err == nil {
+		cursor.All(context.TODO(), &commands)
+	}
+	if cursor, err := unknownInputCollection.Find(context.TODO(), filter); err == nil {
+		cursor.All(context.TODO(), &unknown)
+	}
+
+	weekly := make(map[string]int)
+	daily := make(map[string]int)
+	for _, r := range commands {
+		weekly[r.Command] += r.Count
+		if r.Day == today {
+			daily[r.Command] += r.Count
+		}
+	}
+	names := make([]string, 0, len(weekly))
+	for name := range weekly {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if weekly[names[i]] != weekly[names[j]] {
+			return weekly[names[i]] > weekly[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "📊 Sử dụng lệnh %d ngày qua (từ %s)\n\n", usageReportDays, since)
+	if len(names) == 0 {
+		sb.WriteString("Chưa có dữ liệu.\n")
+	}
+	fmt.Fprintf(&sb, "%-16s %6s %6s\n", "Lệnh", "7 ngày", "Hôm nay")
+	for _, name := range names {
+		fmt.Fprintf(&sb, "%-16s %6d %6d\n", name, weekly[name], daily[name])
+	}
+
+	counts := make(map[string]int)
+	for _, r := range unknown {
+		counts[r.Text] += r.Count
+	}
+	texts := make([]string, 0, len(counts))
+	for t := range counts {
+		texts = append(texts, t)
+	}
+	sort.Slice(texts, func(i, j int) bool {
+		if counts[texts[i]] != counts[texts[j]] {
+			return counts[texts[i]] > counts[texts[j]]
+		}
+		return texts[i] < texts[j]
+	})
+	if len(texts) > usageReportTopUnknown {
+		texts = texts[:usageReportTopUnknown]
+	}
+	sb.WriteString("\n❓ Nhập không nhận ra nhiều nhất (chỉ chat riêng):\n")
+	if len(texts) == 0 {
+		sb.WriteString("—\n")
+	}
+	for _, t := range texts {
+		fmt.Fprintf(&sb, "%4d × %q\n", counts[t], t)
+	}
+	return sb.String()
+}
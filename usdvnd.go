@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// vcbRatesURL is Vietcombank's public exchange-rate XML, the last live source before the
+// configured constant
+const vcbRatesURL = "https://portal.vietcombank.com.vn/Usercontrols/TVPortal.TyGia/pXML.aspx"
+
+// Default sanity band for a USD/VND rate; anything outside is treated as a bad source
+const (
+	defaultUsdVndMin = 20000
+	defaultUsdVndMax = 35000
+)
+
+// Where a USD/VND rate came from, best first
+const (
+	rateSourceLive     = "live"
+	rateSourceCache    = "cache"
+	rateSourceStale    = "stale"
+	rateSourceStored   = "stored"
+	rateSourceVCB      = providerVCB
+	rateSourceFallback = "fallback"
+)
+
+var xmlContentTypes = []string{"application/xml", "text/xml", "text/html"}
+
+var (
+	usdVndMu        sync.Mutex
+	cachedUsdVnd    float64
+	lastCacheUpdate time.Time
+	// cachedUsdVndSource is the override provider the cached rate came from, "" for Twelve Data
+	cachedUsdVndSource string
+)
+
+// storedUsdVnd reads the last known good rate; tests swap it for a fixed one
+var storedUsdVnd = loadStoredUsdVnd
+
+// UsdVndRate is a rate with its provenance; At is when it was fetched
+type UsdVndRate struct {
+	Rate   float64
+	Source string
+	At     time.Time
+}
+
+// --- USD/VND RATE ---
+
+// usdVndDocID is the settings document holding the last known good rate
+func usdVndDocID() string {
+	return activeProfile().collectionName("usdvnd_rate")
+}
+
+// usdVndInBand reports whether rate passes the USDVND_MIN..USDVND_MAX sanity check
+func usdVndInBand(rate float64) bool {
+	return rate >= envFloat("USDVND_MIN", defaultUsdVndMin) && rate <= envFloat("USDVND_MAX", defaultUsdVndMax)
+}
+
+// getUsdVndRate walks the rate chain: fresh in-memory cache, the /source override if one
+// is set, Twelve Data, then on failure the expired in-memory rate, the last rate stored in
+// MongoDB, Vietcombank and finally USDVND_FALLBACK. Each candidate must pass the sanity
+// band or the next source is tried. The error is set only when no source produced a rate.
+func getUsdVndRate(apiKey string) (UsdVndRate, error) {
+	override := loadConfig().SourceOverrides["usdvnd"]
+	if override == providerTwelveData {
+		override = ""
+	}
+	usdVndMu.Lock()
+	rate, at, source := cachedUsdVnd, lastCacheUpdate, cachedUsdVndSource
+	usdVndMu.Unlock()
+	// A rate cached from an override only counts while that override is still set
+	if clock().Sub(at) < loadConfig().UsdVndCacheTTL && rate > 0 && source == override {
+		log.Println("[CACHE] Using cached USD/VND rate")
+		countCache(true)
+		if source != "" {
+			return UsdVndRate{Rate: rate, Source: source, At: at}, nil
+		}
+		return UsdVndRate{Rate: rate, Source: rateSourceCache, At: at}, nil
+	}
+	countCache(false)
+
+	if override != "" {
+		d := quoteProviders[override].Quote("usdvnd")
+		if d.Err == nil && usdVndInBand(d.Price) {
+			now := clock()
+			usdVndMu.Lock()
+			cachedUsdVnd, lastCacheUpdate, cachedUsdVndSource = d.Price, now, override
+			usdVndMu.Unlock()
+			return UsdVndRate{Rate: d.Price, Source: override, At: now}, nil
+		}
+		log.Printf("[USDVND] Source override %s failed, using the default chain: %v", override, d.Err)
+		countFallback("usdvnd", providerTwelveData)
+	}
+
+	data := getMarketData("usdvnd", apiKey)
+	switch {
+	case data.Err != nil:
+		log.Printf("[USDVND] Twelve Data failed: %v", data.Err)
+	case !usdVndInBand(data.Price):
+		log.Printf("[USDVND] Rejected Twelve Data rate %g outside the sanity band", data.Price)
+	default:
+		now := clock()
+		usdVndMu.Lock()
+		cachedUsdVnd, lastCacheUpdate, cachedUsdVndSource = data.Price, now, ""
+		usdVndMu.Unlock()
+		storeUsdVnd(data.Price, now)
+		return UsdVndRate{Rate: data.Price, Source: rateSourceLive, At: now}, nil
+	}
+
+	if rate > 0 && usdVndInBand(rate) {
+		countFallback("usdvnd", rateSourceStale)
+		return UsdVndRate{Rate: rate, Source: rateSourceStale, At: at}, nil
+	}
+	if stored, ok := storedUsdVnd(); ok {
+		countFallback("usdvnd", stored.Source)
+		return stored, nil
+	}
+	if vcb, err := fetchVCBUsdVnd(); err != nil {
+		log.Printf("[USDVND] Vietcombank failed: %v", err)
+	} else if !usdVndInBand(vcb) {
+		log.Printf("[USDVND] Rejected Vietcombank rate %g outside the sanity band", vcb)
+	} else {
+		countFallback("usdvnd", rateSourceVCB)
+		return UsdVndRate{Rate: vcb, Source: rateSourceVCB, At: clock()}, nil
+	}
+	if fallback := envFloat("USDVND_FALLBACK", 0); fallback > 0 && usdVndInBand(fallback) {
+		log.Printf("[USDVND] Using USDVND_FALLBACK %g", fallback)
+		countFallback("usdvnd", rateSourceFallback)
+		return UsdVndRate{Rate: fallback, Source: rateSourceFallback}, nil
+	}
+	return UsdVndRate{}, fmt.Errorf("no USD/VND rate from any source")
+}
+
+// getCachedUsdVnd returns the best available rate. A configured constant is returned with
+// an error, so callers that act on the rate (portfolio alerts) can refuse it while reports
+// still render it.
+func getCachedUsdVnd(apiKey string) (float64, error) {
+	r, err := getUsdVndRate(apiKey)
+	if err != nil {
+		return 0, err
+	}
+	if r.Source == rateSourceFallback {
+		return r.Rate, fmt.Errorf("USD/VND rate is the configured fallback")
+	}
+	return r.Rate, nil
+}
+
+// storeUsdVnd persists a live rate as the last known good one
+func storeUsdVnd(rate float64, at time.Time) {
+	if settingsCollection == nil {
+		return
+	}
+	_, err := settingsCollection.UpdateOne(context.TODO(), bson.M{"_id": usdVndDocID()},
+		bson.M{"$set": bson.M{"rate": rate, "at": at}}, options.Update().SetUpsert(true))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to store USD/VND rate: %v", err)
+	}
+}
+
+// loadStoredUsdVnd returns the last known good rate from MongoDB
+func loadStoredUsdVnd() (UsdVndRate, bool) {
+	if settingsCollection == nil {
+		return UsdVndRate{}, false
+	}
+	var doc struct {
+		Rate float64   `bson:"rate"`
+		At   time.Time `bson:"at"`
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := settingsCollection.FindOne(ctx, bson.M{"_id": usdVndDocID()}).Decode(&doc); err != nil {
+		return UsdVndRate{}, false
+	}
+	if !usdVndInBand(doc.Rate) {
+		log.Printf("[USDVND] Rejected stored rate %g outside the sanity band", doc.Rate)
+		return UsdVndRate{}, false
+	}
+	return UsdVndRate{Rate: doc.Rate, Source: rateSourceStored, At: doc.At}, true
+}
+
+// fetchVCBUsdVnd reads the USD transfer rate from Vietcombank's exchange-rate XML
+func fetchVCBUsdVnd() (float64, error) {
+	body, err := fetchBody(context.Background(), vcbRatesURL, 10*time.Second, xmlContentTypes)
+	if err != nil {
+		return 0, err
+	}
+	var doc struct {
+		Rates []struct {
+			Code     string `xml:"CurrencyCode,attr"`
+			Transfer string `xml:"Transfer,attr"`
+		} `xml:"Exrate"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return 0, err
+	}
+	for _, r := range doc.Rates {
+		if r.Code == "USD" {
+			return strconv.ParseFloat(strings.ReplaceAll(r.Transfer, ",", ""), 64)
+		}
+	}
+	return 0, fmt.Errorf("no USD rate in Vietcombank response")
+}
+
+// usdVndNote annotates a rate that isn't fresh from Twelve Data, for the report line
+func usdVndNote(r UsdVndRate) string {
+	switch r.Source {
+	case rateSourceStale, rateSourceStored:
+		return fmt.Sprintf(" _(tỷ giá lưu lúc %s)_", r.At.In(vnLocation).Format("15:04 02/01"))
+	case rateSourceVCB:
+		return " _(theo Vietcombank)_"
+	case rateSourceFallback:
+		return " _(ước tính, không lấy được tỷ giá)_"
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const (
+	twelveDataHost = "api.twelvedata.com"
+	vcbHost        = "portal.vietcombank.com.vn"
+)
+
+// usdVndChain isolates getUsdVndRate: a fixed clock, an empty in-memory cache, no stored
+// rate and fake Twelve Data and Vietcombank hosts that fail until a test sets them
+func usdVndChain(t *testing.T) *fakeHTTP {
+	t.Helper()
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, vnLocation)
+	savedClock, savedStored := clock, storedUsdVnd
+	clock = func() time.Time { return now }
+	storedUsdVnd = func() (UsdVndRate, bool) { return UsdVndRate{}, false }
+	setUsdVndCache(0, time.Time{})
+	t.Cleanup(func() {
+		clock, storedUsdVnd = savedClock, savedStored
+		setUsdVndCache(0, time.Time{})
+	})
+	t.Setenv("USDVND_FALLBACK", "")
+	f := withFakeHTTP(t)
+	f.set(twelveDataHost, fakeResponse{Err: errors.New("connection reset")})
+	f.set(vcbHost, fakeResponse{Err: errors.New("connection reset")})
+	return f
+}
+
+func setUsdVndCache(rate float64, at time.Time) {
+	usdVndMu.Lock()
+	cachedUsdVnd, lastCacheUpdate, cachedUsdVndSource = rate, at, ""
+	usdVndMu.Unlock()
+}
+
+func vcbResponse(transfer string) fakeResponse {
+	return fakeResponse{Status: http.StatusOK, ContentType: "text/xml", Body: `<ExrateList>` +
+		`<Exrate CurrencyCode="EUR" Transfer="27,100.00"/>` +
+		`<Exrate CurrencyCode="USD" Transfer="` + transfer + `"/></ExrateList>`}
+}
+
+func TestUsdVndChain(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(f *fakeHTTP)
+		rate   float64
+		source string
+	}{
+		{"fresh cache", func(f *fakeHTTP) {
+			setUsdVndCache(25100, clock().Add(-time.Hour))
+		}, 25100, rateSourceCache},
+		{"live Twelve Data", func(f *fakeHTTP) {
+			f.set(twelveDataHost, jsonResponse(`{"symbol":"USD/VND","close":"25432.5"}`))
+		}, 25432.5, rateSourceLive},
+		{"Twelve Data down, expired cache", func(f *fakeHTTP) {
+			setUsdVndCache(25100, clock().Add(-24*time.Hour))
+		}, 25100, rateSourceStale},
+		{"Twelve Data out of band, expired cache", func(f *fakeHTTP) {
+			f.set(twelveDataHost, jsonResponse(`{"symbol":"USD/VND","close":"2.5432"}`))
+			setUsdVndCache(25100, clock().Add(-24*time.Hour))
+		}, 25100, rateSourceStale},
+		{"no cache, stored rate", func(f *fakeHTTP) {
+			storedUsdVnd = func() (UsdVndRate, bool) {
+				return UsdVndRate{Rate: 25200, Source: rateSourceStored, At: clock().Add(-48 * time.Hour)}, true
+			}
+		}, 25200, rateSourceStored},
+		{"nothing stored, Vietcombank", func(f *fakeHTTP) {
+			f.set(vcbHost, vcbResponse("25,380.00"))
+		}, 25380, rateSourceVCB},
+		{"Vietcombank out of band, fallback", func(f *fakeHTTP) {
+			f.set(vcbHost, vcbResponse("253,800.00"))
+			t.Setenv("USDVND_FALLBACK", "25000")
+		}, 25000, rateSourceFallback},
+		{"Vietcombank down, fallback", func(f *fakeHTTP) {
+			t.Setenv("USDVND_FALLBACK", "25000")
+		}, 25000, rateSourceFallback},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := usdVndChain(t)
+			tt.setup(f)
+			got, err := getUsdVndRate("key")
+			if err != nil || got.Rate != tt.rate || got.Source != tt.source {
+				t.Errorf("getUsdVndRate = %+v, %v; want %g from %s", got, err, tt.rate, tt.source)
+			}
+		})
+	}
+}
+
+func TestUsdVndChainExhausted(t *testing.T) {
+	f := usdVndChain(t)
+	t.Setenv("USDVND_FALLBACK", "1")
+	if got, err := getUsdVndRate("key"); err == nil {
+		t.Errorf("getUsdVndRate = %+v with every source down and an out-of-band fallback", got)
+	}
+	if f.count(twelveDataHost) != 1 || f.count(vcbHost) != 1 {
+		t.Errorf("requests: Twelve Data %d, Vietcombank %d; want one each", f.count(twelveDataHost), f.count(vcbHost))
+	}
+}
+
+func TestUsdVndLiveRateIsCached(t *testing.T) {
+	f := usdVndChain(t)
+	f.set(twelveDataHost, jsonResponse(`{"symbol":"USD/VND","close":"25432.5"}`))
+	getUsdVndRate("key")
+	if got, _ := getUsdVndRate("key"); got.Source != rateSourceCache || got.Rate != 25432.5 {
+		t.Errorf("second lookup = %+v, want the cached live rate", got)
+	}
+	if n := f.count(twelveDataHost); n != 1 {
+		t.Errorf("Twelve Data was called %d times, want 1", n)
+	}
+}
+
+func TestGetCachedUsdVndRefusesFallback(t *testing.T) {
+	usdVndChain(t)
+	t.Setenv("USDVND_FALLBACK", "25000")
+	if rate, err := getCachedUsdVnd("key"); err == nil || rate != 25000 {
+		t.Errorf("getCachedUsdVnd = %g, %v; want the fallback with an error", rate, err)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v3"
+)
+
+// watchdogInterval is how often one container re-checks the broadcast heartbeat
+const watchdogInterval = 5 * time.Minute
+
+var (
+	watchdogMu        sync.Mutex
+	watchdogCheckedAt time.Time
+	watchdogStatus    broadcastStatus
+)
+
+// broadcastStatus is the watchdog's last verdict: Missed when the latest due slot has no
+// broadcast run at or after it
+type broadcastStatus struct {
+	Missed bool
+	Slot   time.Time
+	Last   time.Time
+}
+
+// --- BROADCAST WATCHDOG ---
+
+// watchdogDocID is the settings document holding the heartbeat and alarm throttle
+func watchdogDocID() string {
+	return activeProfile().collectionName("watchdog")
+}
+
+// parseBroadcastSchedule parses "08:00,17:30" (Vietnam time) into minutes after midnight,
+// sorted; ok is false when any entry is malformed
+func parseBroadcastSchedule(raw string) ([]int, bool) {
+	var slots []int
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		h, m, found := strings.Cut(part, ":")
+		hour, err1 := strconv.Atoi(h)
+		minute, err2 := strconv.Atoi(m)
+		if !found || err1 != nil || err2 != nil || hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+			return nil, false
+		}
+		slots = append(slots, hour*60+minute)
+	}
+	sort.Ints(slots)
+	return slots, len(slots) > 0
+}
+
+// formatBroadcastSchedule renders slots back as "08:00, 17:30"
+func formatBroadcastSchedule(slots []int) string {
+	if len(slots) == 0 {
+		return "tắt"
+	}
+	parts := make([]string, len(slots))
+	for i, s := range slots {
+		parts[i] = fmt.Sprintf("%02d:%02d", s/60, s%60)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// lastDueSlot returns the latest scheduled broadcast whose grace period has run out by now,
+// or the zero time when there's none in the last two days
+func lastDueSlot(now time.Time, slots []int, grace time.Duration) time.Time {
+	local := now.In(vnLocation)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, vnLocation)
+	for day := 0; day < 2; day++ {
+		base := midnight.AddDate(0, 0, -day)
+		for i := len(slots) - 1; i >= 0; i-- {
+			slot := base.Add(time.Duration(slots[i]) * time.Minute)
+			if !slot.Add(grace).After(now) {
+				return slot
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// isBroadcastMissed reports whether the run at last covers slot; a run shortly before the
+// slot (within grace, e.g. an early cron) counts. No recorded run yet is never a miss, so a
+// fresh deployment doesn't alarm before its first broadcast.
+func isBroadcastMissed(slot, last time.Time, grace time.Duration) bool {
+	return !slot.IsZero() && !last.IsZero() && last.Before(slot.Add(-grace))
+}
+
+// recordBroadcastRun stamps the heartbeat the watchdog checks. Every scheduled run stamps it,
+// including ones skipped for maintenance or an empty audience: the watchdog guards the
+// schedule firing, not the delivery of each message.
+func recordBroadcastRun() {
+	if settingsCollection == nil {
+		return
+	}
+	_, err := settingsCollection.UpdateOne(context.TODO(), bson.M{"_id": watchdogDocID()},
+		bson.M{"$max": bson.M{"last_broadcast_at": clock()}}, options.Update().SetUpsert(true))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to record broadcast heartbeat: %v", err)
+	}
+}
+
+// broadcastHealth returns the watchdog verdict, re-reading the heartbeat at most once per
+// watchdogInterval
+func broadcastHealth() broadcastStatus {
+	watchdogMu.Lock()
+	defer watchdogMu.Unlock()
+	now := clock()
+	if !watchdogCheckedAt.IsZero() && now.Sub(watchdogCheckedAt) < watchdogInterval {
+		return watchdogStatus
+	}
+	cfg := loadConfig()
+	if settingsCollection == nil || len(cfg.BroadcastSlots) == 0 {
+		return broadcastStatus{}
+	}
+	var doc struct {
+		LastBroadcastAt time.Time `bson:"last_broadcast_at"`
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := settingsCollection.FindOne(ctx, bson.M{"_id": watchdogDocID()}).Decode(&doc); err != nil {
+		// No heartbeat yet (or the read failed): nothing to judge
+		watchdogCheckedAt = now
+		watchdogStatus = broadcastStatus{}
+		return watchdogStatus
+	}
+	slot := lastDueSlot(now, cfg.BroadcastSlots, cfg.BroadcastGrace)
+	watchdogCheckedAt = now
+	watchdogStatus = broadcastStatus{
+		Missed: isBroadcastMissed(slot, doc.LastBroadcastAt, cfg.BroadcastGrace),
+		Slot:   slot,
+		Last:   doc.LastBroadcastAt,
+	}
+	return watchdogStatus
+}
+
+// claimWatchdogAlarm marks slot as alarmed; true only for the invocation that did, so each
+// missed slot raises one alarm however many invocations notice it
+func claimWatchdogAlarm(slot time.Time) bool {
+	res, err := settingsCollection.UpdateOne(context.TODO(),
+		bson.M{"_id": watchdogDocID(), "alarmed_slot": bson.M{"$ne": slot}},
+		bson.M{"$set": bson.M{"alarmed_slot": slot}})
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to claim watchdog alarm: %v", err)
+		return false
+	}
+	return res.ModifiedCount > 0
+}
+
+// checkBroadcastWatchdog runs on every invocation (webhooks, actions, crons) and alarms the
+// admin once when a scheduled broadcast didn't happen, e.g. because the EventBridge rule was
+// disabled. Broadcasts skipped during a maintenance window aren't misses.
+func checkBroadcastWatchdog() {
+	status := broadcastHealth()
+	if !status.Missed {
+		return
+	}
+	if _, ok := activeMaintenance(clock()); ok || !claimWatchdogAlarm(status.Slot) {
+		return
+	}
+	log.Printf("[WATCHDOG] Broadcast due %s missed; last run %s", status.Slot.Format(time.RFC3339), status.Last.Format(time.RFC3339))
+	b, err := newBot(tele.Settings{Token: os.Getenv("TELEGRAM_TOKEN"), Synchronous: true, Offline: true})
+	if err != nil {
+		return
+	}
+	sendAdmin(b, fmt.Sprintf("🚨 Không thấy bản tin lúc %s. Lần chạy gần nhất: %s. Kiểm tra lịch EventBridge.%s",
+		status.Slot.In(vnLocation).Format("15:04 02/01"), status.Last.In(vnLocation).Format("15:04 02/01"), resumeHint()))
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseBroadcastSchedule(t *testing.T) {
+	tests := []struct {
+		raw   string
+		slots []int
+		ok    bool
+	}{
+		{"08:00,17:30", []int{480, 1050}, true},
+		{" 17:30 , 8:05 ,", []int{485, 1050}, true},
+		{"24:00", nil, false},
+		{"08:60", nil, false},
+		{"0800", nil, false},
+		{"08:00,noon", nil, false},
+		{"", nil, false},
+	}
+	for _, tt := range tests {
+		slots, ok := parseBroadcastSchedule(tt.raw)
+		if ok != tt.ok || !reflect.DeepEqual(slots, tt.slots) {
+			t.Errorf("parseBroadcastSchedule(%q) = %v, %v; want %v, %v", tt.raw, slots, ok, tt.slots, tt.ok)
+		}
+	}
+	if got := formatBroadcastSchedule([]int{485, 1050}); got != "08:05, 17:30" {
+		t.Errorf("formatBroadcastSchedule = %q", got)
+	}
+	if got := formatBroadcastSchedule(nil); got != "tắt" {
+		t.Errorf("formatBroadcastSchedule(nil) = %q", got)
+	}
+}
+
+func TestLastDueSlot(t *testing.T) {
+	slots := []int{8 * 60, 17*60 + 30}
+	grace := 20 * time.Minute
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 3, day, hour, minute, 0, 0, vnLocation)
+	}
+	tests := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		// 08:00's grace runs until 08:20
+		{at(10, 8, 19), at(9, 17, 30)},
+		{at(10, 8, 20), at(10, 8, 0)},
+		{at(10, 17, 49), at(10, 8, 0)},
+		{at(10, 23, 0), at(10, 17, 30)},
+		// Just after midnight the evening slot of the day before is the latest
+		{at(10, 0, 5), at(9, 17, 30)},
+		// Read in Vietnam time whatever zone now is in
+		{at(10, 9, 0).UTC(), at(10, 8, 0)},
+	}
+	for _, tt := range tests {
+		if got := lastDueSlot(tt.now, slots, grace); !got.Equal(tt.want) {
+			t.Errorf("lastDueSlot(%v) = %v, want %v", tt.now, got, tt.want)
+		}
+	}
+	if got := lastDueSlot(at(10, 9, 0), nil, grace); !got.IsZero() {
+		t.Errorf("lastDueSlot without slots = %v", got)
+	}
+}
+
+func TestIsBroadcastMissed(t *testing.T) {
+	slot := time.Date(2026, 3, 10, 8, 0, 0, 0, vnLocation)
+	grace := 20 * time.Minute
+	tests := []struct {
+		name string
+		last time.Time
+		want bool
+	}{
+		{"ran on time", slot.Add(time.Minute), false},
+		{"early cron within grace", slot.Add(-10 * time.Minute), false},
+		{"last run was the slot before", slot.Add(-14 * time.Hour), true},
+		{"never ran", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		if got := isBroadcastMissed(slot, tt.last, grace); got != tt.want {
+			t.Errorf("%s: isBroadcastMissed = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if isBroadcastMissed(time.Time{}, slot, grace) {
+		t.Error("no due slot counted as a miss")
+	}
+}
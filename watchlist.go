@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxWatchlistSize keeps a watchlist small enough to quote in one refresh
+const maxWatchlistSize = 10
+
+// /trywatch limits: snapshot prices younger than tryWatchCacheAge are reused instead of
+// re-quoted, and each chat gets one preview per tryWatchInterval
+const (
+	tryWatchCacheAge = 15 * time.Minute
+	tryWatchInterval = 30 * time.Second
+)
+
+var (
+	tryWatchMu   sync.Mutex
+	lastTryWatch = make(map[int64]time.Time)
+)
+
+// --- WATCHLIST ---
+
+// watchedList is a chat's stored watchlist with the tier fields that cap it
+type watchedList struct {
+	ChatID       int64     `bson:"chat_id"`
+	Watchlist    []string  `bson:"watchlist"`
+	Tier         string    `bson:"tier"`
+	PremiumUntil time.Time `bson:"premium_until"`
+}
+
+// watchedListProjection loads what a watchedList holds
+var watchedListProjection = bson.M{"chat_id": 1, "watchlist": 1, "tier": 1, "premium_until": 1}
+
+// active is the part of the watchlist in use at now (see capWatchlist)
+func (w watchedList) active(now time.Time) []string {
+	return capWatchlist(w.Watchlist, effectiveTier(w.Tier, w.PremiumUntil, now))
+}
+
+// loadWatchedList returns the chat's stored watchlist; false when not subscribed
+func loadWatchedList(chatID int64) (watchedList, bool) {
+	if store == nil {
+		return watchedList{}, false
+	}
+	w, ok, err := store.Watchlist(context.TODO(), chatID)
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load watchlist for %d: %v", chatID, err)
+	}
+	return w, ok
+}
+
+// getWatchlist returns the chat's watched symbols in use (nil when not subscribed)
+func getWatchlist(chatID int64) []string {
+	w, ok := loadWatchedList(chatID)
+	if !ok {
+		return nil
+	}
+	return w.active(clock())
+}
+
+// loadWatchlists returns every non-empty watchlist in use by chat
+func loadWatchlists() map[int64][]string {
+	lists := make(map[int64][]string)
+	if userCollection == nil {
+		return lists
+	}
+	cursor, err := userCollection.Find(context.TODO(), bson.M{"watchlist.0": bson.M{"$exists": true}},
+		options.Find().SetProjection(watchedListProjection))
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to load watchlists: %v", err)
+		return lists
+	}
+	defer cursor.Close(context.TODO())
+	now := clock()
+	for cursor.Next(context.TODO()) {
+		var w watchedList
+		if cursor.Decode(&w) == nil {
+			lists[w.ChatID] = w.active(now)
+		}
+	}
+	return lists
+}
+
+// watchSymbols adds symbols to the watchlist; returns false if not subscribed
+func watchSymbols(chatID int64, symbols ...string) bool {
+	if store == nil {
+		return false
+	}
+	ok, err := store.WatchSymbols(context.TODO(), chatID, symbols, clock())
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to update watchlist for %d: %v", chatID, err)
+		return false
+	}
+	return ok
+}
+
+// unwatchSymbol removes a symbol from the watchlist; returns false if not subscribed
+func unwatchSymbol(chatID int64, symbol string) bool {
+	if store == nil {
+		return false
+	}
+	ok, err := store.UnwatchSymbol(context.TODO(), chatID, symbol, clock())
+	if err != nil {
+		log.Printf("[DATABASE ERROR] Failed to update watchlist for %d: %v", chatID, err)
+		return false
+	}
+	return ok
+}
+
+// watchReply handles "/watch", "/watch add SYMBOL" and "/watch remove SYMBOL"
+func watchReply(chatID int64, payload string) string {
+	args := strings.Fields(payload)
+	if len(args) == 0 {
+		w, _ := loadWatchedList(chatID)
+		list := w.active(clock())
+		if len(list) == 0 {
+			return "ℹ️ Danh sách theo dõi trống. Thêm mã bằng `/watch add btc`."
+		}
+		labels := make([]string, len(list))
+		for i, symbol := range list {
+			labels[i] = symbolLabel(symbol)
+		}
+		text := "👀 **Danh sách theo dõi:**\n• " + strings.Join(labels, "\n• ")
+		if paused := w.Watchlist[len(list):]; len(paused) > 0 {
+			text += fmt.Sprintf("\n⏸ Tạm dừng (vượt giới hạn %d mã của gói miễn phí): %s", freeWatchlistLimit, strings.Join(paused, ", "))
+		}
+		return text
+	}
+	if len(args) != 2 {
+		return "ℹ️ Cú pháp: `/watch add btc` hoặc `/watch remove btc`"
+	}
+	symbol := resolveSymbol(args[1])
+	switch strings.ToLower(args[0]) {
+	case "add":
+		if len(getWatchlist(chatID)) >= maxWatchlistSize {
+			return fmt.Sprintf("⚠️ Danh sách theo dõi tối đa %d mã.", maxWatchlistSize)
+		}
+		if rejection := probeSymbol(symbol); rejection != "" {
+			return rejection
+		}
+		if !watchSymbols(chatID, symbol) {
+			return "ℹ️ Bạn cần đăng ký bằng /start trước."
+		}
+		return fmt.Sprintf("✅ Đã thêm %s vào danh sách theo dõi.", symbol)
+	case "remove":
+		if !unwatchSymbol(chatID, symbol) {
+			return "ℹ️ Bạn cần đăng ký bằng /start trước."
+		}
+		return fmt.Sprintf("🗑 Đã bỏ %s khỏi danh sách theo dõi.", symbol)
+	default:
+		return "ℹ️ Cú pháp: `/watch add btc` hoặc `/watch remove btc`"
+	}
+}
+
+// splitSymbolList splits a pasted symbol list on commas and whitespace, upper-casing entries
+// (/import resolves them itself, keeping the raw entry for its reply)
+func splitSymbolList(raw string) []string {
+	fields := strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\n' || r == '\t' || r == '\r'
+	})
+	for i, f := range fields {
+		fields[i] = strings.ToUpper(f)
+	}
+	return fields
+}
+
+// allowTryWatch applies the per-chat /trywatch rate limit
+func allowTryWatch(chatID int64) bool {
+	tryWatchMu.Lock()
+	defer tryWatchMu.Unlock()
+	if clock().Sub(lastTryWatch[chatID]) < tryWatchInterval {
+		return false
+	}
+	lastTryWatch[chatID] = clock()
+	return true
+}
+
+// tryWatchReply handles "/trywatch btc,eth": renders the symbols the way the
+// watchlist shows them, without saving anything. Prices from a recent broadcast snapshot
+// are reused; only the rest are quoted.
+func tryWatchReply(chatID int64, payload string) string {
+	symbols := splitSymbolList(payload)
+	if len(symbols) == 0 {
+		return "ℹ️ Cú pháp: `/trywatch btc,eth,gold`"
+	}
+	for i, s := range symbols {
+		symbols[i] = resolveSymbol(s)
+	}
+	if len(symbols) > maxWatchlistSize {
+		return fmt.Sprintf("⚠️ Danh sách theo dõi tối đa %d mã.", maxWatchlistSize)
+	}
+	if !allowTryWatch(chatID) {
+		return fmt.Sprintf("⏳ Vui lòng đợi %s giữa hai lần xem trước.", tryWatchInterval)
+	}
+
+	quotes := make(map[string]MarketData, len(symbols))
+	var missing []string
+	snapshots := loadRecentSnapshots(1)
+	for _, symbol := range symbols {
+		if len(snapshots) == 1 && clock().Sub(snapshots[0].At) < tryWatchCacheAge {
+			if price, ok := snapshots[0].Prices[symbol]; ok {
+				d := MarketData{Price: price, Change: "N/A"}
+				if c, ok := snapshots[0].Changes[symbol]; ok {
+					d.Change, d.Percent, d.HasPercent = formatPercent(c), c, true
+				}
+				quotes[symbol] = d
+				continue
+			}
+		}
+		missing = append(missing, symbol)
+	}
+	for symbol, d := range fetchQuotes(missing) {
+		quotes[symbol] = d
+	}
+	return "👀 **XEM TRƯỚC DANH SÁCH THEO DÕI**\n" + boardBody(symbols, quotes) +
+		"\n\n_Chưa lưu. Dùng /watch add để thêm từng mã._"
+}
+
+// symbolAliases maps common shorthands (and the canonical IDs themselves) to canonical asset IDs
+var symbolAliases = map[string]string{
+	"GOLD":    "gold",
+	"XAU":     "gold",
+	"SILVER":  "silver",
+	"XAG":     "silver",
+	"BTC":     "btc",
+	"BITCOIN": "btc",
+	"ETH":     "eth",
+	"SOL":     "sol",
+	"EURO":    "eurusd",
+}
+
+// resolveSymbol maps anything a user types or pastes onto a canonical asset ID:
+// TradingView EXCHANGE:SYMBOL entries go through the export table (or lose their
+// exchange), shorthands through symbolAliases, six-letter pairs like XAUUSD get their
+// slash back, and provider symbols ("XAU/USD") are mapped back by the quote client
+func resolveSymbol(raw string) string {
+	s := strings.ToUpper(strings.TrimSpace(raw))
+	if _, ticker, ok := strings.Cut(s, ":"); ok {
+		for ours, tv := range tradingViewSymbols {
+			if tv == s {
+				return ours
+			}
+		}
+		s = ticker
+	}
+	if alias, ok := symbolAliases[s]; ok {
+		return alias
+	}
+	if len(s) == 6 && !strings.Contains(s, "/") && (strings.HasSuffix(s, "USD") || strings.HasPrefix(s, "USD")) {
+		s = s[:3] + "/" + s[3:]
+	}
+	return canonicalFromTwelveData(s)
+}
+
+// importCandidates resolves pasted entries, dropping duplicates, and splits them into the
+// symbols already in the watchlist and the new ones
+func importCandidates(entries, current []string) (present, candidates []string) {
+	have := make(map[string]bool, len(current))
+	for _, s := range current {
+		have[s] = true
+	}
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		symbol := resolveSymbol(e)
+		if symbol == "" || seen[symbol] {
+			continue
+		}
+		seen[symbol] = true
+		if have[symbol] {
+			present = append(present, symbol)
+			continue
+		}
+		candidates = append(candidates, symbol)
+	}
+	return present, candidates
+}
+
+// importText is the list /import reads: the text after the command, or else the message
+// it replies to
+func importText(payload, replied string) string {
+	if strings.TrimSpace(payload) == "" {
+		return replied
+	}
+	return payload
+}
+
+// importReply handles "/import" with a pasted symbol list, either after the command or in
+// the message it replies to. Every entry is validated in one batch quote and the new ones
+// are added in a single write, up to limit symbols in the watchlist (the chat's tier).
+func importReply(chatID int64, payload, replied string, limit int) string {
+	entries := splitSymbolList(importText(payload, replied))
+	if len(entries) == 0 {
+		return "ℹ️ Gõ `/import BTC/USD, ETH/USD, OANDA:XAUUSD` hoặc trả lời một tin nhắn chứa danh sách mã bằng /import."
+	}
+
+	stored, _ := loadWatchedList(chatID)
+	current := stored.Watchlist
+	present, candidates := importCandidates(entries, current)
+
+	var valid, unknown []string
+	quotes := fetchQuotes(candidates)
+	for _, symbol := range candidates {
+		d := quotes[symbol]
+		switch {
+		case errors.Is(d.Err, errRateLimited):
+			return "⚠️ Hết lượt gọi API, chưa kiểm tra được các mã. Vui lòng thử lại sau."
+		case d.Err != nil:
+			unknown = append(unknown, symbol)
+		default:
+			valid = append(valid, symbol)
+		}
+	}
+	var skipped []string
+	if room := max(0, limit-len(current)); len(valid) > room {
+		valid, skipped = valid[:room], valid[room:]
+	}
+	if len(valid) > 0 {
+		if !watchSymbols(chatID, valid...) {
+			return "ℹ️ Bạn cần đăng ký bằng /start trước."
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📥 **KẾT QUẢ NHẬP DANH SÁCH**\n")
+	fmt.Fprintf(&sb, "• Đã thêm (%d): %s\n", len(valid), joinOrDash(valid))
+	fmt.Fprintf(&sb, "• Đã có sẵn (%d): %s\n", len(present), joinOrDash(present))
+	fmt.Fprintf(&sb, "• Không nhận ra (%d): %s", len(unknown), joinOrDash(unknown))
+	if len(skipped) > 0 {
+		fmt.Fprintf(&sb, "\n• Vượt giới hạn %d mã, bỏ qua: %s", limit, strings.Join(skipped, ", "))
+		if limit < maxWatchlistSize {
+			sb.WriteString("\n" + gatedFeatureText(featureLargeWatchlist))
+		}
+	}
+	return sb.String()
+}
+
+// joinOrDash lists items, or "—" when there are none
+func joinOrDash(items []string) string {
+	if len(items) == 0 {
+		return "—"
+	}
+	return strings.Join(items, ", ")
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/base64"
+	"log"
+	"mime"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// defaultWebhookMaxBody bounds a webhook request body. A Telegram update is a few KB; the
+// limit leaves room for a relay's batch of updates.
+const defaultWebhookMaxBody = 256 << 10
+
+// --- WEBHOOK GUARDS ---
+
+// webhookBody checks a webhook request before anything is parsed, the database touched or
+// the bot constructed, and returns the decoded body. Oversized bodies get 413 and anything
+// that isn't application/json gets 415. The runtime base64-encodes bodies it doesn't treat
+// as text, so IsBase64Encoded is honoured rather than assumed false.
+func webhookBody(request events.LambdaFunctionURLRequest) (string, *events.LambdaFunctionURLResponse) {
+	limit := envInt("WEBHOOK_MAX_BODY_BYTES", defaultWebhookMaxBody)
+	raw := request.Body
+	// Reject on the encoded length first so a huge junk body is never decoded
+	if (request.IsBase64Encoded && base64.StdEncoding.DecodedLen(len(raw)) > limit+2) ||
+		(!request.IsBase64Encoded && len(raw) > limit) {
+		log.Printf("[WEBHOOK] Rejected %d-byte body (limit %d)", len(raw), limit)
+		return "", &events.LambdaFunctionURLResponse{StatusCode: 413, Body: "Payload too large"}
+	}
+	if media, _, err := mime.ParseMediaType(headerValue(request.Headers, "content-type")); err != nil || media != "application/json" {
+		log.Printf("[WEBHOOK] Rejected content type %q", headerValue(request.Headers, "content-type"))
+		return "", &events.LambdaFunctionURLResponse{StatusCode: 415, Body: "Unsupported media type"}
+	}
+	if !request.IsBase64Encoded {
+		return raw, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		log.Printf("[WEBHOOK] Rejected undecodable base64 body: %v", err)
+		return "", &events.LambdaFunctionURLResponse{StatusCode: 400, Body: "Malformed request"}
+	}
+	if len(decoded) > limit {
+		return "", &events.LambdaFunctionURLResponse{StatusCode: 413, Body: "Payload too large"}
+	}
+	return string(decoded), nil
+}
+
+// headerValue looks a header up case-insensitively; Function URLs lowercase header names,
+// but direct invocations and test harnesses may not
+func headerValue(headers map[string]string, name string) string {
+	if v, ok := headers[name]; ok {
+		return v
+	}
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
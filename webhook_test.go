@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestWebhookBody(t *testing.T) {
+	t.Setenv("WEBHOOK_MAX_BODY_BYTES", "64")
+	update := `{"update_id":1,"message":{"text":"/start"}}`
+	jsonHeaders := map[string]string{"content-type": "application/json"}
+	tests := []struct {
+		name    string
+		request events.LambdaFunctionURLRequest
+		body    string
+		status  int
+	}{
+		{"plain JSON", events.LambdaFunctionURLRequest{Body: update, Headers: jsonHeaders}, update, 0},
+		{"charset parameter and mixed-case header", events.LambdaFunctionURLRequest{Body: update,
+			Headers: map[string]string{"Content-Type": "application/json; charset=utf-8"}}, update, 0},
+		{"base64 body", events.LambdaFunctionURLRequest{Body: base64.StdEncoding.EncodeToString([]byte(update)),
+			IsBase64Encoded: true, Headers: jsonHeaders}, update, 0},
+		{"oversized body", events.LambdaFunctionURLRequest{Body: strings.Repeat("x", 65), Headers: jsonHeaders}, "", 413},
+		{"oversized base64 body", events.LambdaFunctionURLRequest{Body: base64.StdEncoding.EncodeToString([]byte(strings.Repeat("x", 200))),
+			IsBase64Encoded: true, Headers: jsonHeaders}, "", 413},
+		{"decoded body just over the limit", events.LambdaFunctionURLRequest{Body: base64.StdEncoding.EncodeToString([]byte(strings.Repeat("x", 65))),
+			IsBase64Encoded: true, Headers: jsonHeaders}, "", 413},
+		{"form content type", events.LambdaFunctionURLRequest{Body: update,
+			Headers: map[string]string{"content-type": "application/x-www-form-urlencoded"}}, "", 415},
+		{"missing content type", events.LambdaFunctionURLRequest{Body: update}, "", 415},
+		{"malformed base64", events.LambdaFunctionURLRequest{Body: "%%%not base64", IsBase64Encoded: true, Headers: jsonHeaders}, "", 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, resp := webhookBody(tt.request)
+			status := 0
+			if resp != nil {
+				status = resp.StatusCode
+			}
+			if status != tt.status || body != tt.body {
+				t.Errorf("webhookBody = %q, status %d; want %q, status %d", body, status, tt.body, tt.status)
+			}
+		})
+	}
+}
+
+func TestHeaderValue(t *testing.T) {
+	headers := map[string]string{"X-Telegram-Bot-Api-Secret-Token": "s3cret", "content-type": "application/json"}
+	if got := headerValue(headers, "x-telegram-bot-api-secret-token"); got != "s3cret" {
+		t.Errorf("case-insensitive lookup = %q", got)
+	}
+	if got := headerValue(headers, "content-type"); got != "application/json" {
+		t.Errorf("exact lookup = %q", got)
+	}
+	if got := headerValue(headers, "authorization"); got != "" {
+		t.Errorf("missing header = %q", got)
+	}
+}
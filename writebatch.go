@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultWriteBatchSize is how many queued writes one BulkWrite carries; WRITE_BATCH_SIZE
+// overrides it
+const defaultWriteBatchSize = 100
+
+// defaultWriteBatchInterval is the longest a queued write waits for its batch to fill;
+// WRITE_BATCH_INTERVAL overrides it
+const defaultWriteBatchInterval = 2 * time.Second
+
+// queuedWrite is one write waiting for its batch
+type queuedWrite struct {
+	coll  *mongo.Collection
+	model mongo.WriteModel
+}
+
+// writeBatch collects the per-recipient writes of a broadcast (outbox records, pinned
+// message IDs) and sends them as BulkWrites, so a thousand recipients cost a few dozen
+// round trips instead of thousands. Queued writes must not depend on each other: each
+// touches its own document, and a batch is written unordered.
+type writeBatch struct {
+	mu       sync.Mutex
+	pending  []queuedWrite
+	timer    *time.Timer
+	size     int
+	interval time.Duration
+	failed   int
+
+	// flushMu keeps flushes in queue order when the timer and a caller flush at once
+	flushMu sync.Mutex
+}
+
+var (
+	activeBatchMu sync.Mutex
+	activeBatch   *writeBatch
+)
+
+// bulkWrite sends one BulkWrite; tests swap it to record or fail writes
+var bulkWrite = func(ctx context.Context, coll *mongo.Collection, models []mongo.WriteModel, opts ...*options.BulkWriteOptions) error {
+	_, err := coll.BulkWrite(ctx, models, opts...)
+	return err
+}
+
+// --- BATCHED WRITES ---
+
+// startWriteBatch routes queueWrite calls into a new batch until finishWriteBatch
+func startWriteBatch() *writeBatch {
+	w := &writeBatch{
+		size:     max(1, envInt("WRITE_BATCH_SIZE", defaultWriteBatchSize)),
+		interval: envDuration("WRITE_BATCH_INTERVAL", defaultWriteBatchInterval),
+	}
+	activeBatchMu.Lock()
+	activeBatch = w
+	activeBatchMu.Unlock()
+	return w
+}
+
+// finishWriteBatch writes whatever is still queued and goes back to direct writes. It is
+// deferred by the broadcast, so nothing queued is lost when a send panics.
+func finishWriteBatch(w *writeBatch) {
+	activeBatchMu.Lock()
+	if activeBatch == w {
+		activeBatch = nil
+	}
+	activeBatchMu.Unlock()
+	w.flush()
+	w.mu.Lock()
+	failed := w.failed
+	w.mu.Unlock()
+	if failed > 0 {
+		log.Printf("[DATABASE ERROR] %d batched writes failed during the broadcast", failed)
+	}
+}
+
+// flushWrites writes the active batch's queue now; checkpoints call it first, so the
+// checkpoint never gets ahead of the writes of the sends it records
+func flushWrites() {
+	activeBatchMu.Lock()
+	w := activeBatch
+	activeBatchMu.Unlock()
+	if w != nil {
+		w.flush()
+	}
+}
+
+// queueWrite adds a write to the active batch, or performs it at once when no broadcast
+// is batching
+func queueWrite(coll *mongo.Collection, model mongo.WriteModel) {
+	if coll == nil {
+		return
+	}
+	activeBatchMu.Lock()
+	w := activeBatch
+	activeBatchMu.Unlock()
+	if w == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := bulkWrite(ctx, coll, []mongo.WriteModel{model}); err != nil {
+			log.Printf("[DATABASE ERROR] Failed to write to %s: %v", coll.Name(), err)
+		}
+		return
+	}
+	w.mu.Lock()
+	w.pending = append(w.pending, queuedWrite{coll, model})
+	full := len(w.pending) >= w.size
+	if !full && w.timer == nil && w.interval > 0 {
+		w.timer = time.AfterFunc(w.interval, w.flush)
+	}
+	w.mu.Unlock()
+	if full {
+		w.flush()
+	}
+}
+
+// flush writes the queue in BulkWrites of at most size writes per collection. A failed
+// chunk is logged and counted, never retried and never fatal to the broadcast.
+func (w *writeBatch) flush() {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+	w.mu.Lock()
+	pending := w.pending
+	w.pending = nil
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	w.mu.Unlock()
+
+	byColl := make(map[*mongo.Collection][]mongo.WriteModel)
+	var order []*mongo.Collection
+	for _, q := range pending {
+		if _, ok := byColl[q.coll]; !ok {
+			order = append(order, q.coll)
+		}
+		byColl[q.coll] = append(byColl[q.coll], q.model)
+	}
+	failed := 0
+	for _, coll := range order {
+		models := byColl[coll]
+		for start := 0; start < len(models); start += w.size {
+			chunk := models[start:min(start+w.size, len(models))]
+			failed += writeChunk(coll, chunk)
+		}
+	}
+	if failed > 0 {
+		w.mu.Lock()
+		w.failed += failed
+		w.mu.Unlock()
+		withMetrics(func(m *InvocationMetrics) { m.WriteFailures += failed })
+	}
+}
+
+// writeChunk sends one BulkWrite and returns how many of its writes failed
+func writeChunk(coll *mongo.Collection, chunk []mongo.WriteModel) int {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := bulkWrite(ctx, coll, chunk, options.BulkWrite().SetOrdered(false))
+	if err == nil {
+		return 0
+	}
+	failed := len(chunk)
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil && len(bulkErr.WriteErrors) > 0 {
+		failed = len(bulkErr.WriteErrors)
+	}
+	log.Printf("[DATABASE ERROR] Batched write of %d to %s failed (%d lost): %v", len(chunk), coll.Name(), failed, err)
+	return failed
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// bulkCall is one BulkWrite a test saw
+type bulkCall struct {
+	Coll string
+	N    int
+}
+
+// recordBulkWrites swaps bulkWrite for one that records each call and answers with
+// fail's error (nil writes succeed)
+func recordBulkWrites(t *testing.T, fail func(call int, models []mongo.WriteModel) error) func() []bulkCall {
+	t.Helper()
+	var mu sync.Mutex
+	var calls []bulkCall
+	saved := bulkWrite
+	bulkWrite = func(_ context.Context, coll *mongo.Collection, models []mongo.WriteModel, _ ...*options.BulkWriteOptions) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, bulkCall{coll.Name(), len(models)})
+		if fail != nil {
+			return fail(len(calls), models)
+		}
+		return nil
+	}
+	t.Cleanup(func() { bulkWrite = saved })
+	return func() []bulkCall {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]bulkCall(nil), calls...)
+	}
+}
+
+// testCollections returns collections of a client that never connects; bulkWrite is
+// swapped, so nothing reaches them
+func testCollections(t testing.TB, names ...string) []*mongo.Collection {
+	t.Helper()
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:1").SetServerSelectionTimeout(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+	colls := make([]*mongo.Collection, len(names))
+	for i, name := range names {
+		colls[i] = client.Database("test").Collection(name)
+	}
+	return colls
+}
+
+func testWrite(i int) mongo.WriteModel {
+	return mongo.NewInsertOneModel().SetDocument(bson.M{"i": i})
+}
+
+// withWriteBatch starts a batch of the given size and interval
+func withWriteBatch(t *testing.T, size int, interval time.Duration) *writeBatch {
+	t.Helper()
+	w := startWriteBatch()
+	w.size, w.interval = size, interval
+	t.Cleanup(func() {
+		activeBatchMu.Lock()
+		activeBatch = nil
+		activeBatchMu.Unlock()
+	})
+	return w
+}
+
+func TestWriteBatchFlushesWhenFull(t *testing.T) {
+	calls := recordBulkWrites(t, nil)
+	outbox := testCollections(t, "outbox")[0]
+	w := withWriteBatch(t, 3, 0)
+	for i := 0; i < 7; i++ {
+		queueWrite(outbox, testWrite(i))
+	}
+	if got, want := calls(), []bulkCall{{"outbox", 3}, {"outbox", 3}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("before finishing: %v, want %v", got, want)
+	}
+	finishWriteBatch(w)
+	if got := calls(); len(got) != 3 || got[2] != (bulkCall{"outbox", 1}) {
+		t.Errorf("after finishing: %v, want the last write flushed", got)
+	}
+	// Back to direct writes
+	queueWrite(outbox, testWrite(8))
+	if got := calls(); len(got) != 4 || got[3] != (bulkCall{"outbox", 1}) {
+		t.Errorf("after the batch: %v, want a direct write", got)
+	}
+}
+
+func TestWriteBatchGroupsByCollection(t *testing.T) {
+	calls := recordBulkWrites(t, nil)
+	colls := testCollections(t, "outbox", "users")
+	w := withWriteBatch(t, 100, 0)
+	for i := 0; i < 5; i++ {
+		queueWrite(colls[i%2], testWrite(i))
+	}
+	queueWrite(nil, testWrite(99))
+	flushWrites()
+	if got, want := calls(), []bulkCall{{"outbox", 3}, {"users", 2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("flush = %v, want %v", got, want)
+	}
+	finishWriteBatch(w)
+	if got := calls(); len(got) != 2 {
+		t.Errorf("an empty queue was written: %v", got)
+	}
+}
+
+func TestWriteBatchFlushesOnTimer(t *testing.T) {
+	calls := recordBulkWrites(t, nil)
+	outbox := testCollections(t, "outbox")[0]
+	withWriteBatch(t, 100, 10*time.Millisecond)
+	queueWrite(outbox, testWrite(1))
+	deadline := time.Now().Add(2 * time.Second)
+	for len(calls()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := calls(); !reflect.DeepEqual(got, []bulkCall{{"outbox", 1}}) {
+		t.Errorf("timer flush = %v", got)
+	}
+}
+
+func TestWriteBatchPartialFailure(t *testing.T) {
+	recordBulkWrites(t, func(call int, models []mongo.WriteModel) error {
+		switch call {
+		case 1:
+			// Two of the chunk's writes were rejected, the rest landed
+			return mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{
+				{WriteError: mongo.WriteError{Index: 0, Code: 11000}},
+				{WriteError: mongo.WriteError{Index: 2, Code: 11000}},
+			}}
+		case 2:
+			return errors.New("connection reset")
+		}
+		return nil
+	})
+	outbox := testCollections(t, "outbox")[0]
+	w := withWriteBatch(t, 4, 0)
+	for i := 0; i < 10; i++ {
+		queueWrite(outbox, testWrite(i))
+	}
+	finishWriteBatch(w)
+	// Chunk 1 loses its 2 rejected writes, chunk 2 all 4, chunk 3 nothing
+	if w.failed != 6 {
+		t.Errorf("failed = %d, want 6", w.failed)
+	}
+}